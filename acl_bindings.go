@@ -1,5 +1,7 @@
 package sarama
 
+import "strings"
+
 // Resource holds information about acl resource type
 type Resource struct {
 	ResourceType        AclResourceType
@@ -46,6 +48,18 @@ func (r *Resource) decode(pd packetDecoder, version int16) (err error) {
 	return nil
 }
 
+// aclClusterResourceName is the name of the only cluster resource.
+const aclClusterResourceName = "kafka-cluster"
+
+// matches returns true if the literal or prefixed resource applies to the resource of the
+// same type with the given name, the literal name "*" applying to all of them.
+func (r *Resource) matches(name string) bool {
+	if r.ResourcePatternType == AclPatternPrefixed {
+		return strings.HasPrefix(name, r.ResourceName)
+	}
+	return r.ResourceName == "*" || r.ResourceName == name
+}
+
 // Acl holds information about acl type
 type Acl struct {
 	Principal      string
@@ -0,0 +1,139 @@
+package sarama
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ACLDiff are the ACLs ClusterAdmin.SyncACLs creates and deletes, or would in dry-run mode,
+// grouped by resource.
+type ACLDiff struct {
+	Create []*ResourceAcls
+	Delete []*ResourceAcls
+}
+
+// Empty returns true if the ACLs are already in sync.
+func (d *ACLDiff) Empty() bool {
+	return len(d.Create) == 0 && len(d.Delete) == 0
+}
+
+// aclResourceChanges are the ACLs to create and delete on a resource.
+type aclResourceChanges struct {
+	resource Resource
+	create   []*Acl
+	delete   []*Acl
+}
+
+// normalizeACLResource defaults the pattern type of resources to literal, as brokers do.
+func normalizeACLResource(resource Resource) Resource {
+	if resource.ResourcePatternType == AclPatternUnknown {
+		resource.ResourcePatternType = AclPatternLiteral
+	}
+	return resource
+}
+
+// diffACLs compares the desired ACLs with the current ones of the same resources, returning
+// the changes of each resource sorted by resource.
+func diffACLs(desired []ResourceAcls, current map[Resource][]*Acl) []*aclResourceChanges {
+	wanted := make(map[Resource]map[Acl]bool)
+	for _, resourceACLs := range desired {
+		resource := normalizeACLResource(resourceACLs.Resource)
+		if wanted[resource] == nil {
+			wanted[resource] = make(map[Acl]bool)
+		}
+		for _, acl := range resourceACLs.Acls {
+			wanted[resource][*acl] = true
+		}
+	}
+
+	var changes []*aclResourceChanges
+	for resource, acls := range wanted {
+		existing := make(map[Acl]bool, len(current[resource]))
+		for _, acl := range current[resource] {
+			existing[*acl] = true
+		}
+
+		c := &aclResourceChanges{resource: resource}
+		for acl := range acls {
+			if !existing[acl] {
+				acl := acl
+				c.create = append(c.create, &acl)
+			}
+		}
+		for acl := range existing {
+			if !acls[acl] {
+				acl := acl
+				c.delete = append(c.delete, &acl)
+			}
+		}
+		if len(c.create) > 0 || len(c.delete) > 0 {
+			sortACLs(c.create)
+			sortACLs(c.delete)
+			changes = append(changes, c)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return aclResourceLess(changes[i].resource, changes[j].resource)
+	})
+	return changes
+}
+
+func aclResourceLess(a, b Resource) bool {
+	if a.ResourceType != b.ResourceType {
+		return a.ResourceType < b.ResourceType
+	}
+	if a.ResourceName != b.ResourceName {
+		return a.ResourceName < b.ResourceName
+	}
+	return a.ResourcePatternType < b.ResourcePatternType
+}
+
+func sortACLs(acls []*Acl) {
+	sort.Slice(acls, func(i, j int) bool {
+		a, b := acls[i], acls[j]
+		if a.Principal != b.Principal {
+			return a.Principal < b.Principal
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		return a.PermissionType < b.PermissionType
+	})
+}
+
+// newACLDiff groups the changes into an ACLDiff.
+func newACLDiff(changes []*aclResourceChanges) *ACLDiff {
+	diff := &ACLDiff{}
+	for _, c := range changes {
+		if len(c.create) > 0 {
+			diff.Create = append(diff.Create, &ResourceAcls{Resource: c.resource, Acls: c.create})
+		}
+		if len(c.delete) > 0 {
+			diff.Delete = append(diff.Delete, &ResourceAcls{Resource: c.resource, Acls: c.delete})
+		}
+	}
+	return diff
+}
+
+// aclFilterFor returns the filter matching exactly the ACL of the resource.
+func aclFilterFor(resource Resource, acl *Acl) *AclFilter {
+	name, principal, host := resource.ResourceName, acl.Principal, acl.Host
+	return &AclFilter{
+		ResourceType:              resource.ResourceType,
+		ResourceName:              &name,
+		ResourcePatternTypeFilter: resource.ResourcePatternType,
+		Principal:                 &principal,
+		Host:                      &host,
+		Operation:                 acl.Operation,
+		PermissionType:            acl.PermissionType,
+	}
+}
+
+// aclResourceError is the error of applying the changes of a resource.
+func aclResourceError(resource Resource, err error) error {
+	return fmt.Errorf("kafka: failed to sync the ACLs of %s %s: %w", resource.ResourceType.String(), resource.ResourceName, err)
+}
@@ -0,0 +1,38 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffACLs(t *testing.T) {
+	topic := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic", ResourcePatternType: AclPatternLiteral}
+	prefixed := Resource{ResourceType: AclResourceTopic, ResourceName: "my_", ResourcePatternType: AclPatternPrefixed}
+	read := Acl{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow}
+	write := Acl{Principal: "User:alice", Host: "*", Operation: AclOperationWrite, PermissionType: AclPermissionAllow}
+	deny := Acl{Principal: "User:bob", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionDeny}
+
+	desired := []ResourceAcls{
+		{Resource: Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}, Acls: []*Acl{&read}},
+		{Resource: topic, Acls: []*Acl{&write}},
+		{Resource: prefixed, Acls: []*Acl{&read}},
+	}
+	current := map[Resource][]*Acl{
+		topic:    {&read, &deny},
+		prefixed: {&read},
+	}
+
+	diff := newACLDiff(diffACLs(desired, current))
+	expected := &ACLDiff{
+		Create: []*ResourceAcls{{Resource: topic, Acls: []*Acl{&write}}},
+		Delete: []*ResourceAcls{{Resource: topic, Acls: []*Acl{&deny}}},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, diff)
+	}
+
+	current[topic] = []*Acl{&write, &read}
+	if diff := newACLDiff(diffACLs(desired, current)); !diff.Empty() {
+		t.Errorf("Expected no change once in sync, got %+v", diff)
+	}
+}
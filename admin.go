@@ -1,10 +1,14 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,6 +24,23 @@ type ClusterAdmin interface {
 	// may not return information about the new topic.The validateOnly option is supported from version 0.10.2.0.
 	CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error
 
+	// Create the topic dst with the partition count, replication factor and configs set on
+	// the topic src, not the default ones nor the sensitive ones, which cannot be read.
+	// overrides are set on dst instead of the configs of src, a nil value leaving the config
+	// of dst to its default. If preserveAssignment is true, the replicas of each partition of
+	// dst are the ones of the same partition of src.
+	CloneTopic(src, dst string, overrides map[string]*string, preserveAssignment bool) error
+
+	// Compare the topic with its spec and apply the changes needed to match it, returning them:
+	// the topic is created if it does not exist, or else its partitions are added, its
+	// replication factor is increased by reassigning its partitions and its configs are set
+	// and deleted. Changes that cannot be applied, such as decreasing the number of partitions
+	// or the replication factor, are returned as the Conflicts of the diff along with
+	// ErrTopicSpecConflict, and nothing is applied then. With dryRun, the changes are only
+	// computed. The reassignment is asynchronous, see WaitForPartitionReassignments.
+	// Increasing the replication factor is supported by brokers with version 2.4.0.0 or higher.
+	ApplyTopicSpec(spec TopicSpec, dryRun bool) (*TopicSpecDiff, error)
+
 	// List the topics available in the cluster with the default options.
 	ListTopics() (map[string]TopicDetail, error)
 
@@ -40,20 +61,89 @@ type ClusterAdmin interface {
 	// success for all the brokers to become aware that the partitions have been created.
 	// During this time, ClusterAdmin#describeTopics may not return information about the
 	// new partitions. This operation is supported by brokers with version 1.0.0 or higher.
+	// If validateOnly is true, the controller only validates the request, so that the errors
+	// it would return can be checked beforehand, and no partition is created.
 	CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error
 
+	// Generate a balanced assignment of the replicas of numPartitions partitions to the live
+	// brokers of the cluster, spreading the replicas of each partition across as many racks
+	// as possible. The assignment can be passed to CreatePartitions, options.StartPartition
+	// being the current number of partitions of the topic, or to CreateTopic using
+	// TopicDetail.SetReplicaAssignment.
+	GenerateReplicaAssignment(numPartitions int32, replicationFactor int16, options *ReplicaAssignmentOptions) ([][]int32, error)
+
 	// Alter the replica assignment for partitions.
 	// This operation is supported by brokers with version 2.4.0.0 or higher.
 	AlterPartitionReassignments(topic string, assignment [][]int32) error
 
+	// Check the reassignment of the partitions of the topic to the given assignment, as passed
+	// to AlterPartitionReassignments, without starting it, returning the replicas each
+	// partition would add and remove. Brokers cannot validate reassignments without starting
+	// them, so the assignment is only checked against the metadata of the cluster: the
+	// partitions must exist and their new replicas must be distinct known brokers. The plans
+	// are returned along with the ErrReassignPartitions error of invalid assignments.
+	ValidatePartitionReassignments(topic string, assignment [][]int32) (map[int32]*PartitionReassignmentPlan, error)
+
 	// Provides info on ongoing partitions replica reassignments.
 	// This operation is supported by brokers with version 2.4.0.0 or higher.
 	ListPartitionReassignments(topics string, partitions []int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error)
 
+	// Throttle the replication traffic of the reassignment of the partitions of the topic to
+	// the given assignment, as passed to AlterPartitionReassignments, to rate bytes per second
+	// on each of the brokers involved. The current replicas of the moving partitions are
+	// throttled as leaders and the new ones as followers.
+	// This operation is supported by brokers with version 2.3.0.0 or higher.
+	ThrottleReassignment(topic string, assignment [][]int32, rate int64) error
+
+	// Remove the replication throttles of the topic and of all the brokers set by
+	// ThrottleReassignment, once the reassignment is complete.
+	// This operation is supported by brokers with version 2.3.0.0 or higher.
+	ClearReassignmentThrottle(topic string) error
+
+	// Poll ListPartitionReassignments every interval until none of the given partitions of the
+	// topic is being reassigned, calling progress, if not nil, with the partitions still being
+	// reassigned after each poll. It returns ErrReassignmentInProgress if the partitions are
+	// still being reassigned after timeout.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	WaitForPartitionReassignments(topic string, partitions []int32, interval, timeout time.Duration, progress func(map[int32]*PartitionReplicaReassignmentsStatus)) error
+
+	// Elect the leaders of the given partitions, or of all the partitions if nil, returning the
+	// result of each partition, ErrElectionNotNeeded meaning the leader was already the elected
+	// one. PreferredElection is supported by brokers with version 2.2.0.0 or higher and
+	// UncleanElection by brokers with version 2.4.0.0 or higher.
+	ElectLeaders(electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error)
+
+	// Describe the features finalized for the cluster, such as metadata.version on KRaft
+	// clusters, and the features supported by one of its brokers.
+	// This operation is supported by brokers with version 2.7.0.0 or higher.
+	DescribeFeatures() (*FeatureMetadata, error)
+
+	// Update the finalized version levels of features of the cluster, returning the errors of
+	// the features that could not be updated. Downgrades must be allowed by the UpgradeType of
+	// the updates. This operation is supported by brokers with version 2.7.0.0 or higher,
+	// validateOnly and unsafe downgrades by brokers with version 3.3.0.0 or higher.
+	UpdateFeatures(updates []FeatureUpdate, validateOnly bool) error
+
+	// Resolve the offsets of many partitions at once, the values of topicPartitions being
+	// OffsetOldest, OffsetNewest or a timestamp in milliseconds, for which the offset is the
+	// one of the first record at or after it, or -1 if there is none. The partitions are
+	// grouped in a request per leader, and the error of each partition is in its result.
+	// When Consumer.IsolationLevel is ReadCommitted, OffsetNewest resolves the last stable
+	// offset of the partitions with brokers with version 0.11.0.0 or higher.
+	ListOffsets(topicPartitions map[string]map[int32]int64) (map[string]map[int32]*ListOffsetsResult, error)
+
 	// Delete records whose offset is smaller than the given offset of the corresponding partition.
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DeleteRecords(topic string, partitionOffsets map[int32]int64) error
 
+	// Delete the records of many partitions at once, whose offset is smaller than the given
+	// offset of the partition, by topic and partition. The partitions are grouped in a request
+	// per leader, and the low watermark and the error of each partition are in its result. The
+	// partitions of the leaders that failed to answer are missing from the results, the errors
+	// of the leaders being returned along with the results of the others.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	DeleteRecordsOfTopics(topicPartitionOffsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error)
+
 	// Get the configuration for the specified resources.
 	// The returned configuration includes default values and the Default is true
 	// can be used to distinguish them from user supplied values.
@@ -68,14 +158,28 @@ type ClusterAdmin interface {
 	// The resources with their configs (topic is the only resource type with configs
 	// that can be updated currently Updates are not transactional so they may succeed
 	// for some resources while fail for others. The configs for a particular resource are updated automatically.
+	// If validateOnly is true, the broker only validates the new configs, which are not applied.
 	AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error
 
 	// IncrementalAlterConfig Incrementally Update the configuration for the specified resources with the default options.
 	// This operation is supported by brokers with version 2.3.0.0 or higher.
 	// Updates are not transactional so they may succeed for some resources while fail for others.
 	// The configs for a particular resource are updated automatically.
+	// If validateOnly is true, the broker only validates the new configs, which are not applied.
 	IncrementalAlterConfig(resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error
 
+	// Get the log4j levels of the loggers of the broker, by logger name, such as
+	// "kafka.controller" or "root" (KIP-412).
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	DescribeBrokerLoggers(brokerID int32) (map[string]string, error)
+
+	// Set the log4j levels of loggers of the broker at runtime, for example to debug an
+	// incident, by logger name. The levels are TRACE, DEBUG, INFO, WARN, ERROR, FATAL or OFF,
+	// and an empty level resets the logger to the level of the root logger. The levels are
+	// not persisted and are lost when the broker restarts.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	AlterBrokerLoggers(brokerID int32, levels map[string]string, validateOnly bool) error
+
 	// Creates an access control list (ACL) which is bound to a specific resource.
 	// This operation is not transactional so it may succeed or fail.
 	// If you attempt to add an ACL that duplicates an existing ACL, no error will be raised, but
@@ -99,41 +203,198 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DeleteACL(filter AclFilter, validateOnly bool) ([]MatchingAcl, error)
 
+	// Makes the ACLs of the resources listed in desired exactly the desired ones, creating the
+	// missing ACLs and deleting the others, and returns these changes. The ACLs of resources
+	// not listed are left untouched, and resources without pattern type are literal ones.
+	// With dryRun, the changes are only computed. The changes of each resource are applied
+	// together, the ACLs being created before the others are deleted so that no access is
+	// interrupted, and no ACL is deleted if creating the ones of the resource failed.
+	// This operation is not transactional across resources so it may succeed for some
+	// resources while fail for others.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	SyncACLs(desired []ResourceAcls, dryRun bool) (*ACLDiff, error)
+
+	// List the existing resources an ACL bound to the resource would apply to, sorted, to
+	// check the scope of a prefixed or wildcard ("*") resource before creating ACLs for it.
+	// Resources without pattern type are literal ones. The resources of groups whose
+	// coordinator failed to answer are missing, the error being returned along with the others.
+	// Transactional IDs are listed by brokers with version 3.0.0.0 or higher, and delegation
+	// tokens by brokers with version 1.1.0.0 or higher.
+	PreviewACLResources(resource Resource) ([]string, error)
+
 	// List the consumer groups available in the cluster.
 	ListConsumerGroups() (map[string]string, error)
 
+	// List the consumer groups matching the filters of the options with their state and type,
+	// asking all the brokers concurrently as each is the coordinator of some groups. The groups
+	// of the brokers that answered are returned along with the errors of the others, which
+	// are also reported by broker in the report.
+	// This operation is supported by brokers with version 0.9.0.0 or higher, the states of the
+	// groups by brokers with version 2.6.0.0 or higher and their types by brokers with version
+	// 3.8.0.0 or higher.
+	ListConsumerGroupsWithOptions(options ListConsumerGroupsOptions) (*ConsumerGroupListingReport, error)
+
 	// Describe the given consumer groups.
 	DescribeConsumerGroups(groups []string) ([]*GroupDescription, error)
 
 	// List the consumer group offsets available in the cluster.
 	ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error)
 
+	// Report the lag of a consumer group on the partitions it committed offsets for or is
+	// assigned, with the member each partition is assigned to and the totals of each topic and
+	// of the group. The end offsets are the last stable offsets of the partitions when
+	// Consumer.IsolationLevel is ReadCommitted. Committed offsets are only listed for all the
+	// partitions of the group by brokers with version 0.10.2.0 or higher, so partitions the
+	// group committed offsets for but is no longer assigned are missing with older brokers.
+	GroupLag(group string) (*GroupLag, error)
+
 	// Deletes a consumer group offset
 	DeleteConsumerGroupOffset(group string, topic string, partition int32) error
 
+	// Deletes the committed offsets of the group for the given partitions, a nil list of
+	// partitions standing for all the partitions of the topic the group committed offsets for,
+	// for example once the topic is retired. The group must not be subscribed to the topics.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	DeleteConsumerGroupOffsets(group string, topicPartitions map[string][]int32) error
+
 	// Delete a consumer group.
 	DeleteConsumerGroup(group string) error
 
 	// Get information about the nodes in the cluster
 	DescribeCluster() (brokers []*Broker, controllerID int32, err error)
 
+	// Describe the cluster: its ID, controller and brokers with their racks and, if
+	// includeAuthorizedOperations is true, the operations on the cluster the client is
+	// authorized to perform. Brokers with version 2.8.0.0 or higher are asked to describe
+	// the cluster, older ones are asked for its metadata and cannot return the authorized
+	// operations. With version 3.9.0.0 or higher, the brokers still registered but fenced,
+	// such as brokers shut down, are returned too.
+	DescribeClusterInfo(includeAuthorizedOperations bool) (*ClusterDescription, error)
+
+	// Remove the registration of a broker from the metadata of a KRaft cluster, for example
+	// once it was decommissioned, so that it is not counted as a fenced broker anymore. The
+	// broker must be shut down first.
+	// This operation is supported by KRaft clusters with version 3.0.0.0 or higher.
+	UnregisterBroker(brokerID int32) error
+
+	// Describe the Raft quorum of the controllers of a KRaft cluster, which replicates its
+	// metadata log: the leader with its epoch and high watermark, and the voters and observers
+	// with how far their log is behind the one of the leader, for example to monitor the health
+	// of the controllers.
+	// This operation is supported by KRaft clusters with version 3.3.0.0 or higher.
+	DescribeQuorum() (*QuorumInfo, error)
+
+	// Check the health of the cluster for readiness probes and monitors: whether the
+	// controller is reachable, the brokers partitions are assigned to but missing from the
+	// metadata, and the partitions that are under-replicated, offline or have fewer in-sync
+	// replicas than the min.insync.replicas of their topic. If the min.insync.replicas of the
+	// topics cannot be described, the report is returned along with the error.
+	// Checking the min.insync.replicas is supported by brokers with version 0.11.0.0 or higher.
+	HealthReport() (*ClusterHealthReport, error)
+
+	// Take a snapshot of the brokers, of the partitions of all the topics with their leader,
+	// replicas and ISR, and of the configs set on the topics, to be compared with another one
+	// by ClusterSnapshot.Diff, for example to validate a maintenance or audit changes.
+	// The configs of the topics are only included with brokers with version 0.11.0.0 or higher.
+	ClusterSnapshot() (*ClusterSnapshot, error)
+
 	// Get information about all log directories on the given set of brokers
 	DescribeLogDirs(brokers []int32) (map[int32][]DescribeLogDirsResponseDirMetadata, error)
 
-	// Get information about SCRAM users
+	// Get the disk usage of the log dirs of the given brokers, or of all the brokers if none is
+	// given, aggregated by broker, log dir, topic and partition. If some brokers fail to answer,
+	// the report of the others is returned along with the error.
+	// This operation is supported by brokers with version 1.0.0 or higher.
+	DescribeLogDirUsage(brokers []int32) (*LogDirUsageReport, error)
+
+	// Move replicas hosted by the broker to other log dirs of the broker, for example to
+	// balance the usage of its disks, the values of moves being the paths of the destination
+	// log dirs by topic and partition. The replicas are copied to their destination in the
+	// background, whose completion VerifyReplicaLogDirs checks. The error of each partition,
+	// such as ErrLogDirNotFound for an unknown path, is in the results.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	AlterReplicaLogDirs(brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]KError, error)
+
+	// Return the moves of AlterReplicaLogDirs that are not complete yet, that is the replicas
+	// of the broker not in their destination log dir yet, by topic and partition.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	VerifyReplicaLogDirs(brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]string, error)
+
+	// Get information about SCRAM users, the mechanisms and iterations of their credentials,
+	// or about all the users with credentials if users is empty. The error of each user, such
+	// as ErrResourceNotFound for users without credentials, is in its result (KIP-554).
+	// This operation is supported by brokers with version 2.7.0.0 or higher.
 	DescribeUserScramCredentials(users []string) ([]*DescribeUserScramCredentialsResult, error)
 
-	// Delete SCRAM users
+	// Delete SCRAM users, the error of each user being in its result.
+	// This operation is supported by brokers with version 2.7.0.0 or higher.
 	DeleteUserScramCredentials(delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error)
 
-	// Upsert SCRAM users
+	// Upsert SCRAM users, the error of each user being in its result. The passwords are salted
+	// and hashed before being sent, a random salt being generated for the upserts without one
+	// and 4096 iterations, the minimum, being used for those without iterations.
+	// This operation is supported by brokers with version 2.7.0.0 or higher.
 	UpsertUserScramCredentials(upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error)
 
+	// Create a delegation token owned by the principal of the client, which the given renewers
+	// may renew too. A negative maxLifetime uses the delegation.token.max.lifetime.ms of the
+	// brokers. This operation is supported by brokers with version 1.1.0.0 or higher.
+	CreateDelegationToken(renewers []DelegationTokenPrincipal, maxLifetime time.Duration) (*DelegationToken, error)
+
+	// Renew the delegation token with the given HMAC so that it expires renewPeriod from now,
+	// returning its new expiry time. A negative renewPeriod uses the
+	// delegation.token.expiry.time.ms of the brokers.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	RenewDelegationToken(hmac []byte, renewPeriod time.Duration) (time.Time, error)
+
+	// Change the expiry time of the delegation token with the given HMAC to expiryPeriod from
+	// now, returning its new expiry time. A negative expiryPeriod expires the token immediately.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	ExpireDelegationToken(hmac []byte, expiryPeriod time.Duration) (time.Time, error)
+
+	// Describe the delegation tokens of the given owners, or all the ones the principal of the
+	// client may describe if owners is nil.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	DescribeDelegationToken(owners []DelegationTokenPrincipal) ([]DelegationToken, error)
+
+	// Describe the active producers of the given partitions of the topic, asking the leader of
+	// each partition.
+	// This operation is supported by brokers with version 2.8.0.0 or higher.
+	DescribeProducers(topic string, partitions []int32) (map[int32][]ProducerState, error)
+
+	// Describe the given transactions, asking the coordinator of each. The error of each
+	// transaction is in its ErrorCode.
+	// This operation is supported by brokers with version 3.0.0.0 or higher.
+	DescribeTransactions(transactionalIDs []string) ([]*TransactionDescription, error)
+
+	// List the transactions of all the brokers in the given states and of the given producers,
+	// or all of them if states and producerIDs are empty.
+	// This operation is supported by brokers with version 3.0.0.0 or higher.
+	ListTransactions(states []string, producerIDs []int64) ([]TransactionListing, error)
+
+	// List the transactions matching all the filters of the options, asking every broker as
+	// each is the coordinator of some transactions, sorted by transactional ID. The listings of
+	// the brokers that answered are returned along with the errors of the others, and states
+	// unknown to the brokers are returned as a ConfigurationError.
+	// This operation is supported by brokers with version 3.0.0.0 or higher, the
+	// MinDuration filter by brokers with version 3.8.0.0 or higher.
+	ListTransactionsWithOptions(options ListTransactionsOptions) ([]TransactionListing, error)
+
+	// Abort the open transaction of a producer on the partition, as described by
+	// DescribeProducers, for example a hanging transaction holding back the last stable offset
+	// of the partition and so consumers reading committed records. It requires the
+	// ClusterAction permission.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	AbortTransaction(topic string, partition int32, producer ProducerState) error
+
 	// Get client quota configurations corresponding to the specified filter.
 	// This operation is supported by brokers with version 2.6.0.0 or higher.
 	DescribeClientQuotas(components []QuotaFilterComponent, strict bool) ([]DescribeClientQuotasEntry, error)
 
 	// Alters client quota configurations with the specified alterations.
+	// The entity is a user, a client ID or both, a component of type QuotaMatchDefault
+	// standing for the default entity of its type, and the keys of op are Quota* constants
+	// such as QuotaProducerByteRate.
 	// This operation is supported by brokers with version 2.6.0.0 or higher.
 	AlterClientQuotas(entity []QuotaEntityComponent, op ClientQuotasOp, validateOnly bool) error
 
@@ -146,13 +407,25 @@ type ClusterAdmin interface {
 	// This is for static membership feature. KIP-345
 	RemoveMemberFromConsumerGroup(groupId string, groupInstanceIds []string) (*LeaveGroupResponse, error)
 
+	// Remove the static members of the consumer group with the given group.instance.id at once,
+	// rather than waiting for their session to time out, for example once their instances
+	// crashed. The errors of the members that could not be removed, such as
+	// ErrUnknownMemberId, are returned together.
+	// This operation is supported by brokers with version 2.3 or higher (KIP-345).
+	RemoveMembersFromConsumerGroup(group string, groupInstanceIDs []string) error
+
 	// Close shuts down the admin and closes underlying client.
 	Close() error
+
+	ClusterAdminContext
 }
 
 type clusterAdmin struct {
 	client Client
 	conf   *Config
+	// ctx is the context of the ClusterAdminContext operation the admin runs, if any, which
+	// bounds the operation timeouts sent to the brokers and stops the retries once done.
+	ctx context.Context
 }
 
 // NewClusterAdmin creates a new ClusterAdmin using the given broker addresses and configuration.
@@ -197,15 +470,17 @@ func (ca *clusterAdmin) refreshController() (*Broker, error) {
 }
 
 // isErrNoController returns `true` if the given error type unwraps to an
-// `ErrNotController` response from Kafka
+// `ErrNotController` response from Kafka, or to ErrControllerNotAvailable
+// if no controller is known
 func isErrNoController(err error) bool {
-	return errors.Is(err, ErrNotController)
+	return errors.Is(err, ErrNotController) || errors.Is(err, ErrControllerNotAvailable)
 }
 
 // retryOnError will repeatedly call the given (error-returning) func in the
 // case that its response is non-nil and retryable (as determined by the
 // provided retryable func) up to the maximum number of tries permitted by
-// the admin client configuration
+// the admin client configuration, refreshing the controller before retrying
+// if it moved
 func (ca *clusterAdmin) retryOnError(retryable func(error) bool, fn func() error) error {
 	var err error
 	for attempt := 0; attempt < ca.conf.Admin.Retry.Max; attempt++ {
@@ -213,15 +488,97 @@ func (ca *clusterAdmin) retryOnError(retryable func(error) bool, fn func() error
 		if err == nil || !retryable(err) {
 			return err
 		}
+		remaining := ca.conf.Admin.Retry.Max - attempt - 1
+		if remaining == 0 {
+			break
+		}
+		if isErrNoController(err) {
+			_, _ = ca.refreshController()
+		}
+		backoff := ca.computeBackoff(attempt)
 		Logger.Printf(
 			"admin/request retrying after %dms... (%d attempts remaining)\n",
-			ca.conf.Admin.Retry.Backoff/time.Millisecond, ca.conf.Admin.Retry.Max-attempt)
-		time.Sleep(ca.conf.Admin.Retry.Backoff)
-		continue
+			backoff/time.Millisecond, remaining)
+		if !ca.sleep(backoff) {
+			break
+		}
 	}
 	return err
 }
 
+// sleep waits for d, returning false without waiting for the rest of it if the context of the
+// operation is done.
+func (ca *clusterAdmin) sleep(d time.Duration) bool {
+	if ca.ctx == nil {
+		time.Sleep(d)
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ca.ctx.Done():
+		return false
+	}
+}
+
+// timeout returns the Admin.Timeout to send to the brokers, capped by the time left until the
+// deadline of the context of the operation.
+func (ca *clusterAdmin) timeout() time.Duration {
+	timeout := ca.conf.Admin.Timeout
+	if ca.ctx == nil {
+		return timeout
+	}
+	if deadline, ok := ca.ctx.Deadline(); ok {
+		if left := time.Until(deadline); left < timeout {
+			timeout = left
+		}
+		if timeout < 0 {
+			timeout = 0
+		}
+	}
+	return timeout
+}
+
+// computeBackoff returns the backoff before the given retry, doubling from
+// Admin.Retry.Backoff up to Admin.Retry.MaxBackoff unless BackoffFunc is set
+func (ca *clusterAdmin) computeBackoff(retries int) time.Duration {
+	if ca.conf.Admin.Retry.BackoffFunc != nil {
+		return ca.conf.Admin.Retry.BackoffFunc(retries, ca.conf.Admin.Retry.Max)
+	}
+	backoff, maxBackoff := ca.conf.Admin.Retry.Backoff, ca.conf.Admin.Retry.MaxBackoff
+	for i := 0; i < retries && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// isErrCoordinatorUnavailable returns true if the given error unwraps to an
+// error of a group coordinator that moved, is not available or is still
+// loading its groups, all of which are transient
+func isErrCoordinatorUnavailable(err error) bool {
+	return errors.Is(err, ErrNotCoordinatorForConsumer) ||
+		errors.Is(err, ErrConsumerCoordinatorNotAvailable) ||
+		errors.Is(err, ErrOffsetsLoadInProgress)
+}
+
+// retryOnCoordinatorError calls retryOnError with the errors of unavailable
+// coordinators, refreshing the coordinator of the group before retrying if it
+// moved
+func (ca *clusterAdmin) retryOnCoordinatorError(group string, fn func() error) error {
+	return ca.retryOnError(isErrCoordinatorUnavailable, func() error {
+		err := fn()
+		if err != nil && isErrCoordinatorUnavailable(err) && !errors.Is(err, ErrOffsetsLoadInProgress) {
+			_ = ca.client.RefreshCoordinator(group)
+		}
+		return err
+	})
+}
+
 func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error {
 	if topic == "" {
 		return ErrInvalidTopic
@@ -237,7 +594,7 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 	request := &CreateTopicsRequest{
 		TopicDetails: topicDetails,
 		ValidateOnly: validateOnly,
-		Timeout:      ca.conf.Admin.Timeout,
+		Timeout:      ca.timeout(),
 	}
 
 	if ca.conf.Version.IsAtLeast(V0_11_0_0) {
@@ -264,9 +621,6 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 		}
 
 		if !errors.Is(topicErr.Err, ErrNoError) {
-			if errors.Is(topicErr.Err, ErrNotController) {
-				_, _ = ca.refreshController()
-			}
 			return topicErr
 		}
 
@@ -274,6 +628,141 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 	})
 }
 
+func (ca *clusterAdmin) CloneTopic(src, dst string, overrides map[string]*string, preserveAssignment bool) error {
+	if src == "" || dst == "" {
+		return ErrInvalidTopic
+	}
+
+	topics, err := ca.DescribeTopics([]string{src})
+	if err != nil {
+		return err
+	}
+	if len(topics) != 1 {
+		return ErrUnknownTopicOrPartition
+	}
+	if !errors.Is(topics[0].Err, ErrNoError) {
+		return topics[0].Err
+	}
+	if len(topics[0].Partitions) == 0 {
+		return ErrUnknownTopicOrPartition
+	}
+
+	entries, err := ca.DescribeConfig(ConfigResource{Type: TopicResource, Name: src})
+	if err != nil {
+		return err
+	}
+
+	detail := &TopicDetail{
+		NumPartitions:     int32(len(topics[0].Partitions)),
+		ReplicationFactor: int16(len(topics[0].Partitions[0].Replicas)),
+		ConfigEntries:     make(map[string]*string),
+	}
+	for _, entry := range entries {
+		// brokers older than 1.1.0.0 only tell whether configs are default ones
+		if entry.Default || entry.Sensitive || (entry.Source != SourceTopic && entry.Source != SourceUnknown) {
+			continue
+		}
+		value := entry.Value
+		detail.ConfigEntries[entry.Name] = &value
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(detail.ConfigEntries, name)
+		} else {
+			detail.ConfigEntries[name] = value
+		}
+	}
+
+	if preserveAssignment {
+		// the partition count and replication factor must not be set with an assignment
+		detail.NumPartitions, detail.ReplicationFactor = -1, -1
+		detail.ReplicaAssignment = make(map[int32][]int32, len(topics[0].Partitions))
+		for _, partition := range topics[0].Partitions {
+			detail.ReplicaAssignment[partition.ID] = partition.Replicas
+		}
+	}
+
+	return ca.CreateTopic(dst, detail, false)
+}
+
+func (ca *clusterAdmin) ApplyTopicSpec(spec TopicSpec, dryRun bool) (*TopicSpecDiff, error) {
+	if spec.Name == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	topics, err := ca.DescribeTopics([]string{spec.Name})
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) == 0 || errors.Is(topics[0].Err, ErrUnknownTopicOrPartition) {
+		diff := &TopicSpecDiff{Topic: spec.Name, Create: true}
+		if dryRun {
+			return diff, nil
+		}
+		return diff, ca.CreateTopic(spec.Name, spec.topicDetail(), false)
+	}
+	if !errors.Is(topics[0].Err, ErrNoError) {
+		return nil, topics[0].Err
+	}
+
+	entries, err := ca.DescribeConfig(ConfigResource{Type: TopicResource, Name: spec.Name})
+	if err != nil {
+		return nil, err
+	}
+	brokers, _, err := ca.DescribeCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffTopicSpec(&spec, topics[0], entries, brokers)
+	if len(diff.Conflicts) > 0 {
+		return diff, Wrap(ErrTopicSpecConflict, diff.Conflicts...)
+	}
+	if dryRun {
+		return diff, nil
+	}
+	return diff, ca.applyTopicSpecDiff(&spec, diff)
+}
+
+func (ca *clusterAdmin) applyTopicSpecDiff(spec *TopicSpec, diff *TopicSpecDiff) error {
+	if diff.ReplicationFactor > 0 && !ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		return ConfigurationError("increasing the replication factor requires Version >= V2_4_0_0")
+	}
+
+	var errs []error
+	if len(diff.SetConfigs) > 0 || len(diff.DeleteConfigs) > 0 {
+		var err error
+		if ca.conf.Version.IsAtLeast(V2_3_0_0) {
+			entries := make(map[string]IncrementalAlterConfigsEntry, len(diff.SetConfigs)+len(diff.DeleteConfigs))
+			for name, value := range diff.SetConfigs {
+				value := value
+				entries[name] = IncrementalAlterConfigsEntry{Operation: IncrementalAlterConfigsOperationSet, Value: &value}
+			}
+			for _, name := range diff.DeleteConfigs {
+				entries[name] = IncrementalAlterConfigsEntry{Operation: IncrementalAlterConfigsOperationDelete}
+			}
+			err = ca.IncrementalAlterConfig(TopicResource, spec.Name, entries, false)
+		} else {
+			// the configs not altered are reset to their defaults
+			err = ca.AlterConfig(TopicResource, spec.Name, spec.topicDetail().ConfigEntries, false)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if diff.NumPartitions > 0 {
+		if err := ca.CreatePartitions(spec.Name, diff.NumPartitions, diff.NewPartitionsAssignment, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if diff.ReplicationFactor > 0 {
+		if err := ca.AlterPartitionReassignments(spec.Name, diff.Reassignment); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return multiError(errs...)
+}
+
 func (ca *clusterAdmin) DescribeTopics(topics []string) (metadata []*TopicMetadata, err error) {
 	controller, err := ca.Controller()
 	if err != nil {
@@ -303,121 +792,460 @@ func (ca *clusterAdmin) DescribeCluster() (brokers []*Broker, controllerID int32
 	return response.Brokers, response.ControllerID, nil
 }
 
-func (ca *clusterAdmin) findBroker(id int32) (*Broker, error) {
-	brokers := ca.client.Brokers()
-	for _, b := range brokers {
-		if b.ID() == id {
-			return b, nil
+// ClusterDescription describes a cluster, as returned by ClusterAdmin.DescribeClusterInfo.
+type ClusterDescription struct {
+	// ClusterID is empty with brokers older than 0.10.1.0.
+	ClusterID    string
+	ControllerID int32
+	// Brokers are the brokers of the cluster that are not fenced.
+	Brokers []*Broker
+	// FencedBrokers are the brokers registered in the metadata of a KRaft cluster that are
+	// fenced, such as brokers shut down, which are not assigned leaderships anymore until they
+	// are back or unregistered by UnregisterBroker. They are only known with Version >=
+	// V3_9_0_0.
+	FencedBrokers []*Broker
+	// AuthorizedOperations are the operations on the cluster the client is authorized to
+	// perform, nil if they were not requested.
+	AuthorizedOperations []AclOperation
+}
+
+// IsAuthorized returns true if the client is authorized to perform the operation on the
+// cluster, All standing for every operation.
+func (d *ClusterDescription) IsAuthorized(op AclOperation) bool {
+	for _, authorized := range d.AuthorizedOperations {
+		if authorized == op || authorized == AclOperationAll {
+			return true
 		}
 	}
-	return nil, fmt.Errorf("could not find broker id %d", id)
+	return false
 }
 
-func (ca *clusterAdmin) findAnyBroker() (*Broker, error) {
-	brokers := ca.client.Brokers()
-	if len(brokers) > 0 {
-		index := rand.Intn(len(brokers))
-		return brokers[index], nil
+// authorizedOperationsOmitted is the bitmask of authorized operations returned by brokers
+// when they were not requested.
+const authorizedOperationsOmitted = math.MinInt32
+
+// authorizedOperations returns the operations of the bitmask of authorized operations
+// returned by brokers, where bit i stands for AclOperation(i).
+func authorizedOperations(bitmask int32) []AclOperation {
+	if bitmask == authorizedOperationsOmitted {
+		return nil
 	}
-	return nil, errors.New("no available broker")
+	operations := []AclOperation{}
+	for op := AclOperationUnknown; op < 32; op++ {
+		if bitmask&(1<<uint(op)) != 0 {
+			operations = append(operations, op)
+		}
+	}
+	return operations
 }
 
-func (ca *clusterAdmin) ListTopics() (map[string]TopicDetail, error) {
-	// In order to build TopicDetails we need to first get the list of all
-	// topics using a MetadataRequest and then get their configs using a
-	// DescribeConfigsRequest request. To avoid sending many requests to the
-	// broker, we use a single DescribeConfigsRequest.
+func (ca *clusterAdmin) DescribeClusterInfo(includeAuthorizedOperations bool) (*ClusterDescription, error) {
+	if !ca.conf.Version.IsAtLeast(V2_8_0_0) {
+		if includeAuthorizedOperations {
+			return nil, ConfigurationError("describing the authorized operations of the cluster requires Version >= V2_8_0_0")
+		}
+		controller, err := ca.Controller()
+		if err != nil {
+			return nil, err
+		}
+		response, err := controller.GetMetadata(NewMetadataRequest(ca.conf.Version, nil))
+		if err != nil {
+			return nil, err
+		}
+		description := &ClusterDescription{ControllerID: response.ControllerID, Brokers: response.Brokers}
+		if response.ClusterID != nil {
+			description.ClusterID = *response.ClusterID
+		}
+		return description, nil
+	}
 
-	// Send the all-topic MetadataRequest
 	b, err := ca.findAnyBroker()
 	if err != nil {
 		return nil, err
 	}
 	_ = b.Open(ca.client.Config())
 
-	metadataReq := NewMetadataRequest(ca.conf.Version, nil)
-	metadataResp, err := b.GetMetadata(metadataReq)
+	request := &DescribeClusterRequest{
+		IncludeClusterAuthorizedOperations: includeAuthorizedOperations,
+	}
+	if ca.conf.Version.IsAtLeast(V3_9_0_0) {
+		request.Version = 2
+		request.EndpointType = DescribeClusterEndpointBrokers
+		request.IncludeFencedBrokers = true
+	}
+	response, err := b.DescribeCluster(request)
 	if err != nil {
 		return nil, err
 	}
+	if !errors.Is(response.ErrorCode, ErrNoError) {
+		return nil, response.ErrorCode
+	}
 
-	topicsDetailsMap := make(map[string]TopicDetail)
-
-	var describeConfigsResources []*ConfigResource
-
-	for _, topic := range metadataResp.Topics {
-		topicDetails := TopicDetail{
-			NumPartitions: int32(len(topic.Partitions)),
-		}
-		if len(topic.Partitions) > 0 {
-			topicDetails.ReplicaAssignment = map[int32][]int32{}
-			for _, partition := range topic.Partitions {
-				topicDetails.ReplicaAssignment[partition.ID] = partition.Replicas
-			}
-			topicDetails.ReplicationFactor = int16(len(topic.Partitions[0].Replicas))
+	description := &ClusterDescription{
+		ClusterID:    response.ClusterID,
+		ControllerID: response.ControllerID,
+	}
+	for _, broker := range response.Brokers {
+		if response.Fenced[broker.ID()] {
+			description.FencedBrokers = append(description.FencedBrokers, broker)
+		} else {
+			description.Brokers = append(description.Brokers, broker)
 		}
-		topicsDetailsMap[topic.Name] = topicDetails
+	}
+	if includeAuthorizedOperations {
+		description.AuthorizedOperations = authorizedOperations(response.ClusterAuthorizedOperations)
+	}
+	return description, nil
+}
 
-		// we populate the resources we want to describe from the MetadataResponse
-		topicResource := ConfigResource{
-			Type: TopicResource,
-			Name: topic.Name,
-		}
-		describeConfigsResources = append(describeConfigsResources, &topicResource)
+func (ca *clusterAdmin) UnregisterBroker(brokerID int32) error {
+	if !ca.conf.Version.IsAtLeast(V3_0_0_0) {
+		return ConfigurationError("unregistering brokers requires Version >= V3_0_0_0")
 	}
 
-	// Send the DescribeConfigsRequest
-	describeConfigsReq := &DescribeConfigsRequest{
-		Resources: describeConfigsResources,
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return err
 	}
+	_ = b.Open(ca.client.Config())
 
-	if ca.conf.Version.IsAtLeast(V1_1_0_0) {
-		describeConfigsReq.Version = 1
+	response, err := b.UnregisterBroker(&UnregisterBrokerRequest{BrokerID: brokerID})
+	if err != nil {
+		return err
 	}
+	if !errors.Is(response.ErrorCode, ErrNoError) {
+		return response.ErrorCode
+	}
+	return nil
+}
 
-	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
-		describeConfigsReq.Version = 2
+// QuorumInfo describes the Raft quorum of the controllers of a KRaft cluster, as returned by
+// ClusterAdmin.DescribeQuorum.
+type QuorumInfo struct {
+	LeaderID      int32
+	LeaderEpoch   int32
+	HighWatermark int64
+	// Voters are the controllers voting in the elections of the leader, the leader included.
+	Voters []QuorumReplica
+	// Observers are the brokers and controllers replicating the metadata log without voting.
+	Observers []QuorumReplica
+}
+
+// QuorumReplica is the state of the metadata log of a replica of a Raft quorum, as seen by the
+// leader.
+type QuorumReplica struct {
+	ReplicaID    int32
+	LogEndOffset int64
+	// Lag is how many offsets the log of the replica is behind the log end offset of the leader.
+	Lag int64
+	// LastFetchTimestamp is the time in milliseconds of the last fetch of the replica from the
+	// leader, or -1 if unknown.
+	LastFetchTimestamp int64
+	// LastCaughtUpTimestamp is the time in milliseconds the replica last caught up with the
+	// leader, or -1 if unknown.
+	LastCaughtUpTimestamp int64
+}
+
+func (ca *clusterAdmin) DescribeQuorum() (*QuorumInfo, error) {
+	if !ca.conf.Version.IsAtLeast(V3_3_0_0) {
+		return nil, ConfigurationError("describing the quorum requires Version >= V3_3_0_0")
 	}
 
-	describeConfigsResp, err := b.DescribeConfigs(describeConfigsReq)
+	b, err := ca.findAnyBroker()
 	if err != nil {
 		return nil, err
 	}
+	_ = b.Open(ca.client.Config())
 
-	for _, resource := range describeConfigsResp.Resources {
-		topicDetails := topicsDetailsMap[resource.Name]
-		topicDetails.ConfigEntries = make(map[string]*string)
+	response, err := b.DescribeQuorum(&DescribeQuorumRequest{
+		Version: 1,
+		Topics:  map[string][]int32{clusterMetadataTopic: {0}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !errors.Is(response.ErrorCode, ErrNoError) {
+		return nil, response.ErrorCode
+	}
+	partition := response.Topics[clusterMetadataTopic][0]
+	if partition == nil {
+		return nil, ErrIncompleteResponse
+	}
+	if !errors.Is(partition.ErrorCode, ErrNoError) {
+		return nil, partition.ErrorCode
+	}
 
-		for _, entry := range resource.Configs {
-			// only include non-default non-sensitive config
-			// (don't actually think topic config will ever be sensitive)
-			if entry.Default || entry.Sensitive {
-				continue
+	// the lag is relative to the log end offset of the leader, or its high watermark if the
+	// leader is missing from the voters
+	leaderEndOffset := partition.HighWatermark
+	for _, voter := range partition.CurrentVoters {
+		if voter.ReplicaID == partition.LeaderID {
+			leaderEndOffset = voter.LogEndOffset
+		}
+	}
+	replicas := func(states []ReplicaState) []QuorumReplica {
+		out := make([]QuorumReplica, len(states))
+		for i, state := range states {
+			out[i] = QuorumReplica{
+				ReplicaID:             state.ReplicaID,
+				LogEndOffset:          state.LogEndOffset,
+				LastFetchTimestamp:    state.LastFetchTimestamp,
+				LastCaughtUpTimestamp: state.LastCaughtUpTimestamp,
+			}
+			if lag := leaderEndOffset - state.LogEndOffset; lag > 0 {
+				out[i].Lag = lag
 			}
-			topicDetails.ConfigEntries[entry.Name] = &entry.Value
 		}
+		return out
+	}
+	return &QuorumInfo{
+		LeaderID:      partition.LeaderID,
+		LeaderEpoch:   partition.LeaderEpoch,
+		HighWatermark: partition.HighWatermark,
+		Voters:        replicas(partition.CurrentVoters),
+		Observers:     replicas(partition.Observers),
+	}, nil
+}
 
-		topicsDetailsMap[resource.Name] = topicDetails
+func (ca *clusterAdmin) HealthReport() (*ClusterHealthReport, error) {
+	request := NewMetadataRequest(ca.conf.Version, nil)
+
+	// the metadata of the controller is the most up to date
+	var metadata *MetadataResponse
+	controller, controllerErr := ca.Controller()
+	if controllerErr == nil {
+		_ = controller.Open(ca.client.Config())
+		metadata, controllerErr = controller.GetMetadata(request)
+	}
+	if controllerErr != nil {
+		b, err := ca.findAnyBroker()
+		if err != nil {
+			return nil, err
+		}
+		_ = b.Open(ca.client.Config())
+		if metadata, err = b.GetMetadata(request); err != nil {
+			return nil, err
+		}
 	}
 
-	return topicsDetailsMap, nil
+	minIsr, err := ca.describeMinIsr(metadata.Topics)
+	report := newClusterHealthReport(metadata, minIsr)
+	report.ControllerReachable = controllerErr == nil
+	report.ControllerErr = controllerErr
+	return report, err
 }
 
-func (ca *clusterAdmin) DeleteTopic(topic string) error {
-	if topic == "" {
-		return ErrInvalidTopic
+func (ca *clusterAdmin) ClusterSnapshot() (*ClusterSnapshot, error) {
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
 	}
+	_ = b.Open(ca.client.Config())
 
-	request := &DeleteTopicsRequest{
-		Topics:  []string{topic},
-		Timeout: ca.conf.Admin.Timeout,
+	metadata, err := b.GetMetadata(NewMetadataRequest(ca.conf.Version, nil))
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.Topics) == 0 || !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return newClusterSnapshot(metadata, nil), nil
 	}
 
-	if ca.conf.Version.IsAtLeast(V0_11_0_0) {
+	request := &DescribeConfigsRequest{}
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 2
+	} else if ca.conf.Version.IsAtLeast(V1_1_0_0) {
 		request.Version = 1
 	}
+	for _, topic := range metadata.Topics {
+		request.Resources = append(request.Resources, &ConfigResource{Type: TopicResource, Name: topic.Name})
+	}
+	response, err := b.DescribeConfigs(request)
+	if err != nil {
+		return nil, err
+	}
 
-	return ca.retryOnError(isErrNoController, func() error {
+	configs := make(map[string]map[string]string, len(response.Resources))
+	for _, resource := range response.Resources {
+		if resource.ErrorCode != 0 {
+			return nil, KError(resource.ErrorCode)
+		}
+		entries := make(map[string]string)
+		for _, entry := range resource.Configs {
+			// brokers older than 1.1.0.0 only tell whether configs are default ones
+			if entry.Default || entry.Sensitive || (entry.Source != SourceTopic && entry.Source != SourceUnknown) {
+				continue
+			}
+			entries[entry.Name] = entry.Value
+		}
+		configs[resource.Name] = entries
+	}
+	return newClusterSnapshot(metadata, configs), nil
+}
+
+// describeMinIsr returns the min.insync.replicas of the topics, by topic.
+func (ca *clusterAdmin) describeMinIsr(topics []*TopicMetadata) (map[string]int, error) {
+	minIsr := make(map[string]int, len(topics))
+	if len(topics) == 0 || !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return minIsr, nil
+	}
+
+	request := &DescribeConfigsRequest{}
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 2
+	} else if ca.conf.Version.IsAtLeast(V1_1_0_0) {
+		request.Version = 1
+	}
+	for _, topic := range topics {
+		request.Resources = append(request.Resources, &ConfigResource{
+			Type:        TopicResource,
+			Name:        topic.Name,
+			ConfigNames: []string{"min.insync.replicas"},
+		})
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return minIsr, err
+	}
+	_ = b.Open(ca.client.Config())
+	response, err := b.DescribeConfigs(request)
+	if err != nil {
+		return minIsr, err
+	}
+	for _, resource := range response.Resources {
+		for _, entry := range resource.Configs {
+			if entry.Name != "min.insync.replicas" {
+				continue
+			}
+			if n, err := strconv.Atoi(entry.Value); err == nil {
+				minIsr[resource.Name] = n
+			}
+		}
+	}
+	return minIsr, nil
+}
+
+func (ca *clusterAdmin) findBroker(id int32) (*Broker, error) {
+	brokers := ca.client.Brokers()
+	for _, b := range brokers {
+		if b.ID() == id {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find broker id %d", id)
+}
+
+func (ca *clusterAdmin) findAnyBroker() (*Broker, error) {
+	brokers := ca.client.Brokers()
+	if selector := ca.conf.BrokerSelector; selector != nil && len(brokers) > 0 {
+		sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+		if broker := selector.Select(brokers); broker != nil {
+			return broker, nil
+		}
+	}
+	if len(brokers) > 0 {
+		index := rand.Intn(len(brokers))
+		return brokers[index], nil
+	}
+	return nil, errors.New("no available broker")
+}
+
+func (ca *clusterAdmin) ListTopics() (map[string]TopicDetail, error) {
+	// In order to build TopicDetails we need to first get the list of all
+	// topics using a MetadataRequest and then get their configs using a
+	// DescribeConfigsRequest request. To avoid sending many requests to the
+	// broker, we use a single DescribeConfigsRequest.
+
+	// Send the all-topic MetadataRequest
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	metadataReq := NewMetadataRequest(ca.conf.Version, nil)
+	metadataResp, err := b.GetMetadata(metadataReq)
+	if err != nil {
+		return nil, err
+	}
+
+	topicsDetailsMap := make(map[string]TopicDetail)
+
+	var describeConfigsResources []*ConfigResource
+
+	for _, topic := range metadataResp.Topics {
+		topicDetails := TopicDetail{
+			NumPartitions: int32(len(topic.Partitions)),
+		}
+		if len(topic.Partitions) > 0 {
+			topicDetails.ReplicaAssignment = map[int32][]int32{}
+			for _, partition := range topic.Partitions {
+				topicDetails.ReplicaAssignment[partition.ID] = partition.Replicas
+			}
+			topicDetails.ReplicationFactor = int16(len(topic.Partitions[0].Replicas))
+		}
+		topicsDetailsMap[topic.Name] = topicDetails
+
+		// we populate the resources we want to describe from the MetadataResponse
+		topicResource := ConfigResource{
+			Type: TopicResource,
+			Name: topic.Name,
+		}
+		describeConfigsResources = append(describeConfigsResources, &topicResource)
+	}
+
+	// Send the DescribeConfigsRequest
+	describeConfigsReq := &DescribeConfigsRequest{
+		Resources: describeConfigsResources,
+	}
+
+	if ca.conf.Version.IsAtLeast(V1_1_0_0) {
+		describeConfigsReq.Version = 1
+	}
+
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		describeConfigsReq.Version = 2
+	}
+
+	describeConfigsResp, err := b.DescribeConfigs(describeConfigsReq)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range describeConfigsResp.Resources {
+		topicDetails := topicsDetailsMap[resource.Name]
+		topicDetails.ConfigEntries = make(map[string]*string)
+
+		for _, entry := range resource.Configs {
+			// only include non-default non-sensitive config
+			// (don't actually think topic config will ever be sensitive)
+			if entry.Default || entry.Sensitive {
+				continue
+			}
+			topicDetails.ConfigEntries[entry.Name] = &entry.Value
+		}
+
+		topicsDetailsMap[resource.Name] = topicDetails
+	}
+
+	return topicsDetailsMap, nil
+}
+
+func (ca *clusterAdmin) DeleteTopic(topic string) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+
+	request := &DeleteTopicsRequest{
+		Topics:  []string{topic},
+		Timeout: ca.timeout(),
+	}
+
+	if ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		request.Version = 1
+	}
+
+	return ca.retryOnError(isErrNoController, func() error {
 		b, err := ca.Controller()
 		if err != nil {
 			return err
@@ -434,9 +1262,6 @@ func (ca *clusterAdmin) DeleteTopic(topic string) error {
 		}
 
 		if !errors.Is(topicErr, ErrNoError) {
-			if errors.Is(topicErr, ErrNotController) {
-				_, _ = ca.refreshController()
-			}
 			return topicErr
 		}
 
@@ -454,7 +1279,7 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 
 	request := &CreatePartitionsRequest{
 		TopicPartitions: topicPartitions,
-		Timeout:         ca.conf.Admin.Timeout,
+		Timeout:         ca.timeout(),
 		ValidateOnly:    validateOnly,
 	}
 
@@ -475,9 +1300,6 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 		}
 
 		if !errors.Is(topicErr.Err, ErrNoError) {
-			if errors.Is(topicErr.Err, ErrNotController) {
-				_, _ = ca.refreshController()
-			}
 			return topicErr
 		}
 
@@ -485,6 +1307,14 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 	})
 }
 
+func (ca *clusterAdmin) GenerateReplicaAssignment(numPartitions int32, replicationFactor int16, options *ReplicaAssignmentOptions) ([][]int32, error) {
+	brokers, _, err := ca.DescribeCluster()
+	if err != nil {
+		return nil, err
+	}
+	return assignReplicas(brokers, numPartitions, replicationFactor, options)
+}
+
 func (ca *clusterAdmin) AlterPartitionReassignments(topic string, assignment [][]int32) error {
 	if topic == "" {
 		return ErrInvalidTopic
@@ -545,73 +1375,562 @@ func (ca *clusterAdmin) ListPartitionReassignments(topic string, partitions []in
 
 	request.AddBlock(topic, partitions)
 
-	b, err := ca.Controller()
+	err = ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.ListPartitionReassignments(request)
+		if err != nil {
+			return err
+		}
+		if !errors.Is(rsp.ErrorCode, ErrNoError) {
+			return rsp.ErrorCode
+		}
+		topicStatus = rsp.TopicStatus
+		return nil
+	})
+	return topicStatus, err
+}
+
+const (
+	leaderThrottledReplicasConfig   = "leader.replication.throttled.replicas"
+	followerThrottledReplicasConfig = "follower.replication.throttled.replicas"
+	leaderThrottledRateConfig       = "leader.replication.throttled.rate"
+	followerThrottledRateConfig     = "follower.replication.throttled.rate"
+)
+
+func (ca *clusterAdmin) ThrottleReassignment(topic string, assignment [][]int32, rate int64) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	if rate <= 0 {
+		return ConfigurationError("ThrottleReassignment requires a positive rate")
+	}
+
+	plans, err := ca.planReassignment(topic, assignment)
+	if err != nil {
+		return err
+	}
+
+	// the throttled replicas are listed as partition:broker
+	var leaders, followers []string
+	brokers := make(map[int32]bool)
+	for _, partition := range sortedPartitionIDs(plans) {
+		plan := plans[partition]
+		if len(plan.AddingReplicas) == 0 {
+			// replicas only reordered, nothing to copy
+			continue
+		}
+		for _, id := range plan.Replicas {
+			leaders = append(leaders, fmt.Sprintf("%d:%d", partition, id))
+			brokers[id] = true
+		}
+		for _, id := range plan.AddingReplicas {
+			followers = append(followers, fmt.Sprintf("%d:%d", partition, id))
+			brokers[id] = true
+		}
+	}
+	if len(followers) == 0 {
+		return nil
+	}
+
+	leaderReplicas := strings.Join(leaders, ",")
+	followerReplicas := strings.Join(followers, ",")
+	err = ca.IncrementalAlterConfig(TopicResource, topic, map[string]IncrementalAlterConfigsEntry{
+		leaderThrottledReplicasConfig:   {Operation: IncrementalAlterConfigsOperationAppend, Value: &leaderReplicas},
+		followerThrottledReplicasConfig: {Operation: IncrementalAlterConfigsOperationAppend, Value: &followerReplicas},
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	value := strconv.FormatInt(rate, 10)
+	var errs []error
+	for id := range brokers {
+		err := ca.IncrementalAlterConfig(BrokerResource, strconv.Itoa(int(id)), map[string]IncrementalAlterConfigsEntry{
+			leaderThrottledRateConfig:   {Operation: IncrementalAlterConfigsOperationSet, Value: &value},
+			followerThrottledRateConfig: {Operation: IncrementalAlterConfigsOperationSet, Value: &value},
+		}, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("broker %d: %w", id, err))
+		}
+	}
+	return multiError(errs...)
+}
+
+// PartitionReassignmentPlan is the change of the replicas of a partition a reassignment
+// would make, as returned by ClusterAdmin.ValidatePartitionReassignments.
+type PartitionReassignmentPlan struct {
+	// Replicas are the current replicas of the partition and TargetReplicas the ones after
+	// the reassignment.
+	Replicas       []int32
+	TargetReplicas []int32
+	// AddingReplicas are the replicas the data of the partition would be copied to and
+	// RemovingReplicas the ones it would be deleted from.
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+}
+
+// planReassignment returns the plans of the partitions of the topic reassigned by the given
+// assignment, as passed to AlterPartitionReassignments, by partition. The partitions with nil
+// replicas, whose ongoing reassignment would be cancelled, have no plan.
+func (ca *clusterAdmin) planReassignment(topic string, assignment [][]int32) (map[int32]*PartitionReassignmentPlan, error) {
+	topics, err := ca.DescribeTopics([]string{topic})
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) == 0 {
+		return nil, ErrUnknownTopicOrPartition
+	}
+	if topics[0].Err != ErrNoError {
+		return nil, topics[0].Err
+	}
+	current := make(map[int32][]int32, len(topics[0].Partitions))
+	for _, partition := range topics[0].Partitions {
+		current[partition.ID] = partition.Replicas
+	}
+
+	plans := make(map[int32]*PartitionReassignmentPlan)
+	for i, target := range assignment {
+		if target == nil {
+			continue
+		}
+		partition := int32(i)
+		replicas, ok := current[partition]
+		if !ok {
+			return nil, ErrUnknownTopicOrPartition
+		}
+		plan := &PartitionReassignmentPlan{Replicas: replicas, TargetReplicas: target}
+		isCurrent := make(map[int32]bool, len(replicas))
+		for _, id := range replicas {
+			isCurrent[id] = true
+		}
+		isTarget := make(map[int32]bool, len(target))
+		for _, id := range target {
+			isTarget[id] = true
+			if !isCurrent[id] {
+				plan.AddingReplicas = append(plan.AddingReplicas, id)
+			}
+		}
+		for _, id := range replicas {
+			if !isTarget[id] {
+				plan.RemovingReplicas = append(plan.RemovingReplicas, id)
+			}
+		}
+		plans[partition] = plan
+	}
+	return plans, nil
+}
+
+func sortedPartitionIDs(plans map[int32]*PartitionReassignmentPlan) []int32 {
+	partitions := make([]int32, 0, len(plans))
+	for partition := range plans {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
+
+func (ca *clusterAdmin) ValidatePartitionReassignments(topic string, assignment [][]int32) (map[int32]*PartitionReassignmentPlan, error) {
+	if topic == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	plans, err := ca.planReassignment(topic, assignment)
+	if err != nil {
+		return nil, err
+	}
+
+	brokers := make(map[int32]bool)
+	for _, b := range ca.client.Brokers() {
+		brokers[b.ID()] = true
+	}
+	var errs []error
+	for _, partition := range sortedPartitionIDs(plans) {
+		target := plans[partition].TargetReplicas
+		seen := make(map[int32]bool, len(target))
+		valid := len(target) > 0
+		for _, id := range target {
+			if seen[id] || !brokers[id] {
+				valid = false
+			}
+			seen[id] = true
+		}
+		if !valid {
+			errs = append(errs, fmt.Errorf("[%s-%d]: %w", topic, partition, ErrInvalidReplicaAssignment))
+		}
+	}
+	if len(errs) > 0 {
+		return plans, Wrap(ErrReassignPartitions, errs...)
+	}
+	return plans, nil
+}
+
+func (ca *clusterAdmin) ClearReassignmentThrottle(topic string) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+
+	err := ca.IncrementalAlterConfig(TopicResource, topic, map[string]IncrementalAlterConfigsEntry{
+		leaderThrottledReplicasConfig:   {Operation: IncrementalAlterConfigsOperationDelete},
+		followerThrottledReplicasConfig: {Operation: IncrementalAlterConfigsOperationDelete},
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, b := range ca.client.Brokers() {
+		err := ca.IncrementalAlterConfig(BrokerResource, strconv.Itoa(int(b.ID())), map[string]IncrementalAlterConfigsEntry{
+			leaderThrottledRateConfig:   {Operation: IncrementalAlterConfigsOperationDelete},
+			followerThrottledRateConfig: {Operation: IncrementalAlterConfigsOperationDelete},
+		}, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("broker %d: %w", b.ID(), err))
+		}
+	}
+	return multiError(errs...)
+}
+
+func (ca *clusterAdmin) WaitForPartitionReassignments(topic string, partitions []int32, interval, timeout time.Duration, progress func(map[int32]*PartitionReplicaReassignmentsStatus)) error {
+	return ca.WaitForPartitionReassignmentsContext(context.Background(), topic, partitions, interval, timeout, progress)
+}
+
+func (ca *clusterAdmin) WaitForPartitionReassignmentsContext(ctx context.Context, topic string, partitions []int32, interval, timeout time.Duration, progress func(map[int32]*PartitionReplicaReassignmentsStatus)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := ca.ListPartitionReassignmentsContext(ctx, topic, partitions)
+		if err != nil {
+			return err
+		}
+		ongoing := status[topic]
+		if progress != nil {
+			progress(ongoing)
+		}
+		if len(ongoing) == 0 {
+			return nil
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return ErrReassignmentInProgress
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (ca *clusterAdmin) ElectLeaders(electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error) {
+	request := &ElectLeadersRequest{
+		Type:            electionType,
+		TopicPartitions: partitions,
+		Timeout:         ca.timeout(),
+	}
+	if ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		request.Version = 1
+	} else if electionType != PreferredElection {
+		return nil, ConfigurationError("unclean leader elections require Version >= V2_4_0_0")
+	}
+
+	var results map[string]map[int32]*PartitionResult
+	err := ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.ElectLeaders(request)
+		if err != nil {
+			return err
+		}
+		if !errors.Is(rsp.ErrorCode, ErrNoError) {
+			return rsp.ErrorCode
+		}
+		results = rsp.ReplicaElectionResults
+		return nil
+	})
+	return results, err
+}
+
+// FeatureMetadata are the features of a cluster, as returned by ClusterAdmin.DescribeFeatures.
+type FeatureMetadata struct {
+	// FinalizedFeatures are the version levels of the features finalized for the cluster, by
+	// name.
+	FinalizedFeatures map[string]FinalizedFeatureKey
+	// FinalizedFeaturesEpoch is the epoch of the finalized features, or -1 if it is unknown.
+	FinalizedFeaturesEpoch int64
+	// SupportedFeatures are the version ranges of the features supported by the broker that
+	// answered, by name.
+	SupportedFeatures map[string]SupportedFeatureKey
+}
+
+func (ca *clusterAdmin) DescribeFeatures() (*FeatureMetadata, error) {
+	if !ca.conf.Version.IsAtLeast(V2_7_0_0) {
+		return nil, ConfigurationError("features require Version >= V2_7_0_0")
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.ApiVersions(&ApiVersionsRequest{
+		Version:               3,
+		ClientSoftwareName:    defaultClientSoftwareName,
+		ClientSoftwareVersion: version(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if kerr := KError(rsp.ErrorCode); !errors.Is(kerr, ErrNoError) {
+		return nil, kerr
+	}
+
+	metadata := &FeatureMetadata{
+		FinalizedFeatures:      make(map[string]FinalizedFeatureKey, len(rsp.FinalizedFeatures)),
+		FinalizedFeaturesEpoch: rsp.FinalizedFeaturesEpoch,
+		SupportedFeatures:      make(map[string]SupportedFeatureKey, len(rsp.SupportedFeatures)),
+	}
+	for _, feature := range rsp.FinalizedFeatures {
+		metadata.FinalizedFeatures[feature.Name] = feature
+	}
+	for _, feature := range rsp.SupportedFeatures {
+		metadata.SupportedFeatures[feature.Name] = feature
+	}
+	return metadata, nil
+}
+
+func (ca *clusterAdmin) UpdateFeatures(updates []FeatureUpdate, validateOnly bool) error {
+	if len(updates) == 0 {
+		return ConfigurationError("UpdateFeatures requires at least one update")
+	}
+	request := &UpdateFeaturesRequest{
+		Timeout:        ca.timeout(),
+		FeatureUpdates: updates,
+		ValidateOnly:   validateOnly,
+	}
+	if ca.conf.Version.IsAtLeast(V3_3_0_0) {
+		request.Version = 1
+	} else if !ca.conf.Version.IsAtLeast(V2_7_0_0) {
+		return ConfigurationError("features require Version >= V2_7_0_0")
+	} else if validateOnly {
+		return ConfigurationError("validating feature updates requires Version >= V3_3_0_0")
+	} else {
+		for _, update := range updates {
+			if update.UpgradeType == FeatureUnsafeDowngrade {
+				return ConfigurationError("unsafe feature downgrades require Version >= V3_3_0_0")
+			}
+		}
+	}
+
+	return ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.UpdateFeatures(request)
+		if err != nil {
+			return err
+		}
+		if !errors.Is(rsp.ErrorCode, ErrNoError) {
+			return rsp.ErrorCode
+		}
+
+		var errs []error
+		for _, result := range rsp.Results {
+			if errors.Is(result.ErrorCode, ErrNoError) {
+				continue
+			}
+			if result.ErrorMessage != nil {
+				errs = append(errs, fmt.Errorf("feature %s: %w: %s", result.Feature, result.ErrorCode, *result.ErrorMessage))
+			} else {
+				errs = append(errs, fmt.Errorf("feature %s: %w", result.Feature, result.ErrorCode))
+			}
+		}
+		return multiError(errs...)
+	})
+}
+
+// ListOffsetsResult is the offset of a partition resolved by ClusterAdmin.ListOffsets.
+type ListOffsetsResult struct {
+	Offset int64
+	// Timestamp is the timestamp in milliseconds of the record at Offset when resolving a
+	// timestamp, with brokers with version 0.10.1.0 or higher, and -1 otherwise.
+	Timestamp int64
+	// Err is the KError of the partition, ErrNoError if its offset was resolved, or the error
+	// of the request sent to its leader.
+	Err error
+}
+
+func (ca *clusterAdmin) ListOffsets(topicPartitions map[string]map[int32]int64) (map[string]map[int32]*ListOffsetsResult, error) {
+	results := make(map[string]map[int32]*ListOffsetsResult, len(topicPartitions))
+	for topic, partitions := range topicPartitions {
+		results[topic] = make(map[int32]*ListOffsetsResult, len(partitions))
+	}
+	requests, err := offsetRequests(ca.client, topicPartitions, func() *OffsetRequest {
+		request := &OffsetRequest{}
+		if ca.conf.Version.IsAtLeast(V0_11_0_0) {
+			request.Version = 2
+			request.IsolationLevel = ca.conf.Consumer.IsolationLevel
+		} else if ca.conf.Version.IsAtLeast(V0_10_1_0) {
+			request.Version = 1
+		}
+		return request
+	}, func(topic string, partition int32, err error) error {
+		var kerr KError
+		if !errors.As(err, &kerr) {
+			return err
+		}
+		results[topic][partition] = &ListOffsetsResult{Offset: -1, Timestamp: -1, Err: kerr}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	_ = b.Open(ca.client.Config())
 
-	rsp, err := b.ListPartitionReassignments(request)
+	var errs []error
+	sendOffsetRequests(ca.client.Config(), requests, func(b *Broker, request *OffsetRequest, response *OffsetResponse, err error) {
+		if err != nil {
+			err = fmt.Errorf("broker %d: %w", b.ID(), err)
+			errs = append(errs, err)
+		}
+		for topic, partitions := range request.blocks {
+			for partition := range partitions {
+				result := &ListOffsetsResult{Offset: -1, Timestamp: -1, Err: err}
+				if err == nil {
+					// a partition missing from the response is unknown to its leader
+					result.Err = ErrUnknownTopicOrPartition
+					if block := response.GetBlock(topic, partition); block != nil {
+						result.Err = block.Err
+						if request.Version == 0 {
+							if len(block.Offsets) > 0 {
+								result.Offset = block.Offsets[0]
+							}
+						} else {
+							result.Offset, result.Timestamp = block.Offset, block.Timestamp
+						}
+					}
+				}
+				results[topic][partition] = result
+			}
+		}
+	})
 
-	if err == nil && rsp != nil {
-		return rsp.TopicStatus, nil
-	} else {
-		return nil, err
-	}
+	return results, multiError(errs...)
 }
 
 func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
 	if topic == "" {
 		return ErrInvalidTopic
 	}
+	results, err := ca.DeleteRecordsOfTopics(map[string]map[int32]int64{topic: partitionOffsets})
 	errs := make([]error, 0)
-	partitionPerBroker := make(map[*Broker][]int32)
-	for partition := range partitionOffsets {
-		broker, err := ca.client.Leader(topic, partition)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		partitionPerBroker[broker] = append(partitionPerBroker[broker], partition)
+	if err != nil {
+		errs = append(errs, err)
 	}
-	for broker, partitions := range partitionPerBroker {
-		topics := make(map[string]*DeleteRecordsRequestTopic)
-		recordsToDelete := make(map[int32]int64)
-		for _, p := range partitions {
-			recordsToDelete[p] = partitionOffsets[p]
-		}
-		topics[topic] = &DeleteRecordsRequestTopic{
-			PartitionOffsets: recordsToDelete,
-		}
-		request := &DeleteRecordsRequest{
-			Topics:  topics,
-			Timeout: ca.conf.Admin.Timeout,
-		}
-		rsp, err := broker.DeleteRecords(request)
-		if err != nil {
-			errs = append(errs, err)
-			continue
+	for _, result := range results[topic] {
+		if !errors.Is(result.Err, ErrNoError) {
+			errs = append(errs, result.Err)
 		}
+	}
+	if len(errs) > 0 {
+		return Wrap(ErrDeleteRecords, errs...)
+	}
+	return nil
+}
 
-		deleteRecordsResponseTopic, ok := rsp.Topics[topic]
-		if !ok {
-			errs = append(errs, ErrIncompleteResponse)
-			continue
-		}
+// DeleteRecordsResult is the result of the deletion of the records of a partition by
+// ClusterAdmin.DeleteRecordsOfTopics.
+type DeleteRecordsResult struct {
+	// LowWatermark is the offset of the first record left in the partition, or -1 if the
+	// records could not be deleted.
+	LowWatermark int64
+	Err          KError
+}
 
-		for _, deleteRecordsResponsePartition := range deleteRecordsResponseTopic.Partitions {
-			if !errors.Is(deleteRecordsResponsePartition.Err, ErrNoError) {
-				errs = append(errs, deleteRecordsResponsePartition.Err)
+func (ca *clusterAdmin) DeleteRecordsOfTopics(topicPartitionOffsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error) {
+	results := make(map[string]map[int32]*DeleteRecordsResult, len(topicPartitionOffsets))
+	requests := make(map[*Broker]*DeleteRecordsRequest)
+	for topic, partitionOffsets := range topicPartitionOffsets {
+		if topic == "" {
+			return nil, ErrInvalidTopic
+		}
+		results[topic] = make(map[int32]*DeleteRecordsResult, len(partitionOffsets))
+		for partition, offset := range partitionOffsets {
+			b, err := ca.client.Leader(topic, partition)
+			if err != nil {
+				var kerr KError
+				if !errors.As(err, &kerr) {
+					return nil, err
+				}
+				results[topic][partition] = &DeleteRecordsResult{LowWatermark: -1, Err: kerr}
 				continue
 			}
+			request := requests[b]
+			if request == nil {
+				request = &DeleteRecordsRequest{
+					Topics:  make(map[string]*DeleteRecordsRequestTopic),
+					Timeout: ca.timeout(),
+				}
+				requests[b] = request
+			}
+			requestTopic := request.Topics[topic]
+			if requestTopic == nil {
+				requestTopic = &DeleteRecordsRequestTopic{PartitionOffsets: make(map[int32]int64)}
+				request.Topics[topic] = requestTopic
+			}
+			requestTopic.PartitionOffsets[partition] = offset
 		}
 	}
-	if len(errs) > 0 {
-		return Wrap(ErrDeleteRecords, errs...)
+
+	var (
+		lock sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for b, request := range requests {
+		wg.Add(1)
+		go func(b *Broker, request *DeleteRecordsRequest) {
+			defer wg.Done()
+			_ = b.Open(ca.client.Config())
+			response, err := b.DeleteRecords(request)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("broker %d: %w", b.ID(), err))
+				return
+			}
+			for topic, requestTopic := range request.Topics {
+				for partition := range requestTopic.PartitionOffsets {
+					// a partition missing from the response is unknown to its leader
+					result := &DeleteRecordsResult{LowWatermark: -1, Err: ErrUnknownTopicOrPartition}
+					if responseTopic := response.Topics[topic]; responseTopic != nil {
+						if block := responseTopic.Partitions[partition]; block != nil {
+							result.Err = block.Err
+							if errors.Is(block.Err, ErrNoError) {
+								result.LowWatermark = block.LowWatermark
+							}
+						}
+					}
+					results[topic][partition] = result
+				}
+			}
+		}(b, request)
 	}
-	// todo since we are dealing with couple of partitions it would be good if we return slice of errors
-	// for each partition instead of one error
-	return nil
+	wg.Wait()
+
+	return results, multiError(errs...)
 }
 
 // Returns a bool indicating whether the resource request needs to go to a
@@ -784,6 +2103,54 @@ func (ca *clusterAdmin) IncrementalAlterConfig(resourceType ConfigResourceType,
 	return nil
 }
 
+// brokerLoggerLevels are the log4j levels of the loggers of a broker.
+var brokerLoggerLevels = map[string]bool{
+	"TRACE": true, "DEBUG": true, "INFO": true, "WARN": true, "ERROR": true, "FATAL": true, "OFF": true,
+}
+
+func (ca *clusterAdmin) DescribeBrokerLoggers(brokerID int32) (map[string]string, error) {
+	if !ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		return nil, ConfigurationError("describing broker loggers requires Version >= V2_4_0_0")
+	}
+	entries, err := ca.DescribeConfig(ConfigResource{
+		Type: BrokerLoggerResource,
+		Name: strconv.Itoa(int(brokerID)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		levels[entry.Name] = entry.Value
+	}
+	return levels, nil
+}
+
+func (ca *clusterAdmin) AlterBrokerLoggers(brokerID int32, levels map[string]string, validateOnly bool) error {
+	if !ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		return ConfigurationError("altering broker loggers requires Version >= V2_4_0_0")
+	}
+	entries := make(map[string]IncrementalAlterConfigsEntry, len(levels))
+	for logger, level := range levels {
+		if logger == "" {
+			return ConfigurationError("broker logger names must not be empty")
+		}
+		if level == "" {
+			entries[logger] = IncrementalAlterConfigsEntry{Operation: IncrementalAlterConfigsOperationDelete}
+			continue
+		}
+		value := strings.ToUpper(level)
+		if !brokerLoggerLevels[value] {
+			return ConfigurationError(fmt.Sprintf("invalid level %s of broker logger %s", level, logger))
+		}
+		entries[logger] = IncrementalAlterConfigsEntry{Operation: IncrementalAlterConfigsOperationSet, Value: &value}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return ca.IncrementalAlterConfig(BrokerLoggerResource, strconv.Itoa(int(brokerID)), entries, validateOnly)
+}
+
 func (ca *clusterAdmin) CreateACL(resource Resource, acl Acl) error {
 	var acls []*AclCreation
 	acls = append(acls, &AclCreation{resource, acl})
@@ -876,6 +2243,156 @@ func (ca *clusterAdmin) DeleteACL(filter AclFilter, validateOnly bool) ([]Matchi
 	return mAcls, nil
 }
 
+func (ca *clusterAdmin) SyncACLs(desired []ResourceAcls, dryRun bool) (*ACLDiff, error) {
+	current := make(map[Resource][]*Acl)
+	for _, resourceACLs := range desired {
+		resource := normalizeACLResource(resourceACLs.Resource)
+		if _, ok := current[resource]; ok {
+			continue
+		}
+		name := resource.ResourceName
+		listed, err := ca.ListAcls(AclFilter{
+			ResourceType:              resource.ResourceType,
+			ResourceName:              &name,
+			ResourcePatternTypeFilter: resource.ResourcePatternType,
+			Operation:                 AclOperationAny,
+			PermissionType:            AclPermissionAny,
+		})
+		if err != nil {
+			return nil, err
+		}
+		current[resource] = nil
+		for _, l := range listed {
+			if normalizeACLResource(l.Resource) == resource {
+				current[resource] = append(current[resource], l.Acls...)
+			}
+		}
+	}
+
+	changes := diffACLs(desired, current)
+	diff := newACLDiff(changes)
+	if dryRun {
+		return diff, nil
+	}
+
+	var errs []error
+	for _, c := range changes {
+		if err := ca.applyACLChanges(c); err != nil {
+			errs = append(errs, aclResourceError(c.resource, err))
+		}
+	}
+	return diff, multiError(errs...)
+}
+
+func (ca *clusterAdmin) PreviewACLResources(resource Resource) ([]string, error) {
+	resource = normalizeACLResource(resource)
+	if resource.ResourcePatternType != AclPatternLiteral && resource.ResourcePatternType != AclPatternPrefixed {
+		return nil, ConfigurationError("ACLs can only be bound to literal or prefixed resources")
+	}
+
+	var (
+		names []string
+		err   error
+	)
+	switch resource.ResourceType {
+	case AclResourceTopic:
+		var b *Broker
+		if b, err = ca.findAnyBroker(); err != nil {
+			return nil, err
+		}
+		_ = b.Open(ca.client.Config())
+		metadata, err := b.GetMetadata(NewMetadataRequest(ca.conf.Version, nil))
+		if err != nil {
+			return nil, err
+		}
+		for _, topic := range metadata.Topics {
+			names = append(names, topic.Name)
+		}
+	case AclResourceGroup:
+		var groups map[string]string
+		if groups, err = ca.ListConsumerGroups(); groups == nil {
+			return nil, err
+		}
+		for group := range groups {
+			names = append(names, group)
+		}
+	case AclResourceCluster:
+		names = []string{aclClusterResourceName}
+	case AclResourceTransactionalID:
+		var listings []TransactionListing
+		if listings, err = ca.ListTransactions(nil, nil); listings == nil && err != nil {
+			return nil, err
+		}
+		for _, listing := range listings {
+			names = append(names, listing.TransactionalID)
+		}
+	case AclResourceDelegationToken:
+		var tokens []DelegationToken
+		if tokens, err = ca.DescribeDelegationToken(nil); err != nil {
+			return nil, err
+		}
+		for _, token := range tokens {
+			names = append(names, token.TokenID)
+		}
+	default:
+		return nil, ConfigurationError("ACLs can only be bound to topics, groups, the cluster, transactional IDs or delegation tokens")
+	}
+
+	var matching []string
+	for _, name := range names {
+		if resource.matches(name) {
+			matching = append(matching, name)
+		}
+	}
+	sort.Strings(matching)
+	return matching, err
+}
+
+func (ca *clusterAdmin) applyACLChanges(c *aclResourceChanges) error {
+	var version int16
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		version = 1
+	}
+
+	b, err := ca.Controller()
+	if err != nil {
+		return err
+	}
+
+	if len(c.create) > 0 {
+		request := &CreateAclsRequest{Version: version}
+		for _, acl := range c.create {
+			request.AclCreations = append(request.AclCreations, &AclCreation{c.resource, *acl})
+		}
+		rsp, err := b.CreateAcls(request)
+		if err != nil {
+			return err
+		}
+		for _, r := range rsp.AclCreationResponses {
+			if r.Err != ErrNoError {
+				return r.Err
+			}
+		}
+	}
+
+	if len(c.delete) > 0 {
+		request := &DeleteAclsRequest{Version: int(version)}
+		for _, acl := range c.delete {
+			request.Filters = append(request.Filters, aclFilterFor(c.resource, acl))
+		}
+		rsp, err := b.DeleteAcls(request)
+		if err != nil {
+			return err
+		}
+		for _, r := range rsp.FilterResponses {
+			if r.Err != ErrNoError {
+				return r.Err
+			}
+		}
+	}
+	return nil
+}
+
 func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) (result []*GroupDescription, err error) {
 	groupsPerBroker := make(map[*Broker][]string)
 
@@ -904,57 +2421,97 @@ func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) (result []*Group
 	return result, nil
 }
 
-func (ca *clusterAdmin) ListConsumerGroups() (allGroups map[string]string, err error) {
-	allGroups = make(map[string]string)
+func (ca *clusterAdmin) ListConsumerGroups() (map[string]string, error) {
+	report, err := ca.ListConsumerGroupsWithOptions(ListConsumerGroupsOptions{})
+	if report == nil {
+		return nil, err
+	}
+	allGroups := make(map[string]string, len(report.Groups))
+	for _, group := range report.Groups {
+		allGroups[group.GroupID] = group.ProtocolType
+	}
+	return allGroups, err
+}
 
-	// Query brokers in parallel, since we have to query *all* brokers
-	brokers := ca.client.Brokers()
-	groupMaps := make(chan map[string]string, len(brokers))
-	errChan := make(chan error, len(brokers))
-	wg := sync.WaitGroup{}
+func (ca *clusterAdmin) ListConsumerGroupsWithOptions(options ListConsumerGroupsOptions) (*ConsumerGroupListingReport, error) {
+	request := &ListGroupsRequest{
+		StatesFilter: options.States,
+		TypesFilter:  options.Types,
+	}
+	if ca.conf.Version.IsAtLeast(V3_8_0_0) {
+		request.Version = 5
+	} else if ca.conf.Version.IsAtLeast(V2_6_0_0) {
+		request.Version = 4
+	} else if ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		request.Version = 3
+	} else if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 2
+	} else if ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		request.Version = 1
+	}
+	if len(options.States) > 0 && request.Version < 4 {
+		return nil, ConfigurationError("filtering consumer groups by state requires Version >= V2_6_0_0")
+	}
+	if len(options.Types) > 0 && request.Version < 5 {
+		return nil, ConfigurationError("filtering consumer groups by type requires Version >= V3_8_0_0")
+	}
 
+	// Query brokers in parallel, since we have to query *all* brokers
+	var (
+		brokers = ca.client.Brokers()
+		report  = &ConsumerGroupListingReport{}
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
 	for _, b := range brokers {
 		wg.Add(1)
-		go func(b *Broker, conf *Config) {
+		go func(b *Broker) {
 			defer wg.Done()
-			_ = b.Open(conf) // Ensure that broker is opened
+			_ = b.Open(ca.conf) // Ensure that broker is opened
+
+			response, err := b.ListGroups(request)
+			if err == nil && !errors.Is(response.Err, ErrNoError) {
+				err = response.Err
+			}
 
-			response, err := b.ListGroups(&ListGroupsRequest{})
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				errChan <- err
+				if report.BrokerErrors == nil {
+					report.BrokerErrors = make(map[int32]error)
+				}
+				report.BrokerErrors[b.ID()] = err
 				return
 			}
-
-			groups := make(map[string]string)
-			for group, typ := range response.Groups {
-				groups[group] = typ
+			for group, protocolType := range response.Groups {
+				data := response.GroupsData[group]
+				report.Groups = append(report.Groups, ConsumerGroupListing{
+					GroupID:       group,
+					ProtocolType:  protocolType,
+					State:         data.GroupState,
+					Type:          data.GroupType,
+					CoordinatorID: b.ID(),
+				})
 			}
-
-			groupMaps <- groups
-		}(b, ca.conf)
+		}(b)
 	}
-
 	wg.Wait()
-	close(groupMaps)
-	close(errChan)
 
-	for groupMap := range groupMaps {
-		for group, protocolType := range groupMap {
-			allGroups[group] = protocolType
-		}
-	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].GroupID < report.Groups[j].GroupID })
 
-	// Intentionally return only the first error for simplicity
-	err = <-errChan
-	return
+	ids := make([]int32, 0, len(report.BrokerErrors))
+	for id := range report.BrokerErrors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	errs := make([]error, 0, len(ids))
+	for _, id := range ids {
+		errs = append(errs, fmt.Errorf("broker %d: %w", id, report.BrokerErrors[id]))
+	}
+	return report, multiError(errs...)
 }
 
 func (ca *clusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error) {
-	coordinator, err := ca.client.Coordinator(group)
-	if err != nil {
-		return nil, err
-	}
-
 	request := &OffsetFetchRequest{
 		ConsumerGroup: group,
 		partitions:    topicPartitions,
@@ -966,15 +2523,102 @@ func (ca *clusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions m
 		request.Version = 1
 	}
 
-	return coordinator.FetchOffset(request)
+	var response *OffsetFetchResponse
+	err := ca.retryOnCoordinatorError(group, func() error {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return err
+		}
+		rsp, err := coordinator.FetchOffset(request)
+		if err != nil {
+			return err
+		}
+		if isErrCoordinatorUnavailable(rsp.Err) {
+			return rsp.Err
+		}
+		response = rsp
+		return nil
+	})
+	return response, err
 }
 
-func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, partition int32) error {
-	coordinator, err := ca.client.Coordinator(group)
+func (ca *clusterAdmin) GroupLag(group string) (*GroupLag, error) {
+	groups, err := ca.DescribeConsumerGroups([]string{group})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(groups) != 1 {
+		return nil, ErrIncompleteResponse
+	}
+	description := groups[0]
+	if !errors.Is(description.Err, ErrNoError) {
+		return nil, description.Err
+	}
+
+	lag := &GroupLag{Group: group, State: description.State, Topics: make(map[string]*TopicLag)}
+	// only the assignments of consumers can be decoded
+	if description.ProtocolType == "consumer" {
+		for _, member := range description.Members {
+			assignment, err := member.GetMemberAssignment()
+			if err != nil {
+				return nil, err
+			}
+			if assignment == nil {
+				continue
+			}
+			for topic, partitions := range assignment.Topics {
+				for _, partition := range partitions {
+					p := lag.partition(topic, partition)
+					p.MemberID, p.ClientID, p.ClientHost = member.MemberId, member.ClientId, member.ClientHost
+				}
+			}
+		}
+	}
+
+	offsets, err := ca.ListConsumerGroupOffsets(group, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !errors.Is(offsets.Err, ErrNoError) {
+		return nil, offsets.Err
+	}
+	for topic, partitions := range offsets.Blocks {
+		for partition, block := range partitions {
+			p := lag.partition(topic, partition)
+			p.Committed, p.Metadata, p.Err = block.Offset, block.Metadata, block.Err
+		}
+	}
+
+	ends := make(map[string]map[int32]int64, len(lag.Topics))
+	for topic, t := range lag.Topics {
+		ends[topic] = make(map[int32]int64, len(t.Partitions))
+		for partition := range t.Partitions {
+			ends[topic][partition] = OffsetNewest
+		}
+	}
+	results, err := ca.ListOffsets(ends)
+	if err != nil {
+		return nil, err
+	}
+	for topic, partitions := range results {
+		for partition, result := range partitions {
+			p := lag.Topics[topic].Partitions[partition]
+			if !errors.Is(result.Err, ErrNoError) {
+				if errors.Is(p.Err, ErrNoError) {
+					// the results only hold KErrors when no request failed
+					_ = errors.As(result.Err, &p.Err)
+				}
+				continue
+			}
+			p.End = result.Offset
+		}
 	}
 
+	lag.sum()
+	return lag, nil
+}
+
+func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, partition int32) error {
 	request := &DeleteOffsetsRequest{
 		Group: group,
 		partitions: map[string][]int32{
@@ -982,46 +2626,106 @@ func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, pa
 		},
 	}
 
-	resp, err := coordinator.DeleteOffsets(request)
-	if err != nil {
-		return err
+	return ca.retryOnCoordinatorError(group, func() error {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return err
+		}
+
+		resp, err := coordinator.DeleteOffsets(request)
+		if err != nil {
+			return err
+		}
+
+		if !errors.Is(resp.ErrorCode, ErrNoError) {
+			return resp.ErrorCode
+		}
+
+		if !errors.Is(resp.Errors[topic][partition], ErrNoError) {
+			return resp.Errors[topic][partition]
+		}
+		return nil
+	})
+}
+
+func (ca *clusterAdmin) DeleteConsumerGroupOffsets(group string, topicPartitions map[string][]int32) error {
+	request := &DeleteOffsetsRequest{Group: group}
+	var committed *OffsetFetchResponse
+	for topic, partitions := range topicPartitions {
+		if partitions == nil {
+			if committed == nil {
+				var err error
+				if committed, err = ca.ListConsumerGroupOffsets(group, nil); err != nil {
+					return err
+				}
+			}
+			for partition := range committed.Blocks[topic] {
+				request.AddPartition(topic, partition)
+			}
+			continue
+		}
+		for _, partition := range partitions {
+			request.AddPartition(topic, partition)
+		}
+	}
+	if len(request.partitions) == 0 {
+		return nil
 	}
 
-	if !errors.Is(resp.ErrorCode, ErrNoError) {
-		return resp.ErrorCode
-	}
+	return ca.retryOnCoordinatorError(group, func() error {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return err
+		}
+
+		resp, err := coordinator.DeleteOffsets(request)
+		if err != nil {
+			return err
+		}
+
+		if !errors.Is(resp.ErrorCode, ErrNoError) {
+			return resp.ErrorCode
+		}
 
-	if !errors.Is(resp.Errors[topic][partition], ErrNoError) {
-		return resp.Errors[topic][partition]
-	}
-	return nil
+		var errs []error
+		for topic, partitions := range resp.Errors {
+			for partition, kerr := range partitions {
+				if !errors.Is(kerr, ErrNoError) {
+					errs = append(errs, fmt.Errorf("[%s-%d]: %w", topic, partition, kerr))
+				}
+			}
+		}
+		return multiError(errs...)
+	})
 }
 
 func (ca *clusterAdmin) DeleteConsumerGroup(group string) error {
-	coordinator, err := ca.client.Coordinator(group)
-	if err != nil {
-		return err
-	}
-
 	request := &DeleteGroupsRequest{
 		Groups: []string{group},
 	}
 
-	resp, err := coordinator.DeleteGroups(request)
-	if err != nil {
-		return err
-	}
+	return ca.retryOnCoordinatorError(group, func() error {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return err
+		}
 
-	groupErr, ok := resp.GroupErrorCodes[group]
-	if !ok {
-		return ErrIncompleteResponse
-	}
+		resp, err := coordinator.DeleteGroups(request)
+		if err != nil {
+			return err
+		}
 
-	if !errors.Is(groupErr, ErrNoError) {
-		return groupErr
-	}
+		groupErr, ok := resp.GroupErrorCodes[group]
+		if !ok {
+			return ErrIncompleteResponse
+		}
 
-	return nil
+		if !errors.Is(groupErr, ErrNoError) {
+			return groupErr
+		}
+
+		return nil
+	})
 }
 
 func (ca *clusterAdmin) DescribeLogDirs(brokerIds []int32) (allLogDirs map[int32][]DescribeLogDirsResponseDirMetadata, err error) {
@@ -1069,7 +2773,123 @@ func (ca *clusterAdmin) DescribeLogDirs(brokerIds []int32) (allLogDirs map[int32
 	return
 }
 
+func (ca *clusterAdmin) DescribeLogDirUsage(brokerIds []int32) (*LogDirUsageReport, error) {
+	if len(brokerIds) == 0 {
+		for _, b := range ca.client.Brokers() {
+			brokerIds = append(brokerIds, b.ID())
+		}
+	}
+	logDirs, err := ca.DescribeLogDirs(brokerIds)
+	return newLogDirUsageReport(logDirs), err
+}
+
+func (ca *clusterAdmin) AlterReplicaLogDirs(brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]KError, error) {
+	if !ca.conf.Version.IsAtLeast(V1_1_0_0) {
+		return nil, ConfigurationError("altering replica log dirs requires Version >= V1_1_0_0")
+	}
+	request := &AlterReplicaLogDirsRequest{}
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 1
+	}
+	for topic, partitions := range moves {
+		for partition, path := range partitions {
+			request.AddPartition(path, topic, partition)
+		}
+	}
+	if len(request.Dirs) == 0 {
+		return nil, nil
+	}
+
+	b, err := ca.findBroker(brokerID)
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+	response, err := b.AlterReplicaLogDirs(request)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]map[int32]KError, len(moves))
+	for topic, partitions := range moves {
+		results[topic] = make(map[int32]KError, len(partitions))
+		for partition := range partitions {
+			kerr, ok := response.Results[topic][partition]
+			if !ok {
+				// a partition missing from the response is unknown to the broker
+				kerr = ErrUnknownTopicOrPartition
+			}
+			results[topic][partition] = kerr
+		}
+	}
+	return results, nil
+}
+
+func (ca *clusterAdmin) VerifyReplicaLogDirs(brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]string, error) {
+	if !ca.conf.Version.IsAtLeast(V1_1_0_0) {
+		return nil, ConfigurationError("verifying replica log dirs requires Version >= V1_1_0_0")
+	}
+	request := &DescribeLogDirsRequest{}
+	for topic, partitions := range moves {
+		describe := DescribeLogDirsRequestTopic{Topic: topic}
+		for partition := range partitions {
+			describe.PartitionIDs = append(describe.PartitionIDs, partition)
+		}
+		request.DescribeTopics = append(request.DescribeTopics, describe)
+	}
+	if len(request.DescribeTopics) == 0 {
+		return nil, nil
+	}
+
+	b, err := ca.findBroker(brokerID)
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+	response, err := b.DescribeLogDirs(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// the temporary log of a replica being moved replaces its current log once it caught up
+	done := make(map[string]map[int32]bool)
+	for _, dir := range response.LogDirs {
+		if !errors.Is(dir.ErrorCode, ErrNoError) {
+			continue
+		}
+		for _, topic := range dir.Topics {
+			for _, partition := range topic.Partitions {
+				if partition.IsTemporary || moves[topic.Topic][partition.PartitionID] != dir.Path {
+					continue
+				}
+				if done[topic.Topic] == nil {
+					done[topic.Topic] = make(map[int32]bool)
+				}
+				done[topic.Topic][partition.PartitionID] = true
+			}
+		}
+	}
+
+	pending := make(map[string]map[int32]string)
+	for topic, partitions := range moves {
+		for partition, path := range partitions {
+			if done[topic][partition] {
+				continue
+			}
+			if pending[topic] == nil {
+				pending[topic] = make(map[int32]string)
+			}
+			pending[topic][partition] = path
+		}
+	}
+	return pending, nil
+}
+
 func (ca *clusterAdmin) DescribeUserScramCredentials(users []string) ([]*DescribeUserScramCredentialsResult, error) {
+	if !ca.conf.Version.IsAtLeast(V2_7_0_0) {
+		return nil, ConfigurationError("describing SCRAM credentials requires Version >= V2_7_0_0")
+	}
+
 	req := &DescribeUserScramCredentialsRequest{}
 	for _, u := range users {
 		req.DescribeUsers = append(req.DescribeUsers, DescribeUserScramCredentialsRequestUser{
@@ -1081,17 +2901,40 @@ func (ca *clusterAdmin) DescribeUserScramCredentials(users []string) ([]*Describ
 	if err != nil {
 		return nil, err
 	}
+	_ = b.Open(ca.client.Config())
 
 	rsp, err := b.DescribeUserScramCredentials(req)
 	if err != nil {
 		return nil, err
 	}
+	if !errors.Is(rsp.ErrorCode, ErrNoError) {
+		return nil, rsp.ErrorCode
+	}
 
 	return rsp.Results, nil
 }
 
 func (ca *clusterAdmin) UpsertUserScramCredentials(upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error) {
-	res, err := ca.AlterUserScramCredentials(upsert, nil)
+	// the defaults are set on copies not to change the upserts of the caller
+	upserts := make([]AlterUserScramCredentialsUpsert, len(upsert))
+	for i, u := range upsert {
+		if u.Mechanism != SCRAM_MECHANISM_SHA_256 && u.Mechanism != SCRAM_MECHANISM_SHA_512 {
+			return nil, ErrUnknownScramMechanism
+		}
+		if u.Iterations == 0 {
+			u.Iterations = scramMinIterations
+		}
+		if len(u.Salt) == 0 {
+			salt, err := newScramSalt()
+			if err != nil {
+				return nil, err
+			}
+			u.Salt = salt
+		}
+		upserts[i] = u
+	}
+
+	res, err := ca.AlterUserScramCredentials(upserts, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1109,6 +2952,10 @@ func (ca *clusterAdmin) DeleteUserScramCredentials(delete []AlterUserScramCreden
 }
 
 func (ca *clusterAdmin) AlterUserScramCredentials(u []AlterUserScramCredentialsUpsert, d []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error) {
+	if !ca.conf.Version.IsAtLeast(V2_7_0_0) {
+		return nil, ConfigurationError("altering SCRAM credentials requires Version >= V2_7_0_0")
+	}
+
 	req := &AlterUserScramCredentialsRequest{
 		Deletions:  d,
 		Upsertions: u,
@@ -1118,6 +2965,7 @@ func (ca *clusterAdmin) AlterUserScramCredentials(u []AlterUserScramCredentialsU
 	if err != nil {
 		return nil, err
 	}
+	_ = b.Open(ca.client.Config())
 
 	rsp, err := b.AlterUserScramCredentials(req)
 	if err != nil {
@@ -1127,6 +2975,272 @@ func (ca *clusterAdmin) AlterUserScramCredentials(u []AlterUserScramCredentialsU
 	return rsp.Results, nil
 }
 
+func (ca *clusterAdmin) delegationTokenVersion() int16 {
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		return 1
+	}
+	return 0
+}
+
+func (ca *clusterAdmin) CreateDelegationToken(renewers []DelegationTokenPrincipal, maxLifetime time.Duration) (*DelegationToken, error) {
+	request := &CreateDelegationTokenRequest{
+		Version:     ca.delegationTokenVersion(),
+		Renewers:    renewers,
+		MaxLifetime: maxLifetime,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.CreateDelegationToken(request)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Err != ErrNoError {
+		return nil, rsp.Err
+	}
+
+	return &DelegationToken{
+		TokenID:    rsp.TokenID,
+		HMAC:       rsp.HMAC,
+		Owner:      rsp.Owner,
+		Renewers:   renewers,
+		IssueTime:  rsp.IssueTime,
+		ExpiryTime: rsp.ExpiryTime,
+		MaxTime:    rsp.MaxTime,
+	}, nil
+}
+
+func (ca *clusterAdmin) RenewDelegationToken(hmac []byte, renewPeriod time.Duration) (time.Time, error) {
+	request := &RenewDelegationTokenRequest{
+		Version:     ca.delegationTokenVersion(),
+		HMAC:        hmac,
+		RenewPeriod: renewPeriod,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return time.Time{}, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.RenewDelegationToken(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rsp.Err != ErrNoError {
+		return time.Time{}, rsp.Err
+	}
+	return rsp.ExpiryTime, nil
+}
+
+func (ca *clusterAdmin) ExpireDelegationToken(hmac []byte, expiryPeriod time.Duration) (time.Time, error) {
+	request := &ExpireDelegationTokenRequest{
+		Version:      ca.delegationTokenVersion(),
+		HMAC:         hmac,
+		ExpiryPeriod: expiryPeriod,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return time.Time{}, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.ExpireDelegationToken(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rsp.Err != ErrNoError {
+		return time.Time{}, rsp.Err
+	}
+	return rsp.ExpiryTime, nil
+}
+
+func (ca *clusterAdmin) DescribeDelegationToken(owners []DelegationTokenPrincipal) ([]DelegationToken, error) {
+	request := &DescribeDelegationTokenRequest{
+		Version: ca.delegationTokenVersion(),
+		Owners:  owners,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.DescribeDelegationToken(request)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Err != ErrNoError {
+		return nil, rsp.Err
+	}
+	return rsp.Tokens, nil
+}
+
+func (ca *clusterAdmin) DescribeProducers(topic string, partitions []int32) (map[int32][]ProducerState, error) {
+	if topic == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	leaders := make(map[*Broker][]int32)
+	for _, partition := range partitions {
+		b, err := ca.client.Leader(topic, partition)
+		if err != nil {
+			return nil, err
+		}
+		leaders[b] = append(leaders[b], partition)
+	}
+
+	producers := make(map[int32][]ProducerState, len(partitions))
+	var errs []error
+	for b, partitions := range leaders {
+		_ = b.Open(ca.client.Config())
+		rsp, err := b.DescribeProducers(&DescribeProducersRequest{Topics: map[string][]int32{topic: partitions}})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for partition, block := range rsp.Topics[topic] {
+			if !errors.Is(block.ErrorCode, ErrNoError) {
+				errs = append(errs, fmt.Errorf("[%s-%d]: %w", topic, partition, block.ErrorCode))
+				continue
+			}
+			producers[partition] = block.ActiveProducers
+		}
+	}
+	return producers, multiError(errs...)
+}
+
+func (ca *clusterAdmin) DescribeTransactions(transactionalIDs []string) ([]*TransactionDescription, error) {
+	coordinators := make(map[*Broker][]string)
+	for _, id := range transactionalIDs {
+		b, err := ca.client.TransactionCoordinator(id)
+		if err != nil {
+			return nil, err
+		}
+		coordinators[b] = append(coordinators[b], id)
+	}
+
+	var descriptions []*TransactionDescription
+	for b, ids := range coordinators {
+		_ = b.Open(ca.client.Config())
+		rsp, err := b.DescribeTransactions(&DescribeTransactionsRequest{TransactionalIDs: ids})
+		if err != nil {
+			return nil, err
+		}
+		descriptions = append(descriptions, rsp.TransactionStates...)
+	}
+	return descriptions, nil
+}
+
+// ListTransactionsOptions are the filters of ClusterAdmin.ListTransactionsWithOptions.
+type ListTransactionsOptions struct {
+	// States are the states of the transactions to list, such as "Ongoing", all if empty.
+	States []string
+	// ProducerIDs are the producers of the transactions to list, all if empty.
+	ProducerIDs []int64
+	// MinDuration lists only the transactions running for longer, such as hanging
+	// transactions, all if it is not positive.
+	MinDuration time.Duration
+}
+
+func (ca *clusterAdmin) ListTransactions(states []string, producerIDs []int64) ([]TransactionListing, error) {
+	return ca.ListTransactionsWithOptions(ListTransactionsOptions{States: states, ProducerIDs: producerIDs})
+}
+
+func (ca *clusterAdmin) ListTransactionsWithOptions(options ListTransactionsOptions) ([]TransactionListing, error) {
+	if !ca.conf.Version.IsAtLeast(V3_0_0_0) {
+		return nil, ConfigurationError("listing transactions requires Version >= V3_0_0_0")
+	}
+	request := &ListTransactionsRequest{
+		StateFilters:      options.States,
+		ProducerIDFilters: options.ProducerIDs,
+	}
+	if options.MinDuration > 0 {
+		if !ca.conf.Version.IsAtLeast(V3_8_0_0) {
+			return nil, ConfigurationError("filtering transactions by duration requires Version >= V3_8_0_0")
+		}
+		request.Version = 1
+		request.DurationFilter = options.MinDuration
+	}
+
+	var (
+		listings []TransactionListing
+		unknown  = make(map[string]bool)
+		errs     []error
+	)
+	for _, b := range ca.client.Brokers() {
+		_ = b.Open(ca.client.Config())
+		rsp, err := b.ListTransactions(request)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("broker %d: %w", b.ID(), err))
+			continue
+		}
+		if !errors.Is(rsp.ErrorCode, ErrNoError) {
+			errs = append(errs, fmt.Errorf("broker %d: %w", b.ID(), rsp.ErrorCode))
+			continue
+		}
+		for _, state := range rsp.UnknownStateFilters {
+			unknown[state] = true
+		}
+		listings = append(listings, rsp.TransactionStates...)
+	}
+	if len(unknown) > 0 {
+		states := make([]string, 0, len(unknown))
+		for state := range unknown {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+		errs = append(errs, ConfigurationError("unknown transaction states "+strings.Join(states, ", ")))
+	}
+
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].TransactionalID != listings[j].TransactionalID {
+			return listings[i].TransactionalID < listings[j].TransactionalID
+		}
+		return listings[i].ProducerID < listings[j].ProducerID
+	})
+	return listings, multiError(errs...)
+}
+
+func (ca *clusterAdmin) AbortTransaction(topic string, partition int32, producer ProducerState) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+
+	b, err := ca.client.Leader(topic, partition)
+	if err != nil {
+		return err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.WriteTxnMarkers(&WriteTxnMarkersRequest{
+		Markers: []*WriteTxnMarker{{
+			ProducerID:       producer.ProducerID,
+			ProducerEpoch:    int16(producer.ProducerEpoch),
+			Topics:           map[string][]int32{topic: {partition}},
+			CoordinatorEpoch: producer.CoordinatorEpoch,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	kerr, ok := rsp.Errors[producer.ProducerID][topic][partition]
+	if !ok {
+		return ErrIncompleteResponse
+	}
+	if !errors.Is(kerr, ErrNoError) {
+		return kerr
+	}
+	return nil
+}
+
 // Describe All : use an empty/nil components slice + strict = false
 // Contains components: strict = false
 // Contains only components: strict = true
@@ -1206,3 +3320,35 @@ func (ca *clusterAdmin) RemoveMemberFromConsumerGroup(groupId string, groupInsta
 	}
 	return controller.LeaveGroup(request)
 }
+
+func (ca *clusterAdmin) RemoveMembersFromConsumerGroup(group string, groupInstanceIDs []string) error {
+	if !ca.conf.Version.IsAtLeast(V2_3_0_0) {
+		return ConfigurationError("removing static members requires Version >= V2_3_0_0")
+	}
+	if len(groupInstanceIDs) == 0 {
+		return nil
+	}
+
+	return ca.retryOnCoordinatorError(group, func() error {
+		rsp, err := ca.RemoveMemberFromConsumerGroup(group, groupInstanceIDs)
+		if err != nil {
+			return err
+		}
+		if !errors.Is(rsp.Err, ErrNoError) {
+			return rsp.Err
+		}
+
+		var errs []error
+		for _, member := range rsp.Members {
+			if errors.Is(member.Err, ErrNoError) {
+				continue
+			}
+			var instanceID string
+			if member.GroupInstanceId != nil {
+				instanceID = *member.GroupInstanceId
+			}
+			errs = append(errs, fmt.Errorf("group instance %s: %w", instanceID, member.Err))
+		}
+		return multiError(errs...)
+	})
+}
@@ -0,0 +1,831 @@
+package sarama
+
+import (
+	"context"
+	"time"
+)
+
+// ClusterAdminContext are the variants of the ClusterAdmin operations taking a context, each
+// XContext method behaving as X but returning the error of ctx as soon as it is done.
+// The requests to the brokers cannot be interrupted: once ctx is done, an operation sends no
+// further request, neither retrying nor moving on to its next step, but the request in flight,
+// if any, still completes or times out after Net.ReadTimeout. Its mutation, e.g. a topic
+// creation, may thus still apply after a context error is returned, leaving multi-step
+// operations such as CloneTopic partly applied. The deadline of ctx caps the Admin.Timeout sent
+// to the brokers, bounding how long they wait for such operations.
+type ClusterAdminContext interface {
+	CreateTopicContext(ctx context.Context, topic string, detail *TopicDetail, validateOnly bool) error
+	CloneTopicContext(ctx context.Context, src string, dst string, overrides map[string]*string, preserveAssignment bool) error
+	ApplyTopicSpecContext(ctx context.Context, spec TopicSpec, dryRun bool) (*TopicSpecDiff, error)
+	ListTopicsContext(ctx context.Context) (map[string]TopicDetail, error)
+	DescribeTopicsContext(ctx context.Context, topics []string) ([]*TopicMetadata, error)
+	DeleteTopicContext(ctx context.Context, topic string) error
+	CreatePartitionsContext(ctx context.Context, topic string, count int32, assignment [][]int32, validateOnly bool) error
+	GenerateReplicaAssignmentContext(ctx context.Context, numPartitions int32, replicationFactor int16, options *ReplicaAssignmentOptions) ([][]int32, error)
+	AlterPartitionReassignmentsContext(ctx context.Context, topic string, assignment [][]int32) error
+	ValidatePartitionReassignmentsContext(ctx context.Context, topic string, assignment [][]int32) (map[int32]*PartitionReassignmentPlan, error)
+	ListPartitionReassignmentsContext(ctx context.Context, topics string, partitions []int32) (map[string]map[int32]*PartitionReplicaReassignmentsStatus, error)
+	ThrottleReassignmentContext(ctx context.Context, topic string, assignment [][]int32, rate int64) error
+	ClearReassignmentThrottleContext(ctx context.Context, topic string) error
+	WaitForPartitionReassignmentsContext(ctx context.Context, topic string, partitions []int32, interval, timeout time.Duration, progress func(map[int32]*PartitionReplicaReassignmentsStatus)) error
+	ElectLeadersContext(ctx context.Context, electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error)
+	DescribeFeaturesContext(ctx context.Context) (*FeatureMetadata, error)
+	UpdateFeaturesContext(ctx context.Context, updates []FeatureUpdate, validateOnly bool) error
+	ListOffsetsContext(ctx context.Context, topicPartitions map[string]map[int32]int64) (map[string]map[int32]*ListOffsetsResult, error)
+	DeleteRecordsContext(ctx context.Context, topic string, partitionOffsets map[int32]int64) error
+	DeleteRecordsOfTopicsContext(ctx context.Context, topicPartitionOffsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error)
+	DescribeConfigContext(ctx context.Context, resource ConfigResource) ([]ConfigEntry, error)
+	AlterConfigContext(ctx context.Context, resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error
+	IncrementalAlterConfigContext(ctx context.Context, resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error
+	DescribeBrokerLoggersContext(ctx context.Context, brokerID int32) (map[string]string, error)
+	AlterBrokerLoggersContext(ctx context.Context, brokerID int32, levels map[string]string, validateOnly bool) error
+	CreateACLContext(ctx context.Context, resource Resource, acl Acl) error
+	CreateACLsContext(ctx context.Context, resourceACLs []*ResourceAcls) error
+	ListAclsContext(ctx context.Context, filter AclFilter) ([]ResourceAcls, error)
+	DeleteACLContext(ctx context.Context, filter AclFilter, validateOnly bool) ([]MatchingAcl, error)
+	SyncACLsContext(ctx context.Context, desired []ResourceAcls, dryRun bool) (*ACLDiff, error)
+	PreviewACLResourcesContext(ctx context.Context, resource Resource) ([]string, error)
+	ListConsumerGroupsContext(ctx context.Context) (map[string]string, error)
+	ListConsumerGroupsWithOptionsContext(ctx context.Context, options ListConsumerGroupsOptions) (*ConsumerGroupListingReport, error)
+	DescribeConsumerGroupsContext(ctx context.Context, groups []string) ([]*GroupDescription, error)
+	ListConsumerGroupOffsetsContext(ctx context.Context, group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error)
+	GroupLagContext(ctx context.Context, group string) (*GroupLag, error)
+	DeleteConsumerGroupOffsetContext(ctx context.Context, group string, topic string, partition int32) error
+	DeleteConsumerGroupOffsetsContext(ctx context.Context, group string, topicPartitions map[string][]int32) error
+	DeleteConsumerGroupContext(ctx context.Context, group string) error
+	DescribeClusterContext(ctx context.Context) ([]*Broker, int32, error)
+	DescribeClusterInfoContext(ctx context.Context, includeAuthorizedOperations bool) (*ClusterDescription, error)
+	UnregisterBrokerContext(ctx context.Context, brokerID int32) error
+	DescribeQuorumContext(ctx context.Context) (*QuorumInfo, error)
+	HealthReportContext(ctx context.Context) (*ClusterHealthReport, error)
+	ClusterSnapshotContext(ctx context.Context) (*ClusterSnapshot, error)
+	DescribeLogDirsContext(ctx context.Context, brokers []int32) (map[int32][]DescribeLogDirsResponseDirMetadata, error)
+	DescribeLogDirUsageContext(ctx context.Context, brokers []int32) (*LogDirUsageReport, error)
+	AlterReplicaLogDirsContext(ctx context.Context, brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]KError, error)
+	VerifyReplicaLogDirsContext(ctx context.Context, brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]string, error)
+	DescribeUserScramCredentialsContext(ctx context.Context, users []string) ([]*DescribeUserScramCredentialsResult, error)
+	DeleteUserScramCredentialsContext(ctx context.Context, delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error)
+	UpsertUserScramCredentialsContext(ctx context.Context, upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error)
+	CreateDelegationTokenContext(ctx context.Context, renewers []DelegationTokenPrincipal, maxLifetime time.Duration) (*DelegationToken, error)
+	RenewDelegationTokenContext(ctx context.Context, hmac []byte, renewPeriod time.Duration) (time.Time, error)
+	ExpireDelegationTokenContext(ctx context.Context, hmac []byte, expiryPeriod time.Duration) (time.Time, error)
+	DescribeDelegationTokenContext(ctx context.Context, owners []DelegationTokenPrincipal) ([]DelegationToken, error)
+	DescribeProducersContext(ctx context.Context, topic string, partitions []int32) (map[int32][]ProducerState, error)
+	DescribeTransactionsContext(ctx context.Context, transactionalIDs []string) ([]*TransactionDescription, error)
+	ListTransactionsContext(ctx context.Context, states []string, producerIDs []int64) ([]TransactionListing, error)
+	ListTransactionsWithOptionsContext(ctx context.Context, options ListTransactionsOptions) ([]TransactionListing, error)
+	AbortTransactionContext(ctx context.Context, topic string, partition int32, producer ProducerState) error
+	DescribeClientQuotasContext(ctx context.Context, components []QuotaFilterComponent, strict bool) ([]DescribeClientQuotasEntry, error)
+	AlterClientQuotasContext(ctx context.Context, entity []QuotaEntityComponent, op ClientQuotasOp, validateOnly bool) error
+	ControllerContext(ctx context.Context) (*Broker, error)
+	RemoveMemberFromConsumerGroupContext(ctx context.Context, groupId string, groupInstanceIds []string) (*LeaveGroupResponse, error)
+	RemoveMembersFromConsumerGroupContext(ctx context.Context, group string, groupInstanceIDs []string) error
+}
+
+// runContext runs fn with a copy of the admin bound to ctx until it returns or ctx is done,
+// whichever happens first. In the latter case, fn keeps running in the background until the
+// request in flight when ctx is done completes, the brokers to send the next ones to no longer
+// being looked up and the retries stopping, its operation timeouts having been capped by the
+// deadline of ctx.
+func (ca *clusterAdmin) runContext(ctx context.Context, fn func(ca *clusterAdmin)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	bound := &clusterAdmin{client: contextClient{Client: ca.client, ctx: ctx}, conf: ca.conf, ctx: ctx}
+	done := make(chan none)
+	go withRecover(func() {
+		defer close(done)
+		fn(bound)
+	})
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextClient is the client of an admin bound to a context, failing to look up the brokers
+// once the context is done so that an abandoned operation sends no further request.
+type contextClient struct {
+	Client
+	ctx context.Context
+}
+
+func (c contextClient) Controller() (*Broker, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Client.Controller()
+}
+
+func (c contextClient) RefreshController() (*Broker, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Client.RefreshController()
+}
+
+func (c contextClient) Brokers() []*Broker {
+	if c.ctx.Err() != nil {
+		return nil
+	}
+	return c.Client.Brokers()
+}
+
+func (c contextClient) Broker(brokerID int32) (*Broker, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Client.Broker(brokerID)
+}
+
+func (c contextClient) Leader(topic string, partitionID int32) (*Broker, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Client.Leader(topic, partitionID)
+}
+
+func (c contextClient) LeaderAndEpoch(topic string, partitionID int32) (*Broker, int32, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, -1, err
+	}
+	return c.Client.LeaderAndEpoch(topic, partitionID)
+}
+
+func (c contextClient) Coordinator(consumerGroup string) (*Broker, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Client.Coordinator(consumerGroup)
+}
+
+func (c contextClient) TransactionCoordinator(transactionID string) (*Broker, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Client.TransactionCoordinator(transactionID)
+}
+
+func (c contextClient) LeastLoadedBroker() *Broker {
+	if c.ctx.Err() != nil {
+		return nil
+	}
+	return c.Client.LeastLoadedBroker()
+}
+
+func (ca *clusterAdmin) CreateTopicContext(ctx context.Context, topic string, detail *TopicDetail, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.CreateTopic(topic, detail, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) CloneTopicContext(ctx context.Context, src string, dst string, overrides map[string]*string, preserveAssignment bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.CloneTopic(src, dst, overrides, preserveAssignment) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ApplyTopicSpecContext(ctx context.Context, spec TopicSpec, dryRun bool) (*TopicSpecDiff, error) {
+	var (
+		result *TopicSpecDiff
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ApplyTopicSpec(spec, dryRun) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListTopicsContext(ctx context.Context) (map[string]TopicDetail, error) {
+	var (
+		result map[string]TopicDetail
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListTopics() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeTopicsContext(ctx context.Context, topics []string) ([]*TopicMetadata, error) {
+	var (
+		result []*TopicMetadata
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeTopics(topics) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DeleteTopicContext(ctx context.Context, topic string) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.DeleteTopic(topic) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) CreatePartitionsContext(ctx context.Context, topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.CreatePartitions(topic, count, assignment, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) GenerateReplicaAssignmentContext(ctx context.Context, numPartitions int32, replicationFactor int16, options *ReplicaAssignmentOptions) ([][]int32, error) {
+	var (
+		result [][]int32
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) {
+		result, err = ca.GenerateReplicaAssignment(numPartitions, replicationFactor, options)
+	}); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) AlterPartitionReassignmentsContext(ctx context.Context, topic string, assignment [][]int32) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.AlterPartitionReassignments(topic, assignment) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ValidatePartitionReassignmentsContext(ctx context.Context, topic string, assignment [][]int32) (map[int32]*PartitionReassignmentPlan, error) {
+	var (
+		result map[int32]*PartitionReassignmentPlan
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ValidatePartitionReassignments(topic, assignment) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListPartitionReassignmentsContext(ctx context.Context, topics string, partitions []int32) (map[string]map[int32]*PartitionReplicaReassignmentsStatus, error) {
+	var (
+		result map[string]map[int32]*PartitionReplicaReassignmentsStatus
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListPartitionReassignments(topics, partitions) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ThrottleReassignmentContext(ctx context.Context, topic string, assignment [][]int32, rate int64) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.ThrottleReassignment(topic, assignment, rate) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ClearReassignmentThrottleContext(ctx context.Context, topic string) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.ClearReassignmentThrottle(topic) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ElectLeadersContext(ctx context.Context, electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error) {
+	var (
+		result map[string]map[int32]*PartitionResult
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ElectLeaders(electionType, partitions) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeFeaturesContext(ctx context.Context) (*FeatureMetadata, error) {
+	var (
+		result *FeatureMetadata
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeFeatures() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) UpdateFeaturesContext(ctx context.Context, updates []FeatureUpdate, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.UpdateFeatures(updates, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ListOffsetsContext(ctx context.Context, topicPartitions map[string]map[int32]int64) (map[string]map[int32]*ListOffsetsResult, error) {
+	var (
+		result map[string]map[int32]*ListOffsetsResult
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListOffsets(topicPartitions) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DeleteRecordsContext(ctx context.Context, topic string, partitionOffsets map[int32]int64) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.DeleteRecords(topic, partitionOffsets) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DeleteRecordsOfTopicsContext(ctx context.Context, topicPartitionOffsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error) {
+	var (
+		result map[string]map[int32]*DeleteRecordsResult
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DeleteRecordsOfTopics(topicPartitionOffsets) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeConfigContext(ctx context.Context, resource ConfigResource) ([]ConfigEntry, error) {
+	var (
+		result []ConfigEntry
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeConfig(resource) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) AlterConfigContext(ctx context.Context, resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.AlterConfig(resourceType, name, entries, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) IncrementalAlterConfigContext(ctx context.Context, resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.IncrementalAlterConfig(resourceType, name, entries, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DescribeBrokerLoggersContext(ctx context.Context, brokerID int32) (map[string]string, error) {
+	var (
+		result map[string]string
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeBrokerLoggers(brokerID) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) AlterBrokerLoggersContext(ctx context.Context, brokerID int32, levels map[string]string, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.AlterBrokerLoggers(brokerID, levels, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) CreateACLContext(ctx context.Context, resource Resource, acl Acl) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.CreateACL(resource, acl) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) CreateACLsContext(ctx context.Context, resourceACLs []*ResourceAcls) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.CreateACLs(resourceACLs) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ListAclsContext(ctx context.Context, filter AclFilter) ([]ResourceAcls, error) {
+	var (
+		result []ResourceAcls
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListAcls(filter) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DeleteACLContext(ctx context.Context, filter AclFilter, validateOnly bool) ([]MatchingAcl, error) {
+	var (
+		result []MatchingAcl
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DeleteACL(filter, validateOnly) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) SyncACLsContext(ctx context.Context, desired []ResourceAcls, dryRun bool) (*ACLDiff, error) {
+	var (
+		result *ACLDiff
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.SyncACLs(desired, dryRun) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) PreviewACLResourcesContext(ctx context.Context, resource Resource) ([]string, error) {
+	var (
+		result []string
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.PreviewACLResources(resource) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListConsumerGroupsContext(ctx context.Context) (map[string]string, error) {
+	var (
+		result map[string]string
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListConsumerGroups() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListConsumerGroupsWithOptionsContext(ctx context.Context, options ListConsumerGroupsOptions) (*ConsumerGroupListingReport, error) {
+	var (
+		result *ConsumerGroupListingReport
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListConsumerGroupsWithOptions(options) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeConsumerGroupsContext(ctx context.Context, groups []string) ([]*GroupDescription, error) {
+	var (
+		result []*GroupDescription
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeConsumerGroups(groups) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListConsumerGroupOffsetsContext(ctx context.Context, group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error) {
+	var (
+		result *OffsetFetchResponse
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListConsumerGroupOffsets(group, topicPartitions) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) GroupLagContext(ctx context.Context, group string) (*GroupLag, error) {
+	var (
+		result *GroupLag
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.GroupLag(group) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DeleteConsumerGroupOffsetContext(ctx context.Context, group string, topic string, partition int32) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.DeleteConsumerGroupOffset(group, topic, partition) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DeleteConsumerGroupOffsetsContext(ctx context.Context, group string, topicPartitions map[string][]int32) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.DeleteConsumerGroupOffsets(group, topicPartitions) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DeleteConsumerGroupContext(ctx context.Context, group string) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.DeleteConsumerGroup(group) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DescribeClusterContext(ctx context.Context) ([]*Broker, int32, error) {
+	var (
+		brokers      []*Broker
+		controllerID int32
+		err          error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { brokers, controllerID, err = ca.DescribeCluster() }); ctxErr != nil {
+		return nil, 0, ctxErr
+	}
+	return brokers, controllerID, err
+}
+
+func (ca *clusterAdmin) DescribeClusterInfoContext(ctx context.Context, includeAuthorizedOperations bool) (*ClusterDescription, error) {
+	var (
+		result *ClusterDescription
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeClusterInfo(includeAuthorizedOperations) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) UnregisterBrokerContext(ctx context.Context, brokerID int32) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.UnregisterBroker(brokerID) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DescribeQuorumContext(ctx context.Context) (*QuorumInfo, error) {
+	var (
+		result *QuorumInfo
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeQuorum() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) HealthReportContext(ctx context.Context) (*ClusterHealthReport, error) {
+	var (
+		result *ClusterHealthReport
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.HealthReport() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ClusterSnapshotContext(ctx context.Context) (*ClusterSnapshot, error) {
+	var (
+		result *ClusterSnapshot
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ClusterSnapshot() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeLogDirsContext(ctx context.Context, brokers []int32) (map[int32][]DescribeLogDirsResponseDirMetadata, error) {
+	var (
+		result map[int32][]DescribeLogDirsResponseDirMetadata
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeLogDirs(brokers) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeLogDirUsageContext(ctx context.Context, brokers []int32) (*LogDirUsageReport, error) {
+	var (
+		result *LogDirUsageReport
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeLogDirUsage(brokers) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) AlterReplicaLogDirsContext(ctx context.Context, brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]KError, error) {
+	var (
+		result map[string]map[int32]KError
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.AlterReplicaLogDirs(brokerID, moves) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) VerifyReplicaLogDirsContext(ctx context.Context, brokerID int32, moves map[string]map[int32]string) (map[string]map[int32]string, error) {
+	var (
+		result map[string]map[int32]string
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.VerifyReplicaLogDirs(brokerID, moves) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeUserScramCredentialsContext(ctx context.Context, users []string) ([]*DescribeUserScramCredentialsResult, error) {
+	var (
+		result []*DescribeUserScramCredentialsResult
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeUserScramCredentials(users) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DeleteUserScramCredentialsContext(ctx context.Context, delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error) {
+	var (
+		result []*AlterUserScramCredentialsResult
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DeleteUserScramCredentials(delete) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) UpsertUserScramCredentialsContext(ctx context.Context, upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error) {
+	var (
+		result []*AlterUserScramCredentialsResult
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.UpsertUserScramCredentials(upsert) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) CreateDelegationTokenContext(ctx context.Context, renewers []DelegationTokenPrincipal, maxLifetime time.Duration) (*DelegationToken, error) {
+	var (
+		result *DelegationToken
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.CreateDelegationToken(renewers, maxLifetime) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) RenewDelegationTokenContext(ctx context.Context, hmac []byte, renewPeriod time.Duration) (time.Time, error) {
+	var (
+		result time.Time
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.RenewDelegationToken(hmac, renewPeriod) }); ctxErr != nil {
+		return time.Time{}, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ExpireDelegationTokenContext(ctx context.Context, hmac []byte, expiryPeriod time.Duration) (time.Time, error) {
+	var (
+		result time.Time
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ExpireDelegationToken(hmac, expiryPeriod) }); ctxErr != nil {
+		return time.Time{}, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeDelegationTokenContext(ctx context.Context, owners []DelegationTokenPrincipal) ([]DelegationToken, error) {
+	var (
+		result []DelegationToken
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeDelegationToken(owners) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeProducersContext(ctx context.Context, topic string, partitions []int32) (map[int32][]ProducerState, error) {
+	var (
+		result map[int32][]ProducerState
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeProducers(topic, partitions) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) DescribeTransactionsContext(ctx context.Context, transactionalIDs []string) ([]*TransactionDescription, error) {
+	var (
+		result []*TransactionDescription
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeTransactions(transactionalIDs) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListTransactionsContext(ctx context.Context, states []string, producerIDs []int64) ([]TransactionListing, error) {
+	var (
+		result []TransactionListing
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListTransactions(states, producerIDs) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) ListTransactionsWithOptionsContext(ctx context.Context, options ListTransactionsOptions) ([]TransactionListing, error) {
+	var (
+		result []TransactionListing
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.ListTransactionsWithOptions(options) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) AbortTransactionContext(ctx context.Context, topic string, partition int32, producer ProducerState) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.AbortTransaction(topic, partition, producer) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) DescribeClientQuotasContext(ctx context.Context, components []QuotaFilterComponent, strict bool) ([]DescribeClientQuotasEntry, error) {
+	var (
+		result []DescribeClientQuotasEntry
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.DescribeClientQuotas(components, strict) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) AlterClientQuotasContext(ctx context.Context, entity []QuotaEntityComponent, op ClientQuotasOp, validateOnly bool) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.AlterClientQuotas(entity, op, validateOnly) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (ca *clusterAdmin) ControllerContext(ctx context.Context) (*Broker, error) {
+	var (
+		result *Broker
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.Controller() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) RemoveMemberFromConsumerGroupContext(ctx context.Context, groupId string, groupInstanceIds []string) (*LeaveGroupResponse, error) {
+	var (
+		result *LeaveGroupResponse
+		err    error
+	)
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { result, err = ca.RemoveMemberFromConsumerGroup(groupId, groupInstanceIds) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+func (ca *clusterAdmin) RemoveMembersFromConsumerGroupContext(ctx context.Context, group string, groupInstanceIDs []string) error {
+	var err error
+	if ctxErr := ca.runContext(ctx, func(ca *clusterAdmin) { err = ca.RemoveMembersFromConsumerGroup(group, groupInstanceIDs) }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
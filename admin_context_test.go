@@ -0,0 +1,201 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClusterAdminCreateTopicContext(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_2_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	err = admin.CreateTopicContext(context.Background(), "my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = admin.CreateTopicContext(ctx, "my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if n := len(seedBroker.History()); n != 2 {
+		t.Errorf("Expected no request once the context is cancelled, got %d requests", n)
+	}
+}
+
+func TestClusterAdminCreateTopicContextDeadline(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockWrapper(&CreateTopicsResponse{
+			TopicErrors: map[string]*TopicError{"my_topic": {Err: ErrNotController}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_2_0
+	config.Admin.Retry.Max = 5
+	config.Admin.Retry.Backoff = 200 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = admin.CreateTopicContext(ctx, "my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// the abandoned operation does not retry once the context is done
+	time.Sleep(500 * time.Millisecond)
+	var requests []*CreateTopicsRequest
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*CreateTopicsRequest); ok {
+			requests = append(requests, req)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("Expected a single CreateTopics request, got %d", len(requests))
+	}
+	if timeout := requests[0].Timeout; timeout <= 0 || timeout > 100*time.Millisecond {
+		t.Errorf("Expected the timeout sent to be capped by the deadline, got %v", timeout)
+	}
+}
+
+func TestClusterAdminListTopicsContextDeadline(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	seedBroker.SetLatency(500 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	topics, err := admin.ListTopicsContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if topics != nil {
+		t.Errorf("Expected no topics, got %v", topics)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("Expected to return at the deadline, took %v", elapsed)
+	}
+}
+
+func TestClusterAdminWaitForPartitionReassignmentsContext(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	polls := 0
+	err = admin.WaitForPartitionReassignmentsContext(ctx, "my_topic", []int32{0}, time.Hour, 2*time.Hour, func(ongoing map[int32]*PartitionReplicaReassignmentsStatus) {
+		polls++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if polls != 1 {
+		t.Errorf("Expected a single poll, got %d", polls)
+	}
+}
+
+func TestClusterAdminCloneTopicContextAbandoned(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	describeConfigs := &slowDescribeConfigsResponse{slow: "src", started: make(chan none), release: make(chan none)}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("src", 0, seedBroker.BrokerID()),
+		"DescribeConfigsRequest": describeConfigs,
+		"CreateTopicsRequest":    NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-describeConfigs.started
+		cancel()
+	}()
+	err = admin.CloneTopicContext(ctx, "src", "dst", nil, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// the abandoned operation does not move on to creating the topic once its request completes
+	close(describeConfigs.release)
+	time.Sleep(200 * time.Millisecond)
+	for _, rr := range seedBroker.History() {
+		if _, ok := rr.Request.(*CreateTopicsRequest); ok {
+			t.Fatal("Expected no CreateTopics request once the context is cancelled")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -79,6 +80,176 @@ func TestClusterAdminCreateTopic(t *testing.T) {
 	}
 }
 
+func TestClusterAdminCloneTopic(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("src", 0, seedBroker.BrokerID()).
+			SetLeader("src", 1, seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+		"CreateTopicsRequest":    NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	compact := "compact"
+	for _, preserveAssignment := range []bool{false, true} {
+		err = admin.CloneTopic("src", "dst", map[string]*string{"cleanup.policy": &compact}, preserveAssignment)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var request *CreateTopicsRequest
+		for _, rr := range seedBroker.History() {
+			if r, ok := rr.Request.(*CreateTopicsRequest); ok {
+				request = r
+			}
+		}
+		if request == nil {
+			t.Fatal("no CreateTopicsRequest sent")
+		}
+		detail := request.TopicDetails["dst"]
+		if detail == nil {
+			t.Fatal("dst not created")
+		}
+		if len(detail.ConfigEntries) != 2 || *detail.ConfigEntries["retention.ms"] != "5000" || *detail.ConfigEntries["cleanup.policy"] != "compact" {
+			t.Errorf("unexpected configs %v", detail.ConfigEntries)
+		}
+		if preserveAssignment {
+			expected := map[int32][]int32{0: {1}, 1: {1}}
+			if detail.NumPartitions != -1 || detail.ReplicationFactor != -1 || !reflect.DeepEqual(detail.ReplicaAssignment, expected) {
+				t.Errorf("unexpected detail %+v", detail)
+			}
+		} else if detail.NumPartitions != 2 || detail.ReplicationFactor != 1 || len(detail.ReplicaAssignment) != 0 {
+			t.Errorf("unexpected detail %+v", detail)
+		}
+	}
+
+	if err := admin.CloneTopic("unknown", "dst", nil, false); !errors.Is(err, ErrUnknownTopicOrPartition) {
+		t.Errorf("expected ErrUnknownTopicOrPartition, got %v", err)
+	}
+}
+
+func TestClusterAdminApplyTopicSpec(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeader("my_topic", 1, seedBroker.BrokerID()),
+		"DescribeConfigsRequest":  NewMockDescribeConfigsResponse(t),
+		"AlterConfigsRequest":     NewMockAlterConfigsResponse(t),
+		"CreatePartitionsRequest": NewMockCreatePartitionsResponse(t),
+		"CreateTopicsRequest":     NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	spec := TopicSpec{
+		Name:              "my_topic",
+		NumPartitions:     3,
+		ReplicationFactor: 1,
+		ConfigEntries:     map[string]string{"retention.ms": "6000", "password": "12345"},
+	}
+	diff, err := admin.ApplyTopicSpec(spec, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.NumPartitions != 3 || diff.ReplicationFactor != 0 || !reflect.DeepEqual(diff.SetConfigs, map[string]string{"retention.ms": "6000"}) || len(diff.DeleteConfigs) != 0 {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+	for _, rr := range seedBroker.History() {
+		switch rr.Request.(type) {
+		case *AlterConfigsRequest, *CreatePartitionsRequest:
+			t.Errorf("Unexpected %T in dry-run mode", rr.Request)
+		}
+	}
+
+	if _, err := admin.ApplyTopicSpec(spec, false); err != nil {
+		t.Fatal(err)
+	}
+	var alter *AlterConfigsRequest
+	var create *CreatePartitionsRequest
+	for _, rr := range seedBroker.History() {
+		switch r := rr.Request.(type) {
+		case *AlterConfigsRequest:
+			alter = r
+		case *CreatePartitionsRequest:
+			create = r
+		}
+	}
+	if alter == nil || len(alter.Resources) != 1 || len(alter.Resources[0].ConfigEntries) != 2 {
+		t.Errorf("Expected the configs of the spec to be altered, got %+v", alter)
+	}
+	if create == nil || create.TopicPartitions["my_topic"].Count != 3 {
+		t.Errorf("Expected the topic to be increased to 3 partitions, got %+v", create)
+	}
+
+	spec.NumPartitions = 1
+	diff, err = admin.ApplyTopicSpec(spec, false)
+	if !errors.Is(err, ErrTopicSpecConflict) || len(diff.Conflicts) != 1 {
+		t.Errorf("Expected a conflict decreasing the number of partitions, got %v", err)
+	}
+
+	diff, err = admin.ApplyTopicSpec(TopicSpec{Name: "new_topic", NumPartitions: 1, ReplicationFactor: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Create {
+		t.Errorf("Expected the topic to be created, got %+v", diff)
+	}
+}
+
+func TestClusterAdminGenerateReplicaAssignment(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	assignment, err := admin.GenerateReplicaAssignment(2, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int32{{1}, {1}}; !reflect.DeepEqual(assignment, expected) {
+		t.Errorf("Expected %v, got %v", expected, assignment)
+	}
+
+	if _, err := admin.GenerateReplicaAssignment(2, 2, nil); !errors.Is(err, ErrInvalidReplicationFactor) {
+		t.Errorf("Expected ErrInvalidReplicationFactor, got %v", err)
+	}
+}
+
 func TestClusterAdminCreateTopicWithInvalidTopicDetail(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -543,6 +714,76 @@ func TestClusterAdminDeleteRecords(t *testing.T) {
 	}
 }
 
+func TestClusterAdminDeleteRecordsOfTopics(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()).
+			SetLeader("my_topic", 0, 1).
+			SetLeader("my_topic", 1, 2).
+			SetLeader("other_topic", 0, 1).
+			SetLeader("other_topic", 1, -1),
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"DeleteRecordsRequest": NewMockWrapper(&DeleteRecordsResponse{
+			Topics: map[string]*DeleteRecordsResponseTopic{
+				"my_topic": {Partitions: map[int32]*DeleteRecordsResponsePartition{
+					1: {LowWatermark: -1, Err: ErrOffsetOutOfRange},
+				}},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	results, err := admin.DeleteRecordsOfTopics(map[string]map[int32]int64{
+		"my_topic":    {0: 100, 1: 1000},
+		"other_topic": {0: 50, 1: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[int32]*DeleteRecordsResult{
+		"my_topic": {
+			0: {LowWatermark: 100, Err: ErrNoError},
+			1: {LowWatermark: -1, Err: ErrOffsetOutOfRange},
+		},
+		"other_topic": {
+			0: {LowWatermark: 50, Err: ErrNoError},
+			1: {LowWatermark: -1, Err: ErrLeaderNotAvailable},
+		},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+
+	requests := 0
+	for _, rr := range seedBroker.History() {
+		if request, ok := rr.Request.(*DeleteRecordsRequest); ok {
+			requests++
+			if len(request.Topics) != 2 {
+				t.Errorf("Expected the partitions of both topics in a single request, got %v", request.Topics)
+			}
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 DeleteRecordsRequest to the first broker, got %d", requests)
+	}
+}
+
 func TestClusterAdminDeleteRecordsWithInCorrectBroker(t *testing.T) {
 	topicName := "my_topic"
 	seedBroker := NewMockBroker(t, 1)
@@ -689,6 +930,104 @@ func TestClusterAdminDeleteRecordsWithLeaderNotAvailable(t *testing.T) {
 	}
 }
 
+func TestClusterAdminHealthReport(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	metadata := &MetadataResponse{Version: 5, ControllerID: seedBroker.BrokerID()}
+	metadata.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadata.AddTopicPartition("my_topic", 0, 1, []int32{1, 2}, []int32{1}, nil, ErrNoError)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockWrapper(metadata),
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Version: 1,
+			Resources: []*ResourceResponse{{
+				Type:    TopicResource,
+				Name:    "my_topic",
+				Configs: []*ConfigEntry{{Name: "min.insync.replicas", Value: "2", Source: SourceTopic}},
+			}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	report, err := admin.HealthReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.ControllerReachable || report.ControllerID != 1 {
+		t.Errorf("Expected the controller to be reachable, got %+v", report)
+	}
+	if !reflect.DeepEqual(report.MissingBrokers, []int32{2}) {
+		t.Errorf("Expected broker 2 to be missing, got %v", report.MissingBrokers)
+	}
+	if report.UnderReplicatedPartitions != 1 || report.UnderMinIsrPartitions != 1 || report.OfflinePartitions != 0 {
+		t.Errorf("Unexpected partitions %+v", report.Partitions)
+	}
+	if report.Healthy() {
+		t.Error("Expected an unhealthy cluster")
+	}
+
+	for _, rr := range seedBroker.History() {
+		if request, ok := rr.Request.(*DescribeConfigsRequest); ok {
+			if len(request.Resources) != 1 || !reflect.DeepEqual(request.Resources[0].ConfigNames, []string{"min.insync.replicas"}) {
+				t.Errorf("Unexpected resources %+v", request.Resources)
+			}
+		}
+	}
+}
+
+func TestClusterAdminClusterSnapshot(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	snapshot, err := admin.ClusterSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ControllerID != 1 || snapshot.Brokers[1].Addr != seedBroker.Addr() {
+		t.Errorf("Unexpected snapshot %+v", snapshot)
+	}
+	topic := snapshot.Topics["my_topic"]
+	if topic == nil || topic.Partitions[0] == nil || topic.Partitions[0].Leader != 1 {
+		t.Fatalf("Unexpected topic %+v", topic)
+	}
+	// the default and sensitive configs are left out
+	if !reflect.DeepEqual(topic.Configs, map[string]string{"retention.ms": "5000"}) {
+		t.Errorf("Unexpected configs %v", topic.Configs)
+	}
+
+	again, err := admin.ClusterSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := snapshot.Diff(again); !diff.Empty() {
+		t.Errorf("Expected no change, got %+v", diff)
+	}
+}
+
 func TestClusterAdminDescribeConfig(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1102,39 +1441,77 @@ func TestClusterAdminIncrementalAlterBrokerConfig(t *testing.T) {
 	}
 }
 
-func TestClusterAdminCreateAcl(t *testing.T) {
+func TestClusterAdminBrokerLoggers(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"CreateAclsRequest": NewMockCreateAclsResponse(t),
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Version: 2,
+			Resources: []*ResourceResponse{{
+				Type: BrokerLoggerResource,
+				Name: "1",
+				Configs: []*ConfigEntry{
+					{Name: "root", Value: "INFO", Source: SourceDefault},
+					{Name: "kafka.controller", Value: "DEBUG", Source: SourceDynamicBroker},
+				},
+			}},
+		}),
+		"IncrementalAlterConfigsRequest": NewMockIncrementalAlterConfigsResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_4_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
-	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
-
-	err = admin.CreateACL(r, a)
+	levels, err := admin.DescribeBrokerLoggers(1)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !reflect.DeepEqual(levels, map[string]string{"root": "INFO", "kafka.controller": "DEBUG"}) {
+		t.Errorf("Unexpected levels %v", levels)
+	}
 
-	err = admin.Close()
+	err = admin.AlterBrokerLoggers(1, map[string]string{"kafka.controller": "trace", "kafka.log": ""}, false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	var cerr ConfigurationError
+	if err := admin.AlterBrokerLoggers(1, map[string]string{"kafka.controller": "VERBOSE"}, false); !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError for an invalid level, got %v", err)
+	}
+
+	for _, rr := range seedBroker.History() {
+		switch request := rr.Request.(type) {
+		case *DescribeConfigsRequest:
+			if request.Resources[0].Type != BrokerLoggerResource || request.Resources[0].Name != "1" {
+				t.Errorf("Unexpected resource %+v", request.Resources[0])
+			}
+		case *IncrementalAlterConfigsRequest:
+			resource := request.Resources[0]
+			if resource.Type != BrokerLoggerResource || resource.Name != "1" {
+				t.Errorf("Unexpected resource %+v", resource)
+			}
+			set := resource.ConfigEntries["kafka.controller"]
+			if set.Operation != IncrementalAlterConfigsOperationSet || set.Value == nil || *set.Value != "TRACE" {
+				t.Errorf("Expected kafka.controller to be set to TRACE, got %+v", set)
+			}
+			if reset := resource.ConfigEntries["kafka.log"]; reset.Operation != IncrementalAlterConfigsOperationDelete {
+				t.Errorf("Expected kafka.log to be reset, got %+v", reset)
+			}
+		}
+	}
 }
 
-func TestClusterAdminCreateAclErrorHandling(t *testing.T) {
+func TestClusterAdminBrokerLoggersVersion(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -1142,31 +1519,81 @@ func TestClusterAdminCreateAclErrorHandling(t *testing.T) {
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"CreateAclsRequest": NewMockCreateAclsResponseWithError(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_3_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
-	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
-
-	err = admin.CreateACL(r, a)
-	if err == nil {
-		t.Fatal(errors.New("error should have been thrown"))
+	var cerr ConfigurationError
+	if _, err := admin.DescribeBrokerLoggers(1); !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+	if err := admin.AlterBrokerLoggers(1, map[string]string{"root": "DEBUG"}, false); !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
 	}
+}
 
-	err = admin.Close()
+func TestClusterAdminPreviewACLResources(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("orders", 0, seedBroker.BrokerID()).
+			SetLeader("orders.audit", 0, seedBroker.BrokerID()).
+			SetLeader("payments", 0, seedBroker.BrokerID()),
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroup("orders-consumer", "consumer").
+			AddGroup("payments-consumer", "consumer"),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
+
+	for _, tc := range []struct {
+		resource Resource
+		expected []string
+	}{
+		{Resource{ResourceType: AclResourceTopic, ResourceName: "orders", ResourcePatternType: AclPatternPrefixed}, []string{"orders", "orders.audit"}},
+		{Resource{ResourceType: AclResourceTopic, ResourceName: "orders"}, []string{"orders"}},
+		{Resource{ResourceType: AclResourceTopic, ResourceName: "*", ResourcePatternType: AclPatternLiteral}, []string{"orders", "orders.audit", "payments"}},
+		{Resource{ResourceType: AclResourceTopic, ResourceName: "refunds", ResourcePatternType: AclPatternPrefixed}, nil},
+		{Resource{ResourceType: AclResourceGroup, ResourceName: "payments", ResourcePatternType: AclPatternPrefixed}, []string{"payments-consumer"}},
+		{Resource{ResourceType: AclResourceCluster, ResourceName: "kafka-cluster"}, []string{"kafka-cluster"}},
+	} {
+		names, err := admin.PreviewACLResources(tc.resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("Expected %v to match %v, got %v", tc.resource, tc.expected, names)
+		}
+	}
+
+	var cerr ConfigurationError
+	_, err = admin.PreviewACLResources(Resource{ResourceType: AclResourceTopic, ResourceName: "orders", ResourcePatternType: AclPatternMatch})
+	if !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError for a match pattern, got %v", err)
+	}
+	_, err = admin.PreviewACLResources(Resource{ResourceType: AclResourceTransactionalID, ResourceName: "*"})
+	if !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError for transactional IDs before 3.0.0.0, got %v", err)
+	}
 }
 
-func TestClusterAdminCreateAcls(t *testing.T) {
+func TestClusterAdminCreateAcl(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -1184,10 +1611,74 @@ func TestClusterAdminCreateAcls(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	rACLs := []*ResourceAcls{
-		{
-			Resource: Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"},
-			Acls: []*Acl{
+	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
+	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
+
+	err = admin.CreateACL(r, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminCreateAclErrorHandling(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateAclsRequest": NewMockCreateAclsResponseWithError(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
+	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
+
+	err = admin.CreateACL(r, a)
+	if err == nil {
+		t.Fatal(errors.New("error should have been thrown"))
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminCreateAcls(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateAclsRequest": NewMockCreateAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rACLs := []*ResourceAcls{
+		{
+			Resource: Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"},
+			Acls: []*Acl{
 				{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny},
 			},
 		},
@@ -1257,6 +1748,78 @@ func TestClusterAdminListAcls(t *testing.T) {
 	}
 }
 
+func TestClusterAdminSyncACLs(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeAclsRequest": NewMockListAclsResponse(t),
+		"CreateAclsRequest":   NewMockCreateAclsResponse(t),
+		"DeleteAclsRequest":   NewMockDeleteAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	// the mock lists an ACL of User:test on every resource
+	desired := []ResourceAcls{{
+		Resource: Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"},
+		Acls: []*Acl{
+			{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow},
+			{Principal: "User:test", Host: "*", Operation: AclOperationAny, PermissionType: AclPermissionAllow},
+		},
+	}, {
+		Resource: Resource{ResourceType: AclResourceGroup, ResourceName: "my_group"},
+		Acls: []*Acl{
+			{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow},
+		},
+	}}
+
+	diff, err := admin.SyncACLs(desired, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Create) != 2 || len(diff.Delete) != 1 {
+		t.Fatalf("Expected 2 resources with ACLs to create and 1 with ACLs to delete, got %+v", diff)
+	}
+	if d := diff.Delete[0]; d.ResourceName != "my_group" || d.Acls[0].Principal != "User:test" {
+		t.Errorf("Expected the ACL of User:test on my_group to be deleted, got %+v", d)
+	}
+	for _, rr := range seedBroker.History() {
+		switch rr.Request.(type) {
+		case *CreateAclsRequest, *DeleteAclsRequest:
+			t.Fatalf("Expected no change in dry-run mode, got %T", rr.Request)
+		}
+	}
+
+	if _, err := admin.SyncACLs(desired, false); err != nil {
+		t.Fatal(err)
+	}
+	var creations, deletions int
+	for _, rr := range seedBroker.History() {
+		switch req := rr.Request.(type) {
+		case *CreateAclsRequest:
+			creations += len(req.AclCreations)
+		case *DeleteAclsRequest:
+			deletions += len(req.Filters)
+			if f := req.Filters[0]; *f.ResourceName != "my_group" || *f.Principal != "User:test" || f.Operation != AclOperationAny {
+				t.Errorf("Unexpected delete filter %+v", f)
+			}
+		}
+	}
+	if creations != 2 || deletions != 1 {
+		t.Errorf("Expected 2 ACLs to be created and 1 to be deleted, got %d and %d", creations, deletions)
+	}
+}
+
 func TestClusterAdminDeleteAcl(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1568,6 +2131,211 @@ func TestListConsumerGroupOffsets(t *testing.T) {
 	}
 }
 
+func TestClusterAdminGroupLag(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my-group"
+	assignment, err := encode(&ConsumerGroupMemberAssignment{
+		Topics: map[string][]int32{"my-topic": {0, 1}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my-topic", 0, seedBroker.BrokerID()).
+			SetLeader("my-topic", 1, seedBroker.BrokerID()).
+			SetLeader("my-topic", 2, seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+		"DescribeGroupsRequest": NewMockDescribeGroupsResponse(t).AddGroupDescription(group, &GroupDescription{
+			GroupId:      group,
+			State:        "Stable",
+			ProtocolType: "consumer",
+			Members: map[string]*GroupMemberDescription{
+				"member-1": {
+					MemberId:         "member-1",
+					ClientId:         "client-1",
+					ClientHost:       "/127.0.0.1",
+					MemberAssignment: assignment,
+				},
+			},
+		}),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset(group, "my-topic", 0, 5, "meta", ErrNoError).
+			SetOffset(group, "my-topic", 2, 7, "", ErrNoError).
+			SetError(ErrNoError),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetNewest, 10).
+			SetOffset("my-topic", 1, OffsetNewest, 3).
+			SetOffset("my-topic", 2, OffsetNewest, 7),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	lag, err := admin.GroupLag(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.State != "Stable" || lag.Lag != 5 || len(lag.Topics) != 1 {
+		t.Fatalf("unexpected lag %+v", lag)
+	}
+	topic := lag.Topics["my-topic"]
+	if topic.Lag != 5 || !reflect.DeepEqual(topic.SortedPartitions(), []int32{0, 1, 2}) {
+		t.Fatalf("unexpected topic lag %+v", topic)
+	}
+
+	expected := map[int32]PartitionLag{
+		0: {Committed: 5, Metadata: "meta", End: 10, Lag: 5, MemberID: "member-1", ClientID: "client-1", ClientHost: "/127.0.0.1"},
+		1: {Committed: -1, End: 3, Lag: -1, MemberID: "member-1", ClientID: "client-1", ClientHost: "/127.0.0.1"},
+		2: {Committed: 7, End: 7, Lag: 0},
+	}
+	for partition, want := range expected {
+		if got := *topic.Partitions[partition]; got != want {
+			t.Errorf("partition %d: expected %+v, got %+v", partition, want, got)
+		}
+	}
+}
+
+func TestClusterAdminRemoveMembersFromConsumerGroup(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my-group"
+	removed, unknown := "instance-1", "instance-2"
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+		"LeaveGroupRequest": NewMockWrapper(&LeaveGroupResponse{
+			Version: 3,
+			Members: []MemberResponse{
+				{GroupInstanceId: &removed, Err: ErrNoError},
+				{GroupInstanceId: &unknown, Err: ErrUnknownMemberId},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	err = admin.RemoveMembersFromConsumerGroup(group, []string{removed, unknown})
+	if !errors.Is(err, ErrUnknownMemberId) || !strings.Contains(err.Error(), unknown) || strings.Contains(err.Error(), removed) {
+		t.Errorf("Expected ErrUnknownMemberId for %s, got %v", unknown, err)
+	}
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*LeaveGroupRequest); ok {
+			if req.GroupId != group || len(req.Members) != 2 || *req.Members[0].GroupInstanceId != removed || *req.Members[1].GroupInstanceId != unknown {
+				t.Errorf("Unexpected request %+v", req)
+			}
+		}
+	}
+}
+
+func TestListConsumerGroupsWithOptions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+	thirdBroker := NewMockBroker(t, 3)
+	defer thirdBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID()).
+		SetBroker(thirdBroker.Addr(), thirdBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest":    metadata,
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroupWithState("b-group", "consumer", "Stable", "classic").
+			AddGroupWithState("empty-group", "consumer", "Empty", "classic"),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroupWithState("a-group", "consumer", "Stable", "consumer"),
+	})
+	thirdBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"ListGroupsRequest":  NewMockWrapper(&ListGroupsResponse{Version: 5, Err: ErrOffsetsLoadInProgress}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_8_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	report, err := admin.ListConsumerGroupsWithOptions(ListConsumerGroupsOptions{States: []string{"Stable"}})
+	if !errors.Is(err, ErrOffsetsLoadInProgress) {
+		t.Fatalf("Expected the error of the third broker, got %v", err)
+	}
+	expected := []ConsumerGroupListing{
+		{GroupID: "a-group", ProtocolType: "consumer", State: "Stable", Type: "consumer", CoordinatorID: 2},
+		{GroupID: "b-group", ProtocolType: "consumer", State: "Stable", Type: "classic", CoordinatorID: 1},
+	}
+	if !reflect.DeepEqual(report.Groups, expected) {
+		t.Errorf("Expected groups %+v, got %+v", expected, report.Groups)
+	}
+	if len(report.BrokerErrors) != 1 || !errors.Is(report.BrokerErrors[3], ErrOffsetsLoadInProgress) {
+		t.Errorf("Expected an error for the third broker, got %v", report.BrokerErrors)
+	}
+
+	for _, rr := range seedBroker.History() {
+		if request, ok := rr.Request.(*ListGroupsRequest); ok {
+			if request.Version != 5 || !reflect.DeepEqual(request.StatesFilter, []string{"Stable"}) {
+				t.Errorf("Unexpected request %+v", request)
+			}
+		}
+	}
+}
+
+func TestListConsumerGroupsWithOptionsVersion(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var cerr ConfigurationError
+	if _, err := admin.ListConsumerGroupsWithOptions(ListConsumerGroupsOptions{States: []string{"Stable"}}); !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError for the state filter, got %v", err)
+	}
+	if _, err := admin.ListConsumerGroupsWithOptions(ListConsumerGroupsOptions{Types: []string{"consumer"}}); !errors.As(err, &cerr) {
+		t.Errorf("Expected a ConfigurationError for the type filter, got %v", err)
+	}
+}
+
 func TestDeleteConsumerGroup(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1598,6 +2366,81 @@ func TestDeleteConsumerGroup(t *testing.T) {
 	}
 }
 
+func TestDeleteConsumerGroupRetriesLoadingCoordinator(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my-group"
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"DeleteGroupsRequest": NewMockSequence(
+			NewMockWrapper(&DeleteGroupsResponse{GroupErrorCodes: map[string]KError{group: ErrOffsetsLoadInProgress}}),
+			NewMockWrapper(&DeleteGroupsResponse{GroupErrorCodes: map[string]KError{group: ErrNotCoordinatorForConsumer}}),
+			NewMockWrapper(&DeleteGroupsResponse{GroupErrorCodes: map[string]KError{group: ErrNoError}}),
+		),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	config.Admin.Retry.Backoff = time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if err := admin.DeleteConsumerGroup(group); err != nil {
+		t.Fatalf("DeleteConsumerGroup failed with error %v", err)
+	}
+
+	var deletes, lookups int
+	for _, rr := range seedBroker.History() {
+		switch rr.Request.(type) {
+		case *DeleteGroupsRequest:
+			deletes++
+		case *FindCoordinatorRequest:
+			lookups++
+		}
+	}
+	if deletes != 3 {
+		t.Errorf("Expected 3 DeleteGroupsRequests, got %d", deletes)
+	}
+	// the coordinator is only looked up again once it moved, not while it is loading
+	if lookups != 2 {
+		t.Errorf("Expected 2 FindCoordinatorRequests, got %d", lookups)
+	}
+}
+
+func TestClusterAdminRetryBackoff(t *testing.T) {
+	config := NewTestConfig()
+	admin := &clusterAdmin{conf: config}
+
+	expected := []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+		800 * time.Millisecond, time.Second, time.Second,
+	}
+	for retries, backoff := range expected {
+		if actual := admin.computeBackoff(retries); actual != backoff {
+			t.Errorf("Expected a backoff of %v before retry %d, got %v", backoff, retries, actual)
+		}
+	}
+
+	config.Admin.Retry.MaxBackoff = 0
+	if actual := admin.computeBackoff(3); actual != 100*time.Millisecond {
+		t.Errorf("Expected a constant backoff without MaxBackoff, got %v", actual)
+	}
+
+	config.Admin.Retry.BackoffFunc = func(retries, maxRetries int) time.Duration {
+		return time.Duration(retries*maxRetries) * time.Millisecond
+	}
+	if actual := admin.computeBackoff(2); actual != 10*time.Millisecond {
+		t.Errorf("Expected the backoff of BackoffFunc, got %v", actual)
+	}
+}
+
 func TestDeleteOffset(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1649,8 +2492,168 @@ func TestDeleteOffset(t *testing.T) {
 	}
 }
 
-// TestRefreshMetaDataWithDifferentController ensures that the cached
-// controller can be forcibly updated from Metadata by the admin client
+func TestClusterAdminListOffsets(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 2)
+	defer leader.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(leader.Addr(), leader.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID()).
+		SetLeader("my_topic", 1, leader.BrokerID()).
+		SetLeader("other_topic", 0, leader.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 10),
+	})
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 1, OffsetNewest, 200).
+			SetOffset("other_topic", 0, 1234, 42),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	config.Consumer.IsolationLevel = ReadCommitted
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	results, err := admin.ListOffsets(map[string]map[int32]int64{
+		"my_topic":    {0: OffsetOldest, 1: OffsetNewest},
+		"other_topic": {0: 1234},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []struct {
+		topic     string
+		partition int32
+		offset    int64
+	}{{"my_topic", 0, 10}, {"my_topic", 1, 200}, {"other_topic", 0, 42}} {
+		result := results[expected.topic][expected.partition]
+		if result == nil || result.Err != ErrNoError || result.Offset != expected.offset {
+			t.Errorf("Expected offset %d for %s/%d, got %+v", expected.offset, expected.topic, expected.partition, result)
+		}
+	}
+
+	var requests int
+	for _, b := range []*MockBroker{seedBroker, leader} {
+		for _, rr := range b.History() {
+			if req, ok := rr.Request.(*OffsetRequest); ok {
+				requests++
+				if req.Version != 2 || req.IsolationLevel != ReadCommitted {
+					t.Errorf("Expected a read committed request V2, got %+v", req)
+				}
+			}
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected a request per leader, got %d", requests)
+	}
+}
+
+func TestClusterAdminListOffsetsBrokerFailure(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 2)
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(leader.Addr(), leader.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID()).
+		SetLeader("my_topic", 1, leader.BrokerID()).
+		SetLeader("my_topic", 2, leader.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 10),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+	leader.Close()
+
+	results, err := admin.ListOffsets(map[string]map[int32]int64{
+		"my_topic": {0: OffsetNewest, 1: OffsetNewest, 2: OffsetNewest},
+	})
+	if err == nil {
+		t.Fatal("Expected the failure of the request to the leader to be returned")
+	}
+	if result := results["my_topic"][0]; result == nil || result.Err != ErrNoError || result.Offset != 10 {
+		t.Errorf("Expected offset 10 for my_topic/0, got %+v", result)
+	}
+	for _, partition := range []int32{1, 2} {
+		if result := results["my_topic"][partition]; result == nil || result.Err == nil || errors.Is(result.Err, ErrNoError) || result.Offset != -1 {
+			t.Errorf("Expected the error of the request to the leader for my_topic/%d, got %+v", partition, result)
+		}
+	}
+}
+
+func TestDeleteConsumerGroupOffsets(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my_group"
+	handlerMap := map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset(group, "retired", 0, 10, "", ErrNoError).
+			SetOffset(group, "retired", 1, 20, "", ErrNoError).
+			SetOffset(group, "active", 0, 30, "", ErrNoError),
+		"DeleteOffsetsRequest": NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, "retired", 0, ErrNoError),
+	}
+	seedBroker.SetHandlerByMap(handlerMap)
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if err := admin.DeleteConsumerGroupOffsets(group, map[string][]int32{"retired": nil}); err != nil {
+		t.Fatal(err)
+	}
+
+	var deleted map[string][]int32
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*DeleteOffsetsRequest); ok {
+			deleted = req.partitions
+		}
+	}
+	if len(deleted) != 1 || len(deleted["retired"]) != 2 {
+		t.Errorf("Expected the offsets of the 2 partitions of the retired topic to be deleted, got %v", deleted)
+	}
+
+	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, "active", 0, ErrGroupSubscribedToTopic)
+	seedBroker.SetHandlerByMap(handlerMap)
+	err = admin.DeleteConsumerGroupOffsets(group, map[string][]int32{"active": {0}})
+	if !errors.Is(err, ErrGroupSubscribedToTopic) {
+		t.Errorf("Expected ErrGroupSubscribedToTopic, got %v", err)
+	}
+}
+
+// TestRefreshMetaDataWithDifferentController ensures that the cached
+// controller can be forcibly updated from Metadata by the admin client
 func TestRefreshMetaDataWithDifferentController(t *testing.T) {
 	seedBroker1 := NewMockBroker(t, 1)
 	seedBroker2 := NewMockBroker(t, 2)
@@ -1746,6 +2749,124 @@ func TestDescribeLogDirs(t *testing.T) {
 	}
 }
 
+func TestClusterAdminAlterReplicaLogDirs(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"AlterReplicaLogDirsRequest": NewMockWrapper(&AlterReplicaLogDirsResponse{
+			Version: 1,
+			Results: map[string]map[int32]KError{
+				"my_topic": {0: ErrNoError, 1: ErrNoError, 2: ErrLogDirNotFound},
+			},
+		}),
+		"DescribeLogDirsRequest": NewMockWrapper(&DescribeLogDirsResponse{
+			LogDirs: []DescribeLogDirsResponseDirMetadata{{
+				ErrorCode: ErrNoError,
+				Path:      "/data/1",
+				Topics: []DescribeLogDirsResponseTopic{{
+					Topic:      "my_topic",
+					Partitions: []DescribeLogDirsResponsePartition{{PartitionID: 1}},
+				}},
+			}, {
+				ErrorCode: ErrNoError,
+				Path:      "/data/2",
+				Topics: []DescribeLogDirsResponseTopic{{
+					Topic: "my_topic",
+					Partitions: []DescribeLogDirsResponsePartition{
+						{PartitionID: 0},
+						{PartitionID: 1, IsTemporary: true},
+					},
+				}},
+			}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	moves := map[string]map[int32]string{"my_topic": {0: "/data/2", 1: "/data/2", 2: "/data/3"}}
+	results, err := admin.AlterReplicaLogDirs(1, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[int32]KError{"my_topic": {0: ErrNoError, 1: ErrNoError, 2: ErrLogDirNotFound}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+
+	delete(moves["my_topic"], 2)
+	pending, err := admin.VerifyReplicaLogDirs(1, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the replica of partition 1 is still being copied to its destination
+	if !reflect.DeepEqual(pending, map[string]map[int32]string{"my_topic": {1: "/data/2"}}) {
+		t.Errorf("Unexpected pending moves %v", pending)
+	}
+
+	for _, rr := range seedBroker.History() {
+		if request, ok := rr.Request.(*AlterReplicaLogDirsRequest); ok {
+			if request.Version != 1 || len(request.Dirs) != 2 || len(request.Dirs["/data/2"]["my_topic"]) != 2 {
+				t.Errorf("Unexpected request %+v", request)
+			}
+		}
+	}
+}
+
+func TestDescribeLogDirUsage(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeLogDirsRequest": NewMockDescribeLogDirsResponse(t).
+			SetLogDirs("/tmp/logs", map[string]int{"topic1": 2, "topic2": 1}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	report, err := admin.DescribeLogDirUsage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Size != 3*1234 {
+		t.Errorf("Expected a total size of %d, got %d", 3*1234, report.Size)
+	}
+	broker := report.Brokers[seedBroker.BrokerID()]
+	if broker == nil || broker.Size != 3*1234 {
+		t.Fatalf("Expected broker %d to use %d bytes, got %+v", seedBroker.BrokerID(), 3*1234, broker)
+	}
+	dir := broker.LogDirs["/tmp/logs"]
+	if dir == nil || dir.Size != 3*1234 || dir.FutureSize != 0 {
+		t.Fatalf("Expected /tmp/logs to use %d bytes, got %+v", 3*1234, dir)
+	}
+	if size := report.TopicSize("topic1"); size != 2*1234 {
+		t.Errorf("Expected topic1 to use %d bytes, got %d", 2*1234, size)
+	}
+	if size := dir.Partitions["topic2"][0]; size != 1234 {
+		t.Errorf("Expected topic2/0 to use 1234 bytes, got %d", size)
+	}
+}
+
 func TestDescribeLogDirsUnknownBroker(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1790,3 +2911,1003 @@ func TestDescribeLogDirsUnknownBroker(t *testing.T) {
 		}
 	}
 }
+
+func TestClusterAdminValidatePartitionReassignments(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	metadata := &MetadataResponse{Version: 9, ControllerID: 1}
+	metadata.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadata.AddBroker(secondBroker.Addr(), secondBroker.BrokerID())
+	metadata.AddTopicPartition("my_topic", 0, 1, []int32{1}, []int32{1}, nil, ErrNoError)
+	metadata.AddTopicPartition("my_topic", 1, 2, []int32{2}, []int32{2}, nil, ErrNoError)
+	metadata.AddTopicPartition("my_topic", 2, 1, []int32{1, 2}, []int32{1, 2}, nil, ErrNoError)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest":    NewMockWrapper(metadata),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	plans, err := admin.ValidatePartitionReassignments("my_topic", [][]int32{{2}, nil, {2, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int32]*PartitionReassignmentPlan{
+		0: {Replicas: []int32{1}, TargetReplicas: []int32{2}, AddingReplicas: []int32{2}, RemovingReplicas: []int32{1}},
+		2: {Replicas: []int32{1, 2}, TargetReplicas: []int32{2, 1}},
+	}
+	if !reflect.DeepEqual(plans, expected) {
+		t.Errorf("Expected %v, got %v", expected, plans)
+	}
+
+	_, err = admin.ValidatePartitionReassignments("my_topic", [][]int32{{1, 1}, {3}, {}})
+	if !errors.Is(err, ErrReassignPartitions) || !errors.Is(err, ErrInvalidReplicaAssignment) {
+		t.Fatalf("Expected ErrInvalidReplicaAssignment, got %v", err)
+	}
+	for _, partition := range []string{"my_topic-0", "my_topic-1", "my_topic-2"} {
+		if !strings.Contains(err.Error(), partition) {
+			t.Errorf("Expected an error for %s, got %v", partition, err)
+		}
+	}
+
+	if _, err := admin.ValidatePartitionReassignments("my_topic", [][]int32{nil, nil, nil, {1}}); !errors.Is(err, ErrUnknownTopicOrPartition) {
+		t.Errorf("Expected ErrUnknownTopicOrPartition, got %v", err)
+	}
+
+	for _, rr := range seedBroker.History() {
+		if _, ok := rr.Request.(*AlterPartitionReassignmentsRequest); ok {
+			t.Error("Expected no reassignment to be started")
+		}
+	}
+}
+
+func TestClusterAdminThrottleReassignment(t *testing.T) {
+	brokers := []*MockBroker{NewMockBroker(t, 1), NewMockBroker(t, 2), NewMockBroker(t, 3)}
+	metadata := &MetadataResponse{Version: 9, ControllerID: 1}
+	for _, b := range brokers {
+		defer b.Close()
+		metadata.AddBroker(b.Addr(), b.BrokerID())
+	}
+	metadata.AddTopicPartition("my_topic", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, ErrNoError)
+	metadata.AddTopicPartition("my_topic", 1, 2, []int32{2, 1}, []int32{2, 1}, nil, ErrNoError)
+	for _, b := range brokers {
+		b.SetHandlerByMap(map[string]MockResponse{
+			"ApiVersionsRequest":             NewMockApiVersionsResponse(t),
+			"MetadataRequest":                NewMockWrapper(metadata),
+			"IncrementalAlterConfigsRequest": NewMockIncrementalAlterConfigsResponse(t),
+		})
+	}
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{brokers[0].Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	// partition 0 moves from brokers 1 and 2 to brokers 2 and 3, partition 1 is only reordered
+	if err := admin.ThrottleReassignment("my_topic", [][]int32{{2, 3}, {1, 2}}, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	topicConfigs := make(map[string]string)
+	throttledBrokers := make(map[string]string)
+	for _, b := range brokers {
+		for _, rr := range b.History() {
+			req, ok := rr.Request.(*IncrementalAlterConfigsRequest)
+			if !ok {
+				continue
+			}
+			for _, resource := range req.Resources {
+				switch resource.Type {
+				case TopicResource:
+					for name, entry := range resource.ConfigEntries {
+						if entry.Operation != IncrementalAlterConfigsOperationAppend {
+							t.Errorf("Expected %s to be appended to, got operation %d", name, entry.Operation)
+						}
+						topicConfigs[name] = *entry.Value
+					}
+				case BrokerResource:
+					throttledBrokers[resource.Name] = *resource.ConfigEntries[leaderThrottledRateConfig].Value
+				}
+			}
+		}
+	}
+
+	if v := topicConfigs[leaderThrottledReplicasConfig]; v != "0:1,0:2" {
+		t.Errorf("Expected the current replicas of partition 0 to be leader throttled, got %q", v)
+	}
+	if v := topicConfigs[followerThrottledReplicasConfig]; v != "0:3" {
+		t.Errorf("Expected the new replica of partition 0 to be follower throttled, got %q", v)
+	}
+	if len(throttledBrokers) != 3 || throttledBrokers["1"] != "1024" || throttledBrokers["3"] != "1024" {
+		t.Errorf("Expected the rate to be set on the 3 brokers, got %v", throttledBrokers)
+	}
+
+	if err := admin.ThrottleReassignment("my_topic", [][]int32{{1, 2}}, 0); err == nil {
+		t.Error("Expected an error for a zero rate")
+	}
+}
+
+func TestClusterAdminClearReassignmentThrottle(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	handlers := map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"IncrementalAlterConfigsRequest": NewMockIncrementalAlterConfigsResponse(t),
+	}
+	seedBroker.SetHandlerByMap(handlers)
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if err := admin.ClearReassignmentThrottle("my_topic"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := make(map[string]bool)
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*IncrementalAlterConfigsRequest); ok {
+			for _, resource := range req.Resources {
+				for name, entry := range resource.ConfigEntries {
+					if entry.Operation != IncrementalAlterConfigsOperationDelete {
+						t.Errorf("Expected %s to be deleted, got operation %d", name, entry.Operation)
+					}
+					deleted[resource.Name+"/"+name] = true
+				}
+			}
+		}
+	}
+	for _, name := range []string{
+		"my_topic/" + leaderThrottledReplicasConfig,
+		"my_topic/" + followerThrottledReplicasConfig,
+		"1/" + leaderThrottledRateConfig,
+		"1/" + followerThrottledRateConfig,
+	} {
+		if !deleted[name] {
+			t.Errorf("Expected %s to be deleted, got %v", name, deleted)
+		}
+	}
+}
+
+func TestClusterAdminWaitForPartitionReassignments(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListPartitionReassignmentsRequest": NewMockSequence(
+			NewMockListPartitionReassignmentsResponse(t),
+			NewMockWrapper(&ListPartitionReassignmentsResponse{}),
+		),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var polls []int
+	err = admin.WaitForPartitionReassignments("my_topic", []int32{0}, time.Millisecond, time.Second, func(ongoing map[int32]*PartitionReplicaReassignmentsStatus) {
+		polls = append(polls, len(ongoing))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polls) != 2 || polls[0] != 1 || polls[1] != 0 {
+		t.Errorf("Expected a poll with the partition reassigning then one without, got %v", polls)
+	}
+}
+
+func TestClusterAdminUserScramCredentials(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeUserScramCredentialsRequest": NewMockWrapper(&DescribeUserScramCredentialsResponse{
+			Results: []*DescribeUserScramCredentialsResult{{
+				User: "alice",
+				CredentialInfos: []*UserScramCredentialsResponseInfo{
+					{Mechanism: SCRAM_MECHANISM_SHA_512, Iterations: 8192},
+				},
+			}},
+		}),
+		"AlterUserScramCredentialsRequest": NewMockWrapper(&AlterUserScramCredentialsResponse{
+			Results: []*AlterUserScramCredentialsResult{{User: "alice"}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_7_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	described, err := admin.DescribeUserScramCredentials([]string{"alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(described) != 1 || described[0].User != "alice" || described[0].CredentialInfos[0].Iterations != 8192 {
+		t.Errorf("Unexpected credentials %+v", described)
+	}
+
+	upsert := []AlterUserScramCredentialsUpsert{{
+		Name:      "alice",
+		Mechanism: SCRAM_MECHANISM_SHA_512,
+		Password:  []byte("secret"),
+	}}
+	results, err := admin.UpsertUserScramCredentials(upsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !errors.Is(results[0].ErrorCode, ErrNoError) {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if upsert[0].Iterations != 0 || upsert[0].Salt != nil {
+		t.Errorf("Expected the upsert of the caller to be left untouched, got %+v", upsert[0])
+	}
+
+	var request *AlterUserScramCredentialsRequest
+	for _, rr := range seedBroker.History() {
+		if r, ok := rr.Request.(*AlterUserScramCredentialsRequest); ok {
+			request = r
+		}
+	}
+	if request == nil || len(request.Upsertions) != 1 {
+		t.Fatalf("Expected an upsertion, got %+v", request)
+	}
+	if u := request.Upsertions[0]; u.Iterations != scramMinIterations || len(u.Salt) != scramSaltLength || len(u.saltedPassword) == 0 {
+		t.Errorf("Expected a salted password with the default iterations, got %+v", u)
+	}
+
+	if _, err := admin.UpsertUserScramCredentials([]AlterUserScramCredentialsUpsert{{Name: "bob"}}); !errors.Is(err, ErrUnknownScramMechanism) {
+		t.Errorf("Expected ErrUnknownScramMechanism, got %v", err)
+	}
+
+	if _, err := admin.DeleteUserScramCredentials([]AlterUserScramCredentialsDelete{{Name: "alice", Mechanism: SCRAM_MECHANISM_SHA_512}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminUserScramCredentialsVersion(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_6_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var configErr ConfigurationError
+	if _, err := admin.DescribeUserScramCredentials(nil); !errors.As(err, &configErr) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+	if _, err := admin.DeleteUserScramCredentials(nil); !errors.As(err, &configErr) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestClusterAdminDelegationTokens(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	owner := DelegationTokenPrincipal{Type: "User", Name: "alice"}
+	renewers := []DelegationTokenPrincipal{{Type: "User", Name: "bob"}}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateDelegationTokenRequest": NewMockWrapper(&CreateDelegationTokenResponse{
+			Version:    1,
+			Owner:      owner,
+			IssueTime:  time.Unix(1, 0),
+			ExpiryTime: time.Unix(2, 0),
+			MaxTime:    time.Unix(3, 0),
+			TokenID:    "id",
+			HMAC:       []byte{1, 2, 3},
+		}),
+		"RenewDelegationTokenRequest": NewMockWrapper(&RenewDelegationTokenResponse{
+			Version:    1,
+			ExpiryTime: time.Unix(3, 0),
+		}),
+		"ExpireDelegationTokenRequest": NewMockWrapper(&ExpireDelegationTokenResponse{
+			Version: 1,
+			Err:     ErrDelegationTokenExpired,
+		}),
+		"DescribeDelegationTokenRequest": NewMockWrapper(&DescribeDelegationTokenResponse{
+			Version: 1,
+			Tokens:  []DelegationToken{{TokenID: "id", Owner: owner, Renewers: renewers}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	token, err := admin.CreateDelegationToken(renewers, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.TokenID != "id" || token.Owner != owner || len(token.Renewers) != 1 || !token.MaxTime.Equal(time.Unix(3, 0)) {
+		t.Errorf("Unexpected token %+v", token)
+	}
+
+	expiry, err := admin.RenewDelegationToken(token.HMAC, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(time.Unix(3, 0)) {
+		t.Errorf("Expected the token to expire at its max time, got %v", expiry)
+	}
+
+	if _, err := admin.ExpireDelegationToken(token.HMAC, -1); !errors.Is(err, ErrDelegationTokenExpired) {
+		t.Errorf("Expected ErrDelegationTokenExpired, got %v", err)
+	}
+
+	tokens, err := admin.DescribeDelegationToken([]DelegationTokenPrincipal{owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].TokenID != "id" || tokens[0].Renewers[0] != renewers[0] {
+		t.Errorf("Unexpected tokens %+v", tokens)
+	}
+
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*CreateDelegationTokenRequest); ok {
+			if req.Version != 1 || req.MaxLifetime != time.Hour {
+				t.Errorf("Unexpected create request %+v", req)
+			}
+		}
+	}
+}
+
+func TestClusterAdminElectLeaders(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ElectLeadersRequest": NewMockWrapper(&ElectLeadersResponse{
+			Version: 1,
+			ReplicaElectionResults: map[string]map[int32]*PartitionResult{
+				"my_topic": {
+					0: {ErrorCode: ErrNoError},
+					1: {ErrorCode: ErrElectionNotNeeded},
+				},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	results, err := admin.ElectLeaders(UncleanElection, map[string][]int32{"my_topic": {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results["my_topic"]) != 2 || results["my_topic"][1].ErrorCode != ErrElectionNotNeeded {
+		t.Errorf("Unexpected results %v", results)
+	}
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*ElectLeadersRequest); ok && (req.Version != 1 || req.Type != UncleanElection) {
+			t.Errorf("Expected an unclean election request V1, got %+v", req)
+		}
+	}
+}
+
+func TestClusterAdminElectLeadersUncleanUnsupported(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_2_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var target ConfigurationError
+	if _, err := admin.ElectLeaders(UncleanElection, nil); !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestClusterAdminDescribeClusterInfo(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	rack := "rack-1"
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeClusterRequest": NewMockWrapper(&DescribeClusterResponse{
+			ClusterID:                   "my-cluster",
+			ControllerID:                seedBroker.BrokerID(),
+			Brokers:                     []*Broker{{id: seedBroker.BrokerID(), addr: seedBroker.Addr(), rack: &rack}},
+			ClusterAuthorizedOperations: 1<<AclOperationDescribe | 1<<AclOperationDescribeConfigs,
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	description, err := admin.DescribeClusterInfo(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.ClusterID != "my-cluster" || description.ControllerID != seedBroker.BrokerID() {
+		t.Errorf("Unexpected description %+v", description)
+	}
+	if len(description.Brokers) != 1 || description.Brokers[0].ID() != seedBroker.BrokerID() || description.Brokers[0].Rack() != rack {
+		t.Errorf("Unexpected brokers %v", description.Brokers)
+	}
+	expected := []AclOperation{AclOperationDescribe, AclOperationDescribeConfigs}
+	if !reflect.DeepEqual(description.AuthorizedOperations, expected) {
+		t.Errorf("Expected authorized operations %v, got %v", expected, description.AuthorizedOperations)
+	}
+	if !description.IsAuthorized(AclOperationDescribe) || description.IsAuthorized(AclOperationAlter) {
+		t.Errorf("Unexpected authorizations %v", description.AuthorizedOperations)
+	}
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*DescribeClusterRequest); ok && !req.IncludeClusterAuthorizedOperations {
+			t.Error("Expected the authorized operations to be requested")
+		}
+	}
+}
+
+func TestClusterAdminDescribeClusterInfoFencedBrokers(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeClusterRequest": NewMockWrapper(&DescribeClusterResponse{
+			Version:      2,
+			EndpointType: DescribeClusterEndpointBrokers,
+			ClusterID:    "my-cluster",
+			ControllerID: seedBroker.BrokerID(),
+			Brokers: []*Broker{
+				{id: seedBroker.BrokerID(), addr: seedBroker.Addr()},
+				{id: 2, addr: "localhost:9093"},
+			},
+			Fenced:                      map[int32]bool{2: true},
+			ClusterAuthorizedOperations: authorizedOperationsOmitted,
+		}),
+		"UnregisterBrokerRequest": NewMockWrapper(&UnregisterBrokerResponse{}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_9_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	description, err := admin.DescribeClusterInfo(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(description.Brokers) != 1 || description.Brokers[0].ID() != seedBroker.BrokerID() {
+		t.Errorf("Expected only the unfenced broker, got %v", description.Brokers)
+	}
+	if len(description.FencedBrokers) != 1 || description.FencedBrokers[0].ID() != 2 {
+		t.Errorf("Expected broker 2 to be fenced, got %v", description.FencedBrokers)
+	}
+
+	if err := admin.UnregisterBroker(2); err != nil {
+		t.Fatal(err)
+	}
+	var unregister *UnregisterBrokerRequest
+	for _, rr := range seedBroker.History() {
+		switch req := rr.Request.(type) {
+		case *DescribeClusterRequest:
+			if req.Version != 2 || !req.IncludeFencedBrokers {
+				t.Errorf("Expected the fenced brokers to be requested, got %+v", req)
+			}
+		case *UnregisterBrokerRequest:
+			unregister = req
+		}
+	}
+	if unregister == nil || unregister.BrokerID != 2 {
+		t.Errorf("Expected broker 2 to be unregistered, got %+v", unregister)
+	}
+}
+
+func TestClusterAdminUnregisterBrokerError(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"UnregisterBrokerRequest": NewMockWrapper(&UnregisterBrokerResponse{ErrorCode: ErrClusterAuthorizationFailed}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if err := admin.UnregisterBroker(2); !errors.Is(err, ErrClusterAuthorizationFailed) {
+		t.Errorf("Expected ErrClusterAuthorizationFailed, got %v", err)
+	}
+
+	config = NewTestConfig()
+	config.Version = V2_8_0_0
+	oldAdmin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, oldAdmin)
+
+	var target ConfigurationError
+	if err := oldAdmin.UnregisterBroker(2); !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestClusterAdminDescribeQuorum(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeQuorumRequest": NewMockWrapper(&DescribeQuorumResponse{
+			Version: 1,
+			Topics: map[string]map[int32]*DescribeQuorumPartition{
+				clusterMetadataTopic: {0: {
+					LeaderID:      3000,
+					LeaderEpoch:   7,
+					HighWatermark: 100,
+					CurrentVoters: []ReplicaState{
+						{ReplicaID: 3000, LogEndOffset: 105, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+						{ReplicaID: 3001, LogEndOffset: 95, LastFetchTimestamp: 1000, LastCaughtUpTimestamp: 900},
+					},
+					Observers: []ReplicaState{
+						{ReplicaID: 1, LogEndOffset: 105, LastFetchTimestamp: 1000, LastCaughtUpTimestamp: 1000},
+					},
+				}},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	quorum, err := admin.DescribeQuorum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quorum.LeaderID != 3000 || quorum.LeaderEpoch != 7 || quorum.HighWatermark != 100 {
+		t.Errorf("Unexpected leader %+v", quorum)
+	}
+	expectedVoters := []QuorumReplica{
+		{ReplicaID: 3000, LogEndOffset: 105, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+		{ReplicaID: 3001, LogEndOffset: 95, Lag: 10, LastFetchTimestamp: 1000, LastCaughtUpTimestamp: 900},
+	}
+	if !reflect.DeepEqual(quorum.Voters, expectedVoters) {
+		t.Errorf("Expected voters %+v, got %+v", expectedVoters, quorum.Voters)
+	}
+	expectedObservers := []QuorumReplica{
+		{ReplicaID: 1, LogEndOffset: 105, LastFetchTimestamp: 1000, LastCaughtUpTimestamp: 1000},
+	}
+	if !reflect.DeepEqual(quorum.Observers, expectedObservers) {
+		t.Errorf("Expected observers %+v, got %+v", expectedObservers, quorum.Observers)
+	}
+
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*DescribeQuorumRequest); ok {
+			if partitions := req.Topics[clusterMetadataTopic]; req.Version != 1 || len(partitions) != 1 || partitions[0] != 0 {
+				t.Errorf("Expected the metadata partition to be described, got %+v", req)
+			}
+		}
+	}
+}
+
+func TestClusterAdminDescribeQuorumError(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeQuorumRequest": NewMockWrapper(&DescribeQuorumResponse{
+			Version: 1,
+			Topics: map[string]map[int32]*DescribeQuorumPartition{
+				clusterMetadataTopic: {0: {ErrorCode: ErrNotLeaderForPartition}},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if _, err := admin.DescribeQuorum(); !errors.Is(err, ErrNotLeaderForPartition) {
+		t.Errorf("Expected ErrNotLeaderForPartition, got %v", err)
+	}
+
+	config = NewTestConfig()
+	config.Version = V3_0_0_0
+	oldAdmin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, oldAdmin)
+
+	var target ConfigurationError
+	if _, err := oldAdmin.DescribeQuorum(); !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestClusterAdminDescribeClusterInfoWithMetadata(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var target ConfigurationError
+	if _, err := admin.DescribeClusterInfo(true); !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+
+	description, err := admin.DescribeClusterInfo(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.ControllerID != seedBroker.BrokerID() || len(description.Brokers) != 1 || description.AuthorizedOperations != nil {
+		t.Errorf("Unexpected description %+v", description)
+	}
+}
+
+func TestClusterAdminDescribeFeatures(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).
+			SetSupportedFeatures([]SupportedFeatureKey{{Name: "metadata.version", MinVersion: 1, MaxVersion: 7}}).
+			SetFinalizedFeatures(12, []FinalizedFeatureKey{{Name: "metadata.version", MaxVersionLevel: 5, MinVersionLevel: 5}}),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	features, err := admin.DescribeFeatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &FeatureMetadata{
+		FinalizedFeatures:      map[string]FinalizedFeatureKey{"metadata.version": {Name: "metadata.version", MaxVersionLevel: 5, MinVersionLevel: 5}},
+		FinalizedFeaturesEpoch: 12,
+		SupportedFeatures:      map[string]SupportedFeatureKey{"metadata.version": {Name: "metadata.version", MinVersion: 1, MaxVersion: 7}},
+	}
+	if !reflect.DeepEqual(features, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, features)
+	}
+}
+
+func TestClusterAdminUpdateFeatures(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	message := "metadata.version cannot be downgraded"
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"UpdateFeaturesRequest": NewMockWrapper(&UpdateFeaturesResponse{
+			Version: 1,
+			Results: []UpdatableFeatureResult{
+				{Feature: "metadata.version", ErrorCode: ErrInvalidRequest, ErrorMessage: &message},
+				{Feature: "other.version", ErrorCode: ErrNoError},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	updates := []FeatureUpdate{
+		{Feature: "metadata.version", MaxVersionLevel: 4, UpgradeType: FeatureSafeDowngrade},
+		{Feature: "other.version", MaxVersionLevel: 2, UpgradeType: FeatureUpgrade},
+	}
+	err = admin.UpdateFeatures(updates, true)
+	if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), message) {
+		t.Errorf("Expected ErrInvalidRequest, got %v", err)
+	}
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*UpdateFeaturesRequest); ok && (req.Version != 1 || !req.ValidateOnly || !reflect.DeepEqual(req.FeatureUpdates, updates)) {
+			t.Errorf("Unexpected request %+v", req)
+		}
+	}
+
+	config.Version = V2_8_0_0
+	admin, err = NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var target ConfigurationError
+	if err := admin.UpdateFeatures(updates, true); !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestClusterAdminTransactions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	producer := ProducerState{ProducerID: 42, ProducerEpoch: 3, CoordinatorEpoch: 5, CurrentTxnStartOffset: 100}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorTransaction, "tx", seedBroker),
+		"DescribeProducersRequest": NewMockWrapper(&DescribeProducersResponse{
+			Topics: map[string]map[int32]*DescribeProducersPartition{
+				"my_topic": {0: {ActiveProducers: []ProducerState{producer}}},
+			},
+		}),
+		"DescribeTransactionsRequest": NewMockWrapper(&DescribeTransactionsResponse{
+			TransactionStates: []*TransactionDescription{{TransactionalID: "tx", State: "Ongoing", ProducerID: 42}},
+		}),
+		"ListTransactionsRequest": NewMockWrapper(&ListTransactionsResponse{
+			TransactionStates: []TransactionListing{{TransactionalID: "tx", ProducerID: 42, State: "Ongoing"}},
+		}),
+		"WriteTxnMarkersRequest": NewMockWrapper(&WriteTxnMarkersResponse{
+			Errors: map[int64]map[string]map[int32]KError{42: {"my_topic": {0: ErrNoError}}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	producers, err := admin.DescribeProducers("my_topic", []int32{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(producers[0]) != 1 || producers[0][0] != producer {
+		t.Errorf("Unexpected producers %v", producers)
+	}
+
+	descriptions, err := admin.DescribeTransactions([]string{"tx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptions) != 1 || descriptions[0].State != "Ongoing" {
+		t.Errorf("Unexpected descriptions %v", descriptions)
+	}
+
+	listings, err := admin.ListTransactions([]string{"Ongoing"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 1 || listings[0].TransactionalID != "tx" {
+		t.Errorf("Unexpected listings %v", listings)
+	}
+
+	if err := admin.AbortTransaction("my_topic", 0, producers[0][0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*WriteTxnMarkersRequest); ok {
+			marker := req.Markers[0]
+			if marker.Commit || marker.ProducerID != 42 || marker.ProducerEpoch != 3 || marker.CoordinatorEpoch != 5 {
+				t.Errorf("Unexpected marker %+v", marker)
+			}
+		}
+	}
+}
+
+func TestClusterAdminListTransactionsWithOptions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest":    metadata,
+		"ListTransactionsRequest": NewMockWrapper(&ListTransactionsResponse{
+			Version:             1,
+			UnknownStateFilters: []string{"Hanging"},
+			TransactionStates:   []TransactionListing{{TransactionalID: "tx-b", ProducerID: 43, State: "Ongoing"}},
+		}),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest":    metadata,
+		"ListTransactionsRequest": NewMockWrapper(&ListTransactionsResponse{
+			Version:           1,
+			TransactionStates: []TransactionListing{{TransactionalID: "tx-a", ProducerID: 42, State: "Ongoing"}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_8_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	listings, err := admin.ListTransactionsWithOptions(ListTransactionsOptions{
+		States:      []string{"Ongoing", "Hanging"},
+		MinDuration: time.Minute,
+	})
+	var target ConfigurationError
+	if !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError for the unknown state, got %v", err)
+	}
+	if len(listings) != 2 || listings[0].TransactionalID != "tx-a" || listings[1].TransactionalID != "tx-b" {
+		t.Errorf("Expected the listings of both brokers sorted, got %v", listings)
+	}
+
+	for _, broker := range []*MockBroker{seedBroker, secondBroker} {
+		var request *ListTransactionsRequest
+		for _, rr := range broker.History() {
+			if r, ok := rr.Request.(*ListTransactionsRequest); ok {
+				request = r
+			}
+		}
+		if request == nil || request.Version != 1 || request.DurationFilter != time.Minute {
+			t.Errorf("Expected broker %d to be asked for transactions older than a minute, got %+v", broker.BrokerID(), request)
+		}
+	}
+}
+
+func TestClusterAdminListTransactionsVersion(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	var target ConfigurationError
+	if _, err := admin.ListTransactionsWithOptions(ListTransactionsOptions{MinDuration: time.Minute}); !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package sarama
+
+import "sort"
+
+// AlterReplicaLogDirsRequest (Version: 1) => [dirs]
+//   dirs => path [topics]
+//     path => STRING
+//     topics => name [partitions]
+//       name => STRING
+//       partitions => INT32
+
+// AlterReplicaLogDirsRequest moves replicas of a broker to other log dirs of the broker.
+type AlterReplicaLogDirsRequest struct {
+	// Version 0 and 1 are equal
+	// The version number is bumped to indicate that on quota violation brokers send out responses before throttling.
+	Version int16
+	// Dirs are the partitions to move to each log dir, by path and topic.
+	Dirs map[string]map[string][]int32
+}
+
+// AddPartition adds the move of the replica of the partition to the log dir.
+func (r *AlterReplicaLogDirsRequest) AddPartition(path, topic string, partition int32) {
+	if r.Dirs == nil {
+		r.Dirs = make(map[string]map[string][]int32)
+	}
+	if r.Dirs[path] == nil {
+		r.Dirs[path] = make(map[string][]int32)
+	}
+	r.Dirs[path][topic] = append(r.Dirs[path][topic], partition)
+}
+
+func (r *AlterReplicaLogDirsRequest) encode(pe packetEncoder) error {
+	paths := make([]string, 0, len(r.Dirs))
+	for path := range r.Dirs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if err := pe.putArrayLength(len(paths)); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := pe.putString(path); err != nil {
+			return err
+		}
+		topics := make([]string, 0, len(r.Dirs[path]))
+		for topic := range r.Dirs[path] {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		if err := pe.putArrayLength(len(topics)); err != nil {
+			return err
+		}
+		for _, topic := range topics {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putInt32Array(r.Dirs[path][topic]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *AlterReplicaLogDirsRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return nil
+	}
+	r.Dirs = make(map[string]map[string][]int32, n)
+	for i := 0; i < n; i++ {
+		path, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		topics := make(map[string][]int32, m)
+		for j := 0; j < m; j++ {
+			topic, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if topics[topic], err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+		r.Dirs[path] = topics
+	}
+	return nil
+}
+
+func (r *AlterReplicaLogDirsRequest) key() int16 {
+	return 34
+}
+
+func (r *AlterReplicaLogDirsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *AlterReplicaLogDirsRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *AlterReplicaLogDirsRequest) requiredVersion() KafkaVersion {
+	if r.Version >= 1 {
+		return V2_0_0_0
+	}
+	return V1_1_0_0
+}
@@ -0,0 +1,27 @@
+package sarama
+
+import "testing"
+
+var alterReplicaLogDirsRequest = []byte{
+	0, 0, 0, 2, // 2 log dirs
+	0, 7, '/', 'd', 'a', 't', 'a', '/', '1', // path
+	0, 0, 0, 1, // 1 topic
+	0, 3, 'f', 'o', 'o', // topic
+	0, 0, 0, 1, 0, 0, 0, 0, // partition 0
+	0, 7, '/', 'd', 'a', 't', 'a', '/', '2', // path
+	0, 0, 0, 2, // 2 topics
+	0, 3, 'b', 'a', 'r', // topic
+	0, 0, 0, 1, 0, 0, 0, 4, // partition 4
+	0, 3, 'f', 'o', 'o', // topic
+	0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2, // partitions 1 and 2
+}
+
+func TestAlterReplicaLogDirsRequest(t *testing.T) {
+	request := &AlterReplicaLogDirsRequest{Version: 1}
+	request.AddPartition("/data/1", "foo", 0)
+	request.AddPartition("/data/2", "foo", 1)
+	request.AddPartition("/data/2", "foo", 2)
+	request.AddPartition("/data/2", "bar", 4)
+
+	testRequest(t, "v1", request, alterReplicaLogDirsRequest)
+}
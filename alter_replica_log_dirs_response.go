@@ -0,0 +1,118 @@
+package sarama
+
+import (
+	"sort"
+	"time"
+)
+
+// AlterReplicaLogDirsResponse (Version: 1) => throttle_time_ms [results]
+//   throttle_time_ms => INT32
+//   results => topic_name [partitions]
+//     topic_name => STRING
+//     partitions => partition_index error_code
+//       partition_index => INT32
+//       error_code => INT16
+
+// AlterReplicaLogDirsResponse are the errors of the moves of an AlterReplicaLogDirsRequest.
+type AlterReplicaLogDirsResponse struct {
+	Version      int16
+	ThrottleTime time.Duration
+	// Results are the errors of the partitions, by topic and partition.
+	Results map[string]map[int32]KError
+}
+
+func (r *AlterReplicaLogDirsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	topics := make([]string, 0, len(r.Results))
+	for topic := range r.Results {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	if err := pe.putArrayLength(len(topics)); err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		partitions := make([]int32, 0, len(r.Results[topic]))
+		for partition := range r.Results[topic] {
+			partitions = append(partitions, partition)
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			pe.putInt32(partition)
+			pe.putInt16(int16(r.Results[topic][partition]))
+		}
+	}
+	return nil
+}
+
+func (r *AlterReplicaLogDirsResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return nil
+	}
+	r.Results = make(map[string]map[int32]KError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		partitions := make(map[int32]KError, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			partitions[partition] = KError(kerr)
+		}
+		r.Results[topic] = partitions
+	}
+	return nil
+}
+
+func (r *AlterReplicaLogDirsResponse) key() int16 {
+	return 34
+}
+
+func (r *AlterReplicaLogDirsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *AlterReplicaLogDirsResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *AlterReplicaLogDirsResponse) requiredVersion() KafkaVersion {
+	if r.Version >= 1 {
+		return V2_0_0_0
+	}
+	return V1_1_0_0
+}
+
+func (r *AlterReplicaLogDirsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,27 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var alterReplicaLogDirsResponse = []byte{
+	0, 0, 0, 100, // throttle time
+	0, 0, 0, 1, // 1 topic
+	0, 3, 'f', 'o', 'o', // topic
+	0, 0, 0, 2, // 2 partitions
+	0, 0, 0, 0, 0, 0, // partition 0, no error
+	0, 0, 0, 1, 0, 57, // partition 1, ErrLogDirNotFound
+}
+
+func TestAlterReplicaLogDirsResponse(t *testing.T) {
+	response := &AlterReplicaLogDirsResponse{
+		Version:      1,
+		ThrottleTime: 100 * time.Millisecond,
+		Results: map[string]map[int32]KError{
+			"foo": {0: ErrNoError, 1: ErrLogDirNotFound},
+		},
+	}
+
+	testResponse(t, "v1", response, alterReplicaLogDirsResponse)
+}
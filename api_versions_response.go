@@ -50,6 +50,26 @@ func (a *ApiVersionsResponseKey) decode(pd packetDecoder, version int16) (err er
 	return nil
 }
 
+// SupportedFeatureKey contains a feature supported by the broker (KIP-584).
+type SupportedFeatureKey struct {
+	// Name contains the name of the feature.
+	Name string
+	// MinVersion contains the minimum supported version for the feature.
+	MinVersion int16
+	// MaxVersion contains the maximum supported version for the feature.
+	MaxVersion int16
+}
+
+// FinalizedFeatureKey contains a feature finalized for the cluster (KIP-584).
+type FinalizedFeatureKey struct {
+	// Name contains the name of the feature.
+	Name string
+	// MaxVersionLevel contains the cluster-wide finalized max version level for the feature.
+	MaxVersionLevel int16
+	// MinVersionLevel contains the cluster-wide finalized min version level for the feature.
+	MinVersionLevel int16
+}
+
 type ApiVersionsResponse struct {
 	// Version defines the protocol version to use for encode and decode
 	Version int16
@@ -59,8 +79,22 @@ type ApiVersionsResponse struct {
 	ApiKeys []ApiVersionsResponseKey
 	// ThrottleTimeMs contains the duration in milliseconds for which the request was throttled due to a quota violation, or zero if the request did not violate any quota.
 	ThrottleTimeMs int32
+	// SupportedFeatures contains the features supported by the broker, from version 3.
+	SupportedFeatures []SupportedFeatureKey
+	// FinalizedFeaturesEpoch contains the epoch of the finalized features, or -1 if the broker
+	// did not return any, from version 3.
+	FinalizedFeaturesEpoch int64
+	// FinalizedFeatures contains the features finalized for the cluster, from version 3.
+	FinalizedFeatures []FinalizedFeatureKey
 }
 
+// Tags of the tagged fields of ApiVersionsResponse.
+const (
+	apiVersionsSupportedFeaturesTag      = 0
+	apiVersionsFinalizedFeaturesEpochTag = 1
+	apiVersionsFinalizedFeaturesTag      = 2
+)
+
 func (r *ApiVersionsResponse) encode(pe packetEncoder) (err error) {
 	pe.putInt16(r.ErrorCode)
 
@@ -82,9 +116,103 @@ func (r *ApiVersionsResponse) encode(pe packetEncoder) (err error) {
 	}
 
 	if r.Version >= 3 {
-		pe.putEmptyTaggedFieldArray()
+		return r.encodeTaggedFields(pe)
+	}
+
+	return nil
+}
+
+func (r *ApiVersionsResponse) encodeTaggedFields(pe packetEncoder) error {
+	var fields []taggedField
+	if len(r.SupportedFeatures) > 0 {
+		fields = append(fields, taggedField{apiVersionsSupportedFeaturesTag, func(pe packetEncoder) error {
+			pe.putCompactArrayLength(len(r.SupportedFeatures))
+			for _, feature := range r.SupportedFeatures {
+				if err := pe.putCompactString(feature.Name); err != nil {
+					return err
+				}
+				pe.putInt16(feature.MinVersion)
+				pe.putInt16(feature.MaxVersion)
+				pe.putEmptyTaggedFieldArray()
+			}
+			return nil
+		}})
+	}
+	if r.FinalizedFeaturesEpoch != -1 {
+		fields = append(fields, taggedField{apiVersionsFinalizedFeaturesEpochTag, func(pe packetEncoder) error {
+			pe.putInt64(r.FinalizedFeaturesEpoch)
+			return nil
+		}})
 	}
+	if len(r.FinalizedFeatures) > 0 {
+		fields = append(fields, taggedField{apiVersionsFinalizedFeaturesTag, func(pe packetEncoder) error {
+			pe.putCompactArrayLength(len(r.FinalizedFeatures))
+			for _, feature := range r.FinalizedFeatures {
+				if err := pe.putCompactString(feature.Name); err != nil {
+					return err
+				}
+				pe.putInt16(feature.MaxVersionLevel)
+				pe.putInt16(feature.MinVersionLevel)
+				pe.putEmptyTaggedFieldArray()
+			}
+			return nil
+		}})
+	}
+	return putTaggedFields(pe, fields)
+}
 
+func (r *ApiVersionsResponse) decodeTaggedField(tag uint64, pd packetDecoder) (err error) {
+	switch tag {
+	case apiVersionsSupportedFeaturesTag:
+		n, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		r.SupportedFeatures = make([]SupportedFeatureKey, n)
+		for i := range r.SupportedFeatures {
+			feature := &r.SupportedFeatures[i]
+			if feature.Name, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			if feature.MinVersion, err = pd.getInt16(); err != nil {
+				return err
+			}
+			if feature.MaxVersion, err = pd.getInt16(); err != nil {
+				return err
+			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	case apiVersionsFinalizedFeaturesEpochTag:
+		r.FinalizedFeaturesEpoch, err = pd.getInt64()
+		return err
+	case apiVersionsFinalizedFeaturesTag:
+		n, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		r.FinalizedFeatures = make([]FinalizedFeatureKey, n)
+		for i := range r.FinalizedFeatures {
+			feature := &r.FinalizedFeatures[i]
+			if feature.Name, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			if feature.MaxVersionLevel, err = pd.getInt16(); err != nil {
+				return err
+			}
+			if feature.MinVersionLevel, err = pd.getInt16(); err != nil {
+				return err
+			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	default:
+		// skip the fields of newer versions such as ZkMigrationReady
+		_, err = pd.getRawBytes(pd.remaining())
+		return err
+	}
 	return nil
 }
 
@@ -122,7 +250,8 @@ func (r *ApiVersionsResponse) decode(pd packetDecoder, version int16) (err error
 	}
 
 	if r.Version >= 3 {
-		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+		r.FinalizedFeaturesEpoch = -1
+		if err = getTaggedFields(pd, r.decodeTaggedField); err != nil {
 			return err
 		}
 	}
@@ -21,6 +21,21 @@ var (
 		0x00, 0x00, 0x00, 0x00, // throttle time
 		0x01, 0x01, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // tagged fields (empty SupportedFeatures)
 	}
+
+	apiVersionResponseV3WithFeatures = []byte{
+		0x00, 0x00, // no error
+		0x01,                   // compact array length 0
+		0x00, 0x00, 0x00, 0x00, // throttle time
+		0x03,       // 3 tagged fields
+		0x00, 0x17, // SupportedFeatures
+		0x02, 0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+		0x00, 0x01, 0x00, 0x07, 0x00,
+		0x01, 0x08, // FinalizedFeaturesEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+		0x02, 0x17, // FinalizedFeatures
+		0x02, 0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+		0x00, 0x07, 0x00, 0x01, 0x00,
+	}
 )
 
 func TestApiVersionsResponse(t *testing.T) {
@@ -57,3 +72,17 @@ func TestApiVersionsResponseV3(t *testing.T) {
 		t.Error("Decoding error: expected 0x01 but got", response.ApiKeys[0].MaxVersion)
 	}
 }
+
+func TestApiVersionsResponseV3WithFeatures(t *testing.T) {
+	response := &ApiVersionsResponse{
+		Version:                3,
+		ApiKeys:                []ApiVersionsResponseKey{},
+		SupportedFeatures:      []SupportedFeatureKey{{Name: "metadata.version", MinVersion: 1, MaxVersion: 7}},
+		FinalizedFeaturesEpoch: 5,
+		FinalizedFeatures:      []FinalizedFeatureKey{{Name: "metadata.version", MaxVersionLevel: 7, MinVersionLevel: 1}},
+	}
+	testResponse(t, "features", response, apiVersionResponseV3WithFeatures)
+
+	response = &ApiVersionsResponse{Version: 3, ApiKeys: []ApiVersionsResponseKey{}, FinalizedFeaturesEpoch: -1}
+	testResponse(t, "no features", response, []byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00})
+}
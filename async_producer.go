@@ -1,11 +1,14 @@
 package sarama
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eapache/go-resiliency/breaker"
@@ -34,6 +37,14 @@ type AsyncProducer interface {
 	// Close on the underlying client.
 	Close() error
 
+	// CloseContext shuts down the producer like Close, stopping it from accepting
+	// new input and flushing buffered messages, but only waits for them to be
+	// acknowledged until ctx is done. Messages that failed to deliver, as well as
+	// messages still unacknowledged when ctx is done, are returned as
+	// ProducerErrors; the latter carry ctx.Err() as their error and may still be
+	// delivered by the producer in the background.
+	CloseContext(ctx context.Context) error
+
 	// Input is the input channel for the user to write messages to that they
 	// wish to send.
 	Input() chan<- *ProducerMessage
@@ -50,6 +61,14 @@ type AsyncProducer interface {
 	// errors to be returned.
 	Errors() <-chan *ProducerError
 
+	// CheckMessage verifies, without sending it, that msg can currently be
+	// produced: that its encoded size is within Producer.MaxMessageBytes and
+	// the `max.message.bytes` of its topic, and that a leader is available for
+	// its partition. The topic configuration is fetched with a DescribeConfigs
+	// request and cached for Metadata.RefreshFrequency. This lets oversized
+	// payloads be rejected synchronously rather than through Errors().
+	CheckMessage(msg *ProducerMessage) error
+
 	// IsTransactional return true when current producer is is transactional.
 	IsTransactional() bool
 
@@ -79,6 +98,8 @@ type asyncProducer struct {
 	errors                    chan *ProducerError
 	input, successes, retries chan *ProducerMessage
 	inFlight                  sync.WaitGroup
+	unacked                   *unackedMessages
+	topicConfigs              *topicConfigCache
 
 	brokers    map[*Broker]*brokerProducer
 	brokerRefs map[*brokerProducer]int
@@ -87,6 +108,8 @@ type asyncProducer struct {
 	txnmgr *transactionManager
 	txLock sync.Mutex
 
+	leaderEpochs *leaderEpochTracker
+
 	metricsRegistry metrics.Registry
 }
 
@@ -128,7 +151,10 @@ func newAsyncProducer(client Client) (AsyncProducer, error) {
 		retries:         make(chan *ProducerMessage),
 		brokers:         make(map[*Broker]*brokerProducer),
 		brokerRefs:      make(map[*brokerProducer]int),
+		unacked:         newUnackedMessages(),
 		txnmgr:          txnmgr,
+		leaderEpochs:    newLeaderEpochTracker(),
+		topicConfigs:    newTopicConfigCache(client),
 		metricsRegistry: newCleanupRegistry(client.Config().MetricRegistry),
 	}
 
@@ -170,6 +196,16 @@ type ProducerMessage struct {
 	// pass-through data.
 	Metadata interface{}
 
+	// EncodedBatch is an already encoded v2 record batch, for example one
+	// copied from a FetchResponse, to be produced as-is instead of Value. Its
+	// records are written without being decompressed or re-encoded, keeping
+	// their compression, timestamps and headers; only the producer id, epoch
+	// and sequence are overwritten. Key is still used for partitioning, but
+	// Value and Headers must be nil. Requires Kafka at least v0.11 and is not
+	// supported by the idempotent producer. On success Offset is the offset of
+	// the first record of the batch.
+	EncodedBatch []byte
+
 	// Below this point are filled in by the producer as the message is processed
 
 	// Offset is the offset of the message stored on the broker. This is only
@@ -191,6 +227,16 @@ type ProducerMessage struct {
 	// by the broker. This is only guaranteed to be defined if the message was
 	// successfully delivered and RequiredAcks is not NoResponse.
 	Timestamp time.Time
+	// LogAppendTime is true when Timestamp was assigned by the broker because
+	// the topic is configured with `message.timestamp.type=LogAppendTime`,
+	// rather than being the CreateTime set by the producer.
+	LogAppendTime bool
+	// BatchFirstOffset and BatchLastOffset are the offsets of the first and
+	// last messages of the batch this message was written in. They are only
+	// guaranteed to be defined if the message was successfully delivered and
+	// RequiredAcks is not NoResponse.
+	BatchFirstOffset int64
+	BatchLastOffset  int64
 
 	retries        int
 	flags          flagSet
@@ -198,11 +244,17 @@ type ProducerMessage struct {
 	sequenceNumber int32
 	producerEpoch  int16
 	hasSequence    bool
+	// unacked is the ticket of the message in unackedMessages, reset atomically to 0 once it is
+	// returned as a success or an error.
+	unacked uint32
 }
 
 const producerMessageOverhead = 26 // the metadata overhead of CRC, flags, etc.
 
 func (m *ProducerMessage) ByteSize(version int) int {
+	if m.EncodedBatch != nil {
+		return len(m.EncodedBatch)
+	}
 	var size int
 	if version >= 2 {
 		size = maximumRecordOverhead
@@ -229,14 +281,47 @@ func (m *ProducerMessage) clear() {
 	m.hasSequence = false
 }
 
+// TimestampError is the error returned for messages whose explicit Timestamp would be rejected by
+// the broker, when Producer.Timestamp.Validate is enabled. It wraps ErrInvalidTimestamp.
+type TimestampError struct {
+	Topic     string
+	Timestamp time.Time
+	// MaxSkew is the maximum difference allowed with the producer's clock, zero if the timestamp
+	// was rejected for being before the Unix epoch.
+	MaxSkew time.Duration
+}
+
+func (te *TimestampError) Error() string {
+	if te.MaxSkew == 0 {
+		return fmt.Sprintf("kafka: invalid timestamp %v for topic %s: before the Unix epoch", te.Timestamp, te.Topic)
+	}
+	return fmt.Sprintf("kafka: invalid timestamp %v for topic %s: more than %v away from the producer's clock",
+		te.Timestamp, te.Topic, te.MaxSkew)
+}
+
+func (te *TimestampError) Unwrap() error {
+	return ErrInvalidTimestamp
+}
+
 // ProducerError is the type of error generated when the producer fails to deliver a message.
 // It contains the original ProducerMessage as well as the actual error value.
 type ProducerError struct {
 	Msg *ProducerMessage
 	Err error
+
+	// InvalidRecord is true when the broker identified this particular message as
+	// the reason its batch was rejected (requires Kafka 2.4 or higher, see KIP-467).
+	// The other messages of the batch fail with the same Err but InvalidRecord unset.
+	InvalidRecord bool
+	// ErrorMessage is the broker's description of why the message, or the batch it
+	// was part of, was rejected. It is empty if the broker did not provide one.
+	ErrorMessage string
 }
 
 func (pe ProducerError) Error() string {
+	if pe.ErrorMessage != "" {
+		return fmt.Sprintf("kafka: Failed to produce message to topic %s: %s: %s", pe.Msg.Topic, pe.Err, pe.ErrorMessage)
+	}
 	return fmt.Sprintf("kafka: Failed to produce message to topic %s: %s", pe.Msg.Topic, pe.Err)
 }
 
@@ -356,6 +441,123 @@ func (p *asyncProducer) Input() chan<- *ProducerMessage {
 	return p.input
 }
 
+func (p *asyncProducer) CheckMessage(msg *ProducerMessage) error {
+	if msg == nil {
+		return ConfigurationError("CheckMessage requires a non-nil message")
+	}
+
+	size, err := p.encodedSize(msg)
+	if err != nil {
+		return err
+	}
+	if size > p.conf.Producer.MaxMessageBytes {
+		return fmt.Errorf("%w: %d bytes exceeds Producer.MaxMessageBytes (%d)", ErrMessageSizeTooLarge, size, p.conf.Producer.MaxMessageBytes)
+	}
+
+	// DescribeConfigs requires Kafka 0.11
+	if p.conf.Version.IsAtLeast(V0_11_0_0) {
+		configs, err := p.topicConfigs.get(msg.Topic)
+		if err != nil {
+			return err
+		}
+		if value, ok := configs["max.message.bytes"]; ok {
+			maxBytes, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			if size > maxBytes {
+				return fmt.Errorf("%w: %d bytes exceeds max.message.bytes of topic %s (%d)", ErrMessageSizeTooLarge, size, msg.Topic, maxBytes)
+			}
+		}
+	}
+
+	return p.checkPartitionAvailable(msg)
+}
+
+// encodedSize returns the size msg would have on the wire as a batch of its own, compressed with
+// the producer's codec.
+func (p *asyncProducer) encodedSize(msg *ProducerMessage) (int, error) {
+	if msg.EncodedBatch != nil {
+		return len(msg.EncodedBatch), nil
+	}
+	if !p.conf.Version.IsAtLeast(V0_11_0_0) {
+		return msg.ByteSize(1), nil
+	}
+
+	rec := &Record{}
+	var err error
+	if msg.Key != nil {
+		if rec.Key, err = msg.Key.Encode(); err != nil {
+			return 0, err
+		}
+	}
+	if msg.Value != nil {
+		if rec.Value, err = msg.Value.Encode(); err != nil {
+			return 0, err
+		}
+	}
+	for i := range msg.Headers {
+		rec.Headers = append(rec.Headers, &msg.Headers[i])
+	}
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	batch := &RecordBatch{
+		Version:          2,
+		Codec:            p.conf.Producer.Compression,
+		CompressionLevel: p.conf.producerCompressionLevel(),
+		FirstTimestamp:   timestamp,
+		MaxTimestamp:     timestamp,
+		Records:          []*Record{rec},
+	}
+	encoded, err := encode(batch, nil)
+	if err != nil {
+		return 0, err
+	}
+	return len(encoded), nil
+}
+
+// checkPartitionAvailable returns an error if the partition msg would be sent to has no leader,
+// or, for partitioners that don't require consistency, if no partition of the topic is writable.
+func (p *asyncProducer) checkPartitionAvailable(msg *ProducerMessage) error {
+	// use a fresh partitioner so that the producer's partitioners don't see the message
+	partitioner := p.conf.Producer.Partitioner(msg.Topic)
+	var requiresConsistency bool
+	if ep, ok := partitioner.(DynamicConsistencyPartitioner); ok {
+		requiresConsistency = ep.MessageRequiresConsistency(msg)
+	} else {
+		requiresConsistency = partitioner.RequiresConsistency()
+	}
+
+	if !requiresConsistency {
+		partitions, err := p.client.WritablePartitions(msg.Topic)
+		if err != nil {
+			return err
+		}
+		if len(partitions) == 0 {
+			return ErrLeaderNotAvailable
+		}
+		return nil
+	}
+
+	partitions, err := p.client.Partitions(msg.Topic)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return ErrLeaderNotAvailable
+	}
+	choice, err := partitioner.Partition(msg, int32(len(partitions)))
+	if err != nil {
+		return err
+	} else if choice < 0 || choice >= int32(len(partitions)) {
+		return ErrInvalidPartition
+	}
+	_, err = p.client.Leader(msg.Topic, partitions[choice])
+	return err
+}
+
 func (p *asyncProducer) Close() error {
 	p.AsyncClose()
 
@@ -381,6 +583,46 @@ func (p *asyncProducer) Close() error {
 	return nil
 }
 
+func (p *asyncProducer) CloseContext(ctx context.Context) error {
+	p.AsyncClose()
+
+	var errors ProducerErrors
+	successes, errs := p.successes, p.errors
+	for successes != nil || errs != nil {
+		select {
+		case _, ok := <-successes:
+			if !ok {
+				successes = nil
+			}
+		case event, ok := <-errs:
+			if !ok {
+				errs = nil
+			} else if p.conf.Producer.Return.Errors {
+				errors = append(errors, event)
+			}
+		case <-ctx.Done():
+			for _, msg := range p.unacked.list() {
+				errors = append(errors, &ProducerError{Msg: msg, Err: ctx.Err()})
+			}
+			// keep draining so that the shutdown can still complete in the background
+			go withRecover(func() {
+				for range successes {
+				}
+			})
+			go withRecover(func() {
+				for range errs {
+				}
+			})
+			return errors
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
 func (p *asyncProducer) AsyncClose() {
 	go withRecover(p.shutdown)
 }
@@ -430,6 +672,7 @@ func (p *asyncProducer) dispatcher() {
 				continue
 			}
 			p.inFlight.Add(1)
+			p.unacked.add(msg)
 			// Ignore retried msg, there are already in txn.
 			// Can't produce new record when transaction is not started.
 			if p.IsTransactional() && p.txnmgr.currentTxnStatus()&ProducerTxnFlagInTransaction == 0 {
@@ -450,6 +693,20 @@ func (p *asyncProducer) dispatcher() {
 			p.returnError(msg, ConfigurationError("Producing headers requires Kafka at least v0.11"))
 			continue
 		}
+		if msg.EncodedBatch != nil {
+			if version < 2 {
+				p.returnError(msg, ConfigurationError("Producing encoded record batches requires Kafka at least v0.11"))
+				continue
+			}
+			if p.conf.Producer.Idempotent {
+				p.returnError(msg, ConfigurationError("Producing encoded record batches is not supported by the idempotent producer"))
+				continue
+			}
+			if msg.Value != nil || msg.Headers != nil {
+				p.returnError(msg, ConfigurationError("EncodedBatch cannot be combined with Value or Headers"))
+				continue
+			}
+		}
 		if msg.ByteSize(version) > p.conf.Producer.MaxMessageBytes {
 			p.returnError(msg, ErrMessageSizeTooLarge)
 			continue
@@ -479,6 +736,11 @@ type topicProducer struct {
 	breaker     *breaker.Breaker
 	handlers    map[int32]chan<- *ProducerMessage
 	partitioner Partitioner
+
+	// the topic's timestamp configuration, loaded for the first message with an explicit timestamp
+	timestampConfigLoaded bool
+	logAppendTime         bool
+	maxTimestampSkew      time.Duration
 }
 
 func (p *asyncProducer) newTopicProducer(topic string) chan<- *ProducerMessage {
@@ -498,6 +760,12 @@ func (p *asyncProducer) newTopicProducer(topic string) chan<- *ProducerMessage {
 func (tp *topicProducer) dispatch() {
 	for msg := range tp.input {
 		if msg.retries == 0 {
+			if tp.parent.conf.Producer.Timestamp.Validate {
+				if err := tp.validateTimestamp(msg); err != nil {
+					tp.parent.returnError(msg, err)
+					continue
+				}
+			}
 			if err := tp.partitionMessage(msg); err != nil {
 				tp.parent.returnError(msg, err)
 				continue
@@ -518,6 +786,68 @@ func (tp *topicProducer) dispatch() {
 	}
 }
 
+// validateTimestamp returns a *TimestampError if the broker would reject the explicit timestamp of msg.
+func (tp *topicProducer) validateTimestamp(msg *ProducerMessage) error {
+	if msg.Timestamp.IsZero() {
+		return nil
+	}
+	if msg.Timestamp.Before(time.Unix(0, 0)) {
+		return &TimestampError{Topic: tp.topic, Timestamp: msg.Timestamp}
+	}
+
+	if !tp.timestampConfigLoaded {
+		var err error
+		tp.logAppendTime, tp.maxTimestampSkew, err = tp.parent.timestampConfig(tp.topic)
+		if err != nil {
+			// leave the validation to the broker rather than failing messages
+			Logger.Printf("producer/%s unable to describe the timestamp configuration: %v\n", tp.topic, err)
+		}
+		tp.timestampConfigLoaded = true
+	}
+	if tp.logAppendTime {
+		// the broker overwrites the timestamp without validating it
+		return nil
+	}
+
+	maxSkew := tp.parent.conf.Producer.Timestamp.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = tp.maxTimestampSkew
+	}
+	if maxSkew == 0 {
+		return nil
+	}
+	skew := time.Since(msg.Timestamp)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return &TimestampError{Topic: tp.topic, Timestamp: msg.Timestamp, MaxSkew: maxSkew}
+	}
+	return nil
+}
+
+// timestampConfig returns whether the topic uses LogAppendTime and its
+// `message.timestamp.difference.max.ms`, zero if unlimited.
+func (p *asyncProducer) timestampConfig(topic string) (bool, time.Duration, error) {
+	configs, err := p.topicConfigs.get(topic)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var maxSkew time.Duration
+	if value, ok := configs["message.timestamp.difference.max.ms"]; ok {
+		millis, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false, 0, err
+		}
+		// the broker default is Long.MAX_VALUE, which doesn't fit a time.Duration
+		if millis > 0 && millis < math.MaxInt64/int64(time.Millisecond) {
+			maxSkew = time.Duration(millis) * time.Millisecond
+		}
+	}
+	return configs["message.timestamp.type"] == "LogAppendTime", maxSkew, nil
+}
+
 func (tp *topicProducer) partitionMessage(msg *ProducerMessage) error {
 	var partitions []int32
 
@@ -569,6 +899,7 @@ type partitionProducer struct {
 	input     <-chan *ProducerMessage
 
 	leader         *Broker
+	leaderEpoch    int32
 	breaker        *breaker.Breaker
 	brokerProducer *brokerProducer
 
@@ -593,8 +924,9 @@ func (p *asyncProducer) newPartitionProducer(topic string, partition int32) chan
 		partition: partition,
 		input:     input,
 
-		breaker:    breaker.New(3, 1, 10*time.Second),
-		retryState: make([]partitionRetryState, p.conf.Producer.Retry.Max+1),
+		leaderEpoch: invalidLeaderEpoch,
+		breaker:     breaker.New(3, 1, 10*time.Second),
+		retryState:  make([]partitionRetryState, p.conf.Producer.Retry.Max+1),
 	}
 	go withRecover(pp.dispatch)
 	return input
@@ -628,8 +960,9 @@ func (pp *partitionProducer) updateLeaderIfBrokerProducerIsNil(msg *ProducerMess
 func (pp *partitionProducer) dispatch() {
 	// try to prefetch the leader; if this doesn't work, we'll do a proper call to `updateLeader`
 	// on the first message
-	pp.leader, _ = pp.parent.client.Leader(pp.topic, pp.partition)
+	pp.leader, pp.leaderEpoch, _ = pp.parent.client.LeaderAndEpoch(pp.topic, pp.partition)
 	if pp.leader != nil {
+		pp.parent.leaderEpochs.set(pp.topic, pp.partition, pp.leaderEpoch)
 		pp.brokerProducer = pp.parent.getBrokerProducer(pp.leader)
 		pp.parent.inFlight.Add(1) // we're generating a syn message; track it so we don't shut down while it's still inflight
 		pp.brokerProducer.input <- &ProducerMessage{Topic: pp.topic, Partition: pp.partition, flags: syn}
@@ -753,14 +1086,11 @@ func (pp *partitionProducer) flushRetryBuffers() {
 
 func (pp *partitionProducer) updateLeader() error {
 	return pp.breaker.Run(func() (err error) {
-		if err = pp.parent.client.RefreshMetadata(pp.topic); err != nil {
-			return err
-		}
-
-		if pp.leader, err = pp.parent.client.Leader(pp.topic, pp.partition); err != nil {
+		if err = pp.refreshLeader(); err != nil {
 			return err
 		}
 
+		pp.parent.leaderEpochs.set(pp.topic, pp.partition, pp.leaderEpoch)
 		pp.brokerProducer = pp.parent.getBrokerProducer(pp.leader)
 		pp.parent.inFlight.Add(1) // we're generating a syn message; track it so we don't shut down while it's still inflight
 		pp.brokerProducer.input <- &ProducerMessage{Topic: pp.topic, Partition: pp.partition, flags: syn}
@@ -769,6 +1099,88 @@ func (pp *partitionProducer) updateLeader() error {
 	})
 }
 
+// refreshLeader refreshes the metadata for the topic and looks up the current leader of the
+// partition. If the previous leader was fenced by a NOT_LEADER_OR_FOLLOWER response and the
+// refreshed metadata still reports that (or an older) leader epoch, the metadata is stale and
+// is refreshed again rather than sending the next batch straight back to the old leader.
+func (pp *partitionProducer) refreshLeader() (err error) {
+	for attempt := 0; ; attempt++ {
+		if err = pp.parent.client.RefreshMetadata(pp.topic); err != nil {
+			return err
+		}
+
+		if pp.leader, pp.leaderEpoch, err = pp.parent.client.LeaderAndEpoch(pp.topic, pp.partition); err != nil {
+			return err
+		}
+
+		if !pp.parent.leaderEpochs.isStale(pp.topic, pp.partition, pp.leaderEpoch) {
+			return nil
+		}
+
+		if attempt >= pp.parent.conf.Metadata.Retry.Max {
+			Logger.Printf("producer/leader/%s/%d metadata still reports fenced leader epoch %d, giving up\n",
+				pp.topic, pp.partition, pp.leaderEpoch)
+			return ErrNotLeaderForPartition
+		}
+
+		Logger.Printf("producer/leader/%s/%d metadata reports fenced leader epoch %d, refreshing again\n",
+			pp.topic, pp.partition, pp.leaderEpoch)
+		time.Sleep(pp.parent.conf.Metadata.Retry.Backoff)
+	}
+}
+
+// leaderEpochTracker records the leader epoch each partition producer is currently sending to,
+// along with the epochs that have since been fenced by a NOT_LEADER_OR_FOLLOWER response, so
+// that metadata which has not yet caught up with a leader change can be detected.
+type leaderEpochTracker struct {
+	lock    sync.Mutex
+	current map[string]map[int32]int32
+	fenced  map[string]map[int32]int32
+}
+
+func newLeaderEpochTracker() *leaderEpochTracker {
+	return &leaderEpochTracker{
+		current: make(map[string]map[int32]int32),
+		fenced:  make(map[string]map[int32]int32),
+	}
+}
+
+// set records the leader epoch now in use for the partition, clearing any fenced epoch.
+func (t *leaderEpochTracker) set(topic string, partition int32, epoch int32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.current[topic] == nil {
+		t.current[topic] = make(map[int32]int32)
+	}
+	t.current[topic][partition] = epoch
+	delete(t.fenced[topic], partition)
+}
+
+// fence marks the leader epoch currently in use for the partition as no longer valid.
+func (t *leaderEpochTracker) fence(topic string, partition int32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	epoch, ok := t.current[topic][partition]
+	if !ok || epoch == invalidLeaderEpoch {
+		return
+	}
+	if t.fenced[topic] == nil {
+		t.fenced[topic] = make(map[int32]int32)
+	}
+	t.fenced[topic][partition] = epoch
+}
+
+// isStale returns true if epoch is not newer than the last fenced epoch of the partition.
+func (t *leaderEpochTracker) isStale(topic string, partition int32, epoch int32) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	fenced, ok := t.fenced[topic][partition]
+	return ok && epoch != invalidLeaderEpoch && epoch <= fenced
+}
+
 // one per broker; also constructs an associated flusher
 func (p *asyncProducer) newBrokerProducer(broker *Broker) *brokerProducer {
 	var (
@@ -779,13 +1191,15 @@ func (p *asyncProducer) newBrokerProducer(broker *Broker) *brokerProducer {
 	)
 
 	bp := &brokerProducer{
-		parent:         p,
-		broker:         broker,
-		input:          input,
-		output:         bridge,
-		responses:      responses,
-		buffer:         newProduceSet(p),
-		currentRetries: make(map[string]map[int32]error),
+		parent:          p,
+		broker:          broker,
+		input:           input,
+		output:          bridge,
+		responses:       responses,
+		buffer:          newProduceSet(p),
+		currentRetries:  make(map[string]map[int32]error),
+		inFlightBatches: make(map[string]map[int32]int),
+		parked:          make(map[string]map[int32][]*ProducerMessage),
 	}
 	go withRecover(bp.run)
 
@@ -905,11 +1319,16 @@ type brokerProducer struct {
 
 	closing        error
 	currentRetries map[string]map[int32]error
+
+	// unacknowledged batches per partition, and the messages held back for the
+	// partitions that reached Producer.MaxInFlightPerPartition
+	inFlightBatches map[string]map[int32]int
+	parked          map[string]map[int32][]*ProducerMessage
+	parkedCount     int
 }
 
 func (bp *brokerProducer) run() {
 	var output chan<- *produceSet
-	var timerChan <-chan time.Time
 	Logger.Printf("producer/broker/%d starting up\n", bp.broker.ID())
 
 	for {
@@ -957,6 +1376,11 @@ func (bp *brokerProducer) run() {
 				continue
 			}
 
+			if bp.mustPark(msg) {
+				bp.park(msg)
+				continue
+			}
+
 			if bp.buffer.wouldOverflow(msg) {
 				Logger.Printf("producer/broker/%d maximum request accumulated, waiting for space\n", bp.broker.ID())
 				if err := bp.waitForSpace(msg, false); err != nil {
@@ -977,16 +1401,12 @@ func (bp *brokerProducer) run() {
 				bp.parent.returnError(msg, err)
 				continue
 			}
-
-			if bp.parent.conf.Producer.Flush.Frequency > 0 && bp.timer == nil {
-				bp.timer = time.NewTimer(bp.parent.conf.Producer.Flush.Frequency)
-				timerChan = bp.timer.C
-			}
-		case <-timerChan:
+			bp.startTimer()
+		case <-bp.timerChan():
 			bp.timerFired = true
 		case output <- bp.buffer:
+			bp.trackInFlight(bp.buffer, 1)
 			bp.rollOver()
-			timerChan = nil
 		case response, ok := <-bp.responses:
 			if ok {
 				bp.handleResponse(response)
@@ -1002,11 +1422,17 @@ func (bp *brokerProducer) run() {
 }
 
 func (bp *brokerProducer) shutdown() {
-	for !bp.buffer.empty() {
+	for !bp.buffer.empty() || bp.parkedCount > 0 {
+		// parked messages are only released by responses, don't send empty buffers meanwhile
+		output := bp.output
+		if bp.buffer.empty() {
+			output = nil
+		}
 		select {
 		case response := <-bp.responses:
 			bp.handleResponse(response)
-		case bp.output <- bp.buffer:
+		case output <- bp.buffer:
+			bp.trackInFlight(bp.buffer, 1)
 			bp.rollOver()
 		}
 	}
@@ -1039,12 +1465,114 @@ func (bp *brokerProducer) waitForSpace(msg *ProducerMessage, forceRollover bool)
 				return nil
 			}
 		case bp.output <- bp.buffer:
+			bp.trackInFlight(bp.buffer, 1)
 			bp.rollOver()
 			return nil
 		}
 	}
 }
 
+func (bp *brokerProducer) startTimer() {
+	if bp.parent.conf.Producer.Flush.Frequency > 0 && bp.timer == nil {
+		bp.timer = time.NewTimer(bp.parent.conf.Producer.Flush.Frequency)
+	}
+}
+
+func (bp *brokerProducer) timerChan() <-chan time.Time {
+	if bp.timer == nil {
+		return nil
+	}
+	return bp.timer.C
+}
+
+// mustPark reports whether msg has to be held back because its partition reached
+// Producer.MaxInFlightPerPartition, or already has messages held back.
+func (bp *brokerProducer) mustPark(msg *ProducerMessage) bool {
+	max := bp.parent.conf.Producer.MaxInFlightPerPartition
+	if max <= 0 {
+		return false
+	}
+	return len(bp.parked[msg.Topic][msg.Partition]) > 0 || bp.inFlightBatches[msg.Topic][msg.Partition] >= max
+}
+
+func (bp *brokerProducer) park(msg *ProducerMessage) {
+	if bp.parked[msg.Topic] == nil {
+		bp.parked[msg.Topic] = make(map[int32][]*ProducerMessage)
+	}
+	bp.parked[msg.Topic][msg.Partition] = append(bp.parked[msg.Topic][msg.Partition], msg)
+	bp.parkedCount++
+}
+
+// unpark moves the messages held back for partitions that are below
+// Producer.MaxInFlightPerPartition again into the buffer, or retries them if
+// their partition is now being retried.
+func (bp *brokerProducer) unpark() {
+	if bp.closing != nil {
+		// the connection is closing, the held back messages fail rather than being sent on it
+		for _, partitions := range bp.parked {
+			for _, msgs := range partitions {
+				bp.parent.returnErrors(msgs, bp.closing)
+			}
+		}
+		bp.parked = make(map[string]map[int32][]*ProducerMessage)
+		bp.parkedCount = 0
+		return
+	}
+
+	max := bp.parent.conf.Producer.MaxInFlightPerPartition
+	for topic, partitions := range bp.parked {
+		for partition, msgs := range partitions {
+			if reason := bp.needsRetry(msgs[0]); reason != nil {
+				delete(partitions, partition)
+				bp.parkedCount -= len(msgs)
+				bp.parent.retryMessages(msgs, reason)
+				continue
+			}
+			if bp.inFlightBatches[topic][partition] >= max {
+				continue
+			}
+
+			added := 0
+			for _, msg := range msgs {
+				if bp.buffer.wouldOverflow(msg) ||
+					(bp.parent.txnmgr.producerID != noProducerID && bp.buffer.producerEpoch != msg.producerEpoch) {
+					// the rest is added after the buffer was flushed and acknowledged
+					break
+				}
+				if err := bp.buffer.add(msg); err != nil {
+					bp.parent.returnError(msg, err)
+				}
+				added++
+			}
+			bp.parkedCount -= added
+			if added == len(msgs) {
+				delete(partitions, partition)
+			} else {
+				partitions[partition] = msgs[added:]
+			}
+		}
+	}
+	if !bp.buffer.empty() {
+		bp.startTimer()
+	}
+}
+
+// trackInFlight adds delta to the unacknowledged batch count of every partition in the set.
+func (bp *brokerProducer) trackInFlight(set *produceSet, delta int) {
+	if bp.parent.conf.Producer.MaxInFlightPerPartition <= 0 || set == nil {
+		return
+	}
+	set.eachPartition(func(topic string, partition int32, _ *partitionSet) {
+		if bp.inFlightBatches[topic] == nil {
+			bp.inFlightBatches[topic] = make(map[int32]int)
+		}
+		bp.inFlightBatches[topic][partition] += delta
+		if bp.inFlightBatches[topic][partition] <= 0 {
+			delete(bp.inFlightBatches[topic], partition)
+		}
+	})
+}
+
 func (bp *brokerProducer) rollOver() {
 	if bp.timer != nil {
 		bp.timer.Stop()
@@ -1055,6 +1583,8 @@ func (bp *brokerProducer) rollOver() {
 }
 
 func (bp *brokerProducer) handleResponse(response *brokerProducerResponse) {
+	bp.trackInFlight(response.set, -1)
+
 	if response.err != nil {
 		bp.handleError(response.set, response.err)
 	} else {
@@ -1064,6 +1594,10 @@ func (bp *brokerProducer) handleResponse(response *brokerProducerResponse) {
 	if bp.buffer.empty() {
 		bp.rollOver() // this can happen if the response invalidated our buffer
 	}
+
+	if bp.parkedCount > 0 {
+		bp.unpark()
+	}
 }
 
 func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceResponse) {
@@ -1089,10 +1623,17 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 			if bp.parent.conf.Version.IsAtLeast(V0_10_0_0) && !block.Timestamp.IsZero() {
 				for _, msg := range pSet.msgs {
 					msg.Timestamp = block.Timestamp
+					msg.LogAppendTime = true
 				}
 			}
+			lastOffset := block.Offset + int64(len(pSet.msgs)-1)
+			if pSet.encoded {
+				lastOffset = block.Offset + int64(pSet.recordsToSend.RecordBatch.LastOffsetDelta)
+			}
 			for i, msg := range pSet.msgs {
 				msg.Offset = block.Offset + int64(i)
+				msg.BatchFirstOffset = block.Offset
+				msg.BatchLastOffset = lastOffset
 			}
 			bp.parent.returnSuccesses(pSet.msgs)
 		// Duplicate
@@ -1112,7 +1653,11 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 			if bp.parent.conf.Producer.Retry.Max <= 0 {
 				bp.parent.abandonBrokerConnection(bp.broker)
 			}
-			bp.parent.returnErrors(pSet.msgs, block.Err)
+			if len(block.RecordErrors) > 0 || block.ErrorMessage != nil {
+				bp.parent.returnBlockErrors(pSet.msgs, block)
+			} else {
+				bp.parent.returnErrors(pSet.msgs, block.Err)
+			}
 		}
 	})
 
@@ -1136,6 +1681,10 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 				ErrRequestTimedOut, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend:
 				Logger.Printf("producer/broker/%d state change to [retrying] on %s/%d because %v\n",
 					bp.broker.ID(), topic, partition, block.Err)
+				if block.Err == ErrNotLeaderForPartition && bp.parent.conf.Version.IsAtLeast(V2_1_0_0) {
+					// leader epochs are only reported by metadata v7 and later
+					bp.parent.leaderEpochs.fence(topic, partition)
+				}
 				if bp.currentRetries[topic] == nil {
 					bp.currentRetries[topic] = make(map[int32]error)
 				}
@@ -1202,6 +1751,80 @@ func (bp *brokerProducer) handleError(sent *produceSet, err error) {
 	}
 }
 
+// unackedMessagesCompactMin is the length under which the list of unackedMessages is not
+// compacted.
+const unackedMessagesCompactMin = 1024
+
+// unackedMessages tracks the messages accepted by the producer that have not yet been
+// returned as a success or an error, so that they can be reported by CloseContext. Messages
+// are only added by the dispatcher, which gives each of them a new ticket, and acknowledged by
+// resetting it, so that the goroutines returning them never contend on a lock. The entries
+// whose ticket is no longer the one of their message, acknowledged or sent again since, are
+// dropped from the list once it doubled in size since the last time they were.
+type unackedMessages struct {
+	lock      sync.Mutex // only contended when listing the messages
+	entries   []unackedMessage
+	compactAt int
+	ticket    uint32
+}
+
+type unackedMessage struct {
+	msg    *ProducerMessage
+	ticket uint32
+}
+
+func newUnackedMessages() *unackedMessages {
+	return &unackedMessages{compactAt: unackedMessagesCompactMin}
+}
+
+func (u *unackedMessages) add(msg *ProducerMessage) {
+	u.lock.Lock()
+	if u.ticket++; u.ticket == 0 {
+		u.ticket++
+	}
+	atomic.StoreUint32(&msg.unacked, u.ticket)
+	if len(u.entries) >= u.compactAt {
+		u.compact()
+	}
+	u.entries = append(u.entries, unackedMessage{msg: msg, ticket: u.ticket})
+	u.lock.Unlock()
+}
+
+func (u *unackedMessages) remove(msg *ProducerMessage) {
+	atomic.StoreUint32(&msg.unacked, 0)
+}
+
+// compact drops the stale entries from the list. u.lock must be held by caller.
+func (u *unackedMessages) compact() {
+	entries := u.entries[:0]
+	for _, entry := range u.entries {
+		if atomic.LoadUint32(&entry.msg.unacked) == entry.ticket {
+			entries = append(entries, entry)
+		}
+	}
+	for i := len(entries); i < len(u.entries); i++ {
+		u.entries[i] = unackedMessage{}
+	}
+	u.entries = entries
+	u.compactAt = 2 * len(entries)
+	if u.compactAt < unackedMessagesCompactMin {
+		u.compactAt = unackedMessagesCompactMin
+	}
+}
+
+func (u *unackedMessages) list() []*ProducerMessage {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	var msgs []*ProducerMessage
+	for _, entry := range u.entries {
+		if atomic.LoadUint32(&entry.msg.unacked) == entry.ticket {
+			msgs = append(msgs, entry.msg)
+		}
+	}
+	return msgs
+}
+
 // singleton
 // effectively a "bridge" between the flushers and the dispatcher in order to avoid deadlock
 // based on https://godoc.org/github.com/eapache/channels#InfiniteChannel
@@ -1281,6 +1904,11 @@ func (p *asyncProducer) maybeTransitionToErrorState(err error) error {
 }
 
 func (p *asyncProducer) returnError(msg *ProducerMessage, err error) {
+	p.returnProducerError(&ProducerError{Msg: msg, Err: err})
+}
+
+func (p *asyncProducer) returnProducerError(pErr *ProducerError) {
+	msg, err := pErr.Msg, pErr.Err
 	if p.IsTransactional() {
 		_ = p.maybeTransitionToErrorState(err)
 	}
@@ -1292,7 +1920,7 @@ func (p *asyncProducer) returnError(msg *ProducerMessage, err error) {
 	}
 
 	msg.clear()
-	pErr := &ProducerError{Msg: msg, Err: err}
+	p.unacked.remove(msg)
 	if p.conf.Producer.Return.Errors {
 		p.errors <- pErr
 	} else {
@@ -1307,8 +1935,27 @@ func (p *asyncProducer) returnErrors(batch []*ProducerMessage, err error) {
 	}
 }
 
+// returnBlockErrors fails a batch rejected by the broker, attaching the record-level
+// details of the produce response block to each message of the batch.
+func (p *asyncProducer) returnBlockErrors(batch []*ProducerMessage, block *ProduceResponseBlock) {
+	for i, msg := range batch {
+		pErr := &ProducerError{Msg: msg, Err: block.Err}
+		if recordError := block.recordError(int32(i)); recordError != nil {
+			pErr.InvalidRecord = true
+			if recordError.BatchIndexErrorMessage != nil {
+				pErr.ErrorMessage = *recordError.BatchIndexErrorMessage
+			}
+		}
+		if pErr.ErrorMessage == "" && block.ErrorMessage != nil {
+			pErr.ErrorMessage = *block.ErrorMessage
+		}
+		p.returnProducerError(pErr)
+	}
+}
+
 func (p *asyncProducer) returnSuccesses(batch []*ProducerMessage) {
 	for _, msg := range batch {
+		p.unacked.remove(msg)
 		if p.conf.Producer.Return.Successes {
 			msg.clear()
 			p.successes <- msg
@@ -1375,3 +2022,110 @@ func (p *asyncProducer) abandonBrokerConnection(broker *Broker) {
 
 	delete(p.brokers, broker)
 }
+
+// topicConfigCache fetches the configuration of topics with DescribeConfigs requests, keeping it
+// for Metadata.RefreshFrequency, or forever if that is 0. The topics the principal is not allowed
+// to describe are cached without configuration, so that the checks relying on it are skipped
+// rather than failing every message.
+type topicConfigCache struct {
+	client Client
+
+	lock    sync.Mutex
+	configs map[string]map[string]string
+	fetched map[string]time.Time
+	// fetches are the DescribeConfigs requests in flight by topic, shared by the concurrent
+	// misses of the topic, the lock not being held while they are sent
+	fetches map[string]*topicConfigFetch
+}
+
+type topicConfigFetch struct {
+	done    chan none
+	configs map[string]string
+	err     error
+}
+
+func newTopicConfigCache(client Client) *topicConfigCache {
+	return &topicConfigCache{
+		client:  client,
+		configs: make(map[string]map[string]string),
+		fetched: make(map[string]time.Time),
+		fetches: make(map[string]*topicConfigFetch),
+	}
+}
+
+func (c *topicConfigCache) get(topic string) (map[string]string, error) {
+	c.lock.Lock()
+	ttl := c.client.Config().Metadata.RefreshFrequency
+	if configs, ok := c.configs[topic]; ok && (ttl == 0 || time.Since(c.fetched[topic]) < ttl) {
+		c.lock.Unlock()
+		return configs, nil
+	}
+	if fetch, ok := c.fetches[topic]; ok {
+		c.lock.Unlock()
+		<-fetch.done
+		return fetch.configs, fetch.err
+	}
+	fetch := &topicConfigFetch{done: make(chan none)}
+	c.fetches[topic] = fetch
+	c.lock.Unlock()
+
+	fetch.configs, fetch.err = c.describe(topic)
+	if errors.Is(fetch.err, ErrTopicAuthorizationFailed) || errors.Is(fetch.err, ErrClusterAuthorizationFailed) {
+		Logger.Printf("producer/config not allowed to describe the configuration of topic %s, skipping the checks relying on it: %v\n", topic, fetch.err)
+		fetch.configs, fetch.err = map[string]string{}, nil
+	}
+
+	c.lock.Lock()
+	delete(c.fetches, topic)
+	if fetch.err == nil {
+		c.configs[topic] = fetch.configs
+		c.fetched[topic] = time.Now()
+	}
+	c.lock.Unlock()
+	close(fetch.done)
+
+	return fetch.configs, fetch.err
+}
+
+func (c *topicConfigCache) describe(topic string) (map[string]string, error) {
+	conf := c.client.Config()
+	broker := c.client.LeastLoadedBroker()
+	if broker == nil {
+		return nil, ErrOutOfBrokers
+	}
+
+	request := &DescribeConfigsRequest{
+		Resources: []*ConfigResource{{Type: TopicResource, Name: topic}},
+	}
+	if conf.Version.IsAtLeast(V1_1_0_0) {
+		request.Version = 1
+	}
+	if conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 2
+	}
+
+	response, err := broker.DescribeConfigs(request)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]string)
+	for _, resource := range response.Resources {
+		if resource.Name != topic {
+			continue
+		}
+		if resource.ErrorCode != 0 {
+			if resource.ErrorMsg != "" {
+				return nil, fmt.Errorf("%w: %s", KError(resource.ErrorCode), resource.ErrorMsg)
+			}
+			return nil, KError(resource.ErrorCode)
+		}
+		if resource.ErrorMsg != "" {
+			return nil, errors.New(resource.ErrorMsg)
+		}
+		for _, entry := range resource.Configs {
+			configs[entry.Name] = entry.Value
+		}
+	}
+	return configs, nil
+}
@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"log"
 	"math"
@@ -187,6 +188,646 @@ done:
 	seedBroker.Close()
 }
 
+func TestBrokerProducerMaxInFlightPerPartition(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.MaxInFlightPerPartition = 1
+	txnmgr, _ := newTransactionManager(config, nil)
+	p := &asyncProducer{conf: config, txnmgr: txnmgr, unacked: newUnackedMessages()}
+
+	input := make(chan *ProducerMessage)
+	output := make(chan *produceSet)
+	responses := make(chan *brokerProducerResponse)
+	bp := &brokerProducer{
+		parent:          p,
+		broker:          &Broker{id: 1},
+		input:           input,
+		output:          output,
+		responses:       responses,
+		buffer:          newProduceSet(p),
+		currentRetries:  make(map[string]map[int32]error),
+		inFlightBatches: make(map[string]map[int32]int),
+		parked:          make(map[string]map[int32][]*ProducerMessage),
+	}
+	done := make(chan none)
+	go func() {
+		bp.run()
+		close(done)
+	}()
+
+	ack := func(set *produceSet) {
+		res := new(ProduceResponse)
+		set.eachPartition(func(topic string, partition int32, _ *partitionSet) {
+			res.AddTopicPartition(topic, partition, ErrNoError)
+		})
+		responses <- &brokerProducerResponse{set: set, res: res}
+	}
+	count := func(set *produceSet, partition int32) int {
+		if pSet := set.msgs["my_topic"][partition]; pSet != nil {
+			return len(pSet.msgs)
+		}
+		return 0
+	}
+
+	p.inFlight.Add(4)
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage)}
+	first := <-output
+	if count(first, 0) != 1 {
+		t.Fatalf("expected the first batch to hold 1 message for partition 0, got %d", count(first, 0))
+	}
+
+	// partition 0 is at its limit, so only partition 1 may be sent until the first batch is acknowledged
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage)}
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage)}
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 1, Value: StringEncoder(TestMessage)}
+	second := <-output
+	if count(second, 0) != 0 || count(second, 1) != 1 {
+		t.Fatalf("expected the second batch to only hold partition 1, got %d and %d", count(second, 0), count(second, 1))
+	}
+
+	ack(first)
+	third := <-output
+	if count(third, 0) != 2 || count(third, 1) != 0 {
+		t.Fatalf("expected the third batch to hold the 2 held back messages, got %d and %d", count(third, 0), count(third, 1))
+	}
+	ack(second)
+	ack(third)
+
+	close(input)
+	close(responses)
+	<-done
+	p.inFlight.Wait()
+}
+
+func TestBrokerProducerUnparkClosing(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.MaxInFlightPerPartition = 1
+	txnmgr, _ := newTransactionManager(config, nil)
+	p := &asyncProducer{
+		conf:    config,
+		txnmgr:  txnmgr,
+		unacked: newUnackedMessages(),
+		errors:  make(chan *ProducerError, 2),
+		retries: make(chan *ProducerMessage, 1),
+	}
+
+	input := make(chan *ProducerMessage)
+	output := make(chan *produceSet)
+	responses := make(chan *brokerProducerResponse)
+	bp := &brokerProducer{
+		parent:          p,
+		broker:          &Broker{id: 1},
+		input:           input,
+		output:          output,
+		responses:       responses,
+		buffer:          newProduceSet(p),
+		currentRetries:  make(map[string]map[int32]error),
+		inFlightBatches: make(map[string]map[int32]int),
+		parked:          make(map[string]map[int32][]*ProducerMessage),
+	}
+	done := make(chan none)
+	go func() {
+		bp.run()
+		close(done)
+	}()
+
+	p.inFlight.Add(3)
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage)}
+	first := <-output
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage)}
+	input <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage)}
+
+	// the first batch fails, closing the connection, so the held back messages must not be sent
+	responses <- &brokerProducerResponse{set: first, err: ErrBrokerNotAvailable}
+	if msg := <-p.retries; msg.retries != 1 {
+		t.Errorf("expected the sent message to be retried, got %d retries", msg.retries)
+	}
+	p.inFlight.Done()
+	for i := 0; i < 2; i++ {
+		if pErr := <-p.errors; !errors.Is(pErr.Err, ErrBrokerNotAvailable) {
+			t.Errorf("expected the held back message to fail with ErrBrokerNotAvailable, got %v", pErr.Err)
+		}
+	}
+
+	close(input)
+	close(responses)
+	<-done
+	p.inFlight.Wait()
+}
+
+func TestAsyncProducerTimestampValidation(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Resources: []*ResourceResponse{{
+				Type: TopicResource,
+				Name: "my_topic",
+				Configs: []*ConfigEntry{
+					{Name: "message.timestamp.type", Value: "CreateTime"},
+					{Name: "message.timestamp.difference.max.ms", Value: "3600000"},
+				},
+			}},
+		}),
+		"ProduceRequest": NewMockProduceResponse(t).SetVersion(3),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Timestamp.Validate = true
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, timestamp := range []time.Time{time.Now().Add(-2 * time.Hour), time.Unix(-1, 0)} {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Timestamp: timestamp}
+		select {
+		case pErr := <-producer.Errors():
+			var tErr *TimestampError
+			if !errors.As(pErr.Err, &tErr) || !errors.Is(pErr.Err, ErrInvalidTimestamp) {
+				t.Errorf("expected a TimestampError, got %v", pErr.Err)
+			}
+		case <-producer.Successes():
+			t.Errorf("expected timestamp %v to be rejected", timestamp)
+		}
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Timestamp: time.Now().Add(-time.Minute)}
+	select {
+	case pErr := <-producer.Errors():
+		t.Error(pErr.Err)
+	case <-producer.Successes():
+	}
+
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerCheckMessage(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Resources: []*ResourceResponse{{
+				Type:    TopicResource,
+				Name:    "my_topic",
+				Configs: []*ConfigEntry{{Name: "max.message.bytes", Value: "200"}},
+			}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Partitioner = NewManualPartitioner
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+
+	if err := producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}); err != nil {
+		t.Error(err)
+	}
+	err = producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Value: ByteEncoder(make([]byte, 300))})
+	if !errors.Is(err, ErrMessageSizeTooLarge) {
+		t.Errorf("expected ErrMessageSizeTooLarge, got %v", err)
+	}
+	if err := producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Partition: 1, Value: StringEncoder(TestMessage)}); err == nil {
+		t.Error("expected an error for a partition without leader")
+	}
+
+	describes := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*DescribeConfigsRequest); ok {
+			describes++
+		}
+	}
+	if describes != 1 {
+		t.Errorf("expected the topic configuration to be described once, got %d requests", describes)
+	}
+}
+
+func TestAsyncProducerCheckMessageDescribeConfigsDenied(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Resources: []*ResourceResponse{{
+				ErrorCode: int16(ErrTopicAuthorizationFailed),
+				ErrorMsg:  "Topic authorization failed",
+				Type:      TopicResource,
+				Name:      "my_topic",
+			}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.MaxMessageBytes = 200
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+
+	for i := 0; i < 2; i++ {
+		if err := producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}); err != nil {
+			t.Error(err)
+		}
+	}
+	err = producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Value: ByteEncoder(make([]byte, 300))})
+	if !errors.Is(err, ErrMessageSizeTooLarge) {
+		t.Errorf("expected ErrMessageSizeTooLarge from Producer.MaxMessageBytes, got %v", err)
+	}
+
+	describes := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*DescribeConfigsRequest); ok {
+			describes++
+		}
+	}
+	if describes != 1 {
+		t.Errorf("expected the denial to be cached, got %d requests", describes)
+	}
+}
+
+// slowDescribeConfigsResponse holds the DescribeConfigs requests of the slow topic until release is closed,
+// signalling started when it begins to.
+type slowDescribeConfigsResponse struct {
+	slow    string
+	started chan none
+	release chan none
+}
+
+func (r *slowDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	name := reqBody.(*DescribeConfigsRequest).Resources[0].Name
+	if name == r.slow {
+		r.started <- none{}
+		<-r.release
+	}
+	return &DescribeConfigsResponse{
+		Resources: []*ResourceResponse{{
+			Type:    TopicResource,
+			Name:    name,
+			Configs: []*ConfigEntry{{Name: "max.message.bytes", Value: "200"}},
+		}},
+	}
+}
+
+func TestAsyncProducerCheckMessageSlowDescribe(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	describeConfigs := &slowDescribeConfigsResponse{slow: "slow_topic", started: make(chan none, 2), release: make(chan none)}
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()).
+			SetLeader("slow_topic", 0, broker.BrokerID()),
+		"DescribeConfigsRequest": describeConfigs,
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+
+	if err := producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := producer.CheckMessage(&ProducerMessage{Topic: "slow_topic", Value: StringEncoder(TestMessage)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	select {
+	case <-describeConfigs.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("slow_topic was not described")
+	}
+
+	checked := make(chan error, 1)
+	go func() {
+		checked <- producer.CheckMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)})
+	}()
+	select {
+	case err := <-checked:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("CheckMessage on my_topic blocked behind the slow_topic DescribeConfigs")
+	}
+
+	close(describeConfigs.release)
+	wg.Wait()
+
+	describes := 0
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*DescribeConfigsRequest); ok && req.Resources[0].Name == "slow_topic" {
+			describes++
+		}
+	}
+	if describes != 1 {
+		t.Errorf("expected the concurrent misses on slow_topic to share one request, got %d", describes)
+	}
+}
+
+func TestUnackedMessages(t *testing.T) {
+	u := newUnackedMessages()
+	sent, resent := &ProducerMessage{}, &ProducerMessage{}
+	u.add(sent)
+	u.add(resent)
+	u.remove(resent)
+	u.add(resent)
+
+	for i := 0; i < 2*unackedMessagesCompactMin; i++ {
+		msg := &ProducerMessage{}
+		u.add(msg)
+		u.remove(msg)
+	}
+	if len(u.entries) > unackedMessagesCompactMin {
+		t.Errorf("expected the acknowledged messages to be dropped, got %d entries", len(u.entries))
+	}
+
+	msgs := u.list()
+	if len(msgs) != 2 || msgs[0] != sent || msgs[1] != resent {
+		t.Errorf("expected the sent and resent messages to be unacknowledged once, got %v", msgs)
+	}
+	u.remove(sent)
+	if msgs := u.list(); len(msgs) != 1 || msgs[0] != resent {
+		t.Errorf("expected the resent message to be unacknowledged, got %v", msgs)
+	}
+}
+
+// BenchmarkUnackedMessages measures the tracking of the messages on the steady-state path: the
+// dispatcher accepting messages, and the broker producers acknowledging them concurrently.
+func BenchmarkUnackedMessages(b *testing.B) {
+	const inFlight = 4096
+	msgs := make([]*ProducerMessage, inFlight)
+	for i := range msgs {
+		msgs[i] = &ProducerMessage{}
+	}
+
+	b.Run("accept", func(b *testing.B) {
+		u := newUnackedMessages()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			msg := msgs[i%inFlight]
+			u.remove(msg)
+			u.add(msg)
+		}
+	})
+
+	b.Run("ack", func(b *testing.B) {
+		u := newUnackedMessages()
+		for _, msg := range msgs {
+			u.add(msg)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				u.remove(msgs[i%inFlight])
+			}
+		})
+	})
+}
+
+func TestAsyncProducerEncodedBatch(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := &MetadataResponse{Version: 1}
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := &ProduceResponse{Version: 3}
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	prodSuccess.GetBlock("my_topic", 0).Offset = 100
+	leader.Returns(prodSuccess)
+
+	encoded, err := encode(&RecordBatch{
+		Version:         2,
+		Codec:           CompressionGZIP,
+		LastOffsetDelta: 2,
+		Records: []*Record{
+			{Value: []byte("a")},
+			{Value: []byte("b"), OffsetDelta: 1},
+			{Value: []byte("c"), OffsetDelta: 2},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", EncodedBatch: encoded, Value: StringEncoder(TestMessage)}
+	select {
+	case pErr := <-producer.Errors():
+		var cErr ConfigurationError
+		if !errors.As(pErr.Err, &cErr) {
+			t.Errorf("expected a ConfigurationError, got %v", pErr.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an error for an encoded batch with a value")
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", EncodedBatch: encoded}
+	select {
+	case pErr := <-producer.Errors():
+		t.Error(pErr.Err)
+	case msg := <-producer.Successes():
+		if msg.Offset != 100 || msg.BatchFirstOffset != 100 || msg.BatchLastOffset != 102 {
+			t.Errorf("expected offsets [100, 102], got %d [%d, %d]", msg.Offset, msg.BatchFirstOffset, msg.BatchLastOffset)
+		}
+	}
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
+func TestAsyncProducerLogAppendTime(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := &MetadataResponse{Version: 1}
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	logAppendTime := time.Unix(1600000000, 0)
+	prodSuccess := &ProduceResponse{Version: 2}
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	block := prodSuccess.GetBlock("my_topic", 0)
+	block.Offset = 100
+	block.Timestamp = logAppendTime
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Version = V0_10_0_0
+	config.Producer.Flush.Messages = 3
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-producer.Errors():
+			t.Error(msg.Err)
+		case msg := <-producer.Successes():
+			if !msg.LogAppendTime || !msg.Timestamp.Equal(logAppendTime) {
+				t.Errorf("expected LogAppendTime %v, got %v (%v)", logAppendTime, msg.Timestamp, msg.LogAppendTime)
+			}
+			if msg.Offset != int64(100+i) {
+				t.Errorf("expected offset %d, got %d", 100+i, msg.Offset)
+			}
+			if msg.BatchFirstOffset != 100 || msg.BatchLastOffset != 102 {
+				t.Errorf("expected batch offsets [100, 102], got [%d, %d]", msg.BatchFirstOffset, msg.BatchLastOffset)
+			}
+		}
+	}
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
+func TestAsyncProducerRecordErrors(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := &MetadataResponse{Version: 9}
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	invalidTimestamp, batchRejected := "timestamp out of range", "batch rejected"
+	prodRejected := &ProduceResponse{Version: 8}
+	prodRejected.AddTopicPartition("my_topic", 0, ErrInvalidTimestamp)
+	block := prodRejected.GetBlock("my_topic", 0)
+	block.RecordErrors = []*ProduceResponseRecordError{{BatchIndex: 1, BatchIndexErrorMessage: &invalidTimestamp}}
+	block.ErrorMessage = &batchRejected
+	leader.Returns(prodRejected)
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.ApiVersionsRequest = false
+	config.Producer.Flush.Messages = 3
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Metadata: i}
+	}
+	for i := 0; i < 3; i++ {
+		pErr := <-producer.Errors()
+		if !errors.Is(pErr, ErrInvalidTimestamp) {
+			t.Errorf("expected ErrInvalidTimestamp, got %v", pErr.Err)
+		}
+		if pErr.Msg.Metadata.(int) == 1 {
+			if !pErr.InvalidRecord || pErr.ErrorMessage != invalidTimestamp {
+				t.Errorf("expected message 1 to be reported as the invalid record, got %+v", pErr)
+			}
+		} else if pErr.InvalidRecord || pErr.ErrorMessage != batchRejected {
+			t.Errorf("expected message %d to carry the batch error, got %+v", pErr.Msg.Metadata, pErr)
+		}
+	}
+
+	closeProducer(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
+func TestAsyncProducerCloseContext(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 5
+	config.Producer.Flush.MaxMessages = 5
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first batch is acknowledged, the second one never is
+	for i := 0; i < 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Metadata: i}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err = producer.CloseContext(ctx)
+
+	var pErrs ProducerErrors
+	if !errors.As(err, &pErrs) {
+		t.Fatalf("expected ProducerErrors, got %v", err)
+	}
+	if len(pErrs) != 5 {
+		t.Fatalf("expected 5 unacknowledged messages, got %d", len(pErrs))
+	}
+	for _, pErr := range pErrs {
+		if !errors.Is(pErr.Err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", pErr.Err)
+		}
+		if pErr.Msg.Metadata.(int) < 5 {
+			t.Errorf("message %d was acknowledged but reported as unacknowledged", pErr.Msg.Metadata)
+		}
+	}
+
+	leader.Close()
+	seedBroker.Close()
+}
+
 func TestAsyncProducerMultipleFlushes(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 2)
@@ -616,6 +1257,56 @@ func TestAsyncProducerMultipleRetries(t *testing.T) {
 	closeProducer(t, producer)
 }
 
+func TestAsyncProducerNotLeaderWithStaleLeaderEpoch(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader1 := NewMockBroker(t, 2)
+	leader2 := NewMockBroker(t, 3)
+
+	metadataLeader1 := &MetadataResponse{Version: 7}
+	metadataLeader1.AddBroker(leader1.Addr(), leader1.BrokerID())
+	metadataLeader1.AddTopicPartition("my_topic", 0, leader1.BrokerID(), nil, nil, nil, ErrNoError)
+	metadataLeader1.Topics[0].Partitions[0].LeaderEpoch = 1
+	seedBroker.Returns(metadataLeader1)
+
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Producer.Flush.Messages = 10
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 1
+	config.Producer.Retry.Backoff = 0
+	config.Metadata.Retry.Backoff = 0
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	}
+	prodNotLeader := &ProduceResponse{Version: 3}
+	prodNotLeader.AddTopicPartition("my_topic", 0, ErrNotLeaderForPartition)
+	leader1.Returns(prodNotLeader)
+
+	// the first refresh still reports the fenced epoch and must not be used
+	seedBroker.Returns(metadataLeader1)
+
+	metadataLeader2 := &MetadataResponse{Version: 7}
+	metadataLeader2.AddBroker(leader2.Addr(), leader2.BrokerID())
+	metadataLeader2.AddTopicPartition("my_topic", 0, leader2.BrokerID(), nil, nil, nil, ErrNoError)
+	metadataLeader2.Topics[0].Partitions[0].LeaderEpoch = 2
+	seedBroker.Returns(metadataLeader2)
+
+	prodSuccess := &ProduceResponse{Version: 3}
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader2.Returns(prodSuccess)
+	expectResults(t, producer, 10, 0)
+
+	closeProducer(t, producer)
+	seedBroker.Close()
+	leader1.Close()
+	leader2.Close()
+}
+
 func TestAsyncProducerMultipleRetriesWithBackoffFunc(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader1 := NewMockBroker(t, 2)
@@ -1780,7 +2471,7 @@ func TestTxnProduceBumpEpoch(t *testing.T) {
 	config.ApiVersionsRequest = false
 
 	metadataLeader := new(MetadataResponse)
-	metadataLeader.Version = 7
+	metadataLeader.Version = 9
 	metadataLeader.ControllerID = broker.brokerID
 	metadataLeader.AddBroker(broker.Addr(), broker.BrokerID())
 	metadataLeader.AddTopic("test-topic", ErrNoError)
@@ -1825,7 +2516,7 @@ func TestTxnProduceBumpEpoch(t *testing.T) {
 	broker.Returns(addPartitionsToTxnResponse)
 
 	produceResponse := new(ProduceResponse)
-	produceResponse.Version = 3
+	produceResponse.Version = 8
 	produceResponse.AddTopicPartition("test-topic", 0, ErrOutOfOrderSequenceNumber)
 	broker.Returns(produceResponse)
 
@@ -19,6 +19,14 @@ const (
 	// StickyBalanceStrategyName identifies strategies that use the sticky-partition assignment strategy
 	StickyBalanceStrategyName = "sticky"
 
+	// CooperativeStickyBalanceStrategyName identifies strategies that use the cooperative sticky-partition
+	// assignment strategy
+	CooperativeStickyBalanceStrategyName = "cooperative-sticky"
+
+	// LagAwareBalanceStrategyName identifies strategies that use the lag-aware partition assignment
+	// strategy
+	LagAwareBalanceStrategyName = "lag-aware"
+
 	defaultGeneration = -1
 )
 
@@ -55,6 +63,48 @@ type BalanceStrategy interface {
 	AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error)
 }
 
+// CooperativeBalanceStrategy is implemented by balance strategies following the
+// cooperative rebalance protocol of KIP-429: their plans never assign a partition
+// to a member while another member still owns it, such partitions are left
+// unassigned until their owner has revoked them and a follow-up rebalance runs.
+//
+// When all the strategies of Config.Consumer.Group.Rebalance.GroupStrategies are
+// cooperative, members keep consuming the partitions they retain across rebalances
+// and only stop the claims of the partitions they lose.
+type CooperativeBalanceStrategy interface {
+	BalanceStrategy
+
+	// SupportsCooperativeRebalance marks the strategy as cooperative.
+	SupportsCooperativeRebalance()
+}
+
+// UserDataBalanceStrategy is implemented by balance strategies exchanging custom
+// data between the members of a group and its leader, e.g. the current load of
+// the members or the state they hold, to compute load-aware or state-sticky plans.
+//
+// The user data returned by SubscriptionUserData is sent by each member when
+// joining the group and handed to the leader's PlanWithUserData, which is used
+// instead of Plan and AssignmentData, in ConsumerGroupMemberMetadata.UserData.
+// The assignment user data returned by PlanWithUserData is sent to each member
+// along with its assignment, and passed to OnAssignment.
+type UserDataBalanceStrategy interface {
+	BalanceStrategy
+
+	// SubscriptionUserData returns the user data sent by this member when joining
+	// the group, which takes precedence over Consumer.Group.Member.UserData.
+	SubscriptionUserData(topics []string) ([]byte, error)
+
+	// PlanWithUserData accepts a map of `memberID -> metadata` and a map of
+	// `topic -> partitions`, and returns a distribution plan along with the
+	// assignment user data of each member, if any.
+	PlanWithUserData(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, map[string][]byte, error)
+
+	// OnAssignment is called on every member once the group is synced, with the
+	// partitions assigned to the member and the assignment user data computed
+	// by the leader.
+	OnAssignment(assignment map[string][]int32, userData []byte, generationID int32)
+}
+
 // --------------------------------------------------------------------
 
 // NewBalanceStrategyRange returns a range balance strategy,
@@ -111,6 +161,23 @@ func NewBalanceStrategySticky() BalanceStrategy {
 // Deprecated: use NewBalanceStrategySticky to avoid data race issue
 var BalanceStrategySticky = NewBalanceStrategySticky()
 
+// NewBalanceStrategyCooperativeSticky returns a cooperative sticky balance strategy,
+// which computes the same plans as the sticky strategy from the partitions members
+// currently own, but only hands over partitions that changed owners once their
+// previous owner has revoked them, see CooperativeBalanceStrategy.
+// Example with topic T with four partitions (0..3), owned by M1, when M2 joins:
+//
+//	M1: {T: [0, 1]}
+//	M2: {}
+//
+// M1 revokes partitions 2 and 3 and rejoins, then the follow-up rebalance gives:
+//
+//	M1: {T: [0, 1]}
+//	M2: {T: [2, 3]}
+func NewBalanceStrategyCooperativeSticky() BalanceStrategy {
+	return &cooperativeStickyBalanceStrategy{}
+}
+
 // --------------------------------------------------------------------
 
 type balanceStrategy struct {
@@ -279,6 +346,89 @@ func (s *stickyBalanceStrategy) AssignmentData(memberID string, topics map[strin
 	}, nil)
 }
 
+type cooperativeStickyBalanceStrategy struct {
+	stickyBalanceStrategy
+}
+
+// Name implements BalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) Name() string { return CooperativeStickyBalanceStrategyName }
+
+// SupportsCooperativeRebalance implements CooperativeBalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) SupportsCooperativeRebalance() {}
+
+// Plan implements BalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	// the partitions owned by members, as reported in their subscription, take precedence
+	// over the assignment recorded in their user data, which may not have been fully granted
+	owners := make(map[topicPartitionAssignment][]string)
+	stickyMembers := make(map[string]ConsumerGroupMemberMetadata, len(members))
+	for memberID, meta := range members {
+		userData, err := deserializeTopicPartitionAssignment(meta.UserData)
+		if err != nil {
+			return nil, err
+		}
+		if meta.Version < 1 {
+			for _, partition := range userData.partitions() {
+				owners[partition] = append(owners[partition], memberID)
+			}
+			stickyMembers[memberID] = meta
+			continue
+		}
+
+		owned := make(map[string][]int32, len(meta.OwnedPartitions))
+		for _, op := range meta.OwnedPartitions {
+			owned[op.Topic] = append(owned[op.Topic], op.Partitions...)
+			for _, partition := range op.Partitions {
+				tp := topicPartitionAssignment{Topic: op.Topic, Partition: partition}
+				owners[tp] = append(owners[tp], memberID)
+			}
+		}
+		if userData.hasGeneration() {
+			meta.UserData, err = encode(&StickyAssignorUserDataV1{Topics: owned, Generation: int32(userData.generation())}, nil)
+		} else {
+			meta.UserData, err = encode(&StickyAssignorUserDataV0{Topics: owned}, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stickyMembers[memberID] = meta
+	}
+
+	plan, err := s.stickyBalanceStrategy.Plan(stickyMembers, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	// leave the partitions changing owners unassigned, their current owners revoke them
+	// when they don't find them in their assignment and rejoin, triggering another rebalance
+	for memberID, assignment := range plan {
+		for topic, partitions := range assignment {
+			granted := partitions[:0]
+			for _, partition := range partitions {
+				if ownedByOthers(owners[topicPartitionAssignment{Topic: topic, Partition: partition}], memberID) {
+					continue
+				}
+				granted = append(granted, partition)
+			}
+			if len(granted) == 0 {
+				delete(assignment, topic)
+			} else {
+				assignment[topic] = granted
+			}
+		}
+	}
+	return plan, nil
+}
+
+func ownedByOthers(owners []string, memberID string) bool {
+	for _, owner := range owners {
+		if owner != memberID {
+			return true
+		}
+	}
+	return false
+}
+
 func strsContains(s []string, value string) bool {
 	for _, entry := range s {
 		if entry == value {
@@ -0,0 +1,216 @@
+package sarama
+
+import "sort"
+
+// PartitionLoadFunc returns the load of the partitions of the given topics consumed by a
+// member, by topic and partition, e.g. their lag or their throughput in messages per second.
+// Partitions the member does not know the load of can be omitted.
+type PartitionLoadFunc func(topics []string) map[string]map[int32]int64
+
+// NewBalanceStrategyLagAware returns a lag-aware balance strategy, which spreads the load of
+// the partitions across members rather than their number. Each member reports the load of the
+// partitions it consumes, as returned by load, in its subscription user data, and the leader
+// assigns the partitions from the most to the least loaded to the member with the lowest total
+// load so far. Each partition weighs its load plus one, so that partitions without load are
+// balanced by count, and partitions no member reported weigh the average reported load.
+// Example with topic T with four partitions (0..3) and two members (M1, M2), where partition
+// 0 has a lag of 1000 and the others a lag of 10:
+//
+//	M1: {T: [0]}
+//	M2: {T: [1, 2, 3]}
+func NewBalanceStrategyLagAware(load PartitionLoadFunc) BalanceStrategy {
+	return &lagAwareBalanceStrategy{load: load}
+}
+
+type lagAwareBalanceStrategy struct {
+	load PartitionLoadFunc
+}
+
+// Name implements BalanceStrategy.
+func (s *lagAwareBalanceStrategy) Name() string { return LagAwareBalanceStrategyName }
+
+// Plan implements BalanceStrategy.
+func (s *lagAwareBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	plan, _, err := s.PlanWithUserData(members, topics)
+	return plan, err
+}
+
+// AssignmentData implements BalanceStrategy.
+func (s *lagAwareBalanceStrategy) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return nil, nil
+}
+
+// SubscriptionUserData implements UserDataBalanceStrategy, encoding the load of the partitions
+// consumed by the member.
+func (s *lagAwareBalanceStrategy) SubscriptionUserData(topics []string) ([]byte, error) {
+	if s.load == nil {
+		return nil, nil
+	}
+	return encode(&partitionLoadUserData{Loads: s.load(topics)}, nil)
+}
+
+// PlanWithUserData implements UserDataBalanceStrategy.
+func (s *lagAwareBalanceStrategy) PlanWithUserData(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, map[string][]byte, error) {
+	// a partition is consumed by a single member, but members may report stale loads
+	loads := make(map[topicPartitionAssignment]int64)
+	for memberID, meta := range members {
+		if len(meta.UserData) == 0 {
+			continue
+		}
+		userData := &partitionLoadUserData{}
+		if err := decode(meta.UserData, userData, nil); err != nil {
+			Logger.Printf("lag-aware balance strategy ignoring invalid user data of member %s: %v\n", memberID, err)
+			continue
+		}
+		for topic, partitions := range userData.Loads {
+			for partition, load := range partitions {
+				if load < 0 {
+					load = 0
+				}
+				tp := topicPartitionAssignment{Topic: topic, Partition: partition}
+				if prev, ok := loads[tp]; !ok || load > prev {
+					loads[tp] = load
+				}
+			}
+		}
+	}
+
+	var reported, total int64
+	weights := make(map[topicPartitionAssignment]int64)
+	for topic, partitions := range topics {
+		for _, partition := range partitions {
+			tp := topicPartitionAssignment{Topic: topic, Partition: partition}
+			if load, ok := loads[tp]; ok {
+				weights[tp] = load + 1
+				reported++
+				total += load
+			}
+		}
+	}
+	unknown := int64(1)
+	if reported > 0 {
+		unknown = total/reported + 1
+	}
+
+	subscribers := make(map[string][]string, len(topics))
+	for memberID, meta := range members {
+		for _, topic := range meta.Topics {
+			subscribers[topic] = append(subscribers[topic], memberID)
+		}
+	}
+
+	partitions := make([]topicPartitionAssignment, 0)
+	for topic, ps := range topics {
+		if len(subscribers[topic]) == 0 {
+			continue
+		}
+		for _, partition := range ps {
+			tp := topicPartitionAssignment{Topic: topic, Partition: partition}
+			if _, ok := weights[tp]; !ok {
+				weights[tp] = unknown
+			}
+			partitions = append(partitions, tp)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		pi, pj := partitions[i], partitions[j]
+		if weights[pi] != weights[pj] {
+			return weights[pi] > weights[pj]
+		}
+		if pi.Topic != pj.Topic {
+			return pi.Topic < pj.Topic
+		}
+		return pi.Partition < pj.Partition
+	})
+
+	plan := make(BalanceStrategyPlan, len(members))
+	memberLoads := make(map[string]int64, len(members))
+	memberCounts := make(map[string]int, len(members))
+	for _, tp := range partitions {
+		var chosen string
+		for _, memberID := range subscribers[tp.Topic] {
+			if chosen == "" || memberLoads[memberID] < memberLoads[chosen] ||
+				(memberLoads[memberID] == memberLoads[chosen] && (memberCounts[memberID] < memberCounts[chosen] ||
+					(memberCounts[memberID] == memberCounts[chosen] && memberID < chosen))) {
+				chosen = memberID
+			}
+		}
+		plan.Add(chosen, tp.Topic, tp.Partition)
+		memberLoads[chosen] += weights[tp]
+		memberCounts[chosen]++
+	}
+	for _, assignment := range plan {
+		for _, ps := range assignment {
+			sort.Sort(int32Slice(ps))
+		}
+	}
+	return plan, nil, nil
+}
+
+// OnAssignment implements UserDataBalanceStrategy.
+func (s *lagAwareBalanceStrategy) OnAssignment(assignment map[string][]int32, userData []byte, generationID int32) {
+}
+
+// partitionLoadUserData is the subscription user data of the lag-aware balance strategy.
+type partitionLoadUserData struct {
+	Loads map[string]map[int32]int64
+}
+
+func (m *partitionLoadUserData) encode(pe packetEncoder) error {
+	topics := make([]string, 0, len(m.Loads))
+	for topic := range m.Loads {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	if err := pe.putArrayLength(len(topics)); err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		partitions := make([]int32, 0, len(m.Loads[topic]))
+		for partition := range m.Loads[topic] {
+			partitions = append(partitions, partition)
+		}
+		sort.Sort(int32Slice(partitions))
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			pe.putInt32(partition)
+			pe.putInt64(m.Loads[topic][partition])
+		}
+	}
+	return nil
+}
+
+func (m *partitionLoadUserData) decode(pd packetDecoder) (err error) {
+	var topicCount int
+	if topicCount, err = pd.getArrayLength(); err != nil {
+		return err
+	}
+	m.Loads = make(map[string]map[int32]int64, topicCount)
+	for i := 0; i < topicCount; i++ {
+		var topic string
+		if topic, err = pd.getString(); err != nil {
+			return err
+		}
+		var partitionCount int
+		if partitionCount, err = pd.getArrayLength(); err != nil {
+			return err
+		}
+		m.Loads[topic] = make(map[int32]int64, partitionCount)
+		for j := 0; j < partitionCount; j++ {
+			var partition int32
+			if partition, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if m.Loads[topic][partition], err = pd.getInt64(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
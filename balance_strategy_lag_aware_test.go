@@ -0,0 +1,92 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBalanceStrategyLagAware(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  map[string][]string
+		loads    map[string]map[string]map[int32]int64
+		topics   map[string][]int32
+		expected BalanceStrategyPlan
+	}{
+		{
+			name:    "hot partition",
+			members: map[string][]string{"M1": {"T"}, "M2": {"T"}},
+			loads: map[string]map[string]map[int32]int64{
+				"M1": {"T": {0: 1000, 1: 10}},
+				"M2": {"T": {2: 10, 3: 10}},
+			},
+			topics: map[string][]int32{"T": {0, 1, 2, 3}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T": {0}},
+				"M2": map[string][]int32{"T": {1, 2, 3}},
+			},
+		},
+		{
+			name:    "no load reported",
+			members: map[string][]string{"M1": {"T"}, "M2": {"T"}},
+			topics:  map[string][]int32{"T": {0, 1, 2, 3}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T": {0, 2}},
+				"M2": map[string][]int32{"T": {1, 3}},
+			},
+		},
+		{
+			name:    "unreported partitions weigh the average load",
+			members: map[string][]string{"M1": {"T"}, "M2": {"T"}},
+			loads: map[string]map[string]map[int32]int64{
+				"M1": {"T": {0: 200}},
+			},
+			topics: map[string][]int32{"T": {0, 1, 2}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T": {0, 2}},
+				"M2": map[string][]int32{"T": {1}},
+			},
+		},
+		{
+			name:    "subscriptions",
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T2"}},
+			loads: map[string]map[string]map[int32]int64{
+				"M1": {"T1": {0: 100}},
+			},
+			topics: map[string][]int32{"T1": {0}, "T2": {0, 1}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0}, "T2": {1}},
+				"M2": map[string][]int32{"T2": {0}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			members := make(map[string]ConsumerGroupMemberMetadata)
+			for memberID, topics := range test.members {
+				loads := test.loads[memberID]
+				strategy := NewBalanceStrategyLagAware(func(topics []string) map[string]map[int32]int64 {
+					return loads
+				}).(UserDataBalanceStrategy)
+				userData, err := strategy.SubscriptionUserData(topics)
+				if err != nil {
+					t.Fatal(err)
+				}
+				members[memberID] = ConsumerGroupMemberMetadata{Topics: topics, UserData: userData}
+			}
+
+			strategy := NewBalanceStrategyLagAware(nil).(UserDataBalanceStrategy)
+			if strategy.Name() != LagAwareBalanceStrategyName {
+				t.Errorf("Unexpected strategy name %s", strategy.Name())
+			}
+			actual, _, err := strategy.PlanWithUserData(members, test.topics)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -2094,6 +2094,61 @@ func Test_stickyBalanceStrategy_Plan_AssignmentData(t *testing.T) {
 	}
 }
 
+func Test_cooperativeStickyBalanceStrategy_Plan(t *testing.T) {
+	s := NewBalanceStrategyCooperativeSticky()
+	if _, ok := s.(CooperativeBalanceStrategy); !ok {
+		t.Fatal("expected the cooperative sticky strategy to be a CooperativeBalanceStrategy")
+	}
+	topics := map[string][]int32{"topic1": {0, 1, 2, 3}}
+
+	// consumer2 joins while consumer1 owns all the partitions
+	members := map[string]ConsumerGroupMemberMetadata{
+		"consumer1": {
+			Version:         1,
+			Topics:          []string{"topic1"},
+			UserData:        encodeSubscriberPlanWithGeneration(t, map[string][]int32{"topic1": {0, 1, 2, 3}}, 1),
+			OwnedPartitions: []*OwnedPartition{{Topic: "topic1", Partitions: []int32{0, 1, 2, 3}}},
+		},
+		"consumer2": {
+			Version: 1,
+			Topics:  []string{"topic1"},
+		},
+	}
+	plan, err := s.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan["consumer1"]["topic1"]) != 2 {
+		t.Errorf("expected consumer1 to keep 2 partitions, got %v", plan["consumer1"])
+	}
+	if len(plan["consumer2"]["topic1"]) != 0 {
+		t.Errorf("expected consumer2 to wait for the revoked partitions, got %v", plan["consumer2"])
+	}
+
+	// consumer1 revoked the partitions missing from its assignment and rejoined
+	kept := plan["consumer1"]["topic1"]
+	members["consumer1"] = ConsumerGroupMemberMetadata{
+		Version:         1,
+		Topics:          []string{"topic1"},
+		UserData:        encodeSubscriberPlanWithGeneration(t, map[string][]int32{"topic1": kept}, 2),
+		OwnedPartitions: []*OwnedPartition{{Topic: "topic1", Partitions: kept}},
+	}
+	plan, err = s.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := append([]int32(nil), plan["consumer1"]["topic1"]...)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+	if !reflect.DeepEqual(got, kept) {
+		t.Errorf("expected consumer1 to keep %v, got %v", kept, plan["consumer1"])
+	}
+	if len(plan["consumer2"]["topic1"]) != 2 {
+		t.Errorf("expected consumer2 to get the revoked partitions, got %v", plan["consumer2"])
+	}
+	verifyValidityAndBalance(t, members, plan)
+}
+
 func Test_stickyBalanceStrategy_Plan_data_race(t *testing.T) {
 	for i := 0; i < 1000; i++ {
 		go func(bs BalanceStrategy) {
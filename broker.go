@@ -3,12 +3,14 @@ package sarama
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,6 +35,30 @@ type Broker struct {
 	opened        int32
 	responses     chan *responsePromise
 	done          chan bool
+	// throttledUntil is when the throttling of the last throttled response ends, in Unix
+	// nanoseconds, accessed atomically as responses are handled without the lock.
+	throttledUntil int64
+	// inFlightRequests is the number of requests sent and awaiting a response, and lastUsed when
+	// the broker was last handed out, sent a request or received a response, in Unix
+	// nanoseconds, both accessed atomically to reap the idle connections, see Net.MaxIdle.
+	inFlightRequests int64
+	lastUsed         int64
+	// circuit is the circuit breaker of the broker, see Net.CircuitBreaker, never enabled on the
+	// pooled connections as the broker's own one covers them.
+	circuit brokerCircuit
+	// apiVersions are the versions of the API keys the broker supports, by key, as advertised
+	// in the ApiVersions response of the connection, see Config.MaxAPIVersions.
+	apiVersions atomic.Value // map[int16]ApiVersionsResponseKey
+	// inFlight are the semaphores of the API keys of Net.MaxOpenRequestsPerAPIKey, by key, replaced
+	// as a whole by Open while requests may read them without the lock.
+	inFlight atomic.Value // map[int16]chan struct{}
+	// pool are the other connections to the broker when Net.ConnectionsPerBroker is higher than
+	// 1, requests being sent on them and this one in turn. Pooled connections share the metrics
+	// of the broker they belong to.
+	poolLock sync.RWMutex
+	pool     []*Broker
+	poolNext uint32
+	pooled   bool
 
 	metricRegistry             metrics.Registry
 	incomingByteRate           metrics.Meter
@@ -44,6 +70,7 @@ type Broker struct {
 	responseRate               metrics.Meter
 	responseSize               metrics.Histogram
 	requestsInFlight           metrics.Counter
+	throttleTime               metrics.Histogram
 	protocolRequestsRate       map[int16]metrics.Meter
 	brokerIncomingByteRate     metrics.Meter
 	brokerRequestRate          metrics.Meter
@@ -190,9 +217,16 @@ type responsePromise struct {
 	requestTime   time.Time
 	correlationID int32
 	headerVersion int16
+	readTimeout   time.Duration
 	handler       func([]byte, error)
 	packets       chan []byte
 	errors        chan error
+	// responseSize and latency are set by the response receiver for Net.Interceptors
+	responseSize int
+	latency      time.Duration
+	// apiKey and apiVersion are the ones of the request, for Net.Capture
+	apiKey     int16
+	apiVersion int16
 }
 
 func (p *responsePromise) handle(packets []byte, err error) {
@@ -221,6 +255,7 @@ func NewBroker(addr string) *Broker {
 // follow it by a call to Connected(). The only errors Open will return directly are ConfigurationError or
 // AlreadyConnected. If conf is nil, the result of NewConfig() is used.
 func (b *Broker) Open(conf *Config) error {
+	b.touch()
 	if !atomic.CompareAndSwapInt32(&b.opened, 0, 1) {
 		return ErrAlreadyConnected
 	}
@@ -242,21 +277,38 @@ func (b *Broker) Open(conf *Config) error {
 		b.metricRegistry = newCleanupRegistry(conf.MetricRegistry)
 	}
 
+	if !b.pooled {
+		b.circuit.configure(conf)
+	}
+	b.apiVersions.Store(map[int16]ApiVersionsResponseKey(nil))
+
+	inFlight := make(map[int16]chan struct{}, len(conf.Net.MaxOpenRequestsPerAPIKey))
+	for key, max := range conf.Net.MaxOpenRequestsPerAPIKey {
+		inFlight[key] = make(chan struct{}, max)
+	}
+	b.inFlight.Store(inFlight)
+
+	if !b.pooled && conf.Net.ConnectionsPerBroker > 1 {
+		b.openPool(conf)
+	}
+
 	go withRecover(func() {
 		defer func() {
 			b.lock.Unlock()
 
-			// Send an ApiVersionsRequest to identify the client (KIP-511).
-			// Ideally Sarama would use the response to control protocol versions,
-			// but for now just fire-and-forget just to send
+			// Send an ApiVersionsRequest to identify the client (KIP-511) and learn
+			// the versions the broker supports, which the requests are checked
+			// against. The requests are still built from Config.Version.
 			if usingApiVersionsRequests {
-				_, err = b.ApiVersions(&ApiVersionsRequest{
+				res, err := b.ApiVersions(&ApiVersionsRequest{
 					Version:               3,
 					ClientSoftwareName:    defaultClientSoftwareName,
 					ClientSoftwareVersion: version(),
 				})
 				if err != nil {
 					Logger.Printf("Error while sending ApiVersionsRequest to broker %s: %s\n", b.addr, err)
+				} else if res.ErrorCode == int16(ErrNoError) {
+					b.setAPIVersions(res.ApiKeys)
 				}
 			}
 		}()
@@ -269,7 +321,7 @@ func (b *Broker) Open(conf *Config) error {
 			return
 		}
 		if conf.Net.TLS.Enable {
-			b.conn = tls.Client(b.conn, validServerNameTLS(b.addr, conf.Net.TLS.Config))
+			b.conn = tls.Client(b.conn, brokerTLSConfig(b.addr, conf))
 		}
 
 		b.conn = newBufConn(b.conn)
@@ -285,6 +337,7 @@ func (b *Broker) Open(conf *Config) error {
 		b.responseRate = metrics.GetOrRegisterMeter("response-rate", b.metricRegistry)
 		b.responseSize = getOrRegisterHistogram("response-size", b.metricRegistry)
 		b.requestsInFlight = metrics.GetOrRegisterCounter("requests-in-flight", b.metricRegistry)
+		b.throttleTime = getOrRegisterHistogram("throttle-time-in-ms", b.metricRegistry)
 		b.protocolRequestsRate = map[int16]metrics.Meter{}
 		// Do not gather metrics for seeded broker (only used during bootstrap) because they share
 		// the same id (-1) and are already exposed through the global metrics above
@@ -342,6 +395,53 @@ func (b *Broker) Open(conf *Config) error {
 	return nil
 }
 
+// openPool opens the other connections of the pool of the broker, without waiting for them,
+// closing the ones left by a previous failed Open.
+func (b *Broker) openPool(conf *Config) {
+	b.closePool()
+
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	b.pool = make([]*Broker, conf.Net.ConnectionsPerBroker-1)
+	for i := range b.pool {
+		b.pool[i] = &Broker{id: b.id, addr: b.addr, rack: b.rack, metricRegistry: b.metricRegistry, pooled: true}
+		_ = b.pool[i].Open(conf)
+	}
+}
+
+// closePool closes the other connections of the pool of the broker.
+func (b *Broker) closePool() {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	for _, conn := range b.pool {
+		if err := conn.Close(); err != nil && !errors.Is(err, ErrNotConnected) {
+			Logger.Printf("Error while closing pooled connection to broker %s: %s\n", b.addr, err)
+		}
+	}
+	b.pool = nil
+}
+
+// pick returns the connection of the pool of the broker to send the next request on, in turn.
+// A pooled connection which failed to connect is skipped for the broker's own one.
+func (b *Broker) pick() *Broker {
+	b.poolLock.RLock()
+	defer b.poolLock.RUnlock()
+
+	if len(b.pool) == 0 {
+		return b
+	}
+	i := atomic.AddUint32(&b.poolNext, 1) % uint32(len(b.pool)+1)
+	if i == 0 {
+		return b
+	}
+	if conn := b.pool[i-1]; atomic.LoadInt32(&conn.opened) == 1 {
+		return conn
+	}
+	return b
+}
+
 func (b *Broker) ResponseSize() int {
 	b.lock.Lock()
 	defer b.lock.Unlock()
@@ -381,6 +481,51 @@ func (b *Broker) Close() error {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
+	return b.close()
+}
+
+// closeIfIdle closes the connections to the broker if none of them had a request in flight or
+// was used for the timeout, returning whether it did.
+func (b *Broker) closeIfIdle(timeout time.Duration) bool {
+	if !b.idleFor(timeout) {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// check again now that no request can be sent
+	if b.conn == nil || !b.idleFor(timeout) {
+		return false
+	}
+	_ = b.close()
+	return true
+}
+
+// idleFor returns whether neither the broker nor its pooled connections had a request in flight
+// or were used for the timeout.
+func (b *Broker) idleFor(timeout time.Duration) bool {
+	b.poolLock.RLock()
+	defer b.poolLock.RUnlock()
+
+	since := time.Now().Add(-timeout).UnixNano()
+	for _, conn := range append([]*Broker{b}, b.pool...) {
+		if atomic.LoadInt64(&conn.inFlightRequests) > 0 || atomic.LoadInt64(&conn.lastUsed) > since {
+			return false
+		}
+	}
+	return true
+}
+
+// touch records that the broker is being used.
+func (b *Broker) touch() {
+	atomic.StoreInt64(&b.lastUsed, time.Now().UnixNano())
+}
+
+// close closes the connections to the broker. b.lock must be held by caller.
+func (b *Broker) close() error {
+	b.closePool()
+
 	if b.conn == nil {
 		return ErrNotConnected
 	}
@@ -395,7 +540,9 @@ func (b *Broker) Close() error {
 	b.done = nil
 	b.responses = nil
 
-	b.metricRegistry.UnregisterAll()
+	if !b.pooled {
+		b.metricRegistry.UnregisterAll()
+	}
 
 	if err == nil {
 		DebugLogger.Printf("Closed connection to broker %s\n", b.addr)
@@ -431,7 +578,7 @@ func (b *Broker) Rack() string {
 
 // GetMetadata send a metadata request and returns a metadata response or error
 func (b *Broker) GetMetadata(request *MetadataRequest) (*MetadataResponse, error) {
-	response := new(MetadataResponse)
+	response := &MetadataResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -494,6 +641,10 @@ type ProduceCallback func(*ProduceResponse, error)
 //
 // Make sure not to Close the broker in the callback as it will lead to a deadlock.
 func (b *Broker) AsyncProduce(request *ProduceRequest, cb ProduceCallback) error {
+	if err := b.allowCircuit(request); err != nil {
+		return err
+	}
+
 	metricRegistry := b.metricRegistry
 	needAcks := request.RequiredAcks != NoResponse
 	// Use a nil promise when no acks is required
@@ -508,26 +659,53 @@ func (b *Broker) AsyncProduce(request *ProduceRequest, cb ProduceCallback) error
 			handler: func(packets []byte, err error) {
 				if err != nil {
 					// Failed request
+					b.interceptResponse(request, promise, nil, err)
 					cb(nil, err)
 					return
 				}
 
 				if err := versionedDecode(packets, res, request.version(), metricRegistry); err != nil {
 					// Malformed response
+					b.interceptResponse(request, promise, nil, err)
 					cb(nil, err)
 					return
 				}
+				b.interceptResponse(request, promise, res, nil)
 
 				// Wellformed response
-				b.updateThrottleMetric(res.ThrottleTime)
+				b.handleThrottle(request, res)
 				cb(res, nil)
 			},
 		}
 	}
 
-	b.lock.Lock()
-	defer b.lock.Unlock()
-	return b.sendWithPromise(request, promise)
+	conn := b.pick()
+	release := conn.acquireInFlight(request.key())
+	if promise == nil {
+		defer release()
+	} else {
+		handler := promise.handler
+		promise.handler = func(packets []byte, err error) {
+			release()
+			b.recordCircuit(err)
+			handler(packets, err)
+		}
+	}
+
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	if conn.conf != nil {
+		conn.pinAPIVersion(request, nil)
+	}
+	conn.waitForThrottle(request)
+	err := conn.sendWithPromise(request, promise)
+	if err != nil && promise != nil {
+		release()
+	}
+	if err != nil || promise == nil {
+		b.recordCircuit(err)
+	}
+	return err
 }
 
 // Produce returns a produce response or error
@@ -542,7 +720,6 @@ func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
 	} else {
 		response = new(ProduceResponse)
 		err = b.sendAndReceive(request, response)
-		b.updateThrottleMetric(response.ThrottleTime)
 	}
 
 	if err != nil {
@@ -563,7 +740,11 @@ func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
 		}
 	}()
 
-	response := new(FetchResponse)
+	response := &FetchResponse{
+		Version:           request.Version,
+		topicIDs:          request.topicIDs,
+		lazyDecompression: request.lazyDecompression,
+	}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -648,7 +829,7 @@ func (b *Broker) Heartbeat(request *HeartbeatRequest) (*HeartbeatResponse, error
 
 // ListGroups return a list group response or error
 func (b *Broker) ListGroups(request *ListGroupsRequest) (*ListGroupsResponse, error) {
-	response := new(ListGroupsResponse)
+	response := &ListGroupsResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -963,6 +1144,177 @@ func (b *Broker) AlterUserScramCredentials(req *AlterUserScramCredentialsRequest
 	return res, nil
 }
 
+// CreateDelegationToken sends a request to create a delegation token
+func (b *Broker) CreateDelegationToken(request *CreateDelegationTokenRequest) (*CreateDelegationTokenResponse, error) {
+	response := new(CreateDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// RenewDelegationToken sends a request to renew a delegation token
+func (b *Broker) RenewDelegationToken(request *RenewDelegationTokenRequest) (*RenewDelegationTokenResponse, error) {
+	response := new(RenewDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ExpireDelegationToken sends a request to expire a delegation token
+func (b *Broker) ExpireDelegationToken(request *ExpireDelegationTokenRequest) (*ExpireDelegationTokenResponse, error) {
+	response := new(ExpireDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeDelegationToken sends a request to describe delegation tokens
+func (b *Broker) DescribeDelegationToken(request *DescribeDelegationTokenRequest) (*DescribeDelegationTokenResponse, error) {
+	response := new(DescribeDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ElectLeaders sends a request to elect the leaders of partitions
+func (b *Broker) ElectLeaders(request *ElectLeadersRequest) (*ElectLeadersResponse, error) {
+	response := new(ElectLeadersResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeProducers sends a request to describe the producers of partitions
+func (b *Broker) DescribeProducers(request *DescribeProducersRequest) (*DescribeProducersResponse, error) {
+	response := new(DescribeProducersResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeTransactions sends a request to describe transactions
+func (b *Broker) DescribeTransactions(request *DescribeTransactionsRequest) (*DescribeTransactionsResponse, error) {
+	response := new(DescribeTransactionsResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ListTransactions sends a request to list the transactions of the broker
+func (b *Broker) ListTransactions(request *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	response := new(ListTransactionsResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// WriteTxnMarkers sends a request to write transaction markers
+func (b *Broker) WriteTxnMarkers(request *WriteTxnMarkersRequest) (*WriteTxnMarkersResponse, error) {
+	response := new(WriteTxnMarkersResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UpdateFeatures sends a request to update the finalized features of the cluster and returns
+// the response or error
+func (b *Broker) UpdateFeatures(request *UpdateFeaturesRequest) (*UpdateFeaturesResponse, error) {
+	response := new(UpdateFeaturesResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeCluster sends a request to describe the cluster and returns the response or error
+func (b *Broker) DescribeCluster(request *DescribeClusterRequest) (*DescribeClusterResponse, error) {
+	response := new(DescribeClusterResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeQuorum sends a request to describe the Raft quorum of KRaft partitions and returns the
+// response or error
+func (b *Broker) DescribeQuorum(request *DescribeQuorumRequest) (*DescribeQuorumResponse, error) {
+	response := new(DescribeQuorumResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UnregisterBroker sends a request to remove the registration of a broker from the metadata of
+// a KRaft cluster and returns the response or error
+func (b *Broker) UnregisterBroker(request *UnregisterBrokerRequest) (*UnregisterBrokerResponse, error) {
+	response := new(UnregisterBrokerResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// AlterReplicaLogDirs sends a request to move replicas of the broker to other log dirs
+func (b *Broker) AlterReplicaLogDirs(request *AlterReplicaLogDirsRequest) (*AlterReplicaLogDirsResponse, error) {
+	response := new(AlterReplicaLogDirsResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // DescribeClientQuotas sends a request to get the broker's quotas
 func (b *Broker) DescribeClientQuotas(request *DescribeClientQuotasRequest) (*DescribeClientQuotasResponse, error) {
 	response := new(DescribeClientQuotasResponse)
@@ -990,13 +1342,27 @@ func (b *Broker) AlterClientQuotas(request *AlterClientQuotasRequest) (*AlterCli
 // readFull ensures the conn ReadDeadline has been setup before making a
 // call to io.ReadFull
 func (b *Broker) readFull(buf []byte) (n int, err error) {
-	if err := b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout)); err != nil {
+	return b.readFullWithTimeout(buf, b.conf.Net.ReadTimeout)
+}
+
+// readFullWithTimeout is readFull with the given read timeout.
+func (b *Broker) readFullWithTimeout(buf []byte, timeout time.Duration) (n int, err error) {
+	if err := b.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return 0, err
 	}
 
 	return io.ReadFull(b.conn, buf)
 }
 
+// readTimeout returns how long to wait for the response to a request of the API key, see
+// Net.ReadTimeoutPerAPIKey.
+func (b *Broker) readTimeout(key int16) time.Duration {
+	if timeout, ok := b.conf.Net.ReadTimeoutPerAPIKey[key]; ok {
+		return timeout
+	}
+	return b.conf.Net.ReadTimeout
+}
+
 // write  ensures the conn WriteDeadline has been setup before making a
 // call to conn.Write
 func (b *Broker) write(buf []byte) (n int, err error) {
@@ -1051,17 +1417,113 @@ func (b *Broker) sendWithPromise(rb protocolBody, promise *responsePromise) erro
 	return b.sendInternal(rb, promise)
 }
 
-// b.lock must be held by caller
-func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
+// setAPIVersions records the versions of the API keys advertised by the broker.
+func (b *Broker) setAPIVersions(keys []ApiVersionsResponseKey) {
+	versions := make(map[int16]ApiVersionsResponseKey, len(keys))
+	for _, key := range keys {
+		versions[key.ApiKey] = key
+	}
+	b.apiVersions.Store(versions)
+}
+
+// NegotiatedAPIVersions returns the range of versions of each API key the requests to the
+// broker can be sent with: the versions the broker advertised, capped by
+// Config.MaxAPIVersions. The requests are pinned to the latter, and rejected when outside of
+// the former. It is nil until the broker answered the ApiVersions request sent once
+// connected, with Version >= V2_4_0_0 and Config.ApiVersionsRequest, so before that the
+// requests are only checked against Config.Version.
+func (b *Broker) NegotiatedAPIVersions() map[int16]ApiVersionsResponseKey {
+	advertised, _ := b.apiVersions.Load().(map[int16]ApiVersionsResponseKey)
+	if advertised == nil {
+		return nil
+	}
+	b.lock.Lock()
+	conf := b.conf
+	b.lock.Unlock()
+
+	versions := make(map[int16]ApiVersionsResponseKey, len(advertised))
+	for key, v := range advertised {
+		if max, ok := conf.MaxAPIVersions[key]; ok && max < v.MaxVersion {
+			v.MaxVersion = max
+		}
+		versions[key] = v
+	}
+	return versions
+}
+
+// pinAPIVersion lowers the version of the request to the one of Config.MaxAPIVersions for its
+// API key, if higher, along with the version of its response if it was set to the one of the
+// request. b.lock must be held by caller.
+func (b *Broker) pinAPIVersion(req, res protocolBody) {
+	max, ok := b.conf.MaxAPIVersions[req.key()]
+	version := req.version()
+	if !ok || version <= max || !setAPIVersion(req, max) {
+		return
+	}
+	if res != nil && res.version() == version {
+		setAPIVersion(res, max)
+	}
+}
+
+// setAPIVersion sets the Version field of the request or response, returning false if it has
+// none.
+func setAPIVersion(body protocolBody, version int16) bool {
+	v := reflect.ValueOf(body)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	field := v.Elem().FieldByName("Version")
+	if !field.IsValid() || !field.CanSet() || field.Kind() != reflect.Int16 {
+		return false
+	}
+	field.SetInt(int64(version))
+	return true
+}
+
+// checkAPIVersion returns ErrUnsupportedVersion if the version of the request is not supported
+// by Config.Version, or an *UnsupportedAPIVersionError if it is outside of the versions the broker
+// advertised. The keys the broker did not advertise are left to it. b.lock must be held by caller.
+func (b *Broker) checkAPIVersion(rb protocolBody) error {
 	if !b.conf.Version.IsAtLeast(rb.requiredVersion()) {
 		return ErrUnsupportedVersion
 	}
+	if _, ok := rb.(*ApiVersionsRequest); ok {
+		// the broker answers the versions it does not support with the ones it does
+		return nil
+	}
+
+	advertised, _ := b.apiVersions.Load().(map[int16]ApiVersionsResponseKey)
+	supported, ok := advertised[rb.key()]
+	if !ok || (rb.version() >= supported.MinVersion && rb.version() <= supported.MaxVersion) {
+		return nil
+	}
+	unsupported := &UnsupportedAPIVersionError{
+		BrokerID:          b.id,
+		APIKey:            rb.key(),
+		APIVersion:        rb.version(),
+		RequiredVersion:   rb.requiredVersion(),
+		ConfiguredVersion: b.conf.Version,
+		MinVersion:        supported.MinVersion,
+		MaxVersion:        supported.MaxVersion,
+	}
+	if max, ok := b.conf.MaxAPIVersions[rb.key()]; ok && rb.version() == max {
+		unsupported.Pinned = true
+	}
+	return unsupported
+}
+
+// b.lock must be held by caller
+func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
+	if err := b.checkAPIVersion(rb); err != nil {
+		return err
+	}
 
 	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
 	buf, err := encode(req, b.metricRegistry)
 	if err != nil {
 		return err
 	}
+	b.interceptRequest(req, buf)
 
 	requestTime := time.Now()
 	// Will be decremented in responseReceiver (except error or request with NoResponse)
@@ -1073,6 +1535,7 @@ func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
 		b.addRequestInFlightMetrics(-1)
 		return err
 	}
+	b.captureFrame(CapturedFrameSent, rb.key(), rb.version(), req.correlationID, buf)
 	b.correlationID++
 
 	if promise == nil {
@@ -1083,29 +1546,160 @@ func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
 
 	promise.requestTime = requestTime
 	promise.correlationID = req.correlationID
+	promise.readTimeout = b.readTimeout(rb.key())
+	promise.apiKey, promise.apiVersion = rb.key(), rb.version()
 	b.responses <- promise
 
 	return nil
 }
 
+// sendAndReceive sends the request and waits for its response. Only the sending holds the
+// lock, so that requests are pipelined on the connection, up to Net.MaxOpenRequests, while
+// their responses are waited for, the response receiver matching them by correlation ID.
 func (b *Broker) sendAndReceive(req protocolBody, res protocolBody) error {
+	if err := b.allowCircuit(req); err != nil {
+		return err
+	}
+	err := b.sendAndReceiveConn(req, res)
+	b.recordCircuit(err)
+	return err
+}
+
+// allowCircuit returns ErrBrokerCircuitOpen if the circuit of the broker is open, probing the
+// broker with an ApiVersions request first if the request is the first one after the backoff.
+func (b *Broker) allowCircuit(req protocolBody) error {
+	probe, err := b.circuit.allow()
+	if err != nil || !probe {
+		return err
+	}
+	if _, ok := req.(*ApiVersionsRequest); ok {
+		return nil
+	}
+	DebugLogger.Printf("broker/%d probing the broker with an ApiVersions request\n", b.ID())
+	err = b.sendAndReceiveConn(&ApiVersionsRequest{}, &ApiVersionsResponse{})
+	b.recordCircuit(err)
+	return err
+}
+
+// recordCircuit records the outcome of a request in the circuit of the broker.
+func (b *Broker) recordCircuit(err error) {
+	switch opened, closed := b.circuit.record(err); {
+	case opened:
+		Logger.Printf("broker/%d circuit breaker opened after consecutive failures, last one: %v\n", b.ID(), err)
+	case closed:
+		Logger.Printf("broker/%d circuit breaker closed\n", b.ID())
+	}
+}
+
+// sendAndReceiveConn sends the request on a connection of the pool of the broker and waits for
+// its response.
+func (b *Broker) sendAndReceiveConn(req protocolBody, res protocolBody) error {
+	if conn := b.pick(); conn != b {
+		return conn.sendAndReceiveConn(req, res)
+	}
+
+	release := b.acquireInFlight(req.key())
+	defer release()
+
+	promise, err := b.sendLocked(req, res)
+	if err != nil || promise == nil {
+		return err
+	}
+
+	err = handleResponsePromise(req, res, promise, b.metricRegistry)
+	b.interceptResponse(req, promise, res, err)
+	if err != nil {
+		return err
+	}
+	b.handleThrottle(req, res)
+	return nil
+}
+
+// sendLocked sends the request with the lock held, once the throttling of the last throttled
+// response is over if the request is paced, and returns the promise of its response, nil if res
+// is nil.
+func (b *Broker) sendLocked(req protocolBody, res protocolBody) (*responsePromise, error) {
 	b.lock.Lock()
 	defer b.lock.Unlock()
+
+	if b.conf != nil {
+		b.pinAPIVersion(req, res)
+	}
 	responseHeaderVersion := int16(-1)
 	if res != nil {
 		responseHeaderVersion = res.headerVersion()
 	}
 
-	promise, err := b.send(req, res != nil, responseHeaderVersion)
-	if err != nil {
-		return err
+	b.waitForThrottle(req)
+	return b.send(req, res != nil, responseHeaderVersion)
+}
+
+// waitForThrottle waits for the throttling of the last throttled response to end before sending
+// the request, if it is paced. b.lock must be held by caller.
+func (b *Broker) waitForThrottle(req protocolBody) {
+	if !b.pacesThrottled(req) {
+		return
+	}
+	if wait := time.Until(time.Unix(0, atomic.LoadInt64(&b.throttledUntil))); wait > 0 {
+		DebugLogger.Printf("broker/%d waiting %v for the throttling of its last response to end\n", b.ID(), wait)
+		time.Sleep(wait)
 	}
+}
 
-	if promise == nil {
-		return nil
+// pacesThrottled returns whether the request waits for the throttling of the last throttled
+// response to end: the administrative requests always do, and the produce and fetch requests do
+// with Net.PaceThrottledRequests.
+func (b *Broker) pacesThrottled(req protocolBody) bool {
+	switch req.(type) {
+	case *ProduceRequest, *FetchRequest:
+		return b.conf != nil && b.conf.Net.PaceThrottledRequests
+	default:
+		return true
+	}
+}
+
+// handleThrottle surfaces the throttle time of the response to the request, if any, through the
+// metrics and Net.OnThrottle, and records when the throttling ends to pace the next requests.
+func (b *Broker) handleThrottle(req protocolBody, res protocolBody) {
+	var throttleTime time.Duration
+	switch r := res.(type) {
+	case *ProduceResponse:
+		throttleTime = r.ThrottleTime
+	case *FetchResponse:
+		throttleTime = r.ThrottleTime
+	case throttledResponse:
+		throttleTime = r.throttleTime()
+	}
+	if throttleTime <= 0 {
+		return
+	}
+
+	DebugLogger.Printf("broker/%d response to request %d throttled %v\n", b.ID(), req.key(), throttleTime)
+	b.updateThrottleMetric(req.key(), throttleTime)
+	atomic.StoreInt64(&b.throttledUntil, time.Now().Add(throttleTime).UnixNano())
+	if b.conf != nil && b.conf.Net.OnThrottle != nil {
+		b.conf.Net.OnThrottle(b.ID(), req.key(), throttleTime)
+	}
+}
+
+// acquireInFlight waits for the number of requests in flight of the API key to be under its
+// Net.MaxOpenRequestsPerAPIKey, if any, and returns the function to call once the response of
+// the request is received.
+func (b *Broker) acquireInFlight(key int16) (release func()) {
+	inFlight, _ := b.inFlight.Load().(map[int16]chan struct{})
+	semaphore := inFlight[key]
+	if semaphore == nil {
+		return func() {}
 	}
+	semaphore <- struct{}{}
+	return func() { <-semaphore }
+}
 
-	return handleResponsePromise(req, res, promise, b.metricRegistry)
+// throttledResponse is implemented by the responses of the administrative APIs, whose throttle
+// time is always waited for before sending the next request to the broker, as brokers expect
+// from clients since KIP-219.
+type throttledResponse interface {
+	throttleTime() time.Duration
 }
 
 func handleResponsePromise(req protocolBody, res protocolBody, promise *responsePromise, metricRegistry metrics.Registry) error {
@@ -1123,7 +1717,12 @@ func (b *Broker) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
-	host, err := pd.getString()
+	var host string
+	if version >= 9 {
+		host, err = pd.getCompactString()
+	} else {
+		host, err = pd.getString()
+	}
 	if err != nil {
 		return err
 	}
@@ -1133,13 +1732,24 @@ func (b *Broker) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
-	if version >= 1 {
+	if version >= 9 {
+		if b.rack, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	} else if version >= 1 {
 		b.rack, err = pd.getNullableString()
 		if err != nil {
 			return err
 		}
 	}
 
+	// some brokers advertise literal IPv6 listeners with their brackets
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
 	b.addr = net.JoinHostPort(host, fmt.Sprint(port))
 	if _, _, err := net.SplitHostPort(b.addr); err != nil {
 		return err
@@ -1161,14 +1771,23 @@ func (b *Broker) encode(pe packetEncoder, version int16) (err error) {
 
 	pe.putInt32(b.id)
 
-	err = pe.putString(host)
+	if version >= 9 {
+		err = pe.putCompactString(host)
+	} else {
+		err = pe.putString(host)
+	}
 	if err != nil {
 		return err
 	}
 
 	pe.putInt32(int32(port))
 
-	if version >= 1 {
+	if version >= 9 {
+		if err := pe.putNullableCompactString(b.rack); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	} else if version >= 1 {
 		err = pe.putNullableString(b.rack)
 		if err != nil {
 			return err
@@ -1178,50 +1797,107 @@ func (b *Broker) encode(pe packetEncoder, version int16) (err error) {
 	return nil
 }
 
+// responseReceiver reads the responses of the connection and hands each one to the promise of
+// the request with its correlation ID, so that the requests in flight don't wait on each other
+// whatever the order of their responses. The promises are queued on b.responses once their
+// request is sent, and taken from it as their responses, or the ones of the requests sent
+// after them, are received.
 func (b *Broker) responseReceiver() {
 	var dead error
+	pending := make(map[int32]*responsePromise) // the promises taken from b.responses
+	var lastQueued int32                        // the correlation ID of the last one
+	queued := true                              // whether b.responses is still open
+
+	// next takes the next promise from b.responses, returning false once closed
+	next := func() bool {
+		if !queued {
+			return false
+		}
+		promise, ok := <-b.responses
+		if !ok {
+			queued = false
+			return false
+		}
+		pending[promise.correlationID] = promise
+		lastQueued = promise.correlationID
+		return true
+	}
 
-	for response := range b.responses {
+	for len(pending) > 0 || next() {
 		if dead != nil {
-			// This was previously incremented in send() and
-			// we are not calling updateIncomingCommunicationMetrics()
-			b.addRequestInFlightMetrics(-1)
-			response.handle(nil, dead)
+			for correlationID, response := range pending {
+				// This was previously incremented in send() and
+				// we are not calling updateIncomingCommunicationMetrics()
+				b.addRequestInFlightMetrics(-1)
+				response.handle(nil, dead)
+				delete(pending, correlationID)
+			}
 			continue
 		}
 
-		headerLength := getHeaderLength(response.headerVersion)
-		header := make([]byte, headerLength)
+		// the responses are waited for as long as the longest read timeout of the pending
+		// requests, and attributed to the oldest one until their correlation ID is known
+		var oldest *responsePromise
+		var readTimeout time.Duration
+		for _, response := range pending {
+			if oldest == nil || response.correlationID < oldest.correlationID {
+				oldest = response
+			}
+			if response.readTimeout > readTimeout {
+				readTimeout = response.readTimeout
+			}
+		}
+
+		// The tagged fields of the flexible header versions are read with the body, as their
+		// length is only known once read.
+		header := make([]byte, responseHeaderLength)
 
-		bytesReadHeader, err := b.readFull(header)
-		requestLatency := time.Since(response.requestTime)
+		bytesReadHeader, err := b.readFullWithTimeout(header, readTimeout)
 		if err != nil {
-			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
+			b.updateIncomingCommunicationMetrics(bytesReadHeader, time.Since(oldest.requestTime))
 			dead = err
-			response.handle(nil, err)
+			delete(pending, oldest.correlationID)
+			oldest.handle(nil, err)
 			continue
 		}
 
 		decodedHeader := responseHeader{}
-		err = versionedDecode(header, &decodedHeader, response.headerVersion, b.metricRegistry)
+		err = versionedDecode(header, &decodedHeader, 0, b.metricRegistry)
 		if err != nil {
-			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
+			b.updateIncomingCommunicationMetrics(bytesReadHeader, time.Since(oldest.requestTime))
 			dead = err
-			response.handle(nil, err)
+			delete(pending, oldest.correlationID)
+			oldest.handle(nil, err)
 			continue
 		}
-		if decodedHeader.correlationID != response.correlationID {
-			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
-			// TODO if decoded ID < cur ID, discard until we catch up
-			// TODO if decoded ID > cur ID, save it so when cur ID catches up we have a response
-			dead = PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", response.correlationID, decodedHeader.correlationID)}
-			response.handle(nil, dead)
+
+		// the promise of a request is queued right after it is sent, so the promise of a
+		// response to a later request than the queued ones is about to be
+		response, ok := pending[decodedHeader.correlationID]
+		for !ok && decodedHeader.correlationID > lastQueued && next() {
+			response, ok = pending[decodedHeader.correlationID]
+		}
+		if !ok {
+			b.updateIncomingCommunicationMetrics(bytesReadHeader, time.Since(oldest.requestTime))
+			dead = PacketDecodingError{fmt.Sprintf("correlation ID didn't match any request, got %d", decodedHeader.correlationID)}
+			delete(pending, oldest.correlationID)
+			oldest.handle(nil, dead)
 			continue
 		}
+		delete(pending, response.correlationID)
+		requestLatency := time.Since(response.requestTime)
 
-		buf := make([]byte, decodedHeader.length-int32(headerLength)+4)
-		bytesReadBody, err := b.readFull(buf)
+		buf := make([]byte, decodedHeader.length-responseHeaderLength+4)
+		bytesReadBody, err := b.readFullWithTimeout(buf, response.readTimeout)
 		b.updateIncomingCommunicationMetrics(bytesReadHeader+bytesReadBody, requestLatency)
+		response.responseSize = bytesReadHeader + bytesReadBody
+		response.latency = requestLatency
+		if err == nil && b.conf.Net.Capture.Enable {
+			b.captureFrame(CapturedFrameReceived, response.apiKey, response.apiVersion, response.correlationID, append(header, buf...))
+		}
+		if err == nil && response.headerVersion >= 1 {
+			buf, err = skipHeaderTaggedFields(buf)
+		}
 		if err != nil {
 			dead = err
 			response.handle(nil, err)
@@ -1233,13 +1909,17 @@ func (b *Broker) responseReceiver() {
 	close(b.done)
 }
 
-func getHeaderLength(headerVersion int16) int8 {
-	if headerVersion < 1 {
-		return 8
-	} else {
-		// header contains additional tagged field length (0), we don't support actual tags yet.
-		return 9
+// responseHeaderLength is the length of the length and correlation ID of response headers.
+const responseHeaderLength = 8
+
+// skipHeaderTaggedFields returns the body of a response following the tagged fields of its
+// flexible header.
+func skipHeaderTaggedFields(buf []byte) ([]byte, error) {
+	rd := &realDecoder{raw: buf}
+	if _, err := rd.getEmptyTaggedFieldArray(); err != nil {
+		return nil, err
 	}
+	return buf[rd.off:], nil
 }
 
 func (b *Broker) authenticateViaSASLv0() error {
@@ -1680,6 +2360,8 @@ func (b *Broker) updateRequestLatencyAndInFlightMetrics(requestLatency time.Dura
 }
 
 func (b *Broker) addRequestInFlightMetrics(i int64) {
+	atomic.AddInt64(&b.inFlightRequests, i)
+	b.touch()
 	b.requestsInFlight.Inc(i)
 	if b.brokerRequestsInFlight != nil {
 		b.brokerRequestsInFlight.Inc(i)
@@ -1722,15 +2404,15 @@ func (b *Broker) updateProtocolMetrics(rb protocolBody) {
 	}
 }
 
-func (b *Broker) updateThrottleMetric(throttleTime time.Duration) {
-	if throttleTime != time.Duration(0) {
-		DebugLogger.Printf(
-			"producer/broker/%d ProduceResponse throttled %v\n",
-			b.ID(), throttleTime)
-		if b.brokerThrottleTime != nil {
-			throttleTimeInMs := int64(throttleTime / time.Millisecond)
-			b.brokerThrottleTime.Update(throttleTimeInMs)
-		}
+func (b *Broker) updateThrottleMetric(key int16, throttleTime time.Duration) {
+	throttleTimeInMs := int64(throttleTime / time.Millisecond)
+	if b.throttleTime != nil {
+		b.throttleTime.Update(throttleTimeInMs)
+	}
+	// throttled responses are rare enough to look the histogram of the API key up each time
+	getOrRegisterHistogram(fmt.Sprintf("throttle-time-in-ms-%d", key), b.metricRegistry).Update(throttleTimeInMs)
+	if b.brokerThrottleTime != nil {
+		b.brokerThrottleTime.Update(throttleTimeInMs)
 	}
 }
 
@@ -1763,6 +2445,48 @@ func (b *Broker) registerCounter(name string) metrics.Counter {
 	return metrics.GetOrRegisterCounter(nameForBroker, b.metricRegistry)
 }
 
+// brokerTLSConfig returns the TLS configuration of the connections to the broker, adding the
+// session cache, verification hooks and certificate provider of Net.TLS to Net.TLS.Config.
+func brokerTLSConfig(addr string, conf *Config) *tls.Config {
+	cfg := validServerNameTLS(addr, conf.Net.TLS.Config)
+	resume := conf.Net.TLS.SessionCache != nil && cfg.ClientSessionCache == nil
+	if !resume && conf.Net.TLS.VerifyPeerCertificate == nil && conf.Net.TLS.VerifyConnection == nil &&
+		conf.Net.TLS.CertificateProvider == nil {
+		return cfg
+	}
+
+	c := cfg.Clone()
+	if resume {
+		c.ClientSessionCache = conf.Net.TLS.SessionCache
+	}
+	if provider := conf.Net.TLS.CertificateProvider; provider != nil {
+		c.GetClientCertificate = provider.GetClientCertificate
+	}
+	if verify := conf.Net.TLS.VerifyPeerCertificate; verify != nil {
+		prev := c.VerifyPeerCertificate
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if prev != nil {
+				if err := prev(rawCerts, verifiedChains); err != nil {
+					return err
+				}
+			}
+			return verify(rawCerts, verifiedChains)
+		}
+	}
+	if verify := conf.Net.TLS.VerifyConnection; verify != nil {
+		prev := c.VerifyConnection
+		c.VerifyConnection = func(state tls.ConnectionState) error {
+			if prev != nil {
+				if err := prev(state); err != nil {
+					return err
+				}
+			}
+			return verify(state)
+		}
+	}
+	return c
+}
+
 func validServerNameTLS(addr string, cfg *tls.Config) *tls.Config {
 	if cfg == nil {
 		cfg = &tls.Config{
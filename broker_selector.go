@@ -0,0 +1,61 @@
+package sarama
+
+// BrokerSelector picks the broker to send the requests any broker can serve to, such as the
+// metadata requests, the coordinator lookups and the admin requests, see
+// Config.BrokerSelector. It can for example prefer the brokers in the same rack, or the ones
+// with the lowest latency as measured by a BrokerInterceptor.
+type BrokerSelector interface {
+	// Select returns one of the brokers, which are ordered by ID and not empty, or nil to fall
+	// back to the seed brokers. It is called with the lock of the client held, so it must not
+	// call the client, and must not block.
+	Select(brokers []*Broker) *Broker
+}
+
+type leastLoadedBrokerSelector struct{}
+
+// NewLeastLoadedBrokerSelector returns a BrokerSelector picking the broker with the fewest
+// responses pending, the first one in case of a tie.
+func NewLeastLoadedBrokerSelector() BrokerSelector {
+	return leastLoadedBrokerSelector{}
+}
+
+func (leastLoadedBrokerSelector) Select(brokers []*Broker) *Broker {
+	var leastLoaded *Broker
+	pending := 0
+	for _, broker := range brokers {
+		if n := broker.ResponseSize(); leastLoaded == nil || n < pending {
+			leastLoaded, pending = broker, n
+		}
+	}
+	return leastLoaded
+}
+
+type rackAwareBrokerSelector struct {
+	rack     string
+	fallback BrokerSelector
+}
+
+// NewRackAwareBrokerSelector returns a BrokerSelector preferring the brokers in the rack,
+// typically Config.RackID, picking one of them with the fallback selector, or one of all the
+// brokers if none is in the rack. A nil fallback stands for NewLeastLoadedBrokerSelector.
+func NewRackAwareBrokerSelector(rack string, fallback BrokerSelector) BrokerSelector {
+	if fallback == nil {
+		fallback = NewLeastLoadedBrokerSelector()
+	}
+	return &rackAwareBrokerSelector{rack: rack, fallback: fallback}
+}
+
+func (s *rackAwareBrokerSelector) Select(brokers []*Broker) *Broker {
+	var sameRack []*Broker
+	for _, broker := range brokers {
+		if broker.Rack() == s.rack {
+			sameRack = append(sameRack, broker)
+		}
+	}
+	if len(sameRack) > 0 {
+		if broker := s.fallback.Select(sameRack); broker != nil {
+			return broker
+		}
+	}
+	return s.fallback.Select(brokers)
+}
@@ -0,0 +1,35 @@
+package sarama
+
+import "testing"
+
+func TestLeastLoadedBrokerSelector(t *testing.T) {
+	busy := &Broker{id: 1, responses: make(chan *responsePromise, 2)}
+	busy.responses <- &responsePromise{}
+	busy.responses <- &responsePromise{}
+	idle := &Broker{id: 2, responses: make(chan *responsePromise, 2)}
+	alsoIdle := &Broker{id: 3, responses: make(chan *responsePromise, 2)}
+
+	selector := NewLeastLoadedBrokerSelector()
+	if broker := selector.Select([]*Broker{busy, idle, alsoIdle}); broker != idle {
+		t.Errorf("Expected the first least loaded broker to be selected, got %v", broker)
+	}
+}
+
+func TestRackAwareBrokerSelector(t *testing.T) {
+	rackA, rackB := "a", "b"
+	a1 := &Broker{id: 1, rack: &rackA, responses: make(chan *responsePromise, 1)}
+	a1.responses <- &responsePromise{}
+	b2 := &Broker{id: 2, rack: &rackB}
+	a3 := &Broker{id: 3, rack: &rackA}
+	noRack := &Broker{id: 4}
+
+	selector := NewRackAwareBrokerSelector("a", nil)
+	if broker := selector.Select([]*Broker{a1, b2, a3, noRack}); broker != a3 {
+		t.Errorf("Expected the least loaded broker of the rack to be selected, got %v", broker)
+	}
+
+	selector = NewRackAwareBrokerSelector("c", nil)
+	if broker := selector.Select([]*Broker{a1, b2, a3}); broker != b2 {
+		t.Errorf("Expected the least loaded broker to be selected when none is in the rack, got %v", broker)
+	}
+}
@@ -3,10 +3,13 @@ package sarama
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -215,6 +218,565 @@ func newTokenProvider(token *AccessToken, err error) *TokenProvider {
 	}
 }
 
+func TestBrokerWaitsForThrottledResponses(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"DeleteGroupsRequest": NewMockWrapper(&DeleteGroupsResponse{
+			ThrottleTime:    200 * time.Millisecond,
+			GroupErrorCodes: map[string]KError{"my-group": ErrNoError},
+		}),
+	})
+
+	broker := NewBroker(mb.Addr())
+	conf := NewTestConfig()
+	conf.ApiVersionsRequest = false
+	conf.Version = V1_1_0_0
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	request := &DeleteGroupsRequest{Groups: []string{"my-group"}}
+	if _, err := broker.DeleteGroups(request); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := broker.DeleteGroups(request); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the second request to wait for the throttling of the first one, took %v", elapsed)
+	}
+}
+
+func TestBrokerSurfacesThrottledProduceResponses(t *testing.T) {
+	for _, pace := range []bool{false, true} {
+		pace := pace
+		t.Run(fmt.Sprintf("pace=%t", pace), func(t *testing.T) {
+			mb := NewMockBroker(t, 1)
+			defer mb.Close()
+			mb.SetHandlerByMap(map[string]MockResponse{
+				"ProduceRequest": NewMockWrapper(&ProduceResponse{Version: 3, ThrottleTime: 200 * time.Millisecond}),
+			})
+
+			type throttle struct {
+				brokerID     int32
+				apiKey       int16
+				throttleTime time.Duration
+			}
+			var throttles []throttle
+			broker := NewBroker(mb.Addr())
+			broker.id = mb.BrokerID()
+			conf := NewTestConfig()
+			conf.ApiVersionsRequest = false
+			conf.Version = V1_1_0_0
+			conf.Net.PaceThrottledRequests = pace
+			conf.Net.OnThrottle = func(brokerID int32, apiKey int16, throttleTime time.Duration) {
+				throttles = append(throttles, throttle{brokerID, apiKey, throttleTime})
+			}
+			if err := broker.Open(conf); err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, broker)
+
+			request := &ProduceRequest{Version: 3, RequiredAcks: WaitForLocal}
+			if _, err := broker.Produce(request); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			if _, err := broker.Produce(request); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); pace && elapsed < 150*time.Millisecond {
+				t.Errorf("Expected the second request to wait for the throttling of the first one, took %v", elapsed)
+			} else if !pace && elapsed >= 150*time.Millisecond {
+				t.Errorf("Expected the second request not to wait for the throttling of the first one, took %v", elapsed)
+			}
+
+			expected := throttle{mb.BrokerID(), 0, 200 * time.Millisecond}
+			if len(throttles) != 2 || throttles[0] != expected || throttles[1] != expected {
+				t.Errorf("Expected OnThrottle to be called twice with %+v, got %+v", expected, throttles)
+			}
+			for _, name := range []string{"throttle-time-in-ms", "throttle-time-in-ms-0", "throttle-time-in-ms-for-broker-1"} {
+				histogram, ok := conf.MetricRegistry.Get(name).(metrics.Histogram)
+				if !ok || histogram.Count() != 2 || histogram.Max() != 200 {
+					t.Errorf("Expected the %s histogram to record 2 throttle times of 200ms, got %v", name, histogram)
+				}
+			}
+		})
+	}
+}
+
+func TestBrokerCircuitBreaker(t *testing.T) {
+	// nothing listens on the address of the broker until it is reinstated
+	mb := NewMockBroker(t, 1)
+	addr := mb.Addr()
+	mb.Close()
+
+	conf := NewTestConfig()
+	conf.Version = V1_0_0_0
+	conf.ApiVersionsRequest = false
+	conf.Net.CircuitBreaker.Enable = true
+	conf.Net.CircuitBreaker.Failures = 2
+	conf.Net.CircuitBreaker.Backoff = 200 * time.Millisecond
+	broker := NewBroker(addr)
+	defer func() { _ = broker.Close() }()
+	request := func() error {
+		_ = broker.Open(conf)
+		_, err := broker.GetMetadata(&MetadataRequest{})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := request(); err == nil || errors.Is(err, ErrBrokerCircuitOpen) {
+			t.Fatalf("Expected request %d to fail to connect, got %v", i, err)
+		}
+	}
+	if err := request(); !errors.Is(err, ErrBrokerCircuitOpen) {
+		t.Fatalf("Expected the circuit to be open after 2 failures, got %v", err)
+	}
+
+	// the probe fails, opening the circuit again
+	time.Sleep(250 * time.Millisecond)
+	if err := request(); err == nil || errors.Is(err, ErrBrokerCircuitOpen) {
+		t.Fatalf("Expected the probe to fail to connect, got %v", err)
+	}
+	if err := request(); !errors.Is(err, ErrBrokerCircuitOpen) {
+		t.Fatalf("Expected the circuit to be open again after the failed probe, got %v", err)
+	}
+
+	mb = NewMockBrokerAddr(t, 1, addr)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest":    NewMockMetadataResponse(t),
+	})
+	time.Sleep(250 * time.Millisecond)
+	if err := request(); err != nil {
+		t.Fatalf("Expected the broker to be reinstated after a successful probe, got %v", err)
+	}
+	history := mb.History()
+	if len(history) != 2 {
+		t.Fatalf("Expected a probe and the request, got %d requests", len(history))
+	}
+	if _, ok := history[0].Request.(*ApiVersionsRequest); !ok {
+		t.Errorf("Expected the broker to be probed with an ApiVersions request, got %T", history[0].Request)
+	}
+	if broker.circuit.isOpen() {
+		t.Error("Expected the circuit to be closed")
+	}
+}
+
+// recordingInterceptor records the requests and responses it intercepts.
+type recordingInterceptor struct {
+	lock      sync.Mutex
+	requests  []*InterceptedRequest
+	responses []*InterceptedResponse
+}
+
+func (r *recordingInterceptor) OnRequest(_ *Broker, req *InterceptedRequest) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requests = append(r.requests, req)
+}
+
+func (r *recordingInterceptor) OnResponse(_ *Broker, res *InterceptedResponse) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.responses = append(r.responses, res)
+}
+
+// panickingInterceptor panics when intercepting anything.
+type panickingInterceptor struct{}
+
+func (panickingInterceptor) OnRequest(*Broker, *InterceptedRequest)   { panic("request") }
+func (panickingInterceptor) OnResponse(*Broker, *InterceptedResponse) { panic("response") }
+
+func TestBrokerNegotiatedAPIVersions(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiKeys([]ApiVersionsResponseKey{
+			{ApiKey: 0, MinVersion: 3, MaxVersion: 9},
+			{ApiKey: 1, MinVersion: 4, MaxVersion: 11},
+			{ApiKey: 3, MinVersion: 0, MaxVersion: 12},
+		}),
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	conf := NewTestConfig()
+	conf.Version = V2_4_0_0
+	conf.MaxAPIVersions = map[int16]int16{1: 3, 3: 4}
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	var versions map[int16]ApiVersionsResponseKey
+	for deadline := time.Now().Add(time.Second); versions == nil && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		versions = broker.NegotiatedAPIVersions()
+	}
+	if len(versions) != 3 || versions[0].MinVersion != 3 || versions[0].MaxVersion != 9 ||
+		versions[3].MinVersion != 0 || versions[3].MaxVersion != 4 {
+		t.Fatalf("Expected Produce v3 to v9 and Metadata v0 to v4 to be negotiated, got %+v", versions)
+	}
+
+	if _, err := broker.GetMetadata(&MetadataRequest{Version: 1}); err != nil {
+		t.Errorf("Expected a version within the negotiated ones to be sent, got %v", err)
+	}
+
+	// the requests above Config.MaxAPIVersions are pinned to it
+	request := &MetadataRequest{Version: 5}
+	if res, err := broker.GetMetadata(request); err != nil {
+		t.Errorf("Expected the request to be pinned to v4 and sent, got %v", err)
+	} else if request.Version != 4 || res.Version != 4 {
+		t.Errorf("Expected the request and its response to be pinned to v4, got v%d and v%d", request.Version, res.Version)
+	}
+
+	var unsupported *UnsupportedAPIVersionError
+	_, err := broker.Fetch(&FetchRequest{Version: 11})
+	if !errors.As(err, &unsupported) || !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("Expected an UnsupportedAPIVersionError, got %v", err)
+	}
+	if !unsupported.Pinned || unsupported.APIVersion != 3 || unsupported.MinVersion != 4 {
+		t.Errorf("Expected the version to be pinned to v3, below the v4 the broker supports, got %+v", unsupported)
+	}
+
+	_, err = broker.Produce(&ProduceRequest{Version: 2})
+	if !errors.As(err, &unsupported) || unsupported.Pinned || unsupported.MinVersion != 3 || unsupported.MaxVersion != 9 {
+		t.Errorf("Expected the broker not to support Produce v2, got %v", err)
+	}
+
+	// the requests unsupported by Config.Version fail with ErrUnsupportedVersion itself
+	if _, err = broker.DescribeCluster(&DescribeClusterRequest{}); err != ErrUnsupportedVersion {
+		t.Errorf("Expected Config.Version not to support DescribeCluster, got %v", err)
+	}
+
+	for _, rr := range mb.History() {
+		if req, ok := rr.Request.(*MetadataRequest); ok && req.Version != 1 && req.Version != 4 {
+			t.Errorf("Expected the unsupported requests not to be sent, got %+v", req)
+		}
+		switch rr.Request.(type) {
+		case *ProduceRequest, *FetchRequest:
+			t.Error("Expected the unsupported requests not to be sent")
+		}
+	}
+}
+
+func TestBrokerInterceptors(t *testing.T) {
+	mb := NewMockBroker(t, 1)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+		"ProduceRequest":  NewMockProduceResponse(t),
+	})
+	mb.SetLatency(50 * time.Millisecond)
+
+	recording := &recordingInterceptor{}
+	conf := NewTestConfig()
+	conf.Net.Interceptors = []BrokerInterceptor{panickingInterceptor{}, recording}
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	metadataRequest := &MetadataRequest{}
+	if _, err := broker.GetMetadata(metadataRequest); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	produceRequest := &ProduceRequest{RequiredAcks: WaitForLocal}
+	if err := broker.AsyncProduce(produceRequest, func(_ *ProduceResponse, err error) { done <- err }); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	recording.lock.Lock()
+	defer recording.lock.Unlock()
+	if len(recording.requests) != 2 || len(recording.responses) != 2 {
+		t.Fatalf("Expected 2 requests and responses, got %d and %d", len(recording.requests), len(recording.responses))
+	}
+	for i, body := range []protocolBody{metadataRequest, produceRequest} {
+		req, res := recording.requests[i], recording.responses[i]
+		if req.Body != body || req.APIKey != body.key() || req.APIVersion != body.version() {
+			t.Errorf("Unexpected intercepted request %+v", req)
+		}
+		if size := int(binary.BigEndian.Uint32(req.Encoded)); size != len(req.Encoded)-4 {
+			t.Errorf("Expected the encoded request to start with its size %d, got %d", len(req.Encoded)-4, size)
+		}
+		if res.APIKey != req.APIKey || res.APIVersion != req.APIVersion || res.CorrelationID != req.CorrelationID {
+			t.Errorf("Expected the response to match the request %+v, got %+v", req, res)
+		}
+		if res.Err != nil || res.Size <= 8 || res.Latency < 50*time.Millisecond {
+			t.Errorf("Unexpected intercepted response %+v", res)
+		}
+	}
+	if _, ok := recording.responses[0].Body.(*MetadataResponse); !ok {
+		t.Errorf("Expected a metadata response, got %T", recording.responses[0].Body)
+	}
+	if _, ok := recording.responses[1].Body.(*ProduceResponse); !ok {
+		t.Errorf("Expected a produce response, got %T", recording.responses[1].Body)
+	}
+}
+
+func TestBrokerPipelinesRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		perAPIKey map[int16]int
+		queued    int
+	}{
+		{"pipelined", nil, 2},
+		{"limited by API key", map[int16]int{3: 1}, 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mb := NewMockBroker(t, 0)
+			defer mb.Close()
+			mb.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t),
+			})
+			mb.SetLatency(300 * time.Millisecond)
+
+			broker := NewBroker(mb.Addr())
+			conf := NewTestConfig()
+			conf.Net.MaxOpenRequestsPerAPIKey = tt.perAPIKey
+			if err := broker.Open(conf); err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, broker)
+			if _, err := broker.Connected(); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			// the receiver waits for the response of the first request while the others are queued
+			if queued := broker.ResponseSize(); queued != tt.queued {
+				t.Errorf("Expected %d requests waiting for their response behind the first one, got %d", tt.queued, queued)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// TestBrokerMatchesResponsesByCorrelationID ensures that responses received out of the order of
+// their requests are handed to the requests with their correlation ID.
+func TestBrokerMatchesResponsesByCorrelationID(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the server answers the two requests in the reverse order, with the topic of each
+	// request as the host of the broker of its response
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var frames [][]byte
+		for i := 0; i < 2; i++ {
+			req, _, err := decodeRequest(conn)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res := new(MetadataResponse)
+			res.AddBroker(req.body.(*MetadataRequest).Topics[0]+":9092", 0)
+			body, err := encode(res, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			frame := make([]byte, 8, 8+len(body))
+			binary.BigEndian.PutUint32(frame, uint32(4+len(body)))
+			binary.BigEndian.PutUint32(frame[4:], uint32(req.correlationID))
+			frames = append(frames, append(frame, body...))
+		}
+		for i := len(frames) - 1; i >= 0; i-- {
+			if _, err := conn.Write(frames[i]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	broker := NewBroker(ln.Addr().String())
+	if err := broker.Open(NewTestConfig()); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	var wg sync.WaitGroup
+	for _, topic := range []string{"first", "second"} {
+		topic := topic
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := broker.GetMetadata(&MetadataRequest{Topics: []string{topic}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if addr := res.Brokers[0].Addr(); addr != topic+":9092" {
+				t.Errorf("Expected the response to the request for %s, got the one for %s", topic, addr)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBrokerOpenConcurrentRequest(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	broker := NewBroker(mb.Addr())
+	conf := NewTestConfig()
+	conf.Net.MaxOpenRequestsPerAPIKey = map[int16]int{3: 1}
+
+	// the request is either refused as not connected or sent, but must not race with Open
+	done := make(chan none)
+	go func() {
+		defer close(done)
+		_, _ = broker.GetMetadata(&MetadataRequest{})
+	}()
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	defer safeClose(t, broker)
+
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBrokerReadTimeoutPerAPIKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		perAPIKey map[int16]time.Duration
+		timeout   bool
+	}{
+		{"read timeout", nil, false},
+		{"metadata read timeout", map[int16]time.Duration{3: 50 * time.Millisecond}, true},
+		{"other API key read timeout", map[int16]time.Duration{0: 50 * time.Millisecond}, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mb := NewMockBroker(t, 0)
+			defer mb.Close()
+			mb.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t),
+			})
+			mb.SetLatency(200 * time.Millisecond)
+
+			broker := NewBroker(mb.Addr())
+			conf := NewTestConfig()
+			conf.Net.ReadTimeoutPerAPIKey = tt.perAPIKey
+			if err := broker.Open(conf); err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, broker)
+
+			_, err := broker.GetMetadata(&MetadataRequest{})
+			var netErr net.Error
+			if timeout := errors.As(err, &netErr) && netErr.Timeout(); timeout != tt.timeout {
+				t.Errorf("Expected timeout %t, got error %v", tt.timeout, err)
+			}
+		})
+	}
+}
+
+func TestSkipHeaderTaggedFields(t *testing.T) {
+	body := []byte{0x00, 0x00, 0x00, 0x05}
+
+	// no tagged field, then a tagged field with tag 1 and a 2 bytes value
+	for _, header := range [][]byte{{0x00}, {0x01, 0x01, 0x02, 0xab, 0xcd}} {
+		buf, err := skipHeaderTaggedFields(append(header, body...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, body) {
+			t.Errorf("Expected the body %v, got %v", body, buf)
+		}
+	}
+
+	if _, err := skipHeaderTaggedFields([]byte{0x01, 0x01, 0x05, 0xab}); err == nil {
+		t.Error("Expected an error for a truncated tagged field")
+	}
+}
+
+func TestBrokerConnectionsPerBroker(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	broker := NewBroker(mb.Addr())
+	conf := NewTestConfig()
+	conf.Net.ConnectionsPerBroker = 3
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conns := append([]*Broker{broker}, broker.pool...)
+	if len(conns) != 3 {
+		t.Fatalf("Expected 3 connections, got %d", len(conns))
+	}
+	for i, conn := range conns {
+		conn.lock.Lock()
+		sent := conn.correlationID
+		conn.lock.Unlock()
+		if sent != 2 {
+			t.Errorf("Expected connection %d to send 2 requests, sent %d", i, sent)
+		}
+	}
+
+	safeClose(t, broker)
+	if broker.pool != nil {
+		t.Error("Expected the pooled connections to be closed with the broker")
+	}
+	for i, conn := range conns[1:] {
+		if connected, _ := conn.Connected(); connected {
+			t.Errorf("Expected pooled connection %d to be closed", i)
+		}
+	}
+}
+
 func TestSASLOAuthBearer(t *testing.T) {
 	testTable := []struct {
 		name                      string
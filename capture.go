@@ -0,0 +1,84 @@
+package sarama
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Directions of the captured frames.
+const (
+	CapturedFrameSent     = "sent"
+	CapturedFrameReceived = "received"
+)
+
+// CapturedFrame is a frame sent to or received from a broker, as written by Net.Capture, one
+// JSON object per line, for offline analysis with a Kafka protocol decoder.
+type CapturedFrame struct {
+	Time     time.Time `json:"time"`
+	BrokerID int32     `json:"brokerId"`
+	Addr     string    `json:"addr"`
+	// Direction is CapturedFrameSent or CapturedFrameReceived.
+	Direction string `json:"direction"`
+	// APIKey and APIVersion are the ones of the request, for the responses too.
+	APIKey        int16 `json:"apiKey"`
+	APIVersion    int16 `json:"apiVersion"`
+	CorrelationID int32 `json:"correlationId"`
+	// Size is the length of the frame, its size prefix included.
+	Size int `json:"size"`
+	// Frame is the frame as written to or read from the connection, its size prefix
+	// included, base64 encoded in JSON. It is nil if Redacted.
+	Frame []byte `json:"frame,omitempty"`
+	// Redacted is whether the frame was left out as it carries credentials.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// captureLock serializes the writes of the captured frames, which the brokers write
+// concurrently, possibly to the same writer.
+var captureLock sync.Mutex
+
+// capturedFrameRedacted returns whether the frames of the API key carry credentials, so are
+// left out of the capture: the SASL tokens, the delegation tokens and their HMACs, and the
+// salted SCRAM passwords.
+func capturedFrameRedacted(key int16) bool {
+	switch key {
+	case 36, // SaslAuthenticate
+		38, 39, 40, 41, // CreateDelegationToken, RenewDelegationToken, ExpireDelegationToken, DescribeDelegationToken
+		51: // AlterUserScramCredentials
+		return true
+	default:
+		return false
+	}
+}
+
+// captureFrame writes the frame to Net.Capture.Writer, if enabled.
+func (b *Broker) captureFrame(direction string, key, version int16, correlationID int32, frame []byte) {
+	if !b.conf.Net.Capture.Enable {
+		return
+	}
+	captured := CapturedFrame{
+		Time:          time.Now(),
+		BrokerID:      b.id,
+		Addr:          b.addr,
+		Direction:     direction,
+		APIKey:        key,
+		APIVersion:    version,
+		CorrelationID: correlationID,
+		Size:          len(frame),
+		Frame:         frame,
+	}
+	if capturedFrameRedacted(key) {
+		captured.Frame, captured.Redacted = nil, true
+	}
+	line, err := json.Marshal(&captured)
+	if err != nil {
+		Logger.Printf("Error while capturing a frame of broker %s: %s\n", b.addr, err)
+		return
+	}
+
+	captureLock.Lock()
+	defer captureLock.Unlock()
+	if _, err := b.conf.Net.Capture.Writer.Write(append(line, '\n')); err != nil {
+		Logger.Printf("Error while capturing a frame of broker %s: %s\n", b.addr, err)
+	}
+}
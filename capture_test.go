@@ -0,0 +1,98 @@
+package sarama
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func TestBrokerCapture(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+			SetEnabledMechanisms([]string{SASLTypePlaintext}),
+		"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t),
+		"MetadataRequest":         NewMockMetadataResponse(t),
+	})
+
+	var capture bytes.Buffer
+	conf := NewTestConfig()
+	conf.Version = V1_0_0_0
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = SASLTypePlaintext
+	conf.Net.SASL.Version = SASLHandshakeV1
+	conf.Net.SASL.User = "user"
+	conf.Net.SASL.Password = "secret-password"
+	conf.Net.Capture.Enable = true
+	conf.Net.Capture.Writer = &capture
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, broker)
+
+	if bytes.Contains(capture.Bytes(), []byte(conf.Net.SASL.Password)) {
+		t.Error("Expected the SASL credentials to be redacted")
+	}
+
+	var frames []CapturedFrame
+	for scanner := bufio.NewScanner(&capture); scanner.Scan(); {
+		var frame CapturedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+	expected := []struct {
+		direction string
+		key       int16
+		redacted  bool
+	}{
+		{CapturedFrameSent, 17, false},
+		{CapturedFrameReceived, 17, false},
+		{CapturedFrameSent, 36, true},
+		{CapturedFrameReceived, 36, true},
+		{CapturedFrameSent, 3, false},
+		{CapturedFrameReceived, 3, false},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("Expected %d frames to be captured, got %+v", len(expected), frames)
+	}
+	for i, e := range expected {
+		frame := frames[i]
+		if frame.Direction != e.direction || frame.APIKey != e.key || frame.Redacted != e.redacted || frame.Addr != mb.Addr() {
+			t.Errorf("Frame %d: expected %s API key %d redacted %v, got %+v", i, e.direction, e.key, e.redacted, frame)
+			continue
+		}
+		if frame.Redacted {
+			if frame.Frame != nil || frame.Size == 0 {
+				t.Errorf("Frame %d: expected only the size of a redacted frame, got %+v", i, frame)
+			}
+			continue
+		}
+		if len(frame.Frame) != frame.Size || int(binary.BigEndian.Uint32(frame.Frame)) != frame.Size-4 {
+			t.Errorf("Frame %d: expected the whole frame with its size prefix, got %+v", i, frame)
+		}
+		if e.direction == CapturedFrameSent && int16(binary.BigEndian.Uint16(frame.Frame[4:])) != e.key {
+			t.Errorf("Frame %d: expected the request header to be captured, got %v", i, frame.Frame)
+		}
+		if int32(binary.BigEndian.Uint32(frame.Frame[correlationIDOffset(e.direction):])) != frame.CorrelationID {
+			t.Errorf("Frame %d: expected correlation ID %d in the frame, got %v", i, frame.CorrelationID, frame.Frame)
+		}
+	}
+}
+
+// correlationIDOffset returns the offset of the correlation ID in the frames of the direction.
+func correlationIDOffset(direction string) int {
+	if direction == CapturedFrameSent {
+		return 8 // after the size, API key and API version
+	}
+	return 4 // after the size
+}
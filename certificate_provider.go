@@ -0,0 +1,93 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateProvider provides the client certificate of the TLS handshakes with the brokers,
+// see Config.Net.TLS.CertificateProvider. It is consulted on each handshake, so that new
+// connections use the current certificate while the established ones keep the certificate
+// they were made with.
+type CertificateProvider interface {
+	// GetClientCertificate returns the certificate to present to the broker, as
+	// tls.Config.GetClientCertificate.
+	GetClientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error)
+}
+
+// FileCertificateProvider is a CertificateProvider loading a PEM encoded certificate and key
+// pair from files, which it reloads when they are modified, so that short-lived certificates
+// can be rotated by replacing the files.
+type FileCertificateProvider struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	lock      sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewFileCertificateProvider loads the certificate and key pair from the files, checking
+// whether they were modified at most once per interval during the handshakes. A certificate
+// failing to load is logged and the previous one kept until the files are fixed.
+func NewFileCertificateProvider(certFile, keyFile string, interval time.Duration) (*FileCertificateProvider, error) {
+	p := &FileCertificateProvider{certFile: certFile, keyFile: keyFile, interval: interval}
+	modTime, err := p.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.load(modTime); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetClientCertificate implements CertificateProvider.
+func (p *FileCertificateProvider) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if now := time.Now(); now.Sub(p.checkedAt) >= p.interval {
+		p.checkedAt = now
+		modTime, err := p.filesModTime()
+		if err != nil {
+			Logger.Printf("Failed to check the client certificate %s: %s\n", p.certFile, err)
+		} else if !modTime.Equal(p.modTime) {
+			if err := p.load(modTime); err != nil {
+				Logger.Printf("Failed to reload the client certificate %s: %s\n", p.certFile, err)
+			} else {
+				DebugLogger.Printf("Reloaded the client certificate %s\n", p.certFile)
+			}
+		}
+	}
+	return p.cert, nil
+}
+
+// filesModTime returns the latest modification time of the certificate and key files.
+func (p *FileCertificateProvider) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{p.certFile, p.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (p *FileCertificateProvider) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return err
+	}
+	p.cert = &cert
+	p.modTime = modTime
+	p.checkedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,162 @@
+package sarama
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed client certificate with the common name and its
+// key to the files.
+func writeTestCertificate(t *testing.T, commonName, certFile, keyFile string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		Subject:      pkix.Name{CommonName: commonName},
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: commonName}}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func certificateCommonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestFileCertificateProviderReloads(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeTestCertificate(t, "client-1", certFile, keyFile)
+
+	provider, err := NewFileCertificateProvider(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := provider.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := certificateCommonName(t, cert); cn != "client-1" {
+		t.Errorf("Expected the certificate of client-1, got %s", cn)
+	}
+
+	// the certificate is rotated
+	writeTestCertificate(t, "client-2", certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cert, err = provider.GetClientCertificate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if cn := certificateCommonName(t, cert); cn != "client-2" {
+		t.Errorf("Expected the rotated certificate of client-2, got %s", cn)
+	}
+
+	// a broken certificate keeps the previous one
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = provider.GetClientCertificate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if cn := certificateCommonName(t, cert); cn != "client-2" {
+		t.Errorf("Expected the previous certificate of client-2, got %s", cn)
+	}
+}
+
+func TestFileCertificateProviderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewFileCertificateProvider(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key"), time.Minute); err == nil {
+		t.Error("Expected an error loading missing files")
+	}
+}
+
+func TestBrokerCertificateProvider(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeTestCertificate(t, "client-1", certFile, keyFile)
+	provider, err := NewFileCertificateProvider(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCertFile, serverKeyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	writeTestCertificate(t, "server", serverCertFile, serverKeyFile)
+	serverCert, err := tls.LoadX509KeyPair(serverCertFile, serverKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := make(chan string, 1)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			clients <- cert.Subject.CommonName
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := NewMockBrokerListener(t, 1, listener)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	conf := NewTestConfig()
+	conf.Net.TLS.Enable = true
+	conf.Net.TLS.Config = &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS12}
+	conf.Net.TLS.CertificateProvider = provider
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if cn := <-clients; cn != "client-1" {
+		t.Errorf("Expected the broker to get the certificate of client-1, got %s", cn)
+	}
+}
@@ -0,0 +1,106 @@
+package sarama
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// brokerCircuit is the circuit breaker of a broker, see Config.Net.CircuitBreaker. It opens
+// after consecutive failures, failing the requests right away for the backoff, then lets a
+// single request probe the broker, closing again if it succeeds.
+type brokerCircuit struct {
+	lock     sync.Mutex
+	enabled  bool
+	maxFails int
+	backoff  time.Duration
+	// whether the broker can be probed with an ApiVersions request, the request let through
+	// being the probe otherwise
+	canProbe bool
+
+	failures  int
+	openUntil time.Time // zero while the circuit is closed
+	probing   bool
+}
+
+// configure sets the thresholds of the circuit from the config, keeping its state so that it
+// survives the broker being closed and opened again.
+func (c *brokerCircuit) configure(conf *Config) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.enabled = conf.Net.CircuitBreaker.Enable
+	c.maxFails = conf.Net.CircuitBreaker.Failures
+	c.backoff = conf.Net.CircuitBreaker.Backoff
+	c.canProbe = conf.Version.IsAtLeast(V0_10_0_0)
+	if !c.enabled {
+		c.failures, c.openUntil, c.probing = 0, time.Time{}, false
+	}
+}
+
+// allow returns ErrBrokerCircuitOpen if the request must not be sent, and whether the caller
+// must probe the broker with an ApiVersions request first, once the backoff is over.
+func (c *brokerCircuit) allow() (probe bool, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch {
+	case !c.enabled || c.openUntil.IsZero():
+		return false, nil
+	case c.probing || time.Now().Before(c.openUntil):
+		return false, ErrBrokerCircuitOpen
+	default:
+		c.probing = true
+		return c.canProbe, nil
+	}
+}
+
+// record records the outcome of a request, returning whether it opened or closed the circuit.
+func (c *brokerCircuit) record(err error) (opened, closed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// a probe failing before reaching the broker lets the next request probe it
+	c.probing = false
+	if !c.enabled || !reflectsBrokerHealth(err) {
+		return false, false
+	}
+	if err == nil {
+		closed = !c.openUntil.IsZero()
+		c.failures = 0
+		c.openUntil = time.Time{}
+		return false, closed
+	}
+	c.failures++
+	if c.failures < c.maxFails {
+		return false, false
+	}
+	opened = c.openUntil.IsZero()
+	c.openUntil = time.Now().Add(c.backoff)
+	return opened, false
+}
+
+// isOpen returns whether the requests to the broker currently fail right away.
+func (c *brokerCircuit) isOpen() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.enabled && !c.openUntil.IsZero() && (c.probing || time.Now().Before(c.openUntil))
+}
+
+// reopensAt returns when the backoff of the open circuit ends, zero while it is closed.
+func (c *brokerCircuit) reopensAt() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.openUntil
+}
+
+// reflectsBrokerHealth returns whether the outcome of a request tells about the health of the
+// broker, the errors raised by the client before sending it not doing so.
+func reflectsBrokerHealth(err error) bool {
+	var encodingErr PacketEncodingError
+	return !errors.Is(err, ErrBrokerCircuitOpen) &&
+		!errors.Is(err, ErrUnsupportedVersion) &&
+		!errors.As(err, &encodingErr)
+}
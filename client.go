@@ -5,6 +5,7 @@ import (
 	"math"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -35,9 +36,20 @@ type Client interface {
 	// Broker returns the active Broker if available for the broker ID.
 	Broker(brokerID int32) (*Broker, error)
 
+	// NegotiatedAPIVersions returns the range of versions of each API key the requests to
+	// the broker can be sent with, i.e. the versions it supports capped by
+	// Config.MaxAPIVersions, nil if they are not known yet. See
+	// Broker.NegotiatedAPIVersions.
+	NegotiatedAPIVersions(brokerID int32) (map[int16]ApiVersionsResponseKey, error)
+
 	// Topics returns the set of available topics as retrieved from cluster metadata.
 	Topics() ([]string, error)
 
+	// TopicID returns the ID of the given topic as retrieved from cluster metadata,
+	// which changes when the topic is deleted and created again. It is the zero ID
+	// if the brokers do not support topic IDs. Requires Kafka 2.8 or higher.
+	TopicID(topic string) (Uuid, error)
+
 	// Partitions returns the sorted list of all partition IDs for the given topic.
 	Partitions(topic string) ([]int32, error)
 
@@ -82,6 +94,13 @@ type Client interface {
 	// OffsetNewest for the offset of the message that will be produced next, or a time.
 	GetOffset(topic string, partitionID int32, time int64) (int64, error)
 
+	// GetOffsetsForTimestamp queries the cluster, with a single request per partition
+	// leader, for the offset of the first message produced at or after the given
+	// timestamp on every partition of the topic. Partitions without any such message
+	// get the offset of the message that will be produced next, so that the returned
+	// offsets can be passed as they are to ConsumePartition.
+	GetOffsetsForTimestamp(topic string, timestamp time.Time) (map[int32]int64, error)
+
 	// Coordinator returns the coordinating broker for a consumer group. It will
 	// return a locally cached value if it's available. You can call
 	// RefreshCoordinator to update the cached value. This function only works on
@@ -105,7 +124,9 @@ type Client interface {
 	// InitProducerID retrieves information required for Idempotent Producer
 	InitProducerID() (*InitProducerIDResponse, error)
 
-	// LeastLoadedBroker retrieves broker that has the least responses pending
+	// LeastLoadedBroker retrieves broker that has the least responses pending, skipping the
+	// brokers whose circuit breaker is open (see Config.Net.CircuitBreaker), or the one
+	// picked by Config.BrokerSelector if set.
 	LeastLoadedBroker() *Broker
 
 	// Close shuts down all broker connections managed by this client. It is required
@@ -129,6 +150,18 @@ const (
 	// offset, or when calling ConsumePartition to start consuming from the
 	// oldest offset that is still available on the broker.
 	OffsetOldest int64 = -2
+	// OffsetTimestamp stands for the offset of the first message produced at or
+	// after Consumer.Offsets.InitialTimestamp, or OffsetNewest if there is none.
+	// It can be used as Consumer.Offsets.Initial, or when calling ConsumePartition.
+	OffsetTimestamp int64 = math.MinInt64
+	// OffsetRelative stands for the offset of the first message produced at or
+	// after Consumer.Offsets.InitialLookback ago, or OffsetNewest if there is none.
+	// It can be used as Consumer.Offsets.Initial, or when calling ConsumePartition.
+	OffsetRelative int64 = math.MinInt64 + 1
+	// OffsetFail stands for no offset at all: used as Consumer.Offsets.Initial,
+	// consuming a partition without a committed offset fails with ErrNoInitialOffset,
+	// and the offsets that are out of range are not reset.
+	OffsetFail int64 = math.MinInt64 + 2
 )
 
 type client struct {
@@ -145,11 +178,14 @@ type client struct {
 	// so we store them separately
 	seedBrokers []*Broker
 	deadSeeds   []*Broker
+	// the SRV names the seed brokers are resolved from, see Config.Net.SRV
+	srvNames []string
 
 	controllerID            int32                                   // cluster controller broker id
 	brokers                 map[int32]*Broker                       // maps broker ids to brokers
 	metadata                map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
 	metadataTopics          map[string]none                         // topics that need to collect metadata
+	topicIDs                map[string]Uuid                         // maps topics to their IDs
 	coordinators            map[string]int32                        // Maps consumer group names to coordinating broker IDs
 	transactionCoordinators map[string]int32                        // Maps transaction ids to coordinating broker IDs
 
@@ -159,6 +195,16 @@ type client struct {
 
 	lock sync.RWMutex // protects access to the maps that hold cluster state.
 
+	refreshLock     sync.Mutex                  // protects refreshes and fullRefreshedAt
+	refreshes       map[string]*metadataRefresh // the metadata refreshes in flight, by their topics
+	fullRefreshedAt time.Time                   // when the metadata of all topics was last refreshed
+}
+
+// metadataRefresh is a metadata refresh in flight, whose result is shared by the concurrent
+// refreshes of the same topics.
+type metadataRefresh struct {
+	done chan none
+	err  error
 }
 
 // NewClient creates a new Client. It connects to one of the given broker addresses
@@ -186,12 +232,21 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		brokers:                 make(map[int32]*Broker),
 		metadata:                make(map[string]map[int32]*PartitionMetadata),
 		metadataTopics:          make(map[string]none),
+		topicIDs:                make(map[string]Uuid),
 		cachedPartitionsResults: make(map[string][maxPartitionIndex][]int32),
 		coordinators:            make(map[string]int32),
 		transactionCoordinators: make(map[string]int32),
+		refreshes:               make(map[string]*metadataRefresh),
 	}
 
-	client.randomizeSeedBrokers(addrs)
+	seedBrokers, err := client.newSeedBrokers(addrs)
+	if err != nil {
+		return nil, err
+	}
+	client.seedBrokers = seedBrokers
+	if conf.Net.SRV.Enable {
+		client.srvNames = addrs
+	}
 
 	if conf.Metadata.Full {
 		// do an initial fetch of all cluster metadata by specifying an empty list of topics
@@ -207,6 +262,9 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		}
 	}
 	go withRecover(client.backgroundMetadataUpdater)
+	if interval := idleReapingInterval(conf); interval > 0 {
+		go withRecover(func() { client.reapIdleBrokers(interval) })
+	}
 
 	DebugLogger.Println("Successfully initialized new client")
 
@@ -238,6 +296,14 @@ func (client *client) Broker(brokerID int32) (*Broker, error) {
 	return broker, nil
 }
 
+func (client *client) NegotiatedAPIVersions(brokerID int32) (map[int16]ApiVersionsResponseKey, error) {
+	broker, err := client.Broker(brokerID)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NegotiatedAPIVersions(), nil
+}
+
 func (client *client) InitProducerID() (*InitProducerIDResponse, error) {
 	brokerErrors := make([]error, 0)
 	for broker := client.anyBroker(); broker != nil; broker = client.anyBroker() {
@@ -286,6 +352,7 @@ func (client *client) Close() error {
 	client.brokers = nil
 	client.metadata = nil
 	client.metadataTopics = nil
+	client.topicIDs = nil
 
 	return nil
 }
@@ -313,6 +380,28 @@ func (client *client) Topics() ([]string, error) {
 	return ret, nil
 }
 
+func (client *client) TopicID(topic string) (Uuid, error) {
+	if client.Closed() {
+		return Uuid{}, ErrClosedClient
+	}
+
+	id, known := client.cachedTopicID(topic)
+
+	if !known {
+		err := client.RefreshMetadata(topic)
+		if err != nil {
+			return Uuid{}, err
+		}
+		id, known = client.cachedTopicID(topic)
+	}
+
+	if !known {
+		return Uuid{}, ErrUnknownTopicOrPartition
+	}
+
+	return id, nil
+}
+
 func (client *client) MetadataTopics() ([]string, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
@@ -482,6 +571,11 @@ func (client *client) RefreshBrokers(addrs []string) error {
 		return ErrClosedClient
 	}
 
+	seedBrokers, err := client.newSeedBrokers(addrs)
+	if err != nil {
+		return err
+	}
+
 	client.lock.Lock()
 	defer client.lock.Unlock()
 
@@ -501,7 +595,10 @@ func (client *client) RefreshBrokers(addrs []string) error {
 	client.seedBrokers = nil
 	client.deadSeeds = nil
 
-	client.randomizeSeedBrokers(addrs)
+	client.seedBrokers = seedBrokers
+	if client.conf.Net.SRV.Enable {
+		client.srvNames = addrs
+	}
 
 	return nil
 }
@@ -520,11 +617,47 @@ func (client *client) RefreshMetadata(topics ...string) error {
 		}
 	}
 
+	// concurrent refreshes of the same topics share a single metadata request
+	key := metadataRefreshKey(topics)
+	client.refreshLock.Lock()
+	if refresh, ok := client.refreshes[key]; ok {
+		client.refreshLock.Unlock()
+		<-refresh.done
+		return refresh.err
+	}
+	if backoff := client.conf.Metadata.FullRefreshBackoff; len(topics) == 0 && backoff > 0 &&
+		time.Since(client.fullRefreshedAt) < backoff {
+		client.refreshLock.Unlock()
+		DebugLogger.Println("client/metadata skipping the refresh of all topics as they were refreshed recently")
+		return nil
+	}
+	refresh := &metadataRefresh{done: make(chan none)}
+	client.refreshes[key] = refresh
+	client.refreshLock.Unlock()
+
 	deadline := time.Time{}
 	if client.conf.Metadata.Timeout > 0 {
 		deadline = time.Now().Add(client.conf.Metadata.Timeout)
 	}
-	return client.tryRefreshMetadata(topics, client.conf.Metadata.Retry.Max, deadline)
+	refresh.err = client.tryRefreshMetadata(topics, client.conf.Metadata.Retry.Max, deadline)
+
+	client.refreshLock.Lock()
+	delete(client.refreshes, key)
+	if len(topics) == 0 && refresh.err == nil {
+		client.fullRefreshedAt = time.Now()
+	}
+	client.refreshLock.Unlock()
+	close(refresh.done)
+
+	return refresh.err
+}
+
+// metadataRefreshKey identifies the refreshes of the same topics, whatever their order.
+func metadataRefreshKey(topics []string) string {
+	sorted := make([]string, len(topics))
+	copy(sorted, topics)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
 }
 
 func (client *client) GetOffset(topic string, partitionID int32, time int64) (int64, error) {
@@ -543,6 +676,54 @@ func (client *client) GetOffset(topic string, partitionID int32, time int64) (in
 	return offset, err
 }
 
+func (client *client) GetOffsetsForTimestamp(topic string, timestamp time.Time) (map[int32]int64, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	millis := timestamp.UnixNano() / int64(time.Millisecond)
+	offsets, err := client.getOffsetsForTimestamp(topic, millis)
+	if err != nil {
+		if err := client.RefreshMetadata(topic); err != nil {
+			return nil, err
+		}
+		return client.getOffsetsForTimestamp(topic, millis)
+	}
+
+	return offsets, nil
+}
+
+func (client *client) getOffsetsForTimestamp(topic string, millis int64) (map[int32]int64, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := client.getOffsets(topic, partitions, millis)
+	if err != nil {
+		return nil, err
+	}
+
+	// brokers answer -1 for the partitions without any message at or after the timestamp
+	var missing []int32
+	for partition, offset := range offsets {
+		if offset < 0 {
+			missing = append(missing, partition)
+		}
+	}
+	if len(missing) > 0 {
+		newest, err := client.getOffsets(topic, missing, OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		for partition, offset := range newest {
+			offsets[partition] = offset
+		}
+	}
+
+	return offsets, nil
+}
+
 func (client *client) Controller() (*Broker, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
@@ -680,11 +861,32 @@ func (client *client) RefreshTransactionCoordinator(transactionID string) error
 
 // private broker management helpers
 
-func (client *client) randomizeSeedBrokers(addrs []string) {
+// newSeedBrokers returns the seed brokers at the addresses in a random order, or at the targets
+// of the SRV names if Config.Net.SRV is enabled.
+func (client *client) newSeedBrokers(addrs []string) ([]*Broker, error) {
+	if !client.conf.Net.SRV.Enable {
+		return randomizeSeedBrokers(addrs), nil
+	}
+
+	resolved, err := resolveSRV(client.conf, addrs)
+	if err != nil {
+		return nil, err
+	}
+	// the records are already ordered by priority and weight
+	seedBrokers := make([]*Broker, 0, len(resolved))
+	for _, addr := range resolved {
+		seedBrokers = append(seedBrokers, NewBroker(addr))
+	}
+	return seedBrokers, nil
+}
+
+func randomizeSeedBrokers(addrs []string) []*Broker {
+	seedBrokers := make([]*Broker, 0, len(addrs))
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	for _, index := range random.Perm(len(addrs)) {
-		client.seedBrokers = append(client.seedBrokers, NewBroker(addrs[index]))
+		seedBrokers = append(seedBrokers, NewBroker(addrs[index]))
 	}
+	return seedBrokers
 }
 
 func (client *client) updateBroker(brokers []*Broker) {
@@ -750,6 +952,18 @@ func (client *client) deregisterBroker(broker *Broker) {
 }
 
 func (client *client) resurrectDeadBrokers() {
+	client.lock.RLock()
+	srvNames := client.srvNames
+	client.lock.RUnlock()
+	if len(srvNames) > 0 {
+		addrs, err := resolveSRV(client.conf, srvNames)
+		if err == nil {
+			client.replaceSeedBrokers(srvNames, addrs)
+			return
+		}
+		Logger.Printf("client/brokers failed to resolve the seed brokers of %s: %v", strings.Join(srvNames, ", "), err)
+	}
+
 	client.lock.Lock()
 	defer client.lock.Unlock()
 
@@ -758,10 +972,44 @@ func (client *client) resurrectDeadBrokers() {
 	client.deadSeeds = nil
 }
 
+// replaceSeedBrokers replaces the seed brokers with the brokers at the addresses, keeping the
+// current seed brokers still found at their address.
+func (client *client) replaceSeedBrokers(srvNames, addrs []string) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	current := make(map[string]*Broker, len(client.seedBrokers)+len(client.deadSeeds))
+	for _, broker := range append(client.seedBrokers, client.deadSeeds...) {
+		current[broker.Addr()] = broker
+	}
+
+	seedBrokers := make([]*Broker, 0, len(addrs))
+	for _, addr := range addrs {
+		if broker, ok := current[addr]; ok {
+			delete(current, addr)
+			seedBrokers = append(seedBrokers, broker)
+		} else {
+			seedBrokers = append(seedBrokers, NewBroker(addr))
+		}
+	}
+	for _, broker := range current {
+		safeAsyncClose(broker)
+	}
+
+	Logger.Printf("client/brokers resolved %d seed brokers from %s", len(seedBrokers), strings.Join(srvNames, ", "))
+	client.seedBrokers = seedBrokers
+	client.deadSeeds = nil
+}
+
 func (client *client) anyBroker() *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
 
+	if broker := client.selectBroker(); broker != nil {
+		_ = broker.Open(client.conf)
+		return broker
+	}
+
 	if len(client.seedBrokers) > 0 {
 		_ = client.seedBrokers[0].Open(client.conf)
 		return client.seedBrokers[0]
@@ -769,10 +1017,17 @@ func (client *client) anyBroker() *Broker {
 
 	// not guaranteed to be random *or* deterministic
 	for _, broker := range client.brokers {
+		if broker.circuit.isOpen() {
+			continue
+		}
 		_ = broker.Open(client.conf)
 		return broker
 	}
 
+	if broker := client.leastOpenBroker(); broker != nil {
+		_ = broker.Open(client.conf)
+		return broker
+	}
 	return nil
 }
 
@@ -780,6 +1035,11 @@ func (client *client) LeastLoadedBroker() *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
 
+	if broker := client.selectBroker(); broker != nil {
+		_ = broker.Open(client.conf)
+		return broker
+	}
+
 	if len(client.seedBrokers) > 0 {
 		_ = client.seedBrokers[0].Open(client.conf)
 		return client.seedBrokers[0]
@@ -788,18 +1048,59 @@ func (client *client) LeastLoadedBroker() *Broker {
 	var leastLoadedBroker *Broker
 	pendingRequests := math.MaxInt
 	for _, broker := range client.brokers {
+		if broker.circuit.isOpen() {
+			continue
+		}
 		if pendingRequests > broker.ResponseSize() {
 			pendingRequests = broker.ResponseSize()
 			leastLoadedBroker = broker
 		}
 	}
 
+	if leastLoadedBroker == nil {
+		leastLoadedBroker = client.leastOpenBroker()
+	}
 	if leastLoadedBroker != nil {
 		_ = leastLoadedBroker.Open(client.conf)
 	}
 	return leastLoadedBroker
 }
 
+// leastOpenBroker returns the known broker whose circuit closes the soonest, for when the
+// circuit of every known broker is open, nil if there is none. client.lock must be held by
+// the caller.
+func (client *client) leastOpenBroker() *Broker {
+	var leastOpen *Broker
+	var reopensAt time.Time
+	for _, broker := range client.brokers {
+		if at := broker.circuit.reopensAt(); leastOpen == nil || at.Before(reopensAt) {
+			leastOpen, reopensAt = broker, at
+		}
+	}
+	return leastOpen
+}
+
+// selectBroker returns the broker picked by Config.BrokerSelector among the known brokers
+// whose circuit is not open, nil if no selector is set or it picked none. client.lock must be
+// held by the caller.
+func (client *client) selectBroker() *Broker {
+	selector := client.conf.BrokerSelector
+	if selector == nil {
+		return nil
+	}
+	brokers := make([]*Broker, 0, len(client.brokers))
+	for _, broker := range client.brokers {
+		if !broker.circuit.isOpen() {
+			brokers = append(brokers, broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return nil
+	}
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+	return selector.Select(brokers)
+}
+
 // private caching/lazy metadata helpers
 
 type partitionType int
@@ -825,6 +1126,15 @@ func (client *client) cachedMetadata(topic string, partitionID int32) *Partition
 	return nil
 }
 
+// cachedTopicID returns the ID of the topic, and whether the metadata of the topic is known.
+func (client *client) cachedTopicID(topic string) (Uuid, bool) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	_, known := client.metadata[topic]
+	return client.topicIDs[topic], known
+}
+
 func (client *client) cachedPartitions(topic string, partitionSet partitionType) []int32 {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -912,6 +1222,113 @@ func (client *client) getOffset(topic string, partitionID int32, time int64) (in
 	return block.Offsets[0], nil
 }
 
+// getOffsets lists the offsets at the given time of partitions of a topic, sending a
+// single request to each partition leader.
+func (client *client) getOffsets(topic string, partitions []int32, at int64) (map[int32]int64, error) {
+	times := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		times[partition] = at
+	}
+	requests, err := offsetRequests(client, map[string]map[int32]int64{topic: times}, func() *OffsetRequest {
+		request := &OffsetRequest{}
+		if client.conf.Version.IsAtLeast(V0_10_1_0) {
+			request.Version = 1
+		}
+		return request
+	}, func(topic string, partition int32, err error) error {
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	sendOffsetRequests(client.conf, requests, func(broker *Broker, request *OffsetRequest, response *OffsetResponse, requestErr error) {
+		if err != nil {
+			return
+		}
+		if requestErr != nil {
+			_ = broker.Close()
+			err = requestErr
+			return
+		}
+		for partition := range request.blocks[topic] {
+			block := response.GetBlock(topic, partition)
+			if block == nil {
+				_ = broker.Close()
+				err = ErrIncompleteResponse
+				return
+			}
+			if !errors.Is(block.Err, ErrNoError) {
+				err = block.Err
+				return
+			}
+			if len(block.Offsets) != 1 {
+				err = ErrOffsetOutOfRange
+				return
+			}
+			offsets[partition] = block.Offsets[0]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return offsets, nil
+}
+
+// offsetRequests groups partitions by leader, adding the time of each partition to a single
+// OffsetRequest per leader created by newRequest. The error of the partitions whose leader
+// can't be found is passed to unknownLeader, the grouping failing if it returns an error.
+func offsetRequests(client Client, times map[string]map[int32]int64, newRequest func() *OffsetRequest,
+	unknownLeader func(topic string, partition int32, err error) error,
+) (map[*Broker]*OffsetRequest, error) {
+	requests := make(map[*Broker]*OffsetRequest)
+	for topic, partitions := range times {
+		for partition, at := range partitions {
+			broker, err := client.Leader(topic, partition)
+			if err != nil {
+				if err := unknownLeader(topic, partition, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			request := requests[broker]
+			if request == nil {
+				request = newRequest()
+				requests[broker] = request
+			}
+			request.AddBlock(topic, partition, at, 1)
+		}
+	}
+	return requests, nil
+}
+
+// sendOffsetRequests sends the requests built by offsetRequests to their broker concurrently,
+// passing each request with its response, or the error sending it, to handle. The calls to
+// handle are serialized.
+func sendOffsetRequests(conf *Config, requests map[*Broker]*OffsetRequest,
+	handle func(broker *Broker, request *OffsetRequest, response *OffsetResponse, err error),
+) {
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	for broker, request := range requests {
+		wg.Add(1)
+		go func(broker *Broker, request *OffsetRequest) {
+			defer wg.Done()
+			_ = broker.Open(conf)
+			response, err := broker.GetAvailableOffsets(request)
+
+			lock.Lock()
+			defer lock.Unlock()
+			handle(broker, request, response, err)
+		}(broker, request)
+	}
+	wg.Wait()
+}
+
 // core metadata update logic
 
 func (client *client) backgroundMetadataUpdater() {
@@ -936,6 +1353,50 @@ func (client *client) backgroundMetadataUpdater() {
 	}
 }
 
+// idleReapingInterval returns how often to look for the idle connections to close, half the
+// shortest of Net.MaxIdle and Net.MaxIdlePerBroker, or 0 if they are all kept open.
+func idleReapingInterval(conf *Config) time.Duration {
+	shortest := conf.Net.MaxIdle
+	for _, maxIdle := range conf.Net.MaxIdlePerBroker {
+		if maxIdle > 0 && (shortest == 0 || maxIdle < shortest) {
+			shortest = maxIdle
+		}
+	}
+	return shortest / 2
+}
+
+// reapIdleBrokers closes the connections to the brokers idle for longer than their
+// Net.MaxIdle, every interval until the client is closed.
+func (client *client) reapIdleBrokers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			client.lock.RLock()
+			brokers := make([]*Broker, 0, len(client.brokers)+len(client.seedBrokers))
+			for _, broker := range client.brokers {
+				brokers = append(brokers, broker)
+			}
+			brokers = append(brokers, client.seedBrokers...)
+			client.lock.RUnlock()
+
+			for _, broker := range brokers {
+				maxIdle, ok := client.conf.Net.MaxIdlePerBroker[broker.ID()]
+				if !ok {
+					maxIdle = client.conf.Net.MaxIdle
+				}
+				if maxIdle > 0 && broker.closeIfIdle(maxIdle) {
+					Logger.Printf("client/brokers closed the connection to broker #%d at %s idle for more than %v\n", broker.ID(), broker.Addr(), maxIdle)
+				}
+			}
+		case <-client.closer:
+			return
+		}
+	}
+}
+
 func (client *client) refreshMetadata() error {
 	var topics []string
 
@@ -964,7 +1425,7 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 		}
 		return false
 	}
-	retry := func(err error) error {
+	retry := func(topics []string, err error) error {
 		if attemptsRemaining > 0 {
 			backoff := client.computeBackoff(attemptsRemaining)
 			if pastDeadline(backoff) {
@@ -1010,10 +1471,11 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 		if err == nil {
 			allKnownMetaData := len(topics) == 0
 			// valid response, use it
-			shouldRetry, err := client.updateMetadata(response, allKnownMetaData)
-			if shouldRetry {
+			retryTopics, err := client.updateMetadata(response, allKnownMetaData)
+			if len(retryTopics) > 0 {
+				// only the topics which need it are refreshed again
 				Logger.Println("client/metadata found some partitions to be leaderless")
-				return retry(err) // note: err can be nil
+				return retry(retryTopics, err) // note: err can be nil
 			}
 			return err
 		} else if errors.As(err, &packetEncodingError) {
@@ -1046,16 +1508,17 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 	error := Wrap(ErrOutOfBrokers, brokerErrors...)
 	if broker != nil {
 		Logger.Printf("client/metadata not fetching metadata from broker %s as we would go past the metadata timeout\n", broker.addr)
-		return retry(error)
+		return retry(topics, error)
 	}
 
 	Logger.Println("client/metadata no available broker to send metadata request to")
 	client.resurrectDeadBrokers()
-	return retry(error)
+	return retry(topics, error)
 }
 
 // if no fatal error, returns a list of topics that need retrying due to ErrLeaderNotAvailable
-func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bool) (retry bool, err error) {
+// or ErrUnknownTopicOrPartition
+func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bool) (retry []string, err error) {
 	if client.Closed() {
 		return
 	}
@@ -1075,6 +1538,7 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 	if allKnownMetaData {
 		client.metadata = make(map[string]map[int32]*PartitionMetadata)
 		client.metadataTopics = make(map[string]none)
+		client.topicIDs = make(map[string]Uuid)
 		client.cachedPartitionsResults = make(map[string][maxPartitionIndex][]int32)
 	}
 	for _, topic := range data.Topics {
@@ -1086,6 +1550,12 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 		}
 		delete(client.metadata, topic.Name)
 		delete(client.cachedPartitionsResults, topic.Name)
+		// a topic deleted and created again gets a new ID, which replaces the old one
+		if topic.Uuid.IsZero() {
+			delete(client.topicIDs, topic.Name)
+		} else {
+			client.topicIDs[topic.Name] = topic.Uuid
+		}
 
 		switch topic.Err {
 		case ErrNoError:
@@ -1095,10 +1565,10 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 			continue
 		case ErrUnknownTopicOrPartition: // retry, do not store partial partition results
 			err = topic.Err
-			retry = true
+			retry = append(retry, topic.Name)
 			continue
 		case ErrLeaderNotAvailable: // retry, but store partial partition results
-			retry = true
+			retry = append(retry, topic.Name)
 		default: // don't retry, don't store partial results
 			Logger.Printf("Unexpected topic-level metadata error: %s", topic.Err)
 			err = topic.Err
@@ -1106,12 +1576,16 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 		}
 
 		client.metadata[topic.Name] = make(map[int32]*PartitionMetadata, len(topic.Partitions))
+		leaderless := false
 		for _, partition := range topic.Partitions {
 			client.metadata[topic.Name][partition.ID] = partition
 			if errors.Is(partition.Err, ErrLeaderNotAvailable) {
-				retry = true
+				leaderless = true
 			}
 		}
+		if leaderless && !errors.Is(topic.Err, ErrLeaderNotAvailable) {
+			retry = append(retry, topic.Name)
+		}
 
 		var partitionCache [maxPartitionIndex][]int32
 		partitionCache[allPartitions] = client.setPartitionCache(topic.Name, allPartitions)
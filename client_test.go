@@ -3,6 +3,7 @@ package sarama
 import (
 	"errors"
 	"io"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -353,6 +354,110 @@ func TestClientGetOffset(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientGetOffsetsForTimestamp(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader1 := NewMockBroker(t, 2)
+	defer leader1.Close()
+	leader2 := NewMockBroker(t, 3)
+	defer leader2.Close()
+
+	timestamp := time.Unix(1600000000, 0)
+	millis := timestamp.UnixNano() / int64(time.Millisecond)
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(leader1.Addr(), leader1.BrokerID()).
+			SetBroker(leader2.Addr(), leader2.BrokerID()).
+			SetLeader("foo", 0, leader1.BrokerID()).
+			SetLeader("foo", 1, leader1.BrokerID()).
+			SetLeader("foo", 2, leader2.BrokerID()),
+	})
+	leader1.SetHandlerByMap(map[string]MockResponse{
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("foo", 0, millis, 10).
+			SetOffset("foo", 1, millis, 20),
+	})
+	leader2.SetHandlerByMap(map[string]MockResponse{
+		// no message was produced on partition 2 since the timestamp
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("foo", 2, millis, -1).
+			SetOffset("foo", 2, OffsetNewest, 30),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_1_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	offsets, err := client.GetOffsetsForTimestamp("foo", timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int32]int64{0: 10, 1: 20, 2: 30}
+	if !reflect.DeepEqual(offsets, expected) {
+		t.Errorf("expected offsets %v, got %v", expected, offsets)
+	}
+
+	// a single request per leader, plus one to resolve the newest offset of partition 2
+	if requests := len(leader1.History()); requests != 1 {
+		t.Errorf("expected a single request to leader1, got %d", requests)
+	}
+	if requests := len(leader2.History()); requests != 2 {
+		t.Errorf("expected two requests to leader2, got %d", requests)
+	}
+}
+
+func TestClientTopicID(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	id1 := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	id2 := Uuid{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20}
+	metadataResponse := NewMockMetadataResponse(t).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetLeader("foo", 0, seedBroker.BrokerID()).
+		SetTopicID("foo", id1)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest":    metadataResponse,
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if id, err := client.TopicID("foo"); err != nil || id != id1 {
+		t.Errorf("expected topic ID %s, got %s (%v)", id1, id, err)
+	}
+
+	// the topic is deleted and created again
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("foo", 0, seedBroker.BrokerID()).
+			SetTopicID("foo", id2),
+	})
+	if err := client.RefreshMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := client.TopicID("foo"); err != nil || id != id2 {
+		t.Errorf("expected topic ID %s after the topic was recreated, got %s (%v)", id2, id, err)
+	}
+
+	if _, err := client.TopicID("bar"); !errors.Is(err, ErrUnknownTopicOrPartition) {
+		t.Errorf("expected ErrUnknownTopicOrPartition for an unknown topic, got %v", err)
+	}
+}
+
 func TestClientReceivingUnknownTopicWithBackoffFunc(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 
@@ -426,6 +531,339 @@ func TestClientReceivingUnknownTopic(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestClientCoalescesMetadataRefreshes(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("foo", 0, seedBroker.BrokerID()).
+			SetLeader("bar", 0, seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Metadata.FullRefreshBackoff = time.Minute
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	metadataRequests := func() (requests []*MetadataRequest) {
+		for _, rr := range seedBroker.History() {
+			if req, ok := rr.Request.(*MetadataRequest); ok {
+				requests = append(requests, req)
+			}
+		}
+		return requests
+	}
+	initial := len(metadataRequests())
+
+	seedBroker.SetLatency(200 * time.Millisecond)
+	var wg sync.WaitGroup
+	for _, topics := range [][]string{{"foo", "bar"}, {"bar", "foo"}, {"foo", "bar"}} {
+		topics := topics
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.RefreshMetadata(topics...); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if requests := len(metadataRequests()) - initial; requests != 1 {
+		t.Errorf("Expected the concurrent refreshes of the same topics to share 1 request, got %d", requests)
+	}
+
+	// all the topics were refreshed when creating the client
+	seedBroker.SetLatency(0)
+	if err := client.RefreshMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if requests := len(metadataRequests()) - initial; requests != 1 {
+		t.Errorf("Expected the refresh of all topics to be skipped within the backoff, got %d requests", requests)
+	}
+}
+
+func TestClientRetriesOnlyLeaderlessTopics(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 5)
+	defer leader.Close()
+
+	metadataResponse1 := new(MetadataResponse)
+	metadataResponse1.AddBroker(leader.Addr(), leader.BrokerID())
+	seedBroker.Returns(metadataResponse1)
+
+	config := NewTestConfig()
+	config.Metadata.Retry.Max = 1
+	config.Metadata.Retry.Backoff = 0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	leaderless := new(MetadataResponse)
+	leaderless.AddBroker(leader.Addr(), leader.BrokerID())
+	leaderless.AddTopicPartition("foo", 0, -1, nil, nil, nil, ErrLeaderNotAvailable)
+	leaderless.AddTopicPartition("bar", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	leader.Returns(leaderless)
+	elected := new(MetadataResponse)
+	elected.AddBroker(leader.Addr(), leader.BrokerID())
+	elected.AddTopicPartition("foo", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	leader.Returns(elected)
+
+	if err := client.RefreshMetadata("foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	history := leader.History()
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 metadata requests, got %d", len(history))
+	}
+	if topics := history[1].Request.(*MetadataRequest).Topics; !reflect.DeepEqual(topics, []string{"foo"}) {
+		t.Errorf("Expected only the leaderless topic to be refreshed again, got %v", topics)
+	}
+	if b, err := client.Leader("foo", 0); err != nil || b.ID() != leader.BrokerID() {
+		t.Errorf("Expected the elected leader of foo, got %v (%v)", b, err)
+	}
+}
+
+func TestClientSRVSeedBrokers(t *testing.T) {
+	seedBroker1 := NewMockBroker(t, 1)
+	seedBroker1.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker1.Addr(), seedBroker1.BrokerID()),
+	})
+	seedBroker2 := NewMockBroker(t, 2)
+	defer seedBroker2.Close()
+	seedBroker2.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker2.Addr(), seedBroker2.BrokerID()).
+			SetLeader("foo", 0, seedBroker2.BrokerID()),
+	})
+
+	const name = "_kafka._tcp.cluster.internal"
+	resolver := &testSRVResolver{}
+	resolver.set(name, srvRecord(t, seedBroker1.Addr()))
+
+	config := NewTestConfig()
+	config.Net.SRV.Enable = true
+	config.Net.SRV.Resolver = resolver
+	config.Metadata.Retry.Max = 1
+	config.Metadata.Retry.Backoff = 0
+	client, err := NewClient([]string{name}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	// the brokers move to another address
+	seedBroker1.Close()
+	resolver.set(name, srvRecord(t, seedBroker2.Addr()))
+
+	if err := client.RefreshMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := client.Leader("foo", 0); err != nil || b.ID() != seedBroker2.BrokerID() {
+		t.Errorf("Expected the leader of foo at the re-resolved broker, got %v (%v)", b, err)
+	}
+
+	if _, err := NewClient([]string{"_kafka._tcp.missing.internal"}, config); err == nil {
+		t.Error("Expected an error creating a client with an unresolvable SRV name")
+	}
+}
+
+func TestClientNegotiatedAPIVersions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.MaxAPIVersions = map[int16]int16{1: 11}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if _, err := client.NegotiatedAPIVersions(2); !errors.Is(err, ErrBrokerNotFound) {
+		t.Errorf("Expected ErrBrokerNotFound for an unknown broker, got %v", err)
+	}
+
+	var versions map[int16]ApiVersionsResponseKey
+	for deadline := time.Now().Add(time.Second); versions == nil && time.Now().Before(deadline); {
+		if versions, err = client.NegotiatedAPIVersions(seedBroker.BrokerID()); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if fetch := versions[1]; fetch.MinVersion != 7 || fetch.MaxVersion != 11 {
+		t.Errorf("Expected Fetch v7 to v11 to be negotiated, got %+v", fetch)
+	}
+}
+
+func TestClientBrokerSelector(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	rackA := NewMockBroker(t, 2)
+	defer rackA.Close()
+	rackB := NewMockBroker(t, 3)
+	defer rackB.Close()
+
+	racks := []string{"a", "b"}
+	metadata := NewMockWrapper(&MetadataResponse{
+		Version: 1,
+		Brokers: []*Broker{
+			{id: rackA.BrokerID(), addr: rackA.Addr(), rack: &racks[0]},
+			{id: rackB.BrokerID(), addr: rackB.Addr(), rack: &racks[1]},
+		},
+	})
+	for _, broker := range []*MockBroker{seedBroker, rackA, rackB} {
+		broker.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadata})
+	}
+
+	config := NewTestConfig()
+	config.Version = V0_10_0_0
+	config.RackID = "b"
+	config.BrokerSelector = NewRackAwareBrokerSelector(config.RackID, nil)
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if broker := client.LeastLoadedBroker(); broker == nil || broker.ID() != rackB.BrokerID() {
+		t.Errorf("Expected the broker of rack b to be selected, got %v", broker)
+	}
+	if err := client.RefreshMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rackB.History()) == 0 || len(rackA.History()) != 0 {
+		t.Errorf("Expected the metadata to be refreshed from the broker of rack b, got %d requests to rack a and %d to rack b",
+			len(rackA.History()), len(rackB.History()))
+	}
+}
+
+func TestClientSkipsBrokersWithOpenCircuit(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	broker2 := NewMockBroker(t, 2)
+	defer broker2.Close()
+	broker3 := NewMockBroker(t, 3)
+	defer broker3.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(broker2.Addr(), broker2.BrokerID())
+	metadataResponse.AddBroker(broker3.Addr(), broker3.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	config := NewTestConfig()
+	config.Net.CircuitBreaker.Enable = true
+	config.Net.CircuitBreaker.Failures = 1
+	config.Net.CircuitBreaker.Backoff = time.Minute
+	c, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+	client := c.(*client)
+	// only use the registered brokers
+	client.seedBrokers = nil
+
+	tripped, err := client.Broker(broker2.BrokerID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tripped.circuit.configure(config)
+	tripped.circuit.record(ErrNotConnected)
+
+	for i := 0; i < 10; i++ {
+		if b := client.LeastLoadedBroker(); b == nil || b.ID() != broker3.BrokerID() {
+			t.Fatalf("Expected the broker with a closed circuit, got %v", b)
+		}
+		if b := client.anyBroker(); b == nil || b.ID() != broker3.BrokerID() {
+			t.Fatalf("Expected the broker with a closed circuit, got %v", b)
+		}
+	}
+
+	// with every circuit open, the broker whose circuit closes the soonest is used
+	last, err := client.Broker(broker3.BrokerID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	last.circuit.configure(config)
+	last.circuit.record(ErrNotConnected)
+	if b := client.LeastLoadedBroker(); b == nil || b.ID() != broker2.BrokerID() {
+		t.Fatalf("Expected the broker whose circuit closes first, got %v", b)
+	}
+	if b := client.anyBroker(); b == nil || b.ID() != broker2.BrokerID() {
+		t.Fatalf("Expected the broker whose circuit closes first, got %v", b)
+	}
+}
+
+func TestClientReapsIdleBrokers(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 5)
+	defer leader.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(leader.Addr(), leader.BrokerID()).
+			SetLeader("foo", 0, leader.BrokerID()),
+	})
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("foo", 0, OffsetNewest, 42),
+	})
+
+	config := NewTestConfig()
+	config.Metadata.RefreshFrequency = 0
+	config.Net.MaxIdle = 100 * time.Millisecond
+	config.Net.MaxIdlePerBroker = map[int32]time.Duration{leader.BrokerID(): 300 * time.Millisecond}
+	c, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+	client := c.(*client)
+
+	// the response to the request keeps the connection to the leader busy past its MaxIdle
+	leader.SetLatency(450 * time.Millisecond)
+	if offset, err := client.GetOffset("foo", 0, OffsetNewest); err != nil || offset != 42 {
+		t.Fatalf("Expected offset 42, got %d (%v)", offset, err)
+	}
+	leader.SetLatency(0)
+
+	seed := client.seedBrokers[0]
+	broker, err := client.Leader("foo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connected, _ := seed.Connected(); connected {
+		t.Error("Expected the connection to the idle seed broker to be closed")
+	}
+	if connected, _ := broker.Connected(); !connected {
+		t.Error("Expected the connection to the leader used until now to be open")
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if connected, _ := broker.Connected(); connected {
+		t.Error("Expected the connection to the idle leader to be closed")
+	}
+	// the connections are opened again when needed
+	if offset, err := client.GetOffset("foo", 0, OffsetNewest); err != nil || offset != 42 {
+		t.Fatalf("Expected offset 42 once the leader is reconnected, got %d (%v)", offset, err)
+	}
+}
+
 func TestClientReceivingPartialMetadata(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 5)
@@ -832,15 +1270,18 @@ func TestClientUpdateMetadataErrorAndRetry(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// concurrent refreshes are coalesced, so the number of requests depends on the timing
+	var failedMetadataResponse MetadataResponse
+	failedMetadataResponse.AddBroker(seedBroker.Addr(), 1)
+	failedMetadataResponse.AddTopic("new_topic", ErrUnknownTopicOrPartition)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockWrapper(&failedMetadataResponse),
+	})
 	waitGroup := sync.WaitGroup{}
 	waitGroup.Add(10)
 	for i := 0; i < 10; i++ {
 		go func() {
 			defer waitGroup.Done()
-			var failedMetadataResponse MetadataResponse
-			failedMetadataResponse.AddBroker(seedBroker.Addr(), 1)
-			failedMetadataResponse.AddTopic("new_topic", ErrUnknownTopicOrPartition)
-			seedBroker.Returns(&failedMetadataResponse)
 			err := client.RefreshMetadata()
 			if err == nil {
 				t.Error("should return error")
@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"math/big"
 	"net"
 	"testing"
@@ -236,3 +237,88 @@ func TestSetServerName(t *testing.T) {
 		t.Fatal("Expected empty ServerName as the broker addr is missing the port")
 	}
 }
+
+func TestTLSSessionResumptionAndVerifyHooks(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "host"},
+		Issuer:                pkix.Name{CommonName: "host"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		SerialNumber:          big.NewInt(0),
+		NotAfter:              time.Now().Add(1 * time.Hour),
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := NewMockBrokerListener(t, 1, listener)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	var resumed []bool
+	conf := NewTestConfig()
+	conf.Net.TLS.Enable = true
+	conf.Net.TLS.Config = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	conf.Net.TLS.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 || verifiedChains[0][0].Subject.CommonName != "host" {
+			return errors.New("unexpected broker identity")
+		}
+		return nil
+	}
+	conf.Net.TLS.VerifyConnection = func(state tls.ConnectionState) error {
+		resumed = append(resumed, state.DidResume)
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		broker := NewBroker(mb.Addr())
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		safeClose(t, broker)
+	}
+	if len(resumed) != 2 || resumed[0] || !resumed[1] {
+		t.Errorf("Expected a full handshake then a resumed session, got resumed %v", resumed)
+	}
+
+	// the hook rejecting the broker fails the connection
+	conf.Net.TLS.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		return errors.New("untrusted broker")
+	}
+	conf.Net.TLS.SessionCache = nil
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err == nil {
+		t.Error("Expected the connection to fail when VerifyPeerCertificate fails")
+	}
+	_ = broker.Close()
+}
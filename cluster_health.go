@@ -0,0 +1,109 @@
+package sarama
+
+import "sort"
+
+// ClusterHealthReport is the health of a cluster, as returned by ClusterAdmin.HealthReport.
+type ClusterHealthReport struct {
+	// ControllerID is the ID of the controller according to the metadata, or -1 if there is
+	// none.
+	ControllerID int32
+	// ControllerReachable is true if the controller answered, otherwise ControllerErr is the
+	// error of the request to it and the metadata is the one of another broker.
+	ControllerReachable bool
+	ControllerErr       error
+	// Brokers are the IDs of the brokers in the metadata, sorted.
+	Brokers []int32
+	// MissingBrokers are the IDs of the brokers partitions are assigned to but missing from the
+	// metadata, such as brokers down, sorted.
+	MissingBrokers []int32
+	// UnderReplicatedPartitions is the number of partitions with fewer in-sync replicas than
+	// replicas, OfflinePartitions the number of partitions without leader and
+	// UnderMinIsrPartitions the number of partitions with fewer in-sync replicas than the
+	// min.insync.replicas of their topic, which reject the records produced with acks=all.
+	UnderReplicatedPartitions int
+	OfflinePartitions         int
+	UnderMinIsrPartitions     int
+	// Partitions are the partitions that are under-replicated, offline or under their min ISR,
+	// sorted by topic and partition.
+	Partitions []*PartitionHealth
+}
+
+// PartitionHealth is the health of a partition reported by ClusterAdmin.HealthReport.
+type PartitionHealth struct {
+	Topic     string
+	Partition int32
+	// Leader is the ID of the leader of the partition, or -1 if it is offline.
+	Leader   int32
+	Replicas []int32
+	Isr      []int32
+	// MinIsr is the min.insync.replicas of the topic, or 0 if it is unknown.
+	MinIsr          int
+	UnderReplicated bool
+	Offline         bool
+	UnderMinIsr     bool
+}
+
+// Healthy returns true if the controller is reachable, no broker is missing and all the
+// partitions are fully replicated.
+func (r *ClusterHealthReport) Healthy() bool {
+	return r.ControllerReachable && len(r.MissingBrokers) == 0 && len(r.Partitions) == 0
+}
+
+// newClusterHealthReport checks the partitions of the metadata, minIsr being the
+// min.insync.replicas of the topics, by topic.
+func newClusterHealthReport(metadata *MetadataResponse, minIsr map[string]int) *ClusterHealthReport {
+	report := &ClusterHealthReport{ControllerID: metadata.ControllerID}
+
+	known := make(map[int32]bool, len(metadata.Brokers))
+	for _, b := range metadata.Brokers {
+		known[b.ID()] = true
+		report.Brokers = append(report.Brokers, b.ID())
+	}
+	missing := make(map[int32]bool)
+
+	for _, topic := range metadata.Topics {
+		for _, partition := range topic.Partitions {
+			for _, id := range partition.Replicas {
+				if !known[id] {
+					missing[id] = true
+				}
+			}
+			health := &PartitionHealth{
+				Topic:           topic.Name,
+				Partition:       partition.ID,
+				Leader:          partition.Leader,
+				Replicas:        partition.Replicas,
+				Isr:             partition.Isr,
+				MinIsr:          minIsr[topic.Name],
+				UnderReplicated: len(partition.Isr) < len(partition.Replicas),
+				Offline:         partition.Leader < 0,
+			}
+			health.UnderMinIsr = len(partition.Isr) < health.MinIsr
+			if health.UnderReplicated {
+				report.UnderReplicatedPartitions++
+			}
+			if health.Offline {
+				report.OfflinePartitions++
+			}
+			if health.UnderMinIsr {
+				report.UnderMinIsrPartitions++
+			}
+			if health.UnderReplicated || health.Offline || health.UnderMinIsr {
+				report.Partitions = append(report.Partitions, health)
+			}
+		}
+	}
+
+	for id := range missing {
+		report.MissingBrokers = append(report.MissingBrokers, id)
+	}
+	sort.Slice(report.Brokers, func(i, j int) bool { return report.Brokers[i] < report.Brokers[j] })
+	sort.Slice(report.MissingBrokers, func(i, j int) bool { return report.MissingBrokers[i] < report.MissingBrokers[j] })
+	sort.Slice(report.Partitions, func(i, j int) bool {
+		if report.Partitions[i].Topic != report.Partitions[j].Topic {
+			return report.Partitions[i].Topic < report.Partitions[j].Topic
+		}
+		return report.Partitions[i].Partition < report.Partitions[j].Partition
+	})
+	return report
+}
@@ -0,0 +1,48 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewClusterHealthReport(t *testing.T) {
+	metadata := &MetadataResponse{ControllerID: 1}
+	metadata.AddBroker("localhost:9091", 1)
+	metadata.AddBroker("localhost:9092", 2)
+	metadata.AddTopicPartition("healthy", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, ErrNoError)
+	metadata.AddTopicPartition("my_topic", 1, 1, []int32{1, 2, 3}, []int32{1, 2}, nil, ErrNoError)
+	metadata.AddTopicPartition("my_topic", 0, 2, []int32{2, 3, 1}, []int32{2}, nil, ErrNoError)
+	metadata.AddTopicPartition("offline", 0, -1, []int32{3}, nil, nil, ErrLeaderNotAvailable)
+
+	report := newClusterHealthReport(metadata, map[string]int{"healthy": 2, "my_topic": 2})
+	if report.Healthy() {
+		t.Error("Expected an unhealthy cluster")
+	}
+	if !reflect.DeepEqual(report.Brokers, []int32{1, 2}) || !reflect.DeepEqual(report.MissingBrokers, []int32{3}) {
+		t.Errorf("Unexpected brokers %v and missing brokers %v", report.Brokers, report.MissingBrokers)
+	}
+	if report.UnderReplicatedPartitions != 3 || report.OfflinePartitions != 1 || report.UnderMinIsrPartitions != 1 {
+		t.Errorf("Unexpected counts %d under-replicated, %d offline, %d under min ISR",
+			report.UnderReplicatedPartitions, report.OfflinePartitions, report.UnderMinIsrPartitions)
+	}
+	expected := []*PartitionHealth{
+		{Topic: "my_topic", Partition: 0, Leader: 2, Replicas: []int32{2, 3, 1}, Isr: []int32{2}, MinIsr: 2, UnderReplicated: true, UnderMinIsr: true},
+		{Topic: "my_topic", Partition: 1, Leader: 1, Replicas: []int32{1, 2, 3}, Isr: []int32{1, 2}, MinIsr: 2, UnderReplicated: true},
+		{Topic: "offline", Partition: 0, Leader: -1, Replicas: []int32{3}, UnderReplicated: true, Offline: true},
+	}
+	if !reflect.DeepEqual(report.Partitions, expected) {
+		t.Errorf("Unexpected partitions %+v", report.Partitions)
+	}
+}
+
+func TestNewClusterHealthReportHealthy(t *testing.T) {
+	metadata := &MetadataResponse{ControllerID: 1}
+	metadata.AddBroker("localhost:9091", 1)
+	metadata.AddTopicPartition("my_topic", 0, 1, []int32{1}, []int32{1}, nil, ErrNoError)
+
+	report := newClusterHealthReport(metadata, map[string]int{"my_topic": 1})
+	report.ControllerReachable = true
+	if !report.Healthy() {
+		t.Errorf("Expected a healthy cluster, got %+v", report)
+	}
+}
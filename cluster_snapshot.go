@@ -0,0 +1,228 @@
+package sarama
+
+import (
+	"sort"
+	"time"
+)
+
+// ClusterSnapshot is the state of a cluster at a point in time, as taken by
+// ClusterAdmin.ClusterSnapshot, to be compared with another snapshot by Diff, for example
+// before and after a maintenance.
+type ClusterSnapshot struct {
+	// Time is the time the snapshot was taken at.
+	Time         time.Time
+	ControllerID int32
+	// Brokers are the brokers of the cluster, by broker ID.
+	Brokers map[int32]BrokerSnapshot
+	// Topics are the topics of the cluster, by name.
+	Topics map[string]*TopicSnapshot
+}
+
+// BrokerSnapshot is a broker in a ClusterSnapshot.
+type BrokerSnapshot struct {
+	Addr string
+	Rack string
+}
+
+// TopicSnapshot is a topic in a ClusterSnapshot.
+type TopicSnapshot struct {
+	// Partitions are the partitions of the topic, by partition ID.
+	Partitions map[int32]*PartitionSnapshot
+	// Configs are the configs set on the topic, sensitive ones excluded, by name, or nil with
+	// brokers older than 0.11.0.0.
+	Configs map[string]string
+}
+
+// PartitionSnapshot is a partition in a ClusterSnapshot.
+type PartitionSnapshot struct {
+	// Leader is the ID of the leader of the partition, or -1 if it is offline.
+	Leader          int32
+	Replicas        []int32
+	Isr             []int32
+	OfflineReplicas []int32
+}
+
+// ClusterSnapshotDiff are the changes from a ClusterSnapshot to a later one, as returned by
+// ClusterSnapshot.Diff. The brokers, topics and changes are sorted.
+type ClusterSnapshotDiff struct {
+	// OldControllerID and NewControllerID are the controllers of the snapshots.
+	OldControllerID int32
+	NewControllerID int32
+	AddedBrokers    []int32
+	RemovedBrokers  []int32
+	// ChangedBrokers are the brokers whose address or rack changed.
+	ChangedBrokers []int32
+	AddedTopics    []string
+	RemovedTopics  []string
+	// Partitions are the partitions of the topics of both snapshots that were added, removed
+	// or whose leader, replicas, ISR or offline replicas changed.
+	Partitions []*PartitionChange
+	// Configs are the configs of the topics of both snapshots that were set, unset or changed.
+	Configs []*TopicConfigChange
+}
+
+// PartitionChange is the change of a partition between two snapshots.
+type PartitionChange struct {
+	Topic     string
+	Partition int32
+	// Old is nil if the partition was added and New nil if it was removed.
+	Old *PartitionSnapshot
+	New *PartitionSnapshot
+}
+
+// LeaderChanged returns true if the leader of the partition changed.
+func (c *PartitionChange) LeaderChanged() bool {
+	return c.Old != nil && c.New != nil && c.Old.Leader != c.New.Leader
+}
+
+// TopicConfigChange is the change of a config of a topic between two snapshots.
+type TopicConfigChange struct {
+	Topic string
+	Name  string
+	// Old is nil if the config was set and New nil if it was unset.
+	Old *string
+	New *string
+}
+
+// Empty returns true if the snapshots are the same, controller aside.
+func (d *ClusterSnapshotDiff) Empty() bool {
+	return len(d.AddedBrokers) == 0 && len(d.RemovedBrokers) == 0 && len(d.ChangedBrokers) == 0 &&
+		len(d.AddedTopics) == 0 && len(d.RemovedTopics) == 0 &&
+		len(d.Partitions) == 0 && len(d.Configs) == 0
+}
+
+// Diff returns the changes from the snapshot to the other one, taken later.
+func (s *ClusterSnapshot) Diff(other *ClusterSnapshot) *ClusterSnapshotDiff {
+	diff := &ClusterSnapshotDiff{OldControllerID: s.ControllerID, NewControllerID: other.ControllerID}
+
+	for id, broker := range s.Brokers {
+		if newBroker, ok := other.Brokers[id]; !ok {
+			diff.RemovedBrokers = append(diff.RemovedBrokers, id)
+		} else if newBroker != broker {
+			diff.ChangedBrokers = append(diff.ChangedBrokers, id)
+		}
+	}
+	for id := range other.Brokers {
+		if _, ok := s.Brokers[id]; !ok {
+			diff.AddedBrokers = append(diff.AddedBrokers, id)
+		}
+	}
+
+	for name, topic := range s.Topics {
+		newTopic, ok := other.Topics[name]
+		if !ok {
+			diff.RemovedTopics = append(diff.RemovedTopics, name)
+			continue
+		}
+		diff.diffPartitions(name, topic.Partitions, newTopic.Partitions)
+		diff.diffConfigs(name, topic.Configs, newTopic.Configs)
+	}
+	for name := range other.Topics {
+		if _, ok := s.Topics[name]; !ok {
+			diff.AddedTopics = append(diff.AddedTopics, name)
+		}
+	}
+
+	for _, ids := range [][]int32{diff.AddedBrokers, diff.RemovedBrokers, diff.ChangedBrokers} {
+		ids := ids
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	sort.Strings(diff.AddedTopics)
+	sort.Strings(diff.RemovedTopics)
+	sort.Slice(diff.Partitions, func(i, j int) bool {
+		if diff.Partitions[i].Topic != diff.Partitions[j].Topic {
+			return diff.Partitions[i].Topic < diff.Partitions[j].Topic
+		}
+		return diff.Partitions[i].Partition < diff.Partitions[j].Partition
+	})
+	sort.Slice(diff.Configs, func(i, j int) bool {
+		if diff.Configs[i].Topic != diff.Configs[j].Topic {
+			return diff.Configs[i].Topic < diff.Configs[j].Topic
+		}
+		return diff.Configs[i].Name < diff.Configs[j].Name
+	})
+	return diff
+}
+
+func (d *ClusterSnapshotDiff) diffPartitions(topic string, old, new map[int32]*PartitionSnapshot) {
+	for id, partition := range old {
+		newPartition := new[id]
+		if newPartition == nil || !partition.equal(newPartition) {
+			d.Partitions = append(d.Partitions, &PartitionChange{Topic: topic, Partition: id, Old: partition, New: newPartition})
+		}
+	}
+	for id, partition := range new {
+		if old[id] == nil {
+			d.Partitions = append(d.Partitions, &PartitionChange{Topic: topic, Partition: id, New: partition})
+		}
+	}
+}
+
+func (d *ClusterSnapshotDiff) diffConfigs(topic string, old, new map[string]string) {
+	for name, value := range old {
+		value := value
+		change := &TopicConfigChange{Topic: topic, Name: name, Old: &value}
+		if newValue, ok := new[name]; ok {
+			if newValue == value {
+				continue
+			}
+			change.New = &newValue
+		}
+		d.Configs = append(d.Configs, change)
+	}
+	for name, value := range new {
+		if _, ok := old[name]; !ok {
+			value := value
+			d.Configs = append(d.Configs, &TopicConfigChange{Topic: topic, Name: name, New: &value})
+		}
+	}
+}
+
+func (p *PartitionSnapshot) equal(other *PartitionSnapshot) bool {
+	return p.Leader == other.Leader && int32sEqual(p.Replicas, other.Replicas) &&
+		int32sEqual(p.Isr, other.Isr) && int32sEqual(p.OfflineReplicas, other.OfflineReplicas)
+}
+
+// int32sEqual returns true if the slices have the same elements in the same order, nil and
+// empty slices being equal.
+func int32sEqual(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newClusterSnapshot takes a snapshot of the metadata and of the configs of the topics, by
+// topic.
+func newClusterSnapshot(metadata *MetadataResponse, configs map[string]map[string]string) *ClusterSnapshot {
+	snapshot := &ClusterSnapshot{
+		Time:         time.Now(),
+		ControllerID: metadata.ControllerID,
+		Brokers:      make(map[int32]BrokerSnapshot, len(metadata.Brokers)),
+		Topics:       make(map[string]*TopicSnapshot, len(metadata.Topics)),
+	}
+	for _, b := range metadata.Brokers {
+		snapshot.Brokers[b.ID()] = BrokerSnapshot{Addr: b.Addr(), Rack: b.Rack()}
+	}
+	for _, topic := range metadata.Topics {
+		t := &TopicSnapshot{
+			Partitions: make(map[int32]*PartitionSnapshot, len(topic.Partitions)),
+			Configs:    configs[topic.Name],
+		}
+		for _, partition := range topic.Partitions {
+			t.Partitions[partition.ID] = &PartitionSnapshot{
+				Leader:          partition.Leader,
+				Replicas:        partition.Replicas,
+				Isr:             partition.Isr,
+				OfflineReplicas: partition.OfflineReplicas,
+			}
+		}
+		snapshot.Topics[topic.Name] = t
+	}
+	return snapshot
+}
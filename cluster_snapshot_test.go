@@ -0,0 +1,78 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testClusterSnapshot() *ClusterSnapshot {
+	return &ClusterSnapshot{
+		ControllerID: 1,
+		Brokers: map[int32]BrokerSnapshot{
+			1: {Addr: "localhost:9091", Rack: "a"},
+			2: {Addr: "localhost:9092", Rack: "b"},
+			3: {Addr: "localhost:9093", Rack: "c"},
+		},
+		Topics: map[string]*TopicSnapshot{
+			"my_topic": {
+				Partitions: map[int32]*PartitionSnapshot{
+					0: {Leader: 1, Replicas: []int32{1, 2}, Isr: []int32{1, 2}},
+					1: {Leader: 2, Replicas: []int32{2, 3}, Isr: []int32{2, 3}},
+				},
+				Configs: map[string]string{"retention.ms": "5000", "cleanup.policy": "compact"},
+			},
+			"old_topic": {Partitions: map[int32]*PartitionSnapshot{0: {Leader: 3, Replicas: []int32{3}, Isr: []int32{3}}}},
+		},
+	}
+}
+
+func TestClusterSnapshotDiff(t *testing.T) {
+	before := testClusterSnapshot()
+	if diff := before.Diff(testClusterSnapshot()); !diff.Empty() {
+		t.Errorf("Expected no change, got %+v", diff)
+	}
+
+	after := testClusterSnapshot()
+	after.ControllerID = 2
+	delete(after.Brokers, 3)
+	after.Brokers[2] = BrokerSnapshot{Addr: "localhost:9092", Rack: "c"}
+	after.Brokers[4] = BrokerSnapshot{Addr: "localhost:9094"}
+	delete(after.Topics, "old_topic")
+	after.Topics["new_topic"] = &TopicSnapshot{}
+	myTopic := after.Topics["my_topic"]
+	myTopic.Partitions[1] = &PartitionSnapshot{Leader: 2, Replicas: []int32{2, 3}, Isr: []int32{2}, OfflineReplicas: []int32{3}}
+	myTopic.Partitions[2] = &PartitionSnapshot{Leader: 1, Replicas: []int32{1, 2}, Isr: []int32{1, 2}}
+	myTopic.Configs = map[string]string{"retention.ms": "6000", "min.insync.replicas": "2"}
+
+	diff := before.Diff(after)
+	if diff.Empty() || diff.OldControllerID != 1 || diff.NewControllerID != 2 {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+	if !reflect.DeepEqual(diff.AddedBrokers, []int32{4}) || !reflect.DeepEqual(diff.RemovedBrokers, []int32{3}) ||
+		!reflect.DeepEqual(diff.ChangedBrokers, []int32{2}) {
+		t.Errorf("Unexpected brokers added %v, removed %v and changed %v", diff.AddedBrokers, diff.RemovedBrokers, diff.ChangedBrokers)
+	}
+	if !reflect.DeepEqual(diff.AddedTopics, []string{"new_topic"}) || !reflect.DeepEqual(diff.RemovedTopics, []string{"old_topic"}) {
+		t.Errorf("Unexpected topics added %v and removed %v", diff.AddedTopics, diff.RemovedTopics)
+	}
+
+	if len(diff.Partitions) != 2 {
+		t.Fatalf("Expected 2 partition changes, got %d", len(diff.Partitions))
+	}
+	if c := diff.Partitions[0]; c.Partition != 1 || c.Old == nil || c.New == nil || c.LeaderChanged() {
+		t.Errorf("Expected the ISR change of partition 1, got %+v", c)
+	}
+	if c := diff.Partitions[1]; c.Partition != 2 || c.Old != nil || c.New == nil {
+		t.Errorf("Expected partition 2 to be added, got %+v", c)
+	}
+
+	cleanup, minIsr, retention, oldRetention := "compact", "2", "6000", "5000"
+	expected := []*TopicConfigChange{
+		{Topic: "my_topic", Name: "cleanup.policy", Old: &cleanup},
+		{Topic: "my_topic", Name: "min.insync.replicas", New: &minIsr},
+		{Topic: "my_topic", Name: "retention.ms", Old: &oldRetention, New: &retention},
+	}
+	if !reflect.DeepEqual(diff.Configs, expected) {
+		t.Errorf("Unexpected config changes %+v", diff.Configs)
+	}
+}
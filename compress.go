@@ -6,189 +6,184 @@ import (
 	"fmt"
 	"sync"
 
-	snappy "github.com/eapache/go-xerial-snappy"
+	"github.com/golang/snappy"
 	"github.com/pierrec/lz4/v4"
 )
 
 var (
-	lz4WriterPool = sync.Pool{
+	// compressionBufferPool holds the scratch buffers data is compressed and decompressed into
+	// before being copied into a right-sized slice, so that each call allocates once instead of
+	// growing a buffer
+	compressionBufferPool = sync.Pool{
 		New: func() interface{} {
-			return lz4.NewWriter(nil)
+			return new([]byte)
 		},
 	}
 
-	gzipWriterPool = sync.Pool{
-		New: func() interface{} {
-			return gzip.NewWriter(nil)
-		},
-	}
-	gzipWriterPoolForCompressionLevel1 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 1)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel2 = sync.Pool{
+	// lz4WriterPools holds a pool of lz4 writers for each non-default compression level
+	lz4WriterPools sync.Map
+
+	lz4WriterPool = sync.Pool{
 		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 2)
-			if err != nil {
-				panic(err)
-			}
-			return gz
+			return lz4.NewWriter(nil)
 		},
 	}
-	gzipWriterPoolForCompressionLevel3 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 3)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+
+	// gzipWriterPools holds a pool of gzip writers for each compression level
+	gzipWriterPools sync.Map
+)
+
+func acquireCompressionBuffer() *[]byte {
+	return compressionBufferPool.Get().(*[]byte)
+}
+
+// releaseCompressionBuffer returns a copy of out, the output written to the buffer, and puts the
+// buffer back in the pool, keeping the scratch space if it had to grow
+func releaseCompressionBuffer(buf *[]byte, out []byte) []byte {
+	res := make([]byte, len(out))
+	copy(res, out)
+	if cap(out) > cap(*buf) {
+		*buf = out[:0]
 	}
-	gzipWriterPoolForCompressionLevel4 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 4)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+	compressionBufferPool.Put(buf)
+	return res
+}
+
+// lz4Level converts a compression level to the lz4 one, 0 being the fast mode and 1 to 9 the high
+// compression modes.
+func lz4Level(level int) (lz4.CompressionLevel, error) {
+	switch {
+	case level == 0:
+		return lz4.Fast, nil
+	case level >= 1 && level <= 9:
+		return lz4.CompressionLevel(1 << (8 + level)), nil
+	default:
+		return 0, fmt.Errorf("invalid lz4 compression level %d, must be between 0 and 9", level)
 	}
-	gzipWriterPoolForCompressionLevel5 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 5)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+}
+
+func getLZ4Writer(level int) (*lz4.Writer, *sync.Pool, error) {
+	if level == CompressionLevelDefault {
+		return lz4WriterPool.Get().(*lz4.Writer), &lz4WriterPool, nil
 	}
-	gzipWriterPoolForCompressionLevel6 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 6)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+	lz4level, err := lz4Level(level)
+	if err != nil {
+		return nil, nil, err
 	}
-	gzipWriterPoolForCompressionLevel7 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 7)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+	pool, ok := lz4WriterPools.Load(level)
+	if !ok {
+		pool, _ = lz4WriterPools.LoadOrStore(level, &sync.Pool{
+			New: func() interface{} {
+				writer := lz4.NewWriter(nil)
+				if err := writer.Apply(lz4.CompressionLevelOption(lz4level)); err != nil {
+					panic(err)
+				}
+				return writer
+			},
+		})
 	}
-	gzipWriterPoolForCompressionLevel8 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 8)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+	return pool.(*sync.Pool).Get().(*lz4.Writer), pool.(*sync.Pool), nil
+}
+
+func getGzipWriter(level int) (*gzip.Writer, *sync.Pool, error) {
+	if level == CompressionLevelDefault {
+		level = gzip.DefaultCompression
 	}
-	gzipWriterPoolForCompressionLevel9 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 9)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+	pool, ok := gzipWriterPools.Load(level)
+	if !ok {
+		// check the level once, before pooling its writers
+		writer, err := gzip.NewWriterLevel(nil, level)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool, _ = gzipWriterPools.LoadOrStore(level, &sync.Pool{
+			New: func() interface{} {
+				writer, err := gzip.NewWriterLevel(nil, level)
+				if err != nil {
+					panic(err)
+				}
+				return writer
+			},
+		})
+		return writer, pool.(*sync.Pool), nil
 	}
-)
+	return pool.(*sync.Pool).Get().(*gzip.Writer), pool.(*sync.Pool), nil
+}
 
 func compress(cc CompressionCodec, level int, data []byte) ([]byte, error) {
-	switch cc {
-	case CompressionNone:
+	if cc == CompressionNone {
 		return data, nil
-	case CompressionGZIP:
-		var (
-			err    error
-			buf    bytes.Buffer
-			writer *gzip.Writer
-		)
-
-		switch level {
-		case CompressionLevelDefault:
-			writer = gzipWriterPool.Get().(*gzip.Writer)
-			defer gzipWriterPool.Put(writer)
-			writer.Reset(&buf)
-		case 1:
-			writer = gzipWriterPoolForCompressionLevel1.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel1.Put(writer)
-			writer.Reset(&buf)
-		case 2:
-			writer = gzipWriterPoolForCompressionLevel2.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel2.Put(writer)
-			writer.Reset(&buf)
-		case 3:
-			writer = gzipWriterPoolForCompressionLevel3.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel3.Put(writer)
-			writer.Reset(&buf)
-		case 4:
-			writer = gzipWriterPoolForCompressionLevel4.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel4.Put(writer)
-			writer.Reset(&buf)
-		case 5:
-			writer = gzipWriterPoolForCompressionLevel5.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel5.Put(writer)
-			writer.Reset(&buf)
-		case 6:
-			writer = gzipWriterPoolForCompressionLevel6.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel6.Put(writer)
-			writer.Reset(&buf)
-		case 7:
-			writer = gzipWriterPoolForCompressionLevel7.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel7.Put(writer)
-			writer.Reset(&buf)
-		case 8:
-			writer = gzipWriterPoolForCompressionLevel8.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel8.Put(writer)
-			writer.Reset(&buf)
-		case 9:
-			writer = gzipWriterPoolForCompressionLevel9.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel9.Put(writer)
-			writer.Reset(&buf)
-		default:
-			writer, err = gzip.NewWriterLevel(&buf, level)
-			if err != nil {
-				return nil, err
-			}
-		}
-		if _, err := writer.Write(data); err != nil {
-			return nil, err
-		}
-		if err := writer.Close(); err != nil {
-			return nil, err
-		}
-		return buf.Bytes(), nil
-	case CompressionSnappy:
-		return snappy.Encode(data), nil
-	case CompressionLZ4:
-		writer := lz4WriterPool.Get().(*lz4.Writer)
-		defer lz4WriterPool.Put(writer)
-
-		var buf bytes.Buffer
-		writer.Reset(&buf)
-
-		if _, err := writer.Write(data); err != nil {
-			return nil, err
-		}
-		if err := writer.Close(); err != nil {
-			return nil, err
-		}
-		return buf.Bytes(), nil
-	case CompressionZSTD:
-		return zstdCompress(ZstdEncoderParams{level}, nil, data)
-	default:
+	}
+	compressor := compressorFor(cc)
+	if compressor == nil {
 		return nil, PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", cc)}
 	}
+
+	buf := acquireCompressionBuffer()
+	out, err := compressor.Compress((*buf)[:0], data, level)
+	if err != nil {
+		compressionBufferPool.Put(buf)
+		return nil, err
+	}
+	return releaseCompressionBuffer(buf, out), nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(dst, data []byte, level int) ([]byte, error) {
+	writer, pool, err := getGzipWriter(level)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Put(writer)
+
+	buf := bytes.NewBuffer(dst)
+	writer.Reset(buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Compress(dst, data []byte, _ int) ([]byte, error) {
+	// encode the unframed block right after dst, growing it to the maximum length first
+	n := len(dst)
+	if max := n + snappy.MaxEncodedLen(len(data)); cap(dst) < max {
+		dst = append(dst[:cap(dst)], make([]byte, max-cap(dst))...)
+	} else {
+		dst = dst[:max]
+	}
+	out := snappy.Encode(dst[n:], data)
+	return dst[:n+len(out)], nil
+}
+
+type lz4Compressor struct{}
+
+func (lz4Compressor) Compress(dst, data []byte, level int) ([]byte, error) {
+	writer, pool, err := getLZ4Writer(level)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Put(writer)
+
+	buf := bytes.NewBuffer(dst)
+	writer.Reset(buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) Compress(dst, data []byte, level int) ([]byte, error) {
+	return zstdCompress(ZstdEncoderParams{level}, dst, data)
 }
@@ -0,0 +1,178 @@
+package sarama
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func compressionTestData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte((i / 256) + (i * 257))
+	}
+	return data
+}
+
+func TestCompressLevels(t *testing.T) {
+	data := compressionTestData(64 * 1024)
+	for _, tc := range []struct {
+		codec  CompressionCodec
+		levels []int
+	}{
+		{CompressionGZIP, []int{CompressionLevelDefault, 0, 1, 9}},
+		{CompressionSnappy, []int{CompressionLevelDefault}},
+		{CompressionLZ4, []int{CompressionLevelDefault, 0, 1, 9}},
+		{CompressionZSTD, []int{CompressionLevelDefault, 1, 3, 22}},
+	} {
+		for _, level := range tc.levels {
+			compressed, err := compress(tc.codec, level, data)
+			if err != nil {
+				t.Fatalf("%s level %d: %v", tc.codec, level, err)
+			}
+			decompressed, err := decompress(tc.codec, compressed)
+			if err != nil {
+				t.Fatalf("%s level %d: %v", tc.codec, level, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Errorf("%s level %d: data did not survive the round trip", tc.codec, level)
+			}
+		}
+	}
+
+	if _, err := compress(CompressionLZ4, 10, data); err == nil {
+		t.Error("expected an error for lz4 level 10")
+	}
+	if _, err := compress(CompressionGZIP, 10, data); err == nil {
+		t.Error("expected an error for gzip level 10")
+	}
+}
+
+func TestCompressDoesNotShareBuffers(t *testing.T) {
+	for _, codec := range []CompressionCodec{CompressionGZIP, CompressionSnappy, CompressionLZ4, CompressionZSTD} {
+		first, err := compress(codec, CompressionLevelDefault, compressionTestData(1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := append([]byte(nil), first...)
+		second, err := compress(codec, CompressionLevelDefault, compressionTestData(4096))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, saved) {
+			t.Errorf("%s: compressed data was overwritten by a later call", codec)
+		}
+
+		decompressed, err := decompress(codec, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decompress(codec, second); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decompressed, compressionTestData(1024)) {
+			t.Errorf("%s: decompressed data was overwritten by a later call", codec)
+		}
+	}
+}
+
+// countingCompressor counts the calls to the compressor it wraps.
+type countingCompressor struct {
+	Compressor
+	compressed, decompressed int
+}
+
+func (c *countingCompressor) Compress(dst, data []byte, level int) ([]byte, error) {
+	c.compressed++
+	return c.Compressor.Compress(dst, data, level)
+}
+
+func (c *countingCompressor) Decompress(dst, data []byte) ([]byte, error) {
+	c.decompressed++
+	return c.Compressor.Decompress(dst, data)
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	counting := &countingCompressor{Compressor: compressorFor(CompressionSnappy)}
+	if err := RegisterCompressor(CompressionSnappy, counting); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := RegisterCompressor(CompressionSnappy, nil); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	batch := &RecordBatch{
+		Version: 2,
+		Codec:   CompressionSnappy,
+		Records: []*Record{{Value: compressionTestData(1024)}},
+	}
+	buf, err := encode(batch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(RecordBatch)
+	if err := decode(buf, decoded, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Records) != 1 || !bytes.Equal(decoded.Records[0].Value, compressionTestData(1024)) {
+		t.Error("the records did not survive the round trip")
+	}
+	if counting.compressed != 1 || counting.decompressed != 1 {
+		t.Errorf("Expected the registered compressor to be used once each way, got %d and %d", counting.compressed, counting.decompressed)
+	}
+
+	if err := RegisterCompressor(CompressionSnappy, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := compressorFor(CompressionSnappy).(snappyCompressor); !ok {
+		t.Error("Expected a nil compressor to restore the built-in one")
+	}
+	for _, cc := range []CompressionCodec{CompressionNone, CompressionZSTD + 1} {
+		if err := RegisterCompressor(cc, counting); err == nil {
+			t.Errorf("Expected an error registering a compressor for the codec %d", cc)
+		}
+	}
+}
+
+func TestProducerCompressionLevel(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.CompressionLevel = 5
+	if level := config.producerCompressionLevel(); level != 5 {
+		t.Errorf("expected CompressionLevel to be used, got %d", level)
+	}
+	config.Producer.CompressionLevels.GZIP = 9
+	if level := config.producerCompressionLevel(); level != 9 {
+		t.Errorf("expected CompressionLevels.GZIP to take precedence, got %d", level)
+	}
+
+	config.Producer.Compression = CompressionLZ4
+	if level := config.producerCompressionLevel(); level != CompressionLevelDefault {
+		t.Errorf("expected CompressionLevel to be ignored for lz4, got %d", level)
+	}
+	config.Producer.CompressionLevels.LZ4 = 12
+	if err := config.Validate(); err == nil {
+		t.Error("expected an invalid lz4 level to fail validation")
+	}
+}
+
+// BenchmarkCompress reports the allocations of compressing a 1MiB batch with each codec, which
+// pooling the writers and scratch buffers keeps down to the compressed output itself.
+func BenchmarkCompress(b *testing.B) {
+	data := compressionTestData(1024 * 1024)
+	for _, codec := range []CompressionCodec{CompressionGZIP, CompressionSnappy, CompressionLZ4, CompressionZSTD} {
+		for _, level := range []int{CompressionLevelDefault, 1} {
+			b.Run(fmt.Sprintf("%s/level=%d", codec, level), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					if _, err := compress(codec, level, data); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package sarama
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Compressor compresses and decompresses the messages and record batches of a compression
+// codec. The built-in implementations can be replaced with RegisterCompressor, e.g. by a cgo
+// zstd or a hardware-accelerated snappy one.
+//
+// Compressors are called concurrently. dst is a pooled scratch buffer: the result is copied
+// before the buffer is reused, so it must be dst with the output appended and not a slice of
+// data.
+type Compressor interface {
+	// Compress appends data compressed at the level to dst, level being
+	// CompressionLevelDefault for the default level of the codec.
+	Compress(dst, data []byte, level int) ([]byte, error)
+	// Decompress appends the decompressed data to dst.
+	Decompress(dst, data []byte) ([]byte, error)
+}
+
+var (
+	builtinCompressors = [...]Compressor{
+		CompressionGZIP:   gzipCompressor{},
+		CompressionSnappy: snappyCompressor{},
+		CompressionLZ4:    lz4Compressor{},
+		CompressionZSTD:   zstdCompressor{},
+	}
+
+	// compressors holds the registeredCompressor of each codec, if any
+	compressors [len(builtinCompressors)]atomic.Value
+)
+
+// registeredCompressor wraps the compressors as atomic.Value requires values of a single type
+type registeredCompressor struct {
+	Compressor
+}
+
+// RegisterCompressor replaces the compressor of the compression codec, which must be gzip,
+// snappy, lz4 or zstd, for all the producers and consumers. A nil compressor restores the
+// built-in one. Compressors are usually registered before creating any client, records
+// compressed with a codec being decompressed with whatever compressor is registered then.
+func RegisterCompressor(cc CompressionCodec, c Compressor) error {
+	if cc <= CompressionNone || int(cc) >= len(builtinCompressors) {
+		return ConfigurationError(fmt.Sprintf("cannot register a compressor for the compression codec %d", cc))
+	}
+	if c == nil {
+		c = builtinCompressors[cc]
+	}
+	compressors[cc].Store(registeredCompressor{c})
+	return nil
+}
+
+// compressorFor returns the compressor of the codec, nil if it is not known.
+func compressorFor(cc CompressionCodec) Compressor {
+	if cc <= CompressionNone || int(cc) >= len(builtinCompressors) {
+		return nil
+	}
+	if c, ok := compressors[cc].Load().(registeredCompressor); ok {
+		return c.Compressor
+	}
+	return builtinCompressors[cc]
+}
@@ -3,10 +3,12 @@ package sarama
 import (
 	"compress/gzip"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
 	"regexp"
+	"syscall"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -25,8 +27,16 @@ type Config struct {
 			// The total number of times to retry sending (retriable) admin requests (default 5).
 			// Similar to the `retries` setting of the JVM AdminClientConfig.
 			Max int
-			// Backoff time between retries of a failed request (default 100ms)
+			// Backoff time between retries of a failed request (default 100ms),
+			// doubling with each retry up to MaxBackoff.
 			Backoff time.Duration
+			// The maximum backoff time between retries (default 1s). Set to 0 to
+			// keep the backoff time constant.
+			MaxBackoff time.Duration
+			// Called to compute backoff time dynamically. Useful for implementing
+			// more sophisticated backoff strategies. This takes precedence over
+			// `Backoff` and `MaxBackoff` if set.
+			BackoffFunc func(retries, maxRetries int) time.Duration
 		}
 		// The maximum duration the administrative Kafka client will wait for ClusterAdmin operations,
 		// including topics, brokers, configurations and ACLs (defaults to 3 seconds).
@@ -42,6 +52,28 @@ type Config struct {
 		// https://kafka.apache.org/protocol#protocol_network
 		// https://kafka.apache.org/28/documentation.html#producerconfigs_max.in.flight.requests.per.connection
 		MaxOpenRequests int
+		// MaxOpenRequestsPerAPIKey limits how many of the MaxOpenRequests a
+		// connection can have in flight for an API key, such as {0: 2} so that
+		// produce requests (API key 0) leave room for the others (defaults to
+		// nil, meaning MaxOpenRequests applies to all the keys).
+		MaxOpenRequestsPerAPIKey map[int16]int
+
+		// How many connections to open to each broker (default 1). Requests are
+		// spread over the connections in turn, so that a slow request or the
+		// MaxOpenRequests limit of one connection does not hold back the others.
+		// As with MaxOpenRequests, message ordering is not guaranteed with more
+		// than one connection, which the idempotent producer does not allow.
+		ConnectionsPerBroker int
+
+		// How long the connections to a broker can stay unused, without any
+		// request in flight, before the client closes them (defaults to 0,
+		// meaning they are kept open). They are opened again when the broker is
+		// needed, so that long-lived clients do not hold sockets to every broker
+		// they ever used.
+		MaxIdle time.Duration
+		// MaxIdlePerBroker overrides MaxIdle for the brokers with the IDs, 0
+		// keeping the connections to a broker open (defaults to nil).
+		MaxIdlePerBroker map[int32]time.Duration
 
 		// All three of the below configurations are similar to the
 		// `socket.timeout.ms` setting in JVM kafka. All of them default
@@ -50,6 +82,14 @@ type Config struct {
 		ReadTimeout  time.Duration // How long to wait for a response.
 		WriteTimeout time.Duration // How long to wait for a transmit.
 
+		// ReadTimeoutPerAPIKey overrides ReadTimeout for the responses to the
+		// requests of an API key, since a Metadata request (API key 3) should
+		// answer well within a second while a DeleteRecords request (API key
+		// 21) may take minutes, such as {3: 500 * time.Millisecond, 21: 10 *
+		// time.Minute} (defaults to nil, meaning ReadTimeout applies to all the
+		// keys).
+		ReadTimeoutPerAPIKey map[int16]time.Duration
+
 		TLS struct {
 			// Whether or not to use TLS when connecting to the broker
 			// (defaults to false).
@@ -57,6 +97,26 @@ type Config struct {
 			// The TLS configuration to use for secure connections if
 			// enabled (defaults to nil).
 			Config *tls.Config
+			// The cache of the TLS sessions to resume when reconnecting to
+			// the brokers, saving a full handshake, unless Config has its
+			// own ClientSessionCache (defaults to a cache of 64 sessions
+			// shared by the clients of the Config, set it to nil to always
+			// make full handshakes).
+			SessionCache tls.ClientSessionCache
+			// VerifyPeerCertificate and VerifyConnection, if not nil, are
+			// called during the handshakes after those of Config, see
+			// tls.Config for their arguments. The certificates are only
+			// verified against the root CAs before if Config does not set
+			// InsecureSkipVerify, which lets these hooks implement custom
+			// trust models, such as checking the SPIFFE ID in the URI SAN
+			// of the broker certificate (defaults to nil).
+			VerifyPeerCertificate func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+			VerifyConnection      func(state tls.ConnectionState) error
+			// CertificateProvider, if not nil, provides the client
+			// certificate on each handshake instead of Config, such as a
+			// FileCertificateProvider reloading rotated certificates
+			// without recreating the client (defaults to nil).
+			CertificateProvider CertificateProvider
 		}
 
 		// SASL based authentication with broker. While there are multiple SASL authentication methods
@@ -109,6 +169,29 @@ type Config struct {
 		// If zero or positive, keep-alives are enabled.
 		// If negative, keep-alives are disabled.
 		KeepAlive time.Duration
+		// KeepAliveInterval is the interval between the keep-alive probes which are not
+		// acknowledged (defaults to 0, meaning KeepAlive), and KeepAliveCount how many of them
+		// are sent before the connection is closed (defaults to 0, meaning the OS default, 9 on
+		// Linux). Together they bound the time to detect a dead broker to KeepAlive +
+		// KeepAliveInterval * KeepAliveCount. They are only supported on Linux.
+		KeepAliveInterval time.Duration
+		KeepAliveCount    int
+		// UserTimeout is how long sent data may stay unacknowledged before the connection is
+		// closed (TCP_USER_TIMEOUT, defaults to 0, meaning the OS default of about 15 minutes
+		// on Linux). It is only supported on Linux.
+		UserTimeout time.Duration
+		// ReadBufferSize and WriteBufferSize are the sizes of the receive and send buffers of
+		// the sockets (SO_RCVBUF and SO_SNDBUF, defaults to 0, meaning the OS default, which
+		// autotunes them on Linux), for example to fill high-bandwidth links with a high
+		// latency. They are set before connecting, so that the TCP window scale fits them, and
+		// capped by the net.core.rmem_max and net.core.wmem_max sysctls. They are only
+		// supported on Linux.
+		ReadBufferSize  int
+		WriteBufferSize int
+		// Control, if set, is called with the socket of each connection to a broker before
+		// connecting it, after the socket options of the config are set, as net.Dialer.Control,
+		// for example to set platform-specific options. An error fails the connection.
+		Control func(network, address string, conn syscall.RawConn) error
 
 		// LocalAddr is the local address to use when dialing an
 		// address. The address must be of a compatible type for the
@@ -116,12 +199,98 @@ type Config struct {
 		// If nil, a local address is automatically chosen.
 		LocalAddr net.Addr
 
+		// FallbackDelay is how long to wait for the connection to an address of a
+		// broker hostname resolving to several ones before also trying the next
+		// address, the first connection to succeed being kept (defaults to
+		// 300ms). Addresses of both IP families are tried alternately. If
+		// negative, the addresses are tried one after another.
+		FallbackDelay time.Duration
+
+		// AddressFamily is the IP family whose addresses are tried first when
+		// a broker hostname resolves to addresses of both families, e.g. in
+		// dual-stack Kubernetes clusters (defaults to AddressFamilyAny, trying
+		// the family of the first address returned by the resolver first). The
+		// addresses of the other family are still tried if those fail.
+		AddressFamily AddressFamily
+
 		Proxy struct {
 			// Whether or not to use proxy when connecting to the broker
 			// (defaults to false).
 			Enable bool
-			// The proxy dialer to use enabled (defaults to nil).
+			// The proxy dialer to use enabled (defaults to nil). If nil, the
+			// proxy at Addr is used.
 			Dialer proxy.Dialer
+			// Protocol is the protocol of the proxy at Addr, ProxySOCKS5 (the
+			// default) or ProxyHTTPConnect.
+			Protocol ProxyProtocol
+			// Addr is the host:port address of the proxy, used when Dialer is nil.
+			Addr string
+			// User and Password authenticate with the proxy, with the SOCKS5
+			// username/password method or HTTP basic authentication, if User is
+			// not empty.
+			User     string
+			Password string
+		}
+
+		// Interceptors to be called with every request sent to and response
+		// received from the brokers on the wire, in order (defaults to nil).
+		Interceptors []BrokerInterceptor
+
+		// Capture writes the frames sent to and received from the brokers to
+		// Writer, as JSON lines of CapturedFrame, for example to debug broker
+		// incompatibilities offline. The frames carrying credentials, such as
+		// the SaslAuthenticate ones, are redacted, and the SASL exchanges of
+		// Net.SASL.Version 0, which are not framed as requests, are not
+		// captured. It is meant for debugging, every frame being copied.
+		Capture struct {
+			// Whether or not to capture the frames (defaults to false).
+			Enable bool
+			// Writer is where the frames are written, such as an *os.File,
+			// the writes of all the brokers being serialized.
+			Writer io.Writer
+		}
+
+		// OnThrottle is called with the ID of the broker, the API key of the
+		// request and the throttle time of each response throttled by a broker
+		// enforcing quotas (defaults to nil). It is called from the goroutines
+		// handling the responses, so it must not block.
+		OnThrottle func(brokerID int32, apiKey int16, throttleTime time.Duration)
+		// Whether to wait for the throttle time of a throttled produce or fetch
+		// response before sending the next request to the broker (defaults to
+		// false). Brokers since KIP-219 send throttled responses right away and
+		// mute the connection for the throttle time, the client pacing itself
+		// avoiding requests piling up on it. The administrative requests are
+		// always paced.
+		PaceThrottledRequests bool
+
+		// CircuitBreaker stops sending requests to a broker failing repeatedly, so
+		// that one bad broker does not slow every request cycle down.
+		CircuitBreaker struct {
+			// Whether to enable the circuit breakers of the brokers (defaults to
+			// false).
+			Enable bool
+			// How many consecutive connection or request failures open the
+			// circuit of a broker (defaults to 5).
+			Failures int
+			// How long the requests to a broker whose circuit is open fail right
+			// away with ErrBrokerCircuitOpen (defaults to 10s). A request then
+			// probes the broker with an ApiVersions request, closing the circuit
+			// if it succeeds and opening it for another Backoff otherwise.
+			Backoff time.Duration
+		}
+
+		// SRV configures the discovery of the seed brokers with DNS SRV records.
+		SRV struct {
+			// Whether the addresses given to NewClient are SRV names (e.g.
+			// _kafka._tcp.cluster.internal) rather than host:port addresses of
+			// brokers (defaults to false). The targets of the records are the seed
+			// brokers, and the names are resolved again when none of them can be
+			// reached, so that the brokers can move to other addresses.
+			Enable bool
+			// The resolver looking up the records (defaults to net.DefaultResolver).
+			Resolver SRVResolver
+			// How long to wait for the records to be resolved (defaults to 5s).
+			Timeout time.Duration
 		}
 	}
 
@@ -158,6 +327,13 @@ type Config struct {
 		// to fail.
 		Timeout time.Duration
 
+		// The minimum time between two refreshes of the metadata of all topics,
+		// a refresh of all topics requested sooner after a successful one being
+		// skipped, as they are expensive for large clusters (defaults to 0,
+		// meaning they are never skipped). Concurrent refreshes of the same topics
+		// share the same metadata request regardless of this setting.
+		FullRefreshBackoff time.Duration
+
 		// Whether to allow auto-create topics in metadata refresh. If set to true,
 		// the broker may auto-create topics that we requested which do not already exist,
 		// if it is configured to do so (`auto.create.topics.enable` is true). Defaults to true.
@@ -180,6 +356,12 @@ type Config struct {
 		// millisecond resolution, nanoseconds will be truncated. Equivalent to
 		// the JVM producer's `request.timeout.ms` setting.
 		Timeout time.Duration
+		// The maximum number of unacknowledged batches per partition (defaults
+		// to 0, meaning only Net.MaxOpenRequests applies). Messages for a
+		// partition that has reached the limit are held back while batches for
+		// the other partitions led by the same broker keep being sent, so that
+		// a single slow partition cannot stall the whole broker.
+		MaxInFlightPerPartition int
 		// The type of compression to use on messages (defaults to no compression).
 		// Similar to `compression.codec` setting of the JVM producer.
 		Compression CompressionCodec
@@ -187,9 +369,25 @@ type Config struct {
 		// on the actual compression type used and defaults to default compression
 		// level for the codec.
 		CompressionLevel int
+		// The level of compression to use for each codec, taking precedence
+		// over CompressionLevel for the codec selected by Compression unless
+		// set to CompressionLevelDefault (the default). Setting them lets
+		// Compression be changed without having to adjust the level.
+		CompressionLevels struct {
+			// 0 (no compression) or 1 (best speed) to 9 (best compression).
+			GZIP int
+			// 0 (fast mode) or 1 to 9 (high compression modes). Unlike the
+			// other codecs, lz4 doesn't fall back to CompressionLevel.
+			LZ4 int
+			// 1 (fastest) to 22 (best compression), mapped to the nearest
+			// level supported by the encoder.
+			ZSTD int
+		}
 		// Generates partitioners for choosing the partition to send messages to
-		// (defaults to hashing the message key). Similar to the `partitioner.class`
-		// setting for the JVM producer.
+		// (defaults to hashing the message key with FNV-1a). Similar to the
+		// `partitioner.class` setting for the JVM producer. Use NewMurmur2Partitioner
+		// to send keys to the same partitions as the JVM producer, or
+		// NewCustomPartitioner with WithHashMode to select another hash.
 		Partitioner PartitionerConstructor
 		// If enabled, the producer will ensure that exactly one copy of each message is
 		// written.
@@ -266,6 +464,22 @@ type Config struct {
 			BackoffFunc func(retries, maxRetries int) time.Duration
 		}
 
+		Timestamp struct {
+			// If enabled, messages with an explicit Timestamp are checked
+			// against the `message.timestamp.type` and
+			// `message.timestamp.difference.max.ms` configuration of their
+			// topic before being batched, and fail with a *TimestampError
+			// rather than being rejected by the broker with
+			// ErrInvalidTimestamp. The topic configuration is fetched once per
+			// topic with a DescribeConfigs request. Requires Version >=
+			// V0_11_0_0 (default false).
+			Validate bool
+			// The maximum difference allowed between an explicit Timestamp and
+			// the producer's clock. Overrides the topic's
+			// `message.timestamp.difference.max.ms` when > 0 (default 0).
+			MaxSkew time.Duration
+		}
+
 		// Interceptors to be called when the producer dispatcher reads the
 		// message for the first time. Interceptors allows to intercept and
 		// possible mutate the message before they are published to Kafka
@@ -308,6 +522,8 @@ type Config struct {
 				// GroupStrategies is the priority-ordered list of client-side consumer group
 				// balancing strategies that will be offered to the coordinator. The first
 				// strategy that all group members support will be chosen by the leader.
+				// Rebalances are incremental when all the strategies are cooperative,
+				// see CooperativeBalanceStrategy.
 				// default: [ NewBalanceStrategyRange() ]
 				GroupStrategies []BalanceStrategy
 
@@ -334,7 +550,15 @@ type Config struct {
 				UserData []byte
 			}
 
-			// support KIP-345
+			// InstanceId enables static membership (KIP-345, requires Version >= V2_3_0_0): the
+			// member is identified by this ID, which must be unique within the group and stable
+			// across restarts, rather than by the member ID assigned by the coordinator. A static
+			// member doesn't leave the group on Close, and rejoining with the same InstanceId
+			// within Consumer.Group.Session.Timeout gets it its previous assignment back without
+			// a rebalance, so the session timeout should exceed the time a restart takes.
+			//
+			// If another member joins with the same InstanceId, this one gets fenced: the
+			// session ends and Consume returns ErrFencedInstancedId from then on.
 			InstanceId string
 
 			// If true, consumer offsets will be automatically reset to configured Initial value
@@ -344,6 +568,29 @@ type Config struct {
 			// dangerous to reset the offset automatically, particularly in the latter case. Defaults
 			// to true to maintain existing behavior.
 			ResetInvalidOffsets bool
+
+			// MaxProcessingInterval is the maximum time a claim can go without any message being
+			// received from its Messages() or Batches() channel while messages are waiting, e.g.
+			// because ConsumeClaim is stuck processing a message. Heartbeats are sent independently
+			// of processing, so a busy member otherwise stays in the group however long processing
+			// takes. Once the interval is exceeded, the member leaves the group, unless it is a
+			// static member, and the session ends so that other members take its partitions over,
+			// like max.poll.interval.ms in the Java client. Disabled when 0 (default).
+			MaxProcessingInterval time.Duration
+
+			// StuckClaim detects claims whose handler makes no progress, i.e. which
+			// went without any message being received from their Messages() or
+			// Batches() channel for Timeout while messages were waiting. A stuck
+			// claim is reported once per stall, with ErrClaimStuck through the Errors
+			// channel and to handlers implementing ConsumerGroupStuckClaimListener.
+			StuckClaim struct {
+				// Timeout after which a claim is stuck. Disabled when 0 (default).
+				Timeout time.Duration
+				// If enabled, the member leaves the group once a claim is stuck, as
+				// with MaxProcessingInterval, so that other members take its
+				// partitions over (default disabled).
+				Leave bool
+			}
 		}
 
 		Retry struct {
@@ -377,6 +624,22 @@ type Config struct {
 			// (no limit). Similar to the JVM's `fetch.message.max.bytes`. The
 			// global `sarama.MaxResponseSize` still applies.
 			Max int32
+			// The maximum number of message bytes fetched for a partition but not
+			// yet received from its Messages channel. Fetching from the partition
+			// is suspended while it is exceeded, so that slow consumers don't
+			// accumulate prefetched messages in memory. Defaults to 0 (no limit).
+			MaxBufferedBytesPerPartition int
+			// The maximum number of message bytes fetched but not yet received
+			// from the Messages channels of all the partitions of the consumer.
+			// Fetching is suspended while it is exceeded. Defaults to 0 (no limit).
+			MaxBufferedBytes int
+			// If enabled, compressed record batches are kept compressed once fetched,
+			// and only decompressed one at a time as their messages are delivered to
+			// the Messages or Batches channel, rather than when the fetch response is
+			// received. This reduces the memory held by partitions buffering many
+			// fetched batches for slow consumers. Legacy message sets are always
+			// decompressed when fetched. Defaults to false.
+			LazyDecompression bool
 		}
 		// The maximum amount of time the broker will wait for Consumer.Fetch.Min
 		// bytes to become available before it returns fewer than that anyways. The
@@ -411,6 +674,22 @@ type Config struct {
 			// If enabled, any errors that occurred while consuming are returned on
 			// the Errors channel (default disabled).
 			Errors bool
+
+			// If enabled, messages are returned in batches, one per fetch response,
+			// on the Batches channel of partition consumers and consumer group
+			// claims instead of one by one on their Messages channel, amortizing
+			// the cost of channel operations for consumers processing messages in
+			// bulk (default disabled). The consumer group handlers provided by this
+			// package, e.g. OrderedWorkerPool, still process the messages one by one.
+			Batches bool
+
+			// If enabled, a PartitionEOF event is returned on the EOF channel of
+			// partition consumers and consumer group claims each time they reach
+			// the end of their partition, once all the messages fetched up to it
+			// have been delivered, and so again after new messages were delivered
+			// (default disabled). The EOF channel must then be read, as the
+			// Errors channel is.
+			PartitionEOF bool
 		}
 
 		// Offsets specifies configuration for how and when to commit consumed
@@ -432,10 +711,31 @@ type Config struct {
 				Interval time.Duration
 			}
 
-			// The initial offset to use if no offset was previously committed.
-			// Should be OffsetNewest or OffsetOldest. Defaults to OffsetNewest.
+			// The initial offset to use if no offset was previously committed,
+			// or if the committed offset is out of range and
+			// Consumer.Group.ResetInvalidOffsets is enabled, or if the offset of
+			// a partition consumer is out of range and ResetOutOfRange is
+			// enabled. Should be one of OffsetNewest, OffsetOldest,
+			// OffsetTimestamp, OffsetRelative or OffsetFail. Defaults to
+			// OffsetNewest.
 			Initial int64
 
+			// If enabled, partition consumers whose offset is out of range,
+			// whether when they start or while consuming, restart from Initial
+			// instead of failing with ErrOffsetOutOfRange, unless Initial is
+			// OffsetFail. As with Consumer.Group.ResetInvalidOffsets, an offset
+			// may be out of range because a replica is behind, in which case
+			// resetting it skips or replays messages (default disabled).
+			ResetOutOfRange bool
+
+			// The time to start consuming from when Initial is OffsetTimestamp.
+			InitialTimestamp time.Time
+
+			// How far back in time to start consuming from when Initial is
+			// OffsetRelative, e.g. one hour to start from the first message
+			// produced during the last hour.
+			InitialLookback time.Duration
+
 			// The retention duration for committed offsets. If zero, disabled
 			// (in which case the `offsets.retention.minutes` option on the
 			// broker will be used).  Kafka only supports precision up to
@@ -444,10 +744,24 @@ type Config struct {
 			// (default is 0: disabled).
 			Retention time.Duration
 
+			// Store stores the committed offsets outside of Kafka, instead of
+			// committing them to the group coordinator, while the group
+			// membership and partition assignment still rely on Kafka. Defaults
+			// to nil, committing offsets to Kafka.
+			Store OffsetStore
+
 			Retry struct {
 				// The total number of times to retry failing commit
-				// requests during OffsetManager shutdown (default 3).
+				// requests during OffsetManager shutdown and asynchronous
+				// commits (default 3).
 				Max int
+				// How long to wait before retrying a failing asynchronous
+				// commit (default 100ms).
+				Backoff time.Duration
+				// Called to compute backoff time dynamically. Useful for
+				// implementing more sophisticated backoff strategies.
+				// This takes precedence over `Backoff` if set.
+				BackoffFunc func(retries, maxRetries int) time.Duration
 			}
 		}
 
@@ -455,14 +769,31 @@ type Config struct {
 		// 	- use `ReadUncommitted` (default) to consume and return all messages in message channel
 		//	- use `ReadCommitted` to hide messages that are part of an aborted transaction
 		IsolationLevel IsolationLevel
+		// TopicIsolationLevels overrides IsolationLevel for the given topics, for
+		// consumers mixing transactional and non-transactional topics. As the
+		// isolation level applies to whole fetch requests, partitions of topics
+		// of different isolation levels led by the same broker are fetched with
+		// separate requests.
+		TopicIsolationLevels map[string]IsolationLevel
 
 		// Interceptors to be called just before the record is sent to the
 		// messages channel. Interceptors allows to intercept and possible
 		// mutate the message before they are returned to the client.
 		// *ConsumerMessage modified by the first interceptor's OnConsume() is
 		// passed to the second interceptor OnConsume(), and so on in the
-		// interceptor chain.
+		// interceptor chain. Interceptors implementing ConsumerCommitInterceptor
+		// are also called after offsets are committed.
 		Interceptors []ConsumerInterceptor
+
+		// Deserializers decode the keys and values of the messages of the
+		// given topics into DecodedKey and DecodedValue, before interceptors
+		// are called. Messages which fail to be decoded are still returned,
+		// with their raw Key and Value only, and the failure is reported as a
+		// ConsumerError. Messages are decoded once, in the background, so a
+		// slow deserializer, such as a schema registry lookup, only holds up
+		// its partition, and counts against MaxProcessingTime like slow
+		// processing does.
+		Deserializers map[string]TopicDeserializers
 	}
 
 	// A user-provided string sent with every request to the brokers for logging,
@@ -472,7 +803,20 @@ type Config struct {
 	// A rack identifier for this client. This can be any string value which
 	// indicates where this client is physically located.
 	// It corresponds with the broker config 'broker.rack'
+	//
+	// With Version >= V2_3_0_0 and brokers configured with a 'replica.selector.class'
+	// (KIP-392), consumers send it on Fetch requests and follow the preferred read
+	// replica returned by the leader, typically a replica in the same rack, falling
+	// back to the leader when that replica is unavailable or lagging behind.
 	RackID string
+	// BrokerSelector, if set, picks the broker to send the requests any broker
+	// can serve to among the brokers known from the metadata whose circuit
+	// breaker is not open, such as the metadata requests, the coordinator
+	// lookups and the admin requests, for example to prefer the brokers in
+	// the same rack with NewRackAwareBrokerSelector (defaults to nil). When
+	// it is not set, or picks none, the seed brokers are used first, then
+	// any broker, or the least loaded one for Client.LeastLoadedBroker.
+	BrokerSelector BrokerSelector
 	// The number of events to buffer in internal and external channels. This
 	// permits the producer and consumer to continue processing some messages
 	// in the background while user code is working, greatly improving throughput.
@@ -483,6 +827,13 @@ type Config struct {
 	// connection. This defaults to `true` to match the official Java client
 	// and most 3rdparty ones.
 	ApiVersionsRequest bool
+	// MaxAPIVersions pins the maximum version of the requests of API keys, by
+	// key, for example to stay off a version broken on a broker: the requests
+	// built from Version with a higher version are sent with the given one
+	// instead. The requests outside of the versions the broker advertised in
+	// its ApiVersions response, see Client.NegotiatedAPIVersions, fail with an
+	// *UnsupportedAPIVersionError instead of being sent.
+	MaxAPIVersions map[int16]int16
 	// The version of Kafka that Sarama will assume it is running against.
 	// Defaults to the oldest supported stable version. Since Kafka provides
 	// backwards-compatibility, setting it to a version older than you have
@@ -504,12 +855,19 @@ func NewConfig() *Config {
 
 	c.Admin.Retry.Max = 5
 	c.Admin.Retry.Backoff = 100 * time.Millisecond
+	c.Admin.Retry.MaxBackoff = time.Second
 	c.Admin.Timeout = 3 * time.Second
 
 	c.Net.MaxOpenRequests = 5
+	c.Net.ConnectionsPerBroker = 1
 	c.Net.DialTimeout = 30 * time.Second
+	c.Net.FallbackDelay = 300 * time.Millisecond
+	c.Net.SRV.Timeout = 5 * time.Second
+	c.Net.CircuitBreaker.Failures = 5
+	c.Net.CircuitBreaker.Backoff = 10 * time.Second
 	c.Net.ReadTimeout = 30 * time.Second
 	c.Net.WriteTimeout = 30 * time.Second
+	c.Net.TLS.SessionCache = tls.NewLRUClientSessionCache(64)
 	c.Net.SASL.Handshake = true
 	c.Net.SASL.Version = SASLHandshakeV0
 
@@ -527,6 +885,9 @@ func NewConfig() *Config {
 	c.Producer.Retry.Backoff = 100 * time.Millisecond
 	c.Producer.Return.Errors = true
 	c.Producer.CompressionLevel = CompressionLevelDefault
+	c.Producer.CompressionLevels.GZIP = CompressionLevelDefault
+	c.Producer.CompressionLevels.LZ4 = CompressionLevelDefault
+	c.Producer.CompressionLevels.ZSTD = CompressionLevelDefault
 
 	c.Producer.Transaction.Timeout = 1 * time.Minute
 	c.Producer.Transaction.Retry.Max = 50
@@ -542,6 +903,7 @@ func NewConfig() *Config {
 	c.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
 	c.Consumer.Offsets.Initial = OffsetNewest
 	c.Consumer.Offsets.Retry.Max = 3
+	c.Consumer.Offsets.Retry.Backoff = 100 * time.Millisecond
 
 	c.Consumer.Group.Session.Timeout = 10 * time.Second
 	c.Consumer.Group.Heartbeat.Interval = 3 * time.Second
@@ -627,12 +989,24 @@ func (c *Config) Validate() error {
 	switch {
 	case c.Net.MaxOpenRequests <= 0:
 		return ConfigurationError("Net.MaxOpenRequests must be > 0")
+	case c.Net.ConnectionsPerBroker <= 0:
+		return ConfigurationError("Net.ConnectionsPerBroker must be > 0")
 	case c.Net.DialTimeout <= 0:
 		return ConfigurationError("Net.DialTimeout must be > 0")
 	case c.Net.ReadTimeout <= 0:
 		return ConfigurationError("Net.ReadTimeout must be > 0")
 	case c.Net.WriteTimeout <= 0:
 		return ConfigurationError("Net.WriteTimeout must be > 0")
+	case c.Net.KeepAliveInterval < 0:
+		return ConfigurationError("Net.KeepAliveInterval must be >= 0")
+	case c.Net.KeepAliveCount < 0:
+		return ConfigurationError("Net.KeepAliveCount must be >= 0")
+	case c.Net.UserTimeout < 0:
+		return ConfigurationError("Net.UserTimeout must be >= 0")
+	case c.Net.ReadBufferSize < 0:
+		return ConfigurationError("Net.ReadBufferSize must be >= 0")
+	case c.Net.WriteBufferSize < 0:
+		return ConfigurationError("Net.WriteBufferSize must be >= 0")
 	case c.Net.SASL.Enable:
 		if c.Net.SASL.Mechanism == "" {
 			c.Net.SASL.Mechanism = SASLTypePlaintext
@@ -690,10 +1064,67 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Net.MaxIdle < 0 {
+		return ConfigurationError("Net.MaxIdle must be >= 0")
+	}
+	for id, maxIdle := range c.Net.MaxIdlePerBroker {
+		if maxIdle < 0 {
+			return ConfigurationError(fmt.Sprintf("Net.MaxIdlePerBroker[%d] must be >= 0", id))
+		}
+	}
+
+	for key, timeout := range c.Net.ReadTimeoutPerAPIKey {
+		if timeout <= 0 {
+			return ConfigurationError(fmt.Sprintf("Net.ReadTimeoutPerAPIKey[%d] must be > 0", key))
+		}
+	}
+
+	for key, max := range c.Net.MaxOpenRequestsPerAPIKey {
+		if max <= 0 {
+			return ConfigurationError(fmt.Sprintf("Net.MaxOpenRequestsPerAPIKey[%d] must be > 0", key))
+		}
+	}
+
+	if c.Net.CircuitBreaker.Enable {
+		switch {
+		case c.Net.CircuitBreaker.Failures <= 0:
+			return ConfigurationError("Net.CircuitBreaker.Failures must be > 0")
+		case c.Net.CircuitBreaker.Backoff <= 0:
+			return ConfigurationError("Net.CircuitBreaker.Backoff must be > 0")
+		}
+	}
+
+	if c.Net.AddressFamily != AddressFamilyAny && c.Net.AddressFamily != AddressFamilyIPv4 && c.Net.AddressFamily != AddressFamilyIPv6 {
+		return ConfigurationError("Net.AddressFamily must be AddressFamilyAny, AddressFamilyIPv4 or AddressFamilyIPv6")
+	}
+
+	if c.Net.SRV.Enable && c.Net.SRV.Timeout <= 0 {
+		return ConfigurationError("Net.SRV.Timeout must be > 0")
+	}
+
+	if c.Net.Capture.Enable && c.Net.Capture.Writer == nil {
+		return ConfigurationError("Net.Capture.Writer must be set when Net.Capture.Enable is true")
+	}
+
+	if c.Net.Proxy.Enable && c.Net.Proxy.Dialer == nil {
+		switch {
+		case c.Net.Proxy.Addr == "":
+			return ConfigurationError("Net.Proxy.Addr must be set when Net.Proxy.Dialer is nil")
+		case c.Net.Proxy.Protocol != "" && c.Net.Proxy.Protocol != ProxySOCKS5 && c.Net.Proxy.Protocol != ProxyHTTPConnect:
+			return ConfigurationError(fmt.Sprintf("Net.Proxy.Protocol %q is invalid, it must be socks5 or http", c.Net.Proxy.Protocol))
+		case c.Net.Proxy.User == "" && c.Net.Proxy.Password != "":
+			return ConfigurationError("Net.Proxy.User must be set with Net.Proxy.Password")
+		}
+	}
+
 	// validate the Admin values
 	switch {
 	case c.Admin.Timeout <= 0:
 		return ConfigurationError("Admin.Timeout must be > 0")
+	case c.Admin.Retry.Backoff < 0:
+		return ConfigurationError("Admin.Retry.Backoff must be >= 0")
+	case c.Admin.Retry.MaxBackoff < 0:
+		return ConfigurationError("Admin.Retry.MaxBackoff must be >= 0")
 	}
 
 	// validate the Metadata values
@@ -704,6 +1135,8 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Metadata.Retry.Backoff must be >= 0")
 	case c.Metadata.RefreshFrequency < 0:
 		return ConfigurationError("Metadata.RefreshFrequency must be >= 0")
+	case c.Metadata.FullRefreshBackoff < 0:
+		return ConfigurationError("Metadata.FullRefreshBackoff must be >= 0")
 	}
 
 	// validate the Producer values
@@ -714,6 +1147,8 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.RequiredAcks must be >= -1")
 	case c.Producer.Timeout <= 0:
 		return ConfigurationError("Producer.Timeout must be > 0")
+	case c.Producer.MaxInFlightPerPartition < 0:
+		return ConfigurationError("Producer.MaxInFlightPerPartition must be >= 0")
 	case c.Producer.Partitioner == nil:
 		return ConfigurationError("Producer.Partitioner must not be nil")
 	case c.Producer.Flush.Bytes < 0:
@@ -730,16 +1165,27 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.Retry.Max must be >= 0")
 	case c.Producer.Retry.Backoff < 0:
 		return ConfigurationError("Producer.Retry.Backoff must be >= 0")
+	case c.Producer.Timestamp.MaxSkew < 0:
+		return ConfigurationError("Producer.Timestamp.MaxSkew must be >= 0")
+	}
+
+	if c.Producer.Timestamp.Validate && !c.Version.IsAtLeast(V0_11_0_0) {
+		return ConfigurationError("Producer.Timestamp.Validate requires Version >= V0_11_0_0")
 	}
 
 	if c.Producer.Compression == CompressionLZ4 && !c.Version.IsAtLeast(V0_10_0_0) {
 		return ConfigurationError("lz4 compression requires Version >= V0_10_0_0")
 	}
 
-	if c.Producer.Compression == CompressionGZIP {
-		if c.Producer.CompressionLevel != CompressionLevelDefault {
-			if _, err := gzip.NewWriterLevel(io.Discard, c.Producer.CompressionLevel); err != nil {
-				return ConfigurationError(fmt.Sprintf("gzip compression does not work with level %d: %v", c.Producer.CompressionLevel, err))
+	if level := c.producerCompressionLevel(); level != CompressionLevelDefault {
+		switch c.Producer.Compression {
+		case CompressionGZIP:
+			if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+				return ConfigurationError(fmt.Sprintf("gzip compression does not work with level %d: %v", level, err))
+			}
+		case CompressionLZ4:
+			if _, err := lz4Level(level); err != nil {
+				return ConfigurationError(fmt.Sprintf("lz4 compression does not work with level %d", level))
 			}
 		}
 	}
@@ -761,6 +1207,9 @@ func (c *Config) Validate() error {
 		if c.Net.MaxOpenRequests > 1 {
 			return ConfigurationError("Idempotent producer requires Net.MaxOpenRequests to be 1")
 		}
+		if c.Net.ConnectionsPerBroker > 1 {
+			return ConfigurationError("Idempotent producer requires Net.ConnectionsPerBroker to be 1")
+		}
 	}
 
 	if c.Producer.Transaction.ID != "" && !c.Producer.Idempotent {
@@ -775,6 +1224,10 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Fetch.Default must be > 0")
 	case c.Consumer.Fetch.Max < 0:
 		return ConfigurationError("Consumer.Fetch.Max must be >= 0")
+	case c.Consumer.Fetch.MaxBufferedBytesPerPartition < 0:
+		return ConfigurationError("Consumer.Fetch.MaxBufferedBytesPerPartition must be >= 0")
+	case c.Consumer.Fetch.MaxBufferedBytes < 0:
+		return ConfigurationError("Consumer.Fetch.MaxBufferedBytes must be >= 0")
 	case c.Consumer.MaxWaitTime < 1*time.Millisecond:
 		return ConfigurationError("Consumer.MaxWaitTime must be >= 1ms")
 	case c.Consumer.MaxProcessingTime <= 0:
@@ -783,10 +1236,18 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Retry.Backoff must be >= 0")
 	case c.Consumer.Offsets.AutoCommit.Interval <= 0:
 		return ConfigurationError("Consumer.Offsets.AutoCommit.Interval must be > 0")
-	case c.Consumer.Offsets.Initial != OffsetOldest && c.Consumer.Offsets.Initial != OffsetNewest:
-		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest or OffsetNewest")
+	case c.Consumer.Offsets.Initial != OffsetOldest && c.Consumer.Offsets.Initial != OffsetNewest &&
+		c.Consumer.Offsets.Initial != OffsetTimestamp && c.Consumer.Offsets.Initial != OffsetRelative &&
+		c.Consumer.Offsets.Initial != OffsetFail:
+		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest, OffsetNewest, OffsetTimestamp, OffsetRelative or OffsetFail")
+	case c.Consumer.Offsets.Initial == OffsetTimestamp && c.Consumer.Offsets.InitialTimestamp.IsZero():
+		return ConfigurationError("Consumer.Offsets.InitialTimestamp must be set when Consumer.Offsets.Initial is OffsetTimestamp")
+	case c.Consumer.Offsets.Initial == OffsetRelative && c.Consumer.Offsets.InitialLookback <= 0:
+		return ConfigurationError("Consumer.Offsets.InitialLookback must be > 0 when Consumer.Offsets.Initial is OffsetRelative")
 	case c.Consumer.Offsets.Retry.Max < 0:
 		return ConfigurationError("Consumer.Offsets.Retry.Max must be >= 0")
+	case c.Consumer.Offsets.Retry.Backoff < 0:
+		return ConfigurationError("Consumer.Offsets.Retry.Backoff must be >= 0")
 	case c.Consumer.IsolationLevel != ReadUncommitted && c.Consumer.IsolationLevel != ReadCommitted:
 		return ConfigurationError("Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted")
 	}
@@ -804,6 +1265,14 @@ func (c *Config) Validate() error {
 	if c.Consumer.IsolationLevel == ReadCommitted && !c.Version.IsAtLeast(V0_11_0_0) {
 		return ConfigurationError("ReadCommitted requires Version >= V0_11_0_0")
 	}
+	for topic, level := range c.Consumer.TopicIsolationLevels {
+		switch {
+		case level != ReadUncommitted && level != ReadCommitted:
+			return ConfigurationError(fmt.Sprintf("Consumer.TopicIsolationLevels[%s] must be ReadUncommitted or ReadCommitted", topic))
+		case level == ReadCommitted && !c.Version.IsAtLeast(V0_11_0_0):
+			return ConfigurationError("ReadCommitted requires Version >= V0_11_0_0")
+		}
+	}
 
 	// validate the Consumer Group values
 	switch {
@@ -815,6 +1284,12 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be < Consumer.Group.Session.Timeout")
 	case c.Consumer.Group.Rebalance.Strategy == nil && len(c.Consumer.Group.Rebalance.GroupStrategies) == 0:
 		return ConfigurationError("Consumer.Group.Rebalance.GroupStrategies or Consumer.Group.Rebalance.Strategy must not be empty")
+	case c.Consumer.Group.MaxProcessingInterval < 0:
+		return ConfigurationError("Consumer.Group.MaxProcessingInterval must be >= 0")
+	case c.Consumer.Group.StuckClaim.Timeout < 0:
+		return ConfigurationError("Consumer.Group.StuckClaim.Timeout must be >= 0")
+	case c.Consumer.Group.StuckClaim.Leave && c.Consumer.Group.StuckClaim.Timeout == 0:
+		return ConfigurationError("Consumer.Group.StuckClaim.Leave requires Consumer.Group.StuckClaim.Timeout to be set")
 	case c.Consumer.Group.Rebalance.Timeout <= time.Millisecond:
 		return ConfigurationError("Consumer.Group.Rebalance.Timeout must be >= 1ms")
 	case c.Consumer.Group.Rebalance.Retry.Max < 0:
@@ -838,6 +1313,12 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for key, max := range c.MaxAPIVersions {
+		if max < 0 {
+			return ConfigurationError(fmt.Sprintf("MaxAPIVersions[%d] must be >= 0", key))
+		}
+	}
+
 	// validate misc shared values
 	switch {
 	case c.ChannelBufferSize < 0:
@@ -850,16 +1331,39 @@ func (c *Config) Validate() error {
 }
 
 func (c *Config) getDialer() proxy.Dialer {
-	if c.Net.Proxy.Enable {
+	netDialer := &net.Dialer{
+		Timeout:       c.Net.DialTimeout,
+		KeepAlive:     c.Net.KeepAlive,
+		LocalAddr:     c.Net.LocalAddr,
+		FallbackDelay: c.Net.FallbackDelay,
+	}
+	setTCPOptions(netDialer, c)
+	if control := c.Net.Control; control != nil {
+		if setOptions := netDialer.Control; setOptions != nil {
+			netDialer.Control = func(network, address string, conn syscall.RawConn) error {
+				if err := setOptions(network, address, conn); err != nil {
+					return err
+				}
+				return control(network, address, conn)
+			}
+		} else {
+			netDialer.Control = control
+		}
+	}
+
+	var dialer proxy.Dialer = netDialer
+	if c.Net.FallbackDelay >= 0 || c.Net.AddressFamily != AddressFamilyAny {
+		dialer = newFallbackDialer(netDialer, c.Net.FallbackDelay, c.Net.AddressFamily)
+	}
+	if !c.Net.Proxy.Enable {
+		return dialer
+	}
+	if c.Net.Proxy.Dialer != nil {
 		Logger.Printf("using proxy %s", c.Net.Proxy.Dialer)
 		return c.Net.Proxy.Dialer
-	} else {
-		return &net.Dialer{
-			Timeout:   c.Net.DialTimeout,
-			KeepAlive: c.Net.KeepAlive,
-			LocalAddr: c.Net.LocalAddr,
-		}
 	}
+	Logger.Printf("using proxy %s", c.Net.Proxy.Addr)
+	return newProxyDialer(c, dialer)
 }
 
 const MAX_GROUP_INSTANCE_ID_LENGTH = 249
@@ -881,3 +1385,31 @@ func validateGroupInstanceId(id string) error {
 	}
 	return nil
 }
+
+// isolationLevel returns the isolation level to consume topic with, taking the level set for
+// the topic in Consumer.TopicIsolationLevels over Consumer.IsolationLevel.
+func (c *Config) isolationLevel(topic string) IsolationLevel {
+	if level, ok := c.Consumer.TopicIsolationLevels[topic]; ok {
+		return level
+	}
+	return c.Consumer.IsolationLevel
+}
+
+// producerCompressionLevel returns the level to compress produced messages with, taking the level
+// set for the codec in Producer.CompressionLevels over Producer.CompressionLevel.
+func (c *Config) producerCompressionLevel() int {
+	level := CompressionLevelDefault
+	switch c.Producer.Compression {
+	case CompressionGZIP:
+		level = c.Producer.CompressionLevels.GZIP
+	case CompressionLZ4:
+		// CompressionLevel predates lz4 levels and has always been ignored for lz4
+		return c.Producer.CompressionLevels.LZ4
+	case CompressionZSTD:
+		level = c.Producer.CompressionLevels.ZSTD
+	}
+	if level == CompressionLevelDefault {
+		return c.Producer.CompressionLevel
+	}
+	return level
+}
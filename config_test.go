@@ -2,9 +2,12 @@ package sarama
 
 import (
 	"errors"
+	"net"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/rcrowley/go-metrics"
 )
@@ -58,6 +61,132 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"Net.MaxOpenRequests must be > 0",
 		},
+		{
+			"ConnectionsPerBroker",
+			func(cfg *Config) {
+				cfg.Net.ConnectionsPerBroker = 0
+			},
+			"Net.ConnectionsPerBroker must be > 0",
+		},
+		{
+			"ReadTimeoutPerAPIKey",
+			func(cfg *Config) {
+				cfg.Net.ReadTimeoutPerAPIKey = map[int16]time.Duration{21: -1}
+			},
+			"Net.ReadTimeoutPerAPIKey[21] must be > 0",
+		},
+		{
+			"CircuitBreakerFailures",
+			func(cfg *Config) {
+				cfg.Net.CircuitBreaker.Enable = true
+				cfg.Net.CircuitBreaker.Failures = 0
+			},
+			"Net.CircuitBreaker.Failures must be > 0",
+		},
+		{
+			"CircuitBreakerBackoff",
+			func(cfg *Config) {
+				cfg.Net.CircuitBreaker.Enable = true
+				cfg.Net.CircuitBreaker.Backoff = 0
+			},
+			"Net.CircuitBreaker.Backoff must be > 0",
+		},
+		{
+			"MaxIdle",
+			func(cfg *Config) {
+				cfg.Net.MaxIdle = -1
+			},
+			"Net.MaxIdle must be >= 0",
+		},
+		{
+			"MaxIdlePerBroker",
+			func(cfg *Config) {
+				cfg.Net.MaxIdlePerBroker = map[int32]time.Duration{3: -1}
+			},
+			"Net.MaxIdlePerBroker[3] must be >= 0",
+		},
+		{
+			"AddressFamily",
+			func(cfg *Config) {
+				cfg.Net.AddressFamily = 3
+			},
+			"Net.AddressFamily must be AddressFamilyAny, AddressFamilyIPv4 or AddressFamilyIPv6",
+		},
+		{
+			"MaxAPIVersions",
+			func(cfg *Config) {
+				cfg.MaxAPIVersions = map[int16]int16{3: -1}
+			},
+			"MaxAPIVersions[3] must be >= 0",
+		},
+		{
+			"CaptureWriter",
+			func(cfg *Config) {
+				cfg.Net.Capture.Enable = true
+			},
+			"Net.Capture.Writer must be set when Net.Capture.Enable is true",
+		},
+		{
+			"SRVTimeout",
+			func(cfg *Config) {
+				cfg.Net.SRV.Enable = true
+				cfg.Net.SRV.Timeout = 0
+			},
+			"Net.SRV.Timeout must be > 0",
+		},
+		{
+			"MaxOpenRequestsPerAPIKey",
+			func(cfg *Config) {
+				cfg.Net.MaxOpenRequestsPerAPIKey = map[int16]int{0: 0}
+			},
+			"Net.MaxOpenRequestsPerAPIKey[0] must be > 0",
+		},
+		{
+			"KeepAliveCount",
+			func(cfg *Config) {
+				cfg.Net.KeepAliveCount = -1
+			},
+			"Net.KeepAliveCount must be >= 0",
+		},
+		{
+			"ReadBufferSize",
+			func(cfg *Config) {
+				cfg.Net.ReadBufferSize = -1
+			},
+			"Net.ReadBufferSize must be >= 0",
+		},
+		{
+			"WriteBufferSize",
+			func(cfg *Config) {
+				cfg.Net.WriteBufferSize = -1
+			},
+			"Net.WriteBufferSize must be >= 0",
+		},
+		{
+			"Proxy.Addr",
+			func(cfg *Config) {
+				cfg.Net.Proxy.Enable = true
+			},
+			"Net.Proxy.Addr must be set when Net.Proxy.Dialer is nil",
+		},
+		{
+			"Proxy.Protocol",
+			func(cfg *Config) {
+				cfg.Net.Proxy.Enable = true
+				cfg.Net.Proxy.Addr = "proxy:1080"
+				cfg.Net.Proxy.Protocol = "socks4"
+			},
+			`Net.Proxy.Protocol "socks4" is invalid, it must be socks5 or http`,
+		},
+		{
+			"Proxy.User",
+			func(cfg *Config) {
+				cfg.Net.Proxy.Enable = true
+				cfg.Net.Proxy.Addr = "proxy:1080"
+				cfg.Net.Proxy.Password = "secret"
+			},
+			"Net.Proxy.User must be set with Net.Proxy.Password",
+		},
 		{
 			"DialTimeout",
 			func(cfg *Config) {
@@ -262,6 +391,13 @@ func TestMetadataConfigValidates(t *testing.T) {
 			},
 			"Metadata.RefreshFrequency must be >= 0",
 		},
+		{
+			"FullRefreshBackoff",
+			func(cfg *Config) {
+				cfg.Metadata.FullRefreshBackoff = -1
+			},
+			"Metadata.FullRefreshBackoff must be >= 0",
+		},
 	}
 
 	for i, test := range tests {
@@ -328,6 +464,20 @@ func TestProducerConfigValidates(t *testing.T) {
 			},
 			"Producer.Timeout must be > 0",
 		},
+		{
+			"MaxInFlightPerPartition",
+			func(cfg *Config) {
+				cfg.Producer.MaxInFlightPerPartition = -1
+			},
+			"Producer.MaxInFlightPerPartition must be >= 0",
+		},
+		{
+			"Timestamp.MaxSkew",
+			func(cfg *Config) {
+				cfg.Producer.Timestamp.MaxSkew = -1
+			},
+			"Producer.Timestamp.MaxSkew must be >= 0",
+		},
 		{
 			"Partitioner",
 			func(cfg *Config) {
@@ -419,6 +569,17 @@ func TestProducerConfigValidates(t *testing.T) {
 			},
 			"Idempotent producer requires Net.MaxOpenRequests to be 1",
 		},
+		{
+			"Idempotent with Net.ConnectionsPerBroker",
+			func(cfg *Config) {
+				cfg.Version = V0_11_0_0
+				cfg.Producer.Idempotent = true
+				cfg.Producer.RequiredAcks = WaitForAll
+				cfg.Net.MaxOpenRequests = 1
+				cfg.Net.ConnectionsPerBroker = 2
+			},
+			"Idempotent producer requires Net.ConnectionsPerBroker to be 1",
+		},
 	}
 
 	for i, test := range tests {
@@ -454,6 +615,27 @@ func TestConsumerConfigValidates(t *testing.T) {
 			},
 			"Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted",
 		},
+		{
+			"Incorrect initial offset",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.Initial = 42
+			},
+			"Consumer.Offsets.Initial must be OffsetOldest, OffsetNewest, OffsetTimestamp, OffsetRelative or OffsetFail",
+		},
+		{
+			"Initial offset timestamp",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.Initial = OffsetTimestamp
+			},
+			"Consumer.Offsets.InitialTimestamp must be set when Consumer.Offsets.Initial is OffsetTimestamp",
+		},
+		{
+			"Initial offset lookback",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.Initial = OffsetRelative
+			},
+			"Consumer.Offsets.InitialLookback must be > 0 when Consumer.Offsets.Initial is OffsetRelative",
+		},
 	}
 
 	for i, test := range tests {
@@ -570,3 +752,34 @@ func ExampleConfig_metrics() {
 	// gauge sarama.m2
 	//   value:               2
 }
+
+func TestNetControl(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var controlled []string
+	conf := NewTestConfig()
+	conf.Net.Control = func(network, address string, conn syscall.RawConn) error {
+		controlled = append(controlled, address)
+		return nil
+	}
+	conn, err := conf.getDialer().Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if len(controlled) != 1 || controlled[0] != listener.Addr().String() {
+		t.Errorf("Expected the socket connected to %s to be controlled, got %v", listener.Addr(), controlled)
+	}
+
+	controlErr := errors.New("control failed")
+	conf.Net.Control = func(network, address string, conn syscall.RawConn) error {
+		return controlErr
+	}
+	if _, err := conf.getDialer().Dial("tcp", listener.Addr().String()); !errors.Is(err, controlErr) {
+		t.Errorf("Expected the error of Control to fail the connection, got %v", err)
+	}
+}
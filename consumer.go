@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -21,6 +22,27 @@ type ConsumerMessage struct {
 	Topic      string
 	Partition  int32
 	Offset     int64
+
+	// SkippedAborted lists the records of aborted transactions which were skipped
+	// since the previous message of the partition, only set with the ReadCommitted
+	// isolation level. Gaps in the offsets of consumed messages which aren't
+	// covered by these ranges are due to control records or log compaction.
+	SkippedAborted []AbortedRange
+
+	// DecodedKey and DecodedValue are the key and value decoded by the
+	// deserializers configured for the topic in Consumer.Deserializers, they are
+	// nil when there is no deserializer or for nil keys and values.
+	DecodedKey, DecodedValue interface{}
+}
+
+// AbortedRange is a range of offsets holding records of an aborted transaction,
+// skipped by a consumer using the ReadCommitted isolation level.
+type AbortedRange struct {
+	ProducerID  int64
+	FirstOffset int64
+	LastOffset  int64
+	// Records is the number of records skipped within the range.
+	Records int
 }
 
 // ConsumerError is what is provided to the user when an error occurs.
@@ -48,6 +70,16 @@ func (ce ConsumerErrors) Error() string {
 	return fmt.Sprintf("kafka: %d errors while consuming", len(ce))
 }
 
+// PartitionEOF is returned by partition consumers when they reach the end of their partition,
+// if Consumer.Return.PartitionEOF is enabled.
+type PartitionEOF struct {
+	Topic     string
+	Partition int32
+	// Offset is the end of the partition which was reached, i.e. the offset of the next
+	// message to be produced to it.
+	Offset int64
+}
+
 // Consumer manages PartitionConsumers which process Kafka messages from brokers. You MUST call Close()
 // on a consumer to avoid leaks, it will not be garbage-collected automatically when it passes out of
 // scope.
@@ -63,10 +95,27 @@ type Consumer interface {
 
 	// ConsumePartition creates a PartitionConsumer on the given topic/partition with
 	// the given offset. It will return an error if this Consumer is already consuming
-	// on the given topic/partition. Offset can be a literal offset, or OffsetNewest
-	// or OffsetOldest
+	// on the given topic/partition. Offset can be a literal offset, or OffsetNewest,
+	// OffsetOldest, OffsetTimestamp or OffsetRelative, the latter two being resolved
+	// using Consumer.Offsets.InitialTimestamp and Consumer.Offsets.InitialLookback.
 	ConsumePartition(topic string, partition int32, offset int64) (PartitionConsumer, error)
 
+	// ConsumePartitionTail creates a PartitionConsumer on the given topic/partition
+	// starting with the last n messages of the partition, or all of them if it has
+	// fewer. As compaction and transaction markers leave gaps between offsets, the
+	// batches before the newest offset are scanned to find where these messages start.
+	ConsumePartitionTail(topic string, partition int32, n int64) (PartitionConsumer, error)
+
+	// ConsumeBetween consumes the messages of every partition of the topic produced
+	// between start (inclusive) and end (exclusive), as indexed by the brokers from
+	// the message timestamps, which requires Version >= V0_10_1_0. The partitions are
+	// consumed in parallel and fn is called from one goroutine per partition, with the
+	// messages of each partition in order. It returns once every partition has been
+	// consumed up to end, or with the first error returned by fn, in which case the
+	// other partitions stop, or with the error of ctx once it is done. The partitions
+	// of the topic must not be consumed already by this Consumer.
+	ConsumeBetween(ctx context.Context, topic string, start, end time.Time, fn func(*ConsumerMessage) error) error
+
 	// HighWaterMarks returns the current high water marks for each topic and partition.
 	// Consistency between partitions is not guaranteed since high water marks are updated separately.
 	HighWaterMarks() map[string]map[int32]int64
@@ -163,6 +212,7 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 		topic:                topic,
 		partition:            partition,
 		messages:             make(chan *ConsumerMessage, c.conf.ChannelBufferSize),
+		batches:              make(chan []*ConsumerMessage, c.conf.ChannelBufferSize),
 		errors:               make(chan *ConsumerError, c.conf.ChannelBufferSize),
 		feeder:               make(chan *FetchResponse, 1),
 		leaderEpoch:          invalidLeaderEpoch,
@@ -170,11 +220,17 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 		trigger:              make(chan none, 1),
 		dying:                make(chan none),
 		fetchSize:            c.conf.Consumer.Fetch.Default,
+		lastStableOffset:     -1,
+		fetched:              -1,
+		caughtUp:             make(chan struct{}),
+		eof:                  make(chan *PartitionEOF, c.conf.ChannelBufferSize),
+		eofOffset:            -1,
 	}
 
 	if err := child.chooseStartingOffset(offset); err != nil {
 		return nil, err
 	}
+	child.advance(child.offset)
 
 	leader, epoch, err := c.client.LeaderAndEpoch(child.topic, child.partition)
 	if err != nil {
@@ -184,6 +240,7 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 	if err := c.addChild(child); err != nil {
 		return nil, err
 	}
+	child.registerMetrics()
 
 	go withRecover(child.dispatcher)
 	go withRecover(child.responseFeeder)
@@ -195,6 +252,101 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 	return child, nil
 }
 
+func (c *consumer) ConsumePartitionTail(topic string, partition int32, n int64) (PartitionConsumer, error) {
+	offset, err := c.tailOffset(topic, partition, n)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConsumePartition(topic, partition, offset)
+}
+
+// tailOffset returns the offset from which the last n messages of the partition are consumed.
+// It scans increasingly large ranges of offsets before the ones already scanned, until n
+// messages were found.
+func (c *consumer) tailOffset(topic string, partition int32, n int64) (int64, error) {
+	newest, err := c.client.GetOffset(topic, partition, OffsetNewest)
+	if err != nil {
+		return 0, err
+	}
+	oldest, err := c.client.GetOffset(topic, partition, OffsetOldest)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return newest, nil
+	}
+
+	leader, err := c.client.Leader(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+
+	var found []int64 // offsets of the last messages found, at most n
+	start, step := newest, n
+	for int64(len(found)) < n && start > oldest {
+		from := start - step
+		if from < oldest {
+			from = oldest
+		}
+		offsets, err := c.scanOffsets(leader, topic, partition, from, start, n-int64(len(found)))
+		if err != nil {
+			return 0, err
+		}
+		found = append(offsets, found...)
+		start = from
+		step *= 2
+	}
+	if int64(len(found)) == n {
+		return found[0], nil
+	}
+	return start, nil
+}
+
+// scanOffsets returns the offsets of the last messages of the partition, at most limit, which
+// are consumed between the offsets from (included) and to (excluded), leaving out control
+// records and, when reading committed messages only, aborted transactions.
+func (c *consumer) scanOffsets(broker *Broker, topic string, partition int32, from, to, limit int64) ([]int64, error) {
+	conf := *c.conf
+	conf.Consumer.Return.Errors = false // errors of the scan are only logged
+	scan := &partitionConsumer{
+		conf:      &conf,
+		broker:    &brokerConsumer{broker: broker},
+		topic:     topic,
+		partition: partition,
+		offset:    from,
+		fetchSize: conf.Consumer.Fetch.Default,
+	}
+
+	var offsets []int64
+	for scan.offset < to {
+		request := newFetchRequest(&conf, conf.isolationLevel(topic))
+		request.SessionEpoch = -1 // no fetch session
+		request.AddBlock(topic, partition, scan.offset, scan.fetchSize, invalidLeaderEpoch)
+		response, err := broker.Fetch(request)
+		if err != nil {
+			return nil, err
+		}
+
+		offset, fetchSize := scan.offset, scan.fetchSize
+		msgs, err := scan.parseResponse(response)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if msg.Offset < to {
+				offsets = append(offsets, msg.Offset)
+			}
+		}
+		if extra := int64(len(offsets)) - limit; extra > 0 {
+			offsets = offsets[extra:]
+		}
+		if scan.offset == offset && scan.fetchSize == fetchSize {
+			break // nothing more to fetch before to
+		}
+	}
+	return offsets, nil
+}
+
 func (c *consumer) HighWaterMarks() map[string]map[int32]int64 {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -211,6 +363,42 @@ func (c *consumer) HighWaterMarks() map[string]map[int32]int64 {
 	return hwms
 }
 
+// child returns the partition consumer of the given partition, or nil if it isn't consumed.
+func (c *consumer) child(topic string, partition int32) *partitionConsumer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.children[topic][partition]
+}
+
+// lag returns the sum of the lags of all the partition consumers.
+func (c *consumer) lag() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var total int64
+	for _, partitions := range c.children {
+		for _, child := range partitions {
+			total += child.Lag()
+		}
+	}
+	return total
+}
+
+// bufferedBytes returns the number of message bytes buffered by all the partition consumers.
+func (c *consumer) bufferedBytes() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	total := 0
+	for _, partitions := range c.children {
+		for _, child := range partitions {
+			total += child.buffered.bytesIn(len(child.messages) + len(child.batches))
+		}
+	}
+	return total
+}
+
 func (c *consumer) addChild(child *partitionConsumer) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -365,6 +553,11 @@ type PartitionConsumer interface {
 	// the broker.
 	Messages() <-chan *ConsumerMessage
 
+	// Batches returns the read channel for the batches of messages returned by
+	// the broker, one per fetch response, if Consumer.Return.Batches is enabled.
+	// Messages are then delivered on this channel only, and not on Messages.
+	Batches() <-chan []*ConsumerMessage
+
 	// Errors returns a read channel of errors that occurred during consuming, if
 	// enabled. By default, errors are logged and not returned over this channel.
 	// If you want to implement any custom error handling, set your config's
@@ -376,6 +569,28 @@ type PartitionConsumer interface {
 	// You can use this to determine how far behind the processing is.
 	HighWaterMarkOffset() int64
 
+	// LastStableOffset returns the last stable offset of the partition, i.e. the
+	// offset up to which all transactions have been completed, and the end of the
+	// partition for consumers using the ReadCommitted isolation level. It returns
+	// -1 until it has been reported by the broker, which requires Version >= V0_11_0_0.
+	LastStableOffset() int64
+
+	// Lag returns the number of messages between the end of the partition and the next
+	// message to be delivered, the end being the last stable offset for consumers using
+	// the ReadCommitted isolation level and the high water mark offset otherwise.
+	Lag() int64
+
+	// CaughtUp returns a channel which is closed once all the messages up to the end of
+	// the partition, as it was when they were fetched, have been delivered. It is useful
+	// to read a topic to the end before serving traffic from its content.
+	CaughtUp() <-chan struct{}
+
+	// EOF returns the read channel for the PartitionEOF events sent each time the end
+	// of the partition is reached, if Consumer.Return.PartitionEOF is enabled. Unlike
+	// CaughtUp, it keeps reporting the end of the partition as new messages are
+	// consumed, which is useful to batch jobs draining a snapshot of a topic.
+	EOF() <-chan *PartitionEOF
+
 	// Pause suspends fetching from this partition. Future calls to the broker will not return
 	// any records from these partition until it have been resumed using Resume().
 	// Note that this method does not affect partition subscription.
@@ -393,11 +608,18 @@ type PartitionConsumer interface {
 
 type partitionConsumer struct {
 	highWaterMarkOffset int64 // must be at the top of the struct because https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	lastStableOffset    int64
+	position            int64 // offset of the next message to be delivered
+	fetched             int64 // offset following the last message fetched to be delivered, -1 until then
+	fetching            int32 // 1 while a fetch request for the partition is in flight or being fed
+	sent                int64 // number of messages and batches sent to the Messages and Batches channels
+	sending             int32 // 1 while the response feeder waits to send a message or batch
 
 	consumer *consumer
 	conf     *Config
 	broker   *brokerConsumer
 	messages chan *ConsumerMessage
+	batches  chan []*ConsumerMessage
 	errors   chan *ConsumerError
 	feeder   chan *FetchResponse
 
@@ -412,8 +634,82 @@ type partitionConsumer struct {
 	fetchSize      int32
 	offset         int64
 	retries        int32
+	// resetOffset is set when the offset is out of range, for the next dispatch to restart
+	// from Consumer.Offsets.Initial
+	resetOffset bool
 
 	paused int32
+
+	buffered bufferedMessages
+
+	// skippedAborted accumulates the aborted records skipped until the next message
+	skippedAborted []AbortedRange
+
+	// caughtUp is closed by the response feeder once the end of the partition is reached
+	caughtUp       chan struct{}
+	caughtUpClosed bool
+
+	eof       chan *PartitionEOF
+	eofOffset int64 // the offset of the last PartitionEOF sent
+
+	consumedRate metrics.Meter
+	fetchLatency metrics.Histogram
+}
+
+// bufferedMessages tracks the size of the messages, or batches of messages, sent to the Messages
+// or Batches channel of a partition consumer which have not been received yet.
+type bufferedMessages struct {
+	lock  sync.Mutex
+	sizes []int
+	bytes int
+}
+
+// add records messages that have just been sent to the channel as a single element.
+func (b *bufferedMessages) add(msgs ...*ConsumerMessage) {
+	size := 0
+	for _, msg := range msgs {
+		size += len(msg.Key) + len(msg.Value)
+		for _, header := range msg.Headers {
+			if header != nil {
+				size += len(header.Key) + len(header.Value)
+			}
+		}
+	}
+
+	b.lock.Lock()
+	b.sizes = append(b.sizes, size)
+	b.bytes += size
+	b.lock.Unlock()
+}
+
+// bytesIn returns the size of the elements still queued in the channel, forgetting about the
+// ones that have been received since the last call.
+func (b *bufferedMessages) bytesIn(queued int) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// channels are FIFO, the elements that have been received are the oldest ones
+	for received := len(b.sizes) - queued; received > 0; received-- {
+		b.bytes -= b.sizes[0]
+		b.sizes = b.sizes[1:]
+	}
+	if len(b.sizes) == 0 {
+		b.sizes = nil
+	}
+	return b.bytes
+}
+
+// bufferFull returns true if the partition consumer has buffered more messages than allowed by
+// Consumer.Fetch.MaxBufferedBytesPerPartition.
+func (child *partitionConsumer) bufferFull() bool {
+	limit := child.conf.Consumer.Fetch.MaxBufferedBytesPerPartition
+	return limit > 0 && child.buffered.bytesIn(len(child.messages)+len(child.batches)) >= limit
+}
+
+// trackBuffered returns true if the size of the messages buffered by partition consumers has
+// to be tracked to enforce the configured limits.
+func (child *partitionConsumer) trackBuffered() bool {
+	return child.conf.Consumer.Fetch.MaxBufferedBytesPerPartition > 0 || child.conf.Consumer.Fetch.MaxBufferedBytes > 0
 }
 
 var errTimedOut = errors.New("timed out feeding messages to the user") // not user-facing
@@ -461,10 +757,42 @@ func (child *partitionConsumer) dispatcher() {
 	if child.broker != nil {
 		child.consumer.unrefBrokerConsumer(child.broker)
 	}
+	child.unregisterMetrics()
 	child.consumer.removeChild(child)
 	close(child.feeder)
 }
 
+// markSent records that a message or batch was sent to the Messages or Batches channel.
+func (child *partitionConsumer) markSent() {
+	atomic.AddInt64(&child.sent, 1)
+	atomic.StoreInt32(&child.sending, 0)
+}
+
+// progress returns the number of messages and batches received from the Messages and Batches
+// channels so far, and whether any is waiting to be received.
+func (child *partitionConsumer) progress() (received int64, waiting bool) {
+	queued := int64(len(child.messages) + len(child.batches))
+	received = atomic.LoadInt64(&child.sent) - queued
+	return received, queued > 0 || atomic.LoadInt32(&child.sending) == 1
+}
+
+// registerMetrics registers the metrics of the partition consumer, see the consumer related
+// metrics in the package documentation.
+func (child *partitionConsumer) registerMetrics() {
+	registry := child.consumer.metricRegistry
+	registry.GetOrRegister(getMetricNameForPartition("consumer-lag", child.topic, child.partition), metrics.NewFunctionalGauge(child.Lag))
+	child.consumedRate = metrics.GetOrRegisterMeter(getMetricNameForPartition("consumer-records-consumed-rate", child.topic, child.partition), registry)
+	child.fetchLatency = getOrRegisterHistogram(getMetricNameForPartition("consumer-fetch-latency-in-ms", child.topic, child.partition), registry)
+}
+
+// unregisterMetrics unregisters the metrics of the partition consumer, before it is removed
+// from the consumer so that the partition can be consumed again.
+func (child *partitionConsumer) unregisterMetrics() {
+	for _, name := range []string{"consumer-lag", "consumer-records-consumed-rate", "consumer-fetch-latency-in-ms"} {
+		child.consumer.metricRegistry.Unregister(getMetricNameForPartition(name, child.topic, child.partition))
+	}
+}
+
 func (child *partitionConsumer) preferredBroker() (*Broker, int32, error) {
 	if child.preferredReadReplica >= 0 {
 		broker, err := child.consumer.client.Broker(child.preferredReadReplica)
@@ -490,6 +818,14 @@ func (child *partitionConsumer) dispatch() error {
 		return err
 	}
 
+	if child.resetOffset {
+		if err := child.chooseStartingOffset(child.conf.Consumer.Offsets.Initial); err != nil {
+			return err
+		}
+		child.advance(child.offset)
+		child.resetOffset = false
+	}
+
 	broker, epoch, err := child.preferredBroker()
 	if err != nil {
 		return err
@@ -503,18 +839,42 @@ func (child *partitionConsumer) dispatch() error {
 }
 
 func (child *partitionConsumer) chooseStartingOffset(offset int64) error {
+	switch {
+	case offset == OffsetTimestamp && child.conf.Consumer.Offsets.InitialTimestamp.IsZero():
+		return ConfigurationError("Consumer.Offsets.InitialTimestamp must be set to consume from OffsetTimestamp")
+	case offset == OffsetRelative && child.conf.Consumer.Offsets.InitialLookback <= 0:
+		return ConfigurationError("Consumer.Offsets.InitialLookback must be > 0 to consume from OffsetRelative")
+	}
+
 	newestOffset, err := child.consumer.client.GetOffset(child.topic, child.partition, OffsetNewest)
 	if err != nil {
 		return err
 	}
 
-	child.highWaterMarkOffset = newestOffset
+	atomic.StoreInt64(&child.highWaterMarkOffset, newestOffset)
 
 	oldestOffset, err := child.consumer.client.GetOffset(child.topic, child.partition, OffsetOldest)
 	if err != nil {
 		return err
 	}
 
+	if offset >= 0 && (offset < oldestOffset || offset > newestOffset) && child.resetsOutOfRange() {
+		Logger.Printf("consumer/%s/%d offset %d is out of range, starting from Consumer.Offsets.Initial\n", child.topic, child.partition, offset)
+		offset = child.conf.Consumer.Offsets.Initial
+	}
+
+	switch offset {
+	case OffsetTimestamp:
+		offset, err = child.offsetForTime(child.conf.Consumer.Offsets.InitialTimestamp)
+	case OffsetRelative:
+		offset, err = child.offsetForTime(time.Now().Add(-child.conf.Consumer.Offsets.InitialLookback))
+	case OffsetFail:
+		return ErrNoInitialOffset
+	}
+	if err != nil {
+		return err
+	}
+
 	switch {
 	case offset == OffsetNewest:
 		child.offset = newestOffset
@@ -529,10 +889,33 @@ func (child *partitionConsumer) chooseStartingOffset(offset int64) error {
 	return nil
 }
 
+// resetsOutOfRange returns whether an offset out of range is replaced by
+// Consumer.Offsets.Initial rather than failing with ErrOffsetOutOfRange.
+func (child *partitionConsumer) resetsOutOfRange() bool {
+	return child.conf.Consumer.Offsets.ResetOutOfRange && child.conf.Consumer.Offsets.Initial != OffsetFail
+}
+
+// offsetForTime returns the offset of the first message produced at or after t, or
+// OffsetNewest if there is none.
+func (child *partitionConsumer) offsetForTime(t time.Time) (int64, error) {
+	offset, err := child.consumer.client.GetOffset(child.topic, child.partition, t.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return OffsetNewest, nil
+	}
+	return offset, nil
+}
+
 func (child *partitionConsumer) Messages() <-chan *ConsumerMessage {
 	return child.messages
 }
 
+func (child *partitionConsumer) Batches() <-chan []*ConsumerMessage {
+	return child.batches
+}
+
 func (child *partitionConsumer) Errors() <-chan *ConsumerError {
 	return child.errors
 }
@@ -565,6 +948,59 @@ func (child *partitionConsumer) HighWaterMarkOffset() int64 {
 	return atomic.LoadInt64(&child.highWaterMarkOffset)
 }
 
+func (child *partitionConsumer) LastStableOffset() int64 {
+	return atomic.LoadInt64(&child.lastStableOffset)
+}
+
+func (child *partitionConsumer) Lag() int64 {
+	if lag := child.endOffset() - atomic.LoadInt64(&child.position); lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+func (child *partitionConsumer) CaughtUp() <-chan struct{} {
+	return child.caughtUp
+}
+
+func (child *partitionConsumer) EOF() <-chan *PartitionEOF {
+	return child.eof
+}
+
+// reachedEOF returns the PartitionEOF to send once the messages of a response have been
+// delivered, or nil if the end of the partition was not reached since the last one.
+func (child *partitionConsumer) reachedEOF() *PartitionEOF {
+	if !child.conf.Consumer.Return.PartitionEOF || child.responseResult != nil {
+		return nil
+	}
+	if child.offset < child.endOffset() || child.offset == child.eofOffset {
+		return nil
+	}
+	child.eofOffset = child.offset
+	return &PartitionEOF{Topic: child.topic, Partition: child.partition, Offset: child.offset}
+}
+
+// endOffset returns the offset of the end of the partition for the configured isolation level.
+func (child *partitionConsumer) endOffset() int64 {
+	if child.conf.isolationLevel(child.topic) == ReadCommitted {
+		if lso := atomic.LoadInt64(&child.lastStableOffset); lso >= 0 {
+			return lso
+		}
+	}
+	return atomic.LoadInt64(&child.highWaterMarkOffset)
+}
+
+// advance records that the messages before offset have been delivered, and signals CaughtUp
+// once the end of the partition is reached. It is only called by the response feeder, once
+// the partition consumer has started.
+func (child *partitionConsumer) advance(offset int64) {
+	atomic.StoreInt64(&child.position, offset)
+	if !child.caughtUpClosed && offset >= child.endOffset() {
+		child.caughtUpClosed = true
+		close(child.caughtUp)
+	}
+}
+
 func (child *partitionConsumer) responseFeeder() {
 	var msgs []*ConsumerMessage
 	expiryTicker := time.NewTicker(child.conf.Consumer.MaxProcessingTime)
@@ -572,51 +1008,205 @@ func (child *partitionConsumer) responseFeeder() {
 
 feederLoop:
 	for response := range child.feeder {
-		msgs, child.responseResult = child.parseResponse(response)
+		records, err := child.parseBlock(response)
+		msgs = nil
+		if err == nil && records != nil {
+			msgs, err = child.nextMessages(records)
+		}
+		child.responseResult = err
 
 		if child.responseResult == nil {
 			atomic.StoreInt32(&child.retries, 0)
 		}
 
-		for i, msg := range msgs {
-			child.interceptors(msg)
-		messageSelect:
+		// with lazy decompression, the record sets are parsed as the previous ones are delivered
+		for {
+			if len(msgs) > 0 {
+				atomic.StoreInt64(&child.fetched, msgs[len(msgs)-1].Offset+1)
+			}
+
+			if child.conf.Consumer.Return.Batches {
+				if len(msgs) > 0 && !child.feedBatch(msgs, expiryTicker, &firstAttempt) {
+					continue feederLoop
+				}
+				child.consumedRate.Mark(int64(len(msgs)))
+			} else if !child.feedMessages(msgs, expiryTicker, &firstAttempt) {
+				continue feederLoop
+			}
+
+			if child.responseResult != nil || records == nil || len(records.sets) == 0 {
+				break
+			}
+			if msgs, err = child.nextMessages(records); err != nil {
+				child.responseResult = err
+				break
+			}
+		}
+
+		// skipped records may lie between the last message and the next offset to fetch
+		child.advance(child.offset)
+		eof := child.reachedEOF()
+		child.broker.acks.Done()
+
+		if eof != nil {
 			select {
+			case child.eof <- eof:
 			case <-child.dying:
+			}
+		}
+	}
+
+	expiryTicker.Stop()
+	close(child.messages)
+	close(child.batches)
+	close(child.errors)
+	close(child.eof)
+}
+
+// feedMessages sends messages to the Messages channel. It returns false if the broker consumer
+// has already been acknowledged, because the partition consumer is closing or timed out.
+func (child *partitionConsumer) feedMessages(msgs []*ConsumerMessage, expiryTicker *time.Ticker, firstAttempt *bool) bool {
+	deserialized := child.deserializeAsync(msgs)
+	for i, msg := range msgs {
+		// the message is sent once deserialized
+		waiting, messages := deserialized, child.messages
+		if waiting != nil {
+			messages = nil
+		} else {
+			child.interceptors(msg)
+			atomic.StoreInt32(&child.sending, 1)
+		}
+	messageSelect:
+		select {
+		case <-child.dying:
+			atomic.StoreInt32(&child.sending, 0)
+			child.broker.acks.Done()
+			return false
+		case errs := <-waiting:
+			child.sendErrors(errs)
+			child.interceptors(msg)
+			atomic.StoreInt32(&child.sending, 1)
+			waiting, messages = nil, child.messages
+			goto messageSelect
+		case messages <- msg:
+			child.markSent()
+			if child.trackBuffered() {
+				child.buffered.add(msg)
+			}
+			child.consumedRate.Mark(1)
+			child.advance(msg.Offset + 1)
+			*firstAttempt = true
+		case <-expiryTicker.C:
+			if !*firstAttempt {
+				child.responseResult = errTimedOut
 				child.broker.acks.Done()
-				continue feederLoop
-			case child.messages <- msg:
-				firstAttempt = true
-			case <-expiryTicker.C:
-				if !firstAttempt {
-					child.responseResult = errTimedOut
-					child.broker.acks.Done()
-				remainingLoop:
-					for _, msg = range msgs[i:] {
-						child.interceptors(msg)
-						select {
-						case child.messages <- msg:
-						case <-child.dying:
+			remainingLoop:
+				for j, msg := range msgs[i:] {
+					if j > 0 || waiting != nil {
+						if !child.awaitDeserialized(deserialized, msg) {
 							break remainingLoop
 						}
 					}
-					child.broker.input <- child
-					continue feederLoop
-				} else {
-					// current message has not been sent, return to select
-					// statement
-					firstAttempt = false
-					goto messageSelect
+					atomic.StoreInt32(&child.sending, 1)
+					select {
+					case child.messages <- msg:
+						child.markSent()
+						if child.trackBuffered() {
+							child.buffered.add(msg)
+						}
+						child.consumedRate.Mark(1)
+						child.advance(msg.Offset + 1)
+					case <-child.dying:
+						break remainingLoop
+					}
 				}
+				atomic.StoreInt32(&child.sending, 0)
+				child.broker.input <- child
+				return false
+			} else {
+				// current message has not been sent, return to select
+				// statement
+				*firstAttempt = false
+				goto messageSelect
 			}
 		}
+	}
+	return true
+}
 
-		child.broker.acks.Done()
+// feedBatch sends a batch of messages to the Batches channel, the same way messages are
+// sent to the Messages channel. It returns false if the broker consumer has already been
+// acknowledged, because the partition consumer is closing or timed out.
+func (child *partitionConsumer) feedBatch(msgs []*ConsumerMessage, expiryTicker *time.Ticker, firstAttempt *bool) bool {
+	// the batch is sent once all its messages are deserialized
+	deserialized, waiting, batches := child.deserializeAsync(msgs), len(msgs), child.batches
+	ready := func() {
+		for _, msg := range msgs {
+			child.interceptors(msg)
+		}
+		atomic.StoreInt32(&child.sending, 1)
+	}
+	if deserialized == nil {
+		ready()
+	} else {
+		batches = nil
 	}
 
-	expiryTicker.Stop()
-	close(child.messages)
-	close(child.errors)
+	defer atomic.StoreInt32(&child.sending, 0)
+	for {
+		select {
+		case <-child.dying:
+			child.broker.acks.Done()
+			return false
+		case errs := <-deserialized:
+			child.sendErrors(errs)
+			if waiting--; waiting == 0 {
+				ready()
+				deserialized, batches = nil, child.batches
+			}
+		case batches <- msgs:
+			child.markSent()
+			if child.trackBuffered() {
+				child.buffered.add(msgs...)
+			}
+			*firstAttempt = true
+			return true
+		case <-expiryTicker.C:
+			if *firstAttempt {
+				// the batch has not been sent, try again until the next tick
+				*firstAttempt = false
+				continue
+			}
+			child.responseResult = errTimedOut
+			child.broker.acks.Done()
+			if child.awaitBatchDeserialized(deserialized, waiting) {
+				if deserialized != nil {
+					ready()
+				}
+				select {
+				case child.batches <- msgs:
+					child.markSent()
+					if child.trackBuffered() {
+						child.buffered.add(msgs...)
+					}
+				case <-child.dying:
+				}
+			}
+			child.broker.input <- child
+			return false
+		}
+	}
+}
+
+// awaitBatchDeserialized waits for the given number of messages of deserializeAsync to be
+// deserialized, see awaitDeserialized.
+func (child *partitionConsumer) awaitBatchDeserialized(deserialized <-chan []error, waiting int) bool {
+	for ; waiting > 0; waiting-- {
+		if !child.awaitDeserialized(deserialized, nil) {
+			return false
+		}
+	}
+	return true
 }
 
 func (child *partitionConsumer) parseMessages(msgSet *MessageSet) ([]*ConsumerMessage, error) {
@@ -682,7 +1272,62 @@ func (child *partitionConsumer) parseRecords(batch *RecordBatch) ([]*ConsumerMes
 	return messages, nil
 }
 
+// skipAborted records the messages of an aborted transaction which are not delivered, merging
+// them with the previous range of the same producer when contiguous.
+func (child *partitionConsumer) skipAborted(producerID int64, msgs []*ConsumerMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+	first, last := msgs[0].Offset, msgs[len(msgs)-1].Offset
+
+	if n := len(child.skippedAborted); n > 0 {
+		prev := &child.skippedAborted[n-1]
+		if prev.ProducerID == producerID && prev.LastOffset+1 == first {
+			prev.LastOffset = last
+			prev.Records += len(msgs)
+			return
+		}
+	}
+	child.skippedAborted = append(child.skippedAborted, AbortedRange{
+		ProducerID:  producerID,
+		FirstOffset: first,
+		LastOffset:  last,
+		Records:     len(msgs),
+	})
+}
+
+// parseResponse parses all the messages fetched for the partition.
 func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*ConsumerMessage, error) {
+	records, err := child.parseBlock(response)
+	if err != nil || records == nil {
+		return nil, err
+	}
+
+	var messages []*ConsumerMessage
+	for len(records.sets) > 0 {
+		msgs, err := child.nextMessages(records)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msgs...)
+	}
+	return messages, nil
+}
+
+// fetchedRecords are the record sets fetched for a partition which remain to be parsed.
+type fetchedRecords struct {
+	sets []*Records
+	// abortedProducerIDs contains producerID which message should be ignored as uncommitted
+	// - producerID are added when the partitionConsumer iterate over the offset at which an aborted transaction begins (abortedTransaction.FirstOffset)
+	// - producerID are removed when partitionConsumer iterate over an aborted controlRecord, meaning the aborted transaction for this producer is over
+	abortedProducerIDs  map[int64]struct{}
+	abortedTransactions []*AbortedTransaction
+	lazy                bool // whether the record sets are parsed one at a time
+}
+
+// parseBlock checks the block fetched for the partition, and returns its record sets, or nil
+// if it has none.
+func (child *partitionConsumer) parseBlock(response *FetchResponse) (*fetchedRecords, error) {
 	var consumerBatchSizeMetric metrics.Histogram
 	if child.consumer != nil && child.consumer.metricRegistry != nil {
 		consumerBatchSizeMetric = getOrRegisterHistogram("consumer-batch-size", child.consumer.metricRegistry)
@@ -705,6 +1350,10 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		return nil, block.Err
 	}
 
+	if response.Version >= 4 {
+		atomic.StoreInt64(&child.lastStableOffset, block.LastStableOffset)
+	}
+
 	nRecs, err := block.numRecords()
 	if err != nil {
 		return nil, err
@@ -748,85 +1397,178 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 
 		return nil, nil
 	}
-
 	// we got messages, reset our fetch size in case it was increased for a previous request
 	child.fetchSize = child.conf.Consumer.Fetch.Default
 	atomic.StoreInt64(&child.highWaterMarkOffset, block.HighWaterMarkOffset)
 
-	// abortedProducerIDs contains producerID which message should be ignored as uncommitted
-	// - producerID are added when the partitionConsumer iterate over the offset at which an aborted transaction begins (abortedTransaction.FirstOffset)
-	// - producerID are removed when partitionConsumer iterate over an aborted controlRecord, meaning the aborted transaction for this producer is over
-	abortedProducerIDs := make(map[int64]struct{}, len(block.AbortedTransactions))
-	abortedTransactions := block.getAbortedTransactions()
+	records := &fetchedRecords{
+		sets:                block.RecordsSet,
+		abortedProducerIDs:  make(map[int64]struct{}, len(block.AbortedTransactions)),
+		abortedTransactions: block.getAbortedTransactions(),
+		lazy:                response.lazyDecompression,
+	}
+	return records, nil
+}
 
+// nextMessages parses the remaining record sets, or only the next one if the batches are
+// decompressed lazily, so that the records of a batch are only decompressed once the messages
+// of the previous ones were delivered.
+func (child *partitionConsumer) nextMessages(records *fetchedRecords) ([]*ConsumerMessage, error) {
 	var messages []*ConsumerMessage
-	for _, records := range block.RecordsSet {
-		switch records.recordsType {
-		case legacyRecords:
-			messageSetMessages, err := child.parseMessages(records.MsgSet)
-			if err != nil {
-				return nil, err
-			}
+	for len(records.sets) > 0 {
+		set := records.sets[0]
+		records.sets = records.sets[1:]
 
-			messages = append(messages, messageSetMessages...)
-		case defaultRecords:
-			// Consume remaining abortedTransaction up to last offset of current batch
-			for _, txn := range abortedTransactions {
-				if txn.FirstOffset > records.RecordBatch.LastOffset() {
-					break
-				}
-				abortedProducerIDs[txn.ProducerID] = struct{}{}
-				// Pop abortedTransactions so that we never add it again
-				abortedTransactions = abortedTransactions[1:]
+		msgs, err := child.parseRecordSet(records, set)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msgs...)
+		if records.lazy && len(messages) > 0 {
+			break
+		}
+	}
+	return messages, nil
+}
+
+// parseRecordSet parses a record set, filtering out the control records and, when reading
+// committed messages only, the ones of aborted transactions.
+func (child *partitionConsumer) parseRecordSet(fetched *fetchedRecords, records *Records) ([]*ConsumerMessage, error) {
+	switch records.recordsType {
+	case legacyRecords:
+		return child.parseMessages(records.MsgSet)
+	case defaultRecords:
+		// Consume remaining abortedTransaction up to last offset of current batch
+		for _, txn := range fetched.abortedTransactions {
+			if txn.FirstOffset > records.RecordBatch.LastOffset() {
+				break
 			}
+			fetched.abortedProducerIDs[txn.ProducerID] = struct{}{}
+			// Pop abortedTransactions so that we never add it again
+			fetched.abortedTransactions = fetched.abortedTransactions[1:]
+		}
 
-			recordBatchMessages, err := child.parseRecords(records.RecordBatch)
+		if err := records.RecordBatch.decompressRecords(); err != nil {
+			return nil, err
+		}
+		recordBatchMessages, err := child.parseRecords(records.RecordBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse and commit offset but do not expose messages that are:
+		// - control records
+		// - part of an aborted transaction when set to `ReadCommitted`
+
+		// control record
+		isControl, err := records.isControl()
+		if err != nil {
+			// I don't know why there is this continue in case of error to begin with
+			// Safe bet is to ignore control messages if ReadUncommitted
+			// and block on them in case of error and ReadCommitted
+			if child.conf.isolationLevel(child.topic) == ReadCommitted {
+				return nil, err
+			}
+			return nil, nil
+		}
+		if isControl {
+			controlRecord, err := records.getControlRecord()
 			if err != nil {
 				return nil, err
 			}
 
-			// Parse and commit offset but do not expose messages that are:
-			// - control records
-			// - part of an aborted transaction when set to `ReadCommitted`
-
-			// control record
-			isControl, err := records.isControl()
-			if err != nil {
-				// I don't know why there is this continue in case of error to begin with
-				// Safe bet is to ignore control messages if ReadUncommitted
-				// and block on them in case of error and ReadCommitted
-				if child.conf.Consumer.IsolationLevel == ReadCommitted {
-					return nil, err
-				}
-				continue
+			if controlRecord.Type == ControlRecordAbort {
+				delete(fetched.abortedProducerIDs, records.RecordBatch.ProducerID)
 			}
-			if isControl {
-				controlRecord, err := records.getControlRecord()
-				if err != nil {
-					return nil, err
-				}
+			return nil, nil
+		}
 
-				if controlRecord.Type == ControlRecordAbort {
-					delete(abortedProducerIDs, records.RecordBatch.ProducerID)
-				}
-				continue
+		// filter aborted transactions
+		if child.conf.isolationLevel(child.topic) == ReadCommitted {
+			_, isAborted := fetched.abortedProducerIDs[records.RecordBatch.ProducerID]
+			if records.RecordBatch.IsTransactional && isAborted {
+				child.skipAborted(records.RecordBatch.ProducerID, recordBatchMessages)
+				return nil, nil
 			}
+			if len(child.skippedAborted) > 0 && len(recordBatchMessages) > 0 {
+				recordBatchMessages[0].SkippedAborted = child.skippedAborted
+				child.skippedAborted = nil
+			}
+		}
 
-			// filter aborted transactions
-			if child.conf.Consumer.IsolationLevel == ReadCommitted {
-				_, isAborted := abortedProducerIDs[records.RecordBatch.ProducerID]
-				if records.RecordBatch.IsTransactional && isAborted {
-					continue
-				}
+		return recordBatchMessages, nil
+	}
+	return nil, fmt.Errorf("unknown records type: %v", records.recordsType)
+}
+
+// deserializeAsync deserializes msgs in order in the background, so that slow deserializers,
+// such as schema registry lookups, hold up their partition like slow processing does, rather
+// than the response feeders of all the partitions of the broker. The returned channel receives
+// the errors of each message once deserialized, it is nil if the topic has no deserializers.
+func (child *partitionConsumer) deserializeAsync(msgs []*ConsumerMessage) <-chan []error {
+	deserializers, ok := child.conf.Consumer.Deserializers[child.topic]
+	if !ok || (deserializers.Key == nil && deserializers.Value == nil) || len(msgs) == 0 {
+		return nil
+	}
+
+	deserialized := make(chan []error, len(msgs))
+	go withRecover(func() {
+		for _, msg := range msgs {
+			select {
+			case <-child.dying:
+				return
+			default:
 			}
+			deserialized <- deserialize(deserializers, msg)
+		}
+	})
+	return deserialized
+}
 
-			messages = append(messages, recordBatchMessages...)
-		default:
-			return nil, fmt.Errorf("unknown records type: %v", records.recordsType)
+// awaitDeserialized waits for the next message of deserializeAsync, if any, to be deserialized
+// and reports its errors, then runs the interceptors on msg, if not nil. It returns false if
+// the partition consumer is closing.
+func (child *partitionConsumer) awaitDeserialized(deserialized <-chan []error, msg *ConsumerMessage) bool {
+	if deserialized != nil {
+		select {
+		case errs := <-deserialized:
+			child.sendErrors(errs)
+		case <-child.dying:
+			return false
 		}
 	}
+	if msg != nil {
+		child.interceptors(msg)
+	}
+	return true
+}
 
-	return messages, nil
+func (child *partitionConsumer) sendErrors(errs []error) {
+	for _, err := range errs {
+		child.sendError(err)
+	}
+}
+
+// deserialize decodes the key and value of msg with the given deserializers, returning the
+// errors of those failing to.
+func deserialize(deserializers TopicDeserializers, msg *ConsumerMessage) (errs []error) {
+	if deserializers.Key != nil && msg.Key != nil {
+		key, err := deserializers.Key.Deserialize(msg.Topic, msg.Key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("kafka: unable to deserialize key of message at offset %d: %w", msg.Offset, err))
+		} else {
+			msg.DecodedKey = key
+		}
+	}
+	if deserializers.Value != nil && msg.Value != nil {
+		value, err := deserializers.Value.Deserialize(msg.Topic, msg.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("kafka: unable to deserialize value of message at offset %d: %w", msg.Offset, err))
+		} else {
+			msg.DecodedValue = value
+		}
+	}
+	return errs
 }
 
 func (child *partitionConsumer) interceptors(msg *ConsumerMessage) {
@@ -856,8 +1598,10 @@ type brokerConsumer struct {
 	input            chan *partitionConsumer
 	newSubscriptions chan []*partitionConsumer
 	subscriptions    map[*partitionConsumer]none
+	sessions         map[IsolationLevel]*fetchSession
 	acks             sync.WaitGroup
 	refs             int
+	fetching         []*partitionConsumer // the partitions of the fetch in progress
 }
 
 func (c *consumer) newBrokerConsumer(broker *Broker) *brokerConsumer {
@@ -867,6 +1611,7 @@ func (c *consumer) newBrokerConsumer(broker *Broker) *brokerConsumer {
 		input:            make(chan *partitionConsumer),
 		newSubscriptions: make(chan []*partitionConsumer),
 		subscriptions:    make(map[*partitionConsumer]none),
+		sessions:         make(map[IsolationLevel]*fetchSession),
 		refs:             0,
 	}
 
@@ -932,16 +1677,22 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 			continue
 		}
 
+		requestTime := time.Now()
 		response, err := bc.fetchNewMessages()
+		latency := time.Since(requestTime)
 		if err != nil {
+			bc.fetchDone()
 			Logger.Printf("consumer/broker/%d disconnecting due to error processing FetchRequest: %s\n", bc.broker.ID(), err)
 			bc.abort(err)
 			return
 		}
 
 		// if there isn't response, it means that not fetch was made
-		// so we don't need to handle any response
+		// so we don't need to handle any response. Take a small nap
+		// while partitions are paused or their buffers are full.
 		if response == nil {
+			bc.fetchDone()
+			time.Sleep(partitionConsumersBatchTimeout)
 			continue
 		}
 
@@ -957,13 +1708,24 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 				continue
 			}
 
+			child.fetchLatency.Update(latency.Milliseconds())
 			child.feeder <- response
 		}
 		bc.acks.Wait()
+		bc.fetchDone()
 		bc.handleResponses()
 	}
 }
 
+// fetchDone records that the response to the fetch in progress, if any, was fed to its
+// partitions.
+func (bc *brokerConsumer) fetchDone() {
+	for _, child := range bc.fetching {
+		atomic.StoreInt32(&child.fetching, 0)
+	}
+	bc.fetching = bc.fetching[:0]
+}
+
 func (bc *brokerConsumer) updateSubscriptions(newSubscriptions []*partitionConsumer) {
 	for _, child := range newSubscriptions {
 		bc.subscriptions[child] = none{}
@@ -1003,12 +1765,26 @@ func (bc *brokerConsumer) handleResponses() {
 		}
 
 		// Discard any replica preference.
+		fromReadReplica := child.preferredReadReplica >= 0 && bc.broker.ID() == child.preferredReadReplica
 		child.preferredReadReplica = invalidPreferredReplicaID
 
 		if errors.Is(result, errTimedOut) {
 			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because consuming was taking too long\n",
 				bc.broker.ID(), child.topic, child.partition)
 			delete(bc.subscriptions, child)
+		} else if errors.Is(result, ErrOffsetOutOfRange) && fromReadReplica {
+			// the read replica may be lagging behind the leader and not have the offset yet,
+			// only the leader can tell whether it is really out of range
+			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d in favor of the leader because %s\n",
+				bc.broker.ID(), child.topic, child.partition, result)
+			child.trigger <- none{}
+			delete(bc.subscriptions, child)
+		} else if errors.Is(result, ErrOffsetOutOfRange) && child.resetsOutOfRange() {
+			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d to restart from Consumer.Offsets.Initial because %s\n",
+				bc.broker.ID(), child.topic, child.partition, result)
+			child.resetOffset = true
+			child.trigger <- none{}
+			delete(bc.subscriptions, child)
 		} else if errors.Is(result, ErrOffsetOutOfRange) {
 			// there's no point in retrying this it will just fail the same way again
 			// shut it down and force the user to choose what to do
@@ -1020,8 +1796,11 @@ func (bc *brokerConsumer) handleResponses() {
 			errors.Is(result, ErrNotLeaderForPartition) ||
 			errors.Is(result, ErrLeaderNotAvailable) ||
 			errors.Is(result, ErrReplicaNotAvailable) ||
+			errors.Is(result, ErrOffsetNotAvailable) ||
 			errors.Is(result, ErrFencedLeaderEpoch) ||
-			errors.Is(result, ErrUnknownLeaderEpoch) {
+			errors.Is(result, ErrUnknownLeaderEpoch) ||
+			errors.Is(result, ErrUnknownTopicID) ||
+			errors.Is(result, ErrInconsistentTopicID) {
 			// not an error, but does need redispatching
 			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because %s\n",
 				bc.broker.ID(), child.topic, child.partition, result)
@@ -1060,53 +1839,144 @@ func (bc *brokerConsumer) abort(err error) {
 	}
 }
 
-// fetchResponse can be nil if no fetch is made, it can occur when
-// all partitions are paused
-func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
+// newFetchRequest creates a fetch request without any partition, using the latest version
+// supported by the configured Kafka version.
+func newFetchRequest(conf *Config, isolation IsolationLevel) *FetchRequest {
 	request := &FetchRequest{
-		MinBytes:    bc.consumer.conf.Consumer.Fetch.Min,
-		MaxWaitTime: int32(bc.consumer.conf.Consumer.MaxWaitTime / time.Millisecond),
+		MinBytes:          conf.Consumer.Fetch.Min,
+		MaxWaitTime:       int32(conf.Consumer.MaxWaitTime / time.Millisecond),
+		lazyDecompression: conf.Consumer.Fetch.LazyDecompression,
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V0_9_0_0) {
+	if conf.Version.IsAtLeast(V0_9_0_0) {
 		request.Version = 1
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V0_10_0_0) {
+	if conf.Version.IsAtLeast(V0_10_0_0) {
 		request.Version = 2
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V0_10_1_0) {
+	if conf.Version.IsAtLeast(V0_10_1_0) {
 		request.Version = 3
 		request.MaxBytes = MaxResponseSize
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V0_11_0_0) {
+	if conf.Version.IsAtLeast(V0_11_0_0) {
 		request.Version = 4
-		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+		request.Isolation = isolation
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V1_1_0_0) {
+	if conf.Version.IsAtLeast(V1_1_0_0) {
 		request.Version = 7
-		// We do not currently implement KIP-227 FetchSessions. Setting the id to 0
-		// and the epoch to -1 tells the broker not to generate as session ID we're going
-		// to just ignore anyway.
-		request.SessionID = 0
-		request.SessionEpoch = -1
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V2_1_0_0) {
+	if conf.Version.IsAtLeast(V2_1_0_0) {
 		request.Version = 10
 	}
-	if bc.consumer.conf.Version.IsAtLeast(V2_3_0_0) {
+	if conf.Version.IsAtLeast(V2_3_0_0) {
 		request.Version = 11
-		request.RackID = bc.consumer.conf.RackID
+		request.RackID = conf.RackID
+	}
+	if conf.Version.IsAtLeast(V2_7_0_0) {
+		request.Version = 12
+	}
+	if conf.Version.IsAtLeast(V3_1_0_0) {
+		request.Version = 13
+	}
+	return request
+}
+
+// fetchResponse can be nil if no fetch is made, it can occur when
+// all partitions are paused or have buffered too many messages
+func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
+	if limit := bc.consumer.conf.Consumer.Fetch.MaxBufferedBytes; limit > 0 && bc.consumer.bufferedBytes() >= limit {
+		return nil, nil
 	}
 
+	// the isolation level applies to the whole request, partitions of topics consumed with
+	// different isolation levels are fetched with separate requests
+	partitions := make(map[IsolationLevel]map[topicPartition]fetchSessionPartition)
 	for child := range bc.subscriptions {
-		if !child.IsPaused() {
-			request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize, child.leaderEpoch)
+		// flagged before checking whether it is paused, so that a concurrent Pause either
+		// excludes the partition or sees the fetch in progress
+		atomic.StoreInt32(&child.fetching, 1)
+		bc.fetching = append(bc.fetching, child)
+		if !child.IsPaused() && !child.bufferFull() {
+			level := bc.consumer.conf.isolationLevel(child.topic)
+			if partitions[level] == nil {
+				partitions[level] = make(map[topicPartition]fetchSessionPartition)
+			}
+			partitions[level][topicPartition{topic: child.topic, partition: child.partition}] = fetchSessionPartition{
+				fetchOffset: child.offset,
+				maxBytes:    child.fetchSize,
+				leaderEpoch: child.leaderEpoch,
+			}
 		}
 	}
 
-	// avoid to fetch when there is no block
-	if len(request.blocks) == 0 {
+	// avoid to fetch when there is no partition to fetch
+	if len(partitions) == 0 {
 		return nil, nil
 	}
 
-	return bc.broker.Fetch(request)
+	// the broker handles the requests of a connection one at a time, only the first one waits
+	// for Consumer.MaxWaitTime so that the second one does not double the latency
+	var response *FetchResponse
+	for _, level := range []IsolationLevel{ReadUncommitted, ReadCommitted} {
+		if len(partitions[level]) == 0 {
+			continue
+		}
+		levelResponse, err := bc.fetch(level, partitions[level], response == nil)
+		if err != nil {
+			return nil, err
+		}
+		if response == nil {
+			response = levelResponse
+		} else {
+			response.merge(levelResponse)
+		}
+	}
+	return response, nil
+}
+
+// fetch sends a fetch request for the given partitions with the given isolation level, using
+// the fetch session of the isolation level if the broker supports them. The broker answers
+// right away unless wait is set, in which case it may wait for Consumer.MaxWaitTime.
+func (bc *brokerConsumer) fetch(isolation IsolationLevel, partitions map[topicPartition]fetchSessionPartition, wait bool) (*FetchResponse, error) {
+	request := newFetchRequest(bc.consumer.conf, isolation)
+	if !wait {
+		request.MaxWaitTime = 0
+	}
+	if request.Version >= 13 {
+		bc.setTopicIDs(request, partitions)
+	}
+	if request.Version < 7 {
+		for tp, p := range partitions {
+			request.AddBlock(tp.topic, tp.partition, p.fetchOffset, p.maxBytes, p.leaderEpoch)
+		}
+		return bc.broker.Fetch(request)
+	}
+
+	// use an incremental fetch session, only listing the partitions which changed
+	session := bc.sessions[isolation]
+	if session == nil {
+		session = newFetchSession()
+		bc.sessions[isolation] = session
+	}
+	session.build(request, partitions)
+	response, err := bc.broker.Fetch(request)
+	if err != nil {
+		session.reset()
+		return nil, err
+	}
+	session.update(response)
+	return response, nil
+}
+
+// setTopicIDs sets the IDs of the topics of the partitions to the request, falling back to
+// fetching by name with version 12 when the ID of any of them is unknown.
+func (bc *brokerConsumer) setTopicIDs(request *FetchRequest, partitions map[topicPartition]fetchSessionPartition) {
+	for tp := range partitions {
+		id, err := bc.consumer.client.TopicID(tp.topic)
+		if err != nil || id.IsZero() {
+			request.Version = 12
+			request.topicIDs = nil
+			return
+		}
+		request.SetTopicID(tp.topic, id)
+	}
 }
@@ -0,0 +1,145 @@
+package sarama
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// offsetRange is a range of offsets of a partition, from inclusive and to exclusive.
+type offsetRange struct {
+	from, to int64
+}
+
+func (c *consumer) ConsumeBetween(ctx context.Context, topic string, start, end time.Time, fn func(*ConsumerMessage) error) error {
+	if !end.After(start) {
+		return ConfigurationError("ConsumeBetween requires end to be after start")
+	}
+
+	partitions, err := c.client.Partitions(topic)
+	if err != nil {
+		return err
+	}
+	ranges := make(map[int32]offsetRange, len(partitions))
+	for _, partition := range partitions {
+		from, err := c.offsetAtOrAfter(topic, partition, start)
+		if err != nil {
+			return err
+		}
+		to, err := c.offsetAtOrAfter(topic, partition, end)
+		if err != nil {
+			return err
+		}
+		if from < to {
+			ranges[partition] = offsetRange{from: from, to: to}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+	for partition, r := range ranges {
+		wg.Add(1)
+		go withRecover(func(partition int32, r offsetRange) func() {
+			return func() {
+				defer wg.Done()
+				if err := c.consumeRange(ctx, topic, partition, r, fn); err != nil {
+					failOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}(partition, r))
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// offsetAtOrAfter returns the offset of the first message of the partition produced at or after
+// t, or the high water mark if there is none.
+func (c *consumer) offsetAtOrAfter(topic string, partition int32, t time.Time) (int64, error) {
+	offset, err := c.client.GetOffset(topic, partition, t.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return c.client.GetOffset(topic, partition, OffsetNewest)
+	}
+	return offset, nil
+}
+
+// consumeRange calls fn for the messages of the partition within r, returning once the end of
+// the range is reached.
+func (c *consumer) consumeRange(ctx context.Context, topic string, partition int32, r offsetRange, fn func(*ConsumerMessage) error) error {
+	pc, err := c.ConsumePartition(topic, partition, r.from)
+	if err != nil {
+		return err
+	}
+	child := pc.(*partitionConsumer)
+	defer func() { _ = child.Close() }()
+
+	// deliver returns whether the end of the range is reached
+	deliver := func(msg *ConsumerMessage) (bool, error) {
+		if msg.Offset >= r.to {
+			return true, nil
+		}
+		if err := fn(msg); err != nil {
+			return true, err
+		}
+		return msg.Offset+1 >= r.to, nil
+	}
+
+	// records without messages, e.g. transaction markers, may end the range
+	ticker := time.NewTicker(c.conf.Consumer.MaxWaitTime)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case msg, ok := <-child.messages:
+			if !ok {
+				return closedRangeError(lastErr)
+			}
+			if done, err := deliver(msg); done {
+				return err
+			}
+		case batch, ok := <-child.batches:
+			if !ok {
+				return closedRangeError(lastErr)
+			}
+			for _, msg := range batch {
+				if done, err := deliver(msg); done {
+					return err
+				}
+			}
+		case cerr, ok := <-child.errors:
+			if ok {
+				Logger.Println(cerr)
+				lastErr = cerr
+			}
+		case <-ticker.C:
+			if atomic.LoadInt64(&child.position) >= r.to {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// closedRangeError returns the error to report when the partition consumer of a range shut
+// down before reaching its end, which it mostly does because the offsets it consumes were
+// deleted, unless it reported another error.
+func closedRangeError(lastErr error) error {
+	if lastErr != nil {
+		return lastErr
+	}
+	return ErrOffsetOutOfRange
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -14,6 +17,10 @@ import (
 // ErrClosedConsumerGroup is the error returned when a method is called on a consumer group that has been closed.
 var ErrClosedConsumerGroup = errors.New("kafka: tried to use a consumer group that was closed")
 
+// TopicPatternPrefix marks the topics passed to ConsumerGroup.Consume which are regular
+// expressions rather than topic names, e.g. "re:^payments\\..*".
+const TopicPatternPrefix = "re:"
+
 // ConsumerGroup is responsible for dividing up processing of topics and partitions
 // over a collection of processes (the members of the consumer group).
 type ConsumerGroup interface {
@@ -43,6 +50,17 @@ type ConsumerGroup interface {
 	// This method should be called inside an infinite loop, when a
 	// server-side rebalance happens, the consumer session will need to be
 	// recreated to get the new claims.
+	//
+	// When all the configured balance strategies are cooperative (see CooperativeBalanceStrategy),
+	// rebalances don't end the session: the member rejoins the group while its claims keep being
+	// consumed, only the claims of the partitions it loses are stopped, their Messages() channel
+	// being closed, and claims are started for the partitions it gains. Claims() then returns the
+	// latest assignment and GenerationID() the latest generation.
+	//
+	// Topics prefixed with TopicPatternPrefix are regular expressions subscribing to all the
+	// matching topics, internal topics excepted. The metadata of all topics is then refreshed
+	// every Config.Metadata.RefreshFrequency, and the group rebalanced when topics matching
+	// the patterns are created or deleted.
 	Consume(ctx context.Context, topics []string, handler ConsumerGroupHandler) error
 
 	// Errors returns a read channel of errors that occurred during the consumer life-cycle.
@@ -55,6 +73,18 @@ type ConsumerGroup interface {
 	// this function before the object passes out of scope, as it will otherwise leak memory.
 	Close() error
 
+	// CloseGracefully hands the claims of the running session over to the other members of
+	// the group with as little duplicate processing as possible before closing the
+	// ConsumerGroup. Fetching stops and the member first revokes its claims: it commits the
+	// offsets marked so far with auto-commit, and rejoins the group without subscribing to
+	// any topic so that the group rebalances the partitions to the other members. The
+	// messages already fetched keep being processed until they have all been marked, or the
+	// timeout expires. The session then ends, which commits the marked offsets (or lets
+	// Cleanup commit them without auto-commit), and only then does the member leave the
+	// group, as offsets can't be committed once it is no longer part of it. Messages marked
+	// after the claims were revoked may be processed again by their new owners.
+	CloseGracefully(timeout time.Duration) error
+
 	// Pause suspends fetching from the requested partitions. Future calls to the broker will not return any
 	// records from these partitions until they have been resumed using Resume()/ResumeAll().
 	// Note that this method does not affect partition subscription.
@@ -90,6 +120,11 @@ type consumerGroup struct {
 	errorsLock sync.RWMutex
 	closed     chan none
 	closeOnce  sync.Once
+	fenced     chan none
+	fenceOnce  sync.Once
+
+	session     *consumerGroupSession // the running session, if any
+	sessionLock sync.Mutex
 
 	userData []byte
 
@@ -138,18 +173,28 @@ func newConsumerGroup(groupID string, client Client) (ConsumerGroup, error) {
 		groupID:        groupID,
 		errors:         make(chan error, config.ChannelBufferSize),
 		closed:         make(chan none),
+		fenced:         make(chan none),
 		userData:       config.Consumer.Group.Member.UserData,
 		metricRegistry: newCleanupRegistry(config.MetricRegistry),
 	}
 	if client.Config().Consumer.Group.InstanceId != "" && config.Version.IsAtLeast(V2_3_0_0) {
 		cg.groupInstanceId = &client.Config().Consumer.Group.InstanceId
 	}
+	cg.metricRegistry.GetOrRegister(fmt.Sprintf("consumer-group-lag-%s", groupID), metrics.NewFunctionalGauge(cg.lag))
 	return cg, nil
 }
 
 // Errors implements ConsumerGroup.
 func (c *consumerGroup) Errors() <-chan error { return c.errors }
 
+// lag returns the total lag of the partitions consumed by the member.
+func (c *consumerGroup) lag() int64 {
+	if cons, ok := c.consumer.(*consumer); ok {
+		return cons.lag()
+	}
+	return 0
+}
+
 // Close implements ConsumerGroup.
 func (c *consumerGroup) Close() (err error) {
 	c.closeOnce.Do(func() {
@@ -180,8 +225,33 @@ func (c *consumerGroup) Close() (err error) {
 	return
 }
 
+// CloseGracefully implements ConsumerGroup.
+func (c *consumerGroup) CloseGracefully(timeout time.Duration) error {
+	c.sessionLock.Lock()
+	sess := c.session
+	c.sessionLock.Unlock()
+
+	if sess != nil {
+		deadline := time.Now().Add(timeout)
+		claims := sess.Claims()
+		c.consumer.Pause(claims)
+
+		if err := sess.handOff(); err != nil {
+			Logger.Printf(
+				"consumergroup/session/%s/%d failed to revoke its claims: %v\n",
+				sess.MemberID(), sess.GenerationID(), err)
+		}
+		if !sess.drain(claims, time.Until(deadline)) {
+			Logger.Printf(
+				"consumergroup/session/%s/%d not drained after %s, closing anyway\n",
+				sess.MemberID(), sess.GenerationID(), timeout)
+		}
+	}
+	return c.Close()
+}
+
 // Consume implements ConsumerGroup.
-func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler ConsumerGroupHandler) error {
+func (c *consumerGroup) Consume(ctx context.Context, subscriptions []string, handler ConsumerGroupHandler) error {
 	// Ensure group is not closed
 	select {
 	case <-c.closed:
@@ -189,14 +259,30 @@ func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler Co
 	default:
 	}
 
+	// Ensure the static member was not fenced by another instance
+	select {
+	case <-c.fenced:
+		return ErrFencedInstancedId
+	default:
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	// Quick exit when no topics are provided
-	if len(topics) == 0 {
+	if len(subscriptions) == 0 {
 		return fmt.Errorf("no topics provided")
 	}
 
+	// Resolve topic patterns
+	topics, err := c.resolveTopics(subscriptions)
+	if err != nil {
+		return err
+	}
+	if len(topics) == 0 {
+		return fmt.Errorf("no topics match %v", subscriptions)
+	}
+
 	// Refresh metadata for requested topics
 	if err := c.client.RefreshMetadata(topics...); err != nil {
 		return err
@@ -209,17 +295,42 @@ func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler Co
 	} else if err != nil {
 		return err
 	}
+	c.sessionLock.Lock()
+	c.session = sess
+	c.sessionLock.Unlock()
+	defer func() {
+		c.sessionLock.Lock()
+		c.session = nil
+		c.sessionLock.Unlock()
+	}()
 
 	// loop check topic partition numbers changed
 	// will trigger rebalance when any topic partitions number had changed
 	// avoid Consume function called again that will generate more than loopCheckPartitionNumbers coroutine
-	go c.loopCheckPartitionNumbers(topics, sess)
+	go c.loopCheckPartitionNumbers(subscriptions, topics, sess)
+
+	// Cooperative sessions rejoin the group in place when a rebalance is due
+	if sess.cooperative {
+		if err := sess.rebalanceLoop(); err != nil {
+			_ = sess.release(true)
+			return err
+		}
+	}
 
 	// Wait for session exit signal
 	<-sess.ctx.Done()
 
 	// Gracefully release session claims
-	return sess.release(true)
+	if err := sess.release(true); err != nil {
+		return err
+	}
+
+	select {
+	case <-c.fenced:
+		return ErrFencedInstancedId
+	default:
+		return nil
+	}
 }
 
 // Pause implements ConsumerGroup.
@@ -242,10 +353,87 @@ func (c *consumerGroup) ResumeAll() {
 	c.consumer.ResumeAll()
 }
 
-func (c *consumerGroup) retryNewSession(ctx context.Context, topics []string, handler ConsumerGroupHandler, retries int, refreshCoordinator bool) (*consumerGroupSession, error) {
+func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler ConsumerGroupHandler, retries int) (*consumerGroupSession, error) {
+	claims, generationID, err := c.joinGroup(topics, nil, retries)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := newConsumerGroupSession(ctx, c, claims, c.memberID, generationID, handler)
+	if err != nil {
+		return nil, err
+	}
+	sess.topics = topics
+	return sess, nil
+}
+
+// resolveTopics returns the topics to subscribe to, expanding the patterns among the
+// subscriptions passed to Consume into the matching topics once the metadata of all topics
+// has been refreshed.
+func (c *consumerGroup) resolveTopics(subscriptions []string) ([]string, error) {
+	var topics []string
+	var patterns []*regexp.Regexp
+	for _, subscription := range subscriptions {
+		if !strings.HasPrefix(subscription, TopicPatternPrefix) {
+			topics = append(topics, subscription)
+			continue
+		}
+		pattern, err := regexp.Compile(strings.TrimPrefix(subscription, TopicPatternPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic pattern %q: %w", subscription, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if len(patterns) == 0 {
+		return topics, nil
+	}
+
+	if err := c.client.RefreshMetadata(); err != nil {
+		return nil, err
+	}
+	all, err := c.client.Topics()
+	if err != nil {
+		return nil, err
+	}
+	for _, topic := range all {
+		if strings.HasPrefix(topic, "__") {
+			continue // internal topics, such as __consumer_offsets
+		}
+		for _, pattern := range patterns {
+			if pattern.MatchString(topic) && !stringsContains(topics, topic) {
+				topics = append(topics, topic)
+				break
+			}
+		}
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func stringsContains(s []string, value string) bool {
+	for _, entry := range s {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *consumerGroup) retryJoinGroup(topics []string, owned map[string][]int32, retries int, refreshCoordinator bool) (map[string][]int32, int32, error) {
 	select {
 	case <-c.closed:
-		return nil, ErrClosedConsumerGroup
+		return nil, 0, ErrClosedConsumerGroup
 	case <-time.After(c.config.Consumer.Group.Rebalance.Retry.Backoff):
 	}
 
@@ -253,23 +441,26 @@ func (c *consumerGroup) retryNewSession(ctx context.Context, topics []string, ha
 		err := c.client.RefreshCoordinator(c.groupID)
 		if err != nil {
 			if retries <= 0 {
-				return nil, err
+				return nil, 0, err
 			}
-			return c.retryNewSession(ctx, topics, handler, retries-1, true)
+			return c.retryJoinGroup(topics, owned, retries-1, true)
 		}
 	}
 
-	return c.newSession(ctx, topics, handler, retries-1)
+	return c.joinGroup(topics, owned, retries-1)
 }
 
-func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler ConsumerGroupHandler, retries int) (*consumerGroupSession, error) {
+// joinGroup joins the group and syncs it, returning the claims assigned to the member
+// and the generation it joined. Owned lists the partitions the member currently
+// consumes, for cooperative balance strategies to hand them over.
+func (c *consumerGroup) joinGroup(topics []string, owned map[string][]int32, retries int) (map[string][]int32, int32, error) {
 	coordinator, err := c.client.Coordinator(c.groupID)
 	if err != nil {
 		if retries <= 0 {
-			return nil, err
+			return nil, 0, err
 		}
 
-		return c.retryNewSession(ctx, topics, handler, retries, true)
+		return c.retryJoinGroup(topics, owned, retries, true)
 	}
 
 	var (
@@ -288,7 +479,7 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	}
 
 	// Join consumer group
-	join, err := c.joinGroupRequest(coordinator, topics)
+	join, err := c.joinGroupRequest(coordinator, topics, owned)
 	if consumerGroupJoinTotal != nil {
 		consumerGroupJoinTotal.Inc(1)
 	}
@@ -297,7 +488,7 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 		if consumerGroupJoinFailed != nil {
 			consumerGroupJoinFailed.Inc(1)
 		}
-		return nil, err
+		return nil, 0, err
 	}
 	if !errors.Is(join.Err, ErrNoError) {
 		if consumerGroupJoinFailed != nil {
@@ -310,25 +501,23 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	case ErrUnknownMemberId, ErrIllegalGeneration:
 		// reset member ID and retry immediately
 		c.memberID = ""
-		return c.newSession(ctx, topics, handler, retries)
+		return c.joinGroup(topics, owned, retries)
 	case ErrNotCoordinatorForConsumer, ErrRebalanceInProgress, ErrOffsetsLoadInProgress:
 		// retry after backoff
 		if retries <= 0 {
-			return nil, join.Err
+			return nil, 0, join.Err
 		}
-		return c.retryNewSession(ctx, topics, handler, retries, true)
+		return c.retryJoinGroup(topics, owned, retries, true)
 	case ErrMemberIdRequired:
 		// from JoinGroupRequest v4, if client start with empty member id,
 		// it need to get member id from response and send another join request to join group
 		c.memberID = join.MemberId
-		return c.retryNewSession(ctx, topics, handler, retries+1 /*keep retry time*/, false)
+		return c.retryJoinGroup(topics, owned, retries+1 /*keep retry time*/, false)
 	case ErrFencedInstancedId:
-		if c.groupInstanceId != nil {
-			Logger.Printf("JoinGroup failed: group instance id %s has been fenced\n", *c.groupInstanceId)
-		}
-		return nil, join.Err
+		c.fence()
+		return nil, 0, join.Err
 	default:
-		return nil, join.Err
+		return nil, 0, join.Err
 	}
 
 	var strategy BalanceStrategy
@@ -338,27 +527,28 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 		if !ok {
 			// this case shouldn't happen in practice, since the leader will choose the protocol
 			// that all the members support
-			return nil, fmt.Errorf("unable to find selected strategy: %s", join.GroupProtocol)
+			return nil, 0, fmt.Errorf("unable to find selected strategy: %s", join.GroupProtocol)
 		}
 	}
 
 	// Prepare distribution plan if we joined as the leader
 	var plan BalanceStrategyPlan
 	var members map[string]ConsumerGroupMemberMetadata
+	var assignmentUserData map[string][]byte
 	if join.LeaderId == join.MemberId {
 		members, err = join.GetMembers()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
-		plan, err = c.balance(strategy, members)
+		plan, assignmentUserData, err = c.balance(strategy, members)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
 	// Sync consumer group
-	syncGroupResponse, err := c.syncGroupRequest(coordinator, members, plan, join.GenerationId, strategy)
+	syncGroupResponse, err := c.syncGroupRequest(coordinator, members, plan, assignmentUserData, join.GenerationId, strategy)
 	if consumerGroupSyncTotal != nil {
 		consumerGroupSyncTotal.Inc(1)
 	}
@@ -367,7 +557,7 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 		if consumerGroupSyncFailed != nil {
 			consumerGroupSyncFailed.Inc(1)
 		}
-		return nil, err
+		return nil, 0, err
 	}
 	if !errors.Is(syncGroupResponse.Err, ErrNoError) {
 		if consumerGroupSyncFailed != nil {
@@ -380,30 +570,30 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	case ErrUnknownMemberId, ErrIllegalGeneration:
 		// reset member ID and retry immediately
 		c.memberID = ""
-		return c.newSession(ctx, topics, handler, retries)
+		return c.joinGroup(topics, owned, retries)
 	case ErrNotCoordinatorForConsumer, ErrRebalanceInProgress, ErrOffsetsLoadInProgress:
 		// retry after backoff
 		if retries <= 0 {
-			return nil, syncGroupResponse.Err
+			return nil, 0, syncGroupResponse.Err
 		}
-		return c.retryNewSession(ctx, topics, handler, retries, true)
+		return c.retryJoinGroup(topics, owned, retries, true)
 	case ErrFencedInstancedId:
-		if c.groupInstanceId != nil {
-			Logger.Printf("JoinGroup failed: group instance id %s has been fenced\n", *c.groupInstanceId)
-		}
-		return nil, syncGroupResponse.Err
+		c.fence()
+		return nil, 0, syncGroupResponse.Err
 	default:
-		return nil, syncGroupResponse.Err
+		return nil, 0, syncGroupResponse.Err
 	}
 
 	// Retrieve and sort claims
 	var claims map[string][]int32
+	var userData []byte
 	if len(syncGroupResponse.MemberAssignment) > 0 {
 		members, err := syncGroupResponse.GetMemberAssignment()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		claims = members.Topics
+		userData = members.UserData
 
 		// in the case of stateful balance strategies, hold on to the returned
 		// assignment metadata, otherwise, reset the statically defined conusmer
@@ -419,10 +609,14 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 		}
 	}
 
-	return newConsumerGroupSession(ctx, c, claims, join.MemberId, join.GenerationId, handler)
+	if strategy, ok := strategy.(UserDataBalanceStrategy); ok {
+		strategy.OnAssignment(claims, userData, join.GenerationId)
+	}
+
+	return claims, join.GenerationId, nil
 }
 
-func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string) (*JoinGroupResponse, error) {
+func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string, owned map[string][]int32) (*JoinGroupResponse, error) {
 	req := &JoinGroupRequest{
 		GroupId:        c.groupID,
 		MemberId:       c.memberID,
@@ -442,22 +636,51 @@ func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string) (
 		Topics:   topics,
 		UserData: c.userData,
 	}
-	var strategy BalanceStrategy
-	if strategy = c.config.Consumer.Group.Rebalance.Strategy; strategy != nil {
-		if err := req.AddGroupProtocolMetadata(strategy.Name(), meta); err != nil {
-			return nil, err
+	if c.cooperative() {
+		// v1 subscriptions carry the partitions owned by the member (KIP-429)
+		meta.Version = 1
+		for topic, partitions := range owned {
+			meta.OwnedPartitions = append(meta.OwnedPartitions, &OwnedPartition{Topic: topic, Partitions: partitions})
 		}
-	} else {
-		for _, strategy = range c.config.Consumer.Group.Rebalance.GroupStrategies {
-			if err := req.AddGroupProtocolMetadata(strategy.Name(), meta); err != nil {
+	}
+	strategies := c.config.Consumer.Group.Rebalance.GroupStrategies
+	if strategy := c.config.Consumer.Group.Rebalance.Strategy; strategy != nil {
+		strategies = []BalanceStrategy{strategy}
+	}
+	for _, strategy := range strategies {
+		strategyMeta := meta
+		if strategy, ok := strategy.(UserDataBalanceStrategy); ok {
+			userData, err := strategy.SubscriptionUserData(topics)
+			if err != nil {
 				return nil, err
 			}
+			withUserData := *meta
+			withUserData.UserData = userData
+			strategyMeta = &withUserData
+		}
+		if err := req.AddGroupProtocolMetadata(strategy.Name(), strategyMeta); err != nil {
+			return nil, err
 		}
 	}
 
 	return coordinator.JoinGroup(req)
 }
 
+// cooperative reports whether the group follows the cooperative rebalance protocol,
+// which requires all the configured strategies to be cooperative.
+func (c *consumerGroup) cooperative() bool {
+	if strategy := c.config.Consumer.Group.Rebalance.Strategy; strategy != nil {
+		_, ok := strategy.(CooperativeBalanceStrategy)
+		return ok
+	}
+	for _, strategy := range c.config.Consumer.Group.Rebalance.GroupStrategies {
+		if _, ok := strategy.(CooperativeBalanceStrategy); !ok {
+			return false
+		}
+	}
+	return len(c.config.Consumer.Group.Rebalance.GroupStrategies) > 0
+}
+
 // findStrategy returns the BalanceStrategy with the specified protocolName
 // from the slice provided.
 func (c *consumerGroup) findStrategy(name string, groupStrategies []BalanceStrategy) (BalanceStrategy, bool) {
@@ -473,6 +696,7 @@ func (c *consumerGroup) syncGroupRequest(
 	coordinator *Broker,
 	members map[string]ConsumerGroupMemberMetadata,
 	plan BalanceStrategyPlan,
+	assignmentUserData map[string][]byte,
 	generationID int32,
 	strategy BalanceStrategy,
 ) (*SyncGroupResponse, error) {
@@ -488,13 +712,18 @@ func (c *consumerGroup) syncGroupRequest(
 	if c.groupInstanceId != nil {
 		req.GroupInstanceId = c.groupInstanceId
 	}
+	_, withUserData := strategy.(UserDataBalanceStrategy)
 	for memberID, topics := range plan {
 		assignment := &ConsumerGroupMemberAssignment{Topics: topics}
-		userDataBytes, err := strategy.AssignmentData(memberID, topics, generationID)
-		if err != nil {
-			return nil, err
+		if withUserData {
+			assignment.UserData = assignmentUserData[memberID]
+		} else {
+			userDataBytes, err := strategy.AssignmentData(memberID, topics, generationID)
+			if err != nil {
+				return nil, err
+			}
+			assignment.UserData = userDataBytes
 		}
-		assignment.UserData = userDataBytes
 		if err := req.AddGroupAssignmentMember(memberID, assignment); err != nil {
 			return nil, err
 		}
@@ -502,7 +731,8 @@ func (c *consumerGroup) syncGroupRequest(
 	}
 	// add empty assignments for any remaining members
 	for memberID := range members {
-		if err := req.AddGroupAssignmentMember(memberID, &ConsumerGroupMemberAssignment{}); err != nil {
+		assignment := &ConsumerGroupMemberAssignment{UserData: assignmentUserData[memberID]}
+		if err := req.AddGroupAssignmentMember(memberID, assignment); err != nil {
 			return nil, err
 		}
 	}
@@ -524,7 +754,7 @@ func (c *consumerGroup) heartbeatRequest(coordinator *Broker, memberID string, g
 	return coordinator.Heartbeat(req)
 }
 
-func (c *consumerGroup) balance(strategy BalanceStrategy, members map[string]ConsumerGroupMemberMetadata) (BalanceStrategyPlan, error) {
+func (c *consumerGroup) balance(strategy BalanceStrategy, members map[string]ConsumerGroupMemberMetadata) (BalanceStrategyPlan, map[string][]byte, error) {
 	topics := make(map[string][]int32)
 	for _, meta := range members {
 		for _, topic := range meta.Topics {
@@ -535,12 +765,16 @@ func (c *consumerGroup) balance(strategy BalanceStrategy, members map[string]Con
 	for topic := range topics {
 		partitions, err := c.client.Partitions(topic)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		topics[topic] = partitions
 	}
 
-	return strategy.Plan(members, topics)
+	if strategy, ok := strategy.(UserDataBalanceStrategy); ok {
+		return strategy.PlanWithUserData(members, topics)
+	}
+	plan, err := strategy.Plan(members, topics)
+	return plan, nil, err
 }
 
 // Leaves the cluster, called by Close.
@@ -559,12 +793,8 @@ func (c *consumerGroup) leave() error {
 	// KIP-345 if groupInstanceId is set, don not leave group when consumer closed.
 	// Since we do not discover ApiVersion for brokers, LeaveGroupRequest still use the old version request for now
 	if c.groupInstanceId == nil {
-		resp, err := coordinator.LeaveGroup(&LeaveGroupRequest{
-			GroupId:  c.groupID,
-			MemberId: c.memberID,
-		})
+		resp, err := c.leaveGroupRequest(coordinator, c.memberID)
 		if err != nil {
-			_ = coordinator.Close()
 			return err
 		}
 
@@ -584,6 +814,31 @@ func (c *consumerGroup) leave() error {
 	return nil
 }
 
+// leaveGroupRequest sends a LeaveGroupRequest for the given member to the coordinator.
+func (c *consumerGroup) leaveGroupRequest(coordinator *Broker, memberID string) (*LeaveGroupResponse, error) {
+	resp, err := coordinator.LeaveGroup(&LeaveGroupRequest{
+		GroupId:  c.groupID,
+		MemberId: memberID,
+	})
+	if err != nil {
+		_ = coordinator.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// fence records that another member joined the group with the same group instance
+// id, after which the consumer group must not rejoin as it would fence that member
+// in turn.
+func (c *consumerGroup) fence() {
+	c.fenceOnce.Do(func() {
+		if c.groupInstanceId != nil {
+			Logger.Printf("consumergroup/%s group instance id %s has been fenced\n", c.groupID, *c.groupInstanceId)
+		}
+		close(c.fenced)
+	})
+}
+
 func (c *consumerGroup) handleError(err error, topic string, partition int32) {
 	var consumerError *ConsumerError
 	if ok := errors.As(err, &consumerError); !ok && topic != "" && partition > -1 {
@@ -615,7 +870,7 @@ func (c *consumerGroup) handleError(err error, topic string, partition int32) {
 	}
 }
 
-func (c *consumerGroup) loopCheckPartitionNumbers(topics []string, session *consumerGroupSession) {
+func (c *consumerGroup) loopCheckPartitionNumbers(subscriptions, topics []string, session *consumerGroupSession) {
 	if c.config.Metadata.RefreshFrequency == time.Duration(0) {
 		return
 	}
@@ -628,12 +883,35 @@ func (c *consumerGroup) loopCheckPartitionNumbers(topics []string, session *cons
 		return
 	}
 	for {
-		if newTopicToPartitionNum, err := c.topicToPartitionNumbers(topics); err != nil {
+		// rebalance when topics matching the subscribed patterns are created or deleted
+		newTopics, err := c.resolveTopics(subscriptions)
+		if err != nil {
+			return
+		}
+		if !stringSlicesEqual(newTopics, topics) {
+			Logger.Printf(
+				"consumergroup/%s subscribed topics changed from %s to %s\n",
+				c.groupID, topics, newTopics)
+			if !session.cooperative || len(newTopics) == 0 {
+				return // trigger the end of the session on exit
+			}
+			topics = newTopics
+			session.setTopics(topics)
+			session.requestRebalance(session.GenerationID())
+			if oldTopicToPartitionNum, err = c.topicToPartitionNumbers(topics); err != nil {
+				return
+			}
+		} else if newTopicToPartitionNum, err := c.topicToPartitionNumbers(topics); err != nil {
 			return
 		} else {
 			for topic, num := range oldTopicToPartitionNum {
 				if newTopicToPartitionNum[topic] != num {
-					return // trigger the end of the session on exit
+					if !session.cooperative {
+						return // trigger the end of the session on exit
+					}
+					session.requestRebalance(session.GenerationID())
+					oldTopicToPartitionNum = newTopicToPartitionNum
+					break
 				}
 			}
 		}
@@ -673,10 +951,15 @@ type ConsumerGroupSession interface {
 	// Claims returns information about the claimed partitions by topic.
 	Claims() map[string][]int32
 
+	// GroupID returns the ID of the consumer group.
+	GroupID() string
+
 	// MemberID returns the cluster member ID.
 	MemberID() string
 
-	// GenerationID returns the current generation ID.
+	// GenerationID returns the current generation ID. Generations increase with each
+	// rebalance of the group, see FencingToken to fence the writes of members of
+	// previous generations.
 	GenerationID() int32
 
 	// MarkOffset marks the provided offset, alongside a metadata string
@@ -699,6 +982,12 @@ type ConsumerGroupSession interface {
 	// Note: calling Commit performs a blocking synchronous operation.
 	Commit()
 
+	// CommitAsync commits the offsets to the backend in the background, retrying
+	// failed commits as configured by Consumer.Offsets.Retry, then calls the
+	// callback, if not nil, with the committed offsets and the last error if some
+	// could not be committed.
+	CommitAsync(callback OffsetCommitCallback)
+
 	// ResetOffset resets to the provided offset, alongside a metadata string that
 	// represents the state of the partition consumer at that point in time. Reset
 	// acts as a counterpart to MarkOffset, the difference being that it allows to
@@ -709,8 +998,31 @@ type ConsumerGroupSession interface {
 	// MarkMessage marks a message as consumed.
 	MarkMessage(msg *ConsumerMessage, metadata string)
 
+	// ResetOffsetsToTimestamp resets the offsets of all the claimed partitions to the
+	// first messages produced at or after the given timestamp, or to the newest offsets
+	// for the partitions without such messages, cf ResetOffset. It should be called from
+	// ConsumerGroupHandler.Setup so that claims start consuming at these offsets, claims
+	// already being consumed only pick them up in the next session.
+	ResetOffsetsToTimestamp(timestamp time.Time) error
+
 	// Context returns the session context.
 	Context() context.Context
+
+	// Pause suspends fetching from the requested partitions of the session's claims, e.g. to
+	// apply backpressure while a downstream system is saturated. Paused claims keep their
+	// Messages() channel open and the member keeps heartbeating, so pausing neither causes
+	// a rebalance nor lets the session time out. Note that pausing does not extend
+	// Config.Consumer.Group.Rebalance.Timeout once a rebalance has begun.
+	Pause(partitions map[string][]int32)
+
+	// Resume resumes the partitions which have been paused with Pause()/PauseAll().
+	Resume(partitions map[string][]int32)
+
+	// PauseAll suspends fetching from all the partitions of the session's claims.
+	PauseAll()
+
+	// ResumeAll resumes all the partitions which have been paused with Pause()/PauseAll().
+	ResumeAll()
 }
 
 type consumerGroupSession struct {
@@ -724,6 +1036,17 @@ type consumerGroupSession struct {
 	ctx     context.Context
 	cancel  func()
 
+	// cooperative sessions outlive rebalances, see rejoin
+	cooperative bool
+	rebalance   chan int32 // generations for which a rebalance was requested
+	rejoining   bool
+	topics      []string // the topics subscribed to when rejoining
+	claimStops  map[topicPartition]func()
+	lost        bool       // whether the member lost its membership of the group
+	handedOff   bool       // whether the member revoked its claims to close, see handOff
+	lock        sync.Mutex // protects memberID, generationID, claims, rejoining, topics, lost and handedOff
+	joinLock    sync.Mutex // serializes rejoin and handOff
+
 	waitGroup       sync.WaitGroup
 	releaseOnce     sync.Once
 	hbDying, hbDead chan none
@@ -734,13 +1057,19 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 	ctx, cancel := context.WithCancel(ctx)
 
 	// init offset manager
-	offsets, err := newOffsetManagerFromClient(parent.groupID, memberID, generationID, parent.client, cancel)
+	// the offset manager ends the session when the member gets fenced
+	var sess *consumerGroupSession
+	offsets, err := newOffsetManagerFromClient(parent.groupID, memberID, generationID, parent.client, func() {
+		parent.fence()
+		sess.markLost()
+		cancel()
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// init session
-	sess := &consumerGroupSession{
+	sess = &consumerGroupSession{
 		parent:       parent,
 		memberID:     memberID,
 		generationID: generationID,
@@ -749,6 +1078,9 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 		claims:       claims,
 		ctx:          ctx,
 		cancel:       cancel,
+		cooperative:  parent.cooperative(),
+		rebalance:    make(chan int32, 1),
+		claimStops:   make(map[topicPartition]func()),
 		hbDying:      make(chan none),
 		hbDead:       make(chan none),
 	}
@@ -756,21 +1088,18 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 	// start heartbeat loop
 	go sess.heartbeatLoop()
 
+	// watch processing, independently of heartbeats
+	if parent.config.Consumer.Group.MaxProcessingInterval > 0 || parent.config.Consumer.Group.StuckClaim.Timeout > 0 {
+		go sess.processingWatchdog()
+	}
+
 	// create a POM for each claim
 	for topic, partitions := range claims {
 		for _, partition := range partitions {
-			pom, err := offsets.ManagePartition(topic, partition)
-			if err != nil {
+			if err := sess.manage(topic, partition); err != nil {
 				_ = sess.release(false)
 				return nil, err
 			}
-
-			// handle POM errors
-			go func(topic string, partition int32) {
-				for err := range pom.Errors() {
-					sess.parent.handleError(err, topic, partition)
-				}
-			}(topic, partition)
 		}
 	}
 
@@ -779,30 +1108,81 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 		_ = sess.release(true)
 		return nil, err
 	}
+	sess.notifyAssigned(claims)
 
 	// start consuming
 	for topic, partitions := range claims {
 		for _, partition := range partitions {
-			sess.waitGroup.Add(1)
-
-			go func(topic string, partition int32) {
-				defer sess.waitGroup.Done()
+			sess.startClaim(topic, partition)
+		}
+	}
+	return sess, nil
+}
 
-				// cancel the as session as soon as the first
-				// goroutine exits
-				defer sess.cancel()
+// manage creates the POM of a claimed partition.
+func (s *consumerGroupSession) manage(topic string, partition int32) error {
+	pom, err := s.offsets.ManagePartition(topic, partition)
+	if err != nil {
+		return err
+	}
 
-				// consume a single topic/partition, blocking
-				sess.consume(topic, partition)
-			}(topic, partition)
+	// handle POM errors
+	go func() {
+		for err := range pom.Errors() {
+			s.parent.handleError(err, topic, partition)
 		}
+	}()
+	return nil
+}
+
+// startClaim starts consuming a claimed partition in a separate goroutine.
+func (s *consumerGroupSession) startClaim(topic string, partition int32) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan none)
+	s.claimStops[topicPartition{topic: topic, partition: partition}] = func() {
+		cancel()
+		<-done
 	}
-	return sess, nil
+
+	s.waitGroup.Add(1)
+	go func() {
+		defer s.waitGroup.Done()
+		defer close(done)
+
+		// cancel the as session as soon as the first goroutine exits,
+		// unless its claim was revoked by a cooperative rebalance
+		defer func() {
+			if ctx.Err() == nil || s.ctx.Err() != nil {
+				s.cancel()
+			}
+		}()
+
+		// consume a single topic/partition, blocking
+		s.consume(ctx, topic, partition)
+	}()
+}
+
+func (s *consumerGroupSession) Claims() map[string][]int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.claims
+}
+
+func (s *consumerGroupSession) GroupID() string {
+	return s.parent.groupID
 }
 
-func (s *consumerGroupSession) Claims() map[string][]int32 { return s.claims }
-func (s *consumerGroupSession) MemberID() string           { return s.memberID }
-func (s *consumerGroupSession) GenerationID() int32        { return s.generationID }
+func (s *consumerGroupSession) MemberID() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.memberID
+}
+
+func (s *consumerGroupSession) GenerationID() int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.generationID
+}
 
 func (s *consumerGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
 	if pom := s.offsets.findPOM(topic, partition); pom != nil {
@@ -814,6 +1194,10 @@ func (s *consumerGroupSession) Commit() {
 	s.offsets.Commit()
 }
 
+func (s *consumerGroupSession) CommitAsync(callback OffsetCommitCallback) {
+	s.offsets.CommitAsync(callback)
+}
+
 func (s *consumerGroupSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
 	if pom := s.offsets.findPOM(topic, partition); pom != nil {
 		pom.ResetOffset(offset, metadata)
@@ -824,14 +1208,45 @@ func (s *consumerGroupSession) MarkMessage(msg *ConsumerMessage, metadata string
 	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
 }
 
+func (s *consumerGroupSession) ResetOffsetsToTimestamp(timestamp time.Time) error {
+	for topic, partitions := range s.Claims() {
+		offsets, err := s.parent.client.GetOffsetsForTimestamp(topic, timestamp)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			if offset, ok := offsets[partition]; ok {
+				s.ResetOffset(topic, partition, offset, "")
+			}
+		}
+	}
+	return nil
+}
+
 func (s *consumerGroupSession) Context() context.Context {
 	return s.ctx
 }
 
-func (s *consumerGroupSession) consume(topic string, partition int32) {
+func (s *consumerGroupSession) Pause(partitions map[string][]int32) {
+	s.parent.consumer.Pause(partitions)
+}
+
+func (s *consumerGroupSession) Resume(partitions map[string][]int32) {
+	s.parent.consumer.Resume(partitions)
+}
+
+func (s *consumerGroupSession) PauseAll() {
+	s.parent.consumer.Pause(s.Claims())
+}
+
+func (s *consumerGroupSession) ResumeAll() {
+	s.parent.consumer.Resume(s.Claims())
+}
+
+func (s *consumerGroupSession) consume(ctx context.Context, topic string, partition int32) {
 	// quick exit if rebalance is due
 	select {
-	case <-s.ctx.Done():
+	case <-ctx.Done():
 		return
 	case <-s.parent.closed:
 		return
@@ -858,10 +1273,10 @@ func (s *consumerGroupSession) consume(topic string, partition int32) {
 		}
 	}()
 
-	// trigger close when session is done
+	// trigger close when session is done or the claim is revoked
 	go func() {
 		select {
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 		case <-s.parent.closed:
 		}
 		claim.AsyncClose()
@@ -889,6 +1304,7 @@ func (s *consumerGroupSession) release(withCleanup bool) (err error) {
 	// perform release
 	s.releaseOnce.Do(func() {
 		if withCleanup {
+			s.notifyReleased()
 			if e := s.handler.Cleanup(s); e != nil {
 				s.parent.handleError(e, "", -1)
 				err = e
@@ -910,6 +1326,403 @@ func (s *consumerGroupSession) release(withCleanup bool) (err error) {
 	return
 }
 
+// handOff revokes the claims of a closing member without ending the session: the member
+// rejoins the group without subscribing to any topic, which rebalances its partitions to
+// the other members, and keeps consuming the messages already fetched meanwhile.
+func (s *consumerGroupSession) handOff() error {
+	s.joinLock.Lock()
+	defer s.joinLock.Unlock()
+
+	if s.parent.config.Consumer.Offsets.AutoCommit.Enable {
+		s.offsets.Commit()
+	}
+
+	s.lock.Lock()
+	s.rejoining, s.handedOff = true, true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		s.rejoining = false
+		s.lock.Unlock()
+	}()
+
+	_, generationID, err := s.parent.joinGroup(nil, nil, s.parent.config.Consumer.Group.Rebalance.Retry.Max)
+	if err != nil {
+		return err
+	}
+	memberID := s.parent.memberID
+
+	s.lock.Lock()
+	s.memberID, s.generationID = memberID, generationID
+	s.lock.Unlock()
+	s.offsets.setMember(memberID, generationID)
+
+	Logger.Printf(
+		"consumergroup/session/%s/%d revoked its claims\n",
+		memberID, generationID)
+	return nil
+}
+
+// drain waits for all the messages fetched for the given paused claims to be marked, until
+// the timeout expires. It returns whether the claims were drained.
+func (s *consumerGroupSession) drain(claims map[string][]int32, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(drainPollInterval)
+	defer poll.Stop()
+
+	for !s.drained(claims) {
+		select {
+		case <-poll.C:
+		case <-deadline.C:
+			return false
+		case <-s.ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// drainPollInterval is how often drain checks whether the fetched messages have been marked.
+const drainPollInterval = 10 * time.Millisecond
+
+// drained returns whether the offsets marked for the claims cover all the messages fetched
+// to be delivered, and no fetch for them is in progress.
+func (s *consumerGroupSession) drained(claims map[string][]int32) bool {
+	cons, ok := s.parent.consumer.(*consumer)
+	if !ok {
+		return true
+	}
+
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			child := cons.child(topic, partition)
+			pom := s.offsets.findPOM(topic, partition)
+			if child == nil || pom == nil {
+				continue
+			}
+			if atomic.LoadInt32(&child.fetching) != 0 {
+				return false
+			}
+			fetched := atomic.LoadInt64(&child.fetched)
+			if next, _ := pom.NextOffset(); fetched >= 0 && next < fetched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rebalanceLoop rejoins the group each time a rebalance is requested, until the
+// session is done.
+func (s *consumerGroupSession) rebalanceLoop() error {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case generationID := <-s.rebalance:
+			if generationID != s.GenerationID() {
+				continue // the group was rejoined since
+			}
+			s.lock.Lock()
+			topics := s.topics
+			s.lock.Unlock()
+			if err := s.rejoin(topics); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// setTopics changes the topics subscribed to by the next rejoins of a cooperative session.
+func (s *consumerGroupSession) setTopics(topics []string) {
+	s.lock.Lock()
+	s.topics = topics
+	s.lock.Unlock()
+}
+
+// requestRebalance asks a cooperative session to rejoin the group, the request is
+// dropped if the session has moved past the given generation by the time it runs.
+func (s *consumerGroupSession) requestRebalance(generationID int32) {
+	select {
+	case s.rebalance <- generationID:
+	default:
+	}
+}
+
+// rejoin runs the cooperative rebalance protocol of KIP-429: the member rejoins
+// the group while consuming the partitions it owns, then revokes the partitions
+// missing from its new assignment and starts consuming the ones added to it. If
+// any partition was revoked, the member rejoins again straight away so they can
+// be assigned to their new owners.
+func (s *consumerGroupSession) rejoin(topics []string) error {
+	s.joinLock.Lock()
+	defer s.joinLock.Unlock()
+
+	s.lock.Lock()
+	if s.handedOff {
+		s.lock.Unlock()
+		return nil // the closing member no longer takes part in rebalances
+	}
+	s.rejoining = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		s.rejoining = false
+		s.lock.Unlock()
+	}()
+
+	for {
+		owned := s.Claims()
+		claims, generationID, err := s.parent.joinGroup(topics, owned, s.parent.config.Consumer.Group.Rebalance.Retry.Max)
+		if err != nil {
+			return err
+		}
+		memberID := s.parent.memberID
+
+		s.lock.Lock()
+		s.memberID, s.generationID, s.claims = memberID, generationID, claims
+		s.lock.Unlock()
+		s.offsets.setMember(memberID, generationID)
+
+		revoked := subtractClaims(owned, claims)
+		s.revoke(revoked)
+
+		assigned := subtractClaims(claims, owned)
+		for topic, partitions := range assigned {
+			for _, partition := range partitions {
+				if err := s.manage(topic, partition); err != nil {
+					return err
+				}
+			}
+		}
+		s.notifyAssigned(assigned)
+		for topic, partitions := range assigned {
+			for _, partition := range partitions {
+				s.startClaim(topic, partition)
+			}
+		}
+
+		Logger.Printf(
+			"consumergroup/session/%s/%d rejoined, %d partitions revoked\n",
+			memberID, generationID, len(revoked))
+
+		if len(revoked) == 0 {
+			return nil
+		}
+	}
+}
+
+// revoke stops consuming the given partitions and commits their offsets one last time.
+func (s *consumerGroupSession) revoke(partitions map[string][]int32) {
+	if len(partitions) == 0 {
+		return
+	}
+
+	for topic, partitions := range partitions {
+		for _, partition := range partitions {
+			tp := topicPartition{topic: topic, partition: partition}
+			if stop := s.claimStops[tp]; stop != nil {
+				stop()
+				delete(s.claimStops, tp)
+			}
+			if pom := s.offsets.findPOM(topic, partition); pom != nil {
+				pom.AsyncClose()
+			}
+		}
+	}
+
+	if listener, ok := s.handler.(ConsumerGroupRebalanceListener); ok {
+		listener.OnPartitionsRevoked(s, partitions)
+	}
+
+	// flush the offsets of the revoked partitions and release their POMs, even if
+	// the commit failed as the partitions now belong to other members
+	s.offsets.Commit()
+	s.offsets.releasePOMs(true)
+}
+
+// processingWatchdog watches claims going without any message being received while messages are
+// waiting, reporting them once stuck for Consumer.Group.StuckClaim.Timeout, and leaving the group
+// and ending the session once stuck for Consumer.Group.MaxProcessingInterval.
+func (s *consumerGroupSession) processingWatchdog() {
+	cons, ok := s.parent.consumer.(*consumer)
+	if !ok {
+		return
+	}
+
+	type claimProgress struct {
+		received int64
+		since    time.Time
+		reported bool
+	}
+	progress := make(map[topicPartition]claimProgress)
+
+	interval := s.parent.config.Consumer.Group.MaxProcessingInterval
+	stuck := s.parent.config.Consumer.Group.StuckClaim
+	check := s.parent.config.Consumer.Group.Heartbeat.Interval
+	for _, timeout := range []time.Duration{interval, stuck.Timeout} {
+		if timeout > 0 && timeout/4 < check {
+			check = timeout / 4
+		}
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for topic, partitions := range s.Claims() {
+				for _, partition := range partitions {
+					child := cons.child(topic, partition)
+					if child == nil {
+						continue
+					}
+					tp := topicPartition{topic: topic, partition: partition}
+					received, waiting := child.progress()
+					last, ok := progress[tp]
+					if !ok || !waiting || received != last.received {
+						progress[tp] = claimProgress{received: received, since: now}
+						continue
+					}
+					stalled := now.Sub(last.since)
+					if stuck.Timeout > 0 && stalled > stuck.Timeout && !last.reported {
+						last.reported = true
+						progress[tp] = last
+						s.reportStuck(topic, partition, stalled)
+						if stuck.Leave {
+							s.leaveStuck(topic, partition, stalled, ErrClaimStuck)
+							return
+						}
+					}
+					if interval > 0 && stalled > interval {
+						s.leaveStuck(topic, partition, interval, ErrMaxProcessingIntervalExceeded)
+						return
+					}
+				}
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// reportStuck reports the claim of the given partition as stuck for the given duration, through
+// the Errors channel and to the handler if it implements ConsumerGroupStuckClaimListener.
+func (s *consumerGroupSession) reportStuck(topic string, partition int32, stalled time.Duration) {
+	Logger.Printf(
+		"consumergroup/session/%s/%d claim of %s/%d made no progress for %s\n",
+		s.MemberID(), s.GenerationID(), topic, partition, stalled)
+
+	if listener, ok := s.handler.(ConsumerGroupStuckClaimListener); ok {
+		listener.OnClaimStuck(s, topic, partition, stalled)
+	}
+	if !s.parent.config.Consumer.Group.StuckClaim.Leave {
+		s.parent.handleError(ErrClaimStuck, topic, partition)
+	}
+}
+
+// leaveStuck leaves the group because the claim of the given partition made no progress for the
+// given duration, reports err and ends the session.
+func (s *consumerGroupSession) leaveStuck(topic string, partition int32, stalled time.Duration, err error) {
+	memberID, generationID := s.MemberID(), s.GenerationID()
+	Logger.Printf(
+		"consumergroup/session/%s/%d processing of %s/%d stalled for %s, leaving the group\n",
+		memberID, generationID, topic, partition, stalled)
+
+	s.markLost()
+	if s.parent.groupInstanceId == nil {
+		coordinator, err := s.parent.client.Coordinator(s.parent.groupID)
+		if err == nil {
+			_, err = s.parent.leaveGroupRequest(coordinator, memberID)
+		}
+		if err != nil {
+			Logger.Printf(
+				"consumergroup/session/%s/%d unable to leave the group: %v\n",
+				memberID, generationID, err)
+		}
+	}
+	s.parent.handleError(err, topic, partition)
+	s.cancel()
+}
+
+// notifyAssigned tells the handler about the partitions assigned to the member, if it
+// implements ConsumerGroupRebalanceListener.
+func (s *consumerGroupSession) notifyAssigned(partitions map[string][]int32) {
+	listener, ok := s.handler.(ConsumerGroupRebalanceListener)
+	if !ok || len(partitions) == 0 {
+		return
+	}
+	listener.OnPartitionsAssigned(s, partitions)
+}
+
+// notifyReleased tells the handler about the partitions owned by the member at the end
+// of the session, as revoked or as lost if the member is no longer part of the group.
+func (s *consumerGroupSession) notifyReleased() {
+	listener, ok := s.handler.(ConsumerGroupRebalanceListener)
+	if !ok {
+		return
+	}
+
+	s.lock.Lock()
+	claims, lost := s.claims, s.lost
+	s.lock.Unlock()
+	if len(claims) == 0 {
+		return
+	}
+
+	if lost {
+		listener.OnPartitionsLost(s, claims)
+	} else {
+		listener.OnPartitionsRevoked(s, claims)
+	}
+}
+
+// markLost records that the member lost its membership of the group, so that its
+// partitions are reported as lost rather than revoked.
+func (s *consumerGroupSession) markLost() {
+	s.lock.Lock()
+	s.lost = true
+	s.lock.Unlock()
+}
+
+// isHandedOff reports whether the member revoked its claims to close.
+func (s *consumerGroupSession) isHandedOff() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.handedOff
+}
+
+// rejoinedSince reports whether the session is rejoining or has rejoined the group
+// since the given generation, which only eager sessions do when handing off their claims.
+func (s *consumerGroupSession) rejoinedSince(generationID int32) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.rejoining || s.generationID != generationID
+}
+
+// subtractClaims returns the partitions of a that are not in b.
+func subtractClaims(a, b map[string][]int32) map[string][]int32 {
+	diff := make(map[string][]int32)
+	for topic, partitions := range a {
+		for _, partition := range partitions {
+			if !int32sContains(b[topic], partition) {
+				diff[topic] = append(diff[topic], partition)
+			}
+		}
+	}
+	return diff
+}
+
+func int32sContains(s []int32, value int32) bool {
+	for _, entry := range s {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *consumerGroupSession) heartbeatLoop() {
 	defer close(s.hbDead)
 	defer s.cancel() // trigger the end of the session on exit
@@ -927,6 +1740,20 @@ func (s *consumerGroupSession) heartbeatLoop() {
 
 	retries := s.parent.config.Metadata.Retry.Max
 	for {
+		s.lock.Lock()
+		memberID, generationID, rejoining := s.memberID, s.generationID, s.rejoining
+		s.lock.Unlock()
+
+		// heartbeats resume once a cooperative session has rejoined the group
+		if rejoining {
+			select {
+			case <-pause.C:
+				continue
+			case <-s.hbDying:
+				return
+			}
+		}
+
 		coordinator, err := s.parent.client.Coordinator(s.parent.groupID)
 		if err != nil {
 			if retries <= 0 {
@@ -943,7 +1770,7 @@ func (s *consumerGroupSession) heartbeatLoop() {
 			continue
 		}
 
-		resp, err := s.parent.heartbeatRequest(coordinator, s.memberID, s.generationID)
+		resp, err := s.parent.heartbeatRequest(coordinator, memberID, generationID)
 		if err != nil {
 			_ = coordinator.Close()
 
@@ -961,13 +1788,23 @@ func (s *consumerGroupSession) heartbeatLoop() {
 			retries = s.parent.config.Metadata.Retry.Max
 		case ErrRebalanceInProgress:
 			retries = s.parent.config.Metadata.Retry.Max
-			s.cancel()
+			if s.isHandedOff() {
+				break // the closing member no longer takes part in rebalances
+			}
+			if s.cooperative {
+				s.requestRebalance(generationID)
+			} else {
+				s.cancel()
+			}
 		case ErrUnknownMemberId, ErrIllegalGeneration:
+			if s.rejoinedSince(generationID) {
+				break // the heartbeat raced with the session rejoining the group
+			}
+			s.markLost()
 			return
 		case ErrFencedInstancedId:
-			if s.parent.groupInstanceId != nil {
-				Logger.Printf("JoinGroup failed: group instance id %s has been fenced\n", *s.parent.groupInstanceId)
-			}
+			s.parent.fence()
+			s.markLost()
 			s.parent.handleError(resp.Err, "", -1)
 			return
 		default:
@@ -1005,6 +1842,50 @@ type ConsumerGroupHandler interface {
 	ConsumeClaim(ConsumerGroupSession, ConsumerGroupClaim) error
 }
 
+// noopSetupCleanup implements the Setup and Cleanup hooks of ConsumerGroupHandler doing nothing,
+// for the handlers only consuming claims to embed.
+type noopSetupCleanup struct{}
+
+// Setup implements ConsumerGroupHandler.
+func (noopSetupCleanup) Setup(ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup implements ConsumerGroupHandler.
+func (noopSetupCleanup) Cleanup(ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumerGroupRebalanceListener can be implemented by a ConsumerGroupHandler to be told
+// which partitions are assigned to and taken from the member, e.g. to load or flush the
+// state it keeps per partition. The callbacks are only called with non-empty sets.
+type ConsumerGroupRebalanceListener interface {
+	// OnPartitionsAssigned is called with the partitions assigned to the member, after
+	// Setup and before ConsumeClaim is started for them. With a cooperative strategy, it
+	// is also called with the partitions added to the assignment on each rebalance.
+	OnPartitionsAssigned(sess ConsumerGroupSession, partitions map[string][]int32)
+
+	// OnPartitionsRevoked is called with the partitions taken from the member, once their
+	// ConsumeClaim goroutines have exited but before their offsets are committed for the
+	// last time. At the end of a session, it is called before Cleanup.
+	OnPartitionsRevoked(sess ConsumerGroupSession, partitions map[string][]int32)
+
+	// OnPartitionsLost is called instead of OnPartitionsRevoked when the session ended
+	// because the member was no longer part of the group, e.g. after it was evicted or
+	// fenced. The partitions may already be owned by other members, so offsets marked in
+	// the callback will likely fail to commit.
+	OnPartitionsLost(sess ConsumerGroupSession, partitions map[string][]int32)
+}
+
+// ConsumerGroupStuckClaimListener can be implemented by a ConsumerGroupHandler to be told
+// about claims it makes no progress on, see Consumer.Group.StuckClaim.
+type ConsumerGroupStuckClaimListener interface {
+	// OnClaimStuck is called, from another goroutine than ConsumeClaim, once the claim of
+	// the given partition received no message for stalled while messages were waiting.
+	// It is called again for the claim only after it made progress.
+	OnClaimStuck(sess ConsumerGroupSession, topic string, partition int32, stalled time.Duration)
+}
+
 // ConsumerGroupClaim processes Kafka messages from a given topic and partition within a consumer group.
 type ConsumerGroupClaim interface {
 	// Topic returns the consumed topic name.
@@ -1021,25 +1902,48 @@ type ConsumerGroupClaim interface {
 	// You can use this to determine how far behind the processing is.
 	HighWaterMarkOffset() int64
 
+	// LastStableOffset returns the last stable offset of the partition, i.e. the
+	// offset up to which all transactions have been completed, or -1 if unknown.
+	LastStableOffset() int64
+
+	// Lag returns the number of messages between the offset marked for this claim
+	// (or the committed one, if none was marked yet) and the end of the partition,
+	// which is the last stable offset with the ReadCommitted isolation level and the
+	// high water mark otherwise. It returns -1 if it can't be computed yet, e.g. when
+	// there is no marked offset and the claim was started from OffsetNewest.
+	Lag() int64
+
 	// Messages returns the read channel for the messages that are returned by
 	// the broker. The messages channel will be closed when a new rebalance cycle
 	// is due. You must finish processing and mark offsets within
 	// Config.Consumer.Group.Session.Timeout before the topic/partition is eventually
 	// re-assigned to another group member.
 	Messages() <-chan *ConsumerMessage
+
+	// Batches returns the read channel for the batches of messages returned by the
+	// broker when Consumer.Return.Batches is enabled, in which case Messages is not
+	// used. Like the messages channel, it is closed when a new rebalance cycle is due.
+	Batches() <-chan []*ConsumerMessage
+
+	// EOF returns the read channel for the PartitionEOF events sent each time the end
+	// of the partition is reached, when Consumer.Return.PartitionEOF is enabled. Like
+	// the messages channel, it is closed when a new rebalance cycle is due.
+	EOF() <-chan *PartitionEOF
 }
 
 type consumerGroupClaim struct {
 	topic     string
 	partition int32
 	offset    int64
+	sess      *consumerGroupSession
 	PartitionConsumer
 }
 
 func newConsumerGroupClaim(sess *consumerGroupSession, topic string, partition int32, offset int64) (*consumerGroupClaim, error) {
 	pcm, err := sess.parent.consumer.ConsumePartition(topic, partition, offset)
 
-	if errors.Is(err, ErrOffsetOutOfRange) && sess.parent.config.Consumer.Group.ResetInvalidOffsets {
+	if errors.Is(err, ErrOffsetOutOfRange) && sess.parent.config.Consumer.Group.ResetInvalidOffsets &&
+		sess.parent.config.Consumer.Offsets.Initial != OffsetFail {
 		offset = sess.parent.config.Consumer.Offsets.Initial
 		pcm, err = sess.parent.consumer.ConsumePartition(topic, partition, offset)
 	}
@@ -1057,20 +1961,78 @@ func newConsumerGroupClaim(sess *consumerGroupSession, topic string, partition i
 		topic:             topic,
 		partition:         partition,
 		offset:            offset,
+		sess:              sess,
 		PartitionConsumer: pcm,
 	}, nil
 }
 
+// claimMessages returns the channel the messages of the claim are read from one at a time,
+// flattening the batches of the claim when Consumer.Return.Batches is enabled for it. The
+// returned function stops forwarding the batches and must be called once done reading.
+func claimMessages(sess ConsumerGroupSession, claim ConsumerGroupClaim) (<-chan *ConsumerMessage, func()) {
+	c, ok := claim.(*consumerGroupClaim)
+	if !ok || !c.sess.parent.config.Consumer.Return.Batches {
+		return claim.Messages(), func() {}
+	}
+
+	messages := make(chan *ConsumerMessage, c.sess.parent.config.ChannelBufferSize)
+	stop := make(chan none)
+	go func() {
+		defer close(messages)
+		for batch := range claim.Batches() {
+			for _, msg := range batch {
+				select {
+				case messages <- msg:
+				case <-stop:
+					return
+				case <-sess.Context().Done():
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return messages, func() { once.Do(func() { close(stop) }) }
+}
+
 func (c *consumerGroupClaim) Topic() string        { return c.topic }
 func (c *consumerGroupClaim) Partition() int32     { return c.partition }
 func (c *consumerGroupClaim) InitialOffset() int64 { return c.offset }
 
+func (c *consumerGroupClaim) Lag() int64 {
+	end := c.HighWaterMarkOffset()
+	if c.sess.parent.config.isolationLevel(c.topic) == ReadCommitted {
+		end = c.LastStableOffset()
+	}
+
+	next := c.offset
+	if pom := c.sess.offsets.findPOM(c.topic, c.partition); pom != nil {
+		next, _ = pom.NextOffset()
+	}
+
+	if end < 0 || next < 0 {
+		return -1
+	}
+	if next > end {
+		return 0
+	}
+	return end - next
+}
+
 // Drains messages and errors, ensures the claim is fully closed.
 func (c *consumerGroupClaim) waitClosed() (errs ConsumerErrors) {
 	go func() {
 		for range c.Messages() {
 		}
 	}()
+	go func() {
+		for range c.Batches() {
+		}
+	}()
+	go func() {
+		for range c.EOF() {
+		}
+	}()
 
 	for err := range c.Errors() {
 		errs = append(errs, err)
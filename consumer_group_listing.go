@@ -0,0 +1,33 @@
+package sarama
+
+// ListConsumerGroupsOptions are the filters of ClusterAdmin.ListConsumerGroupsWithOptions.
+type ListConsumerGroupsOptions struct {
+	// States are the states of the groups to list, such as "Stable" or "Empty", all if empty.
+	// Filtering by state requires Version >= V2_6_0_0.
+	States []string
+	// Types are the types of the groups to list, "classic" or "consumer", all if empty.
+	// Filtering by type requires Version >= V3_8_0_0.
+	Types []string
+}
+
+// ConsumerGroupListing is a group listed by ClusterAdmin.ListConsumerGroupsWithOptions.
+type ConsumerGroupListing struct {
+	GroupID      string
+	ProtocolType string
+	// State is the state of the group, or empty if the brokers are older than 2.6.0.0.
+	State string
+	// Type is the type of the group, or empty if the brokers are older than 3.8.0.0.
+	Type string
+	// CoordinatorID is the ID of the broker coordinating the group.
+	CoordinatorID int32
+}
+
+// ConsumerGroupListingReport are the groups of the brokers that answered
+// ClusterAdmin.ListConsumerGroupsWithOptions and the errors of the others.
+type ConsumerGroupListingReport struct {
+	// Groups are the groups listed, sorted by group ID.
+	Groups []ConsumerGroupListing
+	// BrokerErrors are the errors of the brokers that failed to list their groups, by broker
+	// ID, the groups they coordinate missing from Groups.
+	BrokerErrors map[int32]error
+}
@@ -22,6 +22,17 @@ func (m *ConsumerGroupMemberMetadata) encode(pe packetEncoder) error {
 		return err
 	}
 
+	if m.Version >= 1 {
+		if err := pe.putArrayLength(len(m.OwnedPartitions)); err != nil {
+			return err
+		}
+		for _, op := range m.OwnedPartitions {
+			if err := op.encode(pe); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -68,6 +79,13 @@ type OwnedPartition struct {
 	Partitions []int32
 }
 
+func (m *OwnedPartition) encode(pe packetEncoder) error {
+	if err := pe.putString(m.Topic); err != nil {
+		return err
+	}
+	return pe.putInt32Array(m.Partitions)
+}
+
 func (m *OwnedPartition) decode(pd packetDecoder) (err error) {
 	if m.Topic, err = pd.getString(); err != nil {
 		return err
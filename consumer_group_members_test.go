@@ -77,6 +77,30 @@ func TestConsumerGroupMemberMetadataV1Decode(t *testing.T) {
 	}
 }
 
+func TestConsumerGroupMemberMetadataV1OwnedPartitions(t *testing.T) {
+	meta := &ConsumerGroupMemberMetadata{
+		Version:  1,
+		Topics:   []string{"one", "two"},
+		UserData: []byte{0x01, 0x02, 0x03},
+		OwnedPartitions: []*OwnedPartition{
+			{Topic: "one", Partitions: []int32{0, 2}},
+		},
+	}
+
+	buf, err := encode(meta, nil)
+	if err != nil {
+		t.Fatal("Failed to encode data", err)
+	}
+
+	meta2 := new(ConsumerGroupMemberMetadata)
+	if err := decode(buf, meta2, nil); err != nil {
+		t.Fatal("Failed to decode data", err)
+	}
+	if !reflect.DeepEqual(meta, meta2) {
+		t.Errorf("Decoded data does not match expectation\nexpected: %v\nactual: %v", meta, meta2)
+	}
+}
+
 func TestConsumerGroupMemberAssignment(t *testing.T) {
 	amt := &ConsumerGroupMemberAssignment{
 		Version: 0,
@@ -3,6 +3,7 @@ package sarama
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -245,3 +246,945 @@ func TestConsumerGroupSessionDoesNotRetryForever(t *testing.T) {
 
 	wg.Wait()
 }
+
+type cooperativeHandler struct {
+	lock    sync.Mutex
+	setups  int
+	started map[int32]int
+	stopped chan int32
+	sess    ConsumerGroupSession
+}
+
+func (h *cooperativeHandler) Setup(s ConsumerGroupSession) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.setups++
+	h.sess = s
+	return nil
+}
+
+func (h *cooperativeHandler) Cleanup(s ConsumerGroupSession) error { return nil }
+
+func (h *cooperativeHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	h.lock.Lock()
+	h.started[claim.Partition()]++
+	h.lock.Unlock()
+
+	for range claim.Messages() {
+	}
+	h.stopped <- claim.Partition()
+	return nil
+}
+
+// TestConsumerGroupCooperativeRebalance ensures that with a cooperative balance
+// strategy a rebalance only stops the claims of the revoked partitions, and that
+// the member rejoins the group right after revoking them.
+func TestConsumerGroupCooperativeRebalance(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+	config.Consumer.Group.Rebalance.GroupStrategies = []BalanceStrategy{NewBalanceStrategyCooperativeSticky()}
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	assignment := func(partitions ...int32) *MockSyncGroupResponse {
+		return NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": partitions},
+		})
+	}
+	join := func(generation int32) *MockJoinGroupResponse {
+		return NewMockJoinGroupResponse(t).
+			SetGroupProtocol(CooperativeStickyBalanceStrategyName).
+			SetMemberId("my-member").
+			SetLeaderId("other-member").
+			SetGenerationId(generation)
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()).
+			SetLeader("my-topic", 1, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 0).
+			SetOffset("my-topic", 1, OffsetOldest, 0).
+			SetOffset("my-topic", 1, OffsetNewest, 0),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"HeartbeatRequest": NewMockSequence(
+			NewMockHeartbeatResponse(t).SetError(ErrRebalanceInProgress),
+			NewMockHeartbeatResponse(t),
+		),
+		"JoinGroupRequest": NewMockSequence(join(1), join(2), join(3)),
+		"SyncGroupRequest": NewMockSequence(assignment(0, 1), assignment(0), assignment(0)),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, 0, "", ErrNoError).
+			SetOffset("my-group", "my-topic", 1, 0, "", ErrNoError).
+			SetError(ErrNoError),
+		"FetchRequest":      NewMockFetchResponse(t, 1),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := &cooperativeHandler{started: make(map[int32]int), stopped: make(chan int32, 2)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- group.Consume(ctx, []string{"my-topic"}, h)
+	}()
+
+	select {
+	case partition := <-h.stopped:
+		if partition != 1 {
+			t.Fatalf("expected the claim of partition 1 to be revoked, partition %d was", partition)
+		}
+	case err := <-done:
+		t.Fatalf("expected the session to outlive the rebalance, Consume returned %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for partition 1 to be revoked")
+	}
+
+	// the member rejoins right after revoking partition 1
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		h.lock.Lock()
+		sess := h.sess
+		h.lock.Unlock()
+		if sess.GenerationID() == 3 {
+			if claims := sess.Claims(); len(claims["my-topic"]) != 1 || claims["my-topic"][0] != 0 {
+				t.Errorf("expected claims on partition 0 only, got %v", claims)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the member to rejoin, generation is %d", sess.GenerationID())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if partition := <-h.stopped; partition != 0 {
+		t.Errorf("expected the claim of partition 0 to be stopped last, partition %d was", partition)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.setups != 1 {
+		t.Errorf("expected a single session setup, got %d", h.setups)
+	}
+	if h.started[0] != 1 || h.started[1] != 1 {
+		t.Errorf("expected each claim to be started once, got %v", h.started)
+	}
+}
+
+type drainingHandler struct{}
+
+func (drainingHandler) Setup(s ConsumerGroupSession) error   { return nil }
+func (drainingHandler) Cleanup(s ConsumerGroupSession) error { return nil }
+func (drainingHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	for range claim.Messages() {
+	}
+	return nil
+}
+
+// TestConsumerGroupStaticMemberFenced ensures that a static member joins with its
+// group instance id, and that once fenced by another instance it ends its session
+// and refuses to rejoin the group.
+func TestConsumerGroupStaticMemberFenced(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_3_0_0
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Group.InstanceId = "instance-1"
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 0),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": {0}},
+		}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t).SetError(ErrFencedInstancedId),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, 0, "", ErrNoError).
+			SetError(ErrNoError),
+		"FetchRequest": NewMockFetchResponse(t, 1),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	if err := group.Consume(context.Background(), []string{"my-topic"}, drainingHandler{}); !errors.Is(err, ErrFencedInstancedId) {
+		t.Fatalf("expected ErrFencedInstancedId, got %v", err)
+	}
+
+	joins := 0
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*JoinGroupRequest); ok {
+			joins++
+			if req.Version != 5 || req.GroupInstanceId == nil || *req.GroupInstanceId != "instance-1" {
+				t.Errorf("expected a v5 JoinGroupRequest for instance-1, got v%d with %v", req.Version, req.GroupInstanceId)
+			}
+		}
+	}
+	if joins != 1 {
+		t.Fatalf("expected a single JoinGroupRequest, got %d", joins)
+	}
+
+	// a fenced member must not rejoin, as it would fence the other instance in turn
+	if err := group.Consume(context.Background(), []string{"my-topic"}, drainingHandler{}); !errors.Is(err, ErrFencedInstancedId) {
+		t.Fatalf("expected ErrFencedInstancedId, got %v", err)
+	}
+	for _, rr := range broker0.History() {
+		if _, ok := rr.Request.(*JoinGroupRequest); ok {
+			joins--
+		}
+	}
+	if joins != 0 {
+		t.Error("expected the fenced member not to rejoin the group")
+	}
+}
+
+type rebalanceListenerHandler struct {
+	drainingHandler
+	cancel context.CancelFunc
+	events []string
+}
+
+func (h *rebalanceListenerHandler) OnPartitionsAssigned(sess ConsumerGroupSession, partitions map[string][]int32) {
+	h.events = append(h.events, fmt.Sprintf("assigned %v", partitions))
+	if h.cancel != nil {
+		h.cancel()
+	}
+}
+
+func (h *rebalanceListenerHandler) OnPartitionsRevoked(sess ConsumerGroupSession, partitions map[string][]int32) {
+	h.events = append(h.events, fmt.Sprintf("revoked %v", partitions))
+}
+
+func (h *rebalanceListenerHandler) OnPartitionsLost(sess ConsumerGroupSession, partitions map[string][]int32) {
+	h.events = append(h.events, fmt.Sprintf("lost %v", partitions))
+}
+
+// TestConsumerGroupRebalanceListener ensures that handlers implementing
+// ConsumerGroupRebalanceListener are told about their assigned partitions, and
+// about them being revoked or lost at the end of the session.
+func TestConsumerGroupRebalanceListener(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		heartbeat KError
+		expected  []string
+	}{
+		{"revoked", ErrNoError, []string{"assigned map[my-topic:[0]]", "revoked map[my-topic:[0]]"}},
+		{"lost", ErrFencedInstancedId, []string{"assigned map[my-topic:[0]]", "lost map[my-topic:[0]]"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.ClientID = "rebalance-listener-" + tc.name
+			config.Version = V2_3_0_0
+			config.Consumer.Offsets.AutoCommit.Enable = false
+			config.Consumer.Group.InstanceId = "instance-1"
+			config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+
+			broker0 := NewMockBroker(t, 0)
+			defer broker0.Close()
+
+			broker0.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetBroker(broker0.Addr(), broker0.BrokerID()).
+					SetLeader("my-topic", 0, broker0.BrokerID()),
+				"OffsetRequest": NewMockOffsetResponse(t).
+					SetOffset("my-topic", 0, OffsetOldest, 0).
+					SetOffset("my-topic", 0, OffsetNewest, 0),
+				"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+					SetCoordinator(CoordinatorGroup, "my-group", broker0),
+				"JoinGroupRequest": NewMockJoinGroupResponse(t).
+					SetGroupProtocol(RangeBalanceStrategyName).
+					SetMemberId("my-member").
+					SetGenerationId(1),
+				"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+					Topics: map[string][]int32{"my-topic": {0}},
+				}),
+				"HeartbeatRequest": NewMockHeartbeatResponse(t).SetError(tc.heartbeat),
+				"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+					SetOffset("my-group", "my-topic", 0, 0, "", ErrNoError).
+					SetError(ErrNoError),
+				"FetchRequest":      NewMockFetchResponse(t, 1),
+				"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+			})
+
+			group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = group.Close() }()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			handler := &rebalanceListenerHandler{}
+			if tc.heartbeat == ErrNoError {
+				handler.cancel = cancel
+			}
+			_ = group.Consume(ctx, []string{"my-topic"}, handler)
+
+			assert.Equal(t, tc.expected, handler.events)
+		})
+	}
+}
+
+// TestConsumerGroupTopicPattern ensures that topic patterns subscribe to the matching
+// topics, and that the session ends once a new matching topic is created.
+func TestConsumerGroupTopicPattern(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Metadata.RefreshFrequency = 20 * time.Millisecond
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	metadata := func(topics ...string) *MockMetadataResponse {
+		resp := NewMockMetadataResponse(t).SetBroker(broker0.Addr(), broker0.BrokerID())
+		for _, topic := range topics {
+			resp.SetLeader(topic, 0, broker0.BrokerID())
+		}
+		return resp
+	}
+	handlers := map[string]MockResponse{
+		"MetadataRequest": metadata("payments.eu", "payments.us", "orders", "__consumer_offsets"),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{},
+		}),
+		"HeartbeatRequest":  NewMockHeartbeatResponse(t),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+	}
+	broker0.SetHandlerByMap(handlers)
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	if err := group.Consume(context.Background(), []string{"re:["}, drainingHandler{}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+
+	joined := make(chan none)
+	go func() {
+		<-joined
+		updated := make(map[string]MockResponse, len(handlers))
+		for name, handler := range handlers {
+			updated[name] = handler
+		}
+		updated["MetadataRequest"] = metadata("payments.eu", "payments.us", "payments.asia", "orders")
+		broker0.SetHandlerByMap(updated)
+	}()
+	handler := &setupHandler{setup: func(ConsumerGroupSession) { close(joined) }}
+	if err := group.Consume(context.Background(), []string{"re:^payments\\..*"}, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*JoinGroupRequest); ok {
+			meta := new(ConsumerGroupMemberMetadata)
+			if err := decode(req.GroupProtocols[RangeBalanceStrategyName], meta, nil); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, []string{"payments.eu", "payments.us"}, meta.Topics)
+		}
+	}
+}
+
+type setupHandler struct {
+	drainingHandler
+	setup func(ConsumerGroupSession)
+}
+
+func (h *setupHandler) Setup(sess ConsumerGroupSession) error {
+	h.setup(sess)
+	return nil
+}
+
+type slowMarkingHandler struct {
+	drainingHandler
+	received chan none
+	once     sync.Once
+}
+
+func (h *slowMarkingHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		h.once.Do(func() { close(h.received) })
+		time.Sleep(10 * time.Millisecond)
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+// TestConsumerGroupCloseGracefully ensures that a gracefully closing member first revokes its
+// claims by rejoining the group without any topic, then processes the messages fetched before,
+// and commits their offsets, before it leaves the group.
+func TestConsumerGroupCloseGracefully(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Offsets.AutoCommit.Interval = time.Hour
+	config.Consumer.Offsets.Initial = OffsetOldest
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	fetchResponse := NewMockFetchResponse(t, 5)
+	for i := int64(0); i < 5; i++ {
+		fetchResponse.SetMessage("my-topic", 0, i, StringEncoder("foo"))
+	}
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 5),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": {0}},
+		}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, -1, "", ErrNoError).
+			SetError(ErrNoError),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t),
+		"FetchRequest":        fetchResponse,
+		"LeaveGroupRequest":   NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &slowMarkingHandler{received: make(chan none)}
+	done := make(chan error)
+	go func() {
+		done <- group.Consume(context.Background(), []string{"my-topic"}, handler)
+	}()
+
+	select {
+	case <-handler.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the first message")
+	}
+	if err := group.CloseGracefully(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	var committed int64 = -1
+	joins, left, revoked := 0, false, false
+	for _, rr := range broker0.History() {
+		switch req := rr.Request.(type) {
+		case *JoinGroupRequest:
+			if joins++; joins == 1 {
+				continue
+			}
+			meta := new(ConsumerGroupMemberMetadata)
+			if err := decode(req.OrderedGroupProtocols[0].Metadata, meta, nil); err != nil {
+				t.Fatal(err)
+			}
+			if len(meta.Topics) != 0 {
+				t.Errorf("expected the member to rejoin without any topic, got %v", meta.Topics)
+			}
+			if left {
+				t.Error("expected the member to revoke its claims before leaving the group")
+			}
+			revoked = true
+		case *OffsetCommitRequest:
+			if left {
+				t.Error("expected offsets to be committed before leaving the group")
+			}
+			if block := req.blocks["my-topic"][0]; block != nil {
+				committed = block.offset
+			}
+		case *LeaveGroupRequest:
+			left = true
+		}
+	}
+	if committed != 5 {
+		t.Errorf("expected all the fetched messages to be committed, got offset %d", committed)
+	}
+	if !revoked {
+		t.Error("expected the member to revoke its claims")
+	}
+	if !left {
+		t.Error("expected the member to leave the group")
+	}
+}
+
+type stuckHandler struct {
+	drainingHandler
+}
+
+func (stuckHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	<-claim.Messages()
+	<-sess.Context().Done()
+	return nil
+}
+
+// TestConsumerGroupMaxProcessingInterval ensures that a member whose claim is stuck
+// processing a message leaves the group, while heartbeats kept it in the group until then.
+func TestConsumerGroupMaxProcessingInterval(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.ChannelBufferSize = 1
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+	config.Consumer.Group.MaxProcessingInterval = 100 * time.Millisecond
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	fetchResponse := NewMockFetchResponse(t, 2).
+		SetMessage("my-topic", 0, 0, StringEncoder("foo")).
+		SetMessage("my-topic", 0, 1, StringEncoder("bar"))
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 2),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": {0}},
+		}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, -1, "", ErrNoError).
+			SetError(ErrNoError),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t),
+		"FetchRequest":        fetchResponse,
+		"LeaveGroupRequest":   NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	start := time.Now()
+	if err := group.Consume(context.Background(), []string{"my-topic"}, stuckHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < config.Consumer.Group.MaxProcessingInterval {
+		t.Errorf("expected the session to last at least the max processing interval, ended after %v", elapsed)
+	}
+
+	select {
+	case err := <-group.Errors():
+		if !errors.Is(err, ErrMaxProcessingIntervalExceeded) {
+			t.Errorf("expected ErrMaxProcessingIntervalExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for ErrMaxProcessingIntervalExceeded")
+	}
+
+	left := false
+	for _, rr := range broker0.History() {
+		if _, ok := rr.Request.(*LeaveGroupRequest); ok {
+			left = true
+		}
+	}
+	if !left {
+		t.Error("expected the member to leave the group")
+	}
+}
+
+type stuckListenerHandler struct {
+	stuckHandler
+	stuck chan string
+}
+
+func (h stuckListenerHandler) OnClaimStuck(sess ConsumerGroupSession, topic string, partition int32, stalled time.Duration) {
+	h.stuck <- fmt.Sprintf("%s/%d", topic, partition)
+}
+
+// TestConsumerGroupStuckClaim ensures that a claim stuck processing a message is reported,
+// without the member leaving the group.
+func TestConsumerGroupStuckClaim(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.ChannelBufferSize = 1
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+	config.Consumer.Group.StuckClaim.Timeout = 100 * time.Millisecond
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	fetchResponse := NewMockFetchResponse(t, 2).
+		SetMessage("my-topic", 0, 0, StringEncoder("foo")).
+		SetMessage("my-topic", 0, 1, StringEncoder("bar"))
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 2),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": {0}},
+		}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, -1, "", ErrNoError).
+			SetError(ErrNoError),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t),
+		"FetchRequest":        fetchResponse,
+		"LeaveGroupRequest":   NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := stuckListenerHandler{stuck: make(chan string, 1)}
+	errs := make(chan error, 1)
+	go func() { errs <- group.Consume(ctx, []string{"my-topic"}, handler) }()
+
+	select {
+	case claim := <-handler.stuck:
+		if claim != "my-topic/0" {
+			t.Errorf("expected my-topic/0 to be stuck, got %s", claim)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for OnClaimStuck")
+	}
+	select {
+	case err := <-group.Errors():
+		if !errors.Is(err, ErrClaimStuck) {
+			t.Errorf("expected ErrClaimStuck, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for ErrClaimStuck")
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range broker0.History() {
+		if _, ok := rr.Request.(*LeaveGroupRequest); ok {
+			t.Error("expected the member to stay in the group")
+		}
+	}
+}
+
+type pausingHandler struct {
+	cancel context.CancelFunc
+	paused chan bool
+}
+
+func (h *pausingHandler) Setup(s ConsumerGroupSession) error   { return nil }
+func (h *pausingHandler) Cleanup(s ConsumerGroupSession) error { return nil }
+func (h *pausingHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	pc := claim.(*consumerGroupClaim).PartitionConsumer
+
+	sess.Pause(map[string][]int32{claim.Topic(): {claim.Partition()}})
+	h.paused <- pc.IsPaused()
+	sess.ResumeAll()
+	h.paused <- pc.IsPaused()
+	sess.PauseAll()
+	h.paused <- pc.IsPaused()
+
+	h.cancel()
+	for range claim.Messages() {
+	}
+	return nil
+}
+
+// TestConsumerGroupSessionPause ensures that handlers can pause and resume the
+// partitions of their claims through the session.
+func TestConsumerGroupSessionPause(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 0),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": {0}},
+		}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, 0, "", ErrNoError).
+			SetError(ErrNoError),
+		"FetchRequest":      NewMockFetchResponse(t, 1),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &pausingHandler{cancel: cancel, paused: make(chan bool, 3)}
+	if err := group.Consume(ctx, []string{"my-topic"}, h); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []bool{true, false, true} {
+		if paused := <-h.paused; paused != expected {
+			t.Errorf("step %d: expected paused to be %v", i, expected)
+		}
+	}
+}
+
+type offsetsPartitionConsumer struct {
+	PartitionConsumer
+	hwm, lso int64
+}
+
+func (pc offsetsPartitionConsumer) HighWaterMarkOffset() int64 { return pc.hwm }
+func (pc offsetsPartitionConsumer) LastStableOffset() int64    { return pc.lso }
+
+func TestConsumerGroupClaimLag(t *testing.T) {
+	config := NewTestConfig()
+	om := &offsetManager{conf: config, poms: make(map[string]map[int32]*partitionOffsetManager)}
+	sess := &consumerGroupSession{parent: &consumerGroup{config: config}, offsets: om}
+	claim := &consumerGroupClaim{
+		topic:             "my_topic",
+		partition:         0,
+		offset:            OffsetNewest,
+		sess:              sess,
+		PartitionConsumer: offsetsPartitionConsumer{hwm: 100, lso: 80},
+	}
+
+	if lag := claim.Lag(); lag != -1 {
+		t.Errorf("Expected an unknown lag without any offset, got %d", lag)
+	}
+
+	pom := &partitionOffsetManager{parent: om, topic: "my_topic", partition: 0, offset: 40}
+	om.poms["my_topic"] = map[int32]*partitionOffsetManager{0: pom}
+	if lag := claim.Lag(); lag != 60 {
+		t.Errorf("Expected a lag of 60 from the committed offset, got %d", lag)
+	}
+
+	pom.MarkOffset(70, "")
+	if lag := claim.Lag(); lag != 30 {
+		t.Errorf("Expected a lag of 30 from the marked offset, got %d", lag)
+	}
+
+	config.Consumer.IsolationLevel = ReadCommitted
+	if lag := claim.Lag(); lag != 10 {
+		t.Errorf("Expected a lag of 10 from the last stable offset, got %d", lag)
+	}
+}
+
+// loadAwareStrategy assigns all the partitions to the least loaded member, as reported
+// in the subscription user data, and tells it which generation assigned them.
+type loadAwareStrategy struct {
+	load     string
+	assigned chan []byte
+}
+
+func (s *loadAwareStrategy) Name() string { return "load-aware" }
+
+func (s *loadAwareStrategy) Plan(map[string]ConsumerGroupMemberMetadata, map[string][]int32) (BalanceStrategyPlan, error) {
+	return nil, errors.New("Plan must not be used by user data strategies")
+}
+
+func (s *loadAwareStrategy) AssignmentData(string, map[string][]int32, int32) ([]byte, error) {
+	return nil, errors.New("AssignmentData must not be used by user data strategies")
+}
+
+func (s *loadAwareStrategy) SubscriptionUserData(topics []string) ([]byte, error) {
+	return []byte(s.load), nil
+}
+
+func (s *loadAwareStrategy) PlanWithUserData(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, map[string][]byte, error) {
+	var leastLoaded string
+	for memberID, meta := range members {
+		if leastLoaded == "" || string(meta.UserData) < string(members[leastLoaded].UserData) {
+			leastLoaded = memberID
+		}
+	}
+	plan := make(BalanceStrategyPlan)
+	for topic, partitions := range topics {
+		plan.Add(leastLoaded, topic, partitions...)
+	}
+	return plan, map[string][]byte{leastLoaded: []byte("least-loaded")}, nil
+}
+
+func (s *loadAwareStrategy) OnAssignment(assignment map[string][]int32, userData []byte, generationID int32) {
+	s.assigned <- userData
+}
+
+func TestConsumerGroupUserDataBalanceStrategy(t *testing.T) {
+	strategy := &loadAwareStrategy{load: "3", assigned: make(chan []byte, 1)}
+
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Group.Rebalance.GroupStrategies = []BalanceStrategy{strategy}
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol("load-aware").
+			SetMemberId("my-member").
+			SetLeaderId("my-member").
+			SetGenerationId(1).
+			SetMember("my-member", &ConsumerGroupMemberMetadata{Topics: []string{"my-topic"}, UserData: []byte("3")}).
+			SetMember("other-member", &ConsumerGroupMemberMetadata{Topics: []string{"my-topic"}, UserData: []byte("1")}),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			UserData: []byte("from-leader"),
+		}),
+		"HeartbeatRequest":  NewMockHeartbeatResponse(t),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = group.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- group.Consume(ctx, []string{"my-topic"}, drainingHandler{})
+	}()
+
+	select {
+	case userData := <-strategy.assigned:
+		if string(userData) != "from-leader" {
+			t.Errorf("expected the assignment user data to be passed to OnAssignment, got %q", userData)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the assignment")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	for _, rr := range broker0.History() {
+		switch req := rr.Request.(type) {
+		case *JoinGroupRequest:
+			meta := new(ConsumerGroupMemberMetadata)
+			if err := decode(req.OrderedGroupProtocols[0].Metadata, meta, nil); err != nil {
+				t.Fatal(err)
+			}
+			if string(meta.UserData) != "3" {
+				t.Errorf("expected the subscription user data to be sent, got %q", meta.UserData)
+			}
+		case *SyncGroupRequest:
+			for _, assignment := range req.GroupAssignments {
+				decoded := new(ConsumerGroupMemberAssignment)
+				if err := decode(assignment.Assignment, decoded, nil); err != nil {
+					t.Fatal(err)
+				}
+				switch assignment.MemberId {
+				case "other-member":
+					if len(decoded.Topics["my-topic"]) != 1 || string(decoded.UserData) != "least-loaded" {
+						t.Errorf("expected the least loaded member to get the partition and user data, got %+v", decoded)
+					}
+				case "my-member":
+					if len(decoded.Topics) != 0 || decoded.UserData != nil {
+						t.Errorf("expected an empty assignment for the most loaded member, got %+v", decoded)
+					}
+				}
+			}
+		}
+	}
+}
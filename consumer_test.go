@@ -2,81 +2,790 @@ package sarama
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log"
 	"os"
 	"os/signal"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
-var testMsg = StringEncoder("Foo")
-var testKey = StringEncoder("Bar")
+var testMsg = StringEncoder("Foo")
+var testKey = StringEncoder("Bar")
+
+// If a particular offset is provided then messages are consumed starting from
+// that offset.
+func TestConsumerOffsetManual(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	manualOffset := int64(1234)
+	offsetNewest := int64(2345)
+	offsetNewestAfterFetchRequest := int64(3456)
+
+	mockFetchResponse := NewMockFetchResponse(t, 1)
+
+	// skipped because parseRecords(): offset < child.offset
+	mockFetchResponse.SetMessage("my_topic", 0, manualOffset-1, testMsg)
+
+	for i := int64(0); i < 10; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i+manualOffset, testMsg)
+	}
+
+	mockFetchResponse.SetHighWaterMark("my_topic", 0, offsetNewestAfterFetchRequest)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, offsetNewest),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, manualOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	if hwmo := consumer.HighWaterMarkOffset(); hwmo != offsetNewest {
+		t.Errorf("Expected high water mark offset %d, found %d", offsetNewest, hwmo)
+	}
+	for i := int64(0); i < 10; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, i+manualOffset)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	if hwmo := consumer.HighWaterMarkOffset(); hwmo != offsetNewestAfterFetchRequest {
+		t.Errorf("Expected high water mark offset %d, found %d", offsetNewestAfterFetchRequest, hwmo)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerConsumeBetween(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	start, end := time.Unix(1000, 0), time.Unix(2000, 0)
+	startMs, endMs := start.UnixNano()/int64(time.Millisecond), end.UnixNano()/int64(time.Millisecond)
+
+	mockFetchResponse := NewMockFetchResponse(t, 2)
+	for offset := int64(0); offset < 5; offset++ {
+		mockFetchResponse.SetMessage("my_topic", 0, offset, testMsg)
+	}
+	mockFetchResponse.SetMessage("my_topic", 1, 0, testMsg)
+	mockFetchResponse.SetMessage("my_topic", 1, 1, testMsg)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 5).
+			SetOffset("my_topic", 0, startMs, 1).
+			SetOffset("my_topic", 0, endMs, 3).
+			SetOffset("my_topic", 1, OffsetOldest, 0).
+			SetOffset("my_topic", 1, OffsetNewest, 2).
+			SetOffset("my_topic", 1, startMs, 0).
+			SetOffset("my_topic", 1, endMs, -1), // no message after end
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_1_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	var lock sync.Mutex
+	consumed := make(map[int32][]int64)
+	err = master.ConsumeBetween(context.Background(), "my_topic", start, end, func(msg *ConsumerMessage) error {
+		lock.Lock()
+		defer lock.Unlock()
+		consumed[msg.Partition] = append(consumed[msg.Partition], msg.Offset)
+		return nil
+	})
+
+	// Then
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int32][]int64{0: {1, 2}, 1: {0, 1}}
+	if !reflect.DeepEqual(consumed, expected) {
+		t.Errorf("Expected the offsets %v to be consumed, got %v", expected, consumed)
+	}
+	safeClose(t, master)
+
+	// When: fn fails
+	master, err = NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+	failure := errors.New("failure")
+	err = master.ConsumeBetween(context.Background(), "my_topic", start, end, func(msg *ConsumerMessage) error {
+		return failure
+	})
+
+	// Then
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the error of fn, got %v", err)
+	}
+}
+
+func TestConsumerPartitionTail(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	// compaction left gaps between the last offsets of the partition
+	mockFetchResponse := NewMockFetchResponse(t, 3)
+	for _, offset := range []int64{3, 4, 5, 6, 7, 8, 9, 12, 14, 16, 18} {
+		mockFetchResponse.SetMessage("my_topic", 0, offset, testMsg)
+	}
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 3).
+			SetOffset("my_topic", 0, OffsetNewest, 20),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	for _, tc := range []struct {
+		n      int64
+		offset int64
+	}{
+		{n: 5, offset: 9},
+		{n: 2, offset: 16},
+		{n: 0, offset: 20},
+		{n: 100, offset: 3},
+	} {
+		offset, err := master.(*consumer).tailOffset("my_topic", 0, tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != tc.offset {
+			t.Errorf("Expected the last %d messages to start at offset %d, got %d", tc.n, tc.offset, offset)
+		}
+	}
+
+	// When
+	consumer, err := master.ConsumePartitionTail("my_topic", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	for _, offset := range []int64{14, 16, 18} {
+		assertMessageOffset(t, <-consumer.Messages(), offset)
+	}
+	safeClose(t, consumer)
+}
+
+func TestConsumerOffsetTimestamp(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	initial := time.Unix(1600000000, 0)
+	millis := initial.UnixMilli()
+
+	mockFetchResponse := NewMockFetchResponse(t, 1)
+	for i := int64(0); i < 10; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i+1234, testMsg)
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345).
+			SetOffset("my_topic", 0, millis, 1234).
+			SetOffset("my_topic", 1, OffsetOldest, 0).
+			SetOffset("my_topic", 1, OffsetNewest, 2345).
+			SetOffset("my_topic", 1, millis, -1),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Offsets.Initial = OffsetTimestamp
+	config.Consumer.Offsets.InitialTimestamp = initial
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := master.ConsumePartition("my_topic", 1, OffsetFail); !errors.Is(err, ErrNoInitialOffset) {
+		t.Errorf("Expected ErrNoInitialOffset, got %v", err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// there is no message after the timestamp in partition 1
+	latest, err := master.ConsumePartition("my_topic", 1, OffsetTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	for i := int64(0); i < 10; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, i+1234)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+	if offset := latest.(*partitionConsumer).offset; offset != 2345 {
+		t.Errorf("Expected partition 1 to start at the newest offset, got %d", offset)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, latest)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerMaxBufferedBytesPerPartition(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	mockFetchResponse := NewMockFetchResponse(t, 10)
+	for i := int64(0); i < 10; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i, testMsg)
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 10),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	fetches := func() int {
+		n := 0
+		for _, rr := range broker0.History() {
+			if _, ok := rr.Request.(*FetchRequest); ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	config := NewTestConfig()
+	config.Consumer.Fetch.MaxBufferedBytesPerPartition = 10
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the first fetch buffers 30 bytes, so no more fetches are made until they are received
+	time.Sleep(500 * time.Millisecond)
+	if n := fetches(); n != 1 {
+		t.Errorf("Expected a single fetch while the buffer is full, got %d", n)
+	}
+
+	for i := int64(0); i < 10; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, i)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if n := fetches(); n < 2 {
+		t.Errorf("Expected fetching to resume once the buffer is drained, got %d fetches", n)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerBatches(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	mockFetchResponse := NewMockFetchResponse(t, 10)
+	for i := int64(0); i < 10; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i, testMsg)
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 10),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Return.Batches = true
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the whole fetch response is delivered at once
+	select {
+	case batch := <-consumer.Batches():
+		if len(batch) != 10 {
+			t.Fatalf("Expected a batch of 10 messages, got %d", len(batch))
+		}
+		for i, message := range batch {
+			assertMessageOffset(t, message, int64(i))
+		}
+	case err := <-consumer.Errors():
+		t.Error(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a batch")
+	}
+	if len(consumer.Messages()) != 0 {
+		t.Error("Expected no message on the Messages channel")
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerDeserializers(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	mockFetchResponse := NewMockFetchResponse(t, 2).
+		SetMessageWithKey("my_topic", 0, 0, StringEncoder("k"), StringEncoder("value")).
+		SetMessageWithKey("my_topic", 0, 1, StringEncoder("k"), StringEncoder("bad"))
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	errBadValue := errors.New("bad value")
+	config := NewTestConfig()
+	config.Consumer.Return.Errors = true
+	config.Consumer.Deserializers = map[string]TopicDeserializers{
+		"my_topic": {
+			Key: DeserializerFunc(func(topic string, data []byte) (interface{}, error) {
+				return len(data), nil
+			}),
+			Value: DeserializerFunc(func(topic string, data []byte) (interface{}, error) {
+				if string(data) == "bad" {
+					return nil, errBadValue
+				}
+				return strings.ToUpper(string(data)), nil
+			}),
+		},
+	}
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: decoded messages are delivered, along with an error for those failing to decode
+	message := <-consumer.Messages()
+	if message.DecodedKey != 1 || message.DecodedValue != "VALUE" {
+		t.Errorf("Expected the message to be decoded, got key %v and value %v", message.DecodedKey, message.DecodedValue)
+	}
+
+	select {
+	case err := <-consumer.Errors():
+		if !errors.Is(err, errBadValue) {
+			t.Errorf("Expected a deserialization error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the deserialization error")
+	}
+	message = <-consumer.Messages()
+	assertMessageValue(t, message, StringEncoder("bad"))
+	if message.DecodedKey != 1 || message.DecodedValue != nil {
+		t.Errorf("Expected only the key to be decoded, got key %v and value %v", message.DecodedKey, message.DecodedValue)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+// TestConsumerSlowDeserializer ensures that a slow deserializer, such as a schema registry
+// lookup, only holds up its partition and not the other partitions of the broker.
+func TestConsumerSlowDeserializer(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("slow_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 3).
+			SetOffset("slow_topic", 0, OffsetOldest, 0).
+			SetOffset("slow_topic", 0, OffsetNewest, 1),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 0, testMsg).
+			SetMessage("my_topic", 0, 1, testMsg).
+			SetMessage("my_topic", 0, 2, testMsg).
+			SetMessage("slow_topic", 0, 0, testMsg),
+	})
+
+	release := make(chan none)
+	config := NewTestConfig()
+	config.Consumer.MaxProcessingTime = 10 * time.Millisecond
+	config.Consumer.Deserializers = map[string]TopicDeserializers{
+		"slow_topic": {
+			Value: DeserializerFunc(func(topic string, data []byte) (interface{}, error) {
+				<-release
+				return string(data), nil
+			}),
+		},
+	}
+
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	slow, err := master.ConsumePartition("slow_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, slow)
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	defer close(release)
+
+	for i := int64(0); i < 3; i++ {
+		select {
+		case msg := <-consumer.Messages():
+			assertMessageOffset(t, msg, i)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d while the other partition deserializes", i)
+		}
+	}
+}
+
+// TestConsumerDeserializesOnce ensures that the messages are deserialized once, even when the
+// delivery of a response times out.
+func TestConsumerDeserializesOnce(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 3),
+		"FetchRequest": NewMockFetchResponse(t, 3).
+			SetMessage("my_topic", 0, 0, testMsg).
+			SetMessage("my_topic", 0, 1, testMsg).
+			SetMessage("my_topic", 0, 2, testMsg),
+	})
+
+	var calls int32
+	config := NewTestConfig()
+	config.ChannelBufferSize = 1
+	config.Consumer.MaxProcessingTime = 10 * time.Millisecond
+	config.Consumer.Deserializers = map[string]TopicDeserializers{
+		"my_topic": {
+			Value: DeserializerFunc(func(topic string, data []byte) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return string(data), nil
+			}),
+		},
+	}
+
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	for i := int64(0); i < 3; i++ {
+		select {
+		case msg := <-consumer.Messages():
+			assertMessageOffset(t, msg, i)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+		if i == 0 {
+			// the delivery of the last message times out
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 3 {
+		t.Errorf("expected each message to be deserialized once, got %d calls", calls)
+	}
+}
+
+func TestConsumerCaughtUp(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	mockFetchResponse := NewMockFetchResponse(t, 3)
+	for i := int64(0); i < 3; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i, testMsg)
+	}
+	mockFetchResponse.SetHighWaterMark("my_topic", 0, 3)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 3),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.ChannelBufferSize = 0
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the consumer is only caught up once all the messages are delivered
+	if lag := consumer.Lag(); lag != 3 {
+		t.Errorf("Expected a lag of 3 messages, got %d", lag)
+	}
+	for i := int64(0); i < 3; i++ {
+		select {
+		case <-consumer.CaughtUp():
+			t.Fatalf("Expected the consumer not to be caught up before message %d", i)
+		default:
+		}
+		assertMessageOffset(t, <-consumer.Messages(), i)
+	}
+	select {
+	case <-consumer.CaughtUp():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the consumer to catch up")
+	}
+	if lag := consumer.Lag(); lag != 0 {
+		t.Errorf("Expected no lag, got %d", lag)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerPartitionEOF(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	mockFetchResponse := NewMockFetchResponse(t, 2)
+	for i := int64(0); i < 3; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i, testMsg)
+	}
+	mockFetchResponse.SetHighWaterMark("my_topic", 0, 3)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 3),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Return.PartitionEOF = true
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the end of the partition is reported once, after all the messages
+	for i := int64(0); i < 3; i++ {
+		assertMessageOffset(t, <-consumer.Messages(), i)
+	}
+	select {
+	case eof := <-consumer.EOF():
+		if eof.Topic != "my_topic" || eof.Partition != 0 || eof.Offset != 3 {
+			t.Errorf("Expected the end of my_topic/0 at offset 3, got %+v", eof)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the end of the partition")
+	}
+	select {
+	case eof := <-consumer.EOF():
+		t.Errorf("Expected the end of the partition to be reported once, got %+v", eof)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
 
-// If a particular offset is provided then messages are consumed starting from
-// that offset.
-func TestConsumerOffsetManual(t *testing.T) {
+func TestConsumerPartitionMetrics(t *testing.T) {
 	// Given
 	broker0 := NewMockBroker(t, 0)
 
-	manualOffset := int64(1234)
-	offsetNewest := int64(2345)
-	offsetNewestAfterFetchRequest := int64(3456)
-
-	mockFetchResponse := NewMockFetchResponse(t, 1)
-
-	// skipped because parseRecords(): offset < child.offset
-	mockFetchResponse.SetMessage("my_topic", 0, manualOffset-1, testMsg)
-
-	for i := int64(0); i < 10; i++ {
-		mockFetchResponse.SetMessage("my_topic", 0, i+manualOffset, testMsg)
+	mockFetchResponse := NewMockFetchResponse(t, 3)
+	for i := int64(0); i < 3; i++ {
+		mockFetchResponse.SetMessage("my.topic", 0, i, testMsg)
 	}
-
-	mockFetchResponse.SetHighWaterMark("my_topic", 0, offsetNewestAfterFetchRequest)
+	mockFetchResponse.SetHighWaterMark("my.topic", 0, 5)
 
 	broker0.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
-			SetLeader("my_topic", 0, broker0.BrokerID()),
+			SetLeader("my.topic", 0, broker0.BrokerID()),
 		"OffsetRequest": NewMockOffsetResponse(t).
-			SetOffset("my_topic", 0, OffsetOldest, 0).
-			SetOffset("my_topic", 0, OffsetNewest, offsetNewest),
+			SetOffset("my.topic", 0, OffsetOldest, 0).
+			SetOffset("my.topic", 0, OffsetNewest, 5),
 		"FetchRequest": mockFetchResponse,
 	})
 
+	config := NewTestConfig()
+	config.ChannelBufferSize = 0
+
 	// When
-	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	consumer, err := master.ConsumePartition("my_topic", 0, manualOffset)
+	consumer, err := master.ConsumePartition("my.topic", 0, OffsetOldest)
 	if err != nil {
 		t.Fatal(err)
 	}
+	for i := int64(0); i < 3; i++ {
+		assertMessageOffset(t, <-consumer.Messages(), i)
+	}
 
 	// Then
-	if hwmo := consumer.HighWaterMarkOffset(); hwmo != offsetNewest {
-		t.Errorf("Expected high water mark offset %d, found %d", offsetNewest, hwmo)
+	registry := config.MetricRegistry
+	lag, ok := registry.Get("consumer-lag-for-topic-my_topic-partition-0").(metrics.Gauge)
+	if !ok {
+		t.Fatal("Expected the lag gauge to be registered")
 	}
-	for i := int64(0); i < 10; i++ {
-		select {
-		case message := <-consumer.Messages():
-			assertMessageOffset(t, message, i+manualOffset)
-		case err := <-consumer.Errors():
-			t.Error(err)
-		}
+	consumed := registry.Get("consumer-records-consumed-rate-for-topic-my_topic-partition-0").(metrics.Meter)
+	latency := registry.Get("consumer-fetch-latency-in-ms-for-topic-my_topic-partition-0").(metrics.Histogram)
+	if latency.Count() == 0 {
+		t.Error("Expected the fetch latency to be recorded")
 	}
-
-	if hwmo := consumer.HighWaterMarkOffset(); hwmo != offsetNewestAfterFetchRequest {
-		t.Errorf("Expected high water mark offset %d, found %d", offsetNewestAfterFetchRequest, hwmo)
+	// messages are accounted for by the feeder right after they were received
+	for deadline := time.Now().Add(5 * time.Second); consumed.Count() < 3 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if count := consumed.Count(); count != 3 {
+		t.Errorf("Expected 3 consumed messages, got %d", count)
+	}
+	if value := lag.Value(); value != 2 {
+		t.Errorf("Expected a lag of 2 messages, got %d", value)
 	}
 
 	safeClose(t, consumer)
 	safeClose(t, master)
+	if registry.Get("consumer-lag-for-topic-my_topic-partition-0") != nil {
+		t.Error("Expected the partition metrics to be unregistered")
+	}
 	broker0.Close()
 }
 
@@ -623,6 +1332,66 @@ func TestConsumerShutsDownOutOfRange(t *testing.T) {
 	broker0.Close()
 }
 
+// If Consumer.Offsets.ResetOutOfRange is enabled, a partition consumer whose
+// offset is out of range restarts from Consumer.Offsets.Initial.
+func TestConsumerResetsOutOfRange(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	outOfRange := new(FetchResponse)
+	outOfRange.AddError("my_topic", 0, ErrOffsetOutOfRange)
+	messages := new(FetchResponse)
+	messages.AddMessage("my_topic", 0, nil, testMsg, 7)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 7),
+		"FetchRequest": NewMockSequence(outOfRange, messages),
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Offsets.ResetOutOfRange = true
+	config.Consumer.Retry.Backoff = 10 * time.Millisecond
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When/Then: an offset out of range while consuming is reset before fetching again
+	consumer, err := master.ConsumePartition("my_topic", 0, 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-consumer.Messages():
+		assertMessageOffset(t, message, 7)
+	case err := <-consumer.Errors():
+		t.Error(err)
+	}
+	safeClose(t, consumer)
+
+	// When/Then: an offset out of range when starting is reset right away
+	consumer, err = master.ConsumePartition("my_topic", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset := consumer.(*partitionConsumer).offset; offset != 7 {
+		t.Errorf("Expected to start from the oldest offset, got %d", offset)
+	}
+	safeClose(t, consumer)
+
+	// When/Then: the special offsets need their configuration
+	if _, err := master.ConsumePartition("my_topic", 0, OffsetRelative); !errors.As(err, new(ConfigurationError)) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+
+	safeClose(t, master)
+	broker0.Close()
+}
+
 // If a fetch response contains messages with offsets that are smaller then
 // requested, then such messages are ignored.
 func TestConsumerExtraOffsets(t *testing.T) {
@@ -705,19 +1474,205 @@ func TestConsumerReceivingFetchResponseWithTooOldRecords(t *testing.T) {
 	fetchResponse2.AddRecord("my_topic", 0, nil, testMsg, 1000000)
 
 	cfg := NewTestConfig()
-	cfg.Consumer.Return.Errors = true
+	cfg.Consumer.Return.Errors = true
+	cfg.Version = V0_11_0_0
+
+	broker0 := NewMockBroker(t, 0)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-consumer.Messages():
+		assertMessageOffset(t, msg, 1000000)
+	case err := <-consumer.Errors():
+		t.Fatal(err)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumeMessageWithNewerFetchAPIVersion(t *testing.T) {
+	// Given
+	fetchResponse1 := &FetchResponse{Version: 4}
+	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 1)
+	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 2)
+
+	cfg := NewTestConfig()
+	cfg.Version = V0_11_0_0
+
+	broker0 := NewMockBroker(t, 0)
+	fetchResponse2 := &FetchResponse{}
+	fetchResponse2.Version = 4
+	fetchResponse2.AddError("my_topic", 0, ErrNoError)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMessageOffset(t, <-consumer.Messages(), 1)
+	assertMessageOffset(t, <-consumer.Messages(), 2)
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumeMessageWithSessionIDs(t *testing.T) {
+	// Given
+	fetchResponse1 := &FetchResponse{Version: 7}
+	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 1)
+	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 2)
+
+	cfg := NewTestConfig()
+	cfg.Version = V1_1_0_0
+
+	broker0 := NewMockBroker(t, 0)
+	fetchResponse2 := &FetchResponse{}
+	fetchResponse2.Version = 7
+	fetchResponse2.AddError("my_topic", 0, ErrNoError)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMessageOffset(t, <-consumer.Messages(), 1)
+	assertMessageOffset(t, <-consumer.Messages(), 2)
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+
+	fetchReq := broker0.History()[3].Request.(*FetchRequest)
+	if fetchReq.SessionID != 0 || fetchReq.SessionEpoch != 0 {
+		t.Error("Expected session ID to be zero & Epoch to be zero")
+	}
+}
+
+func TestConsumeLazyDecompression(t *testing.T) {
+	// Given
+	fetchResponse := &FetchResponse{Version: 4}
+	for offset := int64(1); offset <= 3; offset++ {
+		fetchResponse.AddRecordBatch("my_topic", 0, nil, testMsg, offset, 0, false)
+	}
+	for _, records := range fetchResponse.GetBlock("my_topic", 0).RecordsSet {
+		records.RecordBatch.Codec = CompressionSnappy
+	}
+
+	cfg := NewTestConfig()
+	cfg.Version = V0_11_0_0
+	cfg.ChannelBufferSize = 0
+	cfg.Consumer.Fetch.LazyDecompression = true
+
+	broker0 := NewMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse, &FetchResponse{Version: 4}),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	for offset := int64(1); offset <= 3; offset++ {
+		msg := <-consumer.Messages()
+		assertMessageOffset(t, msg, offset)
+		if string(msg.Value) != string(testMsg) {
+			t.Errorf("Expected the decompressed value, got %q", msg.Value)
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumeTopicIsolationLevels(t *testing.T) {
+	// Given
+	cfg := NewTestConfig()
 	cfg.Version = V0_11_0_0
+	cfg.Consumer.TopicIsolationLevels = map[string]IsolationLevel{"txn_topic": ReadCommitted}
 
 	broker0 := NewMockBroker(t, 0)
-
 	broker0.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
-			SetLeader("my_topic", 0, broker0.BrokerID()),
+			SetLeader("plain_topic", 0, broker0.BrokerID()).
+			SetLeader("txn_topic", 0, broker0.BrokerID()),
 		"OffsetRequest": NewMockOffsetResponse(t).
-			SetOffset("my_topic", 0, OffsetNewest, 1234).
-			SetOffset("my_topic", 0, OffsetOldest, 0),
-		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+			SetOffset("plain_topic", 0, OffsetNewest, 1234).
+			SetOffset("plain_topic", 0, OffsetOldest, 0).
+			SetOffset("txn_topic", 0, OffsetNewest, 1234).
+			SetOffset("txn_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("plain_topic", 0, 1, testMsg).
+			SetMessage("txn_topic", 0, 1, testMsg),
 	})
 
 	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
@@ -726,36 +1681,62 @@ func TestConsumerReceivingFetchResponseWithTooOldRecords(t *testing.T) {
 	}
 
 	// When
-	consumer, err := master.ConsumePartition("my_topic", 0, 2)
+	plain, err := master.ConsumePartition("plain_topic", 0, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	select {
-	case msg := <-consumer.Messages():
-		assertMessageOffset(t, msg, 1000000)
-	case err := <-consumer.Errors():
+	txn, err := master.ConsumePartition("txn_topic", 0, 1)
+	if err != nil {
 		t.Fatal(err)
 	}
+	assertMessageOffset(t, <-plain.Messages(), 1)
+	assertMessageOffset(t, <-txn.Messages(), 1)
 
-	safeClose(t, consumer)
+	safeClose(t, plain)
+	safeClose(t, txn)
 	safeClose(t, master)
 	broker0.Close()
+
+	// Then
+	levels := make(map[IsolationLevel]bool)
+	var immediate bool
+	for _, rr := range broker0.History() {
+		req, ok := rr.Request.(*FetchRequest)
+		if !ok {
+			continue
+		}
+		levels[req.Isolation] = true
+		for topic := range req.blocks {
+			if expected := cfg.isolationLevel(topic); req.Isolation != expected {
+				t.Errorf("Expected %s to be fetched with isolation level %d, got %d", topic, expected, req.Isolation)
+			}
+		}
+		if req.Isolation == ReadUncommitted && req.MaxWaitTime != int32(cfg.Consumer.MaxWaitTime/time.Millisecond) {
+			t.Errorf("Expected the first request of a fetch to wait for MaxWaitTime, got %d", req.MaxWaitTime)
+		}
+		immediate = immediate || req.Isolation == ReadCommitted && req.MaxWaitTime == 0
+	}
+	if !levels[ReadUncommitted] || !levels[ReadCommitted] {
+		t.Errorf("Expected fetch requests with both isolation levels, got %v", levels)
+	}
+	if !immediate {
+		t.Error("Expected the second request of a fetch not to wait")
+	}
 }
 
-func TestConsumeMessageWithNewerFetchAPIVersion(t *testing.T) {
+func TestConsumeIncrementalFetchSession(t *testing.T) {
 	// Given
-	fetchResponse1 := &FetchResponse{Version: 4}
+	fetchResponse1 := &FetchResponse{Version: 7, SessionID: 42}
 	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 1)
 	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 2)
+	fetchResponse2 := &FetchResponse{Version: 7, SessionID: 42}
+	fetchResponse3 := &FetchResponse{Version: 7, ErrorCode: int16(ErrFetchSessionIDNotFound)}
+	fetchResponse4 := &FetchResponse{Version: 7, SessionID: 43}
 
 	cfg := NewTestConfig()
-	cfg.Version = V0_11_0_0
+	cfg.Version = V1_1_0_0
 
 	broker0 := NewMockBroker(t, 0)
-	fetchResponse2 := &FetchResponse{}
-	fetchResponse2.Version = 4
-	fetchResponse2.AddError("my_topic", 0, ErrNoError)
 	broker0.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
@@ -763,7 +1744,7 @@ func TestConsumeMessageWithNewerFetchAPIVersion(t *testing.T) {
 		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 1234).
 			SetOffset("my_topic", 0, OffsetOldest, 0),
-		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2, fetchResponse3, fetchResponse4),
 	})
 
 	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
@@ -776,61 +1757,124 @@ func TestConsumeMessageWithNewerFetchAPIVersion(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-
 	assertMessageOffset(t, <-consumer.Messages(), 1)
 	assertMessageOffset(t, <-consumer.Messages(), 2)
 
+	var fetches []*FetchRequest
+	for deadline := time.Now().Add(5 * time.Second); len(fetches) < 5 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		fetches = fetches[:0]
+		for _, rr := range broker0.History() {
+			if req, ok := rr.Request.(*FetchRequest); ok {
+				fetches = append(fetches, req)
+			}
+		}
+	}
+
 	safeClose(t, consumer)
 	safeClose(t, master)
 	broker0.Close()
+
+	// Then
+	if len(fetches) < 5 {
+		t.Fatalf("Expected at least 5 fetch requests, got %d", len(fetches))
+	}
+	expected := []struct {
+		sessionID, epoch int32
+		fetchOffset      int64 // -1 if the partition must not be listed
+	}{
+		{0, 0, 1},   // full request creating the session
+		{42, 1, 3},  // the fetch offset changed
+		{42, 2, -1}, // nothing changed
+		{0, 0, 3},   // full request after the session was not found
+		{43, 1, -1},
+	}
+	for i, exp := range expected {
+		req := fetches[i]
+		if req.SessionID != exp.sessionID || req.SessionEpoch != exp.epoch {
+			t.Errorf("Fetch %d: expected session %d epoch %d, got session %d epoch %d",
+				i, exp.sessionID, exp.epoch, req.SessionID, req.SessionEpoch)
+		}
+		block := req.blocks["my_topic"][0]
+		switch {
+		case exp.fetchOffset < 0 && block != nil:
+			t.Errorf("Fetch %d: expected the unchanged partition not to be listed", i)
+		case exp.fetchOffset >= 0 && (block == nil || block.fetchOffset != exp.fetchOffset):
+			t.Errorf("Fetch %d: expected the partition at offset %d, got %+v", i, exp.fetchOffset, block)
+		}
+	}
 }
 
-func TestConsumeMessageWithSessionIDs(t *testing.T) {
+func TestConsumeRecreatedTopicID(t *testing.T) {
 	// Given
-	fetchResponse1 := &FetchResponse{Version: 7}
-	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 1)
-	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 2)
+	id1 := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	id2 := Uuid{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20}
 
 	cfg := NewTestConfig()
-	cfg.Version = V1_1_0_0
+	cfg.Version = V3_1_0_0
 
 	broker0 := NewMockBroker(t, 0)
-	fetchResponse2 := &FetchResponse{}
-	fetchResponse2.Version = 7
-	fetchResponse2.AddError("my_topic", 0, ErrNoError)
-
+	defer broker0.Close()
 	broker0.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
-			SetLeader("my_topic", 0, broker0.BrokerID()),
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetTopicID("my_topic", id1),
 		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 1234).
 			SetOffset("my_topic", 0, OffsetOldest, 0),
-		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetTopicID("my_topic", id1).
+			SetMessage("my_topic", 0, 1, testMsg),
 	})
 
 	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// When
 	consumer, err := master.ConsumePartition("my_topic", 0, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
-
 	assertMessageOffset(t, <-consumer.Messages(), 1)
-	assertMessageOffset(t, <-consumer.Messages(), 2)
 
-	safeClose(t, consumer)
-	safeClose(t, master)
-	broker0.Close()
+	// When the topic is deleted and created again
+	unknownTopicID := &FetchResponse{Version: 13}
+	unknownTopicID.SetTopicID("my_topic", id1)
+	unknownTopicID.AddError("my_topic", 0, ErrUnknownTopicID)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetTopicID("my_topic", id2),
+		"FetchRequest": NewMockSequence(unknownTopicID, NewMockFetchResponse(t, 1).
+			SetTopicID("my_topic", id2).
+			SetMessage("my_topic", 0, 2, testMsg)),
+	})
 
-	fetchReq := broker0.History()[3].Request.(*FetchRequest)
-	if fetchReq.SessionID != 0 || fetchReq.SessionEpoch != -1 {
-		t.Error("Expected session ID to be zero & Epoch to be -1")
+	// Then the consumer resolves the new topic ID and resumes consuming
+	select {
+	case msg := <-consumer.Messages():
+		assertMessageOffset(t, msg, 2)
+	case err := <-consumer.Errors():
+		t.Fatalf("unexpected error %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message of the recreated topic")
+	}
+
+	var last *FetchRequest
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*FetchRequest); ok {
+			last = req
+		}
 	}
+	if last == nil || last.Version != 13 || last.topicIDs[id2.String()] != id2 {
+		t.Errorf("expected the last fetch request of version 13 to refer to the new topic ID, got %+v", last)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
 }
 
 func TestConsumeMessagesFromReadReplica(t *testing.T) {
@@ -1030,6 +2074,80 @@ func TestConsumeMessagesFromReadReplicaErrorReplicaNotAvailable(t *testing.T) {
 	leader.Close()
 }
 
+// TestConsumeMessagesFromReadReplicaErrorOffsetOutOfRange ensures that an offset
+// out of range on a read replica, which may be lagging behind the leader, makes
+// the consumer fall back to the leader rather than shut down.
+func TestConsumeMessagesFromReadReplicaErrorOffsetOutOfRange(t *testing.T) {
+	// Given
+	fetchResponse1 := &FetchResponse{Version: 11}
+	block1 := fetchResponse1.getOrCreateBlock("my_topic", 0)
+	block1.PreferredReadReplica = 1
+
+	fetchResponse2 := &FetchResponse{Version: 11}
+	fetchResponse2.AddMessage("my_topic", 0, nil, testMsg, 1)
+	fetchResponse2.AddMessage("my_topic", 0, nil, testMsg, 2)
+	block2 := fetchResponse2.GetBlock("my_topic", 0)
+	block2.PreferredReadReplica = -1
+
+	fetchResponse3 := &FetchResponse{Version: 11}
+	fetchResponse3.AddError("my_topic", 0, ErrOffsetOutOfRange)
+
+	fetchResponse4 := &FetchResponse{Version: 11}
+	fetchResponse4.AddMessage("my_topic", 0, nil, testMsg, 3)
+	fetchResponse4.AddMessage("my_topic", 0, nil, testMsg, 4)
+
+	cfg := NewConfig()
+	cfg.Version = V2_3_0_0
+	cfg.RackID = "consumer_rack"
+
+	leader := NewMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 1)
+
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(leader.Addr(), leader.BrokerID()).
+			SetLeader("my_topic", 0, leader.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse4),
+	})
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetBroker(leader.Addr(), leader.BrokerID()).
+			SetLeader("my_topic", 0, leader.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse2, fetchResponse3),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	assertMessageOffset(t, <-consumer.Messages(), 1)
+	assertMessageOffset(t, <-consumer.Messages(), 2)
+	assertMessageOffset(t, <-consumer.Messages(), 3)
+	assertMessageOffset(t, <-consumer.Messages(), 4)
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+	leader.Close()
+}
+
 func TestConsumeMessagesFromReadReplicaErrorUnknown(t *testing.T) {
 	// Given
 	fetchResponse1 := &FetchResponse{Version: 11}
@@ -1809,6 +2927,7 @@ func TestExcludeUncommitted(t *testing.T) {
 		Version: 4,
 		Blocks: map[string]map[int32]*FetchResponseBlock{"my_topic": {0: {
 			AbortedTransactions: []*AbortedTransaction{{ProducerID: 7, FirstOffset: 1235}},
+			LastStableOffset:    1239,
 		}}},
 	}
 	fetchResponse.AddRecordBatch("my_topic", 0, nil, testMsg, 1234, 7, true)   // committed msg
@@ -1847,21 +2966,155 @@ func TestExcludeUncommitted(t *testing.T) {
 	select {
 	case message := <-consumer.Messages():
 		assertMessageOffset(t, message, int64(1234))
+		if len(message.SkippedAborted) != 0 {
+			t.Errorf("Expected no skipped aborted records, got %+v", message.SkippedAborted)
+		}
 	case err := <-consumer.Errors():
 		t.Error(err)
 	}
 	select {
 	case message := <-consumer.Messages():
 		assertMessageOffset(t, message, int64(1238))
+		// and the aborted ones are reported with the next committed message
+		expected := []AbortedRange{{ProducerID: 7, FirstOffset: 1235, LastOffset: 1236, Records: 2}}
+		if !reflect.DeepEqual(message.SkippedAborted, expected) {
+			t.Errorf("Expected skipped aborted records %+v, got %+v", expected, message.SkippedAborted)
+		}
 	case err := <-consumer.Errors():
 		t.Error(err)
 	}
+	if lso := consumer.LastStableOffset(); lso != 1239 {
+		t.Errorf("Expected last stable offset 1239, got %d", lso)
+	}
 
 	safeClose(t, consumer)
 	safeClose(t, master)
 	broker0.Close()
 }
 
+// TestConsumerFetchedSkipsControlRecords ensures that the fetched offset tracked for a
+// graceful close follows the last message delivered, not a trailing control record.
+func TestConsumerFetchedSkipsControlRecords(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	fetchResponse := &FetchResponse{
+		Version: 4,
+		Blocks: map[string]map[int32]*FetchResponseBlock{"my_topic": {0: {
+			LastStableOffset: 1236,
+		}}},
+	}
+	fetchResponse.AddRecordBatch("my_topic", 0, nil, testMsg, 1234, 7, true)    // committed msg
+	fetchResponse.AddControlRecord("my_topic", 0, 1235, 7, ControlRecordCommit) // commit control record
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 1236),
+		"FetchRequest": NewMockWrapper(fetchResponse),
+	})
+
+	cfg := NewTestConfig()
+	cfg.Version = V0_11_0_0
+	cfg.Consumer.IsolationLevel = ReadCommitted
+
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	select {
+	case message := <-consumer.Messages():
+		assertMessageOffset(t, message, 1234)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+	if fetched := atomic.LoadInt64(&consumer.(*partitionConsumer).fetched); fetched != 1235 {
+		t.Errorf("expected the fetched offset to follow the last message, got %d", fetched)
+	}
+}
+
+// heldFetchResponse holds the FetchRequests after the first one until release is closed,
+// signalling held when it begins to.
+type heldFetchResponse struct {
+	MockResponse
+	fetches int32
+	held    chan none
+	release chan none
+}
+
+func (r *heldFetchResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	if atomic.AddInt32(&r.fetches, 1) == 2 {
+		close(r.held)
+		<-r.release
+	}
+	return r.MockResponse.For(reqBody)
+}
+
+// TestConsumerFetchingInFlight ensures that a partition paused while a fetch for it is
+// in flight is reported as fetching until the response was fed to it.
+func TestConsumerFetchingInFlight(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	fetchResponse := &heldFetchResponse{
+		MockResponse: NewMockFetchResponse(t, 1).SetMessage("my_topic", 0, 0, testMsg),
+		held:         make(chan none),
+		release:      make(chan none),
+	}
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 1),
+		"FetchRequest": fetchResponse,
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	select {
+	case <-fetchResponse.held:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the second fetch")
+	}
+	consumer.Pause()
+	child := consumer.(*partitionConsumer)
+	if atomic.LoadInt32(&child.fetching) == 0 {
+		t.Error("expected the partition to be fetching while the request is in flight")
+	}
+
+	close(fetchResponse.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&child.fetching) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the partition to stop fetching once paused")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func assertMessageKey(t *testing.T, msg *ConsumerMessage, expectedKey Encoder) {
 	t.Helper()
 
@@ -2011,6 +3264,58 @@ func Test_partitionConsumer_parseResponseEmptyBatch(t *testing.T) {
 	}
 }
 
+func Test_partitionConsumer_lazyDecompression(t *testing.T) {
+	response := &FetchResponse{Version: 4}
+	response.AddRecordBatch("my_topic", 0, nil, StringEncoder("foo"), 0, 0, false)
+	response.AddRecordBatch("my_topic", 0, nil, StringEncoder("bar"), 1, 0, false)
+	for _, records := range response.GetBlock("my_topic", 0).RecordsSet {
+		records.RecordBatch.Codec = CompressionGZIP
+	}
+	buf, err := encode(response, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy := &FetchResponse{lazyDecompression: true}
+	if err := versionedDecode(buf, lazy, 4, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	child := &partitionConsumer{
+		broker: &brokerConsumer{
+			broker: &Broker{},
+		},
+		conf:      NewConfig(),
+		topic:     "my_topic",
+		partition: 0,
+	}
+	records, err := child.parseBlock(lazy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := lazy.GetBlock("my_topic", 0).RecordsSet
+	for i, set := range sets {
+		if set.RecordBatch.compressedRecords == nil {
+			t.Errorf("record batch %d should be kept compressed once fetched", i)
+		}
+	}
+
+	for i, expected := range []string{"foo", "bar"} {
+		msgs, err := child.nextMessages(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 || string(msgs[0].Value) != expected || msgs[0].Offset != int64(i) {
+			t.Fatalf("expected message %s at offset %d, got %v", expected, i, msgs)
+		}
+		if i == 0 && sets[1].RecordBatch.compressedRecords == nil {
+			t.Error("the next record batch should only be decompressed once the previous one is delivered")
+		}
+	}
+	if len(records.sets) != 0 {
+		t.Errorf("expected all the record sets to be parsed, %d remain", len(records.sets))
+	}
+}
+
 func testConsumerInterceptor(
 	t *testing.T,
 	interceptors []ConsumerInterceptor,
@@ -0,0 +1,58 @@
+package sarama
+
+import "time"
+
+// CreateDelegationTokenRequest asks for a new delegation token owned by the principal of the
+// connection.
+type CreateDelegationTokenRequest struct {
+	// Version 0 and 1 are equal
+	// The version number is bumped to indicate that on quota violation brokers send out responses before throttling.
+	Version int16
+	// Renewers are the principals allowed to renew the token besides its owner.
+	Renewers []DelegationTokenPrincipal
+	// MaxLifetime is the maximum lifetime of the token, or a negative duration to use the
+	// delegation.token.max.lifetime.ms of the broker.
+	MaxLifetime time.Duration
+}
+
+func (r *CreateDelegationTokenRequest) encode(pe packetEncoder) error {
+	if err := encodeDelegationTokenPrincipals(pe, r.Renewers); err != nil {
+		return err
+	}
+	pe.putInt64(durationToMillis(r.MaxLifetime))
+	return nil
+}
+
+func (r *CreateDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Renewers, err = decodeDelegationTokenPrincipals(pd); err != nil {
+		return err
+	}
+	maxLifetime, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.MaxLifetime = millisToDuration(maxLifetime)
+	return nil
+}
+
+func (r *CreateDelegationTokenRequest) key() int16 {
+	return 38
+}
+
+func (r *CreateDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *CreateDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *CreateDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
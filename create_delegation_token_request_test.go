@@ -0,0 +1,32 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createDelegationTokenRequestNoRenewers = []byte{
+		0, 0, 0, 0, // no renewers
+		255, 255, 255, 255, 255, 255, 255, 255, // default max lifetime
+	}
+
+	createDelegationTokenRequestWithRenewers = []byte{
+		0, 0, 0, 1, // 1 renewer
+		0, 4, 'U', 's', 'e', 'r', // principal type
+		0, 3, 'b', 'o', 'b', // principal name
+		0, 0, 0, 0, 0, 0, 0x0e, 0x10, // max lifetime: 3600ms
+	}
+)
+
+func TestCreateDelegationTokenRequest(t *testing.T) {
+	request := &CreateDelegationTokenRequest{MaxLifetime: -1}
+	testRequest(t, "no renewers", request, createDelegationTokenRequestNoRenewers)
+
+	request = &CreateDelegationTokenRequest{
+		Version:     1,
+		Renewers:    []DelegationTokenPrincipal{{Type: "User", Name: "bob"}},
+		MaxLifetime: 3600 * time.Millisecond,
+	}
+	testRequest(t, "with renewers", request, createDelegationTokenRequestWithRenewers)
+}
@@ -0,0 +1,86 @@
+package sarama
+
+import "time"
+
+// CreateDelegationTokenResponse is the token created by a CreateDelegationTokenRequest.
+type CreateDelegationTokenResponse struct {
+	Version      int16
+	Err          KError
+	Owner        DelegationTokenPrincipal
+	IssueTime    time.Time
+	ExpiryTime   time.Time
+	MaxTime      time.Time
+	TokenID      string
+	HMAC         []byte
+	ThrottleTime time.Duration
+}
+
+func (r *CreateDelegationTokenResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	if err := r.Owner.encode(pe); err != nil {
+		return err
+	}
+	pe.putInt64(timeToMillis(r.IssueTime))
+	pe.putInt64(timeToMillis(r.ExpiryTime))
+	pe.putInt64(timeToMillis(r.MaxTime))
+	if err := pe.putString(r.TokenID); err != nil {
+		return err
+	}
+	if err := pe.putBytes(r.HMAC); err != nil {
+		return err
+	}
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return nil
+}
+
+func (r *CreateDelegationTokenResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+	if err := r.Owner.decode(pd); err != nil {
+		return err
+	}
+	if r.IssueTime, r.ExpiryTime, r.MaxTime, err = decodeDelegationTokenTimes(pd); err != nil {
+		return err
+	}
+	if r.TokenID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.HMAC, err = pd.getBytes(); err != nil {
+		return err
+	}
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+	return nil
+}
+
+func (r *CreateDelegationTokenResponse) key() int16 {
+	return 38
+}
+
+func (r *CreateDelegationTokenResponse) version() int16 {
+	return r.Version
+}
+
+func (r *CreateDelegationTokenResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *CreateDelegationTokenResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
+
+func (r *CreateDelegationTokenResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
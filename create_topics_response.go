@@ -85,6 +85,10 @@ func (c *CreateTopicsResponse) requiredVersion() KafkaVersion {
 	}
 }
 
+func (c *CreateTopicsResponse) throttleTime() time.Duration {
+	return c.ThrottleTime
+}
+
 type TopicError struct {
 	Err    KError
 	ErrMsg *string
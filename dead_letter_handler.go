@@ -0,0 +1,159 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Header keys stamped on messages republished by a DeadLetterHandler.
+const (
+	// DeadLetterErrorHeader holds the error that caused the message to be dead-lettered.
+	DeadLetterErrorHeader = "sarama-dlq-error"
+	// DeadLetterTopicHeader holds the topic the message was consumed from.
+	DeadLetterTopicHeader = "sarama-dlq-topic"
+	// DeadLetterPartitionHeader holds the partition the message was consumed from, as a decimal string.
+	DeadLetterPartitionHeader = "sarama-dlq-partition"
+	// DeadLetterOffsetHeader holds the offset of the message in its partition, as a decimal string.
+	DeadLetterOffsetHeader = "sarama-dlq-offset"
+)
+
+// MessageHandler processes a single consumed message.
+type MessageHandler func(sess ConsumerGroupSession, msg *ConsumerMessage) error
+
+// ErrorClassifier tells whether the error returned when processing msg is retriable. Messages
+// failing with errors that are not retriable are poison messages, which processing again won't fix.
+type ErrorClassifier func(msg *ConsumerMessage, err error) bool
+
+// DefaultErrorClassifier only considers context cancellations and deadlines as retriable, any other
+// error making the message a poison message.
+func DefaultErrorClassifier(msg *ConsumerMessage, err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// DeadLetterHandler is a ConsumerGroupHandler processing messages one at a time with a
+// MessageHandler, and dead-lettering the poison messages: they are republished to the dead
+// letter topic with headers describing the failure, and marked as consumed so that the
+// partition makes progress.
+//
+// Messages failing with retriable errors are processed again after RetryBackoff, up to
+// MaxRetries times, after which ConsumeClaim returns the error without marking the message,
+// which is then consumed again by the next session.
+type DeadLetterHandler struct {
+	// Classifier tells retriable errors apart from poison messages (defaults to
+	// DefaultErrorClassifier).
+	Classifier ErrorClassifier
+	// MaxRetries is the number of times a message failing with a retriable error is processed
+	// again (defaults to 3).
+	MaxRetries int
+	// RetryBackoff is how long to wait before processing a message again (defaults to 100ms).
+	RetryBackoff time.Duration
+
+	noopSetupCleanup
+	producer SyncProducer
+	topic    string
+	handle   MessageHandler
+}
+
+// NewDeadLetterHandler creates a DeadLetterHandler processing messages with handle and
+// republishing poison messages to deadLetterTopic through the given producer.
+func NewDeadLetterHandler(producer SyncProducer, deadLetterTopic string, handle MessageHandler) (*DeadLetterHandler, error) {
+	if producer == nil {
+		return nil, ConfigurationError("DeadLetterHandler requires a non-nil producer")
+	}
+	if deadLetterTopic == "" {
+		return nil, ConfigurationError("DeadLetterHandler requires a dead letter topic")
+	}
+	if handle == nil {
+		return nil, ConfigurationError("DeadLetterHandler requires a message handler")
+	}
+	return &DeadLetterHandler{
+		Classifier:   DefaultErrorClassifier,
+		MaxRetries:   3,
+		RetryBackoff: 100 * time.Millisecond,
+		producer:     producer,
+		topic:        deadLetterTopic,
+		handle:       handle,
+	}, nil
+}
+
+// ConsumeClaim implements ConsumerGroupHandler, processing the messages of the claim until it
+// is closed or a message keeps failing with a retriable error.
+func (h *DeadLetterHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	messages, stop := claimMessages(sess, claim)
+	defer stop()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			if err := h.process(sess, msg); err != nil {
+				return err
+			}
+			sess.MarkMessage(msg, "")
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process handles msg, retrying retriable errors and dead-lettering poison messages.
+func (h *DeadLetterHandler) process(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+	classify := h.Classifier
+	if classify == nil {
+		classify = DefaultErrorClassifier
+	}
+
+	for retries := 0; ; retries++ {
+		err := h.handle(sess, msg)
+		if err == nil {
+			return nil
+		}
+		if !classify(msg, err) {
+			return h.deadLetter(msg, err)
+		}
+		if retries >= h.MaxRetries {
+			return fmt.Errorf("kafka: unable to process message at offset %d of %s/%d: %w", msg.Offset, msg.Topic, msg.Partition, err)
+		}
+
+		select {
+		case <-time.After(h.RetryBackoff):
+		case <-sess.Context().Done():
+			return sess.Context().Err()
+		}
+	}
+}
+
+func (h *DeadLetterHandler) deadLetter(msg *ConsumerMessage, cause error) error {
+	Logger.Printf("dlq/%s dead-lettering message at offset %d of %s/%d: %v\n", h.topic, msg.Offset, msg.Topic, msg.Partition, cause)
+
+	out := &ProducerMessage{
+		Topic:   h.topic,
+		Headers: deadLetterHeaders(msg, cause),
+	}
+	if msg.Key != nil {
+		out.Key = ByteEncoder(msg.Key)
+	}
+	if msg.Value != nil {
+		out.Value = ByteEncoder(msg.Value)
+	}
+
+	_, _, err := h.producer.SendMessage(out)
+	return err
+}
+
+var deadLetterHeaderKeys = []string{DeadLetterErrorHeader, DeadLetterTopicHeader, DeadLetterPartitionHeader, DeadLetterOffsetHeader}
+
+// deadLetterHeaders copies the headers of a consumed message, replacing any previous dead letter
+// headers with the failure details of this message.
+func deadLetterHeaders(msg *ConsumerMessage, cause error) []RecordHeader {
+	return replaceHeaders(msg.Headers, deadLetterHeaderKeys,
+		RecordHeader{Key: []byte(DeadLetterErrorHeader), Value: []byte(cause.Error())},
+		RecordHeader{Key: []byte(DeadLetterTopicHeader), Value: []byte(msg.Topic)},
+		RecordHeader{Key: []byte(DeadLetterPartitionHeader), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		RecordHeader{Key: []byte(DeadLetterOffsetHeader), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+}
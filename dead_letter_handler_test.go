@@ -0,0 +1,148 @@
+package sarama
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type markingSession struct {
+	testRelaySession
+	marked []int64
+}
+
+func (s *markingSession) MarkMessage(msg *ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+var errTransient = errors.New("transient")
+
+func TestDeadLetterHandler(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	attempts := make(map[int64]int)
+	h, err := NewDeadLetterHandler(producer, "orders.dlq", func(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+		attempts[msg.Offset]++
+		switch string(msg.Value) {
+		case "poison":
+			return errors.New("invalid order")
+		case "flaky":
+			if attempts[msg.Offset] < 3 {
+				return errTransient
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Classifier = func(msg *ConsumerMessage, err error) bool { return errors.Is(err, errTransient) }
+	h.RetryBackoff = time.Millisecond
+
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 3)}
+	for i, value := range []string{"ok", "poison", "flaky"} {
+		claim.messages <- &ConsumerMessage{
+			Topic:     "orders",
+			Partition: 2,
+			Offset:    int64(i),
+			Value:     []byte(value),
+			Headers:   []*RecordHeader{{Key: []byte("trace-id"), Value: []byte("abc")}},
+		}
+	}
+	close(claim.messages)
+
+	sess := &markingSession{}
+	if err := h.ConsumeClaim(sess, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sess.marked) != 3 {
+		t.Errorf("expected all messages to be marked, got %v", sess.marked)
+	}
+	if attempts[2] != 3 {
+		t.Errorf("expected the flaky message to be processed 3 times, got %d", attempts[2])
+	}
+	if len(producer.sent) != 1 {
+		t.Fatalf("expected the poison message only to be dead-lettered, got %d messages", len(producer.sent))
+	}
+
+	sent := producer.sent[0]
+	if sent.Topic != "orders.dlq" {
+		t.Errorf("expected the message to be sent to orders.dlq, got %s", sent.Topic)
+	}
+	headers := make(map[string]string)
+	for _, header := range sent.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	expected := map[string]string{
+		"trace-id":                "abc",
+		DeadLetterErrorHeader:     "invalid order",
+		DeadLetterTopicHeader:     "orders",
+		DeadLetterPartitionHeader: "2",
+		DeadLetterOffsetHeader:    "1",
+	}
+	for key, value := range expected {
+		if headers[key] != value {
+			t.Errorf("expected header %s to be %q, got %q", key, value, headers[key])
+		}
+	}
+}
+
+func TestDeadLetterHandlerRetriesExhausted(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	h, err := NewDeadLetterHandler(producer, "orders.dlq", func(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+		return errTransient
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Classifier = func(msg *ConsumerMessage, err error) bool { return true }
+	h.MaxRetries = 1
+	h.RetryBackoff = time.Millisecond
+
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 1)}
+	claim.messages <- &ConsumerMessage{Topic: "orders", Value: []byte("a")}
+
+	sess := &markingSession{}
+	if err := h.ConsumeClaim(sess, claim); !errors.Is(err, errTransient) {
+		t.Errorf("expected the retriable error to be returned, got %v", err)
+	}
+	if len(sess.marked) != 0 || len(producer.sent) != 0 {
+		t.Error("expected the message to be neither marked nor dead-lettered")
+	}
+}
+
+type batchingPartitionConsumer struct {
+	PartitionConsumer
+	batches chan []*ConsumerMessage
+}
+
+func (pc batchingPartitionConsumer) Batches() <-chan []*ConsumerMessage { return pc.batches }
+
+func TestDeadLetterHandlerBatches(t *testing.T) {
+	h, err := NewDeadLetterHandler(&recordingSyncProducer{}, "orders.dlq", func(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTestConfig()
+	config.Consumer.Return.Batches = true
+	pc := batchingPartitionConsumer{batches: make(chan []*ConsumerMessage, 2)}
+	pc.batches <- []*ConsumerMessage{{Topic: "orders", Offset: 0}, {Topic: "orders", Offset: 1}}
+	pc.batches <- []*ConsumerMessage{{Topic: "orders", Offset: 2}}
+	close(pc.batches)
+	claim := &consumerGroupClaim{
+		topic:             "orders",
+		sess:              &consumerGroupSession{parent: &consumerGroup{config: config}},
+		PartitionConsumer: pc,
+	}
+
+	sess := &markingSession{}
+	if err := h.ConsumeClaim(sess, claim); err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.marked) != 3 || sess.marked[2] != 2 {
+		t.Errorf("expected the messages of every batch to be marked, got %v", sess.marked)
+	}
+}
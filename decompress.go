@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"compress/gzip"
 	"fmt"
-	"io"
 	"sync"
 
 	snappy "github.com/eapache/go-xerial-snappy"
@@ -22,40 +21,73 @@ var (
 )
 
 func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
-	switch cc {
-	case CompressionNone:
+	if cc == CompressionNone {
 		return data, nil
-	case CompressionGZIP:
-		var err error
-		reader, ok := gzipReaderPool.Get().(*gzip.Reader)
-		if !ok {
-			reader, err = gzip.NewReader(bytes.NewReader(data))
-		} else {
-			err = reader.Reset(bytes.NewReader(data))
-		}
-
-		if err != nil {
-			return nil, err
-		}
-
-		defer gzipReaderPool.Put(reader)
-
-		return io.ReadAll(reader)
-	case CompressionSnappy:
-		return snappy.Decode(data)
-	case CompressionLZ4:
-		reader, ok := lz4ReaderPool.Get().(*lz4.Reader)
-		if !ok {
-			reader = lz4.NewReader(bytes.NewReader(data))
-		} else {
-			reader.Reset(bytes.NewReader(data))
-		}
-		defer lz4ReaderPool.Put(reader)
-
-		return io.ReadAll(reader)
-	case CompressionZSTD:
-		return zstdDecompress(ZstdDecoderParams{}, nil, data)
-	default:
+	}
+	compressor := compressorFor(cc)
+	if compressor == nil {
 		return nil, PacketDecodingError{fmt.Sprintf("invalid compression specified (%d)", cc)}
 	}
+
+	buf := acquireCompressionBuffer()
+	out, err := compressor.Decompress((*buf)[:0], data)
+	if err != nil {
+		compressionBufferPool.Put(buf)
+		return nil, err
+	}
+	return releaseCompressionBuffer(buf, out), nil
+}
+
+func (gzipCompressor) Decompress(dst, data []byte) ([]byte, error) {
+	var err error
+	reader, ok := gzipReaderPool.Get().(*gzip.Reader)
+	if !ok {
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	} else {
+		err = reader.Reset(bytes.NewReader(data))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer gzipReaderPool.Put(reader)
+
+	buf := bytes.NewBuffer(dst)
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (snappyCompressor) Decompress(dst, data []byte) ([]byte, error) {
+	// DecodeInto writes from the start of the slice, decoding into its capacity if large enough
+	out, err := snappy.DecodeInto(dst[len(dst):cap(dst)], data)
+	if err != nil {
+		return nil, err
+	}
+	if len(dst) == 0 {
+		return out, nil
+	}
+	return append(dst, out...), nil
+}
+
+func (lz4Compressor) Decompress(dst, data []byte) ([]byte, error) {
+	reader, ok := lz4ReaderPool.Get().(*lz4.Reader)
+	if !ok {
+		reader = lz4.NewReader(bytes.NewReader(data))
+	} else {
+		reader.Reset(bytes.NewReader(data))
+	}
+	defer lz4ReaderPool.Put(reader)
+
+	buf := bytes.NewBuffer(dst)
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (zstdCompressor) Decompress(dst, data []byte) ([]byte, error) {
+	return zstdDecompress(ZstdDecoderParams{}, dst, data)
 }
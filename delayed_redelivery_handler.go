@@ -0,0 +1,100 @@
+package sarama
+
+import (
+	"time"
+)
+
+// DelayedRedeliveryHandler is a ConsumerGroupHandler for the retry topics a RetryPublisher
+// republishes failed messages to. Each message is only processed once the time stamped in its
+// RetryNotBeforeHeader header has passed, and messages failing again are escalated to the next
+// retry tier, or to the dead letter topic after the last tier, by the RetryPublisher.
+//
+// While waiting for a message to become due, the partition is paused rather than the claim
+// left idle with a full buffer, and the messages already fetched are held back so that they
+// are processed in order. Messages without retry headers are processed straight away.
+type DelayedRedeliveryHandler struct {
+	noopSetupCleanup
+	publisher *RetryPublisher
+	handle    MessageHandler
+	now       func() time.Time
+}
+
+// NewDelayedRedeliveryHandler creates a DelayedRedeliveryHandler processing due messages with
+// handle and escalating the ones which fail through publisher.
+func NewDelayedRedeliveryHandler(publisher *RetryPublisher, handle MessageHandler) (*DelayedRedeliveryHandler, error) {
+	if publisher == nil {
+		return nil, ConfigurationError("DelayedRedeliveryHandler requires a non-nil RetryPublisher")
+	}
+	if handle == nil {
+		return nil, ConfigurationError("DelayedRedeliveryHandler requires a message handler")
+	}
+	return &DelayedRedeliveryHandler{
+		publisher: publisher,
+		handle:    handle,
+		now:       time.Now,
+	}, nil
+}
+
+// ConsumeClaim implements ConsumerGroupHandler, processing the messages of the claim as they
+// become due until it is closed. It returns an error if a failed message can't be escalated.
+func (h *DelayedRedeliveryHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	var held []*ConsumerMessage // messages received while waiting for an earlier one
+	messages, stop := claimMessages(sess, claim)
+	defer stop()
+	for {
+		var msg *ConsumerMessage
+		if len(held) > 0 {
+			msg, held = held[0], held[1:]
+		} else {
+			select {
+			case m, ok := <-messages:
+				if !ok {
+					return nil
+				}
+				msg = m
+			case <-sess.Context().Done():
+				return nil
+			}
+		}
+
+		meta, err := ParseRetryMetadata(msg)
+		if err != nil {
+			Logger.Printf("retry/%s/%d processing message at offset %d without delay: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
+		} else if wait := meta.NotBefore.Sub(h.now()); wait > 0 {
+			if !h.delay(sess, msg, wait, messages, &held) {
+				return nil
+			}
+		}
+
+		if err := h.handle(sess, msg); err != nil {
+			if _, err := h.publisher.Retry(msg, err); err != nil {
+				return err
+			}
+		}
+		sess.MarkMessage(msg, "")
+	}
+}
+
+// delay pauses the partition of msg for the given duration, holding back the messages received
+// meanwhile. It returns false if the claim or the session ended first.
+func (h *DelayedRedeliveryHandler) delay(sess ConsumerGroupSession, msg *ConsumerMessage, wait time.Duration, messages <-chan *ConsumerMessage, held *[]*ConsumerMessage) bool {
+	partitions := map[string][]int32{msg.Topic: {msg.Partition}}
+	sess.Pause(partitions)
+	defer sess.Resume(partitions)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case m, ok := <-messages:
+			if !ok {
+				return false
+			}
+			*held = append(*held, m)
+		case <-sess.Context().Done():
+			return false
+		}
+	}
+}
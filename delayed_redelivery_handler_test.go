@@ -0,0 +1,72 @@
+package sarama
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type pausingSession struct {
+	markingSession
+	paused, resumed int
+}
+
+func (s *pausingSession) Pause(partitions map[string][]int32)  { s.paused++ }
+func (s *pausingSession) Resume(partitions map[string][]int32) { s.resumed++ }
+
+func TestDelayedRedeliveryHandler(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	publisher, err := NewRetryPublisher(producer, NewRetryTiers("orders", time.Minute, time.Hour), "orders.dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var processed []int64
+	h, err := NewDelayedRedeliveryHandler(publisher, func(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+		processed = append(processed, msg.Offset)
+		if string(msg.Value) == "fail" {
+			return errors.New("still failing")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	notBefore := func(at time.Time) []*RecordHeader {
+		return []*RecordHeader{
+			{Key: []byte(RetryAttemptHeader), Value: []byte("1")},
+			{Key: []byte(RetryOriginalTopicHeader), Value: []byte("orders")},
+			{Key: []byte(RetryNotBeforeHeader), Value: []byte(strconv.FormatInt(at.UnixNano()/int64(time.Millisecond), 10))},
+		}
+	}
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 3)}
+	claim.messages <- &ConsumerMessage{Topic: "orders.retry.1m", Offset: 0, Value: []byte("due"), Headers: notBefore(start.Add(-time.Second))}
+	claim.messages <- &ConsumerMessage{Topic: "orders.retry.1m", Offset: 1, Value: []byte("fail"), Headers: notBefore(start.Add(50 * time.Millisecond))}
+	claim.messages <- &ConsumerMessage{Topic: "orders.retry.1m", Offset: 2, Value: []byte("ok"), Headers: notBefore(start.Add(10 * time.Millisecond))}
+	// the claim is closed on rebalance only, which would stop the handler while it waits
+	time.AfterFunc(200*time.Millisecond, func() { close(claim.messages) })
+
+	sess := &pausingSession{}
+	if err := h.ConsumeClaim(sess, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the delayed message to wait for its due time, only waited %v", elapsed)
+	}
+	if len(processed) != 3 || processed[1] != 1 || processed[2] != 2 {
+		t.Errorf("expected the messages to be processed in order, got %v", processed)
+	}
+	if sess.paused != 1 || sess.resumed != 1 {
+		t.Errorf("expected the partition to be paused and resumed once, got %d and %d", sess.paused, sess.resumed)
+	}
+	if len(sess.marked) != 3 {
+		t.Errorf("expected all messages to be marked, got %v", sess.marked)
+	}
+	if len(producer.sent) != 1 || producer.sent[0].Topic != "orders.retry.1h" {
+		t.Fatalf("expected the failing message to be escalated to the next tier, got %v", producer.sent)
+	}
+}
@@ -0,0 +1,106 @@
+package sarama
+
+import "time"
+
+// DelegationTokenPrincipal is the owner or a renewer of a delegation token, such as the
+// principal of type "User" and name "alice".
+type DelegationTokenPrincipal struct {
+	Type string
+	Name string
+}
+
+// String returns the principal as Type:Name, as in ACLs.
+func (p DelegationTokenPrincipal) String() string {
+	return p.Type + ":" + p.Name
+}
+
+func (p *DelegationTokenPrincipal) encode(pe packetEncoder) error {
+	if err := pe.putString(p.Type); err != nil {
+		return err
+	}
+	return pe.putString(p.Name)
+}
+
+func (p *DelegationTokenPrincipal) decode(pd packetDecoder) (err error) {
+	if p.Type, err = pd.getString(); err != nil {
+		return err
+	}
+	p.Name, err = pd.getString()
+	return err
+}
+
+func encodeDelegationTokenPrincipals(pe packetEncoder, principals []DelegationTokenPrincipal) error {
+	if err := pe.putArrayLength(len(principals)); err != nil {
+		return err
+	}
+	for i := range principals {
+		if err := principals[i].encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeDelegationTokenPrincipals(pd packetDecoder) ([]DelegationTokenPrincipal, error) {
+	n, err := pd.getArrayLength()
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	principals := make([]DelegationTokenPrincipal, n)
+	for i := range principals {
+		if err := principals[i].decode(pd); err != nil {
+			return nil, err
+		}
+	}
+	return principals, nil
+}
+
+// DelegationToken is a delegation token, short-lived credentials that workloads authenticate
+// with using SASL/SCRAM, the token ID being the user name and the base64-encoded HMAC the
+// password.
+type DelegationToken struct {
+	TokenID string
+	HMAC    []byte
+	Owner   DelegationTokenPrincipal
+	// Renewers are the principals allowed to renew the token besides its owner.
+	Renewers []DelegationTokenPrincipal
+	// IssueTime is when the token was created, ExpiryTime when it expires unless it is
+	// renewed, and MaxTime when it expires anyway.
+	IssueTime  time.Time
+	ExpiryTime time.Time
+	MaxTime    time.Time
+}
+
+func decodeDelegationTokenTimes(pd packetDecoder) (issue, expiry, max time.Time, err error) {
+	var millis [3]int64
+	for i := range millis {
+		if millis[i], err = pd.getInt64(); err != nil {
+			return
+		}
+	}
+	return millisToTime(millis[0]), millisToTime(millis[1]), millisToTime(millis[2]), nil
+}
+
+func millisToTime(millis int64) time.Time {
+	return time.Unix(millis/1000, (millis%1000)*int64(time.Millisecond))
+}
+
+func timeToMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// durationToMillis converts the periods of delegation token requests, negative ones meaning
+// the default of the broker.
+func durationToMillis(d time.Duration) int64 {
+	if d < 0 {
+		return -1
+	}
+	return int64(d / time.Millisecond)
+}
+
+func millisToDuration(millis int64) time.Duration {
+	if millis < 0 {
+		return -1
+	}
+	return time.Duration(millis) * time.Millisecond
+}
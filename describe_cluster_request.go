@@ -0,0 +1,77 @@
+package sarama
+
+// DescribeClusterRequest (Version: 2) => include_cluster_authorized_operations endpoint_type include_fenced_brokers TAG_BUFFER
+//   include_cluster_authorized_operations => BOOLEAN
+//   endpoint_type => INT8
+//   include_fenced_brokers => BOOLEAN
+
+const (
+	// DescribeClusterEndpointBrokers describes the brokers of the cluster, the default.
+	DescribeClusterEndpointBrokers int8 = 1
+	// DescribeClusterEndpointControllers describes the KRaft controllers of the cluster.
+	DescribeClusterEndpointControllers int8 = 2
+)
+
+// DescribeClusterRequest describes the cluster, it can be sent to any broker.
+type DescribeClusterRequest struct {
+	Version                            int16
+	IncludeClusterAuthorizedOperations bool
+	// EndpointType is the type of nodes to describe, from version 1.
+	EndpointType int8
+	// IncludeFencedBrokers requests the fenced brokers too, from version 2.
+	IncludeFencedBrokers bool
+}
+
+func (r *DescribeClusterRequest) encode(pe packetEncoder) error {
+	pe.putBool(r.IncludeClusterAuthorizedOperations)
+	if r.Version >= 1 {
+		pe.putInt8(r.EndpointType)
+	}
+	if r.Version >= 2 {
+		pe.putBool(r.IncludeFencedBrokers)
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeClusterRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.IncludeClusterAuthorizedOperations, err = pd.getBool(); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		if r.EndpointType, err = pd.getInt8(); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 2 {
+		if r.IncludeFencedBrokers, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeClusterRequest) key() int16 {
+	return 60
+}
+
+func (r *DescribeClusterRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeClusterRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeClusterRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 2:
+		return V3_9_0_0
+	case 1:
+		return V3_7_0_0
+	default:
+		return V2_8_0_0
+	}
+}
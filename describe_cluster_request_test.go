@@ -0,0 +1,29 @@
+package sarama
+
+import "testing"
+
+var describeClusterRequest = []byte{
+	1, // include cluster authorized operations
+	0, // empty tagged fields
+}
+
+func TestDescribeClusterRequest(t *testing.T) {
+	request := &DescribeClusterRequest{IncludeClusterAuthorizedOperations: true}
+	testRequest(t, "authorized operations", request, describeClusterRequest)
+}
+
+var describeClusterRequestV2 = []byte{
+	0, // include cluster authorized operations
+	1, // endpoint type: brokers
+	1, // include fenced brokers
+	0, // empty tagged fields
+}
+
+func TestDescribeClusterRequestV2(t *testing.T) {
+	request := &DescribeClusterRequest{
+		Version:              2,
+		EndpointType:         DescribeClusterEndpointBrokers,
+		IncludeFencedBrokers: true,
+	}
+	testRequest(t, "fenced brokers", request, describeClusterRequestV2)
+}
@@ -0,0 +1,173 @@
+package sarama
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// DescribeClusterResponse is the description of the cluster returned for a
+// DescribeClusterRequest.
+type DescribeClusterResponse struct {
+	Version      int16
+	ThrottleTime time.Duration
+	ErrorCode    KError
+	ErrorMessage *string
+	// EndpointType is the type of the nodes described, from version 1.
+	EndpointType int8
+	ClusterID    string
+	ControllerID int32
+	Brokers      []*Broker
+	// Fenced are the IDs of the brokers of Brokers that are fenced, from version 2.
+	Fenced map[int32]bool
+	// ClusterAuthorizedOperations is the bitmask of the operations on the cluster the client
+	// is authorized to perform, if they were requested.
+	ClusterAuthorizedOperations int32
+}
+
+func (r *DescribeClusterResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.ErrorCode))
+	if err := pe.putNullableCompactString(r.ErrorMessage); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		pe.putInt8(r.EndpointType)
+	}
+	if err := pe.putCompactString(r.ClusterID); err != nil {
+		return err
+	}
+	pe.putInt32(r.ControllerID)
+
+	pe.putCompactArrayLength(len(r.Brokers))
+	for _, b := range r.Brokers {
+		host, portstr, err := net.SplitHostPort(b.addr)
+		if err != nil {
+			return err
+		}
+		port, err := strconv.ParseInt(portstr, 10, 32)
+		if err != nil {
+			return err
+		}
+		pe.putInt32(b.id)
+		if err := pe.putCompactString(host); err != nil {
+			return err
+		}
+		pe.putInt32(int32(port))
+		if err := pe.putNullableCompactString(b.rack); err != nil {
+			return err
+		}
+		if r.Version >= 2 {
+			pe.putBool(r.Fenced[b.id])
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putInt32(r.ClusterAuthorizedOperations)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeClusterResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.ErrorCode = KError(kerr)
+	if r.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		if r.EndpointType, err = pd.getInt8(); err != nil {
+			return err
+		}
+	}
+	if r.ClusterID, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if r.ControllerID, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.Brokers = make([]*Broker, n)
+	}
+	for i := 0; i < n; i++ {
+		b := new(Broker)
+		if b.id, err = pd.getInt32(); err != nil {
+			return err
+		}
+		host, err := pd.getCompactString()
+		if err != nil {
+			return err
+		}
+		port, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		if b.rack, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+		if r.Version >= 2 {
+			fenced, err := pd.getBool()
+			if err != nil {
+				return err
+			}
+			if fenced {
+				if r.Fenced == nil {
+					r.Fenced = make(map[int32]bool)
+				}
+				r.Fenced[b.id] = true
+			}
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		b.addr = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		r.Brokers[i] = b
+	}
+
+	if r.ClusterAuthorizedOperations, err = pd.getInt32(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeClusterResponse) key() int16 {
+	return 60
+}
+
+func (r *DescribeClusterResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeClusterResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeClusterResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 2:
+		return V3_9_0_0
+	case 1:
+		return V3_7_0_0
+	default:
+		return V2_8_0_0
+	}
+}
+
+func (r *DescribeClusterResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,82 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var describeClusterResponse = []byte{
+	0, 0, 0, 100, // throttle time
+	0, 0, // no error
+	0,                                    // no error message
+	8, 'c', 'l', 'u', 's', 't', 'e', 'r', // cluster ID
+	0, 0, 0, 1, // controller ID
+	3,          // 2 brokers
+	0, 0, 0, 1, // broker ID
+	5, 'h', 'o', 's', 't', // host
+	0, 0, 0x23, 0x84, // port: 9092
+	3, 'r', '1', // rack
+	0,          // empty tagged fields
+	0, 0, 0, 2, // broker ID
+	5, 'h', 'o', 's', 't', // host
+	0, 0, 0x23, 0x85, // port: 9093
+	0,                // no rack
+	0,                // empty tagged fields
+	0, 0, 0x01, 0x80, // cluster authorized operations: Alter and Describe
+	0, // empty tagged fields
+}
+
+func TestDescribeClusterResponse(t *testing.T) {
+	rack := "r1"
+	response := &DescribeClusterResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		ClusterID:    "cluster",
+		ControllerID: 1,
+		Brokers: []*Broker{
+			{id: 1, addr: "host:9092", rack: &rack},
+			{id: 2, addr: "host:9093"},
+		},
+		ClusterAuthorizedOperations: 1<<AclOperationAlter | 1<<AclOperationDescribe,
+	}
+	testResponse(t, "two brokers", response, describeClusterResponse)
+}
+
+var describeClusterResponseV2 = []byte{
+	0, 0, 0, 0, // throttle time
+	0, 0, // no error
+	0,                                    // no error message
+	1,                                    // endpoint type: brokers
+	8, 'c', 'l', 'u', 's', 't', 'e', 'r', // cluster ID
+	0, 0, 0, 1, // controller ID
+	3,          // 2 brokers
+	0, 0, 0, 1, // broker ID
+	5, 'h', 'o', 's', 't', // host
+	0, 0, 0x23, 0x84, // port: 9092
+	0,          // no rack
+	0,          // not fenced
+	0,          // empty tagged fields
+	0, 0, 0, 2, // broker ID
+	5, 'h', 'o', 's', 't', // host
+	0, 0, 0x23, 0x85, // port: 9093
+	0,                      // no rack
+	1,                      // fenced
+	0,                      // empty tagged fields
+	0x80, 0x00, 0x00, 0x00, // cluster authorized operations omitted
+	0, // empty tagged fields
+}
+
+func TestDescribeClusterResponseV2(t *testing.T) {
+	response := &DescribeClusterResponse{
+		Version:      2,
+		EndpointType: DescribeClusterEndpointBrokers,
+		ClusterID:    "cluster",
+		ControllerID: 1,
+		Brokers: []*Broker{
+			{id: 1, addr: "host:9092"},
+			{id: 2, addr: "host:9093"},
+		},
+		Fenced:                      map[int32]bool{2: true},
+		ClusterAuthorizedOperations: authorizedOperationsOmitted,
+	}
+	testResponse(t, "fenced broker", response, describeClusterResponseV2)
+}
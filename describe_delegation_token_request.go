@@ -0,0 +1,46 @@
+package sarama
+
+// DescribeDelegationTokenRequest lists the delegation tokens of some owners.
+type DescribeDelegationTokenRequest struct {
+	// Version 0 and 1 are equal
+	// The version number is bumped to indicate that on quota violation brokers send out responses before throttling.
+	Version int16
+	// Owners are the owners of the tokens to describe, all the tokens the principal of the
+	// connection may describe being returned if nil.
+	Owners []DelegationTokenPrincipal
+}
+
+func (r *DescribeDelegationTokenRequest) encode(pe packetEncoder) error {
+	if r.Owners == nil {
+		pe.putInt32(-1)
+		return nil
+	}
+	return encodeDelegationTokenPrincipals(pe, r.Owners)
+}
+
+func (r *DescribeDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	r.Owners, err = decodeDelegationTokenPrincipals(pd)
+	return err
+}
+
+func (r *DescribeDelegationTokenRequest) key() int16 {
+	return 41
+}
+
+func (r *DescribeDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
@@ -0,0 +1,23 @@
+package sarama
+
+import "testing"
+
+var (
+	describeDelegationTokenRequestAll = []byte{
+		255, 255, 255, 255, // all owners
+	}
+
+	describeDelegationTokenRequestOwner = []byte{
+		0, 0, 0, 1, // 1 owner
+		0, 4, 'U', 's', 'e', 'r', // principal type
+		0, 5, 'a', 'l', 'i', 'c', 'e', // principal name
+	}
+)
+
+func TestDescribeDelegationTokenRequest(t *testing.T) {
+	request := &DescribeDelegationTokenRequest{}
+	testRequest(t, "all owners", request, describeDelegationTokenRequestAll)
+
+	request = &DescribeDelegationTokenRequest{Owners: []DelegationTokenPrincipal{{Type: "User", Name: "alice"}}}
+	testRequest(t, "one owner", request, describeDelegationTokenRequestOwner)
+}
@@ -0,0 +1,105 @@
+package sarama
+
+import "time"
+
+// DescribeDelegationTokenResponse are the tokens listed by a DescribeDelegationTokenRequest.
+type DescribeDelegationTokenResponse struct {
+	Version      int16
+	Err          KError
+	Tokens       []DelegationToken
+	ThrottleTime time.Duration
+}
+
+func (r *DescribeDelegationTokenResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	if err := pe.putArrayLength(len(r.Tokens)); err != nil {
+		return err
+	}
+	for i := range r.Tokens {
+		token := &r.Tokens[i]
+		if err := token.Owner.encode(pe); err != nil {
+			return err
+		}
+		pe.putInt64(timeToMillis(token.IssueTime))
+		pe.putInt64(timeToMillis(token.ExpiryTime))
+		pe.putInt64(timeToMillis(token.MaxTime))
+		if err := pe.putString(token.TokenID); err != nil {
+			return err
+		}
+		if err := pe.putBytes(token.HMAC); err != nil {
+			return err
+		}
+		if err := encodeDelegationTokenPrincipals(pe, token.Renewers); err != nil {
+			return err
+		}
+	}
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return nil
+}
+
+func (r *DescribeDelegationTokenResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.Tokens = make([]DelegationToken, n)
+	}
+	for i := 0; i < n; i++ {
+		token := &r.Tokens[i]
+		if err := token.Owner.decode(pd); err != nil {
+			return err
+		}
+		if token.IssueTime, token.ExpiryTime, token.MaxTime, err = decodeDelegationTokenTimes(pd); err != nil {
+			return err
+		}
+		if token.TokenID, err = pd.getString(); err != nil {
+			return err
+		}
+		if token.HMAC, err = pd.getBytes(); err != nil {
+			return err
+		}
+		if token.Renewers, err = decodeDelegationTokenPrincipals(pd); err != nil {
+			return err
+		}
+	}
+
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+	return nil
+}
+
+func (r *DescribeDelegationTokenResponse) key() int16 {
+	return 41
+}
+
+func (r *DescribeDelegationTokenResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeDelegationTokenResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *DescribeDelegationTokenResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
+
+func (r *DescribeDelegationTokenResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
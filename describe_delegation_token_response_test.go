@@ -0,0 +1,37 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var describeDelegationTokenResponse = []byte{
+	0, 0, // no error
+	0, 0, 0, 1, // 1 token
+	0, 4, 'U', 's', 'e', 'r', // owner type
+	0, 5, 'a', 'l', 'i', 'c', 'e', // owner name
+	0, 0, 0, 0, 0, 0, 0x03, 0xe8, // issue time
+	0, 0, 0, 0, 0, 0, 0x07, 0xd0, // expiry time
+	0, 0, 0, 0, 0, 0, 0x0b, 0xb8, // max time
+	0, 2, 'i', 'd', // token ID
+	0, 0, 0, 3, 1, 2, 3, // HMAC
+	0, 0, 0, 1, // 1 renewer
+	0, 4, 'U', 's', 'e', 'r', // renewer type
+	0, 3, 'b', 'o', 'b', // renewer name
+	0, 0, 0, 0, // throttle time
+}
+
+func TestDescribeDelegationTokenResponse(t *testing.T) {
+	response := &DescribeDelegationTokenResponse{
+		Tokens: []DelegationToken{{
+			TokenID:    "id",
+			HMAC:       []byte{1, 2, 3},
+			Owner:      DelegationTokenPrincipal{Type: "User", Name: "alice"},
+			Renewers:   []DelegationTokenPrincipal{{Type: "User", Name: "bob"}},
+			IssueTime:  time.Unix(1, 0),
+			ExpiryTime: time.Unix(2, 0),
+			MaxTime:    time.Unix(3, 0),
+		}},
+	}
+	testResponse(t, "one token", response, describeDelegationTokenResponse)
+}
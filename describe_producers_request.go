@@ -0,0 +1,68 @@
+package sarama
+
+// DescribeProducersRequest (Version: 0) => [topics] TAG_BUFFER
+//   topics => name [partition_indexes] TAG_BUFFER
+//     name => COMPACT_STRING
+//     partition_indexes => INT32
+
+// DescribeProducersRequest describes the producers writing to partitions, it is sent to the
+// leaders of the partitions.
+type DescribeProducersRequest struct {
+	Version int16
+	// Topics are the partitions to describe by topic.
+	Topics map[string][]int32
+}
+
+func (r *DescribeProducersRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.Topics))
+	for topic, partitions := range r.Topics {
+		if err := pe.putCompactString(topic); err != nil {
+			return err
+		}
+		if err := pe.putCompactInt32Array(partitions); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeProducersRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string][]int32, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getCompactString()
+		if err != nil {
+			return err
+		}
+		if r.Topics[topic], err = pd.getCompactInt32Array(); err != nil {
+			return err
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersRequest) key() int16 {
+	return 61
+}
+
+func (r *DescribeProducersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeProducersRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeProducersRequest) requiredVersion() KafkaVersion {
+	return V2_8_0_0
+}
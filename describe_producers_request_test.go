@@ -0,0 +1,16 @@
+package sarama
+
+import "testing"
+
+var describeProducersRequest = []byte{
+	2,                          // 1 topic
+	6, 't', 'o', 'p', 'i', 'c', // topic name
+	3, 0, 0, 0, 0, 0, 0, 0, 1, // partitions 0 and 1
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeProducersRequest(t *testing.T) {
+	request := &DescribeProducersRequest{Topics: map[string][]int32{"topic": {0, 1}}}
+	testRequest(t, "one topic", request, describeProducersRequest)
+}
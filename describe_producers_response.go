@@ -0,0 +1,173 @@
+package sarama
+
+import "time"
+
+// ProducerState is the state of an active producer of a partition.
+type ProducerState struct {
+	ProducerID    int64
+	ProducerEpoch int32
+	LastSequence  int32
+	// LastTimestamp is the timestamp in milliseconds of the last record the producer wrote.
+	LastTimestamp    int64
+	CoordinatorEpoch int32
+	// CurrentTxnStartOffset is the offset of the first record of the open transaction of the
+	// producer, or -1 if it has none.
+	CurrentTxnStartOffset int64
+}
+
+func (p *ProducerState) encode(pe packetEncoder) error {
+	pe.putInt64(p.ProducerID)
+	pe.putInt32(p.ProducerEpoch)
+	pe.putInt32(p.LastSequence)
+	pe.putInt64(p.LastTimestamp)
+	pe.putInt32(p.CoordinatorEpoch)
+	pe.putInt64(p.CurrentTxnStartOffset)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (p *ProducerState) decode(pd packetDecoder) (err error) {
+	if p.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if p.ProducerEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.LastSequence, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.LastTimestamp, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if p.CoordinatorEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.CurrentTxnStartOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// DescribeProducersPartition are the active producers of a partition.
+type DescribeProducersPartition struct {
+	ErrorCode       KError
+	ErrorMessage    *string
+	ActiveProducers []ProducerState
+}
+
+// DescribeProducersResponse are the producers of the partitions of a DescribeProducersRequest.
+type DescribeProducersResponse struct {
+	Version      int16
+	ThrottleTime time.Duration
+	Topics       map[string]map[int32]*DescribeProducersPartition
+}
+
+func (r *DescribeProducersResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putCompactArrayLength(len(r.Topics))
+	for topic, partitions := range r.Topics {
+		if err := pe.putCompactString(topic); err != nil {
+			return err
+		}
+		pe.putCompactArrayLength(len(partitions))
+		for partition, block := range partitions {
+			pe.putInt32(partition)
+			pe.putInt16(int16(block.ErrorCode))
+			if err := pe.putNullableCompactString(block.ErrorMessage); err != nil {
+				return err
+			}
+			pe.putCompactArrayLength(len(block.ActiveProducers))
+			for i := range block.ActiveProducers {
+				if err := block.ActiveProducers[i].encode(pe); err != nil {
+					return err
+				}
+			}
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeProducersResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string]map[int32]*DescribeProducersPartition, numTopics)
+	for i := 0; i < numTopics; i++ {
+		topic, err := pd.getCompactString()
+		if err != nil {
+			return err
+		}
+		numPartitions, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Topics[topic] = make(map[int32]*DescribeProducersPartition, numPartitions)
+		for j := 0; j < numPartitions; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			block := &DescribeProducersPartition{ErrorCode: KError(kerr)}
+			if block.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+				return err
+			}
+			numProducers, err := pd.getCompactArrayLength()
+			if err != nil {
+				return err
+			}
+			if numProducers > 0 {
+				block.ActiveProducers = make([]ProducerState, numProducers)
+			}
+			for k := 0; k < numProducers; k++ {
+				if err := block.ActiveProducers[k].decode(pd); err != nil {
+					return err
+				}
+			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+			r.Topics[topic][partition] = block
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersResponse) key() int16 {
+	return 61
+}
+
+func (r *DescribeProducersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeProducersResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeProducersResponse) requiredVersion() KafkaVersion {
+	return V2_8_0_0
+}
+
+func (r *DescribeProducersResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,42 @@
+package sarama
+
+import "testing"
+
+var describeProducersResponse = []byte{
+	0, 0, 0, 0, // throttle time
+	2,                          // 1 topic
+	6, 't', 'o', 'p', 'i', 'c', // topic name
+	2,          // 1 partition
+	0, 0, 0, 1, // partition 1
+	0, 0, // no error
+	0,                       // null error message
+	2,                       // 1 producer
+	0, 0, 0, 0, 0, 0, 0, 42, // producer ID
+	0, 0, 0, 3, // producer epoch
+	0, 0, 0, 9, // last sequence
+	0, 0, 0, 0, 0, 0, 0x03, 0xe8, // last timestamp
+	0, 0, 0, 5, // coordinator epoch
+	0, 0, 0, 0, 0, 0, 0, 100, // current transaction start offset
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeProducersResponse(t *testing.T) {
+	response := &DescribeProducersResponse{
+		Topics: map[string]map[int32]*DescribeProducersPartition{
+			"topic": {1: {
+				ActiveProducers: []ProducerState{{
+					ProducerID:            42,
+					ProducerEpoch:         3,
+					LastSequence:          9,
+					LastTimestamp:         1000,
+					CoordinatorEpoch:      5,
+					CurrentTxnStartOffset: 100,
+				}},
+			}},
+		},
+	}
+	testResponse(t, "one producer", response, describeProducersResponse)
+}
@@ -0,0 +1,87 @@
+package sarama
+
+// DescribeQuorumRequest (Version: 1) => [topics] TAG_BUFFER
+//   topics => topic_name [partitions] TAG_BUFFER
+//     topic_name => COMPACT_STRING
+//     partitions => partition_index TAG_BUFFER
+//       partition_index => INT32
+
+// clusterMetadataTopic is the topic of the metadata log of KRaft clusters, whose single
+// partition is replicated by the Raft quorum of the controllers.
+const clusterMetadataTopic = "__cluster_metadata"
+
+// DescribeQuorumRequest describes the Raft quorum replicating partitions of a KRaft cluster,
+// i.e. the partition 0 of the __cluster_metadata topic. It can be sent to any broker.
+type DescribeQuorumRequest struct {
+	Version int16
+	// Topics are the partitions to describe by topic.
+	Topics map[string][]int32
+}
+
+func (r *DescribeQuorumRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.Topics))
+	for topic, partitions := range r.Topics {
+		if err := pe.putCompactString(topic); err != nil {
+			return err
+		}
+		pe.putCompactArrayLength(len(partitions))
+		for _, partition := range partitions {
+			pe.putInt32(partition)
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeQuorumRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string][]int32, numTopics)
+	for i := 0; i < numTopics; i++ {
+		topic, err := pd.getCompactString()
+		if err != nil {
+			return err
+		}
+		numPartitions, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		partitions := make([]int32, numPartitions)
+		for j := range partitions {
+			if partitions[j], err = pd.getInt32(); err != nil {
+				return err
+			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+		r.Topics[topic] = partitions
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeQuorumRequest) key() int16 {
+	return 55
+}
+
+func (r *DescribeQuorumRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeQuorumRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeQuorumRequest) requiredVersion() KafkaVersion {
+	// brokers only forward the request to the controllers from 3.3, when version 1 was added
+	return V3_3_0_0
+}
@@ -0,0 +1,21 @@
+package sarama
+
+import "testing"
+
+var describeQuorumRequest = []byte{
+	2,                                                                                            // 1 topic
+	19, '_', '_', 'c', 'l', 'u', 's', 't', 'e', 'r', '_', 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', // topic name
+	2,          // 1 partition
+	0, 0, 0, 0, // partition 0
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeQuorumRequest(t *testing.T) {
+	request := &DescribeQuorumRequest{
+		Version: 1,
+		Topics:  map[string][]int32{clusterMetadataTopic: {0}},
+	}
+	testRequest(t, "metadata partition", request, describeQuorumRequest)
+}
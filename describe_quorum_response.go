@@ -0,0 +1,183 @@
+package sarama
+
+// ReplicaState is the state of the log of a replica of a Raft quorum, as seen by the leader.
+type ReplicaState struct {
+	ReplicaID    int32
+	LogEndOffset int64
+	// LastFetchTimestamp is the time in milliseconds of the last fetch of the replica from the
+	// leader, or -1 if unknown, from version 1.
+	LastFetchTimestamp int64
+	// LastCaughtUpTimestamp is the time in milliseconds the replica last caught up with the
+	// log end offset of the leader, or -1 if unknown, from version 1.
+	LastCaughtUpTimestamp int64
+}
+
+func (s *ReplicaState) encode(pe packetEncoder, version int16) error {
+	pe.putInt32(s.ReplicaID)
+	pe.putInt64(s.LogEndOffset)
+	if version >= 1 {
+		pe.putInt64(s.LastFetchTimestamp)
+		pe.putInt64(s.LastCaughtUpTimestamp)
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (s *ReplicaState) decode(pd packetDecoder, version int16) (err error) {
+	if s.ReplicaID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if s.LogEndOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	s.LastFetchTimestamp, s.LastCaughtUpTimestamp = -1, -1
+	if version >= 1 {
+		if s.LastFetchTimestamp, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if s.LastCaughtUpTimestamp, err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// DescribeQuorumPartition is the state of the Raft quorum replicating a partition.
+type DescribeQuorumPartition struct {
+	ErrorCode     KError
+	LeaderID      int32
+	LeaderEpoch   int32
+	HighWatermark int64
+	CurrentVoters []ReplicaState
+	Observers     []ReplicaState
+}
+
+// DescribeQuorumResponse is the state of the quorums of the partitions of a
+// DescribeQuorumRequest.
+type DescribeQuorumResponse struct {
+	Version   int16
+	ErrorCode KError
+	Topics    map[string]map[int32]*DescribeQuorumPartition
+}
+
+func (r *DescribeQuorumResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.ErrorCode))
+	pe.putCompactArrayLength(len(r.Topics))
+	for topic, partitions := range r.Topics {
+		if err := pe.putCompactString(topic); err != nil {
+			return err
+		}
+		pe.putCompactArrayLength(len(partitions))
+		for partition, block := range partitions {
+			pe.putInt32(partition)
+			pe.putInt16(int16(block.ErrorCode))
+			pe.putInt32(block.LeaderID)
+			pe.putInt32(block.LeaderEpoch)
+			pe.putInt64(block.HighWatermark)
+			for _, replicas := range [][]ReplicaState{block.CurrentVoters, block.Observers} {
+				pe.putCompactArrayLength(len(replicas))
+				for i := range replicas {
+					if err := replicas[i].encode(pe, r.Version); err != nil {
+						return err
+					}
+				}
+			}
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeQuorumResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.ErrorCode = KError(kerr)
+
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string]map[int32]*DescribeQuorumPartition, numTopics)
+	for i := 0; i < numTopics; i++ {
+		topic, err := pd.getCompactString()
+		if err != nil {
+			return err
+		}
+		numPartitions, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Topics[topic] = make(map[int32]*DescribeQuorumPartition, numPartitions)
+		for j := 0; j < numPartitions; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			block := &DescribeQuorumPartition{ErrorCode: KError(kerr)}
+			if block.LeaderID, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if block.LeaderEpoch, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if block.HighWatermark, err = pd.getInt64(); err != nil {
+				return err
+			}
+			if block.CurrentVoters, err = decodeReplicaStates(pd, version); err != nil {
+				return err
+			}
+			if block.Observers, err = decodeReplicaStates(pd, version); err != nil {
+				return err
+			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+			r.Topics[topic][partition] = block
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func decodeReplicaStates(pd packetDecoder, version int16) ([]ReplicaState, error) {
+	n, err := pd.getCompactArrayLength()
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	replicas := make([]ReplicaState, n)
+	for i := range replicas {
+		if err := replicas[i].decode(pd, version); err != nil {
+			return nil, err
+		}
+	}
+	return replicas, nil
+}
+
+func (r *DescribeQuorumResponse) key() int16 {
+	return 55
+}
+
+func (r *DescribeQuorumResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeQuorumResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeQuorumResponse) requiredVersion() KafkaVersion {
+	return V3_3_0_0
+}
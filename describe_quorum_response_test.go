@@ -0,0 +1,75 @@
+package sarama
+
+import "testing"
+
+var (
+	describeQuorumResponseV0 = []byte{
+		0, 0, // no error
+		2,                // 1 topic
+		4, 'f', 'o', 'o', // topic name
+		2,          // 1 partition
+		0, 0, 0, 0, // partition 0
+		0, 0, // no error
+		0, 0, 0, 1, // leader ID
+		0, 0, 0, 5, // leader epoch
+		0, 0, 0, 0, 0, 0, 0, 100, // high watermark
+		2,          // 1 voter
+		0, 0, 0, 1, // replica ID
+		0, 0, 0, 0, 0, 0, 0, 110, // log end offset
+		0, // empty tagged fields
+		1, // no observer
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+
+	describeQuorumResponseV1 = []byte{
+		0, 0, // no error
+		2,                // 1 topic
+		4, 'f', 'o', 'o', // topic name
+		2,          // 1 partition
+		0, 0, 0, 0, // partition 0
+		0, 0, // no error
+		0, 0, 0, 1, // leader ID
+		0, 0, 0, 5, // leader epoch
+		0, 0, 0, 0, 0, 0, 0, 100, // high watermark
+		1,          // no voter
+		2,          // 1 observer
+		0, 0, 0, 2, // replica ID
+		0, 0, 0, 0, 0, 0, 0, 90, // log end offset
+		0, 0, 0, 0, 0, 0, 0x03, 0xe8, // last fetch timestamp
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // last caught up timestamp
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestDescribeQuorumResponse(t *testing.T) {
+	response := &DescribeQuorumResponse{
+		Topics: map[string]map[int32]*DescribeQuorumPartition{
+			"foo": {0: {
+				LeaderID:      1,
+				LeaderEpoch:   5,
+				HighWatermark: 100,
+				// the timestamps are unknown before version 1
+				CurrentVoters: []ReplicaState{{ReplicaID: 1, LogEndOffset: 110, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1}},
+			}},
+		},
+	}
+	testResponse(t, "v0", response, describeQuorumResponseV0)
+
+	response = &DescribeQuorumResponse{
+		Version: 1,
+		Topics: map[string]map[int32]*DescribeQuorumPartition{
+			"foo": {0: {
+				LeaderID:      1,
+				LeaderEpoch:   5,
+				HighWatermark: 100,
+				Observers:     []ReplicaState{{ReplicaID: 2, LogEndOffset: 90, LastFetchTimestamp: 1000, LastCaughtUpTimestamp: -1}},
+			}},
+		},
+	}
+	testResponse(t, "v1", response, describeQuorumResponseV1)
+}
@@ -0,0 +1,15 @@
+package sarama
+
+import "testing"
+
+var describeTransactionsRequest = []byte{
+	3,           // 2 transactional IDs
+	3, 't', 'x', // tx
+	3, 't', 'y', // ty
+	0, // empty tagged fields
+}
+
+func TestDescribeTransactionsRequest(t *testing.T) {
+	request := &DescribeTransactionsRequest{TransactionalIDs: []string{"tx", "ty"}}
+	testRequest(t, "two transactions", request, describeTransactionsRequest)
+}
@@ -0,0 +1,155 @@
+package sarama
+
+import "time"
+
+// TransactionDescription is the state of a transaction on its coordinator.
+type TransactionDescription struct {
+	ErrorCode       KError
+	TransactionalID string
+	// State is the state of the transaction, such as Ongoing, PrepareCommit or Empty.
+	State   string
+	Timeout time.Duration
+	// StartTime is the timestamp in milliseconds when the ongoing transaction started, or -1.
+	StartTime     int64
+	ProducerID    int64
+	ProducerEpoch int16
+	// Topics are the partitions written to by the ongoing transaction.
+	Topics map[string][]int32
+}
+
+func (d *TransactionDescription) encode(pe packetEncoder) error {
+	pe.putInt16(int16(d.ErrorCode))
+	if err := pe.putCompactString(d.TransactionalID); err != nil {
+		return err
+	}
+	if err := pe.putCompactString(d.State); err != nil {
+		return err
+	}
+	pe.putInt32(int32(d.Timeout / time.Millisecond))
+	pe.putInt64(d.StartTime)
+	pe.putInt64(d.ProducerID)
+	pe.putInt16(d.ProducerEpoch)
+	pe.putCompactArrayLength(len(d.Topics))
+	for topic, partitions := range d.Topics {
+		if err := pe.putCompactString(topic); err != nil {
+			return err
+		}
+		if err := pe.putCompactInt32Array(partitions); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (d *TransactionDescription) decode(pd packetDecoder) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	d.ErrorCode = KError(kerr)
+	if d.TransactionalID, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if d.State, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	d.Timeout = time.Duration(timeout) * time.Millisecond
+	if d.StartTime, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if d.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if d.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	d.Topics = make(map[string][]int32, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getCompactString()
+		if err != nil {
+			return err
+		}
+		if d.Topics[topic], err = pd.getCompactInt32Array(); err != nil {
+			return err
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// DescribeTransactionsResponse are the transactions of a DescribeTransactionsRequest.
+type DescribeTransactionsResponse struct {
+	Version           int16
+	ThrottleTime      time.Duration
+	TransactionStates []*TransactionDescription
+}
+
+func (r *DescribeTransactionsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putCompactArrayLength(len(r.TransactionStates))
+	for _, state := range r.TransactionStates {
+		if err := state.encode(pe); err != nil {
+			return err
+		}
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeTransactionsResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.TransactionStates = make([]*TransactionDescription, n)
+	}
+	for i := 0; i < n; i++ {
+		r.TransactionStates[i] = new(TransactionDescription)
+		if err := r.TransactionStates[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeTransactionsResponse) key() int16 {
+	return 65
+}
+
+func (r *DescribeTransactionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeTransactionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeTransactionsResponse) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
+
+func (r *DescribeTransactionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,39 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var describeTransactionsResponse = []byte{
+	0, 0, 0, 0, // throttle time
+	2,    // 1 transaction
+	0, 0, // no error
+	3, 't', 'x', // transactional ID
+	8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // state
+	0, 0, 0xea, 0x60, // timeout: 60s
+	0, 0, 0, 0, 0, 0, 0x03, 0xe8, // start time
+	0, 0, 0, 0, 0, 0, 0, 42, // producer ID
+	0, 3, // producer epoch
+	2,                          // 1 topic
+	6, 't', 'o', 'p', 'i', 'c', // topic name
+	2, 0, 0, 0, 1, // partition 1
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeTransactionsResponse(t *testing.T) {
+	response := &DescribeTransactionsResponse{
+		TransactionStates: []*TransactionDescription{{
+			TransactionalID: "tx",
+			State:           "Ongoing",
+			Timeout:         time.Minute,
+			StartTime:       1000,
+			ProducerID:      42,
+			ProducerEpoch:   3,
+			Topics:          map[string][]int32{"topic": {1}},
+		}},
+	}
+	testResponse(t, "one transaction", response, describeTransactionsResponse)
+}
@@ -0,0 +1,188 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deserializer decodes the raw key or value of a consumed message into a domain object.
+type Deserializer interface {
+	Deserialize(topic string, data []byte) (interface{}, error)
+}
+
+// DeserializerFunc adapts an ordinary function into a Deserializer.
+type DeserializerFunc func(topic string, data []byte) (interface{}, error)
+
+// Deserialize implements Deserializer.
+func (f DeserializerFunc) Deserialize(topic string, data []byte) (interface{}, error) {
+	return f(topic, data)
+}
+
+// TopicDeserializers holds the deserializers of the keys and values of a topic, either of
+// which may be nil to leave them undecoded.
+type TopicDeserializers struct {
+	Key, Value Deserializer
+}
+
+// Schema is a schema registered in a schema registry.
+type Schema struct {
+	ID int32
+	// Type is the type of the schema, e.g. AVRO, PROTOBUF or JSON.
+	Type   string
+	Schema string
+}
+
+// SchemaRegistry resolves the schemas referenced by ID in framed data.
+type SchemaRegistry interface {
+	Schema(id int32) (*Schema, error)
+}
+
+// defaultSchemaRegistryTimeout is the timeout of the requests to the schema registry when no
+// HTTP client is given, the default Net.ReadTimeout.
+const defaultSchemaRegistryTimeout = 30 * time.Second
+
+// NewSchemaRegistryClient returns a SchemaRegistry resolving schemas through the REST API of
+// the schema registry at url, using the given HTTP client, or one timing out after 30s if nil.
+// Schemas are immutable once registered, so they are cached once resolved, and concurrent
+// lookups of a schema share a single request.
+func NewSchemaRegistryClient(url string, client *http.Client) SchemaRegistry {
+	if client == nil {
+		client = &http.Client{Timeout: defaultSchemaRegistryTimeout}
+	}
+	return &schemaRegistryClient{
+		url:     strings.TrimSuffix(url, "/"),
+		client:  client,
+		schemas: make(map[int32]*Schema),
+		lookups: make(map[int32]*schemaLookup),
+	}
+}
+
+type schemaRegistryClient struct {
+	url    string
+	client *http.Client
+
+	schemas map[int32]*Schema
+	lookups map[int32]*schemaLookup // the lookups in progress
+	lock    sync.RWMutex
+}
+
+// schemaLookup is a request for a schema shared by the concurrent lookups of the schema.
+type schemaLookup struct {
+	done   chan none
+	schema *Schema
+	err    error
+}
+
+func (c *schemaRegistryClient) Schema(id int32) (*Schema, error) {
+	c.lock.RLock()
+	schema, ok := c.schemas[id]
+	c.lock.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	c.lock.Lock()
+	if schema, ok := c.schemas[id]; ok {
+		c.lock.Unlock()
+		return schema, nil
+	}
+	lookup, ok := c.lookups[id]
+	if ok {
+		c.lock.Unlock()
+		<-lookup.done
+		return lookup.schema, lookup.err
+	}
+	lookup = &schemaLookup{done: make(chan none)}
+	c.lookups[id] = lookup
+	c.lock.Unlock()
+
+	lookup.schema, lookup.err = c.fetch(id)
+
+	c.lock.Lock()
+	if lookup.err == nil {
+		c.schemas[id] = lookup.schema
+	}
+	delete(c.lookups, id)
+	c.lock.Unlock()
+	close(lookup.done)
+	return lookup.schema, lookup.err
+}
+
+// fetch requests the schema from the registry.
+func (c *schemaRegistryClient) fetch(id int32) (*Schema, error) {
+	resp, err := c.client.Get(fmt.Sprintf("%s/schemas/ids/%d", c.url, id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kafka: unable to resolve schema %d from the schema registry: %s", id, resp.Status)
+	}
+
+	var body struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	schema := &Schema{ID: id, Type: body.SchemaType, Schema: body.Schema}
+	if schema.Type == "" {
+		schema.Type = "AVRO" // the registry omits the type of Avro schemas
+	}
+	return schema, nil
+}
+
+// SchemaDecoder decodes a payload encoded with the given schema.
+type SchemaDecoder func(schema *Schema, payload []byte) (interface{}, error)
+
+// SchemaRegistryDeserializer is a Deserializer for data framed in the schema registry wire
+// format: a zero magic byte and the 4-byte big-endian ID of the schema in the registry,
+// followed by the payload encoded with that schema. It strips the framing, resolves the schema
+// through the registry and hands the payload to the decoder of the schema format.
+type SchemaRegistryDeserializer struct {
+	registry SchemaRegistry
+	decode   SchemaDecoder
+}
+
+// NewSchemaRegistryDeserializer creates a SchemaRegistryDeserializer resolving schemas with
+// registry and decoding payloads with decode.
+func NewSchemaRegistryDeserializer(registry SchemaRegistry, decode SchemaDecoder) (*SchemaRegistryDeserializer, error) {
+	if registry == nil {
+		return nil, ConfigurationError("SchemaRegistryDeserializer requires a non-nil registry")
+	}
+	if decode == nil {
+		return nil, ConfigurationError("SchemaRegistryDeserializer requires a schema decoder")
+	}
+	return &SchemaRegistryDeserializer{registry: registry, decode: decode}, nil
+}
+
+// Deserialize implements Deserializer.
+func (d *SchemaRegistryDeserializer) Deserialize(topic string, data []byte) (interface{}, error) {
+	id, payload, err := parseSchemaFraming(data)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := d.registry.Schema(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.decode(schema, payload)
+}
+
+// parseSchemaFraming returns the schema ID and the payload of data framed in the schema
+// registry wire format.
+func parseSchemaFraming(data []byte) (int32, []byte, error) {
+	if len(data) < 5 || data[0] != 0 {
+		return 0, nil, ErrInvalidSchemaFraming
+	}
+	return int32(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
@@ -0,0 +1,92 @@
+package sarama
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSchemaRegistryDeserializer(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/schemas/ids/42" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"schema":"\"string\""}`)
+	}))
+	defer server.Close()
+
+	registry := NewSchemaRegistryClient(server.URL+"/", nil)
+	if timeout := registry.(*schemaRegistryClient).client.Timeout; timeout != defaultSchemaRegistryTimeout {
+		t.Errorf("Expected the default HTTP client to time out after %v, got %v", defaultSchemaRegistryTimeout, timeout)
+	}
+	d, err := NewSchemaRegistryDeserializer(registry, func(schema *Schema, payload []byte) (interface{}, error) {
+		return fmt.Sprintf("%d/%s/%s:%s", schema.ID, schema.Type, schema.Schema, payload), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		value, err := d.Deserialize("my_topic", []byte{0, 0, 0, 0, 42, 'f', 'o', 'o'})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != `42/AVRO/"string":foo` {
+			t.Errorf("Unexpected decoded value %v", value)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the schema to be resolved once, got %d requests", requests)
+	}
+
+	if _, err := d.Deserialize("my_topic", []byte{0, 0, 0, 0, 7}); err == nil {
+		t.Error("Expected an error for an unknown schema")
+	}
+	for _, data := range [][]byte{{}, {0, 0, 0}, {1, 0, 0, 0, 42, 'f'}} {
+		if _, err := d.Deserialize("my_topic", data); !errors.Is(err, ErrInvalidSchemaFraming) {
+			t.Errorf("Expected ErrInvalidSchemaFraming for %v, got %v", data, err)
+		}
+	}
+}
+
+func TestSchemaRegistryConcurrentLookups(t *testing.T) {
+	var requests int32
+	started, release := make(chan none), make(chan none)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+		}
+		<-release
+		fmt.Fprint(w, `{"schema":"\"string\""}`)
+	}))
+	defer server.Close()
+
+	registry := NewSchemaRegistryClient(server.URL, nil)
+	var wg sync.WaitGroup
+	lookup := func() {
+		defer wg.Done()
+		if _, err := registry.Schema(42); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Add(1)
+	go lookup()
+	<-started
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go lookup()
+	}
+	close(release)
+	wg.Wait()
+
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("Expected the concurrent lookups to share a request, got %d requests", requests)
+	}
+}
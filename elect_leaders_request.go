@@ -0,0 +1,107 @@
+package sarama
+
+import "time"
+
+// ElectionType is the kind of leader election of an ElectLeadersRequest.
+type ElectionType int8
+
+const (
+	// PreferredElection elects the preferred replica, the first of the assignment, if it is
+	// in sync.
+	PreferredElection ElectionType = 0
+	// UncleanElection elects any live replica if none of the in-sync replicas is alive,
+	// possibly losing records.
+	UncleanElection ElectionType = 1
+)
+
+// ElectLeadersRequest triggers the election of the leaders of partitions.
+type ElectLeadersRequest struct {
+	Version int16
+	// Type is the kind of election, from version 1, only PreferredElection being supported
+	// before.
+	Type ElectionType
+	// TopicPartitions are the partitions to elect leaders for, all the partitions if nil.
+	TopicPartitions map[string][]int32
+	Timeout         time.Duration
+}
+
+func (r *ElectLeadersRequest) encode(pe packetEncoder) error {
+	if r.Version > 0 {
+		pe.putInt8(int8(r.Type))
+	}
+
+	if r.TopicPartitions == nil {
+		pe.putInt32(-1)
+	} else {
+		if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
+			return err
+		}
+		for topic, partitions := range r.TopicPartitions {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putInt32Array(partitions); err != nil {
+				return err
+			}
+		}
+	}
+
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	return nil
+}
+
+func (r *ElectLeadersRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Version > 0 {
+		t, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		r.Type = ElectionType(t)
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n >= 0 {
+		r.TopicPartitions = make(map[string][]int32, n)
+	}
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		if r.TopicPartitions[topic], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+	return nil
+}
+
+func (r *ElectLeadersRequest) key() int16 {
+	return 43
+}
+
+func (r *ElectLeadersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ElectLeadersRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *ElectLeadersRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_4_0_0
+	default:
+		return V2_2_0_0
+	}
+}
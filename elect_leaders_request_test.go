@@ -0,0 +1,36 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	electLeadersRequestAllPartitionsV0 = []byte{
+		255, 255, 255, 255, // all partitions
+		0, 0, 39, 16, // timeout: 10s
+	}
+
+	electLeadersRequestUncleanV1 = []byte{
+		1,          // unclean election
+		0, 0, 0, 1, // 1 topic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 2, // 2 partitions
+		0, 0, 0, 0,
+		0, 0, 0, 1,
+		0, 0, 39, 16, // timeout: 10s
+	}
+)
+
+func TestElectLeadersRequest(t *testing.T) {
+	request := &ElectLeadersRequest{Timeout: 10 * time.Second}
+	testRequest(t, "all partitions V0", request, electLeadersRequestAllPartitionsV0)
+
+	request = &ElectLeadersRequest{
+		Version:         1,
+		Type:            UncleanElection,
+		TopicPartitions: map[string][]int32{"topic": {0, 1}},
+		Timeout:         10 * time.Second,
+	}
+	testRequest(t, "unclean V1", request, electLeadersRequestUncleanV1)
+}
@@ -0,0 +1,120 @@
+package sarama
+
+import "time"
+
+// PartitionResult is the result of the election of the leader of a partition.
+type PartitionResult struct {
+	ErrorCode    KError
+	ErrorMessage *string
+}
+
+// ElectLeadersResponse are the results of an ElectLeadersRequest by topic and partition.
+type ElectLeadersResponse struct {
+	Version      int16
+	ThrottleTime time.Duration
+	// ErrorCode is the error of the whole request, from version 1.
+	ErrorCode              KError
+	ReplicaElectionResults map[string]map[int32]*PartitionResult
+}
+
+func (r *ElectLeadersResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	if r.Version > 0 {
+		pe.putInt16(int16(r.ErrorCode))
+	}
+
+	if err := pe.putArrayLength(len(r.ReplicaElectionResults)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.ReplicaElectionResults {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, result := range partitions {
+			pe.putInt32(partition)
+			pe.putInt16(int16(result.ErrorCode))
+			if err := pe.putNullableString(result.ErrorMessage); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ElectLeadersResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	if r.Version > 0 {
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		r.ErrorCode = KError(kerr)
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.ReplicaElectionResults = make(map[string]map[int32]*PartitionResult, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.ReplicaElectionResults[topic] = make(map[int32]*PartitionResult, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			result := &PartitionResult{ErrorCode: KError(kerr)}
+			if result.ErrorMessage, err = pd.getNullableString(); err != nil {
+				return err
+			}
+			r.ReplicaElectionResults[topic][partition] = result
+		}
+	}
+	return nil
+}
+
+func (r *ElectLeadersResponse) key() int16 {
+	return 43
+}
+
+func (r *ElectLeadersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ElectLeadersResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *ElectLeadersResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_4_0_0
+	default:
+		return V2_2_0_0
+	}
+}
+
+func (r *ElectLeadersResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
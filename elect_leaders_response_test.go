@@ -0,0 +1,44 @@
+package sarama
+
+import "testing"
+
+var (
+	electLeadersResponseV0 = []byte{
+		0, 0, 0, 0, // throttle time
+		0, 0, 0, 1, // 1 topic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1, // 1 partition
+		0, 0, 0, 0, // partition 0
+		0, 0, // no error
+		255, 255, // no error message
+	}
+
+	electLeadersResponseV1 = []byte{
+		0, 0, 0, 0, // throttle time
+		0, 0, // no error
+		0, 0, 0, 1, // 1 topic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1, // 1 partition
+		0, 0, 0, 1, // partition 1
+		0, 84, // ErrElectionNotNeeded
+		0, 3, 'n', 'o', 'p', // error message
+	}
+)
+
+func TestElectLeadersResponse(t *testing.T) {
+	response := &ElectLeadersResponse{
+		ReplicaElectionResults: map[string]map[int32]*PartitionResult{
+			"topic": {0: {ErrorCode: ErrNoError}},
+		},
+	}
+	testResponse(t, "V0", response, electLeadersResponseV0)
+
+	message := "nop"
+	response = &ElectLeadersResponse{
+		Version: 1,
+		ReplicaElectionResults: map[string]map[int32]*PartitionResult{
+			"topic": {1: {ErrorCode: ErrElectionNotNeeded, ErrorMessage: &message}},
+		},
+	}
+	testResponse(t, "V1", response, electLeadersResponseV1)
+}
@@ -100,3 +100,69 @@ func versionedDecode(buf []byte, in versionedDecoder, version int16, metricRegis
 
 	return nil
 }
+
+// taggedField is a tagged field of a flexible message, whose value is written by encode.
+type taggedField struct {
+	tag    uint64
+	encode func(pe packetEncoder) error
+}
+
+type encoderFunc func(pe packetEncoder) error
+
+func (f encoderFunc) encode(pe packetEncoder) error {
+	return f(pe)
+}
+
+type decoderFunc func(pd packetDecoder) error
+
+func (f decoderFunc) decode(pd packetDecoder) error {
+	return f(pd)
+}
+
+// putTaggedFields writes the tagged fields, which must be sorted by tag, where flexible
+// messages otherwise put an empty tagged field array.
+func putTaggedFields(pe packetEncoder, fields []taggedField) error {
+	pe.putUVarint(uint64(len(fields)))
+	for _, field := range fields {
+		raw, err := encode(encoderFunc(field.encode), nil)
+		if err != nil {
+			return err
+		}
+		pe.putUVarint(field.tag)
+		pe.putUVarint(uint64(len(raw)))
+		if err := pe.putRawBytes(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getTaggedFields reads the tagged fields of a flexible message, decoding the value of each
+// one with decodeField, which must read the whole value.
+func getTaggedFields(pd packetDecoder, decodeField func(tag uint64, pd packetDecoder) error) error {
+	n, err := pd.getUVarint()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		tag, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		length, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		raw, err := pd.getRawBytes(int(length))
+		if err != nil {
+			return err
+		}
+		err = decode(raw, decoderFunc(func(pd packetDecoder) error {
+			return decodeField(tag, pd)
+		}), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package sarama
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -18,6 +19,10 @@ var ErrBrokerNotFound = errors.New("kafka: broker for ID is not found")
 // ErrClosedClient is the error returned when a method is called on a client that has been closed.
 var ErrClosedClient = errors.New("kafka: tried to use a client that was closed")
 
+// ErrClosedOffsetManager is the error the callbacks of the asynchronous commits requested once an
+// OffsetManager is closed are called with.
+var ErrClosedOffsetManager = errors.New("kafka: tried to commit with an offset manager that was closed")
+
 // ErrIncompleteResponse is the error returned when the server returns a syntactically valid response, but it does
 // not contain the expected information.
 var ErrIncompleteResponse = errors.New("kafka: response did not contain all the expected topic/partition blocks")
@@ -43,6 +48,23 @@ var ErrShuttingDown = errors.New("kafka: message received by producer in process
 // ErrMessageTooLarge is returned when the next message to consume is larger than the configured Consumer.Fetch.Max
 var ErrMessageTooLarge = errors.New("kafka: message is larger than Consumer.Fetch.Max")
 
+// ErrNoInitialOffset is returned when consuming a partition without a committed offset while
+// Consumer.Offsets.Initial is OffsetFail.
+var ErrNoInitialOffset = errors.New("kafka: no offset to start consuming from and Consumer.Offsets.Initial is OffsetFail")
+
+// ErrMaxProcessingIntervalExceeded is returned through ConsumerGroup.Errors when a claim went without
+// any message being received for longer than Consumer.Group.MaxProcessingInterval, after which the
+// member left the group.
+var ErrMaxProcessingIntervalExceeded = errors.New("kafka: claim processing exceeded Consumer.Group.MaxProcessingInterval, the member left the group")
+
+// ErrClaimStuck is returned through ConsumerGroup.Errors when a claim went without any message being
+// received for longer than Consumer.Group.StuckClaim.Timeout while messages were waiting.
+var ErrClaimStuck = errors.New("kafka: claim made no progress for Consumer.Group.StuckClaim.Timeout")
+
+// ErrInvalidSchemaFraming is returned by a SchemaRegistryDeserializer when data is not framed in
+// the schema registry wire format.
+var ErrInvalidSchemaFraming = errors.New("kafka: data is not framed in the schema registry wire format")
+
 // ErrConsumerOffsetNotAdvanced is returned when a partition consumer didn't advance its offset after parsing
 // a RecordBatch.
 var ErrConsumerOffsetNotAdvanced = errors.New("kafka: consumer offset was not advanced after a RecordBatch")
@@ -88,6 +110,14 @@ var ErrCannotTransitionNilError = errors.New("transaction manager: cannot transi
 // ErrTxnUnableToParseResponse when response is nil
 var ErrTxnUnableToParseResponse = errors.New("transaction manager: unable to parse response")
 
+// ErrTopicSpecConflict is returned by ClusterAdmin.ApplyTopicSpec when a topic cannot be changed
+// to match its spec, for example because it has more partitions than the spec.
+var ErrTopicSpecConflict = errors.New("kafka: topic cannot be changed to match its spec")
+
+// ErrBrokerCircuitOpen is returned by the requests to a broker whose circuit breaker opened after
+// consecutive failures, see Config.Net.CircuitBreaker.
+var ErrBrokerCircuitOpen = errors.New("kafka: circuit breaker of the broker is open")
+
 // MultiErrorFormat specifies the formatter applied to format multierrors. The
 // default implementation is a consensed version of the hashicorp/go-multierror
 // default one
@@ -139,6 +169,53 @@ func multiError(wrapped ...error) error {
 	return merr.ErrorOrNil()
 }
 
+// UnsupportedAPIVersionError is returned, wrapping ErrUnsupportedVersion, when a request is not
+// sent to a broker as its version is outside of the range of versions the broker advertised in
+// its ApiVersions response. The requests whose version is not supported by Config.Version fail
+// with ErrUnsupportedVersion itself.
+type UnsupportedAPIVersionError struct {
+	BrokerID   int32
+	APIKey     int16
+	APIVersion int16
+	// RequiredVersion is the Kafka version the version of the request requires.
+	RequiredVersion KafkaVersion
+	// ConfiguredVersion is Config.Version.
+	ConfiguredVersion KafkaVersion
+	// MinVersion and MaxVersion are the range of versions the broker supports.
+	MinVersion, MaxVersion int16
+	// Pinned is whether APIVersion is the one of Config.MaxAPIVersions.
+	Pinned bool
+}
+
+func (err *UnsupportedAPIVersionError) Error() string {
+	request := fmt.Sprintf("%s v%d, which requires Kafka %s,", apiKeyName(err.APIKey), err.APIVersion, err.RequiredVersion)
+	switch {
+	case err.Pinned && err.APIVersion < err.MinVersion:
+		return fmt.Sprintf("kafka: %s cannot be sent to broker %d, Config.MaxAPIVersions pinning it below the lowest version the broker supports, v%d",
+			request, err.BrokerID, err.MinVersion)
+	case err.APIVersion < err.MinVersion:
+		return fmt.Sprintf("kafka: %s is not supported by broker %d, which supports v%d to v%d, raise Config.Version from %s",
+			request, err.BrokerID, err.MinVersion, err.MaxVersion, err.ConfiguredVersion)
+	default:
+		return fmt.Sprintf("kafka: %s is not supported by broker %d, which supports v%d to v%d, lower Config.Version from %s or pin it with Config.MaxAPIVersions",
+			request, err.BrokerID, err.MinVersion, err.MaxVersion, err.ConfiguredVersion)
+	}
+}
+
+func (err *UnsupportedAPIVersionError) Unwrap() error {
+	return ErrUnsupportedVersion
+}
+
+// apiKeyName returns the name of the API of the key, e.g. Produce, as used in the messages of the
+// errors.
+func apiKeyName(key int16) string {
+	body := allocateBody(key, 0)
+	if body == nil {
+		return fmt.Sprintf("API key %d", key)
+	}
+	return strings.TrimSuffix(reflect.TypeOf(body).Elem().Name(), "Request")
+}
+
 // PacketEncodingError is returned from a failure while encoding a Kafka packet. This can happen, for example,
 // if you try to encode a string over 2^15 characters in length, since Kafka's encoding rules do not permit that.
 type PacketEncodingError struct {
@@ -265,6 +342,8 @@ const (
 	ErrUnstableOffsetCommit               KError = 88
 	ErrThrottlingQuotaExceeded            KError = 89
 	ErrProducerFenced                     KError = 90
+	ErrUnknownTopicID                     KError = 100
+	ErrInconsistentTopicID                KError = 103
 )
 
 func (err KError) Error() string {
@@ -451,6 +530,10 @@ func (err KError) Error() string {
 		return "kafka server: This record has failed the validation on broker and hence will be rejected"
 	case ErrUnstableOffsetCommit:
 		return "kafka server: There are unstable offsets that need to be cleared"
+	case ErrUnknownTopicID:
+		return "kafka server: This server does not host this topic ID"
+	case ErrInconsistentTopicID:
+		return "kafka server: The log's topic ID did not match the topic ID in the request"
 	}
 
 	return fmt.Sprintf("Unknown error, how did this happen? Error code = %d", err)
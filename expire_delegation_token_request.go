@@ -0,0 +1,57 @@
+package sarama
+
+import "time"
+
+// ExpireDelegationTokenRequest changes the expiry time of a delegation token, expiring it
+// immediately with a negative period.
+type ExpireDelegationTokenRequest struct {
+	// Version 0 and 1 are equal
+	// The version number is bumped to indicate that on quota violation brokers send out responses before throttling.
+	Version int16
+	HMAC    []byte
+	// ExpiryPeriod is how long from now the token expires, capped by its current expiry time,
+	// a negative duration expiring it immediately.
+	ExpiryPeriod time.Duration
+}
+
+func (r *ExpireDelegationTokenRequest) encode(pe packetEncoder) error {
+	if err := pe.putBytes(r.HMAC); err != nil {
+		return err
+	}
+	pe.putInt64(durationToMillis(r.ExpiryPeriod))
+	return nil
+}
+
+func (r *ExpireDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.HMAC, err = pd.getBytes(); err != nil {
+		return err
+	}
+	period, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.ExpiryPeriod = millisToDuration(period)
+	return nil
+}
+
+func (r *ExpireDelegationTokenRequest) key() int16 {
+	return 40
+}
+
+func (r *ExpireDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ExpireDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *ExpireDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
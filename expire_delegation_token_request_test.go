@@ -0,0 +1,13 @@
+package sarama
+
+import "testing"
+
+var expireDelegationTokenRequest = []byte{
+	0, 0, 0, 3, 1, 2, 3, // HMAC
+	255, 255, 255, 255, 255, 255, 255, 255, // expire immediately
+}
+
+func TestExpireDelegationTokenRequest(t *testing.T) {
+	request := &ExpireDelegationTokenRequest{HMAC: []byte{1, 2, 3}, ExpiryPeriod: -1}
+	testRequest(t, "expire", request, expireDelegationTokenRequest)
+}
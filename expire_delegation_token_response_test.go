@@ -0,0 +1,17 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var expireDelegationTokenResponseNotFound = []byte{
+	0, 62, // ErrDelegationTokenNotFound
+	0, 0, 0, 0, 0, 0, 0, 0, // expiry time
+	0, 0, 0, 0, // throttle time
+}
+
+func TestExpireDelegationTokenResponse(t *testing.T) {
+	response := &ExpireDelegationTokenResponse{Err: ErrDelegationTokenNotFound, ExpiryTime: time.Unix(0, 0)}
+	testResponse(t, "not found", response, expireDelegationTokenResponseNotFound)
+}
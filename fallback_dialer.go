@@ -0,0 +1,168 @@
+package sarama
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// AddressFamily is the IP family to try first when a broker hostname resolves to addresses of
+// both families, see Config.Net.AddressFamily.
+type AddressFamily int8
+
+const (
+	// AddressFamilyAny tries the family of the first address returned by the resolver first.
+	AddressFamilyAny AddressFamily = iota
+	// AddressFamilyIPv4 tries the IPv4 addresses first.
+	AddressFamilyIPv4
+	// AddressFamilyIPv6 tries the IPv6 addresses first.
+	AddressFamilyIPv6
+)
+
+func (f AddressFamily) String() string {
+	switch f {
+	case AddressFamilyAny:
+		return "any"
+	case AddressFamilyIPv4:
+		return "ipv4"
+	case AddressFamilyIPv6:
+		return "ipv6"
+	default:
+		return fmt.Sprintf("AddressFamily(%d)", int8(f))
+	}
+}
+
+// fallbackDialer dials the addresses a hostname resolves to in turn, trying the next one when
+// the previous fails or is still pending after the fallback delay, as in RFC 8305 (happy
+// eyeballs), so that an unreachable address does not fail or delay the connection.
+type fallbackDialer struct {
+	dialer *net.Dialer
+	delay  time.Duration
+	family AddressFamily
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+func newFallbackDialer(dialer *net.Dialer, delay time.Duration, family AddressFamily) *fallbackDialer {
+	resolver := dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &fallbackDialer{dialer: dialer, delay: delay, family: family, lookup: resolver.LookupIPAddr}
+}
+
+func (d *fallbackDialer) Dial(network, addr string) (net.Conn, error) {
+	ctx := context.Background()
+	if d.dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.dialer.Timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 1 {
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range interleaveIPFamilies(ips, d.family) {
+		addrs[i] = net.JoinHostPort(ip.String(), port)
+	}
+	return d.race(ctx, network, addrs)
+}
+
+// race dials the addresses in turn, starting the next one when the previous attempt fails or
+// the fallback delay, if not negative, is over, and returns the first connection made, closing the others, or the
+// error of the first attempt if they all fail.
+func (d *fallbackDialer) race(ctx context.Context, network string, addrs []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	next, pending := 0, 0
+	var fallback <-chan time.Time
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			conn, err := d.dialer.DialContext(ctx, network, addr)
+			results <- result{conn, err}
+		}()
+		fallback = nil
+		if next < len(addrs) && d.delay >= 0 {
+			fallback = time.After(d.delay)
+		}
+	}
+
+	var firstErr error
+	for start(); pending > 0; {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if r := <-results; r.conn != nil {
+							_ = r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				start()
+			}
+		case <-fallback:
+			start()
+		}
+	}
+	return nil, firstErr
+}
+
+// interleaveIPFamilies orders the addresses alternately by family, starting with the preferred
+// family if any address is of it, the family of the first one otherwise, and keeping the order of
+// the addresses of each family.
+func interleaveIPFamilies(ips []net.IPAddr, family AddressFamily) []net.IPAddr {
+	var first, second []net.IPAddr
+	firstIsV4 := ips[0].IP.To4() != nil
+	for _, ip := range ips {
+		isV4 := ip.IP.To4() != nil
+		if (family == AddressFamilyIPv4 && isV4) || (family == AddressFamilyIPv6 && !isV4) {
+			firstIsV4 = isV4
+			break
+		}
+	}
+	for _, ip := range ips {
+		if (ip.IP.To4() != nil) == firstIsV4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+
+	interleaved := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			interleaved = append(interleaved, first[i])
+		}
+		if i < len(second) {
+			interleaved = append(interleaved, second[i])
+		}
+	}
+	return interleaved
+}
@@ -0,0 +1,79 @@
+package sarama
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFallbackDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// 127.0.0.2 is a loopback address nothing listens on, refusing the connection
+	for _, delay := range []time.Duration{0, time.Hour} {
+		dialer := newFallbackDialer(&net.Dialer{Timeout: time.Second}, delay, AddressFamilyAny)
+		dialer.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			if host != "kafka" {
+				t.Errorf("Expected to look kafka up, looked %s up", host)
+			}
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+
+		conn, err := dialer.Dial("tcp", net.JoinHostPort("kafka", port))
+		if err != nil {
+			t.Fatalf("delay %v: %v", delay, err)
+		}
+		if conn.RemoteAddr().String() != listener.Addr().String() {
+			t.Errorf("delay %v: expected to connect to %s, connected to %s", delay, listener.Addr(), conn.RemoteAddr())
+		}
+		_ = conn.Close()
+	}
+
+	dialer := newFallbackDialer(&net.Dialer{Timeout: time.Second}, time.Millisecond, AddressFamilyAny)
+	dialer.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.3")}}, nil
+	}
+	if _, err := dialer.Dial("tcp", net.JoinHostPort("kafka", port)); err == nil {
+		t.Error("Expected an error when no address can be connected to")
+	}
+}
+
+func TestInterleaveIPFamilies(t *testing.T) {
+	ips := func(addrs ...string) []net.IPAddr {
+		out := make([]net.IPAddr, len(addrs))
+		for i, addr := range addrs {
+			out[i] = net.IPAddr{IP: net.ParseIP(addr)}
+		}
+		return out
+	}
+
+	got := interleaveIPFamilies(ips("::1", "::2", "::3", "10.0.0.1", "10.0.0.2"), AddressFamilyAny)
+	if expected := ips("::1", "10.0.0.1", "::2", "10.0.0.2", "::3"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	got = interleaveIPFamilies(ips("10.0.0.1", "10.0.0.2", "::1"), AddressFamilyAny)
+	if expected := ips("10.0.0.1", "::1", "10.0.0.2"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	got = interleaveIPFamilies(ips("::1", "::2", "10.0.0.1", "10.0.0.2"), AddressFamilyIPv4)
+	if expected := ips("10.0.0.1", "::1", "10.0.0.2", "::2"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	got = interleaveIPFamilies(ips("10.0.0.1", "::1", "10.0.0.2"), AddressFamilyIPv6)
+	if expected := ips("::1", "10.0.0.1", "10.0.0.2"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	// the preference does not matter if no address is of the preferred family
+	got = interleaveIPFamilies(ips("10.0.0.1", "10.0.0.2"), AddressFamilyIPv6)
+	if expected := ips("10.0.0.1", "10.0.0.2"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,95 @@
+package sarama
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Header keys stamped on messages by FencingToken.Stamp.
+const (
+	// FencingGroupHeader holds the ID of the consumer group of the member which produced the message.
+	FencingGroupHeader = "sarama-fencing-group"
+	// FencingMemberHeader holds the ID of the member which produced the message.
+	FencingMemberHeader = "sarama-fencing-member"
+	// FencingGenerationHeader holds the generation of the group the member produced the message in,
+	// as a decimal string.
+	FencingGenerationHeader = "sarama-fencing-generation"
+)
+
+// FencingToken identifies a member of a consumer group in a generation of the group. As the
+// generation increases with each rebalance, downstream systems keeping track of the highest
+// generation seen for a group can reject the writes of zombie members, which kept processing
+// messages after their partitions were assigned to other members in a later generation.
+type FencingToken struct {
+	GroupID      string
+	MemberID     string
+	GenerationID int32
+}
+
+// NewFencingToken returns the fencing token of the member of the session, to be stamped into
+// the messages it produces, see Stamp, or the external writes it makes while processing the
+// claims of the session.
+func NewFencingToken(sess ConsumerGroupSession) FencingToken {
+	return FencingToken{
+		GroupID:      sess.GroupID(),
+		MemberID:     sess.MemberID(),
+		GenerationID: sess.GenerationID(),
+	}
+}
+
+// IsZero reports whether the token is unset.
+func (t FencingToken) IsZero() bool {
+	return t == FencingToken{}
+}
+
+// Supersedes reports whether the token is from a later generation of the same group than
+// other, in which case writes carrying other must be rejected once one carrying the token
+// has been accepted.
+func (t FencingToken) Supersedes(other FencingToken) bool {
+	return t.GroupID == other.GroupID && t.GenerationID > other.GenerationID
+}
+
+func (t FencingToken) String() string {
+	return fmt.Sprintf("%s/%s/%d", t.GroupID, t.MemberID, t.GenerationID)
+}
+
+// Stamp adds the token to the headers of msg, replacing any token it already carried.
+func (t FencingToken) Stamp(msg *ProducerMessage) {
+	headers := make([]RecordHeader, 0, len(msg.Headers)+3)
+	for _, header := range msg.Headers {
+		switch string(header.Key) {
+		case FencingGroupHeader, FencingMemberHeader, FencingGenerationHeader:
+			continue
+		}
+		headers = append(headers, header)
+	}
+	msg.Headers = append(headers,
+		RecordHeader{Key: []byte(FencingGroupHeader), Value: []byte(t.GroupID)},
+		RecordHeader{Key: []byte(FencingMemberHeader), Value: []byte(t.MemberID)},
+		RecordHeader{Key: []byte(FencingGenerationHeader), Value: []byte(strconv.FormatInt(int64(t.GenerationID), 10))},
+	)
+}
+
+// ParseFencingToken extracts the token stamped by FencingToken.Stamp from the headers of a
+// consumed message. The token is zero if the message carries none.
+func ParseFencingToken(msg *ConsumerMessage) (FencingToken, error) {
+	var token FencingToken
+	for _, header := range msg.Headers {
+		if header == nil {
+			continue
+		}
+		switch string(header.Key) {
+		case FencingGroupHeader:
+			token.GroupID = string(header.Value)
+		case FencingMemberHeader:
+			token.MemberID = string(header.Value)
+		case FencingGenerationHeader:
+			generation, err := strconv.ParseInt(string(header.Value), 10, 32)
+			if err != nil {
+				return token, fmt.Errorf("kafka: invalid %s header: %w", FencingGenerationHeader, err)
+			}
+			token.GenerationID = int32(generation)
+		}
+	}
+	return token, nil
+}
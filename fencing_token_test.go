@@ -0,0 +1,68 @@
+package sarama
+
+import "testing"
+
+type fencingSession struct {
+	testRelaySession
+	generationID int32
+}
+
+func (s fencingSession) GroupID() string     { return "my-group" }
+func (s fencingSession) MemberID() string    { return "my-member" }
+func (s fencingSession) GenerationID() int32 { return s.generationID }
+
+func TestFencingTokenStamp(t *testing.T) {
+	token := NewFencingToken(fencingSession{generationID: 3})
+	expected := FencingToken{GroupID: "my-group", MemberID: "my-member", GenerationID: 3}
+	if token != expected {
+		t.Fatalf("Expected token %s, got %s", expected, token)
+	}
+
+	msg := &ProducerMessage{Topic: "out", Headers: []RecordHeader{
+		{Key: []byte("trace-id"), Value: []byte("abc")},
+	}}
+	NewFencingToken(fencingSession{generationID: 2}).Stamp(msg)
+	token.Stamp(msg)
+	if len(msg.Headers) != 4 {
+		t.Fatalf("Expected the previous token to be replaced, got headers %v", msg.Headers)
+	}
+
+	consumed := &ConsumerMessage{}
+	for i := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+	}
+	parsed, err := ParseFencingToken(consumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != token {
+		t.Errorf("Expected the parsed token %s, got %s", token, parsed)
+	}
+
+	parsed, err = ParseFencingToken(&ConsumerMessage{})
+	if err != nil || !parsed.IsZero() {
+		t.Errorf("Expected a zero token without headers, got %s, %v", parsed, err)
+	}
+	_, err = ParseFencingToken(&ConsumerMessage{Headers: []*RecordHeader{
+		{Key: []byte(FencingGenerationHeader), Value: []byte("x")},
+	}})
+	if err == nil {
+		t.Error("Expected an invalid generation to fail")
+	}
+}
+
+func TestFencingTokenSupersedes(t *testing.T) {
+	older := FencingToken{GroupID: "my-group", MemberID: "zombie", GenerationID: 2}
+	newer := FencingToken{GroupID: "my-group", MemberID: "my-member", GenerationID: 3}
+	other := FencingToken{GroupID: "other-group", MemberID: "my-member", GenerationID: 1}
+
+	if !newer.Supersedes(older) {
+		t.Error("Expected the later generation to supersede the older one")
+	}
+	if older.Supersedes(newer) || newer.Supersedes(newer) {
+		t.Error("Expected a generation not to supersede itself or a later one")
+	}
+	if newer.Supersedes(other) {
+		t.Error("Expected tokens of different groups not to supersede each other")
+	}
+}
@@ -1,5 +1,7 @@
 package sarama
 
+import "fmt"
+
 type fetchRequestBlock struct {
 	Version int16
 	// currentLeaderEpoch contains the current leader epoch of the partition.
@@ -13,6 +15,9 @@ type fetchRequestBlock struct {
 	// maxBytes contains the maximum bytes to fetch from this partition.  See
 	// KIP-74 for cases where this limit may not be honored.
 	maxBytes int32
+	// lastFetchedEpoch contains the epoch of the last fetched record, or -1 if
+	// there is none, from version 12.
+	lastFetchedEpoch int32
 }
 
 func (b *fetchRequestBlock) encode(pe packetEncoder, version int16) error {
@@ -21,10 +26,16 @@ func (b *fetchRequestBlock) encode(pe packetEncoder, version int16) error {
 		pe.putInt32(b.currentLeaderEpoch)
 	}
 	pe.putInt64(b.fetchOffset)
+	if b.Version >= 12 {
+		pe.putInt32(b.lastFetchedEpoch)
+	}
 	if b.Version >= 5 {
 		pe.putInt64(b.logStartOffset)
 	}
 	pe.putInt32(b.maxBytes)
+	if b.Version >= 12 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
@@ -38,6 +49,11 @@ func (b *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error)
 	if b.fetchOffset, err = pd.getInt64(); err != nil {
 		return err
 	}
+	if b.Version >= 12 {
+		if b.lastFetchedEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	if b.Version >= 5 {
 		if b.logStartOffset, err = pd.getInt64(); err != nil {
 			return err
@@ -46,6 +62,11 @@ func (b *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error)
 	if b.maxBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
+	if b.Version >= 12 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -85,6 +106,13 @@ type FetchRequest struct {
 	forgotten map[string][]int32
 	// RackID contains a Rack ID of the consumer making this request
 	RackID string
+	// topicIDs contains the IDs of the topics of the request, which replace
+	// their names from version 13, see SetTopicID.
+	topicIDs map[string]Uuid
+
+	// lazyDecompression keeps the compressed record batches of the response in their wire
+	// form, see Consumer.Fetch.LazyDecompression.
+	lazyDecompression bool
 }
 
 type IsolationLevel int8
@@ -110,17 +138,14 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.SessionID)
 		pe.putInt32(r.SessionEpoch)
 	}
-	err = pe.putArrayLength(len(r.blocks))
-	if err != nil {
+	if err = putFetchArrayLength(pe, len(r.blocks), r.Version); err != nil {
 		return err
 	}
 	for topic, blocks := range r.blocks {
-		err = pe.putString(topic)
-		if err != nil {
+		if err = r.putTopic(pe, topic); err != nil {
 			return err
 		}
-		err = pe.putArrayLength(len(blocks))
-		if err != nil {
+		if err = putFetchArrayLength(pe, len(blocks), r.Version); err != nil {
 			return err
 		}
 		for partition, block := range blocks {
@@ -130,32 +155,42 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 				return err
 			}
 		}
+		if r.Version >= 12 {
+			pe.putEmptyTaggedFieldArray()
+		}
 		getOrRegisterTopicMeter("consumer-fetch-rate", topic, metricRegistry).Mark(1)
 	}
 	if r.Version >= 7 {
-		err = pe.putArrayLength(len(r.forgotten))
-		if err != nil {
+		if err = putFetchArrayLength(pe, len(r.forgotten), r.Version); err != nil {
 			return err
 		}
 		for topic, partitions := range r.forgotten {
-			err = pe.putString(topic)
-			if err != nil {
+			if err = r.putTopic(pe, topic); err != nil {
 				return err
 			}
-			err = pe.putArrayLength(len(partitions))
+			if r.Version >= 12 {
+				err = pe.putCompactInt32Array(partitions)
+			} else {
+				err = pe.putInt32Array(partitions)
+			}
 			if err != nil {
 				return err
 			}
-			for _, partition := range partitions {
-				pe.putInt32(partition)
+			if r.Version >= 12 {
+				pe.putEmptyTaggedFieldArray()
 			}
 		}
 	}
-	if r.Version >= 11 {
+	if r.Version >= 12 {
+		err = pe.putCompactString(r.RackID)
+	} else if r.Version >= 11 {
 		err = pe.putString(r.RackID)
-		if err != nil {
-			return err
-		}
+	}
+	if err != nil {
+		return err
+	}
+	if r.Version >= 12 {
+		pe.putEmptyTaggedFieldArray()
 	}
 
 	return nil
@@ -195,20 +230,19 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 			return err
 		}
 	}
-	topicCount, err := pd.getArrayLength()
+	topicCount, err := getFetchArrayLength(pd, r.Version)
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
-		return nil
+	if topicCount > 0 {
+		r.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	}
-	r.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	for i := 0; i < topicCount; i++ {
-		topic, err := pd.getString()
+		topic, err := r.getTopic(pd)
 		if err != nil {
 			return err
 		}
-		partitionCount, err := pd.getArrayLength()
+		partitionCount, err := getFetchArrayLength(pd, r.Version)
 		if err != nil {
 			return err
 		}
@@ -224,38 +258,52 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 			}
 			r.blocks[topic][partition] = fetchBlock
 		}
+		if r.Version >= 12 {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
 	}
 
 	if r.Version >= 7 {
-		forgottenCount, err := pd.getArrayLength()
+		forgottenCount, err := getFetchArrayLength(pd, r.Version)
 		if err != nil {
 			return err
 		}
 		r.forgotten = make(map[string][]int32)
 		for i := 0; i < forgottenCount; i++ {
-			topic, err := pd.getString()
+			topic, err := r.getTopic(pd)
 			if err != nil {
 				return err
 			}
-			partitionCount, err := pd.getArrayLength()
+			var partitions []int32
+			if r.Version >= 12 {
+				partitions, err = pd.getCompactInt32Array()
+			} else {
+				partitions, err = pd.getInt32Array()
+			}
 			if err != nil {
 				return err
 			}
-			r.forgotten[topic] = make([]int32, partitionCount)
-
-			for j := 0; j < partitionCount; j++ {
-				partition, err := pd.getInt32()
-				if err != nil {
+			r.forgotten[topic] = partitions
+			if r.Version >= 12 {
+				if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
 					return err
 				}
-				r.forgotten[topic][j] = partition
 			}
 		}
 	}
 
-	if r.Version >= 11 {
+	if r.Version >= 12 {
+		r.RackID, err = pd.getCompactString()
+	} else if r.Version >= 11 {
 		r.RackID, err = pd.getString()
-		if err != nil {
+	}
+	if err != nil {
+		return err
+	}
+	if r.Version >= 12 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
 			return err
 		}
 	}
@@ -272,9 +320,61 @@ func (r *FetchRequest) version() int16 {
 }
 
 func (r *FetchRequest) headerVersion() int16 {
+	if r.Version >= 12 {
+		return 2
+	}
 	return 1
 }
 
+// putTopic puts the name of the topic, or its ID from version 13.
+func (r *FetchRequest) putTopic(pe packetEncoder, topic string) error {
+	if r.Version >= 13 {
+		id, ok := r.topicIDs[topic]
+		if !ok {
+			return PacketEncodingError{fmt.Sprintf("unknown topic ID of %s in FetchRequest", topic)}
+		}
+		return putUuid(pe, id)
+	}
+	if r.Version >= 12 {
+		return pe.putCompactString(topic)
+	}
+	return pe.putString(topic)
+}
+
+// getTopic gets the name of the topic, or from version 13 its ID, whose string form then
+// stands for the topic in the request.
+func (r *FetchRequest) getTopic(pd packetDecoder) (string, error) {
+	if r.Version >= 13 {
+		id, err := getUuid(pd)
+		if err != nil {
+			return "", err
+		}
+		r.SetTopicID(id.String(), id)
+		return id.String(), nil
+	}
+	if r.Version >= 12 {
+		return pd.getCompactString()
+	}
+	return pd.getString()
+}
+
+// putFetchArrayLength puts the length of an array of the fetch request or response of the
+// version, compact from the flexible version 12.
+func putFetchArrayLength(pe packetEncoder, n int, version int16) error {
+	if version >= 12 {
+		pe.putCompactArrayLength(n)
+		return nil
+	}
+	return pe.putArrayLength(n)
+}
+
+func getFetchArrayLength(pd packetDecoder, version int16) (int, error) {
+	if version >= 12 {
+		return pd.getCompactArrayLength()
+	}
+	return pd.getArrayLength()
+}
+
 func (r *FetchRequest) requiredVersion() KafkaVersion {
 	switch r.Version {
 	case 0:
@@ -297,6 +397,10 @@ func (r *FetchRequest) requiredVersion() KafkaVersion {
 		return V2_1_0_0
 	case 11:
 		return V2_3_0_0
+	case 12:
+		return V2_7_0_0
+	case 13:
+		return V3_1_0_0
 	default:
 		return MaxVersion
 	}
@@ -322,6 +426,26 @@ func (r *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int
 	if r.Version >= 9 {
 		tmp.currentLeaderEpoch = leaderEpoch
 	}
+	if r.Version >= 12 {
+		tmp.lastFetchedEpoch = invalidLeaderEpoch
+	}
 
 	r.blocks[topic][partitionID] = tmp
 }
+
+// forget lists a partition to remove from the fetch session in an incremental fetch request.
+func (r *FetchRequest) forget(topic string, partitionID int32) {
+	if r.forgotten == nil {
+		r.forgotten = make(map[string][]int32)
+	}
+	r.forgotten[topic] = append(r.forgotten[topic], partitionID)
+}
+
+// SetTopicID sets the ID of the topic, which is required to fetch from it with version 13 and
+// later.
+func (r *FetchRequest) SetTopicID(topic string, id Uuid) {
+	if r.topicIDs == nil {
+		r.topicIDs = make(map[string]Uuid)
+	}
+	r.topicIDs[topic] = id
+}
@@ -48,6 +48,53 @@ var (
 		0x00, 0x00, 0x00, 0x00,
 		0x00, 0x06, 'r', 'a', 'c', 'k', '0', '1', // rackID
 	}
+
+	fetchRequestOneBlockV12 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x06, 't', 'o', 'p', 'i', 'c',
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0x00, 0x00, 0x00, 0x66, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00, // partition tagged fields
+		0x00, // topic tagged fields
+		0x02,
+		0x06, 't', 'o', 'p', 'i', 'c', // forgotten topic
+		0x02, 0x00, 0x00, 0x00, 0x13, // forgotten partitions
+		0x00,
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x00,
+	}
+
+	fetchRequestOneBlockV13 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, // topicID
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0x00, 0x00, 0x00, 0x66, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00, // partition tagged fields
+		0x00, // topic tagged fields
+		0x01,
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x00,
+	}
 )
 
 func TestFetchRequest(t *testing.T) {
@@ -91,4 +138,40 @@ func TestFetchRequest(t *testing.T) {
 		request.RackID = "rack01"
 		testRequest(t, "one block v11 rackid", request, fetchRequestOneBlockV11)
 	})
+
+	t.Run("one block v12 flexible", func(t *testing.T) {
+		request := new(FetchRequest)
+		request.Version = 12
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		request.AddBlock("topic", 0x12, 0x34, 0x56, 0x66)
+		request.forget("topic", 0x13)
+		request.RackID = "rack01"
+		testRequest(t, "one block v12", request, fetchRequestOneBlockV12)
+	})
+
+	t.Run("one block v13 topic ID", func(t *testing.T) {
+		id := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+		request := new(FetchRequest)
+		request.Version = 13
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		// decoded requests know the topics by the string form of their ID
+		request.AddBlock(id.String(), 0x12, 0x34, 0x56, 0x66)
+		request.SetTopicID(id.String(), id)
+		request.RackID = "rack01"
+		testRequest(t, "one block v13", request, fetchRequestOneBlockV13)
+	})
+
+	t.Run("v13 without topic ID", func(t *testing.T) {
+		request := &FetchRequest{Version: 13}
+		request.AddBlock("topic", 0x12, 0x34, 0x56, 0x66)
+		if _, err := encode(request, nil); err == nil {
+			t.Error("Expected an error encoding a topic without ID")
+		}
+	})
 }
@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"time"
 
@@ -20,7 +21,7 @@ type AbortedTransaction struct {
 	FirstOffset int64
 }
 
-func (t *AbortedTransaction) decode(pd packetDecoder) (err error) {
+func (t *AbortedTransaction) decode(pd packetDecoder, version int16) (err error) {
 	if t.ProducerID, err = pd.getInt64(); err != nil {
 		return err
 	}
@@ -29,13 +30,23 @@ func (t *AbortedTransaction) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 12 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (t *AbortedTransaction) encode(pe packetEncoder) (err error) {
+func (t *AbortedTransaction) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt64(t.ProducerID)
 	pe.putInt64(t.FirstOffset)
 
+	if version >= 12 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
@@ -62,6 +73,8 @@ type FetchResponseBlock struct {
 
 	Partial bool
 	Records *Records // deprecated: use FetchResponseBlock.RecordsSet
+
+	lazyDecompression bool
 }
 
 func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error) {
@@ -95,7 +108,7 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 			}
 		}
 
-		numTransact, err := pd.getArrayLength()
+		numTransact, err := getFetchArrayLength(pd, version)
 		if err != nil {
 			return err
 		}
@@ -106,7 +119,7 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 
 		for i := 0; i < numTransact; i++ {
 			transact := new(AbortedTransaction)
-			if err = transact.decode(pd); err != nil {
+			if err = transact.decode(pd, version); err != nil {
 				return err
 			}
 			b.AbortedTransactions[i] = transact
@@ -122,8 +135,17 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 		b.PreferredReadReplica = -1
 	}
 
-	recordsSize, err := pd.getInt32()
-	if err != nil {
+	var recordsSize int32
+	if version >= 12 {
+		// the records are compact nullable bytes, null standing for no records
+		n, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			recordsSize = int32(n - 1)
+		}
+	} else if recordsSize, err = pd.getInt32(); err != nil {
 		return err
 	}
 	if sizeMetric != nil {
@@ -138,7 +160,7 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 	b.RecordsSet = []*Records{}
 
 	for recordsDecoder.remaining() > 0 {
-		records := &Records{}
+		records := &Records{lazyDecompression: b.lazyDecompression}
 		if err := records.decode(recordsDecoder); err != nil {
 			// If we have at least one decoded records, this is not an error
 			if errors.Is(err, ErrInsufficientData) {
@@ -183,6 +205,14 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 		}
 	}
 
+	if version >= 12 {
+		// the diverging epoch, current leader and snapshot ID of the partition are tagged
+		// fields only used by followers
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -225,11 +255,11 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 			pe.putInt64(b.LogStartOffset)
 		}
 
-		if err = pe.putArrayLength(len(b.AbortedTransactions)); err != nil {
+		if err = putFetchArrayLength(pe, len(b.AbortedTransactions), version); err != nil {
 			return err
 		}
 		for _, transact := range b.AbortedTransactions {
-			if err = transact.encode(pe); err != nil {
+			if err = transact.encode(pe, version); err != nil {
 				return err
 			}
 		}
@@ -239,16 +269,34 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 		pe.putInt32(b.PreferredReadReplica)
 	}
 
-	pe.push(&lengthField{})
-	for _, records := range b.RecordsSet {
-		err = records.encode(pe)
+	if version >= 12 {
+		raw, err := encode(encoderFunc(b.encodeRecords), pe.metricRegistry())
 		if err != nil {
 			return err
 		}
+		if err = pe.putCompactBytes(raw); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
+	}
+
+	pe.push(&lengthField{})
+	if err = b.encodeRecords(pe); err != nil {
+		return err
 	}
 	return pe.pop()
 }
 
+func (b *FetchResponseBlock) encodeRecords(pe packetEncoder) error {
+	for _, records := range b.RecordsSet {
+		if err := records.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *FetchResponseBlock) getAbortedTransactions() []*AbortedTransaction {
 	// I can't find any doc that guarantee the field `fetchResponse.AbortedTransactions` is ordered
 	// plus Java implementation use a PriorityQueue based on `FirstOffset`. I guess we have to order it ourself
@@ -276,6 +324,14 @@ type FetchResponse struct {
 
 	LogAppendTime bool
 	Timestamp     time.Time
+
+	// topicIDs contains the IDs of the topics of the response, which replace their names
+	// from version 13, see SetTopicID.
+	topicIDs map[string]Uuid
+
+	// lazyDecompression keeps the compressed record batches of the response in their wire
+	// form when decoding it, see RecordBatch.decompressRecords.
+	lazyDecompression bool
 }
 
 func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
@@ -300,19 +356,19 @@ func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	numTopics, err := pd.getArrayLength()
+	numTopics, err := getFetchArrayLength(pd, r.Version)
 	if err != nil {
 		return err
 	}
 
 	r.Blocks = make(map[string]map[int32]*FetchResponseBlock, numTopics)
 	for i := 0; i < numTopics; i++ {
-		name, err := pd.getString()
+		name, err := r.getTopic(pd)
 		if err != nil {
 			return err
 		}
 
-		numBlocks, err := pd.getArrayLength()
+		numBlocks, err := getFetchArrayLength(pd, r.Version)
 		if err != nil {
 			return err
 		}
@@ -325,13 +381,25 @@ func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
 				return err
 			}
 
-			block := new(FetchResponseBlock)
+			block := &FetchResponseBlock{lazyDecompression: r.lazyDecompression}
 			err = block.decode(pd, version)
 			if err != nil {
 				return err
 			}
 			r.Blocks[name][id] = block
 		}
+
+		if r.Version >= 12 {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.Version >= 12 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -347,18 +415,18 @@ func (r *FetchResponse) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.SessionID)
 	}
 
-	err = pe.putArrayLength(len(r.Blocks))
+	err = putFetchArrayLength(pe, len(r.Blocks), r.Version)
 	if err != nil {
 		return err
 	}
 
 	for topic, partitions := range r.Blocks {
-		err = pe.putString(topic)
+		err = r.putTopic(pe, topic)
 		if err != nil {
 			return err
 		}
 
-		err = pe.putArrayLength(len(partitions))
+		err = putFetchArrayLength(pe, len(partitions), r.Version)
 		if err != nil {
 			return err
 		}
@@ -370,10 +438,62 @@ func (r *FetchResponse) encode(pe packetEncoder) (err error) {
 				return err
 			}
 		}
+
+		if r.Version >= 12 {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+
+	if r.Version >= 12 {
+		pe.putEmptyTaggedFieldArray()
 	}
 	return nil
 }
 
+// putTopic puts the name of the topic, or its ID from version 13.
+func (r *FetchResponse) putTopic(pe packetEncoder, topic string) error {
+	if r.Version >= 13 {
+		id, ok := r.topicIDs[topic]
+		if !ok {
+			return PacketEncodingError{fmt.Sprintf("unknown topic ID of %s in FetchResponse", topic)}
+		}
+		return putUuid(pe, id)
+	}
+	if r.Version >= 12 {
+		return pe.putCompactString(topic)
+	}
+	return pe.putString(topic)
+}
+
+// getTopic gets the name of the topic, or from version 13 the name of the topic of the ID as
+// set with SetTopicID, the string form of the ID standing for unknown topics.
+func (r *FetchResponse) getTopic(pd packetDecoder) (string, error) {
+	if r.Version >= 13 {
+		id, err := getUuid(pd)
+		if err != nil {
+			return "", err
+		}
+		for topic, topicID := range r.topicIDs {
+			if topicID == id {
+				return topic, nil
+			}
+		}
+		return id.String(), nil
+	}
+	if r.Version >= 12 {
+		return pd.getCompactString()
+	}
+	return pd.getString()
+}
+
+// SetTopicID sets the ID of the topic, which stands for its name from version 13.
+func (r *FetchResponse) SetTopicID(topic string, id Uuid) {
+	if r.topicIDs == nil {
+		r.topicIDs = make(map[string]Uuid)
+	}
+	r.topicIDs[topic] = id
+}
+
 func (r *FetchResponse) key() int16 {
 	return 1
 }
@@ -383,6 +503,9 @@ func (r *FetchResponse) version() int16 {
 }
 
 func (r *FetchResponse) headerVersion() int16 {
+	if r.Version >= 12 {
+		return 1
+	}
 	return 0
 }
 
@@ -408,6 +531,10 @@ func (r *FetchResponse) requiredVersion() KafkaVersion {
 		return V2_1_0_0
 	case 11:
 		return V2_3_0_0
+	case 12:
+		return V2_7_0_0
+	case 13:
+		return V3_1_0_0
 	default:
 		return MaxVersion
 	}
@@ -425,6 +552,25 @@ func (r *FetchResponse) GetBlock(topic string, partition int32) *FetchResponseBl
 	return r.Blocks[topic][partition]
 }
 
+// merge adds the blocks of other, a response to a request for other partitions sent to the
+// same broker, to the response.
+func (r *FetchResponse) merge(other *FetchResponse) {
+	if other.ThrottleTime > r.ThrottleTime {
+		r.ThrottleTime = other.ThrottleTime
+	}
+	if r.Blocks == nil {
+		r.Blocks = make(map[string]map[int32]*FetchResponseBlock)
+	}
+	for topic, partitions := range other.Blocks {
+		if r.Blocks[topic] == nil {
+			r.Blocks[topic] = make(map[int32]*FetchResponseBlock, len(partitions))
+		}
+		for partition, block := range partitions {
+			r.Blocks[topic][partition] = block
+		}
+	}
+}
+
 func (r *FetchResponse) AddError(topic string, partition int32, err KError) {
 	if r.Blocks == nil {
 		r.Blocks = make(map[string]map[int32]*FetchResponseBlock)
@@ -200,6 +200,55 @@ var (
 		0xFF, 0xFF, 0xFF, 0xFF,
 		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE,
 	}
+
+	preferredReplicaFetchResponseV12 = []byte{
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime
+		0x00, 0x02, // ErrorCode
+		0x00, 0x00, 0x00, 0xAC, // SessionID
+		0x02,                          // Number of Topics
+		0x06, 't', 'o', 'p', 'i', 'c', // Topic
+		0x02,                   // Number of Partitions
+		0x00, 0x00, 0x00, 0x05, // Partition
+		0x00, 0x01, // Error
+		0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x10, // High Watermark Offset
+		0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x09, // Last Stable Offset
+		0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0x01, // Log Start Offset
+		0x01,                   // Number of Aborted Transactions
+		0x00, 0x00, 0x00, 0x03, // Preferred Read Replica
+		0x1D,
+		// messageSet
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x10,
+		// message
+		0x23, 0x96, 0x4a, 0xf7, // CRC
+		0x00,
+		0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE,
+		0x00, // partition tagged fields
+		0x00, // topic tagged fields
+		0x00,
+	}
+
+	oneTopicIDFetchResponseV13 = []byte{
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime
+		0x00, 0x00, // ErrorCode
+		0x00, 0x00, 0x00, 0xAC, // SessionID
+		0x02,                                                                                           // Number of Topics
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, // Topic ID
+		0x02,                   // Number of Partitions
+		0x00, 0x00, 0x00, 0x05, // Partition
+		0x00, 0x64, // Error
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // High Watermark Offset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Last Stable Offset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Log Start Offset
+		0x01,                   // Number of Aborted Transactions
+		0xFF, 0xFF, 0xFF, 0xFF, // Preferred Read Replica
+		0x01, // no records
+		0x00, // partition tagged fields
+		0x00, // topic tagged fields
+		0x00,
+	}
 )
 
 func TestEmptyFetchResponse(t *testing.T) {
@@ -590,3 +639,59 @@ func TestPreferredReplicaFetchResponseV11(t *testing.T) {
 		t.Error("Decoding produced incorrect message value.")
 	}
 }
+
+func TestPreferredReplicaFetchResponseV12(t *testing.T) {
+	response := FetchResponse{}
+	testVersionDecodable(
+		t, "preferred replica fetch response v12", &response,
+		preferredReplicaFetchResponseV12, 12)
+
+	if response.SessionID != 0x000000AC {
+		t.Fatal("Decoding produced incorrect session ID.")
+	}
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("GetBlock didn't return block.")
+	}
+	if !errors.Is(block.Err, ErrOffsetOutOfRange) {
+		t.Error("Decoding didn't produce correct error code.")
+	}
+	if block.PreferredReadReplica != 0x0003 {
+		t.Error("Decoding didn't produce correct preferred read replica.")
+	}
+	n, err := block.numRecords()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Fatal("Decoding produced incorrect number of records.")
+	}
+	if !bytes.Equal(block.RecordsSet[0].MsgSet.Messages[0].Msg.Value, []byte{0x00, 0xEE}) {
+		t.Error("Decoding produced incorrect message value.")
+	}
+
+	testEncodable(t, "preferred replica fetch response v12", &response, preferredReplicaFetchResponseV12)
+}
+
+func TestTopicIDFetchResponseV13(t *testing.T) {
+	id := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+
+	response := FetchResponse{}
+	response.SetTopicID("topic", id)
+	testVersionDecodable(t, "topic ID fetch response v13", &response, oneTopicIDFetchResponseV13, 13)
+
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("GetBlock didn't return the block of the topic of the ID.")
+	}
+	if !errors.Is(block.Err, ErrUnknownTopicID) {
+		t.Error("Decoding didn't produce correct error code.")
+	}
+	testEncodable(t, "topic ID fetch response v13", &response, oneTopicIDFetchResponseV13)
+
+	unknown := FetchResponse{}
+	testVersionDecodable(t, "unknown topic ID fetch response v13", &unknown, oneTopicIDFetchResponseV13, 13)
+	if unknown.GetBlock(id.String(), 5) == nil {
+		t.Error("Decoding didn't key the block of an unknown topic ID by the ID.")
+	}
+}
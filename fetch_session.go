@@ -0,0 +1,122 @@
+package sarama
+
+import "math"
+
+// fetchSessionPartition is the state of a partition as last sent to the broker in a fetch session.
+type fetchSessionPartition struct {
+	fetchOffset int64
+	maxBytes    int32
+	leaderEpoch int32
+}
+
+// fetchSession tracks an incremental fetch session (KIP-227) with a broker. Once the broker
+// created the session, fetch requests only list the partitions added to the session or whose
+// fetch position changed, and the partitions removed from it as forgotten, instead of every
+// partition consumed from the broker.
+type fetchSession struct {
+	id         int32
+	epoch      int32 // 0 when the next request is a full request asking for a new session
+	partitions map[topicPartition]fetchSessionPartition
+	pending    map[topicPartition]fetchSessionPartition // sent and not yet acknowledged
+	topicIDs   map[string]Uuid                          // nil when fetching topics by name
+}
+
+func newFetchSession() *fetchSession {
+	return &fetchSession{partitions: make(map[topicPartition]fetchSessionPartition)}
+}
+
+// build adds the given partitions to the request, only listing the changes since the previous
+// request when the session is established.
+func (s *fetchSession) build(request *FetchRequest, partitions map[topicPartition]fetchSessionPartition) {
+	if s.epoch != 0 && s.topicIDsChanged(request) {
+		// the broker rejects incremental requests switching between topic names and IDs, or
+		// listing a topic created again under a new ID
+		Logger.Printf("consumer/fetch-session/%d reset because the topic IDs changed\n", s.id)
+		s.reset()
+	}
+	s.updateTopicIDs(request)
+
+	request.SessionID = s.id
+	request.SessionEpoch = s.epoch
+	s.pending = partitions
+
+	for tp, p := range partitions {
+		if prev, ok := s.partitions[tp]; s.epoch == 0 || !ok || prev != p {
+			request.AddBlock(tp.topic, tp.partition, p.fetchOffset, p.maxBytes, p.leaderEpoch)
+		}
+	}
+	if s.epoch == 0 {
+		return
+	}
+	for tp := range s.partitions {
+		if _, ok := partitions[tp]; !ok {
+			request.forget(tp.topic, tp.partition)
+			if id, ok := s.topicIDs[tp.topic]; ok && request.Version >= 13 {
+				request.SetTopicID(tp.topic, id)
+			}
+		}
+	}
+}
+
+// topicIDsChanged returns whether the request fetches topics by ID while the session fetched
+// them by name or the other way around, or if the ID of any of its topics changed.
+func (s *fetchSession) topicIDsChanged(request *FetchRequest) bool {
+	if (request.Version >= 13) != (s.topicIDs != nil) {
+		return true
+	}
+	for topic, id := range request.topicIDs {
+		if prev, ok := s.topicIDs[topic]; ok && prev != id {
+			return true
+		}
+	}
+	return false
+}
+
+// updateTopicIDs records the IDs of the topics of the request, keeping the ones of the topics
+// which might still need to be forgotten.
+func (s *fetchSession) updateTopicIDs(request *FetchRequest) {
+	if request.Version < 13 {
+		s.topicIDs = nil
+		return
+	}
+	if s.topicIDs == nil {
+		s.topicIDs = make(map[string]Uuid, len(request.topicIDs))
+	}
+	for topic, id := range request.topicIDs {
+		s.topicIDs[topic] = id
+	}
+}
+
+// update moves the session to its next epoch once the broker answered the last request, or
+// resets it if the broker did not create a session or failed to find it.
+func (s *fetchSession) update(response *FetchResponse) {
+	if kerr := KError(response.ErrorCode); kerr != ErrNoError {
+		Logger.Printf("consumer/fetch-session/%d reset because %s\n", s.id, kerr)
+		s.reset()
+		return
+	}
+	if response.SessionID == 0 {
+		// the broker did not create a session, keep sending full requests
+		s.reset()
+		return
+	}
+
+	if s.epoch == 0 {
+		s.id = response.SessionID
+	}
+	if s.epoch == math.MaxInt32 {
+		s.epoch = 1
+	} else {
+		s.epoch++
+	}
+	s.partitions, s.pending = s.pending, nil
+}
+
+// reset discards the session, so that the next request is a full request.
+func (s *fetchSession) reset() {
+	s.id = 0
+	s.epoch = 0
+	s.partitions = make(map[topicPartition]fetchSessionPartition)
+	s.pending = nil
+	s.topicIDs = nil
+}
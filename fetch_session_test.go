@@ -0,0 +1,64 @@
+package sarama
+
+import "testing"
+
+func TestFetchSessionForgetsRemovedPartitions(t *testing.T) {
+	session := newFetchSession()
+	partitions := map[topicPartition]fetchSessionPartition{
+		{topic: "my_topic", partition: 0}: {fetchOffset: 10, maxBytes: 1024},
+		{topic: "my_topic", partition: 1}: {fetchOffset: 20, maxBytes: 1024},
+	}
+
+	request := &FetchRequest{Version: 7}
+	session.build(request, partitions)
+	if len(request.blocks["my_topic"]) != 2 || len(request.forgotten) != 0 {
+		t.Fatalf("Expected a full request, got blocks %v and forgotten %v", request.blocks, request.forgotten)
+	}
+	session.update(&FetchResponse{Version: 7, SessionID: 42})
+
+	request = &FetchRequest{Version: 7}
+	session.build(request, map[topicPartition]fetchSessionPartition{
+		{topic: "my_topic", partition: 0}: {fetchOffset: 10, maxBytes: 1024},
+		{topic: "my_topic", partition: 2}: {fetchOffset: 30, maxBytes: 1024},
+	})
+	if request.SessionID != 42 || request.SessionEpoch != 1 {
+		t.Errorf("Expected session 42 epoch 1, got session %d epoch %d", request.SessionID, request.SessionEpoch)
+	}
+	if len(request.blocks["my_topic"]) != 1 || request.blocks["my_topic"][2] == nil {
+		t.Errorf("Expected the added partition only to be listed, got %v", request.blocks)
+	}
+	if forgotten := request.forgotten["my_topic"]; len(forgotten) != 1 || forgotten[0] != 1 {
+		t.Errorf("Expected the removed partition to be forgotten, got %v", request.forgotten)
+	}
+}
+
+func TestFetchSessionResetsOnTopicIDChange(t *testing.T) {
+	session := newFetchSession()
+	partitions := map[topicPartition]fetchSessionPartition{
+		{topic: "my_topic", partition: 0}: {fetchOffset: 10, maxBytes: 1024},
+	}
+
+	request := &FetchRequest{Version: 13}
+	request.SetTopicID("my_topic", Uuid{0x01})
+	session.build(request, partitions)
+	session.update(&FetchResponse{Version: 13, SessionID: 42})
+
+	// the topic was created again under a new ID
+	request = &FetchRequest{Version: 13}
+	request.SetTopicID("my_topic", Uuid{0x02})
+	session.build(request, partitions)
+	if request.SessionID != 0 || request.SessionEpoch != 0 {
+		t.Errorf("Expected a full request, got session %d epoch %d", request.SessionID, request.SessionEpoch)
+	}
+	if request.blocks["my_topic"][0] == nil {
+		t.Errorf("Expected the partition to be listed, got %v", request.blocks)
+	}
+	session.update(&FetchResponse{Version: 13, SessionID: 43})
+
+	// the topics are fetched by name again
+	request = &FetchRequest{Version: 12}
+	session.build(request, partitions)
+	if request.SessionID != 0 || request.SessionEpoch != 0 {
+		t.Errorf("Expected a full request, got session %d epoch %d", request.SessionID, request.SessionEpoch)
+	}
+}
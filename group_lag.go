@@ -0,0 +1,90 @@
+package sarama
+
+import "sort"
+
+// GroupLag is the lag of a consumer group, as returned by ClusterAdmin.GroupLag.
+type GroupLag struct {
+	Group string
+	// State is the state of the group, such as "Stable" or "Empty".
+	State string
+	// Topics are the lags of the topics the group committed offsets for or is assigned
+	// partitions of, by topic.
+	Topics map[string]*TopicLag
+	// Lag is the total lag of the partitions of all the topics.
+	Lag int64
+}
+
+// TopicLag is the lag of a consumer group on a topic.
+type TopicLag struct {
+	Topic string
+	// Partitions are the lags of the partitions of the topic, by partition.
+	Partitions map[int32]*PartitionLag
+	// Lag is the total lag of the partitions of the topic whose lag is known.
+	Lag int64
+}
+
+// PartitionLag is the lag of a consumer group on a partition.
+type PartitionLag struct {
+	// Committed is the offset committed by the group for the partition, or -1 if it has not
+	// committed any.
+	Committed int64
+	Metadata  string
+	// End is the offset of the next record to be written to the partition, or its last stable
+	// offset if Consumer.IsolationLevel is ReadCommitted, or -1 if it is unknown.
+	End int64
+	// Lag is the number of records between Committed and End, or -1 if one of them is unknown.
+	Lag int64
+	// MemberID, ClientID and ClientHost identify the member of the group the partition is
+	// assigned to, if any.
+	MemberID   string
+	ClientID   string
+	ClientHost string
+	// Err is the error encountered fetching the committed or end offset of the partition.
+	Err KError
+}
+
+// SortedPartitions returns the partitions of the topic, sorted.
+func (l *TopicLag) SortedPartitions() []int32 {
+	partitions := make([]int32, 0, len(l.Partitions))
+	for partition := range l.Partitions {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
+
+// partition returns the lag of the partition of the topic, adding it if needed.
+func (l *GroupLag) partition(topic string, partition int32) *PartitionLag {
+	t := l.Topics[topic]
+	if t == nil {
+		t = &TopicLag{Topic: topic, Partitions: make(map[int32]*PartitionLag)}
+		l.Topics[topic] = t
+	}
+	p := t.Partitions[partition]
+	if p == nil {
+		p = &PartitionLag{Committed: -1, End: -1, Lag: -1}
+		t.Partitions[partition] = p
+	}
+	return p
+}
+
+// sum computes the lag of the partitions and the totals of the topics and of the group.
+func (l *GroupLag) sum() {
+	l.Lag = 0
+	for _, t := range l.Topics {
+		t.Lag = 0
+		for _, p := range t.Partitions {
+			if p.Committed < 0 || p.End < 0 {
+				p.Lag = -1
+				continue
+			}
+			p.Lag = p.End - p.Committed
+			if p.Lag < 0 {
+				// the end offset was resolved before the last commit
+				p.Lag = 0
+			}
+			t.Lag += p.Lag
+		}
+		l.Lag += t.Lag
+	}
+}
@@ -1,11 +1,20 @@
 package sarama
 
+// IncrementalAlterConfigsOperation is the operation applied to a config entry by an
+// IncrementalAlterConfigsRequest (KIP-339). Unlike AlterConfigsRequest, which replaces the
+// whole config of a resource, only the entries listed in the request are changed.
 type IncrementalAlterConfigsOperation int8
 
 const (
+	// IncrementalAlterConfigsOperationSet sets the value of the config entry.
 	IncrementalAlterConfigsOperationSet IncrementalAlterConfigsOperation = iota
+	// IncrementalAlterConfigsOperationDelete reverts the config entry to its default value.
 	IncrementalAlterConfigsOperationDelete
+	// IncrementalAlterConfigsOperationAppend adds the comma-separated values to those of a
+	// list config entry, such as cleanup.policy.
 	IncrementalAlterConfigsOperationAppend
+	// IncrementalAlterConfigsOperationSubtract removes the comma-separated values from those
+	// of a list config entry.
 	IncrementalAlterConfigsOperationSubtract
 )
 
@@ -1,5 +1,7 @@
 package sarama
 
+import "time"
+
 // ProducerInterceptor allows you to intercept (and possibly mutate) the records
 // received by the producer before they are published to the Kafka cluster.
 // https://cwiki.apache.org/confluence/display/KAFKA/KIP-42%3A+Add+Producer+and+Consumer+Interceptors#KIP42:AddProducerandConsumerInterceptors-Motivation
@@ -22,6 +24,61 @@ type ConsumerInterceptor interface {
 	OnConsume(*ConsumerMessage)
 }
 
+// ConsumerCommitInterceptor is a ConsumerInterceptor which is also notified of the
+// offsets committed by offset managers and consumer groups using the same Config.
+type ConsumerCommitInterceptor interface {
+	ConsumerInterceptor
+
+	// OnCommit is called after offsets have been successfully committed for the
+	// consumer group, with the committed offsets by topic and partition. It must
+	// not modify the map.
+	OnCommit(group string, offsets map[string]map[int32]int64)
+}
+
+// BrokerInterceptor sees the requests sent to and the responses received from the brokers on
+// the wire, for protocol-level middleware such as request mirroring, audit capture or
+// fine-grained metrics. The SASL authentication exchanges are not intercepted.
+type BrokerInterceptor interface {
+
+	// OnRequest is called with every request once encoded, before it is sent
+	// to the broker. It must not modify or retain the encoded request.
+	OnRequest(broker *Broker, req *InterceptedRequest)
+
+	// OnResponse is called with every response once decoded, or with the error
+	// failing the request. It is not called for the requests without response,
+	// such as the produce requests with NoResponse. It may be called from the
+	// goroutine receiving the responses, so it must not block.
+	OnResponse(broker *Broker, res *InterceptedResponse)
+}
+
+// InterceptedRequest is a request seen by a BrokerInterceptor.
+type InterceptedRequest struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	// Body is the request, such as a *MetadataRequest.
+	Body interface{}
+	// Encoded is the request as written to the connection, size and header
+	// included.
+	Encoded []byte
+}
+
+// InterceptedResponse is a response seen by a BrokerInterceptor.
+type InterceptedResponse struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	// Body is the decoded response, such as a *MetadataResponse, nil if Err is
+	// set.
+	Body interface{}
+	// Size is the number of bytes read for the response, header included.
+	Size int
+	// Latency is the time between sending the request and receiving the
+	// response.
+	Latency time.Duration
+	Err     error
+}
+
 func (msg *ProducerMessage) safelyApplyInterceptor(interceptor ProducerInterceptor) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -41,3 +98,76 @@ func (msg *ConsumerMessage) safelyApplyInterceptor(interceptor ConsumerIntercept
 
 	interceptor.OnConsume(msg)
 }
+
+func safelyApplyCommitInterceptor(interceptor ConsumerCommitInterceptor, group string, offsets map[string]map[int32]int64) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Printf("Error when calling consumer commit interceptor: %s, %w\n", interceptor, r)
+		}
+	}()
+
+	interceptor.OnCommit(group, offsets)
+}
+
+func safelyApplyRequestInterceptor(interceptor BrokerInterceptor, broker *Broker, req *InterceptedRequest) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Printf("Error when calling broker interceptor: %s, %w\n", interceptor, r)
+		}
+	}()
+
+	interceptor.OnRequest(broker, req)
+}
+
+func safelyApplyResponseInterceptor(interceptor BrokerInterceptor, broker *Broker, res *InterceptedResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Printf("Error when calling broker interceptor: %s, %w\n", interceptor, r)
+		}
+	}()
+
+	interceptor.OnResponse(broker, res)
+}
+
+// interceptRequest passes the encoded request to the Net.Interceptors.
+func (b *Broker) interceptRequest(req *request, encoded []byte) {
+	if len(b.conf.Net.Interceptors) == 0 {
+		return
+	}
+	intercepted := &InterceptedRequest{
+		APIKey:        req.body.key(),
+		APIVersion:    req.body.version(),
+		CorrelationID: req.correlationID,
+		Body:          req.body,
+		Encoded:       encoded,
+	}
+	for _, interceptor := range b.conf.Net.Interceptors {
+		safelyApplyRequestInterceptor(interceptor, b, intercepted)
+	}
+}
+
+// interceptResponse passes the response to the request, or the error failing it, to the
+// Net.Interceptors.
+func (b *Broker) interceptResponse(req protocolBody, promise *responsePromise, res protocolBody, err error) {
+	if len(b.conf.Net.Interceptors) == 0 {
+		return
+	}
+	latency := promise.latency
+	if latency == 0 {
+		latency = time.Since(promise.requestTime)
+	}
+	intercepted := &InterceptedResponse{
+		APIKey:        req.key(),
+		APIVersion:    req.version(),
+		CorrelationID: promise.correlationID,
+		Size:          promise.responseSize,
+		Latency:       latency,
+		Err:           err,
+	}
+	if err == nil {
+		intercepted.Body = res
+	}
+	for _, interceptor := range b.conf.Net.Interceptors {
+		safelyApplyResponseInterceptor(interceptor, b, intercepted)
+	}
+}
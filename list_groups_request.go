@@ -1,13 +1,52 @@
 package sarama
 
-type ListGroupsRequest struct{}
+// ListGroupsRequest (Version: 5) => [states_filter] [types_filter] TAG_BUFFER
+//   states_filter => COMPACT_STRING
+//   types_filter => COMPACT_STRING
+
+type ListGroupsRequest struct {
+	Version int16
+	// StatesFilter are the states of the groups to list, such as "Stable", all if empty
+	// (KIP-518). It requires version 4 or higher.
+	StatesFilter []string
+	// TypesFilter are the types of the groups to list, such as "classic" or "consumer", all if
+	// empty (KIP-848). It requires version 5 or higher.
+	TypesFilter []string
+}
 
 func (r *ListGroupsRequest) encode(pe packetEncoder) error {
+	if r.Version >= 4 {
+		if err := putCompactStringArray(pe, r.StatesFilter); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 5 {
+		if err := putCompactStringArray(pe, r.TypesFilter); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 3 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (r *ListGroupsRequest) decode(pd packetDecoder, version int16) (err error) {
-	return nil
+	r.Version = version
+	if r.Version >= 4 {
+		if r.StatesFilter, err = getCompactStringArray(pd); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 5 {
+		if r.TypesFilter, err = getCompactStringArray(pd); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 3 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *ListGroupsRequest) key() int16 {
@@ -15,13 +54,53 @@ func (r *ListGroupsRequest) key() int16 {
 }
 
 func (r *ListGroupsRequest) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (r *ListGroupsRequest) headerVersion() int16 {
+	if r.Version >= 3 {
+		return 2
+	}
 	return 1
 }
 
 func (r *ListGroupsRequest) requiredVersion() KafkaVersion {
-	return V0_9_0_0
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3:
+		return V2_4_0_0
+	case 4:
+		return V2_6_0_0
+	case 5:
+		return V3_8_0_0
+	default:
+		return V0_9_0_0
+	}
+}
+
+func putCompactStringArray(pe packetEncoder, in []string) error {
+	pe.putCompactArrayLength(len(in))
+	for _, s := range in {
+		if err := pe.putCompactString(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getCompactStringArray(pd packetDecoder) ([]string, error) {
+	n, err := pd.getCompactArrayLength()
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	out := make([]string, n)
+	for i := range out {
+		if out[i], err = pd.getCompactString(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
 }
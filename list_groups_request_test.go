@@ -4,4 +4,29 @@ import "testing"
 
 func TestListGroupsRequest(t *testing.T) {
 	testRequest(t, "ListGroupsRequest", &ListGroupsRequest{}, []byte{})
+
+	testRequest(t, "ListGroupsRequest V3", &ListGroupsRequest{Version: 3}, []byte{
+		0, // empty tagged fields
+	})
+
+	testRequest(t, "ListGroupsRequest V4", &ListGroupsRequest{
+		Version:      4,
+		StatesFilter: []string{"Empty"},
+	}, []byte{
+		2,                          // 1 state
+		6, 'E', 'm', 'p', 't', 'y', // state
+		0, // empty tagged fields
+	})
+
+	testRequest(t, "ListGroupsRequest V5", &ListGroupsRequest{
+		Version:      5,
+		StatesFilter: []string{"Stable"},
+		TypesFilter:  []string{"classic"},
+	}, []byte{
+		2,                               // 1 state
+		7, 'S', 't', 'a', 'b', 'l', 'e', // state
+		2,                                    // 1 type
+		8, 'c', 'l', 'a', 's', 's', 'i', 'c', // type
+		0, // empty tagged fields
+	})
 }
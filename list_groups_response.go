@@ -1,29 +1,82 @@
 package sarama
 
+import "time"
+
+// GroupData is the state and type of a group listed by a ListGroupsRequest.
+type GroupData struct {
+	// GroupState is the state of the group, such as "Stable" or "Empty", from version 4.
+	GroupState string
+	// GroupType is the type of the group, such as "classic" or "consumer", from version 5.
+	GroupType string
+}
+
 type ListGroupsResponse struct {
-	Err    KError
+	Version      int16
+	ThrottleTime time.Duration
+	Err          KError
+	// Groups are the protocol types of the groups, by group ID.
 	Groups map[string]string
+	// GroupsData are the states and types of the groups, by group ID, from version 4.
+	GroupsData map[string]GroupData
 }
 
 func (r *ListGroupsResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
+
 	pe.putInt16(int16(r.Err))
 
-	if err := pe.putArrayLength(len(r.Groups)); err != nil {
+	if r.Version >= 3 {
+		pe.putCompactArrayLength(len(r.Groups))
+	} else if err := pe.putArrayLength(len(r.Groups)); err != nil {
 		return err
 	}
 	for groupId, protocolType := range r.Groups {
-		if err := pe.putString(groupId); err != nil {
+		if err := r.putString(pe, groupId); err != nil {
 			return err
 		}
-		if err := pe.putString(protocolType); err != nil {
+		if err := r.putString(pe, protocolType); err != nil {
 			return err
 		}
+		if r.Version >= 4 {
+			if err := pe.putCompactString(r.GroupsData[groupId].GroupState); err != nil {
+				return err
+			}
+		}
+		if r.Version >= 5 {
+			if err := pe.putCompactString(r.GroupsData[groupId].GroupType); err != nil {
+				return err
+			}
+		}
+		if r.Version >= 3 {
+			pe.putEmptyTaggedFieldArray()
+		}
 	}
 
+	if r.Version >= 3 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
+func (r *ListGroupsResponse) putString(pe packetEncoder, in string) error {
+	if r.Version >= 3 {
+		return pe.putCompactString(in)
+	}
+	return pe.putString(in)
+}
+
 func (r *ListGroupsResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	if r.Version >= 1 {
+		throttleTime, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+	}
+
 	kerr, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -31,29 +84,64 @@ func (r *ListGroupsResponse) decode(pd packetDecoder, version int16) error {
 
 	r.Err = KError(kerr)
 
-	n, err := pd.getArrayLength()
+	var n int
+	if r.Version >= 3 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
-	if n == 0 {
-		return nil
-	}
 
-	r.Groups = make(map[string]string)
+	if n > 0 {
+		r.Groups = make(map[string]string, n)
+		if r.Version >= 4 {
+			r.GroupsData = make(map[string]GroupData, n)
+		}
+	}
 	for i := 0; i < n; i++ {
-		groupId, err := pd.getString()
+		groupId, err := r.getString(pd)
 		if err != nil {
 			return err
 		}
-		protocolType, err := pd.getString()
+		protocolType, err := r.getString(pd)
 		if err != nil {
 			return err
 		}
 
 		r.Groups[groupId] = protocolType
+
+		if r.Version >= 4 {
+			var data GroupData
+			if data.GroupState, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			if r.Version >= 5 {
+				if data.GroupType, err = pd.getCompactString(); err != nil {
+					return err
+				}
+			}
+			r.GroupsData[groupId] = data
+		}
+		if r.Version >= 3 {
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
 	}
 
-	return nil
+	if r.Version >= 3 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
+}
+
+func (r *ListGroupsResponse) getString(pd packetDecoder) (string, error) {
+	if r.Version >= 3 {
+		return pd.getCompactString()
+	}
+	return pd.getString()
 }
 
 func (r *ListGroupsResponse) key() int16 {
@@ -61,13 +149,33 @@ func (r *ListGroupsResponse) key() int16 {
 }
 
 func (r *ListGroupsResponse) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (r *ListGroupsResponse) headerVersion() int16 {
+	if r.Version >= 3 {
+		return 1
+	}
 	return 0
 }
 
 func (r *ListGroupsResponse) requiredVersion() KafkaVersion {
-	return V0_9_0_0
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3:
+		return V2_4_0_0
+	case 4:
+		return V2_6_0_0
+	case 5:
+		return V3_8_0_0
+	default:
+		return V0_9_0_0
+	}
+}
+
+func (r *ListGroupsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
 }
@@ -3,6 +3,7 @@ package sarama
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 var (
@@ -22,6 +23,18 @@ var (
 		0, 3, 'f', 'o', 'o', // group name
 		0, 8, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
 	}
+
+	listGroupsResponseV5 = []byte{
+		0, 0, 0, 100, // throttle time
+		0, 0, // no error
+		2,                // 1 group
+		4, 'f', 'o', 'o', // group name
+		9, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
+		7, 'S', 't', 'a', 'b', 'l', 'e', // group state
+		9, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // group type
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
 )
 
 func TestListGroupsResponse(t *testing.T) {
@@ -57,3 +70,22 @@ func TestListGroupsResponse(t *testing.T) {
 		t.Error("Expected foo group to use consumer protocol")
 	}
 }
+
+func TestListGroupsResponseV5(t *testing.T) {
+	response := &ListGroupsResponse{
+		Version:      5,
+		ThrottleTime: 100 * time.Millisecond,
+		Groups:       map[string]string{"foo": "consumer"},
+		GroupsData:   map[string]GroupData{"foo": {GroupState: "Stable", GroupType: "consumer"}},
+	}
+	testResponse(t, "v5", response, listGroupsResponseV5)
+
+	response = new(ListGroupsResponse)
+	testVersionDecodable(t, "v5", response, listGroupsResponseV5, 5)
+	if response.GroupsData["foo"].GroupState != "Stable" || response.GroupsData["foo"].GroupType != "consumer" {
+		t.Errorf("Unexpected group data %+v", response.GroupsData["foo"])
+	}
+	if response.ThrottleTime != 100*time.Millisecond {
+		t.Errorf("Unexpected throttle time %v", response.ThrottleTime)
+	}
+}
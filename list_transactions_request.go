@@ -0,0 +1,99 @@
+package sarama
+
+import "time"
+
+// ListTransactionsRequest (Version: 1) => [state_filters] [producer_id_filters] duration_filter TAG_BUFFER
+//   state_filters => COMPACT_STRING
+//   producer_id_filters => INT64
+//   duration_filter => INT64
+
+// ListTransactionsRequest lists the transactions a broker is the coordinator of.
+type ListTransactionsRequest struct {
+	Version int16
+	// StateFilters are the states of the transactions to list, all if empty.
+	StateFilters []string
+	// ProducerIDFilters are the producers of the transactions to list, all if empty.
+	ProducerIDFilters []int64
+	// DurationFilter lists only the transactions running for longer, from version 1, all if
+	// it is not positive.
+	DurationFilter time.Duration
+}
+
+func (r *ListTransactionsRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.StateFilters))
+	for _, state := range r.StateFilters {
+		if err := pe.putCompactString(state); err != nil {
+			return err
+		}
+	}
+	pe.putCompactArrayLength(len(r.ProducerIDFilters))
+	for _, id := range r.ProducerIDFilters {
+		pe.putInt64(id)
+	}
+	if r.Version >= 1 {
+		if r.DurationFilter > 0 {
+			pe.putInt64(int64(r.DurationFilter / time.Millisecond))
+		} else {
+			pe.putInt64(-1)
+		}
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ListTransactionsRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.StateFilters = make([]string, n)
+	}
+	for i := 0; i < n; i++ {
+		if r.StateFilters[i], err = pd.getCompactString(); err != nil {
+			return err
+		}
+	}
+	if n, err = pd.getCompactArrayLength(); err != nil {
+		return err
+	}
+	if n > 0 {
+		r.ProducerIDFilters = make([]int64, n)
+	}
+	for i := 0; i < n; i++ {
+		if r.ProducerIDFilters[i], err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 1 {
+		millis, err := pd.getInt64()
+		if err != nil {
+			return err
+		}
+		if millis > 0 {
+			r.DurationFilter = time.Duration(millis) * time.Millisecond
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ListTransactionsRequest) key() int16 {
+	return 66
+}
+
+func (r *ListTransactionsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ListTransactionsRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *ListTransactionsRequest) requiredVersion() KafkaVersion {
+	if r.Version >= 1 {
+		return V3_8_0_0
+	}
+	return V3_0_0_0
+}
@@ -0,0 +1,42 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	listTransactionsRequestAll = []byte{
+		1, // no state filter
+		1, // no producer ID filter
+		0, // empty tagged fields
+	}
+
+	listTransactionsRequestFiltered = []byte{
+		2,                                    // 1 state filter
+		8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // state
+		2,                       // 1 producer ID filter
+		0, 0, 0, 0, 0, 0, 0, 42, // producer ID
+		0, // empty tagged fields
+	}
+
+	listTransactionsRequestDuration = []byte{
+		1,                            // no state filter
+		1,                            // no producer ID filter
+		0, 0, 0, 0, 0, 0, 0xea, 0x60, // duration filter: 60000 ms
+		0, // empty tagged fields
+	}
+)
+
+func TestListTransactionsRequest(t *testing.T) {
+	request := &ListTransactionsRequest{}
+	testRequest(t, "all", request, listTransactionsRequestAll)
+
+	request = &ListTransactionsRequest{StateFilters: []string{"Ongoing"}, ProducerIDFilters: []int64{42}}
+	testRequest(t, "filtered", request, listTransactionsRequestFiltered)
+}
+
+func TestListTransactionsRequestV1(t *testing.T) {
+	request := &ListTransactionsRequest{Version: 1, DurationFilter: time.Minute}
+	testRequest(t, "duration", request, listTransactionsRequestDuration)
+}
@@ -0,0 +1,119 @@
+package sarama
+
+import "time"
+
+// TransactionListing is a transaction listed by a ListTransactionsRequest.
+type TransactionListing struct {
+	TransactionalID string
+	ProducerID      int64
+	State           string
+}
+
+// ListTransactionsResponse are the transactions of a coordinator.
+type ListTransactionsResponse struct {
+	Version      int16
+	ThrottleTime time.Duration
+	ErrorCode    KError
+	// UnknownStateFilters are the state filters of the request the broker does not know.
+	UnknownStateFilters []string
+	TransactionStates   []TransactionListing
+}
+
+func (r *ListTransactionsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.ErrorCode))
+	pe.putCompactArrayLength(len(r.UnknownStateFilters))
+	for _, state := range r.UnknownStateFilters {
+		if err := pe.putCompactString(state); err != nil {
+			return err
+		}
+	}
+	pe.putCompactArrayLength(len(r.TransactionStates))
+	for _, listing := range r.TransactionStates {
+		if err := pe.putCompactString(listing.TransactionalID); err != nil {
+			return err
+		}
+		pe.putInt64(listing.ProducerID)
+		if err := pe.putCompactString(listing.State); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ListTransactionsResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.ErrorCode = KError(kerr)
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.UnknownStateFilters = make([]string, n)
+	}
+	for i := 0; i < n; i++ {
+		if r.UnknownStateFilters[i], err = pd.getCompactString(); err != nil {
+			return err
+		}
+	}
+
+	if n, err = pd.getCompactArrayLength(); err != nil {
+		return err
+	}
+	if n > 0 {
+		r.TransactionStates = make([]TransactionListing, n)
+	}
+	for i := 0; i < n; i++ {
+		listing := &r.TransactionStates[i]
+		if listing.TransactionalID, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if listing.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if listing.State, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ListTransactionsResponse) key() int16 {
+	return 66
+}
+
+func (r *ListTransactionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ListTransactionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *ListTransactionsResponse) requiredVersion() KafkaVersion {
+	if r.Version >= 1 {
+		return V3_8_0_0
+	}
+	return V3_0_0_0
+}
+
+func (r *ListTransactionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
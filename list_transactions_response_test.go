@@ -0,0 +1,24 @@
+package sarama
+
+import "testing"
+
+var listTransactionsResponse = []byte{
+	0, 0, 0, 0, // throttle time
+	0, 0, // no error
+	2,                // 1 unknown state filter
+	4, 'F', 'o', 'o', // unknown state
+	2,           // 1 transaction
+	3, 't', 'x', // transactional ID
+	0, 0, 0, 0, 0, 0, 0, 42, // producer ID
+	8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // state
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestListTransactionsResponse(t *testing.T) {
+	response := &ListTransactionsResponse{
+		UnknownStateFilters: []string{"Foo"},
+		TransactionStates:   []TransactionListing{{TransactionalID: "tx", ProducerID: 42, State: "Ongoing"}},
+	}
+	testResponse(t, "one transaction", response, listTransactionsResponse)
+}
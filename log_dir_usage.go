@@ -0,0 +1,111 @@
+package sarama
+
+import "sort"
+
+// LogDirUsageReport is the disk usage of the log dirs of brokers, as returned by
+// ClusterAdmin.DescribeLogDirUsage.
+type LogDirUsageReport struct {
+	// Brokers are the usages of the brokers that answered, by broker ID.
+	Brokers map[int32]*BrokerLogDirUsage
+	// Size is the total size in bytes of the logs of all the brokers.
+	Size int64
+}
+
+// BrokerLogDirUsage is the disk usage of the log dirs of a broker.
+type BrokerLogDirUsage struct {
+	BrokerID int32
+	// LogDirs are the usages of the log dirs of the broker, by path.
+	LogDirs map[string]*LogDirUsage
+	// Size is the total size in bytes of the logs of the broker.
+	Size int64
+}
+
+// LogDirUsage is the disk usage of a log dir of a broker.
+type LogDirUsage struct {
+	Path string
+	// Err is the error the broker reported for the log dir, for example ErrKafkaStorageError
+	// if it is offline, in which case its sizes are unknown.
+	Err KError
+	// Size is the total size in bytes of the logs in the log dir, including FutureSize.
+	Size int64
+	// FutureSize is the size in bytes of the temporary logs of replicas being moved to the log
+	// dir by AlterReplicaLogDirs.
+	FutureSize int64
+	// Partitions are the sizes in bytes of the logs in the log dir, by topic and partition.
+	Partitions map[string]map[int32]int64
+}
+
+// TopicSize returns the size in bytes of the logs of the topic in the log dir.
+func (u *LogDirUsage) TopicSize(topic string) int64 {
+	var size int64
+	for _, s := range u.Partitions[topic] {
+		size += s
+	}
+	return size
+}
+
+// TopicSize returns the size in bytes of the logs of the topic on the broker.
+func (u *BrokerLogDirUsage) TopicSize(topic string) int64 {
+	var size int64
+	for _, dir := range u.LogDirs {
+		size += dir.TopicSize(topic)
+	}
+	return size
+}
+
+// TopicSize returns the size in bytes of the logs of the topic on all the brokers, replicas
+// included.
+func (r *LogDirUsageReport) TopicSize(topic string) int64 {
+	var size int64
+	for _, broker := range r.Brokers {
+		size += broker.TopicSize(topic)
+	}
+	return size
+}
+
+// BrokerIDs returns the IDs of the brokers of the report from the largest usage to the
+// smallest.
+func (r *LogDirUsageReport) BrokerIDs() []int32 {
+	ids := make([]int32, 0, len(r.Brokers))
+	for id := range r.Brokers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if r.Brokers[ids[i]].Size != r.Brokers[ids[j]].Size {
+			return r.Brokers[ids[i]].Size > r.Brokers[ids[j]].Size
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// newLogDirUsageReport aggregates the log dirs described by each broker.
+func newLogDirUsageReport(logDirs map[int32][]DescribeLogDirsResponseDirMetadata) *LogDirUsageReport {
+	report := &LogDirUsageReport{Brokers: make(map[int32]*BrokerLogDirUsage, len(logDirs))}
+	for id, dirs := range logDirs {
+		broker := &BrokerLogDirUsage{BrokerID: id, LogDirs: make(map[string]*LogDirUsage, len(dirs))}
+		for _, dir := range dirs {
+			usage := &LogDirUsage{
+				Path:       dir.Path,
+				Err:        dir.ErrorCode,
+				Partitions: make(map[string]map[int32]int64, len(dir.Topics)),
+			}
+			for _, topic := range dir.Topics {
+				partitions := make(map[int32]int64, len(topic.Partitions))
+				for _, partition := range topic.Partitions {
+					partitions[partition.PartitionID] = partition.Size
+					usage.Size += partition.Size
+					if partition.IsTemporary {
+						usage.FutureSize += partition.Size
+					}
+				}
+				usage.Partitions[topic.Topic] = partitions
+			}
+			broker.LogDirs[dir.Path] = usage
+			broker.Size += usage.Size
+		}
+		report.Brokers[id] = broker
+		report.Size += broker.Size
+	}
+	return report
+}
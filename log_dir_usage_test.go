@@ -0,0 +1,57 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogDirUsageReport(t *testing.T) {
+	report := newLogDirUsageReport(map[int32][]DescribeLogDirsResponseDirMetadata{
+		1: {
+			{Path: "/data1", Topics: []DescribeLogDirsResponseTopic{
+				{Topic: "my_topic", Partitions: []DescribeLogDirsResponsePartition{
+					{PartitionID: 0, Size: 100},
+					{PartitionID: 1, Size: 200},
+				}},
+			}},
+			{Path: "/data2", Topics: []DescribeLogDirsResponseTopic{
+				{Topic: "my_topic", Partitions: []DescribeLogDirsResponsePartition{
+					{PartitionID: 1, Size: 50, IsTemporary: true},
+				}},
+			}},
+			{Path: "/data3", ErrorCode: ErrKafkaStorageError},
+		},
+		2: {
+			{Path: "/data1", Topics: []DescribeLogDirsResponseTopic{
+				{Topic: "my_topic", Partitions: []DescribeLogDirsResponsePartition{
+					{PartitionID: 0, Size: 100},
+				}},
+				{Topic: "other_topic", Partitions: []DescribeLogDirsResponsePartition{
+					{PartitionID: 0, Size: 1000},
+				}},
+			}},
+		},
+	})
+
+	if report.Size != 1450 {
+		t.Errorf("Expected a total size of 1450, got %d", report.Size)
+	}
+	if size := report.Brokers[1].Size; size != 350 {
+		t.Errorf("Expected broker 1 to use 350 bytes, got %d", size)
+	}
+	if dir := report.Brokers[1].LogDirs["/data2"]; dir.Size != 50 || dir.FutureSize != 50 {
+		t.Errorf("Expected /data2 of broker 1 to hold a 50 bytes future log, got %+v", dir)
+	}
+	if dir := report.Brokers[1].LogDirs["/data3"]; dir.Err != ErrKafkaStorageError || dir.Size != 0 {
+		t.Errorf("Expected /data3 of broker 1 to be offline, got %+v", dir)
+	}
+	if size := report.TopicSize("my_topic"); size != 450 {
+		t.Errorf("Expected my_topic to use 450 bytes, got %d", size)
+	}
+	if size := report.Brokers[2].TopicSize("my_topic"); size != 100 {
+		t.Errorf("Expected my_topic to use 100 bytes on broker 2, got %d", size)
+	}
+	if ids := report.BrokerIDs(); !reflect.DeepEqual(ids, []int32{2, 1}) {
+		t.Errorf("Expected brokers by decreasing usage, got %v", ids)
+	}
+}
@@ -7,11 +7,25 @@ type MetadataRequest struct {
 	Topics []string
 	// AllowAutoTopicCreation contains a If this is true, the broker may auto-create topics that we requested which do not already exist, if it is configured to do so.
 	AllowAutoTopicCreation bool
+	// IncludeClusterAuthorizedOperations contains whether to include cluster authorized operations, from version 8 to 10.
+	IncludeClusterAuthorizedOperations bool
+	// IncludeTopicAuthorizedOperations contains whether to include topic authorized operations, from version 8.
+	IncludeTopicAuthorizedOperations bool
 }
 
 func NewMetadataRequest(version KafkaVersion, topics []string) *MetadataRequest {
 	m := &MetadataRequest{Topics: topics}
-	if version.IsAtLeast(V2_1_0_0) {
+	if version.IsAtLeast(V3_1_0_0) {
+		m.Version = 12
+	} else if version.IsAtLeast(V3_0_0_0) {
+		m.Version = 11
+	} else if version.IsAtLeast(V2_8_0_0) {
+		m.Version = 10
+	} else if version.IsAtLeast(V2_4_0_0) {
+		m.Version = 9
+	} else if version.IsAtLeast(V2_3_0_0) {
+		m.Version = 8
+	} else if version.IsAtLeast(V2_1_0_0) {
 		m.Version = 7
 	} else if version.IsAtLeast(V2_0_0_0) {
 		m.Version = 6
@@ -27,7 +41,25 @@ func (r *MetadataRequest) encode(pe packetEncoder) (err error) {
 	if r.Version < 0 || r.Version > 12 {
 		return PacketEncodingError{"invalid or unsupported MetadataRequest version field"}
 	}
-	if r.Version == 0 || len(r.Topics) > 0 {
+	if r.Version >= 9 {
+		if len(r.Topics) > 0 {
+			pe.putCompactArrayLength(len(r.Topics))
+			for _, topic := range r.Topics {
+				if r.Version >= 10 {
+					// topics are requested by name, leaving their ID zero
+					if err := putUuid(pe, Uuid{}); err != nil {
+						return err
+					}
+				}
+				if err := pe.putCompactString(topic); err != nil {
+					return err
+				}
+				pe.putEmptyTaggedFieldArray()
+			}
+		} else {
+			pe.putCompactArrayLength(-1)
+		}
+	} else if r.Version == 0 || len(r.Topics) > 0 {
 		err := pe.putArrayLength(len(r.Topics))
 		if err != nil {
 			return err
@@ -47,20 +79,59 @@ func (r *MetadataRequest) encode(pe packetEncoder) (err error) {
 		pe.putBool(r.AllowAutoTopicCreation)
 	}
 
+	if r.Version >= 8 && r.Version <= 10 {
+		pe.putBool(r.IncludeClusterAuthorizedOperations)
+	}
+	if r.Version >= 8 {
+		pe.putBool(r.IncludeTopicAuthorizedOperations)
+	}
+
+	if r.Version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
 func (r *MetadataRequest) decode(pd packetDecoder, version int16) (err error) {
 	r.Version = version
-	size, err := pd.getInt32()
+	var size int
+	if r.Version >= 9 {
+		size, err = pd.getCompactArrayLength()
+	} else {
+		size, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
 	if size > 0 {
 		r.Topics = make([]string, size)
 		for i := range r.Topics {
-			topic, err := pd.getString()
-			if err != nil {
+			var topic string
+			if r.Version >= 10 {
+				if _, err := getUuid(pd); err != nil {
+					return err
+				}
+			}
+			if r.Version >= 12 {
+				var name *string
+				if name, err = pd.getCompactNullableString(); err != nil {
+					return err
+				}
+				if name != nil {
+					topic = *name
+				}
+				if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+					return err
+				}
+			} else if r.Version >= 9 {
+				if topic, err = pd.getCompactString(); err != nil {
+					return err
+				}
+				if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+					return err
+				}
+			} else if topic, err = pd.getString(); err != nil {
 				return err
 			}
 			r.Topics[i] = topic
@@ -73,6 +144,23 @@ func (r *MetadataRequest) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
+	if r.Version >= 8 && r.Version <= 10 {
+		if r.IncludeClusterAuthorizedOperations, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 8 {
+		if r.IncludeTopicAuthorizedOperations, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -85,6 +173,9 @@ func (r *MetadataRequest) version() int16 {
 }
 
 func (r *MetadataRequest) headerVersion() int16 {
+	if r.Version >= 9 {
+		return 2
+	}
 	return 1
 }
 
@@ -102,6 +193,16 @@ func (r *MetadataRequest) requiredVersion() KafkaVersion {
 		return V2_0_0_0
 	case 7:
 		return V2_1_0_0
+	case 8:
+		return V2_3_0_0
+	case 9:
+		return V2_4_0_0
+	case 10:
+		return V2_8_0_0
+	case 11:
+		return V3_0_0_0
+	case 12:
+		return V3_1_0_0
 	default:
 		return MinVersion
 	}
@@ -60,6 +60,43 @@ var (
 	metadataRequestNoTopicsV5     = append(metadataRequestNoTopicsV1, byte(0))
 	metadataRequestAutoCreateV5   = append(metadataRequestOneTopicV3, byte(1))
 	metadataRequestNoAutoCreateV5 = append(metadataRequestOneTopicV3, byte(0))
+
+	// The v8 metadata request has additional fields for including the authorized operations
+	// of the cluster and of the topics in the response.
+
+	metadataRequestAuthorizedOperationsV8 = append(metadataRequestNoTopicsV1, 0, 1, 1)
+
+	// The v9 metadata request is the first flexible version, with compact arrays and strings
+	// and tagged fields.
+
+	metadataRequestNoTopicsV9 = []byte{
+		0x00,
+		0x00, 0x01, 0x01,
+		0x00,
+	}
+
+	metadataRequestOneTopicV9 = []byte{
+		0x02,
+		0x07, 't', 'o', 'p', 'i', 'c', '1', 0x00,
+		0x01, 0x00, 0x00,
+		0x00,
+	}
+
+	metadataRequestOneTopicV10 = []byte{
+		0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x07, 't', 'o', 'p', 'i', 'c', '1', 0x00,
+		0x01, 0x01, 0x01,
+		0x00,
+	}
+
+	metadataRequestOneTopicV11 = []byte{
+		0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x07, 't', 'o', 'p', 'i', 'c', '1', 0x00,
+		0x01, 0x01,
+		0x00,
+	}
 )
 
 func TestMetadataRequestV0(t *testing.T) {
@@ -136,3 +173,49 @@ func TestMetadataRequestV5(t *testing.T) {
 	request.AllowAutoTopicCreation = false
 	testRequest(t, "one topic", request, metadataRequestNoAutoCreateV5)
 }
+
+func TestMetadataRequestV8(t *testing.T) {
+	request := &MetadataRequest{
+		Version:                            8,
+		IncludeClusterAuthorizedOperations: true,
+		IncludeTopicAuthorizedOperations:   true,
+	}
+	testRequest(t, "authorized operations", request, metadataRequestAuthorizedOperationsV8)
+}
+
+func TestMetadataRequestV9(t *testing.T) {
+	request := &MetadataRequest{
+		Version:                            9,
+		IncludeClusterAuthorizedOperations: true,
+		IncludeTopicAuthorizedOperations:   true,
+	}
+	testRequest(t, "no topics", request, metadataRequestNoTopicsV9)
+
+	request = &MetadataRequest{Version: 9, Topics: []string{"topic1"}, AllowAutoTopicCreation: true}
+	testRequest(t, "one topic", request, metadataRequestOneTopicV9)
+}
+
+func TestMetadataRequestV10(t *testing.T) {
+	request := &MetadataRequest{
+		Version:                            10,
+		Topics:                             []string{"topic1"},
+		AllowAutoTopicCreation:             true,
+		IncludeClusterAuthorizedOperations: true,
+		IncludeTopicAuthorizedOperations:   true,
+	}
+	testRequest(t, "one topic", request, metadataRequestOneTopicV10)
+}
+
+func TestMetadataRequestV11(t *testing.T) {
+	// the cluster authorized operations are no longer requested from version 11
+	request := &MetadataRequest{
+		Version:                          11,
+		Topics:                           []string{"topic1"},
+		AllowAutoTopicCreation:           true,
+		IncludeTopicAuthorizedOperations: true,
+	}
+	testRequest(t, "one topic", request, metadataRequestOneTopicV11)
+
+	request.Version = 12
+	testRequest(t, "one topic v12", request, metadataRequestOneTopicV11)
+}
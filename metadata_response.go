@@ -42,16 +42,22 @@ func (p *PartitionMetadata) decode(pd packetDecoder, version int16) (err error)
 		}
 	}
 
-	if p.Replicas, err = pd.getInt32Array(); err != nil {
+	if p.Replicas, err = getMetadataInt32Array(pd, p.Version); err != nil {
 		return err
 	}
 
-	if p.Isr, err = pd.getInt32Array(); err != nil {
+	if p.Isr, err = getMetadataInt32Array(pd, p.Version); err != nil {
 		return err
 	}
 
 	if p.Version >= 5 {
-		if p.OfflineReplicas, err = pd.getInt32Array(); err != nil {
+		if p.OfflineReplicas, err = getMetadataInt32Array(pd, p.Version); err != nil {
+			return err
+		}
+	}
+
+	if p.Version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
 			return err
 		}
 	}
@@ -71,20 +77,24 @@ func (p *PartitionMetadata) encode(pe packetEncoder, version int16) (err error)
 		pe.putInt32(p.LeaderEpoch)
 	}
 
-	if err := pe.putInt32Array(p.Replicas); err != nil {
+	if err := putMetadataInt32Array(pe, p.Replicas, p.Version); err != nil {
 		return err
 	}
 
-	if err := pe.putInt32Array(p.Isr); err != nil {
+	if err := putMetadataInt32Array(pe, p.Isr, p.Version); err != nil {
 		return err
 	}
 
 	if p.Version >= 5 {
-		if err := pe.putInt32Array(p.OfflineReplicas); err != nil {
+		if err := putMetadataInt32Array(pe, p.OfflineReplicas, p.Version); err != nil {
 			return err
 		}
 	}
 
+	if p.Version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
@@ -96,10 +106,15 @@ type TopicMetadata struct {
 	Err KError
 	// Name contains the topic name.
 	Name string
+	// Uuid contains the topic ID, from version 10.
+	Uuid Uuid
 	// IsInternal contains a True if the topic is internal.
 	IsInternal bool
 	// Partitions contains each partition in the topic.
 	Partitions []*PartitionMetadata
+	// TopicAuthorizedOperations contains a 32-bit bitfield to represent authorized operations
+	// for this topic, from version 8.
+	TopicAuthorizedOperations int32
 }
 
 func (t *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
@@ -110,17 +125,35 @@ func (t *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 	}
 	t.Err = KError(tmp)
 
-	if t.Name, err = pd.getString(); err != nil {
+	if t.Version >= 12 {
+		var name *string
+		if name, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+		if name != nil {
+			t.Name = *name
+		}
+	} else if t.Version >= 9 {
+		if t.Name, err = pd.getCompactString(); err != nil {
+			return err
+		}
+	} else if t.Name, err = pd.getString(); err != nil {
 		return err
 	}
 
+	if t.Version >= 10 {
+		if t.Uuid, err = getUuid(pd); err != nil {
+			return err
+		}
+	}
+
 	if t.Version >= 1 {
 		if t.IsInternal, err = pd.getBool(); err != nil {
 			return err
 		}
 	}
 
-	if numPartitions, err := pd.getArrayLength(); err != nil {
+	if numPartitions, err := getMetadataArrayLength(pd, t.Version); err != nil {
 		return err
 	} else {
 		t.Partitions = make([]*PartitionMetadata, numPartitions)
@@ -133,6 +166,18 @@ func (t *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
+	if t.Version >= 8 {
+		if t.TopicAuthorizedOperations, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	if t.Version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -140,15 +185,26 @@ func (t *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 	t.Version = version
 	pe.putInt16(int16(t.Err))
 
-	if err := pe.putString(t.Name); err != nil {
+	if t.Version >= 9 {
+		err = pe.putCompactString(t.Name)
+	} else {
+		err = pe.putString(t.Name)
+	}
+	if err != nil {
 		return err
 	}
 
+	if t.Version >= 10 {
+		if err := putUuid(pe, t.Uuid); err != nil {
+			return err
+		}
+	}
+
 	if t.Version >= 1 {
 		pe.putBool(t.IsInternal)
 	}
 
-	if err := pe.putArrayLength(len(t.Partitions)); err != nil {
+	if err := putMetadataArrayLength(pe, len(t.Partitions), t.Version); err != nil {
 		return err
 	}
 	for _, block := range t.Partitions {
@@ -157,6 +213,14 @@ func (t *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 		}
 	}
 
+	if t.Version >= 8 {
+		pe.putInt32(t.TopicAuthorizedOperations)
+	}
+
+	if t.Version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
@@ -173,6 +237,9 @@ type MetadataResponse struct {
 	ControllerID int32
 	// Topics contains each topic in the response.
 	Topics []*TopicMetadata
+	// ClusterAuthorizedOperations contains a 32-bit bitfield to represent authorized operations
+	// for this cluster, from version 8 to 10.
+	ClusterAuthorizedOperations int32
 }
 
 func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
@@ -183,7 +250,7 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	n, err := pd.getArrayLength()
+	n, err := getMetadataArrayLength(pd, r.Version)
 	if err != nil {
 		return err
 	}
@@ -196,7 +263,11 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	if r.Version >= 2 {
+	if r.Version >= 9 {
+		if r.ClusterID, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+	} else if r.Version >= 2 {
 		if r.ClusterID, err = pd.getNullableString(); err != nil {
 			return err
 		}
@@ -208,7 +279,7 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	if numTopics, err := pd.getArrayLength(); err != nil {
+	if numTopics, err := getMetadataArrayLength(pd, r.Version); err != nil {
 		return err
 	} else {
 		r.Topics = make([]*TopicMetadata, numTopics)
@@ -221,6 +292,18 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
+	if r.Version >= 8 && r.Version <= 10 {
+		if r.ClusterAuthorizedOperations, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -229,7 +312,7 @@ func (r *MetadataResponse) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.ThrottleTimeMs)
 	}
 
-	if err := pe.putArrayLength(len(r.Brokers)); err != nil {
+	if err := putMetadataArrayLength(pe, len(r.Brokers), r.Version); err != nil {
 		return err
 	}
 	for _, broker := range r.Brokers {
@@ -239,7 +322,11 @@ func (r *MetadataResponse) encode(pe packetEncoder) (err error) {
 		}
 	}
 
-	if r.Version >= 2 {
+	if r.Version >= 9 {
+		if err := pe.putNullableCompactString(r.ClusterID); err != nil {
+			return err
+		}
+	} else if r.Version >= 2 {
 		if err := pe.putNullableString(r.ClusterID); err != nil {
 			return err
 		}
@@ -249,7 +336,7 @@ func (r *MetadataResponse) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.ControllerID)
 	}
 
-	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+	if err := putMetadataArrayLength(pe, len(r.Topics), r.Version); err != nil {
 		return err
 	}
 	for _, block := range r.Topics {
@@ -258,9 +345,53 @@ func (r *MetadataResponse) encode(pe packetEncoder) (err error) {
 		}
 	}
 
+	if r.Version >= 8 && r.Version <= 10 {
+		pe.putInt32(r.ClusterAuthorizedOperations)
+	}
+
+	if r.Version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
+// putMetadataArrayLength puts the length of an array of the metadata of the version, compact
+// from the flexible version 9.
+func putMetadataArrayLength(pe packetEncoder, n int, version int16) error {
+	if version >= 9 {
+		pe.putCompactArrayLength(n)
+		return nil
+	}
+	return pe.putArrayLength(n)
+}
+
+func getMetadataArrayLength(pd packetDecoder, version int16) (int, error) {
+	if version >= 9 {
+		return pd.getCompactArrayLength()
+	}
+	return pd.getArrayLength()
+}
+
+// putMetadataInt32Array puts the broker IDs of a partition, compact from the flexible version
+// 9, in which a nil array is put empty as the replica arrays are not nullable.
+func putMetadataInt32Array(pe packetEncoder, in []int32, version int16) error {
+	if version >= 9 {
+		if in == nil {
+			in = []int32{}
+		}
+		return pe.putCompactInt32Array(in)
+	}
+	return pe.putInt32Array(in)
+}
+
+func getMetadataInt32Array(pd packetDecoder, version int16) ([]int32, error) {
+	if version >= 9 {
+		return pd.getCompactInt32Array()
+	}
+	return pd.getInt32Array()
+}
+
 func (r *MetadataResponse) key() int16 {
 	return 3
 }
@@ -270,6 +401,9 @@ func (r *MetadataResponse) version() int16 {
 }
 
 func (r *MetadataResponse) headerVersion() int16 {
+	if r.Version >= 9 {
+		return 1
+	}
 	return 0
 }
 
@@ -287,6 +421,16 @@ func (r *MetadataResponse) requiredVersion() KafkaVersion {
 		return V2_0_0_0
 	case 7:
 		return V2_1_0_0
+	case 8:
+		return V2_3_0_0
+	case 9:
+		return V2_4_0_0
+	case 10:
+		return V2_8_0_0
+	case 11:
+		return V3_0_0_0
+	case 12:
+		return V3_1_0_0
 	default:
 		return MinVersion
 	}
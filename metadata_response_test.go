@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -149,6 +150,34 @@ func TestMetadataResponseWithBrokersV0(t *testing.T) {
 	}
 }
 
+func TestMetadataResponseWithIPv6BrokersV0(t *testing.T) {
+	response := MetadataResponse{}
+
+	testVersionDecodable(t, "IPv6 brokers, V0", &response, []byte{
+		0x00, 0x00, 0x00, 0x02,
+
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, ':', ':', '1',
+		0x00, 0x00, 0x23, 0x84,
+
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x05, '[', ':', ':', '2', ']',
+		0x00, 0x00, 0x23, 0x84,
+
+		0x00, 0x00, 0x00, 0x00,
+	}, 0)
+	if len(response.Brokers) != 2 {
+		t.Fatal("Decoding produced", len(response.Brokers), "brokers where there were two!")
+	}
+	// literal IPv6 hosts are bracketed once, whether advertised with brackets or not
+	if response.Brokers[0].addr != "[::1]:9092" {
+		t.Error("Decoding produced invalid broker 0 address", response.Brokers[0].addr)
+	}
+	if response.Brokers[1].addr != "[::2]:9092" {
+		t.Error("Decoding produced invalid broker 1 address", response.Brokers[1].addr)
+	}
+}
+
 func TestMetadataResponseWithTopicsV0(t *testing.T) {
 	response := MetadataResponse{}
 
@@ -297,3 +326,41 @@ func TestMetadataResponseWithOfflineReplicasV5(t *testing.T) {
 		t.Error("Decoding produced", len(response.Topics[0].Partitions[0].OfflineReplicas), "should have been 1!")
 	}
 }
+
+func TestMetadataResponseV9(t *testing.T) {
+	clusterID, rack := "clusterId", "rack0"
+	response := &MetadataResponse{
+		Version:                     9,
+		ThrottleTimeMs:              5,
+		ClusterID:                   &clusterID,
+		ControllerID:                1,
+		ClusterAuthorizedOperations: 0x0f,
+	}
+	response.AddBroker("localhost:9092", 1)
+	response.Brokers[0].rack = &rack
+	response.AddTopicPartition("foo", 0, 1, []int32{1, 2}, []int32{1}, []int32{2}, ErrNoError)
+	response.Topics[0].TopicAuthorizedOperations = 0x0ff8
+
+	testResponse(t, "one broker, one topic", response, nil)
+}
+
+func TestMetadataResponseV10(t *testing.T) {
+	clusterID := "clusterId"
+	for _, version := range []int16{10, 11, 12} {
+		response := &MetadataResponse{
+			Version:        version,
+			ThrottleTimeMs: 5,
+			ClusterID:      &clusterID,
+			ControllerID:   1,
+		}
+		if version == 10 {
+			response.ClusterAuthorizedOperations = 0x0f
+		}
+		response.AddBroker("localhost:9092", 1)
+		response.AddTopicPartition("foo", 0, 1, []int32{1, 2}, []int32{1}, []int32{2}, ErrNoError)
+		response.Topics[0].Uuid = Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+		response.Topics[0].TopicAuthorizedOperations = 0x0ff8
+
+		testResponse(t, fmt.Sprintf("one broker, one topic v%d", version), response, nil)
+	}
+}
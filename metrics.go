@@ -35,6 +35,10 @@ func getMetricNameForTopic(name string, topic string) string {
 	return fmt.Sprintf(name+"-for-topic-%s", strings.Replace(topic, ".", "_", -1))
 }
 
+func getMetricNameForPartition(name string, topic string, partition int32) string {
+	return fmt.Sprintf("%s-partition-%d", getMetricNameForTopic(name, topic), partition)
+}
+
 func getOrRegisterTopicMeter(name string, topic string, r metrics.Registry) metrics.Meter {
 	return metrics.GetOrRegisterMeter(getMetricNameForTopic(name, topic), r)
 }
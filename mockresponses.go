@@ -69,31 +69,66 @@ func (mc *MockSequence) For(reqBody versionedDecoder) (res encoderWithHeader) {
 }
 
 type MockListGroupsResponse struct {
-	groups map[string]string
-	t      TestReporter
+	groups     map[string]string
+	groupsData map[string]GroupData
+	t          TestReporter
 }
 
 func NewMockListGroupsResponse(t TestReporter) *MockListGroupsResponse {
 	return &MockListGroupsResponse{
-		groups: make(map[string]string),
-		t:      t,
+		groups:     make(map[string]string),
+		groupsData: make(map[string]GroupData),
+		t:          t,
 	}
 }
 
 func (m *MockListGroupsResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	request := reqBody.(*ListGroupsRequest)
-	_ = request
 	response := &ListGroupsResponse{
-		Groups: m.groups,
+		Version: request.Version,
+		Groups:  make(map[string]string),
+	}
+	if request.Version >= 4 {
+		response.GroupsData = make(map[string]GroupData)
+	}
+	for groupID, protocolType := range m.groups {
+		data := m.groupsData[groupID]
+		if !mockFilterMatches(request.StatesFilter, data.GroupState) || !mockFilterMatches(request.TypesFilter, data.GroupType) {
+			continue
+		}
+		response.Groups[groupID] = protocolType
+		if response.GroupsData != nil {
+			response.GroupsData[groupID] = data
+		}
 	}
 	return response
 }
 
+func mockFilterMatches(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if strings.EqualFold(f, value) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *MockListGroupsResponse) AddGroup(groupID, protocolType string) *MockListGroupsResponse {
 	m.groups[groupID] = protocolType
 	return m
 }
 
+// AddGroupWithState adds a group with its state and type, returned from version 4 and 5 of
+// ListGroupsResponse and used to filter the groups returned.
+func (m *MockListGroupsResponse) AddGroupWithState(groupID, protocolType, state, groupType string) *MockListGroupsResponse {
+	m.groups[groupID] = protocolType
+	m.groupsData[groupID] = GroupData{GroupState: state, GroupType: groupType}
+	return m
+}
+
 type MockDescribeGroupsResponse struct {
 	groups map[string]*GroupDescription
 	t      TestReporter
@@ -136,14 +171,16 @@ type MockMetadataResponse struct {
 	controllerID int32
 	leaders      map[string]map[int32]int32
 	brokers      map[string]int32
+	topicIDs     map[string]Uuid
 	t            TestReporter
 }
 
 func NewMockMetadataResponse(t TestReporter) *MockMetadataResponse {
 	return &MockMetadataResponse{
-		leaders: make(map[string]map[int32]int32),
-		brokers: make(map[string]int32),
-		t:       t,
+		leaders:  make(map[string]map[int32]int32),
+		brokers:  make(map[string]int32),
+		topicIDs: make(map[string]Uuid),
+		t:        t,
 	}
 }
 
@@ -167,6 +204,12 @@ func (mmr *MockMetadataResponse) SetController(brokerID int32) *MockMetadataResp
 	return mmr
 }
 
+// SetTopicID sets the ID of the topic, returned from version 10.
+func (mmr *MockMetadataResponse) SetTopicID(topic string, id Uuid) *MockMetadataResponse {
+	mmr.topicIDs[topic] = id
+	return mmr
+}
+
 func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	metadataRequest := reqBody.(*MetadataRequest)
 	metadataResponse := &MetadataResponse{
@@ -190,13 +233,16 @@ func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader
 				metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
 			}
 		}
-		return metadataResponse
-	}
-	for _, topic := range metadataRequest.Topics {
-		for partition, brokerID := range mmr.leaders[topic] {
-			metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
+	} else {
+		for _, topic := range metadataRequest.Topics {
+			for partition, brokerID := range mmr.leaders[topic] {
+				metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
+			}
 		}
 	}
+	for _, topic := range metadataResponse.Topics {
+		topic.Uuid = mmr.topicIDs[topic.Name]
+	}
 	return metadataResponse
 }
 
@@ -274,6 +320,7 @@ type MockFetchResponse struct {
 	messages       map[string]map[int32]map[int64]*mockMessage
 	messagesLock   *sync.RWMutex
 	highWaterMarks map[string]map[int32]int64
+	topicIDs       map[Uuid]string
 	t              TestReporter
 	batchSize      int
 }
@@ -283,11 +330,18 @@ func NewMockFetchResponse(t TestReporter, batchSize int) *MockFetchResponse {
 		messages:       make(map[string]map[int32]map[int64]*mockMessage),
 		messagesLock:   &sync.RWMutex{},
 		highWaterMarks: make(map[string]map[int32]int64),
+		topicIDs:       make(map[Uuid]string),
 		t:              t,
 		batchSize:      batchSize,
 	}
 }
 
+// SetTopicID sets the ID of the topic, by which fetch requests refer to it from version 13.
+func (mfr *MockFetchResponse) SetTopicID(topic string, id Uuid) *MockFetchResponse {
+	mfr.topicIDs[id] = topic
+	return mfr
+}
+
 func (mfr *MockFetchResponse) SetMessage(topic string, partition int32, offset int64, msg Encoder) *MockFetchResponse {
 	return mfr.SetMessageWithKey(topic, partition, offset, nil, msg)
 }
@@ -325,6 +379,13 @@ func (mfr *MockFetchResponse) For(reqBody versionedDecoder) encoderWithHeader {
 		Version: fetchRequest.Version,
 	}
 	for topic, partitions := range fetchRequest.blocks {
+		if id, ok := fetchRequest.topicIDs[topic]; ok {
+			// the requests of version 13 refer to the topics by ID
+			if name, ok := mfr.topicIDs[id]; ok {
+				topic = name
+			}
+			res.SetTopicID(topic, id)
+		}
 		for partition, block := range partitions {
 			initialOffset := block.fetchOffset
 			offset := initialOffset
@@ -765,8 +826,8 @@ func (mr *MockDeleteRecordsResponse) For(reqBody versionedDecoder) encoderWithHe
 
 	for topic, deleteRecordRequestTopic := range req.Topics {
 		partitions := make(map[int32]*DeleteRecordsResponsePartition)
-		for partition := range deleteRecordRequestTopic.PartitionOffsets {
-			partitions[partition] = &DeleteRecordsResponsePartition{Err: ErrNoError}
+		for partition, offset := range deleteRecordRequestTopic.PartitionOffsets {
+			partitions[partition] = &DeleteRecordsResponsePartition{LowWatermark: offset, Err: ErrNoError}
 		}
 		res.Topics[topic] = &DeleteRecordsResponseTopic{Partitions: partitions}
 	}
@@ -1305,7 +1366,9 @@ func NewMockSyncGroupResponse(t TestReporter) *MockSyncGroupResponse {
 }
 
 func (m *MockSyncGroupResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*SyncGroupRequest)
 	resp := &SyncGroupResponse{
+		Version:          req.Version,
 		Err:              m.Err,
 		MemberAssignment: m.MemberAssignment,
 	}
@@ -1337,7 +1400,11 @@ func NewMockHeartbeatResponse(t TestReporter) *MockHeartbeatResponse {
 }
 
 func (m *MockHeartbeatResponse) For(reqBody versionedDecoder) encoderWithHeader {
-	resp := &HeartbeatResponse{}
+	req := reqBody.(*HeartbeatRequest)
+	resp := &HeartbeatResponse{
+		Version: req.Version,
+		Err:     m.Err,
+	}
 	return resp
 }
 
@@ -1389,8 +1456,11 @@ func (m *MockDescribeLogDirsResponse) For(reqBody versionedDecoder) encoderWithH
 }
 
 type MockApiVersionsResponse struct {
-	t       TestReporter
-	apiKeys []ApiVersionsResponseKey
+	t                      TestReporter
+	apiKeys                []ApiVersionsResponseKey
+	supportedFeatures      []SupportedFeatureKey
+	finalizedFeaturesEpoch int64
+	finalizedFeatures      []FinalizedFeatureKey
 }
 
 func NewMockApiVersionsResponse(t TestReporter) *MockApiVersionsResponse {
@@ -1405,9 +1475,10 @@ func NewMockApiVersionsResponse(t TestReporter) *MockApiVersionsResponse {
 			{
 				ApiKey:     1,
 				MinVersion: 7,
-				MaxVersion: 11,
+				MaxVersion: 13,
 			},
 		},
+		finalizedFeaturesEpoch: -1,
 	}
 }
 
@@ -1416,11 +1487,27 @@ func (m *MockApiVersionsResponse) SetApiKeys(apiKeys []ApiVersionsResponseKey) *
 	return m
 }
 
+func (m *MockApiVersionsResponse) SetSupportedFeatures(features []SupportedFeatureKey) *MockApiVersionsResponse {
+	m.supportedFeatures = features
+	return m
+}
+
+func (m *MockApiVersionsResponse) SetFinalizedFeatures(epoch int64, features []FinalizedFeatureKey) *MockApiVersionsResponse {
+	m.finalizedFeaturesEpoch = epoch
+	m.finalizedFeatures = features
+	return m
+}
+
 func (m *MockApiVersionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*ApiVersionsRequest)
 	res := &ApiVersionsResponse{
 		Version: req.Version,
 		ApiKeys: m.apiKeys,
 	}
+	if req.Version >= 3 {
+		res.SupportedFeatures = m.supportedFeatures
+		res.FinalizedFeaturesEpoch = m.finalizedFeaturesEpoch
+		res.FinalizedFeatures = m.finalizedFeatures
+	}
 	return res
 }
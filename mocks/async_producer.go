@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"sync"
 
@@ -25,6 +26,7 @@ type AsyncProducer struct {
 	txnLock         sync.Mutex
 	txnStatus       sarama.ProducerTxnStatusFlag
 	lastOffset      int64
+	maxMessageBytes int
 	*TopicConfig
 }
 
@@ -48,6 +50,7 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 		errors:          make(chan *sarama.ProducerError, config.ChannelBufferSize),
 		isTransactional: config.Producer.Transaction.ID != "",
 		txnStatus:       sarama.ProducerTxnFlagReady,
+		maxMessageBytes: config.Producer.MaxMessageBytes,
 		TopicConfig:     NewTopicConfig(),
 	}
 
@@ -138,6 +141,18 @@ func (mp *AsyncProducer) Close() error {
 	return nil
 }
 
+// CloseContext corresponds with the CloseContext method of sarama's Producer implementation.
+// It behaves like Close, but stops waiting for the mock producer to finish once ctx is done.
+func (mp *AsyncProducer) CloseContext(ctx context.Context) error {
+	mp.AsyncClose()
+	select {
+	case <-mp.closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Input corresponds with the Input method of sarama's Producer implementation.
 // You have to set expectations on the mock producer before writing messages to the Input
 // channel, so it knows how to handle them. If there is no more remaining expectations and
@@ -157,6 +172,13 @@ func (mp *AsyncProducer) Errors() <-chan *sarama.ProducerError {
 	return mp.errors
 }
 
+// CheckMessage corresponds with the CheckMessage method of sarama's Producer implementation.
+// It only checks the size of the message against the Producer.MaxMessageBytes of the config
+// the mock was created with.
+func (mp *AsyncProducer) CheckMessage(msg *sarama.ProducerMessage) error {
+	return checkMessageSize(msg, mp.maxMessageBytes)
+}
+
 func (mp *AsyncProducer) IsTransactional() bool {
 	return mp.isTransactional
 }
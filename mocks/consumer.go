@@ -1,8 +1,11 @@
 package mocks
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 )
@@ -67,6 +70,48 @@ func (c *Consumer) ConsumePartition(topic string, partition int32, offset int64)
 	return pc, nil
 }
 
+// ConsumePartitionTail implements the ConsumePartitionTail method from the sarama.Consumer
+// interface. As the mock doesn't know the offsets of the partition, the expectation has to
+// be set using ExpectConsumePartition with AnyOffset.
+func (c *Consumer) ConsumePartitionTail(topic string, partition int32, n int64) (sarama.PartitionConsumer, error) {
+	return c.ConsumePartition(topic, partition, AnyOffset)
+}
+
+// ConsumeBetween implements the ConsumeBetween method from the sarama.Consumer interface.
+// It consumes the partitions of the topic which have expectations, which have to be set
+// using ExpectConsumePartition with AnyOffset, calling fn for the messages yielded so far
+// whose Timestamp is between start and end.
+func (c *Consumer) ConsumeBetween(ctx context.Context, topic string, start, end time.Time, fn func(*sarama.ConsumerMessage) error) error {
+	c.l.Lock()
+	partitions := make([]int32, 0, len(c.partitionConsumers[topic]))
+	for partition := range c.partitionConsumers[topic] {
+		partitions = append(partitions, partition)
+	}
+	c.l.Unlock()
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	for _, partition := range partitions {
+		pc, err := c.ConsumePartition(topic, partition, AnyOffset)
+		if err != nil {
+			return err
+		}
+		messages := pc.Messages()
+		for len(messages) > 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			msg := <-messages
+			if msg.Timestamp.Before(start) || !msg.Timestamp.Before(end) {
+				continue
+			}
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Topics returns a list of topics, as registered with SetTopicMetadata
 func (c *Consumer) Topics() ([]string, error) {
 	c.l.Lock()
@@ -227,8 +272,11 @@ func (c *Consumer) ExpectConsumePartition(topic string, partition int32, offset
 			partition:           partition,
 			offset:              offset,
 			messages:            make(chan *sarama.ConsumerMessage, c.config.ChannelBufferSize),
+			batches:             make(chan []*sarama.ConsumerMessage, c.config.ChannelBufferSize),
 			suppressedMessages:  make(chan *sarama.ConsumerMessage, c.config.ChannelBufferSize),
 			errors:              make(chan *sarama.ConsumerError, c.config.ChannelBufferSize),
+			caughtUp:            make(chan struct{}),
+			eof:                 make(chan *sarama.PartitionEOF, c.config.ChannelBufferSize),
 		}
 	}
 
@@ -252,6 +300,7 @@ type PartitionConsumer struct {
 	partition                     int32
 	offset                        int64
 	messages                      chan *sarama.ConsumerMessage
+	batches                       chan []*sarama.ConsumerMessage
 	suppressedMessages            chan *sarama.ConsumerMessage
 	suppressedHighWaterMarkOffset int64
 	errors                        chan *sarama.ConsumerError
@@ -260,6 +309,9 @@ type PartitionConsumer struct {
 	errorsShouldBeDrained         bool
 	messagesShouldBeDrained       bool
 	paused                        bool
+	caughtUp                      chan struct{}
+	caughtUpOnce                  sync.Once
+	eof                           chan *sarama.PartitionEOF
 }
 
 ///////////////////////////////////////////////////
@@ -271,7 +323,9 @@ func (pc *PartitionConsumer) AsyncClose() {
 	pc.singleClose.Do(func() {
 		close(pc.suppressedMessages)
 		close(pc.messages)
+		close(pc.batches)
 		close(pc.errors)
+		close(pc.eof)
 	})
 }
 
@@ -320,6 +374,22 @@ func (pc *PartitionConsumer) Close() error {
 		}
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range pc.batches {
+			// drain
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range pc.eof {
+			// drain
+		}
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -342,10 +412,39 @@ func (pc *PartitionConsumer) Messages() <-chan *sarama.ConsumerMessage {
 	return pc.messages
 }
 
+// Batches implements the Batches method from the sarama.PartitionConsumer interface.
+func (pc *PartitionConsumer) Batches() <-chan []*sarama.ConsumerMessage {
+	return pc.batches
+}
+
 func (pc *PartitionConsumer) HighWaterMarkOffset() int64 {
 	return atomic.LoadInt64(&pc.highWaterMarkOffset)
 }
 
+// LastStableOffset implements the LastStableOffset method from the sarama.PartitionConsumer interface.
+// The mock doesn't produce transactions, so it is the same as the high water mark offset.
+func (pc *PartitionConsumer) LastStableOffset() int64 {
+	return pc.HighWaterMarkOffset()
+}
+
+// Lag implements the Lag method from the sarama.PartitionConsumer interface.
+// It is the number of yielded messages which haven't been read from the Messages channel yet.
+func (pc *PartitionConsumer) Lag() int64 {
+	return int64(len(pc.messages))
+}
+
+// CaughtUp implements the CaughtUp method from the sarama.PartitionConsumer interface.
+// The channel is closed by YieldCaughtUp.
+func (pc *PartitionConsumer) CaughtUp() <-chan struct{} {
+	return pc.caughtUp
+}
+
+// EOF implements the EOF method from the sarama.PartitionConsumer interface.
+// Events are sent on the channel by YieldEOF.
+func (pc *PartitionConsumer) EOF() <-chan *sarama.PartitionEOF {
+	return pc.eof
+}
+
 // Pause implements the Pause method from the sarama.PartitionConsumer interface.
 func (pc *PartitionConsumer) Pause() {
 	pc.l.Lock()
@@ -405,6 +504,45 @@ func (pc *PartitionConsumer) YieldMessage(msg *sarama.ConsumerMessage) *Partitio
 	return pc
 }
 
+// YieldBatch will yield a batch of messages on the Batches channel of this partition
+// consumer when it is consumed, assigning them consecutive offsets. Unlike YieldMessage,
+// batches are yielded even if the partition consumer is paused.
+func (pc *PartitionConsumer) YieldBatch(msgs ...*sarama.ConsumerMessage) *PartitionConsumer {
+	pc.l.Lock()
+	defer pc.l.Unlock()
+
+	for _, msg := range msgs {
+		msg.Topic = pc.topic
+		msg.Partition = pc.partition
+		msg.Offset = atomic.AddInt64(&pc.highWaterMarkOffset, 1) - 1
+	}
+	pc.batches <- msgs
+
+	return pc
+}
+
+// YieldCaughtUp will close the CaughtUp channel of this partition consumer, signalling
+// that the messages yielded so far reach the end of the partition.
+func (pc *PartitionConsumer) YieldCaughtUp() *PartitionConsumer {
+	pc.caughtUpOnce.Do(func() {
+		close(pc.caughtUp)
+	})
+
+	return pc
+}
+
+// YieldEOF will yield a PartitionEOF event on the EOF channel of this partition consumer,
+// signalling that the messages yielded so far reach the end of the partition.
+func (pc *PartitionConsumer) YieldEOF() *PartitionConsumer {
+	pc.eof <- &sarama.PartitionEOF{
+		Topic:     pc.topic,
+		Partition: pc.partition,
+		Offset:    atomic.LoadInt64(&pc.highWaterMarkOffset),
+	}
+
+	return pc
+}
+
 // YieldError will yield an error on the Errors channel of this partition consumer
 // when it is consumed. By default, the mock consumer will not verify whether this error was
 // consumed from the Errors channel, because there are legitimate reasons for this
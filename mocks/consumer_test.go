@@ -1,10 +1,12 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Shopify/sarama"
 )
@@ -66,6 +68,126 @@ func TestConsumerHandlesExpectations(t *testing.T) {
 	}
 }
 
+func TestConsumerHandlesBatchExpectations(t *testing.T) {
+	consumer := NewConsumer(t, NewTestConfig())
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	consumer.ExpectConsumePartition("test", 0, sarama.OffsetOldest).YieldBatch(
+		&sarama.ConsumerMessage{Value: []byte("hello")},
+		&sarama.ConsumerMessage{Value: []byte("world")},
+	)
+
+	pc, err := consumer.ConsumePartition("test", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := <-pc.Batches()
+	if len(batch) != 2 || string(batch[1].Value) != "world" || batch[1].Offset != 1 || batch[1].Topic != "test" {
+		t.Error("Batch was not as expected:", batch)
+	}
+}
+
+func TestConsumerHandlesCaughtUpExpectations(t *testing.T) {
+	consumer := NewConsumer(t, NewTestConfig())
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	consumer.ExpectConsumePartition("test", 0, sarama.OffsetOldest).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")}).
+		YieldCaughtUp()
+
+	pc, err := consumer.ConsumePartition("test", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag := pc.Lag(); lag != 1 {
+		t.Error("Expected a lag of 1 message, got", lag)
+	}
+	<-pc.Messages()
+	<-pc.CaughtUp()
+	if lag := pc.Lag(); lag != 0 {
+		t.Error("Expected no lag, got", lag)
+	}
+}
+
+func TestConsumerHandlesEOFExpectations(t *testing.T) {
+	consumer := NewConsumer(t, NewTestConfig())
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	consumer.ExpectConsumePartition("test", 0, sarama.OffsetOldest).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")}).
+		YieldEOF()
+
+	pc, err := consumer.ConsumePartition("test", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-pc.Messages()
+	if eof := <-pc.EOF(); eof.Topic != "test" || eof.Partition != 0 || eof.Offset != 1 {
+		t.Error("EOF was not as expected:", eof)
+	}
+}
+
+func TestConsumerHandlesBetweenExpectations(t *testing.T) {
+	consumer := NewConsumer(t, NewTestConfig())
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	start, end := time.Unix(1000, 0), time.Unix(2000, 0)
+	consumer.ExpectConsumePartition("test", 0, AnyOffset).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("before"), Timestamp: start.Add(-time.Second)}).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello"), Timestamp: start})
+	consumer.ExpectConsumePartition("test", 1, AnyOffset).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("world"), Timestamp: end.Add(-time.Second)}).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("after"), Timestamp: end})
+
+	var values []string
+	err := consumer.ConsumeBetween(context.Background(), "test", start, end, func(msg *sarama.ConsumerMessage) error {
+		values = append(values, string(msg.Value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != "hello" || values[1] != "world" {
+		t.Error("Messages were not as expected:", values)
+	}
+}
+
+func TestConsumerHandlesTailExpectations(t *testing.T) {
+	consumer := NewConsumer(t, NewTestConfig())
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	consumer.ExpectConsumePartition("test", 0, AnyOffset).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")})
+
+	pc, err := consumer.ConsumePartitionTail("test", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-pc.Messages(); string(msg.Value) != "hello" {
+		t.Error("Message was not as expected:", msg)
+	}
+}
+
 func TestConsumerHandlesExpectationsPausingResuming(t *testing.T) {
 	consumer := NewConsumer(t, NewTestConfig())
 	defer func() {
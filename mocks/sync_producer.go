@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/Shopify/sarama"
@@ -18,8 +19,9 @@ type SyncProducer struct {
 	lastOffset   int64
 
 	*TopicConfig
-	newPartitioner sarama.PartitionerConstructor
-	partitioners   map[string]sarama.Partitioner
+	newPartitioner  sarama.PartitionerConstructor
+	partitioners    map[string]sarama.Partitioner
+	maxMessageBytes int
 
 	isTransactional bool
 	txnLock         sync.Mutex
@@ -43,6 +45,7 @@ func NewSyncProducer(t ErrorReporter, config *sarama.Config) *SyncProducer {
 		TopicConfig:     NewTopicConfig(),
 		newPartitioner:  config.Producer.Partitioner,
 		partitioners:    make(map[string]sarama.Partitioner, 1),
+		maxMessageBytes: config.Producer.MaxMessageBytes,
 		isTransactional: config.Producer.Transaction.ID != "",
 		txnStatus:       sarama.ProducerTxnFlagReady,
 	}
@@ -108,25 +111,9 @@ func (sp *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
 		sp.expectations = sp.expectations[len(msgs):]
 
 		for i, expectation := range expectations {
-			topic := msgs[i].Topic
-			partition, err := sp.partitioner(topic).Partition(msgs[i], sp.partitions(topic))
-			if err != nil {
-				sp.t.Errorf("Partitioner returned an error: %s", err.Error())
+			if err := sp.handleExpectation(msgs[i], expectation); err != nil {
 				return err
 			}
-			msgs[i].Partition = partition
-			if expectation.CheckFunction != nil {
-				errCheck := expectation.CheckFunction(msgs[i])
-				if errCheck != nil {
-					sp.t.Errorf("Check function returned an error: %s", errCheck.Error())
-					return errCheck
-				}
-			}
-			if !errors.Is(expectation.Result, errProduceSuccess) {
-				return expectation.Result
-			}
-			sp.lastOffset++
-			msgs[i].Offset = sp.lastOffset
 		}
 		return nil
 	}
@@ -134,6 +121,77 @@ func (sp *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
 	return errOutOfExpectations
 }
 
+// SendMessagesWithResults corresponds with the SendMessagesWithResults method of sarama's
+// SyncProducer implementation. Unlike SendMessages, every message is handled even if an
+// earlier one fails, and the outcome of each is returned in order. If there are fewer
+// remaining expectations than messages, the mock producer will write an error to the
+// test state object.
+func (sp *SyncProducer) SendMessagesWithResults(msgs []*sarama.ProducerMessage) ([]sarama.ProduceResult, error) {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+
+	if len(sp.expectations) < len(msgs) {
+		sp.t.Errorf("Insufficient expectations set on this mock producer to handle the input messages.")
+		return nil, errOutOfExpectations
+	}
+
+	expectations := sp.expectations[0:len(msgs)]
+	sp.expectations = sp.expectations[len(msgs):]
+
+	results := make([]sarama.ProduceResult, len(msgs))
+	var errs sarama.ProducerErrors
+	for i, expectation := range expectations {
+		if err := sp.handleExpectation(msgs[i], expectation); err != nil {
+			results[i] = sarama.ProduceResult{Msg: msgs[i], Partition: -1, Offset: -1, Err: err}
+			errs = append(errs, &sarama.ProducerError{Msg: msgs[i], Err: err})
+			continue
+		}
+		results[i] = sarama.ProduceResult{Msg: msgs[i], Partition: msgs[i].Partition, Offset: msgs[i].Offset}
+	}
+
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// CheckMessage corresponds with the CheckMessage method of sarama's SyncProducer implementation.
+// It only checks the size of the message against the Producer.MaxMessageBytes of the config
+// the mock was created with.
+func (sp *SyncProducer) CheckMessage(msg *sarama.ProducerMessage) error {
+	return checkMessageSize(msg, sp.maxMessageBytes)
+}
+
+func checkMessageSize(msg *sarama.ProducerMessage, maxMessageBytes int) error {
+	if size := msg.ByteSize(2); size > maxMessageBytes {
+		return fmt.Errorf("%w: %d bytes exceeds Producer.MaxMessageBytes (%d)", sarama.ErrMessageSizeTooLarge, size, maxMessageBytes)
+	}
+	return nil
+}
+
+func (sp *SyncProducer) handleExpectation(msg *sarama.ProducerMessage, expectation *producerExpectation) error {
+	topic := msg.Topic
+	partition, err := sp.partitioner(topic).Partition(msg, sp.partitions(topic))
+	if err != nil {
+		sp.t.Errorf("Partitioner returned an error: %s", err.Error())
+		return err
+	}
+	msg.Partition = partition
+	if expectation.CheckFunction != nil {
+		errCheck := expectation.CheckFunction(msg)
+		if errCheck != nil {
+			sp.t.Errorf("Check function returned an error: %s", errCheck.Error())
+			return errCheck
+		}
+	}
+	if !errors.Is(expectation.Result, errProduceSuccess) {
+		return expectation.Result
+	}
+	sp.lastOffset++
+	msg.Offset = sp.lastOffset
+	return nil
+}
+
 func (sp *SyncProducer) partitioner(topic string) sarama.Partitioner {
 	partitioner := sp.partitioners[topic]
 	if partitioner == nil {
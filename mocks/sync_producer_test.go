@@ -274,6 +274,47 @@ func TestSyncProducerWithCheckerFunctionForSendMessagesWithoutError(t *testing.T
 	}
 }
 
+func TestSyncProducerSendMessagesWithResults(t *testing.T) {
+	trm := newTestReporterMock()
+
+	sp := NewSyncProducer(trm, nil).
+		ExpectSendMessageAndSucceed().
+		ExpectSendMessageAndFail(sarama.ErrOutOfBrokers).
+		ExpectSendMessageAndSucceed()
+
+	msgs := []*sarama.ProducerMessage{
+		{Topic: "test", Value: sarama.StringEncoder("a")},
+		{Topic: "test", Value: sarama.StringEncoder("b")},
+		{Topic: "test", Value: sarama.StringEncoder("c")},
+	}
+
+	results, err := sp.SendMessagesWithResults(msgs)
+	var pErrs sarama.ProducerErrors
+	if !errors.As(err, &pErrs) || len(pErrs) != 1 {
+		t.Errorf("Expected a single producer error, found: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, found %d", len(results))
+	}
+	if results[0].Err != nil || results[0].Offset != 1 {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if !errors.Is(results[1].Err, sarama.ErrOutOfBrokers) || results[1].Offset != -1 {
+		t.Errorf("Unexpected second result: %+v", results[1])
+	}
+	if results[2].Err != nil || results[2].Offset != 2 {
+		t.Errorf("Unexpected third result: %+v", results[2])
+	}
+
+	if err := sp.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected to not report any errors, found: %v", trm.errors)
+	}
+}
+
 func TestSyncProducerSendMessagesExpectationsMismatchTooFew(t *testing.T) {
 	trm := newTestReporterMock()
 
@@ -368,3 +409,22 @@ func TestSyncProducerInvalidConfiguration(t *testing.T) {
 		t.Errorf("Unexpected error: %s", trm.errors[0])
 	}
 }
+
+func TestSyncProducerCheckMessage(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.MaxMessageBytes = 100
+	sp := NewSyncProducer(t, config)
+	defer func() {
+		if err := sp.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err := sp.CheckMessage(&sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("small")}); err != nil {
+		t.Error(err)
+	}
+	err := sp.CheckMessage(&sarama.ProducerMessage{Topic: "test", Value: sarama.ByteEncoder(make([]byte, 200))})
+	if !errors.Is(err, sarama.ErrMessageSizeTooLarge) {
+		t.Errorf("expected ErrMessageSizeTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,350 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClusterError is an error of the consumer group of one of the clusters of a
+// MultiClusterConsumerGroup.
+type ClusterError struct {
+	Cluster string
+	Err     error
+}
+
+func (ce ClusterError) Error() string {
+	return fmt.Sprintf("kafka: error in cluster %s: %s", ce.Cluster, ce.Err)
+}
+
+func (ce ClusterError) Unwrap() error {
+	return ce.Err
+}
+
+// ClusterSession is implemented by the sessions a MultiClusterConsumerGroup passes to its
+// handler, telling which cluster the session, and so its claims, belong to.
+type ClusterSession interface {
+	ConsumerGroupSession
+
+	// Cluster returns the name of the cluster of the session.
+	Cluster() string
+}
+
+// ClusterHealth describes the consumer group of a cluster of a MultiClusterConsumerGroup.
+type ClusterHealth struct {
+	// Active is true while a session of the group is running on the cluster.
+	Active bool
+	// MemberID and GenerationID are the ones of the current or last session.
+	MemberID     string
+	GenerationID int32
+	// Claims are the partitions claimed by the current session.
+	Claims map[string][]int32
+	// Sessions is the number of sessions started on the cluster.
+	Sessions int
+	// SessionStarted is when the current or last session started.
+	SessionStarted time.Time
+	// LastError is the last error returned by Consume on the cluster or received from the
+	// Errors channel of its group, at LastErrorTime.
+	LastError     error
+	LastErrorTime time.Time
+}
+
+// MultiClusterConsumerGroup runs the same handler for the same group and topics on several
+// clusters, for active/active deployments or while migrating between clusters. Each cluster
+// has its own consumer group and sessions, which are managed by a single Consume call.
+type MultiClusterConsumerGroup struct {
+	// RetryBackoff is how long to wait before joining the group of a cluster again after
+	// Consume failed on it (defaults to Consumer.Group.Rebalance.Retry.Backoff, or 2s when
+	// created from existing groups).
+	RetryBackoff time.Duration
+
+	groups    map[string]ConsumerGroup
+	errors    chan error
+	closed    chan none
+	closeOnce sync.Once
+	forwarded sync.WaitGroup
+
+	lock   sync.Mutex
+	health map[string]*ClusterHealth
+}
+
+// NewMultiClusterConsumerGroup creates a consumer group for groupID on each of the given
+// clusters, which map cluster names to broker addresses, all using the same configuration.
+func NewMultiClusterConsumerGroup(clusters map[string][]string, groupID string, config *Config) (*MultiClusterConsumerGroup, error) {
+	if len(clusters) == 0 {
+		return nil, ConfigurationError("MultiClusterConsumerGroup requires at least one cluster")
+	}
+
+	groups := make(map[string]ConsumerGroup, len(clusters))
+	for name, addrs := range clusters {
+		group, err := NewConsumerGroup(addrs, groupID, config)
+		if err != nil {
+			for _, g := range groups {
+				_ = g.Close()
+			}
+			return nil, ClusterError{Cluster: name, Err: err}
+		}
+		groups[name] = group
+	}
+
+	m, err := NewMultiClusterConsumerGroupFromGroups(groups)
+	if err != nil {
+		return nil, err
+	}
+	m.RetryBackoff = config.Consumer.Group.Rebalance.Retry.Backoff
+	return m, nil
+}
+
+// NewMultiClusterConsumerGroupFromGroups creates a MultiClusterConsumerGroup from existing
+// consumer groups, mapped by cluster name. Closing it closes the groups.
+func NewMultiClusterConsumerGroupFromGroups(groups map[string]ConsumerGroup) (*MultiClusterConsumerGroup, error) {
+	if len(groups) == 0 {
+		return nil, ConfigurationError("MultiClusterConsumerGroup requires at least one cluster")
+	}
+
+	m := &MultiClusterConsumerGroup{
+		RetryBackoff: 2 * time.Second,
+		groups:       groups,
+		errors:       make(chan error, len(groups)),
+		closed:       make(chan none),
+		health:       make(map[string]*ClusterHealth, len(groups)),
+	}
+	for name, group := range groups {
+		m.health[name] = &ClusterHealth{}
+		m.forwarded.Add(1)
+		go m.forwardErrors(name, group)
+	}
+	go withRecover(func() {
+		m.forwarded.Wait()
+		close(m.errors)
+	})
+	return m, nil
+}
+
+// Clusters returns the names of the clusters, sorted.
+func (m *MultiClusterConsumerGroup) Clusters() []string {
+	names := make([]string, 0, len(m.groups))
+	for name := range m.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Group returns the consumer group of the given cluster, or nil if there is none, for example
+// to pause its partitions.
+func (m *MultiClusterConsumerGroup) Group(cluster string) ConsumerGroup {
+	return m.groups[cluster]
+}
+
+// Health returns the state of the group of each cluster.
+func (m *MultiClusterConsumerGroup) Health() map[string]ClusterHealth {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	health := make(map[string]ClusterHealth, len(m.health))
+	for name, h := range m.health {
+		health[name] = *h
+	}
+	return health
+}
+
+// Errors returns the errors of the groups of all the clusters, as ClusterError values. As for
+// ConsumerGroup.Errors, errors are only returned when Consumer.Return.Errors is enabled.
+func (m *MultiClusterConsumerGroup) Errors() <-chan error {
+	return m.errors
+}
+
+// Consume joins the group on every cluster and runs sessions with handler, the sessions passed
+// to it implementing ClusterSession. Unlike ConsumerGroup.Consume, it keeps joining the groups
+// again as their sessions end, waiting RetryBackoff after a failure, until ctx is cancelled,
+// in which case it returns nil, or until the groups are closed, in which case it returns
+// ErrClosedConsumerGroup. Consume must not be called concurrently.
+func (m *MultiClusterConsumerGroup) Consume(ctx context.Context, topics []string, handler ConsumerGroupHandler) error {
+	var wg sync.WaitGroup
+	for name, group := range m.groups {
+		wg.Add(1)
+		go func(name string, group ConsumerGroup) {
+			defer wg.Done()
+			m.consume(ctx, name, group, topics, handler)
+		}(name, group)
+	}
+	wg.Wait()
+
+	select {
+	case <-m.closed:
+		return ErrClosedConsumerGroup
+	default:
+		return nil
+	}
+}
+
+// consume runs the sessions of the group of a cluster until ctx is cancelled or the group can
+// no longer be used.
+func (m *MultiClusterConsumerGroup) consume(ctx context.Context, name string, group ConsumerGroup, topics []string, handler ConsumerGroupHandler) {
+	h := &clusterHandler{ConsumerGroupHandler: handler, cluster: name, parent: m}
+	for {
+		err := group.Consume(ctx, topics, h)
+		if errors.Is(err, ErrClosedConsumerGroup) {
+			return
+		}
+		if errors.Is(err, ErrFencedInstancedId) {
+			m.recordError(name, err)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+
+		Logger.Printf("consumergroup/multi-cluster/%s consume failed, retrying in %s: %v\n", name, m.RetryBackoff, err)
+		m.recordError(name, err)
+		timer := time.NewTimer(m.RetryBackoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-m.closed:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Close closes the groups of all the clusters.
+func (m *MultiClusterConsumerGroup) Close() error {
+	var errs []error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		for _, name := range m.Clusters() {
+			if err := m.groups[name].Close(); err != nil {
+				errs = append(errs, ClusterError{Cluster: name, Err: err})
+			}
+		}
+	})
+	return multiError(errs...)
+}
+
+func (m *MultiClusterConsumerGroup) forwardErrors(name string, group ConsumerGroup) {
+	defer m.forwarded.Done()
+	for err := range group.Errors() {
+		m.recordError(name, err)
+		select {
+		case m.errors <- ClusterError{Cluster: name, Err: err}:
+		case <-m.closed:
+		}
+	}
+}
+
+func (m *MultiClusterConsumerGroup) recordError(name string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	h := m.health[name]
+	h.LastError = err
+	h.LastErrorTime = time.Now()
+}
+
+// clusterHandler runs the handler of a MultiClusterConsumerGroup for the group of a cluster,
+// keeping track of its sessions.
+type clusterHandler struct {
+	ConsumerGroupHandler
+	cluster string
+	parent  *MultiClusterConsumerGroup
+
+	lock    sync.Mutex
+	session *clusterSession
+}
+
+type clusterSession struct {
+	ConsumerGroupSession
+	cluster string
+}
+
+func (s *clusterSession) Cluster() string {
+	return s.cluster
+}
+
+// wrap returns the ClusterSession of sess, the same one for the whole session.
+func (h *clusterHandler) wrap(sess ConsumerGroupSession) *clusterSession {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.session == nil || h.session.ConsumerGroupSession != sess {
+		h.session = &clusterSession{ConsumerGroupSession: sess, cluster: h.cluster}
+	}
+	return h.session
+}
+
+func (h *clusterHandler) Setup(sess ConsumerGroupSession) error {
+	h.parent.lock.Lock()
+	health := h.parent.health[h.cluster]
+	health.Active = true
+	health.MemberID = sess.MemberID()
+	health.GenerationID = sess.GenerationID()
+	health.Claims = sess.Claims()
+	health.Sessions++
+	health.SessionStarted = time.Now()
+	h.parent.lock.Unlock()
+
+	return h.ConsumerGroupHandler.Setup(h.wrap(sess))
+}
+
+func (h *clusterHandler) Cleanup(sess ConsumerGroupSession) error {
+	err := h.ConsumerGroupHandler.Cleanup(h.wrap(sess))
+
+	h.parent.lock.Lock()
+	health := h.parent.health[h.cluster]
+	health.Active = false
+	health.Claims = nil
+	h.parent.lock.Unlock()
+	return err
+}
+
+func (h *clusterHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	return h.ConsumerGroupHandler.ConsumeClaim(h.wrap(sess), claim)
+}
+
+// OnPartitionsAssigned implements ConsumerGroupRebalanceListener, forwarding the callback to
+// the handler if it implements it, and so for the other callbacks.
+func (h *clusterHandler) OnPartitionsAssigned(sess ConsumerGroupSession, partitions map[string][]int32) {
+	h.updateClaims(sess)
+	if listener, ok := h.ConsumerGroupHandler.(ConsumerGroupRebalanceListener); ok {
+		listener.OnPartitionsAssigned(h.wrap(sess), partitions)
+	}
+}
+
+func (h *clusterHandler) OnPartitionsRevoked(sess ConsumerGroupSession, partitions map[string][]int32) {
+	if listener, ok := h.ConsumerGroupHandler.(ConsumerGroupRebalanceListener); ok {
+		listener.OnPartitionsRevoked(h.wrap(sess), partitions)
+	}
+	h.updateClaims(sess)
+}
+
+func (h *clusterHandler) OnPartitionsLost(sess ConsumerGroupSession, partitions map[string][]int32) {
+	if listener, ok := h.ConsumerGroupHandler.(ConsumerGroupRebalanceListener); ok {
+		listener.OnPartitionsLost(h.wrap(sess), partitions)
+	}
+}
+
+// OnClaimStuck implements ConsumerGroupStuckClaimListener, forwarding the callback to the
+// handler if it implements it.
+func (h *clusterHandler) OnClaimStuck(sess ConsumerGroupSession, topic string, partition int32, stalled time.Duration) {
+	if listener, ok := h.ConsumerGroupHandler.(ConsumerGroupStuckClaimListener); ok {
+		listener.OnClaimStuck(h.wrap(sess), topic, partition, stalled)
+	}
+}
+
+// updateClaims records the claims of sess, which change within a session with the
+// cooperative rebalance protocol.
+func (h *clusterHandler) updateClaims(sess ConsumerGroupSession) {
+	h.parent.lock.Lock()
+	defer h.parent.lock.Unlock()
+	if health := h.parent.health[h.cluster]; health.Active {
+		health.Claims = sess.Claims()
+	}
+}
@@ -0,0 +1,119 @@
+package sarama
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newMultiClusterTestBroker(t *testing.T, value string) *MockBroker {
+	broker := NewMockBroker(t, 0)
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my-topic", 0, broker.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 1),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("my-member").
+			SetGenerationId(1),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": {0}},
+		}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, -1, "", ErrNoError).
+			SetError(ErrNoError),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t),
+		"FetchRequest":        NewMockFetchResponse(t, 1).SetMessage("my-topic", 0, 0, StringEncoder(value)),
+		"LeaveGroupRequest":   NewMockLeaveGroupResponse(t),
+	})
+	return broker
+}
+
+type clusterRecordingHandler struct {
+	drainingHandler
+	lock     sync.Mutex
+	received map[string]string
+	done     chan none
+}
+
+func (h *clusterRecordingHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		h.lock.Lock()
+		h.received[sess.(ClusterSession).Cluster()] = string(msg.Value)
+		if len(h.received) == 2 {
+			select {
+			case <-h.done:
+			default:
+				close(h.done)
+			}
+		}
+		h.lock.Unlock()
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func TestMultiClusterConsumerGroup(t *testing.T) {
+	east := newMultiClusterTestBroker(t, "from-east")
+	defer east.Close()
+	west := newMultiClusterTestBroker(t, "from-west")
+	defer west.Close()
+
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Offsets.Initial = OffsetOldest
+	group, err := NewMultiClusterConsumerGroup(map[string][]string{
+		"east": {east.Addr()},
+		"west": {west.Addr()},
+	}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clusters := group.Clusters(); len(clusters) != 2 || clusters[0] != "east" || clusters[1] != "west" {
+		t.Errorf("unexpected clusters %v", clusters)
+	}
+
+	handler := &clusterRecordingHandler{received: make(map[string]string), done: make(chan none)}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-handler.done:
+			// check the health while the sessions run
+			for name, health := range group.Health() {
+				if !health.Active || health.Sessions != 1 || health.MemberID != "my-member" || len(health.Claims["my-topic"]) != 1 {
+					t.Errorf("unexpected health of %s: %+v", name, health)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("timed out waiting for the messages of both clusters")
+		}
+		cancel()
+	}()
+	if err := group.Consume(ctx, []string{"my-topic"}, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	if handler.received["east"] != "from-east" || handler.received["west"] != "from-west" {
+		t.Errorf("expected the message of each cluster, got %v", handler.received)
+	}
+	for name, health := range group.Health() {
+		if health.Active || health.LastError != nil {
+			t.Errorf("unexpected health of %s once consuming stopped: %+v", name, health)
+		}
+	}
+
+	if err := group.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := group.Consume(context.Background(), []string{"my-topic"}, handler); err != ErrClosedConsumerGroup {
+		t.Errorf("expected ErrClosedConsumerGroup once closed, got %v", err)
+	}
+}
@@ -17,14 +17,27 @@ type OffsetManager interface {
 	// Close stops the OffsetManager from managing offsets. It is required to call
 	// this function before an OffsetManager object passes out of scope, as it
 	// will otherwise leak memory. You must call this after all the
-	// PartitionOffsetManagers are closed.
+	// PartitionOffsetManagers are closed. The asynchronous commits in flight stop
+	// retrying and are waited for, their callbacks being called before it returns.
 	Close() error
 
 	// Commit commits the offsets. This method can be used if AutoCommit.Enable is
 	// set to false.
 	Commit()
+
+	// CommitAsync commits the offsets in the background, retrying failed commits
+	// as configured by Consumer.Offsets.Retry, then calls the callback, if not nil,
+	// with the offsets that were committed and the error that prevented the others
+	// from being committed, if any. Once the manager is closed, the callback is
+	// called with ErrClosedOffsetManager right away.
+	CommitAsync(callback OffsetCommitCallback)
 }
 
+// OffsetCommitCallback is called once an asynchronous commit completes, with the
+// offsets that were committed by topic and partition, and the last error if some
+// could not be committed.
+type OffsetCommitCallback func(offsets map[string]map[int32]int64, err error)
+
 type offsetManager struct {
 	client          Client
 	conf            *Config
@@ -45,6 +58,11 @@ type offsetManager struct {
 	closeOnce sync.Once
 	closing   chan none
 	closed    chan none
+
+	// asyncCommits are the commits of CommitAsync in flight, waited for by Close so that no
+	// callback is called once it returned. asyncLock orders adding to them with closing.
+	asyncCommits sync.WaitGroup
+	asyncLock    sync.Mutex
 }
 
 // NewOffsetManagerFromClient creates a new OffsetManager from the given client.
@@ -109,11 +127,14 @@ func (om *offsetManager) ManagePartition(topic string, partition int32) (Partiti
 
 func (om *offsetManager) Close() error {
 	om.closeOnce.Do(func() {
-		// exit the mainLoop
+		// exit the mainLoop and stop the asynchronous commits from retrying
+		om.asyncLock.Lock()
 		close(om.closing)
+		om.asyncLock.Unlock()
 		if om.conf.Consumer.Offsets.AutoCommit.Enable {
 			<-om.closed
 		}
+		om.asyncCommits.Wait()
 
 		// mark all POMs as closed
 		om.asyncClosePOMs()
@@ -136,6 +157,23 @@ func (om *offsetManager) Close() error {
 	return nil
 }
 
+// setMember updates the member ID and generation offsets are committed with, after
+// the member rejoined the group without releasing its partitions.
+func (om *offsetManager) setMember(memberID string, generation int32) {
+	om.pomsLock.Lock()
+	defer om.pomsLock.Unlock()
+
+	om.memberID = memberID
+	om.generation = generation
+}
+
+func (om *offsetManager) computeCommitBackoff(retries int) time.Duration {
+	if om.conf.Consumer.Offsets.Retry.BackoffFunc != nil {
+		return om.conf.Consumer.Offsets.Retry.BackoffFunc(retries, om.conf.Consumer.Offsets.Retry.Max)
+	}
+	return om.conf.Consumer.Offsets.Retry.Backoff
+}
+
 func (om *offsetManager) computeBackoff(retries int) time.Duration {
 	if om.conf.Metadata.Retry.BackoffFunc != nil {
 		return om.conf.Metadata.Retry.BackoffFunc(retries, om.conf.Metadata.Retry.Max)
@@ -145,6 +183,10 @@ func (om *offsetManager) computeBackoff(retries int) time.Duration {
 }
 
 func (om *offsetManager) fetchInitialOffset(topic string, partition int32, retries int) (int64, int32, string, error) {
+	if om.conf.Consumer.Offsets.Store != nil {
+		return om.fetchStoredOffset(topic, partition)
+	}
+
 	broker, err := om.coordinator()
 	if err != nil {
 		if retries <= 0 {
@@ -253,16 +295,111 @@ func (om *offsetManager) Commit() {
 	om.releasePOMs(false)
 }
 
+func (om *offsetManager) CommitAsync(callback OffsetCommitCallback) {
+	om.commitAsync(nil, callback)
+}
+
+// commitAsync commits the offsets of the given partition, or of all partitions if nil,
+// in the background. Once the manager is closed, callback is called with
+// ErrClosedOffsetManager right away instead.
+func (om *offsetManager) commitAsync(only *partitionOffsetManager, callback OffsetCommitCallback) {
+	om.asyncLock.Lock()
+	select {
+	case <-om.closing:
+		om.asyncLock.Unlock()
+		if callback != nil {
+			callback(nil, ErrClosedOffsetManager)
+		}
+		return
+	default:
+	}
+	om.asyncCommits.Add(1)
+	om.asyncLock.Unlock()
+
+	go withRecover(func() {
+		defer om.asyncCommits.Done()
+		offsets, err := om.commitWithRetries(only)
+		om.releasePOMs(false)
+		if callback != nil {
+			callback(offsets, err)
+		}
+	})
+}
+
+// commitWithRetries commits the dirty offsets, retrying up to Consumer.Offsets.Retry.Max
+// times as long as the errors are retriable.
+func (om *offsetManager) commitWithRetries(only *partitionOffsetManager) (map[string]map[int32]int64, error) {
+	offsets := make(map[string]map[int32]int64)
+	for retries := 0; ; retries++ {
+		req := om.constructRequest(only)
+		if req == nil {
+			return offsets, nil
+		}
+
+		committed, retriable, err := om.sendRequest(req)
+		for topic, partitions := range committed {
+			if offsets[topic] == nil {
+				offsets[topic] = make(map[int32]int64)
+			}
+			for partition, offset := range partitions {
+				offsets[topic][partition] = offset
+			}
+		}
+		if err == nil {
+			return offsets, nil
+		}
+		if !retriable || retries >= om.conf.Consumer.Offsets.Retry.Max {
+			return offsets, err
+		}
+
+		backoff := om.computeCommitBackoff(retries + 1)
+		Logger.Printf("offsetmanager/%s retrying commit (%d attempts remaining): %v\n",
+			om.group, om.conf.Consumer.Offsets.Retry.Max-retries, err)
+		select {
+		case <-time.After(backoff):
+		case <-om.closing:
+			return offsets, err
+		}
+	}
+}
+
 func (om *offsetManager) flushToBroker() {
-	req := om.constructRequest()
+	req := om.constructRequest(nil)
 	if req == nil {
 		return
 	}
 
+	_, _, _ = om.sendRequest(req)
+}
+
+// sendRequest sends the commit request to the coordinator, or to Consumer.Offsets.Store if
+// set, returning the offsets that were committed and the last error encountered, which is
+// retriable if retrying the commit of the offsets that were not committed might succeed.
+func (om *offsetManager) sendRequest(req *OffsetCommitRequest) (map[string]map[int32]int64, bool, error) {
+	var committed map[string]map[int32]int64
+	var retriable bool
+	var err error
+	if om.conf.Consumer.Offsets.Store != nil {
+		committed, retriable, err = om.sendToStore(req)
+	} else {
+		committed, retriable, err = om.sendToCoordinator(req)
+	}
+
+	if len(committed) > 0 {
+		for _, interceptor := range om.conf.Consumer.Interceptors {
+			if interceptor, ok := interceptor.(ConsumerCommitInterceptor); ok {
+				safelyApplyCommitInterceptor(interceptor, om.group, committed)
+			}
+		}
+	}
+	return committed, retriable, err
+}
+
+func (om *offsetManager) sendToCoordinator(req *OffsetCommitRequest) (map[string]map[int32]int64, bool, error) {
 	broker, err := om.coordinator()
 	if err != nil {
 		om.handleError(err)
-		return
+		return nil, true, err
 	}
 
 	resp, err := broker.CommitOffset(req)
@@ -270,13 +407,18 @@ func (om *offsetManager) flushToBroker() {
 		om.handleError(err)
 		om.releaseCoordinator(broker)
 		_ = broker.Close()
-		return
+		return nil, true, err
 	}
 
-	om.handleResponse(broker, req, resp)
+	return om.handleResponse(broker, req, resp)
 }
 
-func (om *offsetManager) constructRequest() *OffsetCommitRequest {
+// constructRequest builds a request committing the dirty offsets of the given partition,
+// or of all partitions if nil.
+func (om *offsetManager) constructRequest(only *partitionOffsetManager) *OffsetCommitRequest {
+	om.pomsLock.RLock()
+	defer om.pomsLock.RUnlock()
+
 	var r *OffsetCommitRequest
 	var perPartitionTimestamp int64
 	if om.conf.Consumer.Offsets.Retention == 0 {
@@ -297,11 +439,11 @@ func (om *offsetManager) constructRequest() *OffsetCommitRequest {
 		}
 	}
 
-	om.pomsLock.RLock()
-	defer om.pomsLock.RUnlock()
-
 	for _, topicManagers := range om.poms {
 		for _, pom := range topicManagers {
+			if only != nil && pom != only {
+				continue
+			}
 			pom.lock.Lock()
 			if pom.dirty {
 				r.AddBlock(pom.topic, pom.partition, pom.offset, pom.leaderEpoch, perPartitionTimestamp, pom.metadata)
@@ -322,10 +464,17 @@ func (om *offsetManager) constructRequest() *OffsetCommitRequest {
 	return nil
 }
 
-func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest, resp *OffsetCommitResponse) {
+func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest, resp *OffsetCommitResponse) (committed map[string]map[int32]int64, retriable bool, lastErr error) {
 	om.pomsLock.RLock()
 	defer om.pomsLock.RUnlock()
 
+	committed = make(map[string]map[int32]int64)
+	retriable = true
+	fail := func(err error, canRetry bool) {
+		lastErr = err
+		retriable = retriable && canRetry
+	}
+
 	for _, topicManagers := range om.poms {
 		for _, pom := range topicManagers {
 			if req.blocks[pom.topic] == nil || req.blocks[pom.topic][pom.partition] == nil {
@@ -337,10 +486,12 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 
 			if resp.Errors[pom.topic] == nil {
 				pom.handleError(ErrIncompleteResponse)
+				fail(ErrIncompleteResponse, true)
 				continue
 			}
 			if err, ok = resp.Errors[pom.topic][pom.partition]; !ok {
 				pom.handleError(ErrIncompleteResponse)
+				fail(ErrIncompleteResponse, true)
 				continue
 			}
 
@@ -348,19 +499,26 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 			case ErrNoError:
 				block := req.blocks[pom.topic][pom.partition]
 				pom.updateCommitted(block.offset, block.metadata)
+				if committed[pom.topic] == nil {
+					committed[pom.topic] = make(map[int32]int64)
+				}
+				committed[pom.topic][pom.partition] = block.offset
 			case ErrNotLeaderForPartition, ErrLeaderNotAvailable,
 				ErrConsumerCoordinatorNotAvailable, ErrNotCoordinatorForConsumer:
 				// not a critical error, we just need to redispatch
 				om.releaseCoordinator(broker)
+				fail(err, true)
 			case ErrOffsetMetadataTooLarge, ErrInvalidCommitOffsetSize:
 				// nothing we can do about this, just tell the user and carry on
 				pom.handleError(err)
+				fail(err, false)
 			case ErrOffsetsLoadInProgress:
 				// nothing wrong but we didn't commit, we'll get it next time round
+				fail(err, true)
 			case ErrFencedInstancedId:
 				pom.handleError(err)
-				// TODO close the whole consumer for instacne fenced....
 				om.tryCancelSession()
+				fail(err, false)
 			case ErrUnknownTopicOrPartition:
 				// let the user know *and* try redispatching - if topic-auto-create is
 				// enabled, redispatching should trigger a metadata req and create the
@@ -371,9 +529,11 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 				// dunno, tell the user and try redispatching
 				pom.handleError(err)
 				om.releaseCoordinator(broker)
+				fail(err, true)
 			}
 		}
 	}
+	return committed, retriable, lastErr
 }
 
 func (om *offsetManager) handleError(err error) {
@@ -476,6 +636,10 @@ type PartitionOffsetManager interface {
 	// allows incrementing the offset. cf MarkOffset for more details.
 	ResetOffset(offset int64, metadata string)
 
+	// CommitAsync commits the offset of this partition in the background, like
+	// OffsetManager.CommitAsync does for all partitions.
+	CommitAsync(callback OffsetCommitCallback)
+
 	// Errors returns a read channel of errors that occur during offset management, if
 	// enabled. By default, errors are logged and not returned over this channel. If
 	// you want to implement any custom error handling, set your config's
@@ -555,6 +719,10 @@ func (pom *partitionOffsetManager) ResetOffset(offset int64, metadata string) {
 	}
 }
 
+func (pom *partitionOffsetManager) CommitAsync(callback OffsetCommitCallback) {
+	pom.parent.commitAsync(pom, callback)
+}
+
 func (pom *partitionOffsetManager) updateCommitted(offset int64, metadata string) {
 	pom.lock.Lock()
 	defer pom.lock.Unlock()
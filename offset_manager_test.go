@@ -229,6 +229,247 @@ func TestNewOffsetManagerOffsetsManualCommit(t *testing.T) {
 	safeClose(t, testClient)
 }
 
+func TestPartitionOffsetManagerCommitAsync(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Retry.Backoff = time.Millisecond
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	// the first attempt fails with a retriable error
+	loading := new(OffsetCommitResponse)
+	loading.AddError("my_topic", 0, ErrOffsetsLoadInProgress)
+	coordinator.Returns(loading)
+	committed := new(OffsetCommitResponse)
+	committed.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(committed)
+
+	type result struct {
+		offsets map[string]map[int32]int64
+		err     error
+	}
+	done := make(chan result, 1)
+	callback := func(offsets map[string]map[int32]int64, err error) {
+		done <- result{offsets, err}
+	}
+
+	pom.MarkOffset(100, "modified_meta")
+	pom.CommitAsync(callback)
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Error("Expected the commit to succeed, got", res.err)
+		}
+		if res.offsets["my_topic"][0] != 100 {
+			t.Error("Expected offset 100 to be committed, got", res.offsets)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the commit callback")
+	}
+
+	// non-retriable errors are reported right away
+	tooLarge := new(OffsetCommitResponse)
+	tooLarge.AddError("my_topic", 0, ErrOffsetMetadataTooLarge)
+	coordinator.Returns(tooLarge)
+
+	pom.MarkOffset(200, "modified_meta")
+	om.CommitAsync(callback)
+
+	select {
+	case res := <-done:
+		if !errors.Is(res.err, ErrOffsetMetadataTooLarge) {
+			t.Error("Expected ErrOffsetMetadataTooLarge, got", res.err)
+		}
+		if len(res.offsets) != 0 {
+			t.Error("Expected no offset to be committed, got", res.offsets)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the commit callback")
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCloseWaitsForCommitAsync(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Retry.Max = 5
+	config.Consumer.Offsets.Retry.Backoff = time.Hour
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	requested := make(chan none, 1)
+	loading := new(OffsetCommitResponse)
+	loading.AddError("my_topic", 0, ErrOffsetsLoadInProgress)
+	coordinator.setHandler(func(req *request) encoderWithHeader {
+		requested <- none{}
+		return loading
+	})
+
+	var called int32
+	pom.MarkOffset(100, "modified_meta")
+	om.CommitAsync(func(offsets map[string]map[int32]int64, err error) {
+		if !errors.Is(err, ErrOffsetsLoadInProgress) {
+			t.Error("Expected ErrOffsetsLoadInProgress, got", err)
+		}
+		atomic.StoreInt32(&called, 1)
+	})
+	<-requested
+
+	// the commit is backing off for an hour, Close stops it and waits for its callback
+	safeClose(t, om)
+	if atomic.LoadInt32(&called) != 1 {
+		t.Error("Expected the callback to be called before Close returned")
+	}
+	safeClose(t, pom)
+
+	om.CommitAsync(func(offsets map[string]map[int32]int64, err error) {
+		if !errors.Is(err, ErrClosedOffsetManager) {
+			t.Error("Expected ErrClosedOffsetManager, got", err)
+		}
+		atomic.StoreInt32(&called, 2)
+	})
+	if atomic.LoadInt32(&called) != 2 {
+		t.Error("Expected the callback to be called right away once closed")
+	}
+
+	broker.Close()
+	coordinator.Close()
+	safeClose(t, testClient)
+}
+
+type commitInterceptor struct {
+	panics    bool
+	committed chan map[string]map[int32]int64
+}
+
+func (c *commitInterceptor) OnConsume(*ConsumerMessage) {}
+
+func (c *commitInterceptor) OnCommit(group string, offsets map[string]map[int32]int64) {
+	if c.panics {
+		panic("hey, the interceptor has failed")
+	}
+	if group == "group" {
+		c.committed <- offsets
+	}
+}
+
+func TestOffsetManagerCommitInterceptors(t *testing.T) {
+	interceptor := &commitInterceptor{committed: make(chan map[string]map[int32]int64, 1)}
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Interceptors = []ConsumerInterceptor{&commitInterceptor{panics: true}, interceptor}
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+	om.Commit()
+
+	select {
+	case offsets := <-interceptor.committed:
+		if offsets["my_topic"][0] != 100 {
+			t.Error("Expected offset 100 to be reported as committed, got", offsets)
+		}
+	default:
+		t.Error("Expected OnCommit to be called")
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+type memoryOffsetStore struct {
+	offsets map[string]map[int32]OffsetAndMetadata
+	commits []*OffsetStoreCommit
+}
+
+func (s *memoryOffsetStore) FetchOffset(group, topic string, partition int32) (int64, string, error) {
+	if offset, ok := s.offsets[topic][partition]; ok {
+		return offset.Offset, offset.Metadata, nil
+	}
+	return -1, "", nil
+}
+
+func (s *memoryOffsetStore) CommitOffsets(commit *OffsetStoreCommit) error {
+	s.commits = append(s.commits, commit)
+	return nil
+}
+
+func TestOffsetManagerStore(t *testing.T) {
+	store := &memoryOffsetStore{offsets: map[string]map[int32]OffsetAndMetadata{
+		"my_topic": {0: {Offset: 5, Metadata: "stored_meta"}},
+	}}
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Store = store
+
+	broker := NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+	})
+
+	testClient, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	om, err := newOffsetManagerFromClient("group", "member", 3, testClient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pom, err := om.ManagePartition("my_topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, meta := pom.NextOffset(); offset != 5 || meta != "stored_meta" {
+		t.Errorf("Expected the stored offset 5 with stored_meta, got %d with %q", offset, meta)
+	}
+
+	pom.MarkOffset(100, "modified_meta")
+	om.Commit()
+
+	if len(store.commits) != 1 {
+		t.Fatalf("Expected 1 commit to the store, got %d", len(store.commits))
+	}
+	commit := store.commits[0]
+	if commit.Group != "group" || commit.MemberID != "member" || commit.GenerationID != 3 {
+		t.Errorf("Expected the commit of member of generation 3 of group, got %+v", commit)
+	}
+	if offset := commit.Offsets["my_topic"][0]; offset.Offset != 100 || offset.Metadata != "modified_meta" {
+		t.Errorf("Expected offset 100 with modified_meta to be committed, got %+v", offset)
+	}
+	for _, call := range broker.History() {
+		if _, ok := call.Request.(*MetadataRequest); !ok {
+			t.Errorf("Expected no request but metadata requests, got %T", call.Request)
+		}
+	}
+
+	broker.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
 // Test recovery from ErrNotCoordinatorForConsumer
 // on first fetchInitialOffset call
 func TestOffsetManagerFetchInitialFail(t *testing.T) {
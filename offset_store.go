@@ -0,0 +1,78 @@
+package sarama
+
+// OffsetStore stores the offsets committed by an OffsetManager, and so by consumer groups,
+// outside of Kafka, see Consumer.Offsets.Store. Group membership and partition assignment
+// still rely on Kafka. This makes it possible for a sink to write the offsets of the messages
+// it consumed in the same transaction as the data they produced, for exactly-once delivery.
+type OffsetStore interface {
+	// FetchOffset returns the next offset to consume committed by the group for the
+	// partition with its metadata, or an offset of -1 if the group has not committed any.
+	FetchOffset(group, topic string, partition int32) (offset int64, metadata string, err error)
+
+	// CommitOffsets stores the offsets committed by a member of the group. The commit is
+	// considered atomic: if an error is returned, none of the offsets is committed and the
+	// commit is retried as configured by Consumer.Offsets.Retry.
+	CommitOffsets(commit *OffsetStoreCommit) error
+}
+
+// OffsetStoreCommit are offsets committed to an OffsetStore by a member of a group.
+type OffsetStoreCommit struct {
+	Group string
+	// MemberID and GenerationID identify the member of the group committing the offsets, so
+	// that stores can reject the commits of members of previous generations.
+	MemberID     string
+	GenerationID int32
+	// Offsets are the committed offsets by topic and partition.
+	Offsets map[string]map[int32]OffsetAndMetadata
+}
+
+// OffsetAndMetadata is the next offset to consume committed for a partition, and its metadata.
+type OffsetAndMetadata struct {
+	Offset   int64
+	Metadata string
+}
+
+// fetchStoredOffset returns the offset committed for the partition in Consumer.Offsets.Store.
+func (om *offsetManager) fetchStoredOffset(topic string, partition int32) (int64, int32, string, error) {
+	offset, metadata, err := om.conf.Consumer.Offsets.Store.FetchOffset(om.group, topic, partition)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return offset, invalidLeaderEpoch, metadata, nil
+}
+
+// sendToStore commits the offsets of the request to Consumer.Offsets.Store rather than to the
+// coordinator, returning the offsets that were committed, if any, and the error encountered.
+func (om *offsetManager) sendToStore(req *OffsetCommitRequest) (map[string]map[int32]int64, bool, error) {
+	commit := &OffsetStoreCommit{
+		Group:        req.ConsumerGroup,
+		MemberID:     req.ConsumerID,
+		GenerationID: req.ConsumerGroupGeneration,
+		Offsets:      make(map[string]map[int32]OffsetAndMetadata, len(req.blocks)),
+	}
+	for topic, partitions := range req.blocks {
+		commit.Offsets[topic] = make(map[int32]OffsetAndMetadata, len(partitions))
+		for partition, block := range partitions {
+			commit.Offsets[topic][partition] = OffsetAndMetadata{Offset: block.offset, Metadata: block.metadata}
+		}
+	}
+
+	if err := om.conf.Consumer.Offsets.Store.CommitOffsets(commit); err != nil {
+		om.handleError(err)
+		return nil, true, err
+	}
+
+	committed := make(map[string]map[int32]int64, len(commit.Offsets))
+	om.pomsLock.RLock()
+	for topic, partitions := range commit.Offsets {
+		committed[topic] = make(map[int32]int64, len(partitions))
+		for partition, offset := range partitions {
+			if pom := om.poms[topic][partition]; pom != nil {
+				pom.updateCommitted(offset.Offset, offset.Metadata)
+			}
+			committed[topic][partition] = offset.Offset
+		}
+	}
+	om.pomsLock.RUnlock()
+	return committed, false, nil
+}
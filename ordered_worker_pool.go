@@ -0,0 +1,162 @@
+package sarama
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// OrderedWorkerPool is a ConsumerGroupHandler processing the messages of each claim
+// concurrently with a MessageHandler, on a fixed number of workers. Messages are dispatched
+// to workers by key, so that messages with the same key are processed one at a time and in
+// order, while messages without a key are spread across workers.
+//
+// A message is only marked as consumed once it and all the messages before it in the
+// partition were processed, so that the committed offset never skips a message which is
+// still being processed. When a message fails to be processed, no more messages are
+// dispatched and ConsumeClaim returns the error once the workers have stopped, the
+// unprocessed messages being consumed again by the next session.
+type OrderedWorkerPool struct {
+	// MaxInFlight is the maximum number of messages of a claim dispatched to workers and not
+	// yet processed (defaults to 256).
+	MaxInFlight int
+
+	noopSetupCleanup
+	workers int
+	handle  MessageHandler
+}
+
+// NewOrderedWorkerPool creates an OrderedWorkerPool processing the messages of each claim with
+// handle on the given number of workers.
+func NewOrderedWorkerPool(workers int, handle MessageHandler) (*OrderedWorkerPool, error) {
+	if workers <= 0 {
+		return nil, ConfigurationError("OrderedWorkerPool requires at least one worker")
+	}
+	if handle == nil {
+		return nil, ConfigurationError("OrderedWorkerPool requires a message handler")
+	}
+	return &OrderedWorkerPool{
+		MaxInFlight: 256,
+		workers:     workers,
+		handle:      handle,
+	}, nil
+}
+
+type processedMessage struct {
+	msg *ConsumerMessage
+	err error
+}
+
+// ConsumeClaim implements ConsumerGroupHandler, dispatching the messages of the claim to the
+// workers until it is closed and all its messages are processed, or a message fails.
+func (p *OrderedWorkerPool) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	maxInFlight := p.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+
+	// the buffers hold every message in flight, so neither dispatching nor reporting ever blocks
+	processed := make(chan processedMessage, maxInFlight)
+	stopped := make(chan none)
+	inputs := make([]chan *ConsumerMessage, p.workers)
+	var wg sync.WaitGroup
+	for i := range inputs {
+		inputs[i] = make(chan *ConsumerMessage, maxInFlight)
+		wg.Add(1)
+		go func(input <-chan *ConsumerMessage) {
+			defer wg.Done()
+			for msg := range input {
+				select {
+				case <-stopped:
+					continue // drop the messages dispatched before the pool stopped
+				default:
+				}
+				processed <- processedMessage{msg: msg, err: p.handle(sess, msg)}
+			}
+		}(inputs[i])
+	}
+	defer func() {
+		close(stopped)
+		for _, input := range inputs {
+			close(input)
+		}
+		wg.Wait()
+	}()
+
+	tracker := newInFlightMessages()
+	messages, stop := claimMessages(sess, claim)
+	defer stop()
+	next := 0 // worker of the next message without a key
+	for {
+		// stop reading messages while the pool is full or once the claim is closed
+		var in <-chan *ConsumerMessage
+		if messages != nil && tracker.len() < maxInFlight {
+			in = messages
+		}
+
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				messages = nil
+				if tracker.len() == 0 {
+					return nil
+				}
+				continue
+			}
+			tracker.add(msg)
+			worker := next
+			if msg.Key != nil {
+				hash := fnv.New32a()
+				_, _ = hash.Write(msg.Key)
+				worker = int(hash.Sum32() % uint32(p.workers))
+			} else {
+				next = (next + 1) % p.workers
+			}
+			inputs[worker] <- msg
+		case res := <-processed:
+			if res.err != nil {
+				return res.err
+			}
+			if last := tracker.done(res.msg); last != nil {
+				sess.MarkMessage(last, "")
+			}
+			if messages == nil && tracker.len() == 0 {
+				return nil
+			}
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// inFlightMessages tracks the messages of a partition being processed, in offset order.
+type inFlightMessages struct {
+	queue     []*ConsumerMessage
+	processed map[int64]bool
+}
+
+func newInFlightMessages() *inFlightMessages {
+	return &inFlightMessages{processed: make(map[int64]bool)}
+}
+
+func (m *inFlightMessages) len() int {
+	return len(m.queue)
+}
+
+func (m *inFlightMessages) add(msg *ConsumerMessage) {
+	m.queue = append(m.queue, msg)
+}
+
+// done records that msg was processed, and returns the last message of the contiguous run of
+// processed messages it completed at the head of the queue, or nil if there is none.
+func (m *inFlightMessages) done(msg *ConsumerMessage) *ConsumerMessage {
+	m.processed[msg.Offset] = true
+
+	var last *ConsumerMessage
+	for len(m.queue) > 0 && m.processed[m.queue[0].Offset] {
+		last = m.queue[0]
+		delete(m.processed, last.Offset)
+		m.queue[0] = nil
+		m.queue = m.queue[1:]
+	}
+	return last
+}
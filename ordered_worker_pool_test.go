@@ -0,0 +1,93 @@
+package sarama
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedWorkerPool(t *testing.T) {
+	var lock sync.Mutex
+	processed := make(map[string][]int64)
+	pool, err := NewOrderedWorkerPool(4, func(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+		// process the early messages slowly, so that later ones complete first
+		if msg.Offset < 5 {
+			time.Sleep(time.Duration(5-msg.Offset) * time.Millisecond)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		processed[string(msg.Key)] = append(processed[string(msg.Key)], msg.Offset)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.MaxInFlight = 8
+
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 30)}
+	for i := int64(0); i < 30; i++ {
+		key := []byte{byte('a' + i%3)}
+		if i%10 == 9 {
+			key = nil
+		}
+		claim.messages <- &ConsumerMessage{Topic: "orders", Offset: i, Key: key}
+	}
+	close(claim.messages)
+
+	sess := &markingSession{}
+	if err := pool.ConsumeClaim(sess, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, offsets := range processed {
+		if key == "" {
+			continue
+		}
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Errorf("expected the messages of key %s to be processed in order, got %v", key, offsets)
+				break
+			}
+		}
+	}
+
+	// marked offsets only ever advance over processed messages
+	if len(sess.marked) == 0 || sess.marked[len(sess.marked)-1] != 29 {
+		t.Fatalf("expected the last message to be marked, got %v", sess.marked)
+	}
+	for i := 1; i < len(sess.marked); i++ {
+		if sess.marked[i] <= sess.marked[i-1] {
+			t.Errorf("expected marked offsets to increase, got %v", sess.marked)
+			break
+		}
+	}
+}
+
+func TestOrderedWorkerPoolError(t *testing.T) {
+	errFailed := errors.New("failed")
+	pool, err := NewOrderedWorkerPool(2, func(sess ConsumerGroupSession, msg *ConsumerMessage) error {
+		if msg.Offset == 3 {
+			return errFailed
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.MaxInFlight = 1
+
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 6)}
+	for i := int64(0); i < 6; i++ {
+		claim.messages <- &ConsumerMessage{Topic: "orders", Offset: i}
+	}
+	close(claim.messages)
+
+	sess := &markingSession{}
+	if err := pool.ConsumeClaim(sess, claim); !errors.Is(err, errFailed) {
+		t.Fatalf("expected the processing error to be returned, got %v", err)
+	}
+	if len(sess.marked) != 3 || sess.marked[2] != 2 {
+		t.Errorf("expected the messages before the failure only to be marked, got %v", sess.marked)
+	}
+}
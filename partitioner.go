@@ -1,7 +1,9 @@
 package sarama
 
 import (
+	"encoding/binary"
 	"hash"
+	"hash/crc32"
 	"hash/fnv"
 	"math/rand"
 	"time"
@@ -60,6 +62,34 @@ func WithCustomHashFunction(hasher func() hash.Hash32) HashPartitionerOption {
 	}
 }
 
+// HashMode selects one of the built-in hash functions of the hash partitioners
+type HashMode int
+
+const (
+	// HashModeFNV1a hashes keys with FNV-1a, the hash used by NewHashPartitioner
+	HashModeFNV1a HashMode = iota
+	// HashModeMurmur2 hashes keys with the murmur2 variant used by the Java client's default partitioner
+	HashModeMurmur2
+	// HashModeCRC32 hashes keys with the IEEE CRC-32 checksum
+	HashModeCRC32
+)
+
+// WithHashMode lets you select one of the built-in hash functions to use for the partitioning,
+// see WithCustomHashFunction for anything else. Combine HashModeMurmur2 with WithAbsFirst, or use
+// NewMurmur2Partitioner, to choose the same partitions as the Java client.
+func WithHashMode(mode HashMode) HashPartitionerOption {
+	return func(hp *hashPartitioner) {
+		switch mode {
+		case HashModeMurmur2:
+			hp.hasher = newMurmur2Hash32()
+		case HashModeCRC32:
+			hp.hasher = crc32.NewIEEE()
+		default:
+			hp.hasher = fnv.New32a()
+		}
+	}
+}
+
 // WithCustomFallbackPartitioner lets you specify what HashPartitioner should be used in case a Distribution Key is empty
 func WithCustomFallbackPartitioner(randomHP Partitioner) HashPartitionerOption {
 	return func(hp *hashPartitioner) {
@@ -179,6 +209,19 @@ func NewReferenceHashPartitioner(topic string) Partitioner {
 	return p
 }
 
+// NewMurmur2Partitioner returns a Partitioner which is byte-compatible with the default partitioner
+// of the Java client for keyed messages: the murmur2 hash of the encoded bytes of the message key,
+// made positive by clearing its sign bit, modulus the number of partitions. This lets producers
+// written in different languages send the same keys to the same partitions. If the message's key
+// is nil then a random partition is chosen.
+func NewMurmur2Partitioner(topic string) Partitioner {
+	p := new(hashPartitioner)
+	p.random = NewRandomPartitioner(topic)
+	p.hasher = newMurmur2Hash32()
+	p.referenceAbs = true
+	return p
+}
+
 func (p *hashPartitioner) Partition(message *ProducerMessage, numPartitions int32) (int32, error) {
 	if message.Key == nil {
 		return p.random.Partition(message, numPartitions)
@@ -215,3 +258,75 @@ func (p *hashPartitioner) RequiresConsistency() bool {
 func (p *hashPartitioner) MessageRequiresConsistency(message *ProducerMessage) bool {
 	return message.Key != nil
 }
+
+// murmur2Hash32 implements the murmur2 variant of the Java client (org.apache.kafka.common.utils.Utils.murmur2).
+// The hash is not computed incrementally as the Java implementation mixes the length of the data into its seed.
+type murmur2Hash32 struct {
+	data []byte
+}
+
+func newMurmur2Hash32() hash.Hash32 {
+	return new(murmur2Hash32)
+}
+
+func (m *murmur2Hash32) Write(p []byte) (int, error) {
+	m.data = append(m.data, p...)
+	return len(p), nil
+}
+
+func (m *murmur2Hash32) Sum(b []byte) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], m.Sum32())
+	return append(b, sum[:]...)
+}
+
+func (m *murmur2Hash32) Reset() {
+	m.data = m.data[:0]
+}
+
+func (m *murmur2Hash32) Size() int {
+	return 4
+}
+
+func (m *murmur2Hash32) BlockSize() int {
+	return 4
+}
+
+func (m *murmur2Hash32) Sum32() uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		mul  uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	data := m.data
+	length := len(data)
+	h := seed ^ uint32(length)
+
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		k *= mul
+		k ^= k >> r
+		k *= mul
+		h *= mul
+		h ^= k
+		data = data[4:]
+	}
+
+	switch len(data) {
+	case 3:
+		h ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[0])
+		h *= mul
+	}
+
+	h ^= h >> 13
+	h *= mul
+	h ^= h >> 15
+	return h
+}
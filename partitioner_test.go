@@ -316,3 +316,62 @@ func ExamplePartitioner_per_topic() {
 
 	// ...
 }
+
+func TestMurmur2Hash32(t *testing.T) {
+	// test vectors from the Java client's UtilsTest
+	for key, expected := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		hasher := newMurmur2Hash32()
+		_, _ = hasher.Write([]byte(key))
+		if sum := int32(hasher.Sum32()); sum != expected {
+			t.Errorf("murmur2(%q) = %d, expected %d", key, sum, expected)
+		}
+	}
+}
+
+func TestMurmur2Partitioner(t *testing.T) {
+	partitioner := NewMurmur2Partitioner("mytopic")
+
+	// murmur2("foobar") = -790332482, whose positive value is 1357151166
+	choice, err := partitioner.Partition(&ProducerMessage{Key: StringEncoder("foobar")}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if choice != 1357151166%100 {
+		t.Errorf("expected partition %d, got %d", 1357151166%100, choice)
+	}
+
+	buf := make([]byte, 256)
+	for i := 0; i < 50; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			t.Error(err)
+		}
+		assertPartitioningConsistent(t, partitioner, &ProducerMessage{Key: ByteEncoder(buf)}, 50)
+	}
+}
+
+func TestHashPartitionerWithHashMode(t *testing.T) {
+	for _, mode := range []HashMode{HashModeFNV1a, HashModeMurmur2, HashModeCRC32} {
+		partitioner := NewCustomPartitioner(WithHashMode(mode), WithAbsFirst())("mytopic")
+		for _, key := range []string{"a", "foobar", "lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8"} {
+			assertPartitioningConsistent(t, partitioner, &ProducerMessage{Key: StringEncoder(key)}, 7)
+		}
+	}
+
+	murmur2 := NewCustomPartitioner(WithHashMode(HashModeMurmur2), WithAbsFirst())("mytopic")
+	reference := NewMurmur2Partitioner("mytopic")
+	for _, key := range []string{"a", "foobar", "21"} {
+		msg := &ProducerMessage{Key: StringEncoder(key)}
+		got, _ := murmur2.Partition(msg, 13)
+		want, _ := reference.Partition(msg, 13)
+		if got != want {
+			t.Errorf("key %q: expected partition %d, got %d", key, want, got)
+		}
+	}
+}
@@ -54,13 +54,14 @@ func updateMsgSetMetrics(msgSet *MessageSet, compressionRatioMetric metrics.Hist
 
 func updateBatchMetrics(recordBatch *RecordBatch, compressionRatioMetric metrics.Histogram,
 	topicCompressionRatioMetric metrics.Histogram) int64 {
-	if recordBatch.compressedRecords != nil {
+	// recordsLen is unknown for batches that were submitted already encoded
+	if recordBatch.compressedRecords != nil && recordBatch.recordsLen > 0 {
 		compressionRatio := int64(float64(recordBatch.recordsLen) / float64(len(recordBatch.compressedRecords)) * 100)
 		compressionRatioMetric.Update(compressionRatio)
 		topicCompressionRatioMetric.Update(compressionRatio)
 	}
 
-	return int64(len(recordBatch.Records))
+	return int64(recordBatch.numRecords())
 }
 
 func (r *ProduceRequest) encode(pe packetEncoder) error {
@@ -220,6 +221,8 @@ func (r *ProduceRequest) requiredVersion() KafkaVersion {
 		return V0_11_0_0
 	case 7:
 		return V2_1_0_0
+	case 8:
+		return V2_4_0_0
 	default:
 		return MinVersion
 	}
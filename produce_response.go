@@ -9,23 +9,37 @@ import (
 // v1
 // v2 = v3 = v4
 // v5 = v6 = v7
-// Produce Response (Version: 7) => [responses] throttle_time_ms
+// v8
+// Produce Response (Version: 8) => [responses] throttle_time_ms
 //   responses => topic [partition_responses]
 //     topic => STRING
-//     partition_responses => partition error_code base_offset log_append_time log_start_offset
+//     partition_responses => partition error_code base_offset log_append_time log_start_offset [record_errors] error_message
 //       partition => INT32
 //       error_code => INT16
 //       base_offset => INT64
 //       log_append_time => INT64
 //       log_start_offset => INT64
+//       record_errors => batch_index batch_index_error_message
+//         batch_index => INT32
+//         batch_index_error_message => NULLABLE_STRING
+//       error_message => NULLABLE_STRING
 //   throttle_time_ms => INT32
 
+// ProduceResponseRecordError identifies a record that caused its batch to be
+// rejected (KIP-467).
+type ProduceResponseRecordError struct {
+	BatchIndex             int32   // v8, batch_index
+	BatchIndexErrorMessage *string // v8, batch_index_error_message
+}
+
 // partition_responses in protocol
 type ProduceResponseBlock struct {
-	Err         KError    // v0, error_code
-	Offset      int64     // v0, base_offset
-	Timestamp   time.Time // v2, log_append_time, and the broker is configured with `LogAppendTime`
-	StartOffset int64     // v5, log_start_offset
+	Err          KError                        // v0, error_code
+	Offset       int64                         // v0, base_offset
+	Timestamp    time.Time                     // v2, log_append_time, and the broker is configured with `LogAppendTime`
+	StartOffset  int64                         // v5, log_start_offset
+	RecordErrors []*ProduceResponseRecordError // v8, record_errors
+	ErrorMessage *string                       // v8, error_message
 }
 
 func (b *ProduceResponseBlock) decode(pd packetDecoder, version int16) (err error) {
@@ -55,6 +69,30 @@ func (b *ProduceResponseBlock) decode(pd packetDecoder, version int16) (err erro
 		}
 	}
 
+	if version >= 8 {
+		numRecordErrors, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if numRecordErrors > 0 {
+			b.RecordErrors = make([]*ProduceResponseRecordError, numRecordErrors)
+			for i := range b.RecordErrors {
+				recordError := new(ProduceResponseRecordError)
+				if recordError.BatchIndex, err = pd.getInt32(); err != nil {
+					return err
+				}
+				if recordError.BatchIndexErrorMessage, err = pd.getNullableString(); err != nil {
+					return err
+				}
+				b.RecordErrors[i] = recordError
+			}
+		}
+
+		if b.ErrorMessage, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -76,6 +114,32 @@ func (b *ProduceResponseBlock) encode(pe packetEncoder, version int16) (err erro
 		pe.putInt64(b.StartOffset)
 	}
 
+	if version >= 8 {
+		if err := pe.putArrayLength(len(b.RecordErrors)); err != nil {
+			return err
+		}
+		for _, recordError := range b.RecordErrors {
+			pe.putInt32(recordError.BatchIndex)
+			if err := pe.putNullableString(recordError.BatchIndexErrorMessage); err != nil {
+				return err
+			}
+		}
+		if err := pe.putNullableString(b.ErrorMessage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordError returns the record error reported for the record at the given
+// index of the batch, or nil if that record was not at fault.
+func (b *ProduceResponseBlock) recordError(batchIndex int32) *ProduceResponseRecordError {
+	for _, recordError := range b.RecordErrors {
+		if recordError.BatchIndex == batchIndex {
+			return recordError
+		}
+	}
 	return nil
 }
 
@@ -61,6 +61,24 @@ var (
 			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8, // Timestamp January 1st 0001 at 00:00:01,000 UTC (LogAppendTime was used)
 			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x32, // StartOffset 50
 
+			0x00, 0x00, 0x00, 0x64, // 100 ms throttle time
+		},
+		8: { // version 8 adds RecordErrors and ErrorMessage
+			0x00, 0x00, 0x00, 0x01,
+
+			0x00, 0x03, 'f', 'o', 'o',
+			0x00, 0x00, 0x00, 0x01,
+
+			0x00, 0x00, 0x00, 0x01, // Partition 1
+			0x00, 0x02, // ErrInvalidMessage
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, // Offset 255
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8, // Timestamp January 1st 0001 at 00:00:01,000 UTC (LogAppendTime was used)
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x32, // StartOffset 50
+			0x00, 0x00, 0x00, 0x01, // 1 record error
+			0x00, 0x00, 0x00, 0x03, // BatchIndex 3
+			0x00, 0x03, 'b', 'a', 'd', // BatchIndexErrorMessage "bad"
+			0x00, 0x04, 'o', 'o', 'p', 's', // ErrorMessage "oops"
+
 			0x00, 0x00, 0x00, 0x64, // 100 ms throttle time
 		},
 	}
@@ -103,6 +121,15 @@ func TestProduceResponseDecode(t *testing.T) {
 					t.Error("Decoding failed for foo/1/StartOffset, got:", block.StartOffset)
 				}
 			}
+			if v >= 8 {
+				if len(block.RecordErrors) != 1 || block.RecordErrors[0].BatchIndex != 3 ||
+					block.RecordErrors[0].BatchIndexErrorMessage == nil || *block.RecordErrors[0].BatchIndexErrorMessage != "bad" {
+					t.Error("Decoding failed for foo/1/RecordErrors, got:", block.RecordErrors)
+				}
+				if block.ErrorMessage == nil || *block.ErrorMessage != "oops" {
+					t.Error("Decoding failed for foo/1/ErrorMessage, got:", block.ErrorMessage)
+				}
+			}
 		}
 		if v >= 1 {
 			if expected := 100 * time.Millisecond; response.ThrottleTime != expected {
@@ -118,11 +145,14 @@ func TestProduceResponseEncode(t *testing.T) {
 	testEncodable(t, "empty", &response, produceResponseNoBlocksV0)
 
 	response.Blocks["foo"] = make(map[int32]*ProduceResponseBlock)
+	badRecord, oops := "bad", "oops"
 	response.Blocks["foo"][1] = &ProduceResponseBlock{
-		Err:         ErrInvalidMessage,
-		Offset:      255,
-		Timestamp:   time.Unix(1, 0),
-		StartOffset: 50,
+		Err:          ErrInvalidMessage,
+		Offset:       255,
+		Timestamp:    time.Unix(1, 0),
+		StartOffset:  50,
+		RecordErrors: []*ProduceResponseRecordError{{BatchIndex: 3, BatchIndexErrorMessage: &badRecord}},
+		ErrorMessage: &oops,
 	}
 	response.ThrottleTime = 100 * time.Millisecond
 	for v, produceResponseManyBlocks := range produceResponseManyBlocksVersions {
@@ -10,6 +10,7 @@ type partitionSet struct {
 	msgs          []*ProducerMessage
 	recordsToSend Records
 	bufferBytes   int
+	encoded       bool // recordsToSend holds a single batch submitted through ProducerMessage.EncodedBatch
 }
 
 type produceSet struct {
@@ -33,6 +34,10 @@ func newProduceSet(parent *asyncProducer) *produceSet {
 }
 
 func (ps *produceSet) add(msg *ProducerMessage) error {
+	if msg.EncodedBatch != nil {
+		return ps.addEncodedBatch(msg)
+	}
+
 	var err error
 	var key, val []byte
 
@@ -69,7 +74,7 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 				FirstTimestamp:   timestamp,
 				Version:          2,
 				Codec:            ps.parent.conf.Producer.Compression,
-				CompressionLevel: ps.parent.conf.Producer.CompressionLevel,
+				CompressionLevel: ps.parent.conf.producerCompressionLevel(),
 				ProducerID:       ps.producerID,
 				ProducerEpoch:    ps.producerEpoch,
 			}
@@ -82,6 +87,8 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 			set = &partitionSet{recordsToSend: newLegacyRecords(new(MessageSet))}
 		}
 		partitions[msg.Partition] = set
+	} else if set.encoded {
+		return errors.New("assertion failed: message added to the batch of an encoded message")
 	}
 
 	if ps.parent.conf.Version.IsAtLeast(V0_11_0_0) {
@@ -127,6 +134,47 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 	return nil
 }
 
+// addEncodedBatch adds a message carrying an already encoded record batch. The
+// batch gets a partition set of its own and is sent without being re-encoded,
+// apart from the header fields owned by the producer.
+func (ps *produceSet) addEncodedBatch(msg *ProducerMessage) error {
+	batch, err := decodeEncodedRecordBatch(msg.EncodedBatch)
+	if err != nil {
+		return err
+	}
+	if batch.Control {
+		return ConfigurationError("Producing encoded control batches is not supported")
+	}
+
+	partitions := ps.msgs[msg.Topic]
+	if partitions == nil {
+		partitions = make(map[int32]*partitionSet)
+		ps.msgs[msg.Topic] = partitions
+	}
+	if partitions[msg.Partition] != nil {
+		return errors.New("assertion failed: encoded message added to a partition with buffered messages")
+	}
+
+	batch.FirstOffset = 0
+	batch.PartitionLeaderEpoch = 0
+	batch.ProducerID = ps.producerID
+	batch.ProducerEpoch = ps.producerEpoch
+	batch.FirstSequence = -1
+	batch.IsTransactional = false
+
+	size := len(msg.EncodedBatch)
+	partitions[msg.Partition] = &partitionSet{
+		msgs:          []*ProducerMessage{msg},
+		recordsToSend: newDefaultRecords(batch),
+		bufferBytes:   size,
+		encoded:       true,
+	}
+	ps.bufferBytes += size
+	ps.bufferCount++
+
+	return nil
+}
+
 func (ps *produceSet) buildRequest() *ProduceRequest {
 	req := &ProduceRequest{
 		RequiredAcks: ps.parent.conf.Producer.RequiredAcks,
@@ -146,6 +194,11 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 		req.Version = 7
 	}
 
+	if ps.parent.conf.Version.IsAtLeast(V2_4_0_0) {
+		// v8 responses identify the individual records that caused a batch to be rejected
+		req.Version = 8
+	}
+
 	for topic, partitionSets := range ps.msgs {
 		for partition, set := range partitionSets {
 			if req.Version >= 3 {
@@ -157,7 +210,7 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 				// (See https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol#AGuideToTheKafkaProtocol-Messagesets
 				//  under the RecordBatch section for details.)
 				rb := set.recordsToSend.RecordBatch
-				if len(rb.Records) > 0 {
+				if len(rb.Records) > 0 && !set.encoded {
 					rb.LastOffsetDelta = int32(len(rb.Records) - 1)
 					for i, record := range rb.Records {
 						record.OffsetDelta = int64(i)
@@ -195,7 +248,7 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 				}
 				compMsg := &Message{
 					Codec:            ps.parent.conf.Producer.Compression,
-					CompressionLevel: ps.parent.conf.Producer.CompressionLevel,
+					CompressionLevel: ps.parent.conf.producerCompressionLevel(),
 					Key:              nil,
 					Value:            payload,
 					Set:              set.recordsToSend.MsgSet, // Provide the underlying message set for accurate metrics
@@ -248,6 +301,10 @@ func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 	case ps.msgs[msg.Topic] != nil && ps.msgs[msg.Topic][msg.Partition] != nil &&
 		ps.msgs[msg.Topic][msg.Partition].bufferBytes+msg.ByteSize(version) >= ps.parent.conf.Producer.MaxMessageBytes:
 		return true
+	// Encoded batches can't share a partition's batch with any other message
+	case ps.msgs[msg.Topic] != nil && ps.msgs[msg.Topic][msg.Partition] != nil &&
+		(msg.EncodedBatch != nil || ps.msgs[msg.Topic][msg.Partition].encoded):
+		return true
 	// Would we overflow simply in number of messages?
 	case ps.parent.conf.Producer.Flush.MaxMessages > 0 && ps.bufferCount >= ps.parent.conf.Producer.Flush.MaxMessages:
 		return true
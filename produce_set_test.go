@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 	"time"
@@ -281,6 +282,101 @@ func TestProduceSetV3RequestBuilding(t *testing.T) {
 	}
 }
 
+func TestProduceSetEncodedBatchRequestBuilding(t *testing.T) {
+	parent, ps := makeProduceSet()
+	parent.conf.Version = V0_11_0_0
+
+	now := time.Now().Truncate(time.Millisecond)
+	original := &RecordBatch{
+		Version:         2,
+		Codec:           CompressionSnappy,
+		FirstOffset:     42,
+		LastOffsetDelta: 1,
+		FirstTimestamp:  now,
+		MaxTimestamp:    now.Add(time.Second),
+		ProducerID:      1000,
+		ProducerEpoch:   3,
+		FirstSequence:   7,
+		Records: []*Record{
+			{Key: []byte("k1"), Value: []byte("v1")},
+			{Value: []byte("v2"), OffsetDelta: 1, TimestampDelta: time.Second},
+		},
+	}
+	encoded, err := encode(original, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &ProducerMessage{Topic: "t1", Partition: 0, EncodedBatch: encoded}
+	safeAddMessage(t, ps, msg)
+
+	if !ps.wouldOverflow(&ProducerMessage{Topic: "t1", Partition: 0, Value: StringEncoder(TestMessage)}) {
+		t.Error("a message must not be added to the partition set of an encoded batch")
+	}
+	if ps.wouldOverflow(&ProducerMessage{Topic: "t1", Partition: 1, Value: StringEncoder(TestMessage)}) {
+		t.Error("encoded batch shouldn't affect other partitions")
+	}
+	if ps.bufferBytes != len(encoded) {
+		t.Errorf("expected %d buffered bytes, got %d", len(encoded), ps.bufferBytes)
+	}
+	if rb := ps.msgs["t1"][0].recordsToSend.RecordBatch; rb.Records != nil || rb.numRecords() != 2 {
+		t.Errorf("expected 2 records left encoded, got %d in %v", rb.numRecords(), rb.Records)
+	}
+
+	req := ps.buildRequest()
+	raw, err := encode(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, original.compressedRecords) {
+		t.Error("encoded records were not sent verbatim")
+	}
+	decoded := new(ProduceRequest)
+	if err := versionedDecode(raw, decoded, req.Version, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := decoded.records["t1"][0].RecordBatch
+	if batch.FirstOffset != 0 || batch.ProducerID != noProducerID || batch.FirstSequence != -1 {
+		t.Errorf("producer owned header fields were not reset: %+v", batch)
+	}
+	if batch.Codec != CompressionSnappy || batch.LastOffsetDelta != 1 ||
+		!batch.FirstTimestamp.Equal(now) || !batch.MaxTimestamp.Equal(now.Add(time.Second)) {
+		t.Errorf("batch header was not preserved: %+v", batch)
+	}
+	if len(batch.Records) != 2 || string(batch.Records[1].Value) != "v2" || batch.Records[1].TimestampDelta != time.Second {
+		t.Errorf("records were not preserved: %+v", batch.Records)
+	}
+}
+
+func TestProduceSetEncodedBatchInvalid(t *testing.T) {
+	_, ps := makeProduceSet()
+	ps.parent.conf.Version = V0_11_0_0
+
+	control, err := encode(&RecordBatch{Version: 2, Control: true, Records: []*Record{{}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt, err := encode(&RecordBatch{Version: 2, Records: []*Record{{Value: []byte("v")}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	for name, encoded := range map[string][]byte{
+		"control":   control,
+		"corrupt":   corrupt,
+		"truncated": control[:20],
+	} {
+		if err := ps.add(&ProducerMessage{Topic: "t1", EncodedBatch: encoded}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if !ps.empty() {
+		t.Error("invalid encoded batches must not be buffered")
+	}
+}
+
 func TestProduceSetIdempotentRequestBuilding(t *testing.T) {
 	const pID = 1000
 	const pEpoch = 1234
@@ -0,0 +1,104 @@
+package sarama
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyProtocol is the protocol of the proxy of Config.Net.Proxy.Addr.
+type ProxyProtocol string
+
+const (
+	// ProxySOCKS5 is a SOCKS5 proxy, with the username/password authentication if
+	// Config.Net.Proxy.User is set.
+	ProxySOCKS5 ProxyProtocol = "socks5"
+	// ProxyHTTPConnect is an HTTP proxy tunneling connections with the CONNECT method, with the
+	// basic authentication if Config.Net.Proxy.User is set.
+	ProxyHTTPConnect ProxyProtocol = "http"
+)
+
+// newProxyDialer returns the dialer connecting through the proxy of the config, forward
+// connecting to the proxy itself.
+func newProxyDialer(conf *Config, forward proxy.Dialer) proxy.Dialer {
+	if conf.Net.Proxy.Protocol == ProxyHTTPConnect {
+		return &httpConnectDialer{
+			addr:     conf.Net.Proxy.Addr,
+			user:     conf.Net.Proxy.User,
+			password: conf.Net.Proxy.Password,
+			timeout:  conf.Net.DialTimeout,
+			forward:  forward,
+		}
+	}
+
+	var auth *proxy.Auth
+	if conf.Net.Proxy.User != "" {
+		auth = &proxy.Auth{User: conf.Net.Proxy.User, Password: conf.Net.Proxy.Password}
+	}
+	// SOCKS5 only fails on invalid networks, which this cannot be.
+	dialer, _ := proxy.SOCKS5("tcp", conf.Net.Proxy.Addr, auth, forward)
+	return dialer
+}
+
+// httpConnectDialer dials addresses through an HTTP proxy with the CONNECT method.
+type httpConnectDialer struct {
+	addr     string
+	user     string
+	password string
+	timeout  time.Duration
+	forward  proxy.Dialer
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := d.forward.Dial(network, d.addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(d.timeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.user != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.user + ":" + d.password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", d.addr, addr, res.Status)
+	}
+	if reader.Buffered() > 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s sent unexpected data after connecting to %s", d.addr, addr)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
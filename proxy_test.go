@@ -0,0 +1,192 @@
+package sarama
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// startTestProxy serves the connections to a proxy listening on a random port with handshake,
+// which returns the address to connect to, then pipes them to that address.
+func startTestProxy(t *testing.T, handshake func(net.Conn) (string, error)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				addr, err := handshake(conn)
+				if err != nil {
+					return
+				}
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go func() { _, _ = io.Copy(target, conn) }()
+				_, _ = io.Copy(conn, target)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func httpConnectHandshake(credentials string) func(net.Conn) (string, error) {
+	return func(conn net.Conn) (string, error) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return "", err
+		}
+		if req.Method != http.MethodConnect {
+			_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+			return "", errors.New("not a CONNECT")
+		}
+		if req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)) {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return "", errors.New("unauthorized")
+		}
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+		return req.Host, nil
+	}
+}
+
+func socks5Handshake(user, password string) func(net.Conn) (string, error) {
+	return func(conn net.Conn) (string, error) {
+		r := bufio.NewReader(conn)
+		greeting := make([]byte, 2)
+		if _, err := io.ReadFull(r, greeting); err != nil {
+			return "", err
+		}
+		if _, err := io.ReadFull(r, make([]byte, greeting[1])); err != nil {
+			return "", err
+		}
+		// username/password authentication
+		if _, err := conn.Write([]byte{5, 2}); err != nil {
+			return "", err
+		}
+		credentials := make([]string, 2)
+		if _, err := r.ReadByte(); err != nil {
+			return "", err
+		}
+		for i := range credentials {
+			n, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return "", err
+			}
+			credentials[i] = string(buf)
+		}
+		if credentials[0] != user || credentials[1] != password {
+			_, _ = conn.Write([]byte{1, 1})
+			return "", errors.New("unauthorized")
+		}
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return "", err
+		}
+
+		// CONNECT to an IPv4 address or a domain name
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return "", err
+		}
+		var host string
+		switch header[3] {
+		case 1:
+			ip := make([]byte, 4)
+			if _, err := io.ReadFull(r, ip); err != nil {
+				return "", err
+			}
+			host = net.IP(ip).String()
+		case 3:
+			n, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			name := make([]byte, n)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return "", err
+			}
+			host = string(name)
+		default:
+			return "", errors.New("unsupported address type")
+		}
+		port := make([]byte, 2)
+		if _, err := io.ReadFull(r, port); err != nil {
+			return "", err
+		}
+		if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+			return "", err
+		}
+		return net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))), nil
+	}
+}
+
+func TestBrokerProxies(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocol  ProxyProtocol
+		handshake func(net.Conn) (string, error)
+	}{
+		{"SOCKS5", ProxySOCKS5, socks5Handshake("user", "secret")},
+		{"HTTP CONNECT", ProxyHTTPConnect, httpConnectHandshake("user:secret")},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mb := NewMockBroker(t, 0)
+			defer mb.Close()
+			mb.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t),
+			})
+
+			conf := NewTestConfig()
+			conf.Net.Proxy.Enable = true
+			conf.Net.Proxy.Protocol = tt.protocol
+			conf.Net.Proxy.Addr = startTestProxy(t, tt.handshake)
+			conf.Net.Proxy.User = "user"
+			conf.Net.Proxy.Password = "secret"
+
+			broker := NewBroker(mb.Addr())
+			if err := broker.Open(conf); err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, broker)
+			if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+				t.Fatal(err)
+			}
+
+			conf = NewTestConfig()
+			conf.Net.Proxy.Enable = true
+			conf.Net.Proxy.Protocol = tt.protocol
+			conf.Net.Proxy.Addr = startTestProxy(t, tt.handshake)
+			conf.Net.Proxy.User = "user"
+			conf.Net.Proxy.Password = "wrong"
+
+			broker = NewBroker(mb.Addr())
+			if err := broker.Open(conf); err != nil {
+				t.Fatal(err)
+			}
+			if connected, err := broker.Connected(); connected || err == nil {
+				t.Error("Expected the proxy to refuse the wrong credentials")
+			}
+		})
+	}
+}
@@ -19,3 +19,19 @@ const (
 	QuotaMatchDefault
 	QuotaMatchAny
 )
+
+// The quota configuration keys of ClientQuotasOp, ref: https://github.com/apache/kafka/blob/trunk/clients/src/main/java/org/apache/kafka/common/config/internals/QuotaConfigs.java
+const (
+	// QuotaProducerByteRate is the bytes per second a user or client may produce.
+	QuotaProducerByteRate = "producer_byte_rate"
+	// QuotaConsumerByteRate is the bytes per second a user or client may fetch.
+	QuotaConsumerByteRate = "consumer_byte_rate"
+	// QuotaRequestPercentage is the percentage of the time of the request handler and
+	// network threads a user or client may use, per thread.
+	QuotaRequestPercentage = "request_percentage"
+	// QuotaControllerMutationRate is the partitions per second a user or client may create
+	// or delete.
+	QuotaControllerMutationRate = "controller_mutation_rate"
+	// QuotaConnectionCreationRate is the connections per second an IP may open.
+	QuotaConnectionCreationRate = "connection_creation_rate"
+)
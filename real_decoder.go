@@ -258,6 +258,10 @@ func (rd *realDecoder) getCompactString() (string, error) {
 	if length < 0 {
 		return "", errInvalidByteSliceLength
 	}
+	if length > rd.remaining() {
+		rd.off = len(rd.raw)
+		return "", ErrInsufficientData
+	}
 	tmpStr := string(rd.raw[rd.off : rd.off+length])
 	rd.off += length
 	return tmpStr, nil
@@ -274,6 +278,10 @@ func (rd *realDecoder) getCompactNullableString() (*string, error) {
 	if length < 0 {
 		return nil, err
 	}
+	if length > rd.remaining() {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
 
 	tmpStr := string(rd.raw[rd.off : rd.off+length])
 	rd.off += length
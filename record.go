@@ -17,6 +17,20 @@ type RecordHeader struct {
 	Value []byte
 }
 
+// replaceHeaders copies headers without the ones of the given keys, then appends the given
+// headers, so that the headers stamped on a message republished again are replaced rather than
+// repeated.
+func replaceHeaders(headers []*RecordHeader, keys []string, appended ...RecordHeader) []RecordHeader {
+	out := make([]RecordHeader, 0, len(headers)+len(appended))
+	for _, header := range headers {
+		if header == nil || stringsContains(keys, string(header.Key)) {
+			continue
+		}
+		out = append(out, *header)
+	}
+	return append(out, appended...)
+}
+
 func (h *RecordHeader) encode(pe packetEncoder) error {
 	if err := pe.putVarintBytes(h.Key); err != nil {
 		return err
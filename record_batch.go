@@ -50,12 +50,27 @@ type RecordBatch struct {
 
 	compressedRecords []byte
 	recordsLen        int // uncompressed records size
+	// encodedRecords is the number of records of a batch submitted already encoded, whose
+	// records are never decoded and Records left nil, see decodeEncodedRecordBatch.
+	encodedRecords int
+
+	// lazyDecompression keeps the records of a compressed batch in their wire form when
+	// decoding it, until decompressRecords is called.
+	lazyDecompression bool
 }
 
 func (b *RecordBatch) LastOffset() int64 {
 	return b.FirstOffset + int64(b.LastOffsetDelta)
 }
 
+// numRecords returns the number of records of the batch, decoded or not.
+func (b *RecordBatch) numRecords() int {
+	if b.Records == nil {
+		return b.encodedRecords
+	}
+	return len(b.Records)
+}
+
 func (b *RecordBatch) encode(pe packetEncoder) error {
 	if b.Version != 2 {
 		return PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", b.Codec)}
@@ -80,7 +95,7 @@ func (b *RecordBatch) encode(pe packetEncoder) error {
 	pe.putInt16(b.ProducerEpoch)
 	pe.putInt32(b.FirstSequence)
 
-	if err := pe.putArrayLength(len(b.Records)); err != nil {
+	if err := pe.putArrayLength(b.numRecords()); err != nil {
 		return err
 	}
 
@@ -180,6 +195,27 @@ func (b *RecordBatch) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if b.lazyDecompression && b.Codec != CompressionNone {
+		// the records are only counted until decompressRecords is called
+		b.compressedRecords = recBuffer
+		return nil
+	}
+
+	return b.decodeRecords(recBuffer)
+}
+
+// decompressRecords decompresses and decodes the records of a batch decoded lazily, which are
+// kept compressed until then. It does nothing if the records were already decoded.
+func (b *RecordBatch) decompressRecords() error {
+	if !b.lazyDecompression || b.compressedRecords == nil {
+		return nil
+	}
+	recBuffer := b.compressedRecords
+	b.compressedRecords = nil
+	return b.decodeRecords(recBuffer)
+}
+
+func (b *RecordBatch) decodeRecords(recBuffer []byte) (err error) {
 	recBuffer, err = decompress(b.Codec, recBuffer)
 	if err != nil {
 		return err
@@ -224,3 +260,95 @@ func (b *RecordBatch) computeAttributes() int16 {
 func (b *RecordBatch) addRecord(r *Record) {
 	b.Records = append(b.Records, r)
 }
+
+// decodeEncodedRecordBatch parses the header of an encoded v2 record batch
+// without decompressing or decoding its records. The records are kept in their
+// encoded form, so re-encoding the batch writes them out verbatim, preserving
+// their compression, timestamps and offset deltas.
+func decodeEncodedRecordBatch(buf []byte) (*RecordBatch, error) {
+	b := &RecordBatch{}
+	pd := &realDecoder{raw: buf}
+
+	var err error
+	if b.FirstOffset, err = pd.getInt64(); err != nil {
+		return nil, err
+	}
+
+	batchLen, err := pd.getInt32()
+	if err != nil {
+		return nil, err
+	}
+	if int(batchLen) != pd.remaining() {
+		return nil, PacketDecodingError{fmt.Sprintf("record batch length %d does not match encoded size %d", batchLen, pd.remaining())}
+	}
+
+	if b.PartitionLeaderEpoch, err = pd.getInt32(); err != nil {
+		return nil, err
+	}
+
+	if b.Version, err = pd.getInt8(); err != nil {
+		return nil, err
+	}
+	if b.Version != 2 {
+		return nil, PacketDecodingError{fmt.Sprintf("unsupported record batch version (%d)", b.Version)}
+	}
+
+	crc32Decoder := acquireCrc32Field(crcCastagnoli)
+	defer releaseCrc32Field(crc32Decoder)
+
+	if err = pd.push(crc32Decoder); err != nil {
+		return nil, err
+	}
+
+	attributes, err := pd.getInt16()
+	if err != nil {
+		return nil, err
+	}
+	b.Codec = CompressionCodec(int8(attributes) & compressionCodecMask)
+	b.Control = attributes&controlMask == controlMask
+	b.LogAppendTime = attributes&timestampTypeMask == timestampTypeMask
+	b.IsTransactional = attributes&isTransactionalMask == isTransactionalMask
+
+	if b.LastOffsetDelta, err = pd.getInt32(); err != nil {
+		return nil, err
+	}
+
+	if err = (Timestamp{&b.FirstTimestamp}).decode(pd); err != nil {
+		return nil, err
+	}
+
+	if err = (Timestamp{&b.MaxTimestamp}).decode(pd); err != nil {
+		return nil, err
+	}
+
+	if b.ProducerID, err = pd.getInt64(); err != nil {
+		return nil, err
+	}
+
+	if b.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return nil, err
+	}
+
+	if b.FirstSequence, err = pd.getInt32(); err != nil {
+		return nil, err
+	}
+
+	numRecs, err := pd.getArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	if numRecs <= 0 {
+		return nil, PacketDecodingError{"record batch contains no records"}
+	}
+	b.encodedRecords = numRecs
+
+	if b.compressedRecords, err = pd.getRawBytes(pd.remaining()); err != nil {
+		return nil, err
+	}
+
+	if err = pd.pop(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -15,6 +15,8 @@ type Records struct {
 	recordsType int
 	MsgSet      *MessageSet
 	RecordBatch *RecordBatch
+
+	lazyDecompression bool
 }
 
 func newLegacyRecords(msgSet *MessageSet) Records {
@@ -91,7 +93,7 @@ func (r *Records) decode(pd packetDecoder) error {
 		r.MsgSet = &MessageSet{}
 		return r.MsgSet.decode(pd)
 	case defaultRecords:
-		r.RecordBatch = &RecordBatch{}
+		r.RecordBatch = &RecordBatch{lazyDecompression: r.lazyDecompression}
 		return r.RecordBatch.decode(pd)
 	}
 	return fmt.Errorf("unknown records type: %v", r.recordsType)
@@ -114,7 +116,7 @@ func (r *Records) numRecords() (int, error) {
 		if r.RecordBatch == nil {
 			return 0, nil
 		}
-		return len(r.RecordBatch.Records), nil
+		return r.RecordBatch.numRecords(), nil
 	}
 	return 0, fmt.Errorf("unknown records type: %v", r.recordsType)
 }
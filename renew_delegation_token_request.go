@@ -0,0 +1,56 @@
+package sarama
+
+import "time"
+
+// RenewDelegationTokenRequest extends the expiry time of a delegation token.
+type RenewDelegationTokenRequest struct {
+	// Version 0 and 1 are equal
+	// The version number is bumped to indicate that on quota violation brokers send out responses before throttling.
+	Version int16
+	HMAC    []byte
+	// RenewPeriod is how long from now the token expires, capped by its maximum lifetime, or a
+	// negative duration to use the delegation.token.expiry.time.ms of the broker.
+	RenewPeriod time.Duration
+}
+
+func (r *RenewDelegationTokenRequest) encode(pe packetEncoder) error {
+	if err := pe.putBytes(r.HMAC); err != nil {
+		return err
+	}
+	pe.putInt64(durationToMillis(r.RenewPeriod))
+	return nil
+}
+
+func (r *RenewDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.HMAC, err = pd.getBytes(); err != nil {
+		return err
+	}
+	period, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.RenewPeriod = millisToDuration(period)
+	return nil
+}
+
+func (r *RenewDelegationTokenRequest) key() int16 {
+	return 39
+}
+
+func (r *RenewDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *RenewDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *RenewDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
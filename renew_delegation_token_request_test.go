@@ -0,0 +1,16 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var renewDelegationTokenRequest = []byte{
+	0, 0, 0, 3, 1, 2, 3, // HMAC
+	0, 0, 0, 0, 0, 0, 0x03, 0xe8, // renew period
+}
+
+func TestRenewDelegationTokenRequest(t *testing.T) {
+	request := &RenewDelegationTokenRequest{HMAC: []byte{1, 2, 3}, RenewPeriod: time.Second}
+	testRequest(t, "renew", request, renewDelegationTokenRequest)
+}
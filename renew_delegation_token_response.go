@@ -0,0 +1,63 @@
+package sarama
+
+import "time"
+
+// RenewDelegationTokenResponse is the new expiry time of the token of a RenewDelegationTokenRequest.
+type RenewDelegationTokenResponse struct {
+	Version      int16
+	Err          KError
+	ExpiryTime   time.Time
+	ThrottleTime time.Duration
+}
+
+func (r *RenewDelegationTokenResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	pe.putInt64(timeToMillis(r.ExpiryTime))
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return nil
+}
+
+func (r *RenewDelegationTokenResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+	expiry, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.ExpiryTime = millisToTime(expiry)
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+	return nil
+}
+
+func (r *RenewDelegationTokenResponse) key() int16 {
+	return 39
+}
+
+func (r *RenewDelegationTokenResponse) version() int16 {
+	return r.Version
+}
+
+func (r *RenewDelegationTokenResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *RenewDelegationTokenResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
+
+func (r *RenewDelegationTokenResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,17 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var renewDelegationTokenResponse = []byte{
+	0, 0, // no error
+	0, 0, 0, 0, 0, 0, 0x07, 0xd0, // expiry time
+	0, 0, 0, 0, // throttle time
+}
+
+func TestRenewDelegationTokenResponse(t *testing.T) {
+	response := &RenewDelegationTokenResponse{ExpiryTime: time.Unix(2, 0)}
+	testResponse(t, "renewed", response, renewDelegationTokenResponse)
+}
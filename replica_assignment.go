@@ -0,0 +1,144 @@
+package sarama
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReplicaAssignmentOptions are the constraints of the assignments generated by
+// ClusterAdmin.GenerateReplicaAssignment.
+type ReplicaAssignmentOptions struct {
+	// ExcludeBrokers are the brokers no replica is assigned to, for example brokers being
+	// decommissioned.
+	ExcludeBrokers []int32
+	// PreferredLeaderRacks are the racks the leaders, that is the first replicas, of the
+	// partitions are assigned to, for example the racks closest to producers. The followers
+	// are still spread across all the racks.
+	PreferredLeaderRacks []string
+	// StartPartition is the ID of the first partition to assign, the number of partitions of
+	// the topic when adding partitions to it, so that leaders keep being spread evenly.
+	StartPartition int32
+}
+
+// SetReplicaAssignment sets the replicas of the partitions of the topic, by partition ID, as
+// generated by ClusterAdmin.GenerateReplicaAssignment. The number of partitions and the
+// replication factor, which must not be set along with an assignment, are set to -1.
+func (t *TopicDetail) SetReplicaAssignment(assignment [][]int32) {
+	t.NumPartitions, t.ReplicationFactor = -1, -1
+	t.ReplicaAssignment = make(map[int32][]int32, len(assignment))
+	for partition, replicas := range assignment {
+		t.ReplicaAssignment[int32(partition)] = replicas
+	}
+}
+
+// assignReplicas assigns the replicas of numPartitions partitions to the brokers. The brokers
+// are ordered by alternating racks, the leader of each partition being the next broker in
+// that order, and its followers are taken from the racks without a replica of the partition
+// first, with a shift growing with each round over the brokers so that the same brokers do
+// not always follow each other. Brokers without rack are treated as one rack.
+func assignReplicas(brokers []*Broker, numPartitions int32, replicationFactor int16, options *ReplicaAssignmentOptions) ([][]int32, error) {
+	if options == nil {
+		options = &ReplicaAssignmentOptions{}
+	}
+	if numPartitions <= 0 {
+		return nil, ConfigurationError("the number of partitions must be positive")
+	}
+	if replicationFactor <= 0 {
+		return nil, ConfigurationError("the replication factor must be positive")
+	}
+
+	excluded := make(map[int32]bool, len(options.ExcludeBrokers))
+	for _, id := range options.ExcludeBrokers {
+		excluded[id] = true
+	}
+	byRack := make(map[string][]int32)
+	rackOf := make(map[int32]string)
+	for _, b := range brokers {
+		if excluded[b.ID()] {
+			continue
+		}
+		byRack[b.Rack()] = append(byRack[b.Rack()], b.ID())
+		rackOf[b.ID()] = b.Rack()
+	}
+	if int(replicationFactor) > len(rackOf) {
+		return nil, fmt.Errorf("%w: replication factor %d larger than the %d available brokers",
+			ErrInvalidReplicationFactor, replicationFactor, len(rackOf))
+	}
+
+	arranged := arrangeBrokersByRack(byRack)
+	position := make(map[int32]int, len(arranged))
+	for i, id := range arranged {
+		position[id] = i
+	}
+
+	leaders := arranged
+	if len(options.PreferredLeaderRacks) > 0 {
+		preferred := make(map[string]bool, len(options.PreferredLeaderRacks))
+		for _, rack := range options.PreferredLeaderRacks {
+			preferred[rack] = true
+		}
+		leaders = nil
+		for _, id := range arranged {
+			if preferred[rackOf[id]] {
+				leaders = append(leaders, id)
+			}
+		}
+		if len(leaders) == 0 {
+			return nil, ConfigurationError("no available broker in the preferred leader racks")
+		}
+	}
+
+	n := len(arranged)
+	assignment := make([][]int32, numPartitions)
+	for i := range assignment {
+		partition := int(options.StartPartition) + i
+		leader := leaders[partition%len(leaders)]
+		replicas := []int32{leader}
+		assigned := map[int32]bool{leader: true}
+		racks := map[string]bool{rackOf[leader]: true}
+
+		shift := 1
+		if n > 1 {
+			shift += (partition / n) % (n - 1)
+		}
+		// first the brokers of racks without a replica, then any broker
+		for _, spreadRacks := range []bool{true, false} {
+			for j := 0; j < n && len(replicas) < int(replicationFactor); j++ {
+				id := arranged[(position[leader]+shift+j)%n]
+				if assigned[id] || (spreadRacks && racks[rackOf[id]]) {
+					continue
+				}
+				replicas = append(replicas, id)
+				assigned[id] = true
+				racks[rackOf[id]] = true
+			}
+		}
+		assignment[i] = replicas
+	}
+	return assignment, nil
+}
+
+// arrangeBrokersByRack orders the brokers by alternating racks, such as the first broker of
+// each rack, then the second one of each rack, and so on, racks and brokers being sorted.
+func arrangeBrokersByRack(byRack map[string][]int32) []int32 {
+	racks := make([]string, 0, len(byRack))
+	longest := 0
+	for rack, ids := range byRack {
+		racks = append(racks, rack)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if len(ids) > longest {
+			longest = len(ids)
+		}
+	}
+	sort.Strings(racks)
+
+	var arranged []int32
+	for i := 0; i < longest; i++ {
+		for _, rack := range racks {
+			if i < len(byRack[rack]) {
+				arranged = append(arranged, byRack[rack][i])
+			}
+		}
+	}
+	return arranged
+}
@@ -0,0 +1,121 @@
+package sarama
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testRackBrokers(racks map[int32]string) []*Broker {
+	var brokers []*Broker
+	for id, rack := range racks {
+		rack := rack
+		brokers = append(brokers, &Broker{id: id, rack: &rack})
+	}
+	return brokers
+}
+
+func TestAssignReplicasRackAware(t *testing.T) {
+	racks := map[int32]string{1: "a", 2: "b", 3: "c", 4: "a", 5: "b", 6: "c"}
+	assignment, err := assignReplicas(testRackBrokers(racks), 12, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignment) != 12 {
+		t.Fatalf("Expected 12 partitions, got %v", assignment)
+	}
+
+	leaders := make(map[int32]int)
+	replicas := make(map[int32]int)
+	for partition, ids := range assignment {
+		if len(ids) != 3 {
+			t.Fatalf("Expected 3 replicas for partition %d, got %v", partition, ids)
+		}
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if seen[racks[id]] {
+				t.Errorf("Partition %d has several replicas in rack %s: %v", partition, racks[id], ids)
+			}
+			seen[racks[id]] = true
+			replicas[id]++
+		}
+		leaders[ids[0]]++
+	}
+	for id := range racks {
+		if leaders[id] != 2 || replicas[id] != 6 {
+			t.Errorf("Broker %d leads %d partitions and has %d replicas, expected 2 and 6", id, leaders[id], replicas[id])
+		}
+	}
+}
+
+func TestAssignReplicasStartPartition(t *testing.T) {
+	brokers := testRackBrokers(map[int32]string{1: "a", 2: "b", 3: "a", 4: "b"})
+	all, err := assignReplicas(brokers, 8, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := assignReplicas(brokers, 5, 2, &ReplicaAssignmentOptions{StartPartition: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all[3:], added) {
+		t.Errorf("Expected %v, got %v", all[3:], added)
+	}
+}
+
+func TestAssignReplicasConstraints(t *testing.T) {
+	racks := map[int32]string{1: "a", 2: "b", 3: "c", 4: "a", 5: "b", 6: "c"}
+	assignment, err := assignReplicas(testRackBrokers(racks), 6, 2, &ReplicaAssignmentOptions{
+		ExcludeBrokers:       []int32{5},
+		PreferredLeaderRacks: []string{"b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for partition, ids := range assignment {
+		if ids[0] != 2 {
+			t.Errorf("Expected broker 2 to lead partition %d, got %v", partition, ids)
+		}
+		for _, id := range ids {
+			if id == 5 {
+				t.Errorf("Excluded broker 5 assigned to partition %d", partition)
+			}
+		}
+	}
+
+	_, err = assignReplicas(testRackBrokers(racks), 1, 3, &ReplicaAssignmentOptions{ExcludeBrokers: []int32{1, 2, 3, 4}})
+	if !errors.Is(err, ErrInvalidReplicationFactor) {
+		t.Errorf("Expected ErrInvalidReplicationFactor, got %v", err)
+	}
+
+	var target ConfigurationError
+	_, err = assignReplicas(testRackBrokers(racks), 1, 1, &ReplicaAssignmentOptions{PreferredLeaderRacks: []string{"d"}})
+	if !errors.As(err, &target) {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestAssignReplicasWithoutRacks(t *testing.T) {
+	brokers := []*Broker{{id: 3}, {id: 1}, {id: 2}}
+	assignment, err := assignReplicas(brokers, 3, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int32{{1, 2, 3}, {2, 3, 1}, {3, 1, 2}}
+	if !reflect.DeepEqual(assignment, expected) {
+		t.Errorf("Expected %v, got %v", expected, assignment)
+	}
+}
+
+func TestTopicDetailSetReplicaAssignment(t *testing.T) {
+	detail := &TopicDetail{NumPartitions: 2, ReplicationFactor: 2}
+	detail.SetReplicaAssignment([][]int32{{1, 2}, {2, 1}})
+	expected := &TopicDetail{
+		NumPartitions:     -1,
+		ReplicationFactor: -1,
+		ReplicaAssignment: map[int32][]int32{0: {1, 2}, 1: {2, 1}},
+	}
+	if !reflect.DeepEqual(detail, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, detail)
+	}
+}
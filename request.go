@@ -74,9 +74,7 @@ func (r *request) decode(pd packetDecoder) (err error) {
 	}
 
 	if r.body.headerVersion() >= 2 {
-		// tagged field
-		_, err = pd.getUVarint()
-		if err != nil {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
 			return err
 		}
 	}
@@ -143,7 +141,7 @@ func allocateBody(key, version int16) protocolBody {
 	case 15:
 		return &DescribeGroupsRequest{}
 	case 16:
-		return &ListGroupsRequest{}
+		return &ListGroupsRequest{Version: version}
 	case 17:
 		return &SaslHandshakeRequest{}
 	case 18:
@@ -162,6 +160,8 @@ func allocateBody(key, version int16) protocolBody {
 		return &AddOffsetsToTxnRequest{}
 	case 26:
 		return &EndTxnRequest{}
+	case 27:
+		return &WriteTxnMarkersRequest{}
 	case 28:
 		return &TxnOffsetCommitRequest{}
 	case 29:
@@ -174,14 +174,26 @@ func allocateBody(key, version int16) protocolBody {
 		return &DescribeConfigsRequest{}
 	case 33:
 		return &AlterConfigsRequest{}
+	case 34:
+		return &AlterReplicaLogDirsRequest{Version: version}
 	case 35:
 		return &DescribeLogDirsRequest{}
 	case 36:
 		return &SaslAuthenticateRequest{}
 	case 37:
 		return &CreatePartitionsRequest{}
+	case 38:
+		return &CreateDelegationTokenRequest{}
+	case 39:
+		return &RenewDelegationTokenRequest{}
+	case 40:
+		return &ExpireDelegationTokenRequest{}
+	case 41:
+		return &DescribeDelegationTokenRequest{}
 	case 42:
 		return &DeleteGroupsRequest{}
+	case 43:
+		return &ElectLeadersRequest{}
 	case 44:
 		return &IncrementalAlterConfigsRequest{}
 	case 45:
@@ -198,6 +210,20 @@ func allocateBody(key, version int16) protocolBody {
 		return &DescribeUserScramCredentialsRequest{}
 	case 51:
 		return &AlterUserScramCredentialsRequest{}
+	case 55:
+		return &DescribeQuorumRequest{}
+	case 57:
+		return &UpdateFeaturesRequest{}
+	case 60:
+		return &DescribeClusterRequest{}
+	case 61:
+		return &DescribeProducersRequest{}
+	case 64:
+		return &UnregisterBrokerRequest{}
+	case 65:
+		return &DescribeTransactionsRequest{}
+	case 66:
+		return &ListTransactionsRequest{}
 	}
 	return nil
 }
@@ -0,0 +1,193 @@
+package sarama
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Header keys stamped on messages republished by a RetryPublisher.
+const (
+	// RetryAttemptHeader holds the number of times the message has been retried, as a decimal string.
+	RetryAttemptHeader = "sarama-retry-attempt"
+	// RetryNotBeforeHeader holds the earliest time, in milliseconds since the Unix epoch, at which the
+	// message should be processed again.
+	RetryNotBeforeHeader = "sarama-retry-not-before"
+	// RetryOriginalTopicHeader holds the topic the message was first consumed from.
+	RetryOriginalTopicHeader = "sarama-retry-original-topic"
+	// RetryErrorHeader holds the error that caused the message to be retried.
+	RetryErrorHeader = "sarama-retry-error"
+)
+
+// ErrRetriesExhausted is returned by RetryPublisher.Retry when a message has been through every retry
+// tier and no dead letter topic is configured.
+var ErrRetriesExhausted = errors.New("kafka: message has exhausted all retry tiers")
+
+// RetryTier is one level of a tiered retry topology: messages published to Topic should not be
+// processed again until Delay has elapsed.
+type RetryTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// RetryTopicName returns the conventional name of the retry topic for the given base topic and
+// delay, e.g. "orders.retry.5m" or "orders.retry.1h".
+func RetryTopicName(topic string, delay time.Duration) string {
+	var suffix string
+	switch {
+	case delay >= time.Hour && delay%time.Hour == 0:
+		suffix = fmt.Sprintf("%dh", delay/time.Hour)
+	case delay >= time.Minute && delay%time.Minute == 0:
+		suffix = fmt.Sprintf("%dm", delay/time.Minute)
+	case delay >= time.Second && delay%time.Second == 0:
+		suffix = fmt.Sprintf("%ds", delay/time.Second)
+	default:
+		suffix = fmt.Sprintf("%dms", delay/time.Millisecond)
+	}
+	return topic + ".retry." + suffix
+}
+
+// NewRetryTiers builds the retry tiers for a topic using RetryTopicName, one per delay.
+func NewRetryTiers(topic string, delays ...time.Duration) []RetryTier {
+	tiers := make([]RetryTier, len(delays))
+	for i, delay := range delays {
+		tiers[i] = RetryTier{Topic: RetryTopicName(topic, delay), Delay: delay}
+	}
+	return tiers
+}
+
+// RetryMetadata is the retry state carried in the headers of a message.
+type RetryMetadata struct {
+	// Attempt is the number of times the message has been retried, zero if it never was.
+	Attempt int
+	// NotBefore is the earliest time the message should be processed, zero if unset.
+	NotBefore time.Time
+	// OriginalTopic is the topic the message was first consumed from, empty if unset.
+	OriginalTopic string
+}
+
+// ParseRetryMetadata extracts the retry state stamped by a RetryPublisher from the headers of a
+// consumed message.
+func ParseRetryMetadata(msg *ConsumerMessage) (RetryMetadata, error) {
+	var meta RetryMetadata
+	for _, header := range msg.Headers {
+		if header == nil {
+			continue
+		}
+		switch string(header.Key) {
+		case RetryAttemptHeader:
+			attempt, err := strconv.Atoi(string(header.Value))
+			if err != nil {
+				return meta, fmt.Errorf("kafka: invalid %s header: %w", RetryAttemptHeader, err)
+			}
+			meta.Attempt = attempt
+		case RetryNotBeforeHeader:
+			millis, err := strconv.ParseInt(string(header.Value), 10, 64)
+			if err != nil {
+				return meta, fmt.Errorf("kafka: invalid %s header: %w", RetryNotBeforeHeader, err)
+			}
+			meta.NotBefore = time.Unix(millis/1000, (millis%1000)*int64(time.Millisecond))
+		case RetryOriginalTopicHeader:
+			meta.OriginalTopic = string(header.Value)
+		}
+	}
+	return meta, nil
+}
+
+// RetryPublisher republishes messages that failed processing to a series of tiered retry topics,
+// stamping each with the attempt count and the time at which it becomes eligible for processing
+// again. Once a message has been through every tier it is sent to the dead letter topic, if any.
+type RetryPublisher struct {
+	producer        SyncProducer
+	tiers           []RetryTier
+	deadLetterTopic string
+	now             func() time.Time
+}
+
+// NewRetryPublisher creates a RetryPublisher sending through the given producer. Tiers are used in
+// order, the first retry going to tiers[0]. If deadLetterTopic is empty, messages that exhausted
+// all tiers are not republished and Retry returns ErrRetriesExhausted.
+func NewRetryPublisher(producer SyncProducer, tiers []RetryTier, deadLetterTopic string) (*RetryPublisher, error) {
+	if producer == nil {
+		return nil, ConfigurationError("RetryPublisher requires a non-nil producer")
+	}
+	if len(tiers) == 0 {
+		return nil, ConfigurationError("RetryPublisher requires at least one retry tier")
+	}
+	for _, tier := range tiers {
+		if tier.Topic == "" {
+			return nil, ConfigurationError("RetryTier.Topic must not be empty")
+		}
+		if tier.Delay < 0 {
+			return nil, ConfigurationError("RetryTier.Delay must be >= 0")
+		}
+	}
+	return &RetryPublisher{
+		producer:        producer,
+		tiers:           tiers,
+		deadLetterTopic: deadLetterTopic,
+		now:             time.Now,
+	}, nil
+}
+
+// Retry republishes msg to the next retry tier, or to the dead letter topic once all tiers are
+// exhausted, and returns the topic it was sent to. The cause, if not nil, is recorded in the
+// RetryErrorHeader header.
+func (rp *RetryPublisher) Retry(msg *ConsumerMessage, cause error) (string, error) {
+	meta, err := ParseRetryMetadata(msg)
+	if err != nil {
+		return "", err
+	}
+	if meta.OriginalTopic == "" {
+		meta.OriginalTopic = msg.Topic
+	}
+	meta.Attempt++
+
+	var topic string
+	if meta.Attempt <= len(rp.tiers) {
+		tier := rp.tiers[meta.Attempt-1]
+		topic = tier.Topic
+		meta.NotBefore = rp.now().Add(tier.Delay)
+	} else if rp.deadLetterTopic != "" {
+		topic = rp.deadLetterTopic
+		meta.NotBefore = time.Time{}
+	} else {
+		return "", ErrRetriesExhausted
+	}
+
+	out := &ProducerMessage{
+		Topic:   topic,
+		Headers: retryHeaders(msg.Headers, meta, cause),
+	}
+	if msg.Key != nil {
+		out.Key = ByteEncoder(msg.Key)
+	}
+	if msg.Value != nil {
+		out.Value = ByteEncoder(msg.Value)
+	}
+
+	if _, _, err := rp.producer.SendMessage(out); err != nil {
+		return "", err
+	}
+	return topic, nil
+}
+
+var retryHeaderKeys = []string{RetryAttemptHeader, RetryNotBeforeHeader, RetryOriginalTopicHeader, RetryErrorHeader}
+
+// retryHeaders copies the headers of a consumed message, replacing any previous retry headers with
+// the given retry state.
+func retryHeaders(headers []*RecordHeader, meta RetryMetadata, cause error) []RecordHeader {
+	appended := []RecordHeader{
+		{Key: []byte(RetryAttemptHeader), Value: []byte(strconv.Itoa(meta.Attempt))},
+		{Key: []byte(RetryOriginalTopicHeader), Value: []byte(meta.OriginalTopic)},
+	}
+	if !meta.NotBefore.IsZero() {
+		millis := meta.NotBefore.UnixNano() / int64(time.Millisecond)
+		appended = append(appended, RecordHeader{Key: []byte(RetryNotBeforeHeader), Value: []byte(strconv.FormatInt(millis, 10))})
+	}
+	if cause != nil {
+		appended = append(appended, RecordHeader{Key: []byte(RetryErrorHeader), Value: []byte(cause.Error())})
+	}
+	return replaceHeaders(headers, retryHeaderKeys, appended...)
+}
@@ -0,0 +1,109 @@
+package sarama
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordingSyncProducer struct {
+	SyncProducer
+	sent []*ProducerMessage
+}
+
+func (p *recordingSyncProducer) SendMessage(msg *ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, msg)
+	return 0, int64(len(p.sent)), nil
+}
+
+func TestRetryTopicName(t *testing.T) {
+	for delay, expected := range map[time.Duration]string{
+		5 * time.Minute:        "orders.retry.5m",
+		time.Hour:              "orders.retry.1h",
+		90 * time.Second:       "orders.retry.90s",
+		250 * time.Millisecond: "orders.retry.250ms",
+	} {
+		if name := RetryTopicName("orders", delay); name != expected {
+			t.Errorf("expected %s for %v, got %s", expected, delay, name)
+		}
+	}
+}
+
+func TestRetryPublisher(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	rp, err := NewRetryPublisher(producer, NewRetryTiers("orders", 5*time.Minute, time.Hour), "orders.dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	rp.now = func() time.Time { return now }
+
+	msg := &ConsumerMessage{
+		Topic: "orders",
+		Key:   []byte("key"),
+		Value: []byte("value"),
+		Headers: []*RecordHeader{
+			{Key: []byte("trace-id"), Value: []byte("abc")},
+		},
+	}
+
+	expected := []struct {
+		topic     string
+		notBefore time.Time
+	}{
+		{"orders.retry.5m", now.Add(5 * time.Minute)},
+		{"orders.retry.1h", now.Add(time.Hour)},
+		{"orders.dlq", time.Time{}},
+	}
+	for i, want := range expected {
+		topic, err := rp.Retry(msg, errors.New("boom"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if topic != want.topic {
+			t.Errorf("attempt %d: expected topic %s, got %s", i+1, want.topic, topic)
+		}
+
+		// feed the republished message back as if it had been consumed from the retry topic
+		sent := producer.sent[len(producer.sent)-1]
+		msg = &ConsumerMessage{Topic: sent.Topic}
+		msg.Key, _ = sent.Key.Encode()
+		msg.Value, _ = sent.Value.Encode()
+		for j := range sent.Headers {
+			msg.Headers = append(msg.Headers, &sent.Headers[j])
+		}
+
+		meta, err := ParseRetryMetadata(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Attempt != i+1 || meta.OriginalTopic != "orders" || !meta.NotBefore.Equal(want.notBefore) {
+			t.Errorf("attempt %d: unexpected retry metadata %+v", i+1, meta)
+		}
+		if string(msg.Key) != "key" || string(msg.Value) != "value" {
+			t.Errorf("attempt %d: key or value not preserved", i+1)
+		}
+		if len(msg.Headers) == 0 || string(msg.Headers[0].Key) != "trace-id" {
+			t.Errorf("attempt %d: original headers not preserved", i+1)
+		}
+	}
+}
+
+func TestRetryPublisherExhausted(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	rp, err := NewRetryPublisher(producer, NewRetryTiers("orders", time.Minute), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &ConsumerMessage{
+		Topic:   "orders.retry.1m",
+		Headers: []*RecordHeader{{Key: []byte(RetryAttemptHeader), Value: []byte("1")}},
+	}
+	if _, err := rp.Retry(msg, nil); !errors.Is(err, ErrRetriesExhausted) {
+		t.Errorf("expected ErrRetriesExhausted, got %v", err)
+	}
+	if len(producer.sent) != 0 {
+		t.Errorf("expected nothing to be sent, got %d messages", len(producer.sent))
+	}
+}
@@ -43,6 +43,12 @@ Broker related metrics:
 	|                                                         |            | for all brokers                                               |
 	| requests-in-flight-for-broker-<broker-id>               | counter    | The current number of in-flight requests awaiting a response  |
 	|                                                         |            | for a given broker                                            |
+	| throttle-time-in-ms                                     | histogram  | Distribution of the throttle time in ms of the throttled      |
+	|                                                         |            | responses for all brokers                                     |
+	| throttle-time-in-ms-<api-key>                           | histogram  | Distribution of the throttle time in ms of the throttled      |
+	|                                                         |            | responses by api-key for all brokers                          |
+	| throttle-time-in-ms-for-broker-<broker-id>              | histogram  | Distribution of the throttle time in ms of the throttled      |
+	|                                                         |            | responses for a given broker                                  |
 	| protocol-requests-rate-<api-key>          	          | meter      | Number of api requests sent to the brokers for all brokers    |
 	|                                                         |            | https://kafka.apache.org/protocol.html#protocol_api_keys      |                                        |
 	| protocol-requests-rate-<api-key>-for-broker-<broker-id> | meter      | Number of packets sent to the brokers by api-key for a given  |
@@ -68,19 +74,23 @@ Producer related metrics:
 
 Consumer related metrics:
 
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
-	| Name                                      | Type       | Description                                                                          |
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
-	| consumer-batch-size                       | histogram  | Distribution of the number of messages in a batch                                    |
-	| consumer-fetch-rate                       | meter      | Fetch requests/second sent to all brokers                                            |
-	| consumer-fetch-rate-for-broker-<broker>   | meter      | Fetch requests/second sent to a given broker                                         |
-	| consumer-fetch-rate-for-topic-<topic>     | meter      | Fetch requests/second sent for a given topic                                         |
-	| consumer-fetch-response-size              | histogram  | Distribution of the fetch response size in bytes                                     |
-	| consumer-group-join-total-<GroupID>       | counter    | Total count of consumer group join attempts                                          |
-	| consumer-group-join-failed-<GroupID>      | counter    | Total count of consumer group join failures                                          |
-	| consumer-group-sync-total-<GroupID>       | counter    | Total count of consumer group sync attempts                                          |
-	| consumer-group-sync-failed-<GroupID>      | counter    | Total count of consumer group sync failures                                          |
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
+	+------------------------------------------------------------------------+-----------+--------------------------------------------------------------------------+
+	| Name                                                                   | Type      | Description                                                              |
+	+------------------------------------------------------------------------+-----------+--------------------------------------------------------------------------+
+	| consumer-batch-size                                                    | histogram | Distribution of the number of messages in a batch                        |
+	| consumer-fetch-rate                                                    | meter     | Fetch requests/second sent to all brokers                                |
+	| consumer-fetch-rate-for-broker-<broker>                                | meter     | Fetch requests/second sent to a given broker                             |
+	| consumer-fetch-rate-for-topic-<topic>                                  | meter     | Fetch requests/second sent for a given topic                             |
+	| consumer-fetch-response-size                                           | histogram | Distribution of the fetch response size in bytes                         |
+	| consumer-fetch-latency-in-ms-for-topic-<topic>-partition-<partition>   | histogram | Distribution of the fetch latency in ms for a given partition            |
+	| consumer-lag-for-topic-<topic>-partition-<partition>                   | gauge     | Messages between the end of a given partition and the next delivered one |
+	| consumer-records-consumed-rate-for-topic-<topic>-partition-<partition> | meter     | Messages/second delivered for a given partition                          |
+	| consumer-group-join-total-<GroupID>                                    | counter   | Total count of consumer group join attempts                              |
+	| consumer-group-join-failed-<GroupID>                                   | counter   | Total count of consumer group join failures                              |
+	| consumer-group-sync-total-<GroupID>                                    | counter   | Total count of consumer group sync attempts                              |
+	| consumer-group-sync-failed-<GroupID>                                   | counter   | Total count of consumer group sync failures                              |
+	| consumer-group-lag-<GroupID>                                           | gauge     | Sum of the lags of the partitions consumed by the group member           |
+	+------------------------------------------------------------------------+-----------+--------------------------------------------------------------------------+
 */
 package sarama
 
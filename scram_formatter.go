@@ -2,11 +2,20 @@ package sarama
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/sha512"
 	"hash"
 )
 
+const (
+	// scramMinIterations is the minimum number of iterations brokers accept for both
+	// mechanisms, the default of upserted credentials.
+	scramMinIterations = 4096
+	// scramSaltLength is the length of the salts generated for upserted credentials.
+	scramSaltLength = 32
+)
+
 // ScramFormatter implementation
 // @see: https://github.com/apache/kafka/blob/99b9b3e84f4e98c3f07714e1de6a139a004cbc5b/clients/src/main/java/org/apache/kafka/common/security/scram/internals/ScramFormatter.java#L93
 type scramFormatter struct {
@@ -76,3 +85,12 @@ func (s scramFormatter) saltedPassword(password []byte, salt []byte, iterations
 
 	return result, nil
 }
+
+// newScramSalt returns a random salt.
+func newScramSalt() ([]byte, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
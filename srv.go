@@ -0,0 +1,48 @@
+package sarama
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SRVResolver looks up DNS SRV records, see Config.Net.SRV. It is implemented by *net.Resolver.
+type SRVResolver interface {
+	// LookupSRV looks up the SRV records of the name, as net.Resolver.LookupSRV with empty
+	// service and proto.
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// resolveSRV returns the host:port addresses of the targets of the SRV records of the names,
+// ordered by priority and weight.
+func resolveSRV(conf *Config, names []string) ([]string, error) {
+	resolver := conf.Net.SRV.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Net.SRV.Timeout)
+	defer cancel()
+
+	var addrs []string
+	seen := make(map[string]none)
+	for _, name := range names {
+		_, records, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			addr := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = none{}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("kafka: no broker found in the SRV records of %s", strings.Join(names, ", "))
+	}
+	return addrs, nil
+}
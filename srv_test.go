@@ -0,0 +1,81 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testSRVResolver resolves the SRV names to the records it is given.
+type testSRVResolver struct {
+	lock    sync.Mutex
+	records map[string][]*net.SRV
+}
+
+func (r *testSRVResolver) set(name string, records ...*net.SRV) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.records == nil {
+		r.records = make(map[string][]*net.SRV)
+	}
+	r.records[name] = records
+}
+
+func (r *testSRVResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	records, ok := r.records[name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, records, nil
+}
+
+// srvRecord returns an SRV record targeting the host:port address.
+func srvRecord(t *testing.T, addr string) *net.SRV {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := net.LookupPort("tcp", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &net.SRV{Target: host + ".", Port: uint16(p)}
+}
+
+func TestResolveSRV(t *testing.T) {
+	resolver := &testSRVResolver{}
+	resolver.set("_kafka._tcp.a.internal",
+		&net.SRV{Target: "broker-1.a.internal.", Port: 9092},
+		&net.SRV{Target: "broker-2.a.internal.", Port: 9092})
+	resolver.set("_kafka._tcp.b.internal",
+		&net.SRV{Target: "broker-2.a.internal.", Port: 9092},
+		&net.SRV{Target: "broker-3.b.internal.", Port: 9093})
+	resolver.set("_kafka._tcp.empty.internal")
+
+	conf := NewTestConfig()
+	conf.Net.SRV.Enable = true
+	conf.Net.SRV.Resolver = resolver
+
+	addrs, err := resolveSRV(conf, []string{"_kafka._tcp.a.internal", "_kafka._tcp.b.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"broker-1.a.internal:9092", "broker-2.a.internal:9092", "broker-3.b.internal:9093"}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("Expected %v, got %v", expected, addrs)
+	}
+
+	if _, err := resolveSRV(conf, []string{"_kafka._tcp.empty.internal"}); err == nil {
+		t.Error("Expected an error resolving a name without records")
+	}
+	var dnsErr *net.DNSError
+	if _, err := resolveSRV(conf, []string{"_kafka._tcp.missing.internal"}); !errors.As(err, &dnsErr) {
+		t.Errorf("Expected a DNS error resolving a missing name, got %v", err)
+	}
+}
@@ -25,6 +25,17 @@ type SyncProducer interface {
 	// SendMessages will return an error.
 	SendMessages(msgs []*ProducerMessage) error
 
+	// SendMessagesWithResults produces a given set of messages, and returns only
+	// when all messages in the set have either succeeded or failed. Unlike
+	// SendMessages, it returns one ProduceResult per message, in the same order
+	// as msgs, so that callers can retry only the messages that failed. The
+	// returned error is a ProducerErrors if any message failed to produce.
+	SendMessagesWithResults(msgs []*ProducerMessage) ([]ProduceResult, error)
+
+	// CheckMessage verifies, without sending it, that msg can currently be
+	// produced. See AsyncProducer.CheckMessage.
+	CheckMessage(msg *ProducerMessage) error
+
 	// Close shuts down the producer; you must call this function before a producer
 	// object passes out of scope, as it may otherwise leak memory.
 	// You must call this before calling Close on the underlying client.
@@ -52,6 +63,19 @@ type SyncProducer interface {
 	AddMessageToTxn(msg *ConsumerMessage, groupId string, metadata *string) error
 }
 
+// ProduceResult is the outcome of producing a single message with
+// SyncProducer.SendMessagesWithResults.
+type ProduceResult struct {
+	// Msg is the message this result belongs to.
+	Msg *ProducerMessage
+	// Partition and Offset are where the message was stored. Both are -1 if
+	// the message failed to produce.
+	Partition int32
+	Offset    int64
+	// Err is the error encountered producing the message, or nil on success.
+	Err error
+}
+
 type syncProducer struct {
 	producer *asyncProducer
 	wg       sync.WaitGroup
@@ -122,6 +146,11 @@ func (sp *syncProducer) SendMessage(msg *ProducerMessage) (partition int32, offs
 }
 
 func (sp *syncProducer) SendMessages(msgs []*ProducerMessage) error {
+	_, err := sp.SendMessagesWithResults(msgs)
+	return err
+}
+
+func (sp *syncProducer) SendMessagesWithResults(msgs []*ProducerMessage) ([]ProduceResult, error) {
 	expectations := make(chan chan *ProducerError, len(msgs))
 	go func() {
 		for _, msg := range msgs {
@@ -133,17 +162,26 @@ func (sp *syncProducer) SendMessages(msgs []*ProducerMessage) error {
 		close(expectations)
 	}()
 
+	results := make([]ProduceResult, 0, len(msgs))
 	var errors ProducerErrors
 	for expectation := range expectations {
+		msg := msgs[len(results)]
 		if pErr := <-expectation; pErr != nil {
 			errors = append(errors, pErr)
+			results = append(results, ProduceResult{Msg: msg, Partition: -1, Offset: -1, Err: pErr.Err})
+		} else {
+			results = append(results, ProduceResult{Msg: msg, Partition: msg.Partition, Offset: msg.Offset})
 		}
 	}
 
 	if len(errors) > 0 {
-		return errors
+		return results, errors
 	}
-	return nil
+	return results, nil
+}
+
+func (sp *syncProducer) CheckMessage(msg *ProducerMessage) error {
+	return sp.producer.CheckMessage(msg)
 }
 
 func (sp *syncProducer) handleSuccesses() {
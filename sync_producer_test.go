@@ -207,6 +207,63 @@ func TestSyncProducerBatch(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestSyncProducerBatchWithResults(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	prodSuccess.GetBlock("my_topic", 0).Offset = 42
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 2
+	config.Producer.Return.Successes = true
+	config.Producer.MaxMessageBytes = 100
+	producer, err := NewSyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []*ProducerMessage{
+		{Topic: "my_topic", Value: StringEncoder(TestMessage)},
+		{Topic: "my_topic", Value: ByteEncoder(make([]byte, 200))},
+		{Topic: "my_topic", Value: StringEncoder(TestMessage)},
+	}
+	results, err := producer.SendMessagesWithResults(msgs)
+
+	var pErrs ProducerErrors
+	if !errors.As(err, &pErrs) || len(pErrs) != 1 {
+		t.Fatalf("expected a single ProducerError, got %v", err)
+	}
+	if len(results) != len(msgs) {
+		t.Fatalf("expected %d results, got %d", len(msgs), len(results))
+	}
+	for i, result := range results {
+		if result.Msg != msgs[i] {
+			t.Errorf("result %d does not belong to message %d", i, i)
+		}
+	}
+	if results[0].Err != nil || results[0].Offset != 42 {
+		t.Errorf("unexpected result for first message: %+v", results[0])
+	}
+	if !errors.Is(results[1].Err, ErrMessageSizeTooLarge) || results[1].Offset != -1 || results[1].Partition != -1 {
+		t.Errorf("unexpected result for oversized message: %+v", results[1])
+	}
+	if results[2].Err != nil || results[2].Offset != 43 {
+		t.Errorf("unexpected result for last message: %+v", results[2])
+	}
+
+	safeClose(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
 func TestConcurrentSyncProducer(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 2)
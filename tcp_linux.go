@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package sarama
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+// tcpUserTimeout is the TCP_USER_TIMEOUT socket option, missing from the syscall package.
+const tcpUserTimeout = 0x12
+
+// defaultKeepAlive is the keep-alive period net.Dialer uses when its KeepAlive is 0.
+const defaultKeepAlive = 15 * time.Second
+
+// setTCPOptions sets the keep-alive interval and count, the user timeout and the buffer sizes of
+// the config on the sockets of the dialer. As net.Dialer sets the keep-alive interval to the keep-alive
+// period once connected, it is disabled when they are set, the socket options doing it.
+func setTCPOptions(dialer *net.Dialer, c *Config) {
+	keepAlive := c.Net.KeepAlive >= 0 && (c.Net.KeepAliveInterval > 0 || c.Net.KeepAliveCount > 0)
+	if !keepAlive && c.Net.UserTimeout <= 0 && c.Net.ReadBufferSize <= 0 && c.Net.WriteBufferSize <= 0 {
+		return
+	}
+
+	idle := c.Net.KeepAlive
+	if idle == 0 {
+		idle = defaultKeepAlive
+	}
+	interval := c.Net.KeepAliveInterval
+	if interval == 0 {
+		interval = idle
+	}
+	if keepAlive {
+		dialer.KeepAlive = -1
+	}
+
+	dialer.Control = func(network, address string, conn syscall.RawConn) error {
+		var err error
+		set := func(fd uintptr, level, opt, value int) {
+			if err == nil {
+				err = syscall.SetsockoptInt(int(fd), level, opt, value)
+			}
+		}
+		if cerr := conn.Control(func(fd uintptr) {
+			if keepAlive {
+				set(fd, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1)
+				set(fd, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, roundUpSeconds(idle))
+				set(fd, syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, roundUpSeconds(interval))
+				if c.Net.KeepAliveCount > 0 {
+					set(fd, syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, c.Net.KeepAliveCount)
+				}
+			}
+			if c.Net.UserTimeout > 0 {
+				set(fd, syscall.IPPROTO_TCP, tcpUserTimeout, int(c.Net.UserTimeout/time.Millisecond))
+			}
+			if c.Net.ReadBufferSize > 0 {
+				set(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, c.Net.ReadBufferSize)
+			}
+			if c.Net.WriteBufferSize > 0 {
+				set(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, c.Net.WriteBufferSize)
+			}
+		}); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+}
+
+// roundUpSeconds returns the duration in seconds, rounded up, as the keep-alive socket options
+// take.
+func roundUpSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
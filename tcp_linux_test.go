@@ -0,0 +1,94 @@
+//go:build linux
+// +build linux
+
+package sarama
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSetTCPOptions(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conf := NewTestConfig()
+	conf.Net.KeepAlive = 10 * time.Second
+	conf.Net.KeepAliveInterval = 1500 * time.Millisecond
+	conf.Net.KeepAliveCount = 3
+	conf.Net.UserTimeout = 5 * time.Second
+	conf.Net.ReadBufferSize = 64 << 10
+	conf.Net.WriteBufferSize = 32 << 10
+
+	conn, err := conf.getDialer().Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name       string
+		level, opt int
+		value      int
+	}{
+		{"SO_KEEPALIVE", syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1},
+		{"TCP_KEEPIDLE", syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, 10},
+		{"TCP_KEEPINTVL", syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, 2},
+		{"TCP_KEEPCNT", syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, 3},
+		{"TCP_USER_TIMEOUT", syscall.IPPROTO_TCP, tcpUserTimeout, 5000},
+		// the kernel doubles the buffer sizes to account for its bookkeeping
+		{"SO_RCVBUF", syscall.SOL_SOCKET, syscall.SO_RCVBUF, 128 << 10},
+		{"SO_SNDBUF", syscall.SOL_SOCKET, syscall.SO_SNDBUF, 64 << 10},
+	}
+	if err := raw.Control(func(fd uintptr) {
+		for _, e := range expected {
+			value, err := syscall.GetsockoptInt(int(fd), e.level, e.opt)
+			if err != nil {
+				t.Errorf("%s: %v", e.name, err)
+			} else if value != e.value {
+				t.Errorf("Expected %s to be %d, got %d", e.name, e.value, value)
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetTCPOptionsBeforeControl(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conf := NewTestConfig()
+	conf.Net.UserTimeout = 5 * time.Second
+	conf.Net.Control = func(network, address string, conn syscall.RawConn) error {
+		var value int
+		var err error
+		if cerr := conn.Control(func(fd uintptr) {
+			value, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout)
+		}); cerr != nil {
+			return cerr
+		}
+		if err == nil && value != 5000 {
+			t.Errorf("Expected the options of the config to be set before Control, got TCP_USER_TIMEOUT %d", value)
+		}
+		return err
+	}
+
+	conn, err := conf.getDialer().Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
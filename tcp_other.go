@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package sarama
+
+import "net"
+
+// setTCPOptions does nothing as the keep-alive interval and count, the user timeout and the
+// buffer sizes are only supported on Linux.
+func setTCPOptions(dialer *net.Dialer, c *Config) {}
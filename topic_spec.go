@@ -0,0 +1,225 @@
+package sarama
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TopicSpec is the desired definition of a topic, as applied by ClusterAdmin.ApplyTopicSpec.
+type TopicSpec struct {
+	Name string
+	// NumPartitions is the number of partitions of the topic, or -1 for the default of the
+	// brokers when the topic is created, in which case it is not compared once the topic exists.
+	NumPartitions int32
+	// ReplicationFactor is the number of replicas of each partition, or -1 for the default of
+	// the brokers when the topic is created, in which case it is not compared either.
+	ReplicationFactor int16
+	// ConfigEntries are all the configs set on the topic: the configs set on the topic but
+	// missing from the spec are deleted, so that the topic uses the defaults of the brokers.
+	ConfigEntries map[string]string
+}
+
+// TopicSpecDiff are the changes ClusterAdmin.ApplyTopicSpec makes to a topic to match its
+// spec, or would in dry-run mode.
+type TopicSpecDiff struct {
+	Topic string
+	// Create is true if the topic does not exist and is created from the spec.
+	Create bool
+	// NumPartitions is the number of partitions the topic is increased to, or 0 if it does not
+	// change.
+	NumPartitions int32
+	// NewPartitionsAssignment are the replicas of the partitions added when the replication
+	// factor increases too, from the first new partition, or nil to let the brokers assign them.
+	NewPartitionsAssignment [][]int32
+	// ReplicationFactor is the replication factor the existing partitions are increased to by
+	// reassigning them, or 0 if it does not change.
+	ReplicationFactor int16
+	// Reassignment are the replicas of the existing partitions after the replication factor
+	// increase, by partition ID, the partitions whose replicas do not change keeping theirs.
+	Reassignment [][]int32
+	// SetConfigs are the configs set on the topic, by name, and DeleteConfigs the ones reset to
+	// the defaults of the brokers.
+	SetConfigs    map[string]string
+	DeleteConfigs []string
+	// Conflicts are the differences between the topic and its spec that cannot be applied, such
+	// as a decrease of the number of partitions or of the replication factor, each wrapping
+	// ErrTopicSpecConflict. No change is applied to a topic with conflicts.
+	Conflicts []error
+}
+
+// Empty returns true if the topic already matches its spec.
+func (d *TopicSpecDiff) Empty() bool {
+	return !d.Create && d.NumPartitions == 0 && d.ReplicationFactor == 0 &&
+		len(d.SetConfigs) == 0 && len(d.DeleteConfigs) == 0 && len(d.Conflicts) == 0
+}
+
+// topicDetail returns the definition of the topic to create from the spec.
+func (s *TopicSpec) topicDetail() *TopicDetail {
+	detail := &TopicDetail{
+		NumPartitions:     s.NumPartitions,
+		ReplicationFactor: s.ReplicationFactor,
+		ConfigEntries:     make(map[string]*string, len(s.ConfigEntries)),
+	}
+	if detail.NumPartitions <= 0 {
+		detail.NumPartitions = -1
+	}
+	if detail.ReplicationFactor <= 0 {
+		detail.ReplicationFactor = -1
+	}
+	for name, value := range s.ConfigEntries {
+		value := value
+		detail.ConfigEntries[name] = &value
+	}
+	return detail
+}
+
+// diffTopicSpec compares the spec with the metadata and the configs of the existing topic,
+// the brokers being the ones the replicas added by a replication factor increase are assigned
+// to.
+func diffTopicSpec(spec *TopicSpec, topic *TopicMetadata, entries []ConfigEntry, brokers []*Broker) *TopicSpecDiff {
+	diff := &TopicSpecDiff{Topic: spec.Name}
+
+	partitions := make([]*PartitionMetadata, len(topic.Partitions))
+	copy(partitions, topic.Partitions)
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+	current := int32(len(partitions))
+
+	if spec.NumPartitions > 0 {
+		if spec.NumPartitions < current {
+			diff.Conflicts = append(diff.Conflicts, fmt.Errorf("%w: cannot decrease the number of partitions of %s from %d to %d",
+				ErrTopicSpecConflict, spec.Name, current, spec.NumPartitions))
+		} else if spec.NumPartitions > current {
+			diff.NumPartitions = spec.NumPartitions
+		}
+	}
+
+	if spec.ReplicationFactor > 0 {
+		increase := false
+		for _, partition := range partitions {
+			replicas := len(partition.Replicas)
+			if replicas > int(spec.ReplicationFactor) {
+				diff.Conflicts = append(diff.Conflicts, fmt.Errorf("%w: cannot decrease the replication factor of %s-%d from %d to %d",
+					ErrTopicSpecConflict, spec.Name, partition.ID, replicas, spec.ReplicationFactor))
+			} else if replicas < int(spec.ReplicationFactor) {
+				increase = true
+			}
+		}
+		if increase || diff.NumPartitions > 0 {
+			diff.planReplicationFactor(spec, partitions, brokers, increase)
+		}
+	}
+
+	diff.diffConfigs(spec.ConfigEntries, entries)
+	return diff
+}
+
+// planReplicationFactor assigns the replicas added to the existing partitions if increase is
+// true, and the replicas of the new partitions if the replication factor changes.
+func (d *TopicSpecDiff) planReplicationFactor(spec *TopicSpec, partitions []*PartitionMetadata, brokers []*Broker, increase bool) {
+	if int(spec.ReplicationFactor) > len(brokers) {
+		d.Conflicts = append(d.Conflicts, fmt.Errorf("%w: replication factor %d of %s larger than the %d available brokers",
+			ErrTopicSpecConflict, spec.ReplicationFactor, spec.Name, len(brokers)))
+		return
+	}
+	if !increase {
+		// the brokers assign the new partitions the replication factor of the existing ones
+		return
+	}
+
+	d.ReplicationFactor = spec.ReplicationFactor
+	d.Reassignment = addReplicas(partitions, brokers, spec.ReplicationFactor)
+	if d.NumPartitions > 0 {
+		assignment, err := assignReplicas(brokers, d.NumPartitions-int32(len(partitions)), spec.ReplicationFactor,
+			&ReplicaAssignmentOptions{StartPartition: int32(len(partitions))})
+		if err != nil {
+			d.Conflicts = append(d.Conflicts, fmt.Errorf("%w: %v", ErrTopicSpecConflict, err))
+			return
+		}
+		d.NewPartitionsAssignment = assignment
+	}
+}
+
+// addReplicas returns the replicas of the partitions, by partition ID, after adding replicas to
+// the partitions with fewer than replicationFactor. The brokers of the racks without a replica
+// of the partition are picked first, then the ones with the fewest replicas of the topic.
+func addReplicas(partitions []*PartitionMetadata, brokers []*Broker, replicationFactor int16) [][]int32 {
+	load := make(map[int32]int, len(brokers))
+	rackOf := make(map[int32]string, len(brokers))
+	for _, b := range brokers {
+		load[b.ID()] = 0
+		rackOf[b.ID()] = b.Rack()
+	}
+	for _, partition := range partitions {
+		for _, id := range partition.Replicas {
+			load[id]++
+		}
+	}
+
+	assignment := make([][]int32, len(partitions))
+	for i, partition := range partitions {
+		replicas := append([]int32(nil), partition.Replicas...)
+		for len(replicas) < int(replicationFactor) {
+			assigned := make(map[int32]bool, len(replicas))
+			racks := make(map[string]bool, len(replicas))
+			for _, id := range replicas {
+				assigned[id] = true
+				racks[rackOf[id]] = true
+			}
+			best := int32(-1)
+			for _, b := range brokers {
+				id := b.ID()
+				if assigned[id] {
+					continue
+				}
+				if best < 0 || addReplicaLess(id, best, racks, rackOf, load) {
+					best = id
+				}
+			}
+			replicas = append(replicas, best)
+			load[best]++
+		}
+		assignment[i] = replicas
+	}
+	return assignment
+}
+
+func addReplicaLess(a, b int32, racks map[string]bool, rackOf map[int32]string, load map[int32]int) bool {
+	if racks[rackOf[a]] != racks[rackOf[b]] {
+		return !racks[rackOf[a]]
+	}
+	if load[a] != load[b] {
+		return load[a] < load[b]
+	}
+	return a < b
+}
+
+// diffConfigs compares the desired configs with the ones set on the topic. Sensitive configs
+// have no value to compare with, so they are only set if they are not set on the topic yet.
+func (d *TopicSpecDiff) diffConfigs(desired map[string]string, entries []ConfigEntry) {
+	current := make(map[string]*ConfigEntry)
+	for i := range entries {
+		entry := &entries[i]
+		// brokers older than 1.1.0.0 only tell whether configs are default ones
+		if entry.Default || (entry.Source != SourceTopic && entry.Source != SourceUnknown) {
+			continue
+		}
+		current[entry.Name] = entry
+	}
+
+	for name, value := range desired {
+		entry := current[name]
+		if entry != nil && (entry.Sensitive || entry.Value == value) {
+			continue
+		}
+		if d.SetConfigs == nil {
+			d.SetConfigs = make(map[string]string)
+		}
+		d.SetConfigs[name] = value
+	}
+	for name := range current {
+		if _, ok := desired[name]; !ok {
+			d.DeleteConfigs = append(d.DeleteConfigs, name)
+		}
+	}
+	sort.Strings(d.DeleteConfigs)
+}
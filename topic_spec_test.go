@@ -0,0 +1,99 @@
+package sarama
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testTopicSpecMetadata() *TopicMetadata {
+	return &TopicMetadata{
+		Name: "my_topic",
+		Partitions: []*PartitionMetadata{
+			{ID: 2, Replicas: []int32{3, 1}},
+			{ID: 0, Replicas: []int32{1, 2}},
+			{ID: 1, Replicas: []int32{2, 3}},
+		},
+	}
+}
+
+func TestDiffTopicSpec(t *testing.T) {
+	brokers := testRackBrokers(map[int32]string{1: "a", 2: "b", 3: "c", 4: "a"})
+	entries := []ConfigEntry{
+		{Name: "retention.ms", Value: "5000", Source: SourceTopic},
+		{Name: "cleanup.policy", Value: "compact", Source: SourceTopic},
+		{Name: "segment.bytes", Value: "1073741824", Default: true, Source: SourceDefault},
+		{Name: "sasl.jaas.config", Sensitive: true, Source: SourceTopic},
+	}
+	spec := &TopicSpec{
+		Name:              "my_topic",
+		NumPartitions:     4,
+		ReplicationFactor: 3,
+		ConfigEntries: map[string]string{
+			"retention.ms":        "6000",
+			"sasl.jaas.config":    "secret",
+			"min.insync.replicas": "2",
+		},
+	}
+
+	diff := diffTopicSpec(spec, testTopicSpecMetadata(), entries, brokers)
+	if len(diff.Conflicts) != 0 {
+		t.Fatalf("Expected no conflict, got %v", diff.Conflicts)
+	}
+	if diff.Empty() || diff.Create || diff.NumPartitions != 4 || diff.ReplicationFactor != 3 {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+	expected := [][]int32{{1, 2, 3}, {2, 3, 4}, {3, 1, 2}}
+	if !reflect.DeepEqual(diff.Reassignment, expected) {
+		t.Errorf("Expected reassignment %v, got %v", expected, diff.Reassignment)
+	}
+	if len(diff.NewPartitionsAssignment) != 1 || len(diff.NewPartitionsAssignment[0]) != 3 {
+		t.Errorf("Expected 3 replicas for the new partition, got %v", diff.NewPartitionsAssignment)
+	}
+	if !reflect.DeepEqual(diff.SetConfigs, map[string]string{"retention.ms": "6000", "min.insync.replicas": "2"}) {
+		t.Errorf("Unexpected configs to set %v", diff.SetConfigs)
+	}
+	if !reflect.DeepEqual(diff.DeleteConfigs, []string{"cleanup.policy"}) {
+		t.Errorf("Unexpected configs to delete %v", diff.DeleteConfigs)
+	}
+}
+
+func TestDiffTopicSpecUnchanged(t *testing.T) {
+	brokers := testRackBrokers(map[int32]string{1: "a", 2: "b", 3: "c"})
+	entries := []ConfigEntry{{Name: "retention.ms", Value: "5000", Source: SourceTopic}}
+	spec := &TopicSpec{
+		Name:              "my_topic",
+		NumPartitions:     -1,
+		ReplicationFactor: 2,
+		ConfigEntries:     map[string]string{"retention.ms": "5000"},
+	}
+
+	diff := diffTopicSpec(spec, testTopicSpecMetadata(), entries, brokers)
+	if !diff.Empty() {
+		t.Errorf("Expected an empty diff, got %+v", diff)
+	}
+}
+
+func TestDiffTopicSpecConflicts(t *testing.T) {
+	brokers := testRackBrokers(map[int32]string{1: "a", 2: "b", 3: "c"})
+	spec := &TopicSpec{Name: "my_topic", NumPartitions: 2, ReplicationFactor: 1}
+
+	diff := diffTopicSpec(spec, testTopicSpecMetadata(), nil, brokers)
+	if len(diff.Conflicts) != 4 {
+		t.Fatalf("Expected a partition count conflict and 3 replication factor ones, got %v", diff.Conflicts)
+	}
+	for _, err := range diff.Conflicts {
+		if !errors.Is(err, ErrTopicSpecConflict) {
+			t.Errorf("Expected ErrTopicSpecConflict, got %v", err)
+		}
+	}
+	if diff.Empty() || diff.NumPartitions != 0 || diff.ReplicationFactor != 0 {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+
+	spec = &TopicSpec{Name: "my_topic", ReplicationFactor: 4}
+	diff = diffTopicSpec(spec, testTopicSpecMetadata(), nil, brokers)
+	if len(diff.Conflicts) != 1 || !errors.Is(diff.Conflicts[0], ErrTopicSpecConflict) {
+		t.Errorf("Expected a conflict for the missing brokers, got %v", diff.Conflicts)
+	}
+}
@@ -512,6 +512,9 @@ func (t *transactionManager) initProducerId() (int64, int16, error) {
 			coordinator, err = t.client.TransactionCoordinator(t.transactionalID)
 		} else {
 			coordinator = t.client.LeastLoadedBroker()
+			if coordinator == nil {
+				err = ErrOutOfBrokers
+			}
 		}
 		if err != nil {
 			return -1, -1, true, err
@@ -0,0 +1,138 @@
+package sarama
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TransformFunc turns a consumed message into the messages to produce for it, possibly none.
+type TransformFunc func(msg *ConsumerMessage) ([]*ProducerMessage, error)
+
+// TransactionalRelay is a ConsumerGroupHandler implementing the consume-transform-produce loop of
+// exactly-once relays. Each batch of consumed messages is transformed and the resulting messages
+// are produced in a transaction, alongside the offsets of the consumed messages, so that either
+// both the output and the consumer group progress are committed or neither is.
+//
+// The producer must be transactional and used by the relay only; the transactions of the claims
+// of a session are serialized on it. Consumers of the output topics should set
+// Consumer.IsolationLevel to ReadCommitted to only see committed transactions.
+//
+// If a transaction fails, it is aborted and ConsumeClaim returns the error, leaving the claim
+// unconsumed until the next session, which resumes from the last committed offset.
+type TransactionalRelay struct {
+	// MaxBatchSize is the maximum number of consumed messages relayed in a single transaction
+	// (defaults to 100). Messages already buffered by the claim are batched up to this size,
+	// a transaction never waits for more messages to arrive.
+	MaxBatchSize int
+
+	noopSetupCleanup
+	producer  SyncProducer
+	groupID   string
+	transform TransformFunc
+
+	lock sync.Mutex
+}
+
+// NewTransactionalRelay creates a TransactionalRelay producing with the given transactional
+// producer and committing the offsets of consumer group groupID, which must be the group the
+// relay is used to consume with.
+func NewTransactionalRelay(producer SyncProducer, groupID string, transform TransformFunc) (*TransactionalRelay, error) {
+	if producer == nil || !producer.IsTransactional() {
+		return nil, ConfigurationError("TransactionalRelay requires a transactional producer")
+	}
+	if groupID == "" {
+		return nil, ConfigurationError("TransactionalRelay requires a consumer group ID")
+	}
+	if transform == nil {
+		return nil, ConfigurationError("TransactionalRelay requires a transform function")
+	}
+	return &TransactionalRelay{
+		MaxBatchSize: 100,
+		producer:     producer,
+		groupID:      groupID,
+		transform:    transform,
+	}, nil
+}
+
+// ConsumeClaim implements ConsumerGroupHandler, relaying the messages of the claim until it is
+// closed or a transaction fails.
+func (r *TransactionalRelay) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	messages, stop := claimMessages(sess, claim)
+	defer stop()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			if err := r.relay(r.batch(msg, messages)); err != nil {
+				return err
+			}
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// batch collects the messages already buffered by the claim, up to MaxBatchSize.
+func (r *TransactionalRelay) batch(first *ConsumerMessage, messages <-chan *ConsumerMessage) []*ConsumerMessage {
+	batch := []*ConsumerMessage{first}
+	for len(batch) < r.MaxBatchSize {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, msg)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (r *TransactionalRelay) relay(batch []*ConsumerMessage) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.producer.BeginTxn(); err != nil {
+		return err
+	}
+
+	if err := r.produce(batch); err != nil {
+		if abortErr := r.producer.AbortTxn(); abortErr != nil {
+			Logger.Printf("relay/%s unable to abort transaction: %v\n", r.groupID, abortErr)
+		}
+		return err
+	}
+
+	if err := r.producer.CommitTxn(); err != nil {
+		if r.producer.TxnStatus()&ProducerTxnFlagAbortableError != 0 {
+			if abortErr := r.producer.AbortTxn(); abortErr != nil {
+				Logger.Printf("relay/%s unable to abort transaction: %v\n", r.groupID, abortErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *TransactionalRelay) produce(batch []*ConsumerMessage) error {
+	var out []*ProducerMessage
+	for _, msg := range batch {
+		msgs, err := r.transform(msg)
+		if err != nil {
+			return fmt.Errorf("kafka: unable to transform message at offset %d of %s/%d: %w", msg.Offset, msg.Topic, msg.Partition, err)
+		}
+		out = append(out, msgs...)
+	}
+
+	if len(out) > 0 {
+		if err := r.producer.SendMessages(out); err != nil {
+			return err
+		}
+	}
+
+	// the whole batch comes from the same claim, only the last offset needs to be committed
+	return r.producer.AddMessageToTxn(batch[len(batch)-1], r.groupID, nil)
+}
@@ -0,0 +1,116 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type recordingTxnProducer struct {
+	SyncProducer
+	calls []string
+	sent  []*ProducerMessage
+}
+
+func (p *recordingTxnProducer) IsTransactional() bool { return true }
+
+func (p *recordingTxnProducer) TxnStatus() ProducerTxnStatusFlag { return ProducerTxnFlagReady }
+
+func (p *recordingTxnProducer) BeginTxn() error {
+	p.calls = append(p.calls, "begin")
+	return nil
+}
+
+func (p *recordingTxnProducer) SendMessages(msgs []*ProducerMessage) error {
+	p.calls = append(p.calls, "send")
+	p.sent = append(p.sent, msgs...)
+	return nil
+}
+
+func (p *recordingTxnProducer) AddMessageToTxn(msg *ConsumerMessage, groupID string, metadata *string) error {
+	p.calls = append(p.calls, "offset:"+groupID+":"+string(msg.Value))
+	return nil
+}
+
+func (p *recordingTxnProducer) CommitTxn() error {
+	p.calls = append(p.calls, "commit")
+	return nil
+}
+
+func (p *recordingTxnProducer) AbortTxn() error {
+	p.calls = append(p.calls, "abort")
+	return nil
+}
+
+type testRelaySession struct {
+	ConsumerGroupSession
+}
+
+func (s testRelaySession) Context() context.Context { return context.Background() }
+
+type testRelayClaim struct {
+	ConsumerGroupClaim
+	messages chan *ConsumerMessage
+}
+
+func (c testRelayClaim) Messages() <-chan *ConsumerMessage { return c.messages }
+
+func TestTransactionalRelay(t *testing.T) {
+	producer := &recordingTxnProducer{}
+	relay, err := NewTransactionalRelay(producer, "my-group", func(msg *ConsumerMessage) ([]*ProducerMessage, error) {
+		if string(msg.Value) == "skip" {
+			return nil, nil
+		}
+		return []*ProducerMessage{{Topic: "out", Value: StringEncoder(strings.ToUpper(string(msg.Value)))}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.MaxBatchSize = 2
+
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 3)}
+	for i, value := range []string{"a", "skip", "b"} {
+		claim.messages <- &ConsumerMessage{Topic: "in", Offset: int64(i), Value: []byte(value)}
+	}
+	close(claim.messages)
+
+	if err := relay.ConsumeClaim(testRelaySession{}, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "begin send offset:my-group:skip commit begin send offset:my-group:b commit"
+	if calls := strings.Join(producer.calls, " "); calls != expected {
+		t.Errorf("expected calls %q, got %q", expected, calls)
+	}
+	if len(producer.sent) != 2 {
+		t.Errorf("expected 2 messages to be produced, got %d", len(producer.sent))
+	}
+}
+
+func TestTransactionalRelayAbortsOnError(t *testing.T) {
+	producer := &recordingTxnProducer{}
+	boom := errors.New("boom")
+	relay, err := NewTransactionalRelay(producer, "my-group", func(msg *ConsumerMessage) ([]*ProducerMessage, error) {
+		return nil, boom
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := testRelayClaim{messages: make(chan *ConsumerMessage, 1)}
+	claim.messages <- &ConsumerMessage{Topic: "in", Value: []byte("a")}
+
+	if err := relay.ConsumeClaim(testRelaySession{}, claim); !errors.Is(err, boom) {
+		t.Errorf("expected the transform error, got %v", err)
+	}
+	if calls := strings.Join(producer.calls, " "); calls != "begin abort" {
+		t.Errorf("expected the transaction to be aborted, got %q", calls)
+	}
+}
+
+func TestNewTransactionalRelayRequiresTransactionalProducer(t *testing.T) {
+	if _, err := NewTransactionalRelay(nil, "my-group", func(*ConsumerMessage) ([]*ProducerMessage, error) { return nil, nil }); err == nil {
+		t.Error("expected an error without producer")
+	}
+}
@@ -0,0 +1,13 @@
+package sarama
+
+import "testing"
+
+var unregisterBrokerRequest = []byte{
+	0, 0, 0, 4, // broker ID
+	0, // empty tagged fields
+}
+
+func TestUnregisterBrokerRequest(t *testing.T) {
+	request := &UnregisterBrokerRequest{BrokerID: 4}
+	testRequest(t, "broker 4", request, unregisterBrokerRequest)
+}
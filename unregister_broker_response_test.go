@@ -0,0 +1,31 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	unregisterBrokerResponse = []byte{
+		0, 0, 0, 100, // throttle time
+		0, 0, // no error
+		0, // no error message
+		0, // empty tagged fields
+	}
+
+	unregisterBrokerResponseError = []byte{
+		0, 0, 0, 0, // throttle time
+		0, 31, // cluster authorization failed
+		7, 'd', 'e', 'n', 'i', 'e', 'd', // error message
+		0, // empty tagged fields
+	}
+)
+
+func TestUnregisterBrokerResponse(t *testing.T) {
+	response := &UnregisterBrokerResponse{ThrottleTime: 100 * time.Millisecond}
+	testResponse(t, "no error", response, unregisterBrokerResponse)
+
+	message := "denied"
+	response = &UnregisterBrokerResponse{ErrorCode: ErrClusterAuthorizationFailed, ErrorMessage: &message}
+	testResponse(t, "error", response, unregisterBrokerResponseError)
+}
@@ -0,0 +1,142 @@
+package sarama
+
+import "time"
+
+// UpdateFeaturesRequest (Version: 1) => timeout_ms [feature_updates] validate_only TAG_BUFFER
+//   timeout_ms => INT32
+//   feature_updates => feature max_version_level upgrade_type TAG_BUFFER
+//     feature => COMPACT_STRING
+//     max_version_level => INT16
+//     upgrade_type => INT8
+//   validate_only => BOOLEAN
+
+// FeatureUpgradeType is how the version level of a feature is updated (KIP-778).
+type FeatureUpgradeType int8
+
+const (
+	// FeatureUpgrade only allows raising the version level of the feature.
+	FeatureUpgrade FeatureUpgradeType = 1
+	// FeatureSafeDowngrade allows lowering the version level of the feature if no metadata
+	// is lost doing so.
+	FeatureSafeDowngrade FeatureUpgradeType = 2
+	// FeatureUnsafeDowngrade allows lowering the version level of the feature even if
+	// metadata is lost doing so.
+	FeatureUnsafeDowngrade FeatureUpgradeType = 3
+)
+
+// FeatureUpdate is the update of the finalized version level of a feature.
+type FeatureUpdate struct {
+	Feature string
+	// MaxVersionLevel is the new version level of the feature, 0 deleting it, which requires
+	// a downgrade.
+	MaxVersionLevel int16
+	// UpgradeType is sent as a flag allowing downgrades in version 0, in which case downgrades
+	// are safe ones.
+	UpgradeType FeatureUpgradeType
+}
+
+// UpdateFeaturesRequest updates the finalized features of the cluster, it is sent to the
+// controller.
+type UpdateFeaturesRequest struct {
+	Version        int16
+	Timeout        time.Duration
+	FeatureUpdates []FeatureUpdate
+	// ValidateOnly only checks the updates, from version 1.
+	ValidateOnly bool
+}
+
+func (r *UpdateFeaturesRequest) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	pe.putCompactArrayLength(len(r.FeatureUpdates))
+	for _, update := range r.FeatureUpdates {
+		if err := pe.putCompactString(update.Feature); err != nil {
+			return err
+		}
+		pe.putInt16(update.MaxVersionLevel)
+		if r.Version == 0 {
+			pe.putBool(update.UpgradeType == FeatureSafeDowngrade || update.UpgradeType == FeatureUnsafeDowngrade)
+		} else {
+			pe.putInt8(int8(update.UpgradeType))
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	if r.Version > 0 {
+		pe.putBool(r.ValidateOnly)
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *UpdateFeaturesRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.FeatureUpdates = make([]FeatureUpdate, n)
+	}
+	for i := 0; i < n; i++ {
+		update := &r.FeatureUpdates[i]
+		if update.Feature, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if update.MaxVersionLevel, err = pd.getInt16(); err != nil {
+			return err
+		}
+		if r.Version == 0 {
+			allowDowngrade, err := pd.getBool()
+			if err != nil {
+				return err
+			}
+			update.UpgradeType = FeatureUpgrade
+			if allowDowngrade {
+				update.UpgradeType = FeatureSafeDowngrade
+			}
+		} else {
+			upgradeType, err := pd.getInt8()
+			if err != nil {
+				return err
+			}
+			update.UpgradeType = FeatureUpgradeType(upgradeType)
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	if r.Version > 0 {
+		if r.ValidateOnly, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *UpdateFeaturesRequest) key() int16 {
+	return 57
+}
+
+func (r *UpdateFeaturesRequest) version() int16 {
+	return r.Version
+}
+
+func (r *UpdateFeaturesRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *UpdateFeaturesRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V3_3_0_0
+	default:
+		return V2_7_0_0
+	}
+}
@@ -0,0 +1,43 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	updateFeaturesRequestV0 = []byte{
+		0, 0, 0x03, 0xe8, // timeout: 1s
+		2, // 1 update
+		17, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+		0, 7, // max version level
+		1, // allow downgrade
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+
+	updateFeaturesRequestV1 = []byte{
+		0, 0, 0x03, 0xe8, // timeout: 1s
+		2, // 1 update
+		17, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+		0, 7, // max version level
+		2, // safe downgrade
+		0, // empty tagged fields
+		1, // validate only
+		0, // empty tagged fields
+	}
+)
+
+func TestUpdateFeaturesRequest(t *testing.T) {
+	request := &UpdateFeaturesRequest{
+		Timeout: time.Second,
+		FeatureUpdates: []FeatureUpdate{
+			{Feature: "metadata.version", MaxVersionLevel: 7, UpgradeType: FeatureSafeDowngrade},
+		},
+	}
+	testRequest(t, "v0", request, updateFeaturesRequestV0)
+
+	request.Version = 1
+	request.ValidateOnly = true
+	testRequest(t, "v1", request, updateFeaturesRequestV1)
+}
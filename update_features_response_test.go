@@ -0,0 +1,29 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var updateFeaturesResponse = []byte{
+	0, 0, 0, 100, // throttle time
+	0, 0, // no error
+	0, // no error message
+	2, // 1 result
+	17, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+	0, 42, // invalid request
+	4, 'b', 'a', 'd', // error message
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestUpdateFeaturesResponse(t *testing.T) {
+	message := "bad"
+	response := &UpdateFeaturesResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Results: []UpdatableFeatureResult{
+			{Feature: "metadata.version", ErrorCode: ErrInvalidRequest, ErrorMessage: &message},
+		},
+	}
+	testResponse(t, "one result", response, updateFeaturesResponse)
+}
@@ -0,0 +1,32 @@
+package sarama
+
+import "encoding/base64"
+
+// Uuid is the 128-bit universally unique identifier Kafka gives to a topic when creating it
+// (KIP-516), so that a topic deleted and recreated with the same name gets a different ID.
+type Uuid [16]byte
+
+// String returns the URL-safe base64 encoding without padding of the ID, the representation
+// Kafka uses for topic IDs.
+func (u Uuid) String() string {
+	return base64.RawURLEncoding.EncodeToString(u[:])
+}
+
+// IsZero returns true for the zero ID, which Kafka uses for an unknown or missing topic ID.
+func (u Uuid) IsZero() bool {
+	return u == Uuid{}
+}
+
+func putUuid(pe packetEncoder, u Uuid) error {
+	return pe.putRawBytes(u[:])
+}
+
+func getUuid(pd packetDecoder) (Uuid, error) {
+	var u Uuid
+	buf, err := pd.getRawBytes(len(u))
+	if err != nil {
+		return u, err
+	}
+	copy(u[:], buf)
+	return u, nil
+}
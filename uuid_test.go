@@ -0,0 +1,29 @@
+package sarama
+
+import "testing"
+
+func TestUuid(t *testing.T) {
+	id := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0xff}
+	if s := id.String(); s != "AQIDBAUGBwgJCgsMDQ4P_w" {
+		t.Errorf("unexpected string form %s", s)
+	}
+	if id.IsZero() || !(Uuid{}).IsZero() {
+		t.Error("unexpected zero ID")
+	}
+
+	raw, err := encode(encoderFunc(func(pe packetEncoder) error { return putUuid(pe, id) }), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Uuid
+	err = decode(raw, decoderFunc(func(pd packetDecoder) (err error) {
+		decoded, err = getUuid(pd)
+		return err
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != id {
+		t.Errorf("expected %s, got %s", id, decoded)
+	}
+}
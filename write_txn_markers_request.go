@@ -0,0 +1,102 @@
+package sarama
+
+// WriteTxnMarker is a marker committing or aborting the transaction of a producer on
+// partitions.
+type WriteTxnMarker struct {
+	ProducerID    int64
+	ProducerEpoch int16
+	// Commit is true to commit the transaction, false to abort it.
+	Commit           bool
+	Topics           map[string][]int32
+	CoordinatorEpoch int32
+}
+
+// WriteTxnMarkersRequest writes transaction markers to the partitions the broker leads. It is
+// normally sent by transaction coordinators, and by administrators to abort hanging
+// transactions.
+type WriteTxnMarkersRequest struct {
+	Version int16
+	Markers []*WriteTxnMarker
+}
+
+func (r *WriteTxnMarkersRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Markers)); err != nil {
+		return err
+	}
+	for _, marker := range r.Markers {
+		pe.putInt64(marker.ProducerID)
+		pe.putInt16(marker.ProducerEpoch)
+		pe.putBool(marker.Commit)
+		if err := pe.putArrayLength(len(marker.Topics)); err != nil {
+			return err
+		}
+		for topic, partitions := range marker.Topics {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putInt32Array(partitions); err != nil {
+				return err
+			}
+		}
+		pe.putInt32(marker.CoordinatorEpoch)
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.Markers = make([]*WriteTxnMarker, n)
+	}
+	for i := 0; i < n; i++ {
+		marker := new(WriteTxnMarker)
+		if marker.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if marker.ProducerEpoch, err = pd.getInt16(); err != nil {
+			return err
+		}
+		if marker.Commit, err = pd.getBool(); err != nil {
+			return err
+		}
+		numTopics, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		marker.Topics = make(map[string][]int32, numTopics)
+		for j := 0; j < numTopics; j++ {
+			topic, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if marker.Topics[topic], err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+		if marker.CoordinatorEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+		r.Markers[i] = marker
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersRequest) key() int16 {
+	return 27
+}
+
+func (r *WriteTxnMarkersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *WriteTxnMarkersRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *WriteTxnMarkersRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var writeTxnMarkersRequest = []byte{
+	0, 0, 0, 1, // 1 marker
+	0, 0, 0, 0, 0, 0, 0, 42, // producer ID
+	0, 3, // producer epoch
+	0,          // abort
+	0, 0, 0, 1, // 1 topic
+	0, 5, 't', 'o', 'p', 'i', 'c', // topic name
+	0, 0, 0, 1, 0, 0, 0, 1, // partition 1
+	0, 0, 0, 5, // coordinator epoch
+}
+
+func TestWriteTxnMarkersRequest(t *testing.T) {
+	request := &WriteTxnMarkersRequest{
+		Markers: []*WriteTxnMarker{{
+			ProducerID:       42,
+			ProducerEpoch:    3,
+			Topics:           map[string][]int32{"topic": {1}},
+			CoordinatorEpoch: 5,
+		}},
+	}
+	testRequest(t, "abort", request, writeTxnMarkersRequest)
+}
@@ -0,0 +1,94 @@
+package sarama
+
+// WriteTxnMarkersResponse are the errors of the partitions of a WriteTxnMarkersRequest by
+// producer ID, topic and partition.
+type WriteTxnMarkersResponse struct {
+	Version int16
+	Errors  map[int64]map[string]map[int32]KError
+}
+
+func (r *WriteTxnMarkersResponse) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Errors)); err != nil {
+		return err
+	}
+	for producerID, topics := range r.Errors {
+		pe.putInt64(producerID)
+		if err := pe.putArrayLength(len(topics)); err != nil {
+			return err
+		}
+		for topic, partitions := range topics {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putArrayLength(len(partitions)); err != nil {
+				return err
+			}
+			for partition, kerr := range partitions {
+				pe.putInt32(partition)
+				pe.putInt16(int16(kerr))
+			}
+		}
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Errors = make(map[int64]map[string]map[int32]KError, n)
+	for i := 0; i < n; i++ {
+		producerID, err := pd.getInt64()
+		if err != nil {
+			return err
+		}
+		numTopics, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		topics := make(map[string]map[int32]KError, numTopics)
+		for j := 0; j < numTopics; j++ {
+			topic, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			numPartitions, err := pd.getArrayLength()
+			if err != nil {
+				return err
+			}
+			partitions := make(map[int32]KError, numPartitions)
+			for k := 0; k < numPartitions; k++ {
+				partition, err := pd.getInt32()
+				if err != nil {
+					return err
+				}
+				kerr, err := pd.getInt16()
+				if err != nil {
+					return err
+				}
+				partitions[partition] = KError(kerr)
+			}
+			topics[topic] = partitions
+		}
+		r.Errors[producerID] = topics
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersResponse) key() int16 {
+	return 27
+}
+
+func (r *WriteTxnMarkersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *WriteTxnMarkersResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *WriteTxnMarkersResponse) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
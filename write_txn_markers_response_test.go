@@ -0,0 +1,22 @@
+package sarama
+
+import "testing"
+
+var writeTxnMarkersResponse = []byte{
+	0, 0, 0, 1, // 1 marker
+	0, 0, 0, 0, 0, 0, 0, 42, // producer ID
+	0, 0, 0, 1, // 1 topic
+	0, 5, 't', 'o', 'p', 'i', 'c', // topic name
+	0, 0, 0, 1, // 1 partition
+	0, 0, 0, 1, // partition 1
+	0, 0, // no error
+}
+
+func TestWriteTxnMarkersResponse(t *testing.T) {
+	response := &WriteTxnMarkersResponse{
+		Errors: map[int64]map[string]map[int32]KError{
+			42: {"topic": {1: ErrNoError}},
+		},
+	}
+	testResponse(t, "one partition", response, writeTxnMarkersResponse)
+}